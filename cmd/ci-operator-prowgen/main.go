@@ -4,12 +4,15 @@ import (
 	"flag"
 	"fmt"
 	"go/build"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
 
 	"github.com/sirupsen/logrus"
 
+	"sigs.k8s.io/yaml"
+
 	prowconfig "sigs.k8s.io/prow/pkg/config"
 	"sigs.k8s.io/prow/pkg/flagutil"
 
@@ -23,6 +26,11 @@ import (
 	"github.com/openshift/ci-tools/pkg/util"
 )
 
+const (
+	backendProw   = "prow"
+	backendTekton = "tekton"
+)
+
 type options struct {
 	config.Options
 
@@ -37,6 +45,14 @@ type options struct {
 
 	knownInfraJobFiles flagutil.Strings
 
+	explain        string
+	provenanceFile string
+	provenance     prowgen.Provenance
+
+	backend        string
+	writer         jc.Writer
+	tektonReposDir string
+
 	help bool
 }
 
@@ -55,6 +71,12 @@ func bindOptions(flag *flag.FlagSet) *options {
 
 	flag.Var(&opt.knownInfraJobFiles, "known-infra-file", "Name of a known infra-file that will not be acted on. Can be passed multiple times.")
 
+	flag.StringVar(&opt.explain, "explain", "", "Name of a generated job to explain: print which part of the ci-operator configuration set its fields instead of writing the jobs")
+	flag.StringVar(&opt.provenanceFile, "provenance-file", "", "Path to a file to write the explanation of all generated jobs to")
+
+	flag.StringVar(&opt.backend, "backend", backendProw, fmt.Sprintf("What to generate: %q for Prow jobs or %q for Tekton PipelineRuns triggered by Pipelines as Code, written to the %s directory of every repository in --to-dir or --tekton-repos-dir. Pipelines as Code cannot schedule PipelineRuns, so no PipelineRuns are generated for periodics", backendProw, backendTekton, prowgen.PipelineRunsDir))
+	flag.StringVar(&opt.tektonReposDir, "tekton-repos-dir", "", fmt.Sprintf("Path to a directory holding the checkouts of the repositories as <org>/<repo>, like $GOPATH/src/github.com. If set with --backend=%s, the PipelineRuns are written to the %s directory at the root of every checkout, where Pipelines as Code reads them from", backendTekton, prowgen.PipelineRunsDir))
+
 	opt.Options.Bind(flag)
 
 	return opt
@@ -63,6 +85,22 @@ func bindOptions(flag *flag.FlagSet) *options {
 func (o *options) process() error {
 	var err error
 
+	switch o.backend {
+	case backendProw:
+		o.writer = jc.WriteToDir
+	case backendTekton:
+		if o.toReleaseRepo {
+			return fmt.Errorf("--backend=%s cannot be used with --to-release-repo, the release repository only holds Prow jobs", backendTekton)
+		}
+		o.writer = prowgen.WritePipelineRunsToDir
+	default:
+		return fmt.Errorf("--backend must be one of %q or %q", backendProw, backendTekton)
+	}
+
+	if o.tektonReposDir != "" && o.backend != backendTekton {
+		return fmt.Errorf("--tekton-repos-dir can only be used with --backend=%s", backendTekton)
+	}
+
 	if o.fromReleaseRepo {
 		if o.fromDir, err = getReleaseRepoDir("ci-operator/config"); err != nil {
 			return fmt.Errorf("--from-release-repo error: %w", err)
@@ -79,7 +117,7 @@ func (o *options) process() error {
 		return fmt.Errorf("ci-operator-prowgen needs exactly one of `--from-{dir,release-repo}` options")
 	}
 
-	if o.toDir == "" {
+	if o.toDir == "" && o.tektonReposDir == "" && o.explain == "" {
 		return fmt.Errorf("ci-operator-prowgen needs exactly one of `--to-{dir,release-repo}` options")
 	}
 
@@ -105,23 +143,62 @@ func (o *options) process() error {
 // consuming ci-operator configuration.
 func (o *options) generateJobsToDir(subDir string) error {
 	generated := map[string]*prowconfig.JobConfig{}
-	genJobsFunc := generateJobs(o.resolver, generated)
+	if o.provenance == nil {
+		o.provenance = prowgen.Provenance{}
+	}
+	genJobsFunc := generateJobs(o.resolver, generated, o.provenance)
 	if err := o.OperateOnCIOperatorConfigDir(filepath.Join(o.fromDir, subDir), genJobsFunc); err != nil {
 		return fmt.Errorf("failed to generate jobs: %w", err)
 	}
-	if err := o.OperateOnJobConfigSubdirPaths(o.toDir, subDir, o.knownInfraJobFiles.StringSet(), func(info *jc.Info) error {
-		key := fmt.Sprintf("%s/%s", info.Org, info.Repo)
-		if _, ok := generated[key]; !ok {
-			generated[key] = &prowconfig.JobConfig{}
-		}
+	if o.explain != "" {
 		return nil
-	}); err != nil {
-		return fmt.Errorf("failed to read job directory paths: %w", err)
 	}
-	return writeToDir(o.toDir, generated)
+	if o.toDir != "" {
+		if err := o.OperateOnJobConfigSubdirPaths(o.toDir, subDir, o.knownInfraJobFiles.StringSet(), func(info *jc.Info) error {
+			key := fmt.Sprintf("%s/%s", info.Org, info.Repo)
+			if _, ok := generated[key]; !ok {
+				generated[key] = &prowconfig.JobConfig{}
+			}
+			return nil
+		}); err != nil {
+			return fmt.Errorf("failed to read job directory paths: %w", err)
+		}
+	}
+	writer := o.writer
+	if writer == nil {
+		writer = jc.WriteToDir
+	}
+	toDir := o.toDir
+	if o.tektonReposDir != "" {
+		toDir = o.tektonReposDir
+	}
+	return writeToDir(toDir, generated, writer)
+}
+
+// explainJob prints which part of the ci-operator configuration set the fields
+// of the generated job
+func (o *options) explainJob(out io.Writer) error {
+	provenance, ok := o.provenance[o.explain]
+	if !ok {
+		return fmt.Errorf("no job named %q was generated", o.explain)
+	}
+	_, err := fmt.Fprintf(out, "%s (%s):\n%s\n", o.explain, provenance.Kind, provenance)
+	return err
+}
+
+// writeProvenance writes the explanation of all generated jobs to the provenance file
+func (o *options) writeProvenance() error {
+	raw, err := yaml.Marshal(o.provenance)
+	if err != nil {
+		return fmt.Errorf("failed to marshal the provenance: %w", err)
+	}
+	if err := os.WriteFile(o.provenanceFile, raw, 0644); err != nil {
+		return fmt.Errorf("failed to write the provenance: %w", err)
+	}
+	return nil
 }
 
-func generateJobs(resolver registry.Resolver, output map[string]*prowconfig.JobConfig) func(configSpec *cioperatorapi.ReleaseBuildConfiguration, info *config.Info) error {
+func generateJobs(resolver registry.Resolver, output map[string]*prowconfig.JobConfig, provenance prowgen.Provenance) func(configSpec *cioperatorapi.ReleaseBuildConfiguration, info *config.Info) error {
 	return func(configSpec *cioperatorapi.ReleaseBuildConfiguration, info *config.Info) error {
 		orgRepo := fmt.Sprintf("%s/%s", info.Org, info.Repo)
 		var clusterProfileResolver func(name string) (*api.ClusterProfile, error) = func(name string) (*api.ClusterProfile, error) {
@@ -143,10 +220,13 @@ func generateJobs(resolver registry.Resolver, output map[string]*prowconfig.JobC
 			}
 		}
 
-		generated, err := prowgen.GenerateJobs(configSpec, &info.Metadata, clusterProfileResolver)
+		generated, explained, err := prowgen.GenerateJobsWithProvenance(configSpec, &info.Metadata, clusterProfileResolver)
 		if err != nil {
 			return err
 		}
+		for name, job := range explained {
+			provenance[name] = job
+		}
 		if o, ok := output[orgRepo]; ok {
 			jc.Append(o, generated)
 		} else {
@@ -164,7 +244,7 @@ func getReleaseRepoDir(directory string) (string, error) {
 	return "", fmt.Errorf("%s is not an existing directory", tentative)
 }
 
-func writeToDir(dir string, c map[string]*prowconfig.JobConfig) error {
+func writeToDir(dir string, c map[string]*prowconfig.JobConfig, writer jc.Writer) error {
 	type item struct {
 		k string
 		v *prowconfig.JobConfig
@@ -182,7 +262,7 @@ func writeToDir(dir string, c map[string]*prowconfig.JobConfig) error {
 		for x := range ch {
 			i := strings.Index(x.k, "/")
 			org, repo := x.k[:i], x.k[i+1:]
-			if err := jc.WriteToDir(dir, org, repo, x.v, prowgen.Generator, nil); err != nil {
+			if err := writer(dir, org, repo, x.v, prowgen.Generator, nil); err != nil {
 				errCh <- err
 			}
 		}
@@ -218,4 +298,14 @@ func main() {
 			logger.WithError(err).Fatal("Failed to generate jobs")
 		}
 	}
+	if opt.provenanceFile != "" {
+		if err := opt.writeProvenance(); err != nil {
+			logger.WithError(err).Fatal("Failed to write the provenance of the jobs")
+		}
+	}
+	if opt.explain != "" {
+		if err := opt.explainJob(os.Stdout); err != nil {
+			logger.WithError(err).Fatal("Failed to explain the job")
+		}
+	}
 }
@@ -310,3 +310,17 @@ tests:
 		})
 	}
 }
+
+func TestProcessRejectsTektonInReleaseRepo(t *testing.T) {
+	o := &options{backend: backendTekton, toReleaseRepo: true}
+	if err := o.process(); err == nil || !strings.Contains(err.Error(), "--to-release-repo") {
+		t.Errorf("expected PipelineRuns to not be written to the release repository, got %v", err)
+	}
+}
+
+func TestProcessRejectsTektonReposDirWithProw(t *testing.T) {
+	o := &options{backend: backendProw, tektonReposDir: "/repos"}
+	if err := o.process(); err == nil || !strings.Contains(err.Error(), "--tekton-repos-dir") {
+		t.Errorf("expected --tekton-repos-dir to be rejected with the Prow backend, got %v", err)
+	}
+}
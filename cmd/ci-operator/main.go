@@ -81,6 +81,7 @@ import (
 	"github.com/openshift/ci-tools/pkg/api"
 	"github.com/openshift/ci-tools/pkg/api/configresolver"
 	"github.com/openshift/ci-tools/pkg/api/nsttl"
+	"github.com/openshift/ci-tools/pkg/config"
 	"github.com/openshift/ci-tools/pkg/defaults"
 	gsm "github.com/openshift/ci-tools/pkg/gsm-secrets"
 	"github.com/openshift/ci-tools/pkg/interrupt"
@@ -361,6 +362,8 @@ type options struct {
 	leaseServer                string
 	leaseServerCredentialsFile string
 	leaseAcquireTimeout        time.Duration
+	leaseBroker                string
+	leasePriority              string
 	leaseClient                lease.Client
 	clusterProfiles            []metrics.ClusterProfileForTarget
 
@@ -436,6 +439,8 @@ func bindOptions(flag *flag.FlagSet) *options {
 	flag.StringVar(&opt.leaseServer, "lease-server", leaseServerAddress, "Address of the server that manages leases. Required if any test is configured to acquire a lease.")
 	flag.StringVar(&opt.leaseServerCredentialsFile, "lease-server-credentials-file", "", "The path to credentials file used to access the lease server. The content is of the form <username>:<password>.")
 	flag.DurationVar(&opt.leaseAcquireTimeout, "lease-acquire-timeout", leaseAcquireTimeout, "Maximum amount of time to wait for lease acquisition")
+	flag.StringVar(&opt.leaseBroker, "lease-broker", "", "Address of a lease broker which queues lease requests by priority in front of the lease server. If unset, leases are acquired from the lease server directly.")
+	flag.StringVar(&opt.leasePriority, "lease-priority", "", "Priority class of the lease requests sent to the lease broker (presubmit, release-blocking, periodic or rehearsal). Derived from the job if unset.")
 	flag.StringVar(&opt.registryPath, "registry", "", "Path to the step registry directory")
 	flag.StringVar(&opt.configSpecPath, "config", "", "The configuration file. If not specified the CONFIG_SPEC environment variable or the configresolver will be used.")
 	flag.StringVar(&opt.unresolvedConfigPath, "unresolved-config", "", "The configuration file, before resolution. If not specified the UNRESOLVED_CONFIG environment variable will be used, if set.")
@@ -1978,6 +1983,7 @@ func (o *options) writeFailingJUnit(errs []error) {
 			FailureOutput: &junit.FailureOutput{
 				Output: err.Error(),
 			},
+			Properties: []*junit.Property{{Name: results.SignatureProperty, Value: results.Signature(err.Error())}},
 		})
 	}
 	if len(testCases) == 0 {
@@ -2096,7 +2102,23 @@ func (o *options) initializeLeaseClient() error {
 
 	o.metricsAgent.Record(metrics.NewInsightsEvent(metrics.InsightLeaseCredentials, metrics.Context{"lease_server": o.leaseServer, "username": username}))
 
-	if o.leaseClient, err = lease.NewClient(owner, o.leaseServer, username, passwordGetter, 60, o.leaseAcquireTimeout); err != nil {
+	var opts []lease.ClientOptions
+	if o.leaseBroker != "" {
+		priority := lease.PriorityClassForJob(o.jobSpec)
+		if o.leasePriority != "" {
+			if priority, err = lease.ParsePriorityClass(o.leasePriority); err != nil {
+				return fmt.Errorf("invalid --lease-priority: %w", err)
+			}
+		}
+		logrus.Debugf("Using lease broker %s with priority class %s", o.leaseBroker, priority)
+		opts = append(opts,
+			lease.WithBroker(lease.NewBrokerClient(o.leaseBroker, owner, username, passwordGetter), priority),
+			lease.WithQueueStatusReporter(func(rtype string, status lease.QueueStatus) {
+				logrus.Infof("Waiting for a lease of %s: position %d of %d in the queue, estimated wait %s", rtype, status.Position, status.Depth, status.EstimatedWait())
+			}),
+		)
+	}
+	if o.leaseClient, err = lease.NewClient(owner, o.leaseServer, username, passwordGetter, 60, o.leaseAcquireTimeout, opts...); err != nil {
 		return fmt.Errorf("failed to create the lease client: %w", err)
 	}
 	return nil
@@ -2483,6 +2505,9 @@ func (o *options) loadConfig(info *api.Metadata, gcsReader gcsFileReader) (*api.
 		if err != nil {
 			return nil, fmt.Errorf("--config error: %w", err)
 		}
+		if data, err = config.ExpandLayers(o.configSpecPath, data); err != nil {
+			return nil, fmt.Errorf("--config error: %w", err)
+		}
 		raw = string(data)
 	case configSpecSet:
 		if len(configSpecEnv) == 0 {
@@ -2510,10 +2535,16 @@ func (o *options) loadConfig(info *api.Metadata, gcsReader gcsFileReader) (*api.
 		if err != nil {
 			return nil, fmt.Errorf("--unresolved-config error: %w", err)
 		}
+		if data, err = config.ExpandLayers(o.unresolvedConfigPath, data); err != nil {
+			return nil, fmt.Errorf("--unresolved-config error: %w", err)
+		}
 		configSpec, err := o.resolverClient.Resolve(data)
 		err = results.ForReason("config_resolver_literal").ForError(err)
 		return configSpec, err
 	case unresolvedConfigSet:
+		if err := config.RejectLayers([]byte(unresolvedConfigEnv)); err != nil {
+			return nil, fmt.Errorf("%s error: %w", unresolvedConfigVar, err)
+		}
 		configSpec, err := o.resolverClient.Resolve([]byte(unresolvedConfigEnv))
 		err = results.ForReason("config_resolver_literal").ForError(err)
 		return configSpec, err
@@ -2522,6 +2553,9 @@ func (o *options) loadConfig(info *api.Metadata, gcsReader gcsFileReader) (*api.
 		err = results.ForReason("config_resolver").ForError(err)
 		return configSpec, err
 	}
+	if err := config.RejectLayers([]byte(raw)); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
 	configSpec := api.ReleaseBuildConfiguration{}
 	if err := yaml.UnmarshalStrict([]byte(raw), &configSpec); err != nil {
 		if len(o.configSpecPath) > 0 {
@@ -904,6 +904,13 @@ func TestConfig(t *testing.T) {
 			expected:      nil,
 			expectedError: true,
 		},
+		{
+			name:          "layered config that was not expanded results in error",
+			config:        "extends:\n- e2e\n" + rawConfig,
+			asEnv:         true,
+			expected:      nil,
+			expectedError: true,
+		},
 	}
 
 	for _, testCase := range testCases {
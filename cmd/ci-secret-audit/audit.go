@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/sirupsen/logrus"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/openshift/ci-tools/pkg/api"
+	"github.com/openshift/ci-tools/pkg/api/secretbootstrap"
+	vaultapi "github.com/openshift/ci-tools/pkg/api/vault"
+	gsmvalidation "github.com/openshift/ci-tools/pkg/gsm-validation"
+	"github.com/openshift/ci-tools/pkg/secrets"
+)
+
+type findingKind string
+
+const (
+	// findingDrift is reported for keys whose value in the cluster differs from the one synced
+	findingDrift findingKind = "drift"
+	// findingOrphanedKey is reported for keys that are not synced from any source
+	findingOrphanedKey findingKind = "orphaned-key"
+	// findingOrphanedSecret is reported for secrets created by ci-secret-bootstrap that are not configured anymore
+	findingOrphanedSecret findingKind = "orphaned-secret"
+	// findingNoProvenance is reported for secrets that were not synced since provenance has been recorded
+	findingNoProvenance findingKind = "no-provenance"
+	// findingStale is reported for keys whose upstream item changed after the last sync
+	findingStale findingKind = "stale"
+)
+
+type finding struct {
+	Cluster   string      `json:"cluster"`
+	Namespace string      `json:"namespace"`
+	Name      string      `json:"name"`
+	Key       string      `json:"key,omitempty"`
+	Kind      findingKind `json:"kind"`
+	Message   string      `json:"message"`
+}
+
+// versionGetter returns the current version of the upstream source of a key
+type versionGetter func(source secretbootstrap.KeyProvenance) (string, error)
+
+// expectedSecrets maps clusters to the secrets configured on them and their keys;
+// keys are nil when they are only known after fetching the secret, like for GSM bundles
+// without explicit fields
+type expectedSecrets map[string]map[types.NamespacedName]sets.Set[string]
+
+func (e expectedSecrets) insert(cluster string, secret types.NamespacedName, keys sets.Set[string]) {
+	if e[cluster] == nil {
+		e[cluster] = map[types.NamespacedName]sets.Set[string]{}
+	}
+	existing, ok := e[cluster][secret]
+	switch {
+	case !ok:
+		e[cluster][secret] = keys
+	case existing == nil || keys == nil:
+		e[cluster][secret] = nil
+	default:
+		e[cluster][secret] = existing.Union(keys)
+	}
+}
+
+// clusters returns the clusters secrets are synced to
+func (e expectedSecrets) clusters(userSecretsTargetClusters []string) sets.Set[string] {
+	return sets.KeySet(map[string]map[types.NamespacedName]sets.Set[string](e)).Insert(userSecretsTargetClusters...)
+}
+
+// expectedFromConfigs determines the secrets ci-secret-bootstrap syncs from its configuration
+func expectedFromConfigs(vaultConfig secretbootstrap.Config, gsmConfig api.GSMConfig) expectedSecrets {
+	expected := expectedSecrets{}
+	for _, secretConfig := range vaultConfig.Secrets {
+		keys := sets.New[string]()
+		for key := range secretConfig.From {
+			keys.Insert(key)
+		}
+		for _, to := range secretConfig.To {
+			expected.insert(to.Cluster, types.NamespacedName{Namespace: to.Namespace, Name: to.Name}, keys)
+		}
+	}
+	for _, bundle := range gsmConfig.Bundles {
+		if !bundle.SyncToCluster {
+			continue
+		}
+		keys := sets.New[string]()
+		for _, secret := range bundle.GSMSecrets {
+			if len(secret.Fields) == 0 {
+				keys = nil
+				break
+			}
+			for _, field := range secret.Fields {
+				if field.As != "" {
+					keys.Insert(field.As)
+				} else {
+					keys.Insert(gsmvalidation.DenormalizeName(field.Name))
+				}
+			}
+		}
+		if keys != nil && bundle.DockerConfig != nil {
+			if bundle.DockerConfig.As != "" {
+				keys.Insert(bundle.DockerConfig.As)
+			} else {
+				keys.Insert(".dockerconfigjson")
+			}
+		}
+		for _, target := range bundle.Targets {
+			expected.insert(target.Cluster, types.NamespacedName{Namespace: target.Namespace, Name: bundle.Name}, keys)
+		}
+	}
+	return expected
+}
+
+// audit compares the secrets created by ci-secret-bootstrap on the clusters with
+// the provenance recorded on them, their configuration and their upstream items
+func audit(ctx context.Context, getters map[string]secrets.Getter, expected expectedSecrets, upstreamVersion versionGetter) ([]finding, error) {
+	var findings []finding
+	var errs []error
+	versions := map[secretbootstrap.KeyProvenance]string{}
+	for _, cluster := range sets.List(sets.KeySet(getters)) {
+		logger := logrus.WithField("cluster", cluster)
+		list, err := getters[cluster].Secrets(metav1.NamespaceAll).List(ctx, metav1.ListOptions{LabelSelector: fmt.Sprintf("%s=ci-secret-bootstrap", api.DPTPRequesterLabel)})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to list secrets on cluster %s: %w", cluster, err))
+			continue
+		}
+		logger.WithField("secrets", len(list.Items)).Debug("Auditing secrets")
+		for i := range list.Items {
+			secret := &list.Items[i]
+			report := func(key string, kind findingKind, format string, args ...interface{}) {
+				findings = append(findings, finding{Cluster: cluster, Namespace: secret.Namespace, Name: secret.Name, Key: key, Kind: kind, Message: fmt.Sprintf(format, args...)})
+			}
+
+			_, isUserSecret := secret.Data[vaultapi.VaultSourceKey]
+			configuredKeys, configured := expected[cluster][types.NamespacedName{Namespace: secret.Namespace, Name: secret.Name}]
+			if !configured && !isUserSecret {
+				report("", findingOrphanedSecret, "the secret is not configured to be synced anymore")
+				continue
+			}
+
+			provenance, err := secretbootstrap.ProvenanceFor(secret)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("secret %s:%s/%s: %w", cluster, secret.Namespace, secret.Name, err))
+				continue
+			}
+			if provenance == nil {
+				report("", findingNoProvenance, "the secret has no provenance recorded")
+				continue
+			}
+
+			for _, key := range sets.List(sets.KeySet(secret.Data)) {
+				source, synced := provenance[key]
+				switch {
+				case !synced:
+					report(key, findingOrphanedKey, "the key was not synced from any source")
+				case configuredKeys != nil && !isUserSecret && !configuredKeys.Has(key):
+					report(key, findingOrphanedKey, "the key is not configured to be synced anymore")
+				case secretbootstrap.HashValue(secret.Data[key]) != source.Hash:
+					report(key, findingDrift, "the value differs from the one synced from %s", describe(source))
+				}
+			}
+
+			for _, key := range sets.List(sets.KeySet(provenance)) {
+				source := provenance[key]
+				if _, ok := secret.Data[key]; !ok {
+					report(key, findingDrift, "the key synced from %s was removed", describe(source))
+					continue
+				}
+				if source.Version == "" {
+					continue
+				}
+				upstream := source
+				upstream.Hash, upstream.Version = "", ""
+				current, ok := versions[upstream]
+				if !ok {
+					current, err = upstreamVersion(upstream)
+					if err != nil {
+						errs = append(errs, fmt.Errorf("failed to get the version of %s: %w", describe(source), err))
+						continue
+					}
+					versions[upstream] = current
+				}
+				if current != "" && current != source.Version {
+					report(key, findingStale, "%s changed to version %s after version %s was synced", describe(source), current, source.Version)
+				}
+			}
+		}
+	}
+
+	sort.Slice(findings, func(i, j int) bool {
+		a, b := findings[i], findings[j]
+		if a.Cluster != b.Cluster {
+			return a.Cluster < b.Cluster
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		if a.Key != b.Key {
+			return a.Key < b.Key
+		}
+		return a.Kind < b.Kind
+	})
+	return findings, utilerrors.NewAggregate(errs)
+}
+
+// describe names the source of a key for humans
+func describe(source secretbootstrap.KeyProvenance) string {
+	var description string
+	switch source.Backend {
+	case secretbootstrap.ProvenanceBackendGSM:
+		description = fmt.Sprintf("GSM %s/%s", source.Collection, source.Group)
+	default:
+		description = fmt.Sprintf("Vault item %s", source.Item)
+	}
+	if source.Field != "" {
+		description += fmt.Sprintf(" (field %s)", source.Field)
+	}
+	return description
+}
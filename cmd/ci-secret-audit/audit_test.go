@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	coreapi "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/openshift/ci-tools/pkg/api"
+	"github.com/openshift/ci-tools/pkg/api/secretbootstrap"
+	"github.com/openshift/ci-tools/pkg/secrets"
+	"github.com/openshift/ci-tools/pkg/testhelper"
+)
+
+func syncedSecret(t *testing.T, namespace, name string, data map[string]string, provenance secretbootstrap.Provenance) *coreapi.Secret {
+	t.Helper()
+	secret := &coreapi.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      name,
+			Labels:    map[string]string{api.DPTPRequesterLabel: "ci-secret-bootstrap"},
+		},
+		Data: map[string][]byte{},
+	}
+	for key, value := range data {
+		secret.Data[key] = []byte(value)
+	}
+	if provenance != nil {
+		if err := secretbootstrap.SetProvenance(secret, provenance); err != nil {
+			t.Fatalf("failed to set provenance: %v", err)
+		}
+	}
+	return secret
+}
+
+func TestExpectedFromConfigs(t *testing.T) {
+	vaultConfig := secretbootstrap.Config{Secrets: []secretbootstrap.SecretConfig{
+		{
+			From: map[string]secretbootstrap.ItemContext{"a": {Item: "item", Field: "a"}},
+			To: []secretbootstrap.SecretContext{
+				{Cluster: "build01", Namespace: "ci", Name: "secret"},
+				{Cluster: "build02", Namespace: "ci", Name: "secret"},
+			},
+		},
+		{
+			From: map[string]secretbootstrap.ItemContext{"b": {Item: "item", Field: "b"}},
+			To:   []secretbootstrap.SecretContext{{Cluster: "build01", Namespace: "ci", Name: "secret"}},
+		},
+	}}
+	gsmConfig := api.GSMConfig{Bundles: []api.GSMBundle{
+		{
+			Name:          "bundle",
+			SyncToCluster: true,
+			GSMSecrets:    []api.GSMSecretRef{{Collection: "c", Group: "g", Fields: []api.FieldEntry{{Name: "username"}, {Name: "token", As: "api-token"}}}},
+			DockerConfig:  &api.DockerConfigSpec{},
+			Targets:       []api.TargetSpec{{Cluster: "build01", Namespace: "ci"}},
+		},
+		{
+			Name:          "discovered",
+			SyncToCluster: true,
+			GSMSecrets:    []api.GSMSecretRef{{Collection: "c", Group: "g"}},
+			Targets:       []api.TargetSpec{{Cluster: "build02", Namespace: "ci"}},
+		},
+		{
+			Name:       "not-synced",
+			GSMSecrets: []api.GSMSecretRef{{Collection: "c", Group: "g"}},
+			Targets:    []api.TargetSpec{{Cluster: "build03", Namespace: "ci"}},
+		},
+	}}
+	expected := expectedSecrets{
+		"build01": {
+			{Namespace: "ci", Name: "secret"}: sets.New[string]("a", "b"),
+			{Namespace: "ci", Name: "bundle"}: sets.New[string]("username", "api-token", ".dockerconfigjson"),
+		},
+		"build02": {
+			{Namespace: "ci", Name: "secret"}:     sets.New[string]("a"),
+			{Namespace: "ci", Name: "discovered"}: nil,
+		},
+	}
+	if diff := cmp.Diff(expected, expectedFromConfigs(vaultConfig, gsmConfig)); diff != "" {
+		t.Errorf("expected secrets differ from expected: %s", diff)
+	}
+}
+
+func TestAudit(t *testing.T) {
+	source := func(item, field, version, value string) secretbootstrap.KeyProvenance {
+		return secretbootstrap.KeyProvenance{Backend: "vault", Item: item, Field: field, Version: version, Hash: secretbootstrap.HashValue([]byte(value))}
+	}
+	testCases := []struct {
+		name     string
+		secrets  []runtime.Object
+		expected expectedSecrets
+		versions map[string]string
+
+		expectedFindings []finding
+		expectedErr      error
+	}{
+		{
+			name: "secrets match their sources",
+			secrets: []runtime.Object{
+				syncedSecret(t, "ci", "secret", map[string]string{"a": "value"}, secretbootstrap.Provenance{"a": source("item", "a", "2", "value")}),
+				syncedSecret(t, "user", "secret", map[string]string{"secretsync-vault-source-path": "prefix/user", "b": "value"}, secretbootstrap.Provenance{
+					"secretsync-vault-source-path": source("prefix/user", "secretsync-vault-source-path", "", "prefix/user"),
+					"b":                            source("prefix/user", "b", "", "value"),
+				}),
+			},
+			expected: expectedSecrets{"build01": {{Namespace: "ci", Name: "secret"}: sets.New[string]("a")}},
+			versions: map[string]string{"item": "2"},
+		},
+		{
+			name: "drift, orphans and stale items are reported",
+			secrets: []runtime.Object{
+				syncedSecret(t, "ci", "secret", map[string]string{"a": "changed", "b": "value", "c": "value", "e": "value"}, secretbootstrap.Provenance{
+					"a": source("item", "a", "2", "value"),
+					"b": source("item", "b", "2", "value"),
+					"d": source("item", "d", "2", "value"),
+					"e": source("other", "e", "1", "value"),
+				}),
+				syncedSecret(t, "ci", "removed", map[string]string{"a": "value"}, secretbootstrap.Provenance{"a": source("item", "a", "2", "value")}),
+				syncedSecret(t, "ci", "unsynced", map[string]string{"a": "value"}, nil),
+			},
+			expected: expectedSecrets{"build01": {
+				{Namespace: "ci", Name: "secret"}:   sets.New[string]("a", "c", "d", "e"),
+				{Namespace: "ci", Name: "unsynced"}: nil,
+			}},
+			versions: map[string]string{"item": "2", "other": "3"},
+			expectedFindings: []finding{
+				{Cluster: "build01", Namespace: "ci", Name: "removed", Kind: "orphaned-secret", Message: "the secret is not configured to be synced anymore"},
+				{Cluster: "build01", Namespace: "ci", Name: "secret", Key: "a", Kind: "drift", Message: "the value differs from the one synced from Vault item item (field a)"},
+				{Cluster: "build01", Namespace: "ci", Name: "secret", Key: "b", Kind: "orphaned-key", Message: "the key is not configured to be synced anymore"},
+				{Cluster: "build01", Namespace: "ci", Name: "secret", Key: "c", Kind: "orphaned-key", Message: "the key was not synced from any source"},
+				{Cluster: "build01", Namespace: "ci", Name: "secret", Key: "d", Kind: "drift", Message: "the key synced from Vault item item (field d) was removed"},
+				{Cluster: "build01", Namespace: "ci", Name: "secret", Key: "e", Kind: "stale", Message: "Vault item other (field e) changed to version 3 after version 1 was synced"},
+				{Cluster: "build01", Namespace: "ci", Name: "unsynced", Kind: "no-provenance", Message: "the secret has no provenance recorded"},
+			},
+		},
+		{
+			name: "failing version lookups are reported",
+			secrets: []runtime.Object{
+				syncedSecret(t, "ci", "secret", map[string]string{"a": "value"}, secretbootstrap.Provenance{"a": source("missing", "a", "2", "value")}),
+			},
+			expected:    expectedSecrets{"build01": {{Namespace: "ci", Name: "secret"}: sets.New[string]("a")}},
+			expectedErr: errors.New("failed to get the version of Vault item missing (field a): no item missing"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			getters := map[string]secrets.Getter{"build01": fake.NewSimpleClientset(tc.secrets...).CoreV1()}
+			versions := func(source secretbootstrap.KeyProvenance) (string, error) {
+				version, ok := tc.versions[source.Item]
+				if !ok {
+					return "", errors.New("no item " + source.Item)
+				}
+				return version, nil
+			}
+			findings, err := audit(context.Background(), getters, tc.expected, versions)
+			testhelper.Diff(t, "error", err, tc.expectedErr, testhelper.EquateErrorMessage)
+			if diff := cmp.Diff(tc.expectedFindings, findings); diff != "" {
+				t.Errorf("findings differ from expected: %s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+
+	secretmanager "cloud.google.com/go/secretmanager/apiv1"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/api/option"
+
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/prow/pkg/flagutil"
+	"sigs.k8s.io/prow/pkg/logrusutil"
+	"sigs.k8s.io/yaml"
+
+	"github.com/openshift/ci-tools/pkg/api"
+	"github.com/openshift/ci-tools/pkg/api/secretbootstrap"
+	gsm "github.com/openshift/ci-tools/pkg/gsm-secrets"
+	"github.com/openshift/ci-tools/pkg/prowconfigutils"
+	"github.com/openshift/ci-tools/pkg/secrets"
+)
+
+type options struct {
+	secrets           secrets.CLIOptions
+	kubernetesOptions flagutil.KubernetesOptions
+
+	configPath           string
+	enableGsm            bool
+	gsmConfigPath        string
+	gsmProjectConfigPath string
+	gsmCredentialsFile   string
+
+	cluster    string
+	reportPath string
+	logLevel   string
+}
+
+func parseOptions(censor *secrets.DynamicCensor) (options, error) {
+	o := options{kubernetesOptions: flagutil.KubernetesOptions{NOInClusterConfigDefault: true}}
+	fs := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	o.kubernetesOptions.AddFlags(fs)
+	fs.StringVar(&o.configPath, "config", "", "Path to the ci-secret-bootstrap config file.")
+	fs.BoolVar(&o.enableGsm, "enable-gsm", false, "Whether to audit secrets synced from Google Secret Manager bundles")
+	fs.StringVar(&o.gsmConfigPath, "gsm-config", "", "Path to the Google Secret Manager config file.")
+	fs.StringVar(&o.gsmCredentialsFile, "gsm-credentials-file", "", "Path to Google Secret Manager service account credentials.")
+	fs.StringVar(&o.gsmProjectConfigPath, "gsm-project-config", "", "Path to the GCP project config file where secrets are stored.")
+	fs.StringVar(&o.cluster, "cluster", "", "If set, only audit secrets on this cluster")
+	fs.StringVar(&o.reportPath, "report-path", "", "If set, write the findings to this file instead of the standard output.")
+	fs.StringVar(&o.logLevel, "log-level", "info", fmt.Sprintf("Log level is one of %v.", logrus.AllLevels))
+	o.secrets.Bind(fs, os.Getenv, censor)
+	if err := fs.Parse(os.Args[1:]); err != nil {
+		return options{}, err
+	}
+	return o, nil
+}
+
+func (o *options) validate() error {
+	var errs []error
+	level, err := logrus.ParseLevel(o.logLevel)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("invalid log level specified: %w", err))
+	}
+	logrus.SetLevel(level)
+	errs = append(errs, o.secrets.Validate())
+	if o.configPath == "" {
+		errs = append(errs, errors.New("--config is required"))
+	}
+	if o.enableGsm {
+		if o.gsmConfigPath == "" {
+			errs = append(errs, errors.New("--gsm-config is required when --enable-gsm is true"))
+		}
+		if o.gsmProjectConfigPath == "" {
+			errs = append(errs, errors.New("--gsm-project-config is required when --enable-gsm is true"))
+		}
+		if o.gsmCredentialsFile == "" {
+			errs = append(errs, errors.New("--gsm-credentials-file is required when --enable-gsm is true"))
+		}
+	}
+	errs = append(errs, o.kubernetesOptions.Validate(false))
+	return utilerrors.NewAggregate(errs)
+}
+
+// upstreamVersions looks up the current versions of the items in Vault and GSM
+func upstreamVersions(ctx context.Context, vaultClient secrets.ReadOnlyClient, gsmClient gsm.SecretVersionGetter, gsmProjectConfig gsm.Config) versionGetter {
+	return func(source secretbootstrap.KeyProvenance) (string, error) {
+		switch source.Backend {
+		case secretbootstrap.ProvenanceBackendGSM:
+			if gsmClient == nil {
+				return "", nil
+			}
+			resourceName := gsm.GetGSMSecretResourceName(gsmProjectConfig.ProjectIdNumber, source.Collection, source.Group, source.Field)
+			return gsm.GetLatestSecretVersion(ctx, gsmClient, resourceName)
+		default:
+			version, err := vaultClient.GetItemVersion(source.Item)
+			if err != nil || version == 0 {
+				return "", err
+			}
+			return strconv.Itoa(version), nil
+		}
+	}
+}
+
+func main() {
+	logrusutil.ComponentInit()
+	censor := secrets.NewDynamicCensor()
+	logrus.SetFormatter(logrusutil.NewFormatterWithCensor(logrus.StandardLogger().Formatter, &censor))
+	o, err := parseOptions(&censor)
+	if err != nil {
+		logrus.WithError(err).Fatalf("cannot parse args: %q", os.Args[1:])
+	}
+	if err := o.validate(); err != nil {
+		logrus.WithError(err).Fatal("Invalid arguments.")
+	}
+	if err := o.secrets.Complete(&censor); err != nil {
+		logrus.WithError(err).Fatal("Failed to complete options.")
+	}
+
+	var vaultConfig secretbootstrap.Config
+	if err := secretbootstrap.LoadConfigFromFile(o.configPath, &vaultConfig); err != nil {
+		logrus.WithError(err).Fatal("Failed to load the config.")
+	}
+	var gsmConfig api.GSMConfig
+	var gsmProjectConfig gsm.Config
+	var gsmClient gsm.SecretVersionGetter
+	ctx := context.Background()
+	if o.enableGsm {
+		if err := api.LoadGSMConfigFromFile(o.gsmConfigPath, &gsmConfig); err != nil {
+			logrus.WithError(err).Fatal("Failed to load the GSM config.")
+		}
+		if err := api.LoadGSMProjectConfigFromFile(o.gsmProjectConfigPath, &gsmProjectConfig); err != nil {
+			logrus.WithError(err).Fatal("Failed to load the GSM project config.")
+		}
+		client, err := secretmanager.NewClient(ctx, option.WithCredentialsFile(o.gsmCredentialsFile))
+		if err != nil {
+			logrus.WithError(err).Fatal("Failed to create GSM client.")
+		}
+		defer client.Close()
+		gsmClient = client
+	}
+	vaultClient, err := o.secrets.NewReadOnlyClient(&censor)
+	if err != nil {
+		logrus.WithError(err).Fatal("Failed to create client.")
+	}
+
+	prowDisabledClusters, err := prowconfigutils.ProwDisabledClusters(&o.kubernetesOptions)
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to get Prow disable clusters")
+	}
+	kubeConfigs, err := o.kubernetesOptions.LoadClusterConfigs()
+	if err != nil {
+		logrus.WithError(err).Fatal("Failed to load cluster configs.")
+	}
+	expected := expectedFromConfigs(vaultConfig, gsmConfig)
+	getters := map[string]secrets.Getter{}
+	for cluster := range expected.clusters(vaultConfig.UserSecretsTargetClusters).Difference(sets.New[string](prowDisabledClusters...)) {
+		if o.cluster != "" && o.cluster != cluster {
+			continue
+		}
+		getter, err := secrets.NewGetter(kubeConfigs, cluster)
+		if err != nil {
+			logrus.WithError(err).Fatal("Failed to create a client.")
+		}
+		getters[cluster] = getter
+	}
+
+	findings, err := audit(ctx, getters, expected, upstreamVersions(ctx, vaultClient, gsmClient, gsmProjectConfig))
+	if err != nil {
+		logrus.WithError(err).Error("Failed to audit some secrets.")
+	}
+	report, marshalErr := yaml.Marshal(findings)
+	if marshalErr != nil {
+		logrus.WithError(marshalErr).Fatal("Failed to serialize the findings.")
+	}
+	if o.reportPath != "" {
+		if err := os.WriteFile(o.reportPath, report, 0644); err != nil {
+			logrus.WithError(err).Fatal("Failed to write the report.")
+		}
+	} else if len(findings) > 0 {
+		fmt.Print(string(report))
+	}
+	if err != nil || len(findings) > 0 {
+		logrus.WithField("findings", len(findings)).Fatal("The secrets on the clusters do not match their sources.")
+	}
+	logrus.Info("The secrets on the clusters match their sources.")
+}
@@ -29,7 +29,6 @@ import (
 	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/client-go/kubernetes/scheme"
-	coreclientset "k8s.io/client-go/kubernetes/typed/core/v1"
 	"k8s.io/client-go/rest"
 	"sigs.k8s.io/prow/pkg/flagutil"
 	"sigs.k8s.io/prow/pkg/logrusutil"
@@ -55,6 +54,7 @@ type gsmSecretRef struct {
 // fetchedSecret holds the result of fetching a GSM secret (payload or error)
 type fetchedSecret struct {
 	payload []byte
+	version string
 	err     error
 }
 
@@ -77,8 +77,10 @@ type options struct {
 	logLevel        string
 	impersonateUser string
 
-	secretsGetters  map[string]Getter
-	vaultConfig     secretbootstrap.Config
+	secretsGetters map[string]secrets.Getter
+	vaultConfig    secretbootstrap.Config
+	// censor is extended with the values rendered from templates
+	censor          *secrets.DynamicCensor
 	generatorConfig secretgenerator.Config
 
 	gsmConfig            api.GSMConfig
@@ -98,7 +100,7 @@ const (
 )
 
 func parseOptions(censor *secrets.DynamicCensor) (options, error) {
-	o := options{kubernetesOptions: flagutil.KubernetesOptions{NOInClusterConfigDefault: true}}
+	o := options{kubernetesOptions: flagutil.KubernetesOptions{NOInClusterConfigDefault: true}, censor: censor}
 	fs := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
 	o.allowUnused = flagutil.NewStrings()
 	fs.BoolVar(&o.validateOnly, "validate-only", false, "If set, the tool exists after validating its config file.")
@@ -196,7 +198,7 @@ func (o *options) completeOptions(censor *secrets.DynamicCensor, kubeConfigs map
 
 	}
 
-	o.secretsGetters = map[string]Getter{}
+	o.secretsGetters = map[string]secrets.Getter{}
 	var filteredSecrets []secretbootstrap.SecretConfig
 	for i, secretConfig := range o.vaultConfig.Secrets {
 		var to []secretbootstrap.SecretContext
@@ -214,13 +216,9 @@ func (o *options) completeOptions(censor *secrets.DynamicCensor, kubeConfigs map
 
 			if !o.validateOnly {
 				if o.secretsGetters[secretContext.Cluster] == nil {
-					kc, ok := kubeConfigs[secretContext.Cluster]
-					if !ok {
-						return fmt.Errorf("config[%d].to[%d]: failed to find cluster context %q in the kubeconfig", i, j, secretContext.Cluster)
-					}
-					client, err := coreclientset.NewForConfig(&kc)
+					client, err := secrets.NewGetter(kubeConfigs, secretContext.Cluster)
 					if err != nil {
-						return err
+						return fmt.Errorf("config[%d].to[%d]: %w", i, j, err)
 					}
 					o.secretsGetters[secretContext.Cluster] = client
 				}
@@ -265,13 +263,9 @@ func (o *options) completeOptions(censor *secrets.DynamicCensor, kubeConfigs map
 				filteredTargets = append(filteredTargets, target)
 				if !o.validateOnly {
 					if o.secretsGetters[target.Cluster] == nil {
-						kc, ok := kubeConfigs[target.Cluster]
-						if !ok {
-							return fmt.Errorf("bundle %s target cluster %q not found in kubeconfig", bundle.Name, target.Cluster)
-						}
-						client, err := coreclientset.NewForConfig(&kc)
+						client, err := secrets.NewGetter(kubeConfigs, target.Cluster)
 						if err != nil {
-							return err
+							return fmt.Errorf("bundle %s: %w", bundle.Name, err)
 						}
 						o.secretsGetters[target.Cluster] = client
 					}
@@ -320,7 +314,7 @@ func (o *options) validateCompletedOptions() error {
 				return fmt.Errorf("config[%d].from: empty key is not allowed", i)
 			}
 
-			if itemContext.Item == "" && len(itemContext.DockerConfigJSONData) == 0 {
+			if itemContext.Item == "" && len(itemContext.DockerConfigJSONData) == 0 && itemContext.Template == nil {
 				return fmt.Errorf("config[%d].from[%s]: empty value is not allowed", i, key)
 			}
 
@@ -328,7 +322,20 @@ func (o *options) validateCompletedOptions() error {
 				return fmt.Errorf("config[%d].from[%s]: both bitwarden dockerconfigJSON items are not allowed", i, key)
 			}
 
-			if len(itemContext.DockerConfigJSONData) > 0 {
+			if itemContext.Template != nil {
+				if itemContext.Item != "" || len(itemContext.DockerConfigJSONData) > 0 {
+					return fmt.Errorf("config[%d].from[%s]: template can not be combined with item or dockerconfigJSON", i, key)
+				}
+				for _, name := range sets.List(sets.KeySet(itemContext.Template.Fields)) {
+					field := itemContext.Template.Fields[name]
+					if field.Item == "" || field.Field == "" {
+						return fmt.Errorf("config[%d].from[%s]: template field %s must set item and field", i, key, name)
+					}
+				}
+				if err := api.ValidateSecretTemplate(key, itemContext.Template.Content, sets.KeySet(itemContext.Template.Fields)); err != nil {
+					return fmt.Errorf("config[%d].from[%s]: %w", i, key, err)
+				}
+			} else if len(itemContext.DockerConfigJSONData) > 0 {
 				for _, data := range itemContext.DockerConfigJSONData {
 					if data.Item == "" {
 						return fmt.Errorf("config[%d].from[%s]: item is missing", i, key)
@@ -508,7 +515,60 @@ func constructDockerConfigJSONFromGSM(secretsCache map[gsmSecretRef]fetchedSecre
 	return b, nil
 }
 
-func constructSecretsFromVault(config secretbootstrap.Config, client secrets.ReadOnlyClient, prowDisabledClusters sets.Set[string]) (map[string][]*coreapi.Secret, error) {
+// renderTemplate renders a templated value. The rendered value is added to the censor, as it
+// is a secret of its own that does not necessarily contain the fetched values verbatim.
+func renderTemplate(key, content string, values map[string]string, censor *secrets.DynamicCensor) ([]byte, error) {
+	addSecrets := func(s ...string) {
+		if censor != nil {
+			censor.AddSecrets(s...)
+		}
+	}
+	tmpl, err := api.ParseSecretTemplate(key, content, addSecrets)
+	if err != nil {
+		return nil, err
+	}
+	rendered, err := api.RenderSecretTemplate(tmpl, values)
+	if err != nil {
+		return nil, err
+	}
+	addSecrets(string(rendered))
+	return rendered, nil
+}
+
+// constructTemplateFromVault renders a template over fields from Vault
+func constructTemplateFromVault(client secrets.ReadOnlyClient, key string, data *secretbootstrap.TemplateData, censor *secrets.DynamicCensor) ([]byte, error) {
+	values := make(map[string]string, len(data.Fields))
+	for name, field := range data.Fields {
+		value, err := client.GetFieldOnItem(field.Item, field.Field)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't get field '%s' from item %s for template field %s: %w", field.Field, field.Item, name, err)
+		}
+		values[name] = string(value)
+	}
+	return renderTemplate(key, data.Content, values, censor)
+}
+
+// constructTemplateFromGSM renders a template over fields from the GSM secrets cache
+func constructTemplateFromGSM(secretsCache map[gsmSecretRef]fetchedSecret, tmpl api.TemplateSpec, censor *secrets.DynamicCensor) ([]byte, error) {
+	values := make(map[string]string, len(tmpl.Fields))
+	for name, field := range tmpl.Fields {
+		ref := gsmSecretRef{collection: field.Collection, group: field.Group, field: field.Field}
+		fetched, exists := secretsCache[ref]
+		if !exists {
+			return nil, fmt.Errorf("template field %s (collection: %s, group: %s, field: %s) not found in fetched secrets", name, field.Collection, field.Group, field.Field)
+		}
+		if fetched.err != nil {
+			return nil, fmt.Errorf("couldn't get template field %s (collection: %s, group: %s, field: %s): %w", name, field.Collection, field.Group, field.Field, fetched.err)
+		}
+		if censor != nil {
+			censor.AddSecrets(string(fetched.payload))
+		}
+		values[name] = string(fetched.payload)
+	}
+	return renderTemplate(tmpl.As, tmpl.Template, values, censor)
+}
+
+func constructSecretsFromVault(config secretbootstrap.Config, client secrets.ReadOnlyClient, prowDisabledClusters sets.Set[string], provenance *provenanceRecorder, censor *secrets.DynamicCensor) (map[string][]*coreapi.Secret, error) {
 	secretsByClusterAndName := map[string]map[types.NamespacedName]coreapi.Secret{}
 	secretsMapLock := &sync.Mutex{}
 
@@ -526,9 +586,26 @@ func constructSecretsFromVault(config secretbootstrap.Config, client secrets.Rea
 			defer secretConfigWG.Done()
 
 			data := make(map[string][]byte)
+			sources := secretbootstrap.Provenance{}
 			var keys []string
-			for key := range cfg.From {
+			for key, itemContext := range cfg.From {
 				keys = append(keys, key)
+				source := secretbootstrap.KeyProvenance{Backend: secretbootstrap.ProvenanceBackendVault, Item: itemContext.Item, Field: itemContext.Field}
+				if len(itemContext.DockerConfigJSONData) > 0 {
+					items := sets.New[string]()
+					for _, data := range itemContext.DockerConfigJSONData {
+						items.Insert(data.Item)
+					}
+					source.Item = strings.Join(sets.List(items), ",")
+				}
+				if itemContext.Template != nil {
+					items := sets.New[string]()
+					for _, field := range itemContext.Template.Fields {
+						items.Insert(field.Item)
+					}
+					source.Item = strings.Join(sets.List(items), ",")
+				}
+				sources[key] = source
 			}
 			sort.Strings(keys)
 
@@ -546,6 +623,8 @@ func constructSecretsFromVault(config secretbootstrap.Config, client secrets.Rea
 						value, err = client.GetFieldOnItem(itemContext.Item, itemContext.Field)
 					} else if len(itemContext.DockerConfigJSONData) > 0 {
 						value, err = constructDockerConfigJSONFromVault(client, itemContext.DockerConfigJSONData)
+					} else if itemContext.Template != nil {
+						value, err = constructTemplateFromVault(client, key, itemContext.Template, censor)
 					}
 					if err != nil {
 						secretInError.Store(true)
@@ -599,6 +678,7 @@ func constructSecretsFromVault(config secretbootstrap.Config, client secrets.Rea
 				for k, v := range data {
 					secret.Data[k] = v
 				}
+				provenance.record(secretContext.Cluster, types.NamespacedName{Namespace: secret.Namespace, Name: secret.Name}, sources)
 				secretsMapLock.Lock()
 				if _, ok := secretsByClusterAndName[secretContext.Cluster]; !ok {
 					secretsByClusterAndName[secretContext.Cluster] = map[types.NamespacedName]coreapi.Secret{}
@@ -730,12 +810,7 @@ func generateUserSecretLabels(secretKeys map[string]string) (map[string]string,
 	return labels, nil
 }
 
-type Getter interface {
-	coreclientset.SecretsGetter
-	coreclientset.NamespacesGetter
-}
-
-func updateSecrets(getters map[string]Getter, secretsMap map[string][]*coreapi.Secret, force bool, confirm bool, osdGlobalPullSecretGroup, prowDisabledClusters sets.Set[string]) error {
+func updateSecrets(getters map[string]secrets.Getter, secretsMap map[string][]*coreapi.Secret, force bool, confirm bool, osdGlobalPullSecretGroup, prowDisabledClusters sets.Set[string]) error {
 	var errs []error
 
 	var dryRunOptions []string
@@ -833,6 +908,7 @@ func updateSecrets(getters map[string]Getter, secretsMap map[string][]*coreapi.S
 				if !shouldCreate {
 					differentData := !equality.Semantic.DeepEqual(secret.Data, existingSecret.Data)
 					differentLabels := !equality.Semantic.DeepEqual(secret.ObjectMeta.Labels, existingSecret.ObjectMeta.Labels)
+					differentProvenance := secret.Annotations[secretbootstrap.ProvenanceAnnotation] != existingSecret.Annotations[secretbootstrap.ProvenanceAnnotation]
 					var addedKeys, changedKeys, removedKeys []string
 					for k, value := range secret.Data {
 						if existingValue, ok := existingSecret.Data[k]; !ok {
@@ -852,7 +928,7 @@ func updateSecrets(getters map[string]Getter, secretsMap map[string][]*coreapi.S
 						errs = append(errs, fmt.Errorf("secret %s:%s/%s needs updating in place (%s), use --force to do so", cluster, secret.Namespace, secret.Name, change))
 						continue
 					}
-					if existingSecret.Labels == nil || existingSecret.Labels[api.DPTPRequesterLabel] != "ci-secret-bootstrap" || differentData || differentLabels {
+					if existingSecret.Labels == nil || existingSecret.Labels[api.DPTPRequesterLabel] != "ci-secret-bootstrap" || differentData || differentLabels || differentProvenance {
 						if _, err := secretClient.Update(context.TODO(), secret, metav1.UpdateOptions{DryRun: dryRunOptions}); err != nil {
 							errs = append(errs, fmt.Errorf("error updating secret %s:%s/%s: %w", cluster, secret.Namespace, secret.Name, err))
 							continue
@@ -1015,6 +1091,19 @@ func constructConfigItemsByName(config secretbootstrap.Config) map[string]*compa
 					cfgComparableItemsByName[context.Item] = item
 				}
 			}
+
+			if itemContext.Template != nil {
+				for _, field := range itemContext.Template.Fields {
+					item, ok := cfgComparableItemsByName[field.Item]
+					if !ok {
+						item = &comparable{
+							fields: sets.New[string](),
+						}
+					}
+					item.fields = insertIfNotEmpty(item.fields, field.Field)
+					cfgComparableItemsByName[field.Item] = item
+				}
+			}
 		}
 	}
 
@@ -1072,7 +1161,10 @@ func getUnusedItems(config secretbootstrap.Config, client secrets.ReadOnlyClient
 			unused[itemName].fields = diffFields
 		}
 
-		if superfluousFields := item.SuperfluousFields(); len(superfluousFields) > 0 {
+		superfluousFields := item.SuperfluousFields()
+		// ci-secret-generator keeps the state of rotations next to the rotated fields
+		superfluousFields.Delete(secretgenerator.RotationStateField)
+		if len(superfluousFields) > 0 {
 			if allowUnused.Has(itemName) {
 				l.WithField("superfluousFields", superfluousFields).Info("Superfluous fields from item are allowed by arguments")
 				continue
@@ -1108,7 +1200,14 @@ func (o *options) validateItems(client secrets.ReadOnlyClient) error {
 		for _, item := range config.From {
 			logger := logrus.WithField("item", item.Item)
 
-			if item.DockerConfigJSONData != nil {
+			if item.Template != nil {
+				for _, name := range sets.List(sets.KeySet(item.Template.Fields)) {
+					field := item.Template.Fields[name]
+					if err := o.validateField(client, field.Item, field.Field); err != nil {
+						errs = append(errs, fmt.Errorf("template %s: %w", name, err))
+					}
+				}
+			} else if item.DockerConfigJSONData != nil {
 				for _, data := range item.DockerConfigJSONData {
 					hasItem, err := client.HasItem(data.Item)
 					if err != nil {
@@ -1158,6 +1257,31 @@ func (o *options) validateItems(client secrets.ReadOnlyClient) error {
 	return utilerrors.NewAggregate(errs)
 }
 
+// validateField checks that the field exists on the item, or that it will be generated
+func (o *options) validateField(client secrets.ReadOnlyClient, item, field string) error {
+	itemName := stripDPTPPrefixFromItem(item, &o.vaultConfig)
+	logger := logrus.WithFields(logrus.Fields{"item": item, "field": field})
+	hasItem, err := client.HasItem(item)
+	if err != nil {
+		return fmt.Errorf("failed to check if item %s exists: %w", item, err)
+	}
+	if !hasItem {
+		if o.generatorConfig.IsFieldGenerated(itemName, field) {
+			logger.Warn("Item doesn't exist but it will be generated")
+			return nil
+		}
+		return fmt.Errorf("item %s doesn't exist", item)
+	}
+	if _, err := client.GetFieldOnItem(item, field); err != nil {
+		if o.generatorConfig.IsFieldGenerated(itemName, field) {
+			logger.Warn("Field doesn't exist but it will be generated")
+			return nil
+		}
+		return fmt.Errorf("field %s in item %s doesn't exist", field, item)
+	}
+	return nil
+}
+
 // stripDPTPPrefixFromItem strips the dptp prefix from an item name. It is needed when
 // interacting with the secret generator config, because the secret generator gets the full
 // dptp prefix as cli arg (kv/dptp) whereas the ci-secret-bootstrapper which needs to interact with
@@ -1248,18 +1372,29 @@ func reconcileSecrets(o options, vaultClient secrets.ReadOnlyClient, gsmClient *
 	}
 
 	// errors returned by constructSecrets will be handled once the rest of the secrets have been uploaded
-	secretsMap, err := constructSecretsFromVault(o.vaultConfig, vaultClient, prowDisabledClusters)
+	vaultProvenance := newProvenanceRecorder()
+	secretsMap, err := constructSecretsFromVault(o.vaultConfig, vaultClient, prowDisabledClusters, vaultProvenance, o.censor)
 	if err != nil {
 		errs = append(errs, err)
 	}
+	if err := vaultProvenance.resolveVaultVersions(vaultClient); err != nil {
+		errs = append(errs, fmt.Errorf("failed to resolve the versions of Vault items: %w", err))
+	}
+	if err := vaultProvenance.annotate(secretsMap); err != nil {
+		errs = append(errs, fmt.Errorf("failed to record the provenance of secrets: %w", err))
+	}
 
 	if o.enableGsm && gsmClient != nil && len(o.gsmConfig.Bundles) > 0 {
 		ctx := context.Background()
 		var gsmSecretsMap map[string][]*coreapi.Secret
-		gsmSecretsMap, err = constructSecretsFromGSM(ctx, o.gsmConfig, gsmClient, o.gsmProjectConfig, prowDisabledClusters)
+		gsmProvenance := newProvenanceRecorder()
+		gsmSecretsMap, err = constructSecretsFromGSM(ctx, o.gsmConfig, gsmClient, o.gsmProjectConfig, prowDisabledClusters, gsmProvenance, o.censor)
 		if err != nil {
 			errs = append(errs, err)
 		}
+		if err := gsmProvenance.annotate(gsmSecretsMap); err != nil {
+			errs = append(errs, fmt.Errorf("failed to record the provenance of GSM secrets: %w", err))
+		}
 
 		if gsmSecretsMap != nil {
 			secretsMap, err = mergeSecretMaps(secretsMap, gsmSecretsMap)
@@ -1354,7 +1489,9 @@ func constructSecretsFromGSM(
 	gsmConfig api.GSMConfig,
 	gsmClient gsm.SecretManagerClient,
 	gsmProjectConfig gsm.Config,
-	prowDisabledClusters sets.Set[string]) (map[string][]*coreapi.Secret, error) {
+	prowDisabledClusters sets.Set[string],
+	provenance *provenanceRecorder,
+	censor *secrets.DynamicCensor) (map[string][]*coreapi.Secret, error) {
 	var errs []error
 	uniqueSecretNames := sets.New[gsmSecretRef]()
 	discoveredFields := make(map[collectionGroupKey][]string) // track fields for collection+group pairs when `fields` stanza is empty
@@ -1401,6 +1538,16 @@ func constructSecretsFromGSM(
 			}
 		}
 
+		for _, tmpl := range bundle.Templates {
+			for _, field := range tmpl.Fields {
+				uniqueSecretNames.Insert(gsmSecretRef{
+					collection: field.Collection,
+					group:      field.Group,
+					field:      field.Field,
+				})
+			}
+		}
+
 		if bundle.DockerConfig == nil {
 			continue
 		}
@@ -1435,11 +1582,12 @@ func constructSecretsFromGSM(
 			defer wg.Done()
 
 			resourceName := gsm.GetGSMSecretResourceName(gsmProjectConfig.ProjectIdNumber, secretRef.collection, secretRef.group, secretRef.field)
-			payload, err := gsm.GetSecretPayload(ctx, gsmClient, resourceName)
+			payload, version, err := gsm.GetSecretPayloadAndVersion(ctx, gsmClient, resourceName)
 
 			mapLock.Lock()
 			fetchedGsmSecretsMap[secretRef] = fetchedSecret{
 				payload: payload,
+				version: version,
 				err:     err,
 			}
 			mapLock.Unlock()
@@ -1463,6 +1611,7 @@ func constructSecretsFromGSM(
 		}
 
 		k8sSecretData := make(map[string][]byte)
+		sources := secretbootstrap.Provenance{}
 		bundleHasError := false
 
 		for _, gsmSecretEntry := range bundle.GSMSecrets {
@@ -1515,6 +1664,13 @@ func constructSecretsFromGSM(
 					keyName = gsmvalidation.DenormalizeName(field.Name) // we want the original name notation in k8s secrets
 				}
 				k8sSecretData[keyName] = fetchedFromGsm.payload
+				sources[keyName] = secretbootstrap.KeyProvenance{
+					Backend:    secretbootstrap.ProvenanceBackendGSM,
+					Collection: ref.collection,
+					Group:      ref.group,
+					Field:      ref.field,
+					Version:    fetchedFromGsm.version,
+				}
 			}
 
 			if bundleHasError {
@@ -1539,6 +1695,39 @@ func constructSecretsFromGSM(
 				dockerConfigName = ".dockerconfigjson"
 			}
 			k8sSecretData[dockerConfigName] = dockerConfigData
+			groups := sets.New[string]()
+			for _, registry := range bundle.DockerConfig.Registries {
+				groups.Insert(registry.Group)
+			}
+			sources[dockerConfigName] = secretbootstrap.KeyProvenance{
+				Backend:    secretbootstrap.ProvenanceBackendGSM,
+				Collection: gsmConfig.DPTPCollection,
+				Group:      strings.Join(sets.List(groups), ","),
+			}
+		}
+
+		for _, tmpl := range bundle.Templates {
+			rendered, err := constructTemplateFromGSM(fetchedGsmSecretsMap, tmpl, censor)
+			if err != nil {
+				logrus.WithError(err).Errorf("skipping bundle %s: failed to render template %s", bundle.Name, tmpl.As)
+				errs = append(errs, fmt.Errorf("bundle %s: failed to render template %s: %w", bundle.Name, tmpl.As, err))
+				bundleHasError = true
+				break
+			}
+			k8sSecretData[tmpl.As] = rendered
+			collections, groups := sets.New[string](), sets.New[string]()
+			for _, field := range tmpl.Fields {
+				collections.Insert(field.Collection)
+				groups.Insert(field.Group)
+			}
+			sources[tmpl.As] = secretbootstrap.KeyProvenance{
+				Backend:    secretbootstrap.ProvenanceBackendGSM,
+				Collection: strings.Join(sets.List(collections), ","),
+				Group:      strings.Join(sets.List(groups), ","),
+			}
+		}
+		if bundleHasError {
+			continue
 		}
 
 		// finally, construct the whole k8s secret out of the bundle
@@ -1562,6 +1751,7 @@ func constructSecretsFromGSM(
 			for k, v := range k8sSecretData {
 				secret.Data[k] = v
 			}
+			provenance.record(target.Cluster, types.NamespacedName{Namespace: secret.Namespace, Name: secret.Name}, sources)
 			result[target.Cluster] = append(result[target.Cluster], secret)
 		}
 	}
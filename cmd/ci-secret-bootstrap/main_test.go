@@ -775,6 +775,122 @@ func TestValidateCompletedOptions(t *testing.T) {
 			},
 			expected: fmt.Errorf("config[0].from[key-name-1]: auth_field is missing"),
 		},
+		{
+			name: "happy template configuration",
+			given: options{
+				logLevel: "info",
+				vaultConfig: secretbootstrap.Config{
+					Secrets: []secretbootstrap.SecretConfig{
+						{
+							From: map[string]secretbootstrap.ItemContext{
+								"kubeconfig": {
+									Template: &secretbootstrap.TemplateData{
+										Content: "server: {{ .server }}\ntoken: {{ .token }}\n",
+										Fields: map[string]secretbootstrap.TemplateField{
+											"server": {Item: "build01", Field: "server"},
+											"token":  {Item: "build01", Field: "token"},
+										},
+									},
+								},
+							},
+							To: []secretbootstrap.SecretContext{
+								{
+									Cluster:   "default",
+									Name:      "kubeconfigs",
+									Namespace: "ci",
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "sad template configuration: combined with item",
+			given: options{
+				logLevel: "info",
+				vaultConfig: secretbootstrap.Config{
+					Secrets: []secretbootstrap.SecretConfig{
+						{
+							From: map[string]secretbootstrap.ItemContext{
+								"kubeconfig": {
+									Item:  "build01",
+									Field: "kubeconfig",
+									Template: &secretbootstrap.TemplateData{
+										Content: "token: {{ .token }}\n",
+										Fields:  map[string]secretbootstrap.TemplateField{"token": {Item: "build01", Field: "token"}},
+									},
+								},
+							},
+							To: []secretbootstrap.SecretContext{
+								{
+									Cluster:   "default",
+									Name:      "kubeconfigs",
+									Namespace: "ci",
+								},
+							},
+						},
+					},
+				},
+			},
+			expected: fmt.Errorf("config[0].from[kubeconfig]: template can not be combined with item or dockerconfigJSON"),
+		},
+		{
+			name: "sad template configuration: field without field name",
+			given: options{
+				logLevel: "info",
+				vaultConfig: secretbootstrap.Config{
+					Secrets: []secretbootstrap.SecretConfig{
+						{
+							From: map[string]secretbootstrap.ItemContext{
+								"kubeconfig": {
+									Template: &secretbootstrap.TemplateData{
+										Content: "token: {{ .token }}\n",
+										Fields:  map[string]secretbootstrap.TemplateField{"token": {Item: "build01"}},
+									},
+								},
+							},
+							To: []secretbootstrap.SecretContext{
+								{
+									Cluster:   "default",
+									Name:      "kubeconfigs",
+									Namespace: "ci",
+								},
+							},
+						},
+					},
+				},
+			},
+			expected: fmt.Errorf("config[0].from[kubeconfig]: template field token must set item and field"),
+		},
+		{
+			name: "sad template configuration: undefined field",
+			given: options{
+				logLevel: "info",
+				vaultConfig: secretbootstrap.Config{
+					Secrets: []secretbootstrap.SecretConfig{
+						{
+							From: map[string]secretbootstrap.ItemContext{
+								"kubeconfig": {
+									Template: &secretbootstrap.TemplateData{
+										Content: "server: {{ .server }}\ntoken: {{ .token }}\n",
+										Fields:  map[string]secretbootstrap.TemplateField{"token": {Item: "build01", Field: "token"}},
+									},
+								},
+							},
+							To: []secretbootstrap.SecretContext{
+								{
+									Cluster:   "default",
+									Name:      "kubeconfigs",
+									Namespace: "ci",
+								},
+							},
+						},
+					},
+				},
+			},
+			expected: fmt.Errorf("config[0].from[kubeconfig]: template kubeconfig references undefined fields: server"),
+		},
 		{
 			name: "sad dockerconfigJSON configuration: cannot determine registry URL",
 			given: options{
@@ -1418,7 +1534,7 @@ Code: 404. Errors:
 			client := vaultClientFromTestItems(tc.items)
 
 			var actualErrorMsg string
-			actual, actualError := constructSecretsFromVault(tc.config, client, tc.disabledClusters)
+			actual, actualError := constructSecretsFromVault(tc.config, client, tc.disabledClusters, nil, nil)
 			if actualError != nil {
 				actualErrorMsg = actualError.Error()
 			}
@@ -1440,6 +1556,40 @@ Code: 404. Errors:
 	}
 }
 
+func TestConstructTemplateFromVault(t *testing.T) {
+	client := vaultClientFromTestItems(map[string]vaultclient.KVData{
+		"build01": {Data: map[string]string{"server": "https://api.build01:6443", "ca": "ca-data", "token": "sha256~token"}},
+	})
+	data := &secretbootstrap.TemplateData{
+		Content: "server: {{ .server }}\ncertificate-authority-data: {{ base64 .ca }}\ntoken: {{ .token }}\n",
+		Fields: map[string]secretbootstrap.TemplateField{
+			"server": {Item: "build01", Field: "server"},
+			"ca":     {Item: "build01", Field: "ca"},
+			"token":  {Item: "build01", Field: "token"},
+		},
+	}
+	censor := secrets.NewDynamicCensor()
+	rendered, err := constructTemplateFromVault(client, "kubeconfig", data, &censor)
+	if err != nil {
+		t.Fatalf("failed to render template: %v", err)
+	}
+	expected := "server: https://api.build01:6443\ncertificate-authority-data: Y2EtZGF0YQ==\ntoken: sha256~token\n"
+	if diff := cmp.Diff(expected, string(rendered)); diff != "" {
+		t.Errorf("unexpected rendered template: %s", diff)
+	}
+
+	censored := []byte("derived Y2EtZGF0YQ== value")
+	censor.Censor(&censored)
+	if diff := cmp.Diff("derived XXXXXXXXXXXX value", string(censored)); diff != "" {
+		t.Errorf("derived value was not censored: %s", diff)
+	}
+
+	data.Fields["token"] = secretbootstrap.TemplateField{Item: "build01", Field: "missing"}
+	if _, err := constructTemplateFromVault(client, "kubeconfig", data, &censor); err == nil {
+		t.Error("expected an error for a missing field, got none")
+	}
+}
+
 func TestUpdateSecrets(t *testing.T) {
 	testCases := []struct {
 		name                     string
@@ -1477,6 +1627,44 @@ func TestUpdateSecrets(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "secret is updated when only its provenance changed",
+			existSecretsOnDefault: []runtime.Object{
+				&coreapi.Secret{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:        "prod-secret-1",
+						Namespace:   "namespace-1",
+						Labels:      map[string]string{"dptp.openshift.io/requester": "ci-secret-bootstrap"},
+						Annotations: map[string]string{"ci.openshift.io/secret-provenance": `{"secret":{"backend":"vault","item":"item","version":"1","hash":"old"}}`},
+					},
+					Data: map[string][]byte{"secret": []byte("value")},
+				},
+			},
+			secretsMap: map[string][]*coreapi.Secret{
+				"default": {
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:        "prod-secret-1",
+							Namespace:   "namespace-1",
+							Labels:      map[string]string{"dptp.openshift.io/requester": "ci-secret-bootstrap"},
+							Annotations: map[string]string{"ci.openshift.io/secret-provenance": `{"secret":{"backend":"vault","item":"item","version":"2","hash":"new"}}`},
+						},
+						Data: map[string][]byte{"secret": []byte("value")},
+					},
+				},
+			},
+			expectedSecretsOnDefault: []coreapi.Secret{
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:        "prod-secret-1",
+						Namespace:   "namespace-1",
+						Labels:      map[string]string{"dptp.openshift.io/requester": "ci-secret-bootstrap"},
+						Annotations: map[string]string{"ci.openshift.io/secret-provenance": `{"secret":{"backend":"vault","item":"item","version":"2","hash":"new"}}`},
+					},
+					Data: map[string][]byte{"secret": []byte("value")},
+				},
+			},
+		},
 		{
 			name: "basic case with force",
 			existSecretsOnDefault: []runtime.Object{
@@ -1795,7 +1983,7 @@ func TestUpdateSecrets(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			fkcDefault := fake.NewSimpleClientset(tc.existSecretsOnDefault...)
 			fkcBuild01 := fake.NewSimpleClientset(tc.existSecretsOnBuild01...)
-			clients := map[string]Getter{
+			clients := map[string]secrets.Getter{
 				"default": fkcDefault.CoreV1(),
 				"build01": fkcBuild01.CoreV1(),
 			}
@@ -2185,6 +2373,27 @@ func TestGetUnusedItems(t *testing.T) {
 				},
 			},
 		},
+		{
+			id:         "rotation state is not reported as superfluous",
+			allowItems: sets.New[string](),
+			items: map[string]vaultclient.KVData{
+				"item-name-1": {
+					Data: map[string]string{
+						"field-name-1":                     "testdata",
+						secretgenerator.RotationStateField: `{"rotated_at":"2024-01-01T00:00:00Z"}`,
+					},
+				},
+			},
+			config: secretbootstrap.Config{
+				Secrets: []secretbootstrap.SecretConfig{
+					{
+						From: map[string]secretbootstrap.ItemContext{
+							"1": {Item: "item-name-1", Field: "field-name-1"},
+						},
+					},
+				},
+			},
+		},
 		{
 			id: "unused item last modified after threshold is not reported",
 			items: map[string]vaultclient.KVData{
@@ -2239,6 +2448,7 @@ func vaultClientFromTestItems(items map[string]vaultclient.KVData) secrets.Clien
 		}
 
 		kvItem.Metadata.CreatedTime = item.Metadata.CreatedTime
+		kvItem.Metadata.Version = item.Metadata.Version
 		data[prefix+"/"+name] = kvItem
 	}
 
@@ -2303,6 +2513,23 @@ func TestValidateItems(t *testing.T) {
 			generatorCfg: secretgenerator.Config{{ItemName: "foo", Fields: []secretgenerator.FieldGenerator{{Name: "bar"}}}},
 			items:        map[string]*vaultclient.KVData{"/foo": {Data: map[string]string{"baz": "some-value"}}},
 		},
+		{
+			name:  "Template fields exist, no error",
+			cfg:   secretbootstrap.Config{Secrets: []secretbootstrap.SecretConfig{{From: map[string]secretbootstrap.ItemContext{"": {Template: &secretbootstrap.TemplateData{Fields: map[string]secretbootstrap.TemplateField{"a": {Item: "foo", Field: "bar"}, "b": {Item: "baz", Field: "qux"}}}}}}}},
+			items: map[string]*vaultclient.KVData{"/foo": {Data: map[string]string{"bar": "some-value"}}, "/baz": {Data: map[string]string{"qux": "some-value"}}},
+		},
+		{
+			name:         "Template field doesn't exist but is in generator config, success",
+			cfg:          secretbootstrap.Config{Secrets: []secretbootstrap.SecretConfig{{From: map[string]secretbootstrap.ItemContext{"": {Template: &secretbootstrap.TemplateData{Fields: map[string]secretbootstrap.TemplateField{"a": {Item: "foo", Field: "bar"}}}}}}}},
+			generatorCfg: secretgenerator.Config{{ItemName: "foo", Fields: []secretgenerator.FieldGenerator{{Name: "bar"}}}},
+		},
+		{
+			name:  "Template field doesn't exist, error",
+			cfg:   secretbootstrap.Config{Secrets: []secretbootstrap.SecretConfig{{From: map[string]secretbootstrap.ItemContext{"": {Template: &secretbootstrap.TemplateData{Fields: map[string]secretbootstrap.TemplateField{"a": {Item: "foo", Field: "bar"}}}}}}}},
+			items: map[string]*vaultclient.KVData{"/foo": {Data: map[string]string{"baz": "some-value"}}},
+
+			expectedErrorMsg: "template a: field bar in item foo doesn't exist",
+		},
 		{
 			name:         "item exists, field from DockerConfigJSONData doesn't but is in generator config, success",
 			cfg:          secretbootstrap.Config{Secrets: []secretbootstrap.SecretConfig{{From: map[string]secretbootstrap.ItemContext{"": {DockerConfigJSONData: []secretbootstrap.DockerConfigJSONData{{Item: "foo", AuthField: "bar"}}}}}}},
@@ -2371,6 +2598,7 @@ func (f *fakeGSMClient) AccessSecretVersion(ctx context.Context, req *secretmana
 		return nil, fmt.Errorf("secret version not found: %s", req.Name)
 	}
 	return &secretmanagerpb.AccessSecretVersionResponse{
+		Name: strings.TrimSuffix(req.Name, "latest") + "1",
 		Payload: &secretmanagerpb.SecretPayload{
 			Data: payload,
 		},
@@ -2412,7 +2640,7 @@ func TestIntegration(t *testing.T) {
 		initialData      map[string][]coreapi.Secret
 		force            bool
 		config           secretbootstrap.Config
-		secretGetters    map[string]Getter
+		secretGetters    map[string]secrets.Getter
 		vaultData        map[string]map[string][]byte
 		disabledClusters sets.Set[string]
 		expectedSecrets  map[string][]coreapi.Secret
@@ -2441,7 +2669,7 @@ func TestIntegration(t *testing.T) {
 					},
 				},
 			},
-			secretGetters: map[string]Getter{
+			secretGetters: map[string]secrets.Getter{
 				"cluster-1": fake.NewSimpleClientset().CoreV1(),
 			},
 			vaultData: map[string]map[string][]byte{"item-name-1": {"field-name-1": []byte("secret-data")}},
@@ -2482,7 +2710,7 @@ func TestIntegration(t *testing.T) {
 					},
 				},
 			},
-			secretGetters: map[string]Getter{
+			secretGetters: map[string]secrets.Getter{
 				"cluster-1": fake.NewSimpleClientset().CoreV1(),
 				"cluster-2": fake.NewSimpleClientset().CoreV1(),
 			},
@@ -2531,7 +2759,7 @@ func TestIntegration(t *testing.T) {
 					},
 				},
 			},
-			secretGetters: map[string]Getter{
+			secretGetters: map[string]secrets.Getter{
 				"cluster-1": fake.NewSimpleClientset().CoreV1(),
 				"cluster-2": fake.NewSimpleClientset().CoreV1(),
 			},
@@ -2570,7 +2798,7 @@ func TestIntegration(t *testing.T) {
 					},
 				},
 			},
-			secretGetters: map[string]Getter{
+			secretGetters: map[string]secrets.Getter{
 				"cluster-1": fake.NewSimpleClientset().CoreV1(),
 			},
 			vaultData: map[string]map[string][]byte{
@@ -2622,7 +2850,7 @@ func TestIntegration(t *testing.T) {
 					},
 				},
 			},
-			secretGetters: map[string]Getter{
+			secretGetters: map[string]secrets.Getter{
 				"cluster-1": fake.NewSimpleClientset().CoreV1(),
 				"cluster-2": fake.NewSimpleClientset().CoreV1(),
 			},
@@ -2685,7 +2913,7 @@ func TestIntegration(t *testing.T) {
 					},
 				},
 			},
-			secretGetters: map[string]Getter{
+			secretGetters: map[string]secrets.Getter{
 				"cluster-1": fake.NewSimpleClientset().CoreV1(),
 				"cluster-2": fake.NewSimpleClientset().CoreV1(),
 				"cluster-3": fake.NewSimpleClientset().CoreV1(),
@@ -2749,7 +2977,7 @@ func TestIntegration(t *testing.T) {
 					},
 				},
 			},
-			secretGetters: map[string]Getter{
+			secretGetters: map[string]secrets.Getter{
 				"cluster-1": fake.NewSimpleClientset().CoreV1(),
 			},
 			vaultData: map[string]map[string][]byte{
@@ -2779,7 +3007,7 @@ func TestIntegration(t *testing.T) {
 					},
 				},
 			},
-			secretGetters: map[string]Getter{
+			secretGetters: map[string]secrets.Getter{
 				"cluster-1": fake.NewSimpleClientset(
 					[]runtime.Object{
 						&coreapi.Secret{
@@ -2812,7 +3040,7 @@ func TestIntegration(t *testing.T) {
 			config: secretbootstrap.Config{
 				UserSecretsTargetClusters: []string{"cluster-1"},
 			},
-			secretGetters: map[string]Getter{"cluster-1": fake.NewSimpleClientset().CoreV1()},
+			secretGetters: map[string]secrets.Getter{"cluster-1": fake.NewSimpleClientset().CoreV1()},
 			vaultData: map[string]map[string][]byte{
 				"user-item-1": {
 					"some-data-key":               []byte("a-secret"),
@@ -2845,7 +3073,7 @@ func TestIntegration(t *testing.T) {
 			config: secretbootstrap.Config{
 				UserSecretsTargetClusters: []string{"cluster-1"},
 			},
-			secretGetters: map[string]Getter{"cluster-1": fake.NewSimpleClientset().CoreV1()},
+			secretGetters: map[string]secrets.Getter{"cluster-1": fake.NewSimpleClientset().CoreV1()},
 			vaultData: map[string]map[string][]byte{
 				"user-item-1": {
 					"some-data-key":               []byte("a-secret"),
@@ -2883,7 +3111,7 @@ func TestIntegration(t *testing.T) {
 					},
 				},
 			},
-			secretGetters: map[string]Getter{
+			secretGetters: map[string]secrets.Getter{
 				"cluster-1": fake.NewSimpleClientset().CoreV1(),
 				"cluster-2": fake.NewSimpleClientset().CoreV1(),
 				"cluster-3": fake.NewSimpleClientset().CoreV1(),
@@ -2944,7 +3172,7 @@ func TestIntegration(t *testing.T) {
 					},
 				},
 			},
-			secretGetters: map[string]Getter{
+			secretGetters: map[string]secrets.Getter{
 				"cluster-1": fake.NewSimpleClientset([]runtime.Object{
 					&coreapi.Secret{
 						ObjectMeta: metav1.ObjectMeta{Name: "prod-secret-1", Namespace: "namespace-1", Labels: map[string]string{"dptp.openshift.io/requester": "ci-secret-bootstrap"}},
@@ -2975,7 +3203,7 @@ func TestIntegration(t *testing.T) {
 			config: secretbootstrap.Config{
 				UserSecretsTargetClusters: []string{"cluster-1"},
 			},
-			secretGetters: map[string]Getter{
+			secretGetters: map[string]secrets.Getter{
 				"cluster-1": fake.NewSimpleClientset([]runtime.Object{
 					&coreapi.Secret{
 						ObjectMeta: metav1.ObjectMeta{Name: "prod-secret-1", Namespace: "namespace-1", Labels: map[string]string{"dptp.openshift.io/requester": "ci-secret-bootstrap"}},
@@ -3029,7 +3257,7 @@ func TestIntegration(t *testing.T) {
 					},
 				},
 			},
-			secretGetters: map[string]Getter{
+			secretGetters: map[string]secrets.Getter{
 				"cluster-1": fake.NewSimpleClientset([]runtime.Object{
 					&coreapi.Secret{
 						ObjectMeta: metav1.ObjectMeta{
@@ -3827,6 +4055,80 @@ func TestConstructSecretsFromGSM(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "template renders a composite key",
+			config: api.GSMConfig{
+				Bundles: []api.GSMBundle{
+					{
+						Name: "kubeconfig",
+						Targets: []api.TargetSpec{
+							{
+								Namespace: "ci",
+								Cluster:   "build01",
+							},
+						},
+						SyncToCluster: true,
+						Templates: []api.TemplateSpec{
+							{
+								As:       "config",
+								Template: "server: {{ .server }}\ntoken: {{ trim .token }}\n",
+								Fields: map[string]api.TemplateFieldRef{
+									"server": {Collection: "test-infra", Group: "build01", Field: "server"},
+									"token":  {Collection: "test-infra", Group: "build01", Field: "token"},
+								},
+							},
+						},
+					},
+				},
+			},
+			gsmSecretsPayloads: map[string][]byte{
+				"projects/123456/secrets/test-infra__build01__server/versions/latest": []byte("https://api.build01:6443"),
+				"projects/123456/secrets/test-infra__build01__token/versions/latest":  []byte("sha256~token\n"),
+			},
+			expected: map[string][]*coreapi.Secret{
+				"build01": {
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:      "kubeconfig",
+							Namespace: "ci",
+							Labels:    map[string]string{"dptp.openshift.io/requester": "ci-secret-bootstrap"},
+						},
+						Type: coreapi.SecretTypeOpaque,
+						Data: map[string][]byte{
+							"config": []byte("server: https://api.build01:6443\ntoken: sha256~token\n"),
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "template with a missing field is skipped",
+			config: api.GSMConfig{
+				Bundles: []api.GSMBundle{
+					{
+						Name: "kubeconfig",
+						Targets: []api.TargetSpec{
+							{
+								Namespace: "ci",
+								Cluster:   "build01",
+							},
+						},
+						SyncToCluster: true,
+						Templates: []api.TemplateSpec{
+							{
+								As:       "config",
+								Template: "token: {{ .token }}\n",
+								Fields: map[string]api.TemplateFieldRef{
+									"token": {Collection: "test-infra", Group: "build01", Field: "token"},
+								},
+							},
+						},
+					},
+				},
+			},
+			gsmSecretsPayloads: map[string][]byte{},
+			expectedError:      "bundle kubeconfig: failed to render template config",
+		},
 		{
 			name: "multi-level groups",
 			config: api.GSMConfig{
@@ -4219,6 +4521,8 @@ func TestConstructSecretsFromGSM(t *testing.T) {
 				fakeClient,
 				projectConfig,
 				tc.disabledClusters,
+				nil,
+				nil,
 			)
 
 			if tc.expectedError != "" {
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+
+	coreapi "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/openshift/ci-tools/pkg/api/secretbootstrap"
+	vaultapi "github.com/openshift/ci-tools/pkg/api/vault"
+	"github.com/openshift/ci-tools/pkg/secrets"
+)
+
+// maxConcurrentVersionLookups bounds the requests resolveVaultVersions makes at once
+const maxConcurrentVersionLookups = 10
+
+// provenanceRecorder collects the sources of the keys of the constructed secrets, so
+// that they can be recorded on the secrets once their final content is known.
+// A nil recorder records nothing.
+type provenanceRecorder struct {
+	lock    sync.Mutex
+	sources map[string]map[types.NamespacedName]secretbootstrap.Provenance
+}
+
+func newProvenanceRecorder() *provenanceRecorder {
+	return &provenanceRecorder{sources: map[string]map[types.NamespacedName]secretbootstrap.Provenance{}}
+}
+
+// record sets the sources of the keys of a secret on a cluster; hashes are filled in by annotate
+func (r *provenanceRecorder) record(cluster string, secret types.NamespacedName, sources secretbootstrap.Provenance) {
+	if r == nil {
+		return
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.sources[cluster] == nil {
+		r.sources[cluster] = map[types.NamespacedName]secretbootstrap.Provenance{}
+	}
+	copied := make(secretbootstrap.Provenance, len(sources))
+	for key, source := range sources {
+		copied[key] = source
+	}
+	r.sources[cluster][secret] = copied
+}
+
+// resolveVaultVersions fills in the current version of single Vault items, if the backend tracks them
+func (r *provenanceRecorder) resolveVaultVersions(client secrets.ReadOnlyClient) error {
+	if r == nil {
+		return nil
+	}
+	items := sets.New[string]()
+	for _, bySecret := range r.sources {
+		for _, provenance := range bySecret {
+			for _, source := range provenance {
+				if source.Backend == secretbootstrap.ProvenanceBackendVault && source.Item != "" && !strings.Contains(source.Item, ",") {
+					items.Insert(source.Item)
+				}
+			}
+		}
+	}
+
+	versions := map[string]string{}
+	var errs []error
+	lock := sync.Mutex{}
+	// the versions of the items the secrets were constructed from are known to the client,
+	// only the remaining ones are read
+	group := errgroup.Group{}
+	group.SetLimit(maxConcurrentVersionLookups)
+	for item := range items {
+		group.Go(func() error {
+			version, err := client.GetItemVersion(item)
+			lock.Lock()
+			defer lock.Unlock()
+			if err != nil {
+				errs = append(errs, fmt.Errorf("failed to get the version of item %s: %w", item, err))
+				return nil
+			}
+			if version > 0 {
+				versions[item] = strconv.Itoa(version)
+			}
+			return nil
+		})
+	}
+	_ = group.Wait()
+
+	for _, bySecret := range r.sources {
+		for _, provenance := range bySecret {
+			for key, source := range provenance {
+				if source.Backend == secretbootstrap.ProvenanceBackendVault {
+					source.Version = versions[source.Item]
+					provenance[key] = source
+				}
+			}
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// annotate records the provenance of every key on the secrets. Keys of user secrets are
+// attributed to the Vault items listed in the secret.
+func (r *provenanceRecorder) annotate(secretsMap map[string][]*coreapi.Secret) error {
+	var errs []error
+	for cluster, clusterSecrets := range secretsMap {
+		for _, secret := range clusterSecrets {
+			var sources secretbootstrap.Provenance
+			if r != nil {
+				sources = r.sources[cluster][types.NamespacedName{Namespace: secret.Namespace, Name: secret.Name}]
+			}
+			provenance := secretbootstrap.Provenance{}
+			for key, value := range secret.Data {
+				source, ok := sources[key]
+				if !ok {
+					userSecretItems, isUserSecret := secret.Data[vaultapi.VaultSourceKey]
+					if !isUserSecret {
+						logrus.WithFields(logrus.Fields{"cluster": cluster, "namespace": secret.Namespace, "name": secret.Name, "key": key}).Warn("Unknown source for key in secret")
+						continue
+					}
+					source = secretbootstrap.KeyProvenance{Backend: secretbootstrap.ProvenanceBackendVault, Item: string(userSecretItems), Field: key}
+				}
+				source.Hash = secretbootstrap.HashValue(value)
+				provenance[key] = source
+			}
+			if err := secretbootstrap.SetProvenance(secret, provenance); err != nil {
+				errs = append(errs, fmt.Errorf("secret %s:%s/%s: %w", cluster, secret.Namespace, secret.Name, err))
+			}
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	coreapi "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/openshift/ci-tools/pkg/api"
+	"github.com/openshift/ci-tools/pkg/api/secretbootstrap"
+	gsm "github.com/openshift/ci-tools/pkg/gsm-secrets"
+	"github.com/openshift/ci-tools/pkg/secrets"
+	"github.com/openshift/ci-tools/pkg/vaultclient"
+)
+
+func provenanceBySecret(t *testing.T, secretsMap map[string][]*coreapi.Secret) map[string]secretbootstrap.Provenance {
+	t.Helper()
+	result := map[string]secretbootstrap.Provenance{}
+	for cluster, clusterSecrets := range secretsMap {
+		for _, secret := range clusterSecrets {
+			provenance, err := secretbootstrap.ProvenanceFor(secret)
+			if err != nil {
+				t.Fatalf("failed to get the provenance of %s/%s: %v", secret.Namespace, secret.Name, err)
+			}
+			result[cluster+":"+secret.Namespace+"/"+secret.Name] = provenance
+		}
+	}
+	return result
+}
+
+func TestVaultProvenance(t *testing.T) {
+	items := map[string]vaultclient.KVData{
+		"item-1": {
+			Data:     map[string]string{"field-1": "value-1", "auth": "dXNlcjpwYXNz"},
+			Metadata: vaultclient.KVMetadata{Version: 3},
+		},
+		"item-2": {
+			Data: map[string]string{"field-2": "value-2"},
+		},
+		"user/item": {
+			Data: map[string]string{
+				"secretsync/target-namespace": "user-ns",
+				"secretsync/target-name":      "user-secret",
+				"user-key":                    "user-value",
+			},
+		},
+	}
+	config := secretbootstrap.Config{
+		Secrets: []secretbootstrap.SecretConfig{{
+			From: map[string]secretbootstrap.ItemContext{
+				"key-1": {Item: "item-1", Field: "field-1"},
+				"key-2": {Item: "item-2", Field: "field-2"},
+				".dockerconfigjson": {DockerConfigJSONData: []secretbootstrap.DockerConfigJSONData{
+					{Item: "item-1", RegistryURL: "quay.io", AuthField: "auth"},
+				}},
+			},
+			To: []secretbootstrap.SecretContext{{Cluster: "build01", Namespace: "ci", Name: "secret"}},
+		}},
+		UserSecretsTargetClusters: []string{"build01"},
+	}
+	client := vaultClientFromTestItems(items)
+	recorder := newProvenanceRecorder()
+	secretsMap, err := constructSecretsFromVault(config, client, sets.New[string](), recorder, nil)
+	if err != nil {
+		t.Fatalf("failed to construct secrets: %v", err)
+	}
+	if err := recorder.resolveVaultVersions(client); err != nil {
+		t.Fatalf("failed to resolve versions: %v", err)
+	}
+	if err := recorder.annotate(secretsMap); err != nil {
+		t.Fatalf("failed to annotate: %v", err)
+	}
+
+	dockerConfig := secretsMap["build01"][0].Data[".dockerconfigjson"]
+	if secretsMap["build01"][0].Name != "secret" {
+		dockerConfig = secretsMap["build01"][1].Data[".dockerconfigjson"]
+	}
+	expected := map[string]secretbootstrap.Provenance{
+		"build01:ci/secret": {
+			"key-1":             {Backend: "vault", Item: "item-1", Field: "field-1", Version: "3", Hash: secretbootstrap.HashValue([]byte("value-1"))},
+			"key-2":             {Backend: "vault", Item: "item-2", Field: "field-2", Hash: secretbootstrap.HashValue([]byte("value-2"))},
+			".dockerconfigjson": {Backend: "vault", Item: "item-1", Version: "3", Hash: secretbootstrap.HashValue(dockerConfig)},
+		},
+		"build01:user-ns/user-secret": {
+			"user-key":                     {Backend: "vault", Item: "prefix/user/item", Field: "user-key", Hash: secretbootstrap.HashValue([]byte("user-value"))},
+			"secretsync-vault-source-path": {Backend: "vault", Item: "prefix/user/item", Field: "secretsync-vault-source-path", Hash: secretbootstrap.HashValue([]byte("prefix/user/item"))},
+		},
+	}
+	if diff := cmp.Diff(expected, provenanceBySecret(t, secretsMap)); diff != "" {
+		t.Errorf("provenance differs from expected: %s", diff)
+	}
+}
+
+func TestGSMProvenance(t *testing.T) {
+	config := api.GSMConfig{
+		DPTPCollection: "test-dptp",
+		Bundles: []api.GSMBundle{{
+			Name:          "test-secret",
+			Targets:       []api.TargetSpec{{Namespace: "ci", Cluster: "build01"}},
+			SyncToCluster: true,
+			GSMSecrets: []api.GSMSecretRef{{
+				Collection: "test-infra",
+				Group:      "build-farm",
+				Fields:     []api.FieldEntry{{Name: "username", As: "user"}},
+			}},
+			DockerConfig: &api.DockerConfigSpec{
+				As:         "pull-secret",
+				Registries: []api.RegistryAuthData{{Group: "registries", RegistryURL: "quay.io", AuthField: "auth"}},
+			},
+		}},
+	}
+	client := &fakeGSMClient{secrets: map[string][]byte{
+		"projects/123456/secrets/test-infra__build-farm__username/versions/latest": []byte("admin"),
+		"projects/123456/secrets/test-dptp__registries__auth/versions/latest":      []byte("dXNlcjpwYXNz"),
+	}}
+	recorder := newProvenanceRecorder()
+	secretsMap, err := constructSecretsFromGSM(context.Background(), config, client, gsm.Config{ProjectIdNumber: "123456"}, sets.New[string](), recorder, nil)
+	if err != nil {
+		t.Fatalf("failed to construct secrets: %v", err)
+	}
+	if err := recorder.annotate(secretsMap); err != nil {
+		t.Fatalf("failed to annotate: %v", err)
+	}
+
+	expected := map[string]secretbootstrap.Provenance{
+		"build01:ci/test-secret": {
+			"user":        {Backend: "gsm", Collection: "test-infra", Group: "build-farm", Field: "username", Version: "1", Hash: secretbootstrap.HashValue([]byte("admin"))},
+			"pull-secret": {Backend: "gsm", Collection: "test-dptp", Group: "registries", Hash: secretbootstrap.HashValue(secretsMap["build01"][0].Data["pull-secret"])},
+		},
+	}
+	if diff := cmp.Diff(expected, provenanceBySecret(t, secretsMap)); diff != "" {
+		t.Errorf("provenance differs from expected: %s", diff)
+	}
+}
+
+// countingVaultClient counts the reads of every path
+type countingVaultClient struct {
+	*fakeVaultClient
+	lock  sync.Mutex
+	reads map[string]int
+}
+
+func (c *countingVaultClient) GetKV(path string) (*vaultclient.KVData, error) {
+	c.lock.Lock()
+	c.reads[path]++
+	c.lock.Unlock()
+	return c.fakeVaultClient.GetKV(path)
+}
+
+func TestResolveVaultVersionsReusesReads(t *testing.T) {
+	upstream := &countingVaultClient{
+		fakeVaultClient: &fakeVaultClient{items: map[string]*vaultclient.KVData{
+			"prefix/read":   {Data: map[string]string{"field": "value"}, Metadata: vaultclient.KVMetadata{Version: 3}},
+			"prefix/unread": {Data: map[string]string{"field": "value"}, Metadata: vaultclient.KVMetadata{Version: 5}},
+		}},
+		reads: map[string]int{},
+	}
+	censor := secrets.NewDynamicCensor()
+	client := secrets.NewVaultClient(upstream, "prefix", &censor)
+	if _, err := client.GetFieldOnItem("read", "field"); err != nil {
+		t.Fatalf("failed to read the field: %v", err)
+	}
+
+	recorder := newProvenanceRecorder()
+	secret := types.NamespacedName{Namespace: "ci", Name: "secret"}
+	recorder.record("build01", secret, secretbootstrap.Provenance{
+		"read":   {Backend: secretbootstrap.ProvenanceBackendVault, Item: "read", Field: "field"},
+		"unread": {Backend: secretbootstrap.ProvenanceBackendVault, Item: "unread", Field: "field"},
+	})
+	if err := recorder.resolveVaultVersions(client); err != nil {
+		t.Fatalf("failed to resolve versions: %v", err)
+	}
+
+	expected := secretbootstrap.Provenance{
+		"read":   {Backend: secretbootstrap.ProvenanceBackendVault, Item: "read", Field: "field", Version: "3"},
+		"unread": {Backend: secretbootstrap.ProvenanceBackendVault, Item: "unread", Field: "field", Version: "5"},
+	}
+	if diff := cmp.Diff(expected, recorder.sources["build01"][secret]); diff != "" {
+		t.Errorf("provenance differs from expected: %s", diff)
+	}
+	if diff := cmp.Diff(map[string]int{"prefix/read": 1, "prefix/unread": 1}, upstream.reads); diff != "" {
+		t.Errorf("unexpected reads (-want, +got): %s", diff)
+	}
+}
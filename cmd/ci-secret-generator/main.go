@@ -9,15 +9,21 @@ import (
 	"os"
 	"os/exec"
 	"reflect"
+	"slices"
 	"sort"
 	"strings"
 
 	secretmanager "cloud.google.com/go/secretmanager/apiv1"
 	"github.com/sirupsen/logrus"
+	"github.com/slack-go/slack"
 	"google.golang.org/api/option"
 
 	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	prowv1 "sigs.k8s.io/prow/pkg/apis/prowjobs/v1"
+	configflagutil "sigs.k8s.io/prow/pkg/flagutil/config"
 	"sigs.k8s.io/prow/pkg/logrusutil"
 
 	"github.com/openshift/ci-tools/pkg/api"
@@ -27,6 +33,7 @@ import (
 	gsmvalidation "github.com/openshift/ci-tools/pkg/gsm-validation"
 	"github.com/openshift/ci-tools/pkg/prowconfigutils"
 	"github.com/openshift/ci-tools/pkg/secrets"
+	"github.com/openshift/ci-tools/pkg/util"
 )
 
 const (
@@ -62,6 +69,12 @@ type options struct {
 	gsmConfig            api.GSMConfig
 	gsmCredentialsFile   string
 
+	rotate         bool
+	bootstrapJob   string
+	prowconfig     configflagutil.ConfigOptions
+	slackTokenPath string
+	slackChannel   string
+
 	config          secretgenerator.Config
 	bootstrapConfig secretbootstrap.Config
 }
@@ -83,6 +96,12 @@ func parseOptions(censor *secrets.DynamicCensor) options {
 	fs.StringVar(&o.gsmProjectConfigPath, "gsm-project-config", "", "Path to the GCP project config file we use for secrets.")
 	fs.StringVar(&o.gsmConfigPath, "gsm-config", "", "Path to the GSM config file used for bootstrapping cluster secrets after using this tool.")
 
+	fs.BoolVar(&o.rotate, "rotate", false, "Rotate the items with a rotation policy that are due instead of generating the other items.")
+	fs.StringVar(&o.bootstrapJob, "bootstrap-job", "", "Name of the periodic job running ci-secret-bootstrap, triggered for the secrets using rotated items.")
+	fs.StringVar(&o.slackTokenPath, "slack-token-path", "", "Path to the file containing the Slack token used to report rotations.")
+	fs.StringVar(&o.slackChannel, "slack-channel", "", "Slack channel to report rotations to.")
+	o.prowconfig.AddFlags(fs)
+
 	o.secrets.Bind(fs, os.Getenv, censor)
 	if err := fs.Parse(os.Args[1:]); err != nil {
 		logrus.WithError(err).Errorf("cannot parse args: %q", os.Args[1:])
@@ -116,6 +135,22 @@ func (o *options) validateOptions() error {
 	if o.enableGsmSync && o.gsmCredentialsFile == "" && !o.dryRun && !o.validateOnly {
 		return errors.New("--gsm-credentials-file is required when --enable-gsm-sync is true")
 	}
+	if !o.rotate && (o.bootstrapJob != "" || o.slackTokenPath != "") {
+		return errors.New("--bootstrap-job and --slack-token-path are only valid with --rotate")
+	}
+	if o.rotate {
+		if o.bootstrapJob != "" {
+			if o.bootstrapConfigPath == "" {
+				return errors.New("--bootstrap-config is required with --bootstrap-job")
+			}
+			if err := o.prowconfig.Validate(o.dryRun); err != nil {
+				return err
+			}
+		}
+		if (o.slackTokenPath == "") != (o.slackChannel == "") {
+			return errors.New("--slack-token-path and --slack-channel must be set together")
+		}
+	}
 	return nil
 }
 
@@ -180,6 +215,22 @@ func (o *options) validateConfig() error {
 		if !hasCluster {
 			return fmt.Errorf("failed to find params['cluster'] in the %d item with name %q", i, item.ItemName)
 		}
+		if item.Rotation != nil {
+			if item.Rotation.MaxAge.Duration <= 0 {
+				return fmt.Errorf("config[%d].rotation.max_age: must be positive, ItemName: %s", i, item.ItemName)
+			}
+			if item.Rotation.Overlap != nil && item.Rotation.Overlap.Duration <= 0 {
+				return fmt.Errorf("config[%d].rotation.overlap: must be positive, ItemName: %s", i, item.ItemName)
+			}
+			if item.Rotation.RevokeCmd != "" && item.Rotation.Overlap == nil {
+				return fmt.Errorf("config[%d].rotation.revoke_cmd: requires an overlap, ItemName: %s", i, item.ItemName)
+			}
+			for _, name := range item.Rotation.Fields {
+				if !slices.ContainsFunc(item.Fields, func(field secretgenerator.FieldGenerator) bool { return field.Name == name }) {
+					return fmt.Errorf("config[%d].rotation.fields: item has no field %q, ItemName: %s", i, name, item.ItemName)
+				}
+			}
+		}
 	}
 	return nil
 }
@@ -339,6 +390,14 @@ func buildSecretsToUpdate(config secretgenerator.Config, disabledClusters sets.S
 			normalizedGroup := gsmvalidation.NormalizeName(item.ItemName)
 			normalizedField := gsmvalidation.NormalizeName(field.Name)
 			gsmSecretName := fmt.Sprintf("%s%s%s", normalizedGroup, gsmvalidation.CollectionSecretDelimiter, normalizedField)
+			if item.Rotation.Rotates(field.Name) {
+				// rotated fields are only generated by --rotate once the item is due
+				logger.Debug("ignored rotated field")
+				if previousIndexEntries.Has(gsmSecretName) {
+					newIndexEntries = append(newIndexEntries, gsmSecretName)
+				}
+				continue
+			}
 
 			logger.Info("processing field")
 			out, err := executeCommand(field.Cmd)
@@ -357,6 +416,9 @@ func buildSecretsToUpdate(config secretgenerator.Config, disabledClusters sets.S
 			})
 			newIndexEntries = append(newIndexEntries, gsmSecretName)
 		}
+		if item.Rotation != nil && len(itemStruct.Fields) == 0 {
+			continue
+		}
 		secretsToUpdate = append(secretsToUpdate, itemStruct)
 	}
 
@@ -400,6 +462,8 @@ func main() {
 func generateSecrets(o options, censor *secrets.DynamicCensor) (errs []error) {
 	var client secrets.Client
 	var existingIndexEntries sets.Set[string]
+	// rotation state is only kept in Vault, not synced to GSM
+	var stateClient secrets.Client
 
 	if o.dryRun {
 		var err error
@@ -417,12 +481,14 @@ func generateSecrets(o options, censor *secrets.DynamicCensor) (errs []error) {
 			}
 		}
 		client = secrets.NewDryRunClient(f)
+		stateClient = client
 	} else {
 		var err error
 		client, err = o.secrets.NewClient(censor)
 		if err != nil {
 			return append(errs, fmt.Errorf("failed to create secrets client: %w", err))
 		}
+		stateClient = client
 
 		if o.enableGsmSync {
 			client, err = secrets.NewGSMSyncDecorator(client, o.gsmProjectConfig, o.gsmCredentialsFile)
@@ -438,6 +504,10 @@ func generateSecrets(o options, censor *secrets.DynamicCensor) (errs []error) {
 		}
 	}
 
+	if o.rotate {
+		return rotate(o, client, stateClient, censor, existingIndexEntries)
+	}
+
 	if err := updateSecrets(o.config, client, o.disabledClusters, existingIndexEntries); err != nil {
 		errs = append(errs, fmt.Errorf("failed to update secrets: %w", err))
 	}
@@ -445,6 +515,43 @@ func generateSecrets(o options, censor *secrets.DynamicCensor) (errs []error) {
 	return errs
 }
 
+func rotate(o options, client, stateClient secrets.Client, censor *secrets.DynamicCensor, existingIndexEntries sets.Set[string]) []error {
+	var trigger bootstrapTrigger
+	if o.bootstrapJob != "" && !o.dryRun {
+		configAgent, err := o.prowconfig.ConfigAgent()
+		if err != nil {
+			return []error{fmt.Errorf("failed to read Prow configuration: %w", err)}
+		}
+		clusterConfig, err := util.LoadClusterConfig()
+		if err != nil {
+			return []error{fmt.Errorf("failed to load cluster configuration: %w", err)}
+		}
+		if err := prowv1.AddToScheme(scheme.Scheme); err != nil {
+			return []error{fmt.Errorf("failed to add prowjobs to scheme: %w", err)}
+		}
+		kubeClient, err := ctrlruntimeclient.New(clusterConfig, ctrlruntimeclient.Options{})
+		if err != nil {
+			return []error{fmt.Errorf("failed to create a kubernetes client: %w", err)}
+		}
+		trigger, err = newProwJobBootstrapTrigger(kubeClient, configAgent.Config(), o.bootstrapJob)
+		if err != nil {
+			return []error{err}
+		}
+	}
+	var reporter slackClient
+	if o.slackTokenPath != "" && !o.dryRun {
+		token, err := secrets.ReadFromFile(o.slackTokenPath, censor)
+		if err != nil {
+			return []error{fmt.Errorf("failed to read the Slack token: %w", err)}
+		}
+		reporter = slack.New(token)
+	}
+	if existingIndexEntries == nil {
+		existingIndexEntries = sets.New[string]()
+	}
+	return rotateSecrets(context.Background(), o, newRotator(client, stateClient, o.disabledClusters, o.dryRun), trigger, reporter, existingIndexEntries)
+}
+
 func readIndexSecret(o options) ([]byte, error) {
 	ctx := context.Background()
 	opts := []option.ClientOption{option.WithCredentialsFile(o.gsmCredentialsFile)}
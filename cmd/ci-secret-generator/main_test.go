@@ -5,10 +5,12 @@ import (
 	"fmt"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 
 	"k8s.io/apimachinery/pkg/util/sets"
+	prowv1 "sigs.k8s.io/prow/pkg/apis/prowjobs/v1"
 
 	"github.com/openshift/ci-tools/pkg/api"
 	"github.com/openshift/ci-tools/pkg/api/secretbootstrap"
@@ -470,6 +472,18 @@ func TestValidateConfig(t *testing.T) {
 			name:     "no cluster param",
 			expected: fmt.Errorf(`failed to find params['cluster'] in the 0 item with name "Item1"`),
 		},
+		{
+			name:     "rotation without max age",
+			expected: fmt.Errorf(`config[0].rotation.max_age: must be positive, ItemName: Item1`),
+		},
+		{
+			name:     "revoke without overlap",
+			expected: fmt.Errorf(`config[0].rotation.revoke_cmd: requires an overlap, ItemName: Item1`),
+		},
+		{
+			name:     "unknown rotated field",
+			expected: fmt.Errorf(`config[0].rotation.fields: item has no field "Attachment2", ItemName: Item1`),
+		},
 		{
 			name: "valid",
 			expectedConfig: secretgenerator.Config{
@@ -744,6 +758,56 @@ func TestBuildSecretsToUpdate(t *testing.T) {
 			expectedIndexFields: nil,
 			expectError:         true,
 		},
+		{
+			name: "items with a rotation policy are not generated",
+			config: secretgenerator.Config{
+				{
+					ItemName: "item1",
+					Fields: []secretgenerator.FieldGenerator{
+						{Name: "field1", Cmd: "printf 'value1'"},
+					},
+				},
+				{
+					ItemName: "rotated",
+					Fields: []secretgenerator.FieldGenerator{
+						{Name: "field1", Cmd: "printf 'value1'"},
+						{Name: "field2", Cmd: "printf 'value2'"},
+					},
+					Rotation: &secretgenerator.RotationPolicy{MaxAge: prowv1.Duration{Duration: time.Hour}},
+				},
+			},
+			existingIndexValues: []string{"rotated__field1"},
+			expectedItems: map[string]ItemUpdateInfo{
+				"item1": {
+					ItemName: "item1",
+					Fields: []FieldUpdateInfo{
+						{FieldName: "field1", Payload: []byte("value1")},
+					},
+				},
+			},
+			expectedIndexFields: []string{"item1__field1", "rotated__field1"},
+		},
+		{
+			name: "only the rotated fields of items with a rotation policy are not generated",
+			config: secretgenerator.Config{{
+				ItemName: "partially-rotated",
+				Fields: []secretgenerator.FieldGenerator{
+					{Name: "rotated", Cmd: "printf 'value1'"},
+					{Name: "static", Cmd: "printf 'value2'"},
+				},
+				Rotation: &secretgenerator.RotationPolicy{MaxAge: prowv1.Duration{Duration: time.Hour}, Fields: []string{"rotated"}},
+			}},
+			existingIndexValues: []string{"partially-rotated__rotated"},
+			expectedItems: map[string]ItemUpdateInfo{
+				"partially-rotated": {
+					ItemName: "partially-rotated",
+					Fields: []FieldUpdateInfo{
+						{FieldName: "static", Payload: []byte("value2")},
+					},
+				},
+			},
+			expectedIndexFields: []string{"partially-rotated__rotated", "partially-rotated__static"},
+		},
 		{
 			name: "secret not in config is preserved in index",
 			config: secretgenerator.Config{{
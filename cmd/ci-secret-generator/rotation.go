@@ -0,0 +1,439 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/slack-go/slack"
+
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	prowconfig "sigs.k8s.io/prow/pkg/config"
+	"sigs.k8s.io/prow/pkg/pjutil"
+
+	"github.com/openshift/ci-tools/pkg/api"
+	"github.com/openshift/ci-tools/pkg/api/secretbootstrap"
+	"github.com/openshift/ci-tools/pkg/api/secretgenerator"
+	gsm "github.com/openshift/ci-tools/pkg/gsm-secrets"
+	gsmvalidation "github.com/openshift/ci-tools/pkg/gsm-validation"
+	"github.com/openshift/ci-tools/pkg/secrets"
+)
+
+// rotationResult describes what happened to an item with a rotation policy
+type rotationResult struct {
+	item string
+	// due is set when the item had to be rotated
+	due     bool
+	rotated bool
+	// revoked is set when the values replaced by the previous rotation were revoked
+	revoked bool
+	// secrets are the secrets synced from the item by ci-secret-bootstrap
+	secrets []secretbootstrap.SecretContext
+	err     error
+}
+
+// rotator rotates the items with a rotation policy once they are due
+type rotator struct {
+	client secrets.Client
+	// stateClient stores the rotation state, which must not be synced to GSM
+	stateClient      secrets.Client
+	disabledClusters sets.Set[string]
+	dryRun           bool
+	now              func() time.Time
+	// generate runs a field command and returns the value
+	generate func(command string) ([]byte, error)
+	// runHook runs a rotation hook with additional environment variables
+	runHook func(command string, env []string) error
+}
+
+func newRotator(client, stateClient secrets.Client, disabledClusters sets.Set[string], dryRun bool) *rotator {
+	return &rotator{
+		client:           client,
+		stateClient:      stateClient,
+		disabledClusters: disabledClusters,
+		dryRun:           dryRun,
+		now:              time.Now,
+		generate:         executeCommand,
+		runHook:          runHook,
+	}
+}
+
+func runHook(command string, env []string) error {
+	cmd := exec.Command("bash", "-o", "errexit", "-o", "nounset", "-o", "pipefail", "-c", command)
+	cmd.Env = append(os.Environ(), env...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to run command %q: %w\noutput:\n%s", command, err, out)
+	}
+	return nil
+}
+
+// runHookWithValues runs a hook with the values written to files in $ROTATION_VALUES_DIR
+func (r *rotator) runHookWithValues(command, item string, values map[string][]byte) error {
+	dir, err := os.MkdirTemp("", "ci-secret-generator-rotation")
+	if err != nil {
+		return fmt.Errorf("failed to create a directory for the values: %w", err)
+	}
+	defer os.RemoveAll(dir)
+	for field, value := range values {
+		if err := os.WriteFile(filepath.Join(dir, field), value, 0600); err != nil {
+			return fmt.Errorf("failed to write the value of field %s: %w", field, err)
+		}
+	}
+	return r.runHook(command, []string{"ITEM_NAME=" + item, "ROTATION_VALUES_DIR=" + dir})
+}
+
+func (r *rotator) readState(item string) (secretgenerator.RotationState, error) {
+	var state secretgenerator.RotationState
+	raw, err := r.stateClient.GetFieldOnItem(item, secretgenerator.RotationStateField)
+	if err != nil {
+		if secrets.IsNotFound(err) {
+			return state, nil
+		}
+		return state, fmt.Errorf("failed to read the rotation state: %w", err)
+	}
+	if len(raw) == 0 {
+		return state, nil
+	}
+	if err := json.Unmarshal(raw, &state); err != nil {
+		return state, fmt.Errorf("failed to parse the rotation state: %w", err)
+	}
+	return state, nil
+}
+
+func (r *rotator) writeState(item string, state secretgenerator.RotationState) error {
+	raw, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to serialize the rotation state: %w", err)
+	}
+	if err := r.stateClient.SetFieldOnItem(item, secretgenerator.RotationStateField, raw); err != nil {
+		return fmt.Errorf("failed to store the rotation state: %w", err)
+	}
+	return nil
+}
+
+// rotate revokes the previous values of the item once their overlap is over, and
+// rotates the values if they are older than the maximum age
+func (r *rotator) rotate(item secretgenerator.SecretItem) rotationResult {
+	result := rotationResult{item: item.ItemName}
+	logger := logrus.WithField("item", item.ItemName)
+	policy := item.Rotation
+	now := r.now()
+
+	state, err := r.readState(item.ItemName)
+	if err != nil {
+		result.err = err
+		return result
+	}
+
+	if state.PreviousValidUntil != nil && now.After(*state.PreviousValidUntil) {
+		logger.Info("Revoking the previous values")
+		if !r.dryRun {
+			previous := map[string][]byte{}
+			for field, value := range state.Previous {
+				previous[field] = []byte(value)
+			}
+			if policy.RevokeCmd != "" {
+				if err := r.runHookWithValues(policy.RevokeCmd, item.ItemName, previous); err != nil {
+					result.err = fmt.Errorf("failed to revoke the previous values: %w", err)
+					return result
+				}
+			}
+			state.Previous, state.PreviousValidUntil = nil, nil
+			if err := r.writeState(item.ItemName, state); err != nil {
+				result.err = err
+				return result
+			}
+		}
+		result.revoked = true
+	}
+
+	if !state.RotatedAt.IsZero() && now.Before(state.RotatedAt.Add(policy.MaxAge.Duration)) {
+		logger.WithField("rotated_at", state.RotatedAt).Debug("Item is not due for rotation")
+		return result
+	}
+	result.due = true
+	if r.dryRun {
+		logger.Info("Item is due for rotation")
+		return result
+	}
+	if state.PreviousValidUntil != nil {
+		result.err = fmt.Errorf("the values replaced by the last rotation are valid until %s and were not revoked yet", state.PreviousValidUntil.Format(time.RFC3339))
+		return result
+	}
+
+	logger.Info("Rotating item")
+	if policy.PreRotationCmd != "" {
+		if err := r.runHook(policy.PreRotationCmd, []string{"ITEM_NAME=" + item.ItemName}); err != nil {
+			result.err = fmt.Errorf("pre-rotation command failed: %w", err)
+			return result
+		}
+	}
+
+	values := map[string][]byte{}
+	previous := map[string][]byte{}
+	for _, field := range item.Fields {
+		if r.disabledClusters.Has(field.Cluster) || !policy.Rotates(field.Name) {
+			continue
+		}
+		value, err := r.generate(field.Cmd)
+		if err != nil {
+			result.err = fmt.Errorf("failed to generate field %s: %w", field.Name, err)
+			return result
+		}
+		values[field.Name] = value
+		current, err := r.client.GetFieldOnItem(item.ItemName, field.Name)
+		if err != nil && !secrets.IsNotFound(err) {
+			result.err = fmt.Errorf("failed to read the current value of field %s: %w", field.Name, err)
+			return result
+		}
+		if err == nil {
+			previous[field.Name] = current
+		}
+	}
+
+	var errs []error
+	for _, field := range sets.List(sets.KeySet(values)) {
+		if err := r.client.SetFieldOnItem(item.ItemName, field, values[field]); err != nil {
+			errs = append(errs, fmt.Errorf("failed to store field %s: %w", field, err))
+		}
+	}
+	if len(errs) == 0 && policy.VerifyCmd != "" {
+		if err := r.runHookWithValues(policy.VerifyCmd, item.ItemName, values); err != nil {
+			errs = append(errs, fmt.Errorf("verification failed: %w", err))
+		}
+	}
+	if len(errs) > 0 {
+		logger.Warn("Rotation failed, restoring the previous values")
+		for _, field := range sets.List(sets.KeySet(values)) {
+			value, existed := previous[field]
+			if !existed {
+				// the field did not exist before the rotation
+				if err := r.client.DeleteFieldOnItem(item.ItemName, field); err != nil {
+					errs = append(errs, fmt.Errorf("failed to delete field %s: %w", field, err))
+				}
+				continue
+			}
+			if err := r.client.SetFieldOnItem(item.ItemName, field, value); err != nil {
+				errs = append(errs, fmt.Errorf("failed to restore field %s: %w", field, err))
+			}
+		}
+		result.err = utilerrors.NewAggregate(errs)
+		return result
+	}
+
+	state = secretgenerator.RotationState{RotatedAt: now}
+	if policy.Overlap != nil && len(previous) > 0 {
+		validUntil := now.Add(policy.Overlap.Duration)
+		state.PreviousValidUntil = &validUntil
+		state.Previous = map[string]string{}
+		for field, value := range previous {
+			state.Previous[field] = string(value)
+		}
+	}
+	if err := r.writeState(item.ItemName, state); err != nil {
+		result.err = err
+		return result
+	}
+	result.rotated = true
+	return result
+}
+
+// affectedSecrets returns the secrets ci-secret-bootstrap syncs from the item
+func affectedSecrets(item string, config secretbootstrap.Config, gsmConfig api.GSMConfig) []secretbootstrap.SecretContext {
+	var contexts []secretbootstrap.SecretContext
+	seen := sets.New[string]()
+	add := func(context secretbootstrap.SecretContext) {
+		if key := context.String(); !seen.Has(key) {
+			seen.Insert(key)
+			contexts = append(contexts, context)
+		}
+	}
+	trim := func(name string) string {
+		return strings.TrimPrefix(name, config.VaultDPTPPrefix+"/")
+	}
+	for _, secret := range config.Secrets {
+		var uses bool
+		for _, from := range secret.From {
+			if trim(from.Item) == item {
+				uses = true
+			}
+			for _, dc := range from.DockerConfigJSONData {
+				if trim(dc.Item) == item {
+					uses = true
+				}
+			}
+		}
+		if uses {
+			for _, to := range secret.To {
+				add(to)
+			}
+		}
+	}
+	group := gsmvalidation.NormalizeName(item)
+	for _, bundle := range gsmConfig.Bundles {
+		if !bundle.SyncToCluster {
+			continue
+		}
+		var uses bool
+		for _, gsmSecret := range bundle.GSMSecrets {
+			if gsmSecret.Collection == secrets.TestPlatformCollection && gsmSecret.Group == group {
+				uses = true
+			}
+		}
+		if bundle.DockerConfig != nil {
+			for _, registry := range bundle.DockerConfig.Registries {
+				if gsmConfig.DPTPCollection == secrets.TestPlatformCollection && registry.Group == group {
+					uses = true
+				}
+			}
+		}
+		if uses {
+			for _, target := range bundle.Targets {
+				add(secretbootstrap.SecretContext{Cluster: target.Cluster, Namespace: target.Namespace, Name: bundle.Name, Type: target.Type})
+			}
+		}
+	}
+	sort.Slice(contexts, func(i, j int) bool {
+		return contexts[i].String() < contexts[j].String()
+	})
+	return contexts
+}
+
+// bootstrapTrigger runs ci-secret-bootstrap for the secrets with the given names
+type bootstrapTrigger interface {
+	trigger(ctx context.Context, secretNames []string) error
+}
+
+// prowJobBootstrapTrigger triggers the periodic job running ci-secret-bootstrap,
+// limited to the given secrets with --secret-names
+type prowJobBootstrapTrigger struct {
+	client ctrlruntimeclient.Client
+	job    prowconfig.Periodic
+	config *prowconfig.Config
+}
+
+func newProwJobBootstrapTrigger(client ctrlruntimeclient.Client, config *prowconfig.Config, jobName string) (*prowJobBootstrapTrigger, error) {
+	for _, job := range config.AllPeriodics() {
+		if job.Name == jobName {
+			return &prowJobBootstrapTrigger{client: client, job: job, config: config}, nil
+		}
+	}
+	return nil, fmt.Errorf("failed to find the periodic job %s", jobName)
+}
+
+func (t *prowJobBootstrapTrigger) trigger(ctx context.Context, secretNames []string) error {
+	prowJob := pjutil.NewProwJob(pjutil.PeriodicSpec(t.job), nil, nil, pjutil.RequireScheduling(t.config.Scheduler.Enabled))
+	prowJob.Namespace = t.config.ProwJobNamespace
+	if prowJob.Spec.PodSpec == nil || len(prowJob.Spec.PodSpec.Containers) == 0 {
+		return fmt.Errorf("job %s has no containers", t.job.Name)
+	}
+	// the spec shares the pod spec with the job configuration
+	prowJob.Spec.PodSpec = prowJob.Spec.PodSpec.DeepCopy()
+	for _, name := range secretNames {
+		prowJob.Spec.PodSpec.Containers[0].Args = append(prowJob.Spec.PodSpec.Containers[0].Args, "--secret-names="+name)
+	}
+	if err := t.client.Create(ctx, &prowJob); err != nil {
+		return fmt.Errorf("failed to create a ProwJob for %s: %w", t.job.Name, err)
+	}
+	logrus.WithFields(logrus.Fields{"job": t.job.Name, "prowjob": prowJob.Name, "secrets": secretNames}).Info("Triggered ci-secret-bootstrap")
+	return nil
+}
+
+type slackClient interface {
+	PostMessage(channelID string, options ...slack.MsgOption) (string, string, error)
+}
+
+// rotationReport summarizes the rotations that did something or failed
+func rotationReport(results []rotationResult) string {
+	var lines []string
+	for _, result := range results {
+		var secretNames []string
+		for _, secret := range result.secrets {
+			secretNames = append(secretNames, fmt.Sprintf("`%s`", secret))
+		}
+		switch {
+		case result.err != nil:
+			lines = append(lines, fmt.Sprintf(":x: Failed to rotate `%s`: %v", result.item, result.err))
+		case result.rotated && len(secretNames) > 0:
+			lines = append(lines, fmt.Sprintf(":white_check_mark: Rotated `%s`, syncing %s", result.item, strings.Join(secretNames, ", ")))
+		case result.rotated:
+			lines = append(lines, fmt.Sprintf(":white_check_mark: Rotated `%s`", result.item))
+		}
+		if result.revoked {
+			lines = append(lines, fmt.Sprintf(":wastebasket: Revoked the previous values of `%s`", result.item))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// reportRotations posts the rotation report, unless there is nothing to report
+func reportRotations(client slackClient, channel string, results []rotationResult) error {
+	text := rotationReport(results)
+	if text == "" {
+		return nil
+	}
+	blocks := []slack.Block{
+		slack.NewHeaderBlock(slack.NewTextBlockObject(slack.PlainTextType, "Credential rotation", false, false)),
+		slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, text, false, false), nil, nil),
+	}
+	if _, _, err := client.PostMessage(channel, slack.MsgOptionText(text, false), slack.MsgOptionBlocks(blocks...)); err != nil {
+		return fmt.Errorf("failed to post the rotation report to Slack: %w", err)
+	}
+	return nil
+}
+
+// rotateSecrets rotates the items with a rotation policy, triggers ci-secret-bootstrap
+// for the secrets synced from the rotated items and reports the results
+func rotateSecrets(ctx context.Context, o options, r *rotator, trigger bootstrapTrigger, reporter slackClient, existingIndexEntries sets.Set[string]) []error {
+	var errs []error
+	var results []rotationResult
+	secretNames := sets.New[string]()
+	indexEntries := existingIndexEntries.Clone()
+	for _, item := range o.config {
+		if item.Rotation == nil {
+			continue
+		}
+		result := r.rotate(item)
+		if result.err != nil {
+			errs = append(errs, fmt.Errorf("item %s: %w", item.ItemName, result.err))
+		}
+		if result.rotated {
+			result.secrets = affectedSecrets(item.ItemName, o.bootstrapConfig, o.gsmConfig)
+			for _, secret := range result.secrets {
+				secretNames.Insert(secret.Name)
+			}
+			for _, field := range item.Fields {
+				if !o.disabledClusters.Has(field.Cluster) {
+					indexEntries.Insert(gsmvalidation.NormalizeName(item.ItemName) + gsmvalidation.CollectionSecretDelimiter + gsmvalidation.NormalizeName(field.Name))
+				}
+			}
+		}
+		results = append(results, result)
+	}
+
+	if o.enableGsmSync && !indexEntries.Equal(existingIndexEntries) {
+		if err := r.client.UpdateIndexSecret(secrets.TestPlatformCollection, gsm.ConstructIndexSecretContent(sets.List(indexEntries))); err != nil {
+			errs = append(errs, fmt.Errorf("failed to update the index secret: %w", err))
+		}
+	}
+	if trigger != nil && secretNames.Len() > 0 {
+		if err := trigger.trigger(ctx, sets.List(secretNames)); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if reporter != nil {
+		if err := reportRotations(reporter, o.slackChannel, results); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
@@ -0,0 +1,461 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	vaultapi "github.com/hashicorp/vault/api"
+	"github.com/slack-go/slack"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	prowv1 "sigs.k8s.io/prow/pkg/apis/prowjobs/v1"
+
+	"github.com/openshift/ci-tools/pkg/api"
+	"github.com/openshift/ci-tools/pkg/api/secretbootstrap"
+	"github.com/openshift/ci-tools/pkg/api/secretgenerator"
+	gsm "github.com/openshift/ci-tools/pkg/gsm-secrets"
+	"github.com/openshift/ci-tools/pkg/secrets"
+	"github.com/openshift/ci-tools/pkg/testhelper"
+)
+
+type fakeSecretsClient struct {
+	secrets.Client
+	items map[string]map[string]string
+	index []byte
+}
+
+func (c *fakeSecretsClient) GetFieldOnItem(itemName, fieldName string) ([]byte, error) {
+	value, ok := c.items[itemName][fieldName]
+	if !ok {
+		return nil, &vaultapi.ResponseError{StatusCode: http.StatusNotFound}
+	}
+	return []byte(value), nil
+}
+
+func (c *fakeSecretsClient) SetFieldOnItem(itemName, fieldName string, fieldValue []byte) error {
+	if c.items[itemName] == nil {
+		c.items[itemName] = map[string]string{}
+	}
+	c.items[itemName][fieldName] = string(fieldValue)
+	return nil
+}
+
+func (c *fakeSecretsClient) DeleteFieldOnItem(itemName, fieldName string) error {
+	delete(c.items[itemName], fieldName)
+	return nil
+}
+
+func (c *fakeSecretsClient) UpdateIndexSecret(_ string, payload []byte) error {
+	c.index = payload
+	return nil
+}
+
+type hookCall struct {
+	command string
+	item    string
+	values  map[string]string
+}
+
+// fakeHooks records the hooks that ran along with the values they were given
+type fakeHooks struct {
+	calls []hookCall
+	fail  sets.Set[string]
+}
+
+func (h *fakeHooks) run(command string, env []string) error {
+	call := hookCall{command: command}
+	for _, variable := range env {
+		name, value, _ := strings.Cut(variable, "=")
+		switch name {
+		case "ITEM_NAME":
+			call.item = value
+		case "ROTATION_VALUES_DIR":
+			entries, err := os.ReadDir(value)
+			if err != nil {
+				return err
+			}
+			call.values = map[string]string{}
+			for _, entry := range entries {
+				raw, err := os.ReadFile(filepath.Join(value, entry.Name()))
+				if err != nil {
+					return err
+				}
+				call.values[entry.Name()] = string(raw)
+			}
+		}
+	}
+	h.calls = append(h.calls, call)
+	if h.fail.Has(command) {
+		return errors.New("hook failed")
+	}
+	return nil
+}
+
+func TestRotate(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	lastMonth := now.Add(-30 * 24 * time.Hour)
+	yesterday := now.Add(-24 * time.Hour)
+	tomorrow := now.Add(24 * time.Hour)
+	item := func(policy secretgenerator.RotationPolicy) secretgenerator.SecretItem {
+		return secretgenerator.SecretItem{
+			ItemName: "item",
+			Fields: []secretgenerator.FieldGenerator{
+				{Name: "token", Cmd: "new-token", Cluster: "app.ci"},
+				{Name: "other", Cmd: "new-other", Cluster: "disabled"},
+			},
+			Rotation: &policy,
+		}
+	}
+	overlap := &prowv1.Duration{Duration: 48 * time.Hour}
+	state := func(s secretgenerator.RotationState) string {
+		raw, err := json.Marshal(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(raw)
+	}
+
+	testCases := []struct {
+		name          string
+		item          secretgenerator.SecretItem
+		items         map[string]map[string]string
+		failingHooks  sets.Set[string]
+		dryRun        bool
+		expected      rotationResult
+		expectedItems map[string]map[string]string
+		expectedHooks []hookCall
+	}{
+		{
+			name: "item that was never rotated is rotated",
+			item: item(secretgenerator.RotationPolicy{MaxAge: prowv1.Duration{Duration: 7 * 24 * time.Hour}, PreRotationCmd: "pre", VerifyCmd: "verify"}),
+			items: map[string]map[string]string{
+				"item": {"token": "old-token"},
+			},
+			expected: rotationResult{item: "item", due: true, rotated: true},
+			expectedItems: map[string]map[string]string{
+				"item": {"token": "new-token", secretgenerator.RotationStateField: state(secretgenerator.RotationState{RotatedAt: now})},
+			},
+			expectedHooks: []hookCall{
+				{command: "pre", item: "item"},
+				{command: "verify", item: "item", values: map[string]string{"token": "new-token"}},
+			},
+		},
+		{
+			name: "item that is not due is left alone",
+			item: item(secretgenerator.RotationPolicy{MaxAge: prowv1.Duration{Duration: 7 * 24 * time.Hour}, PreRotationCmd: "pre"}),
+			items: map[string]map[string]string{
+				"item": {"token": "old-token", secretgenerator.RotationStateField: state(secretgenerator.RotationState{RotatedAt: yesterday})},
+			},
+			expected: rotationResult{item: "item"},
+			expectedItems: map[string]map[string]string{
+				"item": {"token": "old-token", secretgenerator.RotationStateField: state(secretgenerator.RotationState{RotatedAt: yesterday})},
+			},
+		},
+		{
+			name: "previous values are kept during the overlap",
+			item: item(secretgenerator.RotationPolicy{MaxAge: prowv1.Duration{Duration: 7 * 24 * time.Hour}, Overlap: overlap, RevokeCmd: "revoke"}),
+			items: map[string]map[string]string{
+				"item": {"token": "old-token", secretgenerator.RotationStateField: state(secretgenerator.RotationState{RotatedAt: lastMonth})},
+			},
+			expected: rotationResult{item: "item", due: true, rotated: true},
+			expectedItems: map[string]map[string]string{
+				"item": {"token": "new-token", secretgenerator.RotationStateField: state(secretgenerator.RotationState{
+					RotatedAt:          now,
+					Previous:           map[string]string{"token": "old-token"},
+					PreviousValidUntil: &[]time.Time{now.Add(overlap.Duration)}[0],
+				})},
+			},
+		},
+		{
+			name: "previous values are revoked once the overlap is over",
+			item: item(secretgenerator.RotationPolicy{MaxAge: prowv1.Duration{Duration: 7 * 24 * time.Hour}, Overlap: overlap, RevokeCmd: "revoke"}),
+			items: map[string]map[string]string{
+				"item": {"token": "new-token", secretgenerator.RotationStateField: state(secretgenerator.RotationState{
+					RotatedAt:          now.Add(-3 * 24 * time.Hour),
+					Previous:           map[string]string{"token": "old-token"},
+					PreviousValidUntil: &yesterday,
+				})},
+			},
+			expected: rotationResult{item: "item", revoked: true},
+			expectedItems: map[string]map[string]string{
+				"item": {"token": "new-token", secretgenerator.RotationStateField: state(secretgenerator.RotationState{RotatedAt: now.Add(-3 * 24 * time.Hour)})},
+			},
+			expectedHooks: []hookCall{
+				{command: "revoke", item: "item", values: map[string]string{"token": "old-token"}},
+			},
+		},
+		{
+			name: "item is not rotated while the previous values are still valid",
+			item: item(secretgenerator.RotationPolicy{MaxAge: prowv1.Duration{Duration: time.Hour}, Overlap: overlap}),
+			items: map[string]map[string]string{
+				"item": {"token": "new-token", secretgenerator.RotationStateField: state(secretgenerator.RotationState{
+					RotatedAt:          yesterday,
+					Previous:           map[string]string{"token": "old-token"},
+					PreviousValidUntil: &tomorrow,
+				})},
+			},
+			expected: rotationResult{item: "item", due: true, err: errors.New("the values replaced by the last rotation are valid until 2024-06-02T12:00:00Z and were not revoked yet")},
+			expectedItems: map[string]map[string]string{
+				"item": {"token": "new-token", secretgenerator.RotationStateField: state(secretgenerator.RotationState{
+					RotatedAt:          yesterday,
+					Previous:           map[string]string{"token": "old-token"},
+					PreviousValidUntil: &tomorrow,
+				})},
+			},
+		},
+		{
+			name:         "previous values are restored when the verification fails",
+			item:         item(secretgenerator.RotationPolicy{MaxAge: prowv1.Duration{Duration: time.Hour}, VerifyCmd: "verify"}),
+			failingHooks: sets.New[string]("verify"),
+			items: map[string]map[string]string{
+				"item": {"token": "old-token", secretgenerator.RotationStateField: state(secretgenerator.RotationState{RotatedAt: lastMonth})},
+			},
+			expected: rotationResult{item: "item", due: true, err: errors.New("verification failed: hook failed")},
+			expectedItems: map[string]map[string]string{
+				"item": {"token": "old-token", secretgenerator.RotationStateField: state(secretgenerator.RotationState{RotatedAt: lastMonth})},
+			},
+			expectedHooks: []hookCall{
+				{command: "verify", item: "item", values: map[string]string{"token": "new-token"}},
+			},
+		},
+		{
+			name: "fields that did not exist are deleted when the verification fails",
+			item: secretgenerator.SecretItem{
+				ItemName: "item",
+				Fields: []secretgenerator.FieldGenerator{
+					{Name: "token", Cmd: "new-token", Cluster: "app.ci"},
+					{Name: "key", Cmd: "new-key", Cluster: "app.ci"},
+				},
+				Rotation: &secretgenerator.RotationPolicy{MaxAge: prowv1.Duration{Duration: time.Hour}, VerifyCmd: "verify"},
+			},
+			failingHooks: sets.New[string]("verify"),
+			items: map[string]map[string]string{
+				"item": {"token": "old-token"},
+			},
+			expected: rotationResult{item: "item", due: true, err: errors.New("verification failed: hook failed")},
+			expectedItems: map[string]map[string]string{
+				"item": {"token": "old-token"},
+			},
+			expectedHooks: []hookCall{
+				{command: "verify", item: "item", values: map[string]string{"token": "new-token", "key": "new-key"}},
+			},
+		},
+		{
+			name: "only the rotated fields are rotated",
+			item: secretgenerator.SecretItem{
+				ItemName: "item",
+				Fields: []secretgenerator.FieldGenerator{
+					{Name: "token", Cmd: "new-token", Cluster: "app.ci"},
+					{Name: "static", Cmd: "new-static", Cluster: "app.ci"},
+				},
+				Rotation: &secretgenerator.RotationPolicy{MaxAge: prowv1.Duration{Duration: time.Hour}, Fields: []string{"token"}},
+			},
+			items: map[string]map[string]string{
+				"item": {"token": "old-token", "static": "static"},
+			},
+			expected: rotationResult{item: "item", due: true, rotated: true},
+			expectedItems: map[string]map[string]string{
+				"item": {"token": "new-token", "static": "static", secretgenerator.RotationStateField: state(secretgenerator.RotationState{RotatedAt: now})},
+			},
+		},
+		{
+			name:         "nothing is generated when the pre-rotation command fails",
+			item:         item(secretgenerator.RotationPolicy{MaxAge: prowv1.Duration{Duration: time.Hour}, PreRotationCmd: "pre"}),
+			failingHooks: sets.New[string]("pre"),
+			items: map[string]map[string]string{
+				"item": {"token": "old-token"},
+			},
+			expected: rotationResult{item: "item", due: true, err: errors.New("pre-rotation command failed: hook failed")},
+			expectedItems: map[string]map[string]string{
+				"item": {"token": "old-token"},
+			},
+			expectedHooks: []hookCall{
+				{command: "pre", item: "item"},
+			},
+		},
+		{
+			name:   "dry run only reports items that are due",
+			item:   item(secretgenerator.RotationPolicy{MaxAge: prowv1.Duration{Duration: time.Hour}, PreRotationCmd: "pre"}),
+			dryRun: true,
+			items: map[string]map[string]string{
+				"item": {"token": "old-token", secretgenerator.RotationStateField: state(secretgenerator.RotationState{RotatedAt: lastMonth})},
+			},
+			expected: rotationResult{item: "item", due: true},
+			expectedItems: map[string]map[string]string{
+				"item": {"token": "old-token", secretgenerator.RotationStateField: state(secretgenerator.RotationState{RotatedAt: lastMonth})},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &fakeSecretsClient{items: tc.items}
+			hooks := &fakeHooks{fail: tc.failingHooks}
+			r := &rotator{
+				client:           client,
+				stateClient:      client,
+				disabledClusters: sets.New[string]("disabled"),
+				dryRun:           tc.dryRun,
+				now:              func() time.Time { return now },
+				generate:         func(command string) ([]byte, error) { return []byte(command), nil },
+				runHook:          hooks.run,
+			}
+			result := r.rotate(tc.item)
+			if diff := cmp.Diff(tc.expected, result, cmp.AllowUnexported(rotationResult{}), testhelper.EquateErrorMessage); diff != "" {
+				t.Errorf("unexpected result: %s", diff)
+			}
+			if diff := cmp.Diff(tc.expectedItems, client.items); diff != "" {
+				t.Errorf("unexpected items: %s", diff)
+			}
+			if diff := cmp.Diff(tc.expectedHooks, hooks.calls, cmp.AllowUnexported(hookCall{})); diff != "" {
+				t.Errorf("unexpected hooks: %s", diff)
+			}
+		})
+	}
+}
+
+func TestAffectedSecrets(t *testing.T) {
+	config := secretbootstrap.Config{
+		VaultDPTPPrefix: "dptp",
+		Secrets: []secretbootstrap.SecretConfig{
+			{
+				From: map[string]secretbootstrap.ItemContext{"token": {Item: "dptp/rotated", Field: "token"}},
+				To:   []secretbootstrap.SecretContext{{Cluster: "app.ci", Namespace: "ci", Name: "rotated"}, {Cluster: "build01", Namespace: "ci", Name: "rotated"}},
+			},
+			{
+				From: map[string]secretbootstrap.ItemContext{".dockerconfigjson": {DockerConfigJSONData: []secretbootstrap.DockerConfigJSONData{{Item: "dptp/rotated", RegistryURL: "quay.io", AuthField: "auth"}}}},
+				To:   []secretbootstrap.SecretContext{{Cluster: "app.ci", Namespace: "ci", Name: "pull-secret"}},
+			},
+			{
+				From: map[string]secretbootstrap.ItemContext{"token": {Item: "dptp/other", Field: "token"}},
+				To:   []secretbootstrap.SecretContext{{Cluster: "app.ci", Namespace: "ci", Name: "other"}},
+			},
+		},
+	}
+	gsmConfig := api.GSMConfig{
+		DPTPCollection: secrets.TestPlatformCollection,
+		Bundles: []api.GSMBundle{
+			{
+				Name:          "gsm-rotated",
+				GSMSecrets:    []api.GSMSecretRef{{Collection: secrets.TestPlatformCollection, Group: "rotated"}},
+				SyncToCluster: true,
+				Targets:       []api.TargetSpec{{Cluster: "build02", Namespace: "test-credentials"}},
+			},
+			{
+				Name:          "not-synced",
+				GSMSecrets:    []api.GSMSecretRef{{Collection: secrets.TestPlatformCollection, Group: "rotated"}},
+				SyncToCluster: false,
+			},
+			{
+				Name:          "other-collection",
+				GSMSecrets:    []api.GSMSecretRef{{Collection: "team", Group: "rotated"}},
+				SyncToCluster: true,
+				Targets:       []api.TargetSpec{{Cluster: "build02", Namespace: "test-credentials"}},
+			},
+		},
+	}
+	expected := []secretbootstrap.SecretContext{
+		{Cluster: "app.ci", Namespace: "ci", Name: "pull-secret"},
+		{Cluster: "app.ci", Namespace: "ci", Name: "rotated"},
+		{Cluster: "build01", Namespace: "ci", Name: "rotated"},
+		{Cluster: "build02", Namespace: "test-credentials", Name: "gsm-rotated"},
+	}
+	if diff := cmp.Diff(expected, affectedSecrets("rotated", config, gsmConfig)); diff != "" {
+		t.Errorf("unexpected secrets: %s", diff)
+	}
+}
+
+type fakeBootstrapTrigger struct {
+	triggered [][]string
+}
+
+func (f *fakeBootstrapTrigger) trigger(_ context.Context, secretNames []string) error {
+	f.triggered = append(f.triggered, secretNames)
+	return nil
+}
+
+type recordingSlackClient struct {
+	channel  string
+	messages int
+}
+
+func (c *recordingSlackClient) PostMessage(channelID string, _ ...slack.MsgOption) (string, string, error) {
+	c.channel = channelID
+	c.messages++
+	return channelID, "123.456", nil
+}
+
+func TestRotateSecrets(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	o := options{
+		enableGsmSync: true,
+		slackChannel:  "rotations",
+		config: secretgenerator.Config{
+			{ItemName: "static", Fields: []secretgenerator.FieldGenerator{{Name: "token", Cmd: "static"}}},
+			{ItemName: "rotated", Fields: []secretgenerator.FieldGenerator{{Name: "token", Cmd: "new-token"}}, Rotation: &secretgenerator.RotationPolicy{MaxAge: prowv1.Duration{Duration: time.Hour}}},
+		},
+		bootstrapConfig: secretbootstrap.Config{
+			Secrets: []secretbootstrap.SecretConfig{{
+				From: map[string]secretbootstrap.ItemContext{"token": {Item: "rotated", Field: "token"}},
+				To:   []secretbootstrap.SecretContext{{Cluster: "app.ci", Namespace: "ci", Name: "rotated-secret"}},
+			}},
+		},
+	}
+	client := &fakeSecretsClient{items: map[string]map[string]string{}}
+	r := &rotator{
+		client:           client,
+		stateClient:      client,
+		disabledClusters: sets.New[string](),
+		now:              func() time.Time { return now },
+		generate:         func(command string) ([]byte, error) { return []byte(command), nil },
+		runHook:          (&fakeHooks{}).run,
+	}
+	trigger := &fakeBootstrapTrigger{}
+	reporter := &recordingSlackClient{}
+
+	if errs := rotateSecrets(context.Background(), o, r, trigger, reporter, sets.New[string]("static__token")); len(errs) > 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	if diff := cmp.Diff([]string{"rotated"}, sets.List(sets.KeySet(client.items))); diff != "" {
+		t.Errorf("unexpected items: %s", diff)
+	}
+	if diff := cmp.Diff(string(gsm.ConstructIndexSecretContent([]string{"rotated__token", "static__token"})), string(client.index)); diff != "" {
+		t.Errorf("unexpected index: %s", diff)
+	}
+	if diff := cmp.Diff([][]string{{"rotated-secret"}}, trigger.triggered); diff != "" {
+		t.Errorf("unexpected triggers: %s", diff)
+	}
+	if reporter.messages != 1 || reporter.channel != "rotations" {
+		t.Errorf("expected one message to the rotations channel, got %d to %q", reporter.messages, reporter.channel)
+	}
+}
+
+func TestRotationReport(t *testing.T) {
+	results := []rotationResult{
+		{item: "not-due"},
+		{item: "rotated", due: true, rotated: true, secrets: []secretbootstrap.SecretContext{{Cluster: "app.ci", Namespace: "ci", Name: "secret"}}},
+		{item: "unused", due: true, rotated: true},
+		{item: "failed", due: true, err: errors.New("verification failed")},
+		{item: "revoked", revoked: true},
+	}
+	expected := strings.Join([]string{
+		":white_check_mark: Rotated `rotated`, syncing `ci/secret in cluster app.ci`",
+		":white_check_mark: Rotated `unused`",
+		":x: Failed to rotate `failed`: verification failed",
+		":wastebasket: Revoked the previous values of `revoked`",
+	}, "\n")
+	if diff := cmp.Diff(expected, rotationReport(results)); diff != "" {
+		t.Errorf("unexpected report: %s", diff)
+	}
+	if report := rotationReport([]rotationResult{{item: "not-due"}}); report != "" {
+		t.Errorf("expected no report, got %q", report)
+	}
+}
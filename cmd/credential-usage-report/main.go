@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/sirupsen/logrus"
+
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"sigs.k8s.io/prow/pkg/logrusutil"
+
+	"github.com/openshift/ci-tools/pkg/credentialusage"
+	"github.com/openshift/ci-tools/pkg/load"
+)
+
+type options struct {
+	registryPath string
+	evidenceDir  string
+	outputPath   string
+	logLevel     string
+}
+
+func parseOptions() (options, error) {
+	var o options
+	fs := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	fs.StringVar(&o.registryPath, "registry", "", "Path to the step registry.")
+	fs.StringVar(&o.evidenceDir, "evidence-dir", "", fmt.Sprintf("Path to a directory holding the %s files recorded by steps, searched recursively.", credentialusage.EvidenceFile))
+	fs.StringVar(&o.outputPath, "output", "", "If set, write the report to this file instead of the standard output.")
+	fs.StringVar(&o.logLevel, "log-level", "info", fmt.Sprintf("Log level is one of %v.", logrus.AllLevels))
+	if err := fs.Parse(os.Args[1:]); err != nil {
+		return options{}, err
+	}
+	return o, nil
+}
+
+func (o *options) validate() error {
+	var errs []error
+	level, err := logrus.ParseLevel(o.logLevel)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("invalid log level specified: %w", err))
+	}
+	logrus.SetLevel(level)
+	if o.registryPath == "" {
+		errs = append(errs, errors.New("--registry is required"))
+	}
+	if o.evidenceDir == "" {
+		errs = append(errs, errors.New("--evidence-dir is required"))
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// loadEvidence reads all evidence files under the directory
+func loadEvidence(dir string) ([]credentialusage.Evidence, error) {
+	var evidence []credentialusage.Evidence
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || entry.Name() != credentialusage.EvidenceFile {
+			return nil
+		}
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		var e credentialusage.Evidence
+		if err := json.Unmarshal(raw, &e); err != nil {
+			return fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		evidence = append(evidence, e)
+		return nil
+	})
+	return evidence, err
+}
+
+func main() {
+	logrusutil.ComponentInit()
+	o, err := parseOptions()
+	if err != nil {
+		logrus.WithError(err).Fatalf("cannot parse args: %q", os.Args[1:])
+	}
+	if err := o.validate(); err != nil {
+		logrus.WithError(err).Fatal("Invalid arguments.")
+	}
+
+	references, _, _, _, _, _, _, err := load.Registry(o.registryPath, load.RegistryFlag(0))
+	if err != nil {
+		logrus.WithError(err).Fatal("Failed to load the step registry.")
+	}
+	evidence, err := loadEvidence(o.evidenceDir)
+	if err != nil {
+		logrus.WithError(err).Fatal("Failed to load the evidence.")
+	}
+	logrus.WithField("files", len(evidence)).Info("Loaded credential usage evidence.")
+
+	report := credentialusage.Aggregate(references, evidence)
+	for _, finding := range report.Findings {
+		logrus.WithFields(logrus.Fields{
+			"step":       finding.Step,
+			"credential": finding.Credential,
+			"mount_path": finding.MountPath,
+			"runs":       finding.Runs,
+		}).Warn("Credential was mounted but never read.")
+	}
+	raw, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		logrus.WithError(err).Fatal("Failed to serialize the report.")
+	}
+	if o.outputPath != "" {
+		if err := os.WriteFile(o.outputPath, raw, 0644); err != nil {
+			logrus.WithError(err).Fatal("Failed to write the report.")
+		}
+		return
+	}
+	fmt.Println(string(raw))
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"flag"
 
 	"github.com/sirupsen/logrus"
@@ -11,15 +12,29 @@ import (
 
 type options struct {
 	config.ConfirmableOptions
+
+	fold    bool
+	flatten bool
 }
 
 func (o options) validate() error {
+	if o.fold && o.flatten {
+		return errors.New("--fold and --flatten are mutually exclusive")
+	}
+	if (o.fold || o.flatten) && !o.ProcessAll() {
+		return errors.New("--fold and --flatten need all configurations of a repo, not only the changed ones")
+	}
+	if o.flatten && (o.Org != "" || o.Repo != "") {
+		return errors.New("--flatten removes the defaults and fragments of all configurations and cannot be limited to an --org or --repo")
+	}
 	return o.ConfirmableOptions.Validate()
 }
 
 func gatherOptions() options {
 	o := options{}
 	o.Bind(flag.CommandLine)
+	flag.BoolVar(&o.fold, "fold", false, "Move the blocks and tests all configurations of a repo share into the defaults of the repo")
+	flag.BoolVar(&o.flatten, "flatten", false, "Remove all defaults and fragments and write every configuration with all of its content")
 	flag.Parse()
 
 	return o
@@ -56,8 +71,25 @@ func main() {
 		logrus.WithError(err).Fatal("Could not branch configurations.")
 	}
 
+	if o.Confirm {
+		switch {
+		case o.fold:
+			if err := config.FoldRepoDefaults(o.ConfigDir, toCommit); err != nil {
+				logrus.WithError(err).Fatal("Could not fold configurations.")
+			}
+		case o.flatten:
+			if err := config.RemoveLayers(o.ConfigDir); err != nil {
+				logrus.WithError(err).Fatal("Could not remove defaults and fragments.")
+			}
+			for i := range toCommit {
+				toCommit[i].Info.Extends = nil
+			}
+		}
+	}
+
+	// configurations stay layered over the defaults and fragments they extend
 	for _, output := range toCommit {
-		if err := output.CommitTo(o.ConfigDir); err != nil {
+		if err := output.CommitFoldedTo(o.ConfigDir); err != nil {
 			logrus.WithError(err).Fatal("commitTo failed")
 		}
 	}
@@ -14,6 +14,7 @@ import (
 
 	"github.com/bombsimon/logrusr/v3"
 	"github.com/sirupsen/logrus"
+	"github.com/slack-go/slack"
 	"gopkg.in/fsnotify.v1"
 
 	utilerrors "k8s.io/apimachinery/pkg/util/errors"
@@ -36,6 +37,7 @@ import (
 
 	"github.com/openshift/ci-tools/pkg/api"
 	ephemeralclusterv1 "github.com/openshift/ci-tools/pkg/api/ephemeralcluster/v1"
+	serviceaccountsecretpolicyv1 "github.com/openshift/ci-tools/pkg/api/serviceaccountsecretpolicy/v1"
 	"github.com/openshift/ci-tools/pkg/config"
 	"github.com/openshift/ci-tools/pkg/controller/ephemeralcluster"
 	"github.com/openshift/ci-tools/pkg/controller/promotionreconciler"
@@ -114,6 +116,10 @@ type serviceAccountSecretRefresherOptions struct {
 	enabledNamespaces     flagutil.Strings
 	removeOldSecrets      bool
 	ignoreServiceAccounts flagutil.Strings
+	enablePolicies        bool
+	slackTokenPath        string
+	reportAddress         string
+	reportCredentialsFile string
 }
 
 type ephemeralClusterProvisionerOptions struct {
@@ -146,6 +152,10 @@ func newOpts() (*options, error) {
 	fs.Var(&opts.serviceAccountSecretRefresherOptions.enabledNamespaces, "serviceAccountRefresherOptions.enabled-namespace", "A namespace for which the serviceaccount_secret_refresher should be enabled. Can be passed multiple times.")
 	fs.BoolVar(&opts.serviceAccountSecretRefresherOptions.removeOldSecrets, "serviceAccountRefresherOptions.remove-old-secrets", false, "whether the serviceaccountsecretrefresher should delete secrets older than 30 days")
 	fs.Var(&opts.serviceAccountSecretRefresherOptions.ignoreServiceAccounts, "serviceAccountRefresherOptions.ignore-service-account", "The service account to ignore. It must be in namespace/name format (e.G `ci/sync-rover-groups-updater`). Can be passed multiple times.")
+	fs.BoolVar(&opts.serviceAccountSecretRefresherOptions.enablePolicies, "serviceAccountRefresherOptions.enable-policies", false, "whether the serviceaccountsecretrefresher should apply the ServiceAccountSecretPolicies on app.ci in addition to the default policy of the enabled namespaces")
+	fs.StringVar(&opts.serviceAccountSecretRefresherOptions.slackTokenPath, "serviceAccountRefresherOptions.slack-token-path", "", "Path to the Slack token used to notify the channels of the ServiceAccountSecretPolicies about rotations.")
+	fs.StringVar(&opts.serviceAccountSecretRefresherOptions.reportAddress, "serviceAccountRefresherOptions.report-address", "", "Address to serve the report of the managed service account secrets on, e.g. :8082. The report is disabled when empty.")
+	fs.StringVar(&opts.serviceAccountSecretRefresherOptions.reportCredentialsFile, "serviceAccountRefresherOptions.report-credentials-file", "", "File holding the credentials in the form '<username>:<password>' that requests to the report must authenticate with. Required with --serviceAccountRefresherOptions.report-address.")
 	fs.Var(&opts.imagePusherOptions.imageStreamsRaw, "imagePusherOptions.image-stream", "An imagestream that will be synced. It must be in namespace/name format (e.G `ci/clonerefs`). Can be passed multiple times.")
 	fs.Var(&opts.promotionReconcilerOptions.ignoreImageStreamsRaw, "promotionReconcilerOptions.ignore-image-stream", "The image stream to ignore. It is an regular expression (e.G ^openshift-priv/.+). Can be passed multiple times.")
 	fs.StringVar(&opts.promotionReconcilerOptions.sinceRaw, "promotionReconcilerOptions.since", "360h", "The image stream tags to reconcile if it is younger than a relative duration like 5s, 2m, or 3h. Defaults to 360h, i.e., 15 days")
@@ -245,8 +255,14 @@ func newOpts() (*options, error) {
 	}
 
 	if opts.enabledControllersSet.Has(serviceaccountsecretrefresher.ControllerName) {
-		if len(opts.serviceAccountSecretRefresherOptions.enabledNamespaces.Strings()) == 0 {
-			errs = append(errs, fmt.Errorf("--serviceAccountRefresherOptions.enabled-namespace must be set at least once when enabling the %s controller, otherwise it won't do anything", serviceaccountsecretrefresher.ControllerName))
+		if len(opts.serviceAccountSecretRefresherOptions.enabledNamespaces.Strings()) == 0 && !opts.serviceAccountSecretRefresherOptions.enablePolicies {
+			errs = append(errs, fmt.Errorf("--serviceAccountRefresherOptions.enabled-namespace must be set at least once or --serviceAccountRefresherOptions.enable-policies must be set when enabling the %s controller, otherwise it won't do anything", serviceaccountsecretrefresher.ControllerName))
+		}
+		if opts.serviceAccountSecretRefresherOptions.reportAddress != "" && opts.serviceAccountSecretRefresherOptions.reportCredentialsFile == "" {
+			errs = append(errs, errors.New("--serviceAccountRefresherOptions.report-credentials-file is required with --serviceAccountRefresherOptions.report-address"))
+		}
+		if opts.serviceAccountSecretRefresherOptions.slackTokenPath != "" && !opts.serviceAccountSecretRefresherOptions.enablePolicies {
+			errs = append(errs, errors.New("--serviceAccountRefresherOptions.slack-token-path requires --serviceAccountRefresherOptions.enable-policies, as the notification channels are configured in the policies"))
 		}
 	}
 
@@ -451,6 +467,9 @@ func main() {
 	if err := ephemeralclusterv1.AddToScheme(mgr.GetScheme()); err != nil {
 		logrus.WithError(err).Fatal("Failed to add ephemeralclusterv1 to scheme")
 	}
+	if err := serviceaccountsecretpolicyv1.AddToScheme(mgr.GetScheme()); err != nil {
+		logrus.WithError(err).Fatal("Failed to add serviceaccountsecretpolicyv1 to scheme")
+	}
 	pprof.Serve(flagutil.DefaultPProfPort)
 
 	for cluster, buildClusterMgr := range allManagers {
@@ -537,8 +556,40 @@ func main() {
 	}
 
 	if opts.enabledControllersSet.Has(serviceaccountsecretrefresher.ControllerName) {
+		refresherOptions := serviceaccountsecretrefresher.Options{
+			EnabledNamespaces:     opts.serviceAccountSecretRefresherOptions.enabledNamespaces.StringSet(),
+			IgnoreServiceAccounts: opts.serviceAccountSecretRefresherOptions.ignoreServiceAccounts.StringSet(),
+			RemoveOldSecrets:      opts.serviceAccountSecretRefresherOptions.removeOldSecrets,
+		}
+		if opts.serviceAccountSecretRefresherOptions.enablePolicies {
+			refresherOptions.PolicyManager = mgr
+		}
+		if path := opts.serviceAccountSecretRefresherOptions.slackTokenPath; path != "" {
+			if err := secret.Add(path); err != nil {
+				logrus.WithError(err).Fatal("Failed to start secret agent for the Slack token")
+			}
+			refresherOptions.SlackClient = slack.New(string(secret.GetSecret(path)))
+		}
+		if opts.serviceAccountSecretRefresherOptions.reportAddress != "" {
+			path := opts.serviceAccountSecretRefresherOptions.reportCredentialsFile
+			if err := secret.Add(path); err != nil {
+				logrus.WithError(err).Fatal("Failed to start secret agent for the report credentials")
+			}
+			username, password, ok := strings.Cut(string(secret.GetSecret(path)), ":")
+			if !ok || username == "" || password == "" {
+				logrus.Fatal("The report credentials file must be of the form '<username>:<password>'")
+			}
+			passwordGetter := func() []byte {
+				_, password, _ := strings.Cut(string(secret.GetSecret(path)), ":")
+				return []byte(password)
+			}
+			refresherOptions.Reporter = serviceaccountsecretrefresher.NewReporter()
+			if err := serviceaccountsecretrefresher.ServeReport(mgr, opts.serviceAccountSecretRefresherOptions.reportAddress, refresherOptions.Reporter, username, passwordGetter); err != nil {
+				logrus.WithError(err).Fatal("Failed to serve the service account secret report")
+			}
+		}
 		for clusterName, clusterMgr := range allManagers {
-			if err := serviceaccountsecretrefresher.AddToManager(clusterName, clusterMgr, opts.serviceAccountSecretRefresherOptions.enabledNamespaces.StringSet(), opts.serviceAccountSecretRefresherOptions.ignoreServiceAccounts.StringSet(), opts.serviceAccountSecretRefresherOptions.removeOldSecrets); err != nil {
+			if err := serviceaccountsecretrefresher.AddToManager(clusterName, clusterMgr, refresherOptions); err != nil {
 				logrus.WithError(err).Fatalf("Failed to add the %s controller to the %s cluster", serviceaccountsecretrefresher.ControllerName, clusterName)
 			}
 		}
@@ -0,0 +1,189 @@
+//go:build linux
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/openshift/ci-tools/pkg/credentialusage"
+)
+
+// watchedDir is a directory under a credential mount
+type watchedDir struct {
+	mount string
+	// relative is the path of the directory relative to the mount
+	relative string
+}
+
+// credentialWatcher records the files opened under the credential mounts using inotify,
+// which does not need any privileges and sees the opens of all processes in the container
+type credentialWatcher struct {
+	// fd is kept apart from the file, as calling Fd() would make it blocking
+	fd     int
+	file   *os.File
+	mounts []string
+
+	lock sync.Mutex
+	dirs map[int32]watchedDir
+	read map[string]sets.Set[string]
+	done chan struct{}
+}
+
+func newCredentialWatcher(mounts []string) (*credentialWatcher, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize inotify: %w", err)
+	}
+	w := &credentialWatcher{
+		fd:     fd,
+		file:   os.NewFile(uintptr(fd), "inotify"),
+		mounts: mounts,
+		dirs:   map[int32]watchedDir{},
+		read:   map[string]sets.Set[string]{},
+		done:   make(chan struct{}),
+	}
+	for _, mount := range mounts {
+		w.read[mount] = sets.New[string]()
+		if err := w.watchTree(mount, mount); err != nil {
+			w.file.Close()
+			return nil, err
+		}
+	}
+	go w.run()
+	return w, nil
+}
+
+// watchTree watches the directory and all directories below it
+func (w *credentialWatcher) watchTree(mount, dir string) error {
+	return filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if !entry.IsDir() {
+			return nil
+		}
+		wd, err := syscall.InotifyAddWatch(w.fd, path, syscall.IN_OPEN|syscall.IN_CREATE|syscall.IN_ONLYDIR)
+		if err != nil {
+			return fmt.Errorf("failed to watch %s: %w", path, err)
+		}
+		relative, err := filepath.Rel(mount, path)
+		if err != nil {
+			return err
+		}
+		w.lock.Lock()
+		w.dirs[int32(wd)] = watchedDir{mount: mount, relative: relative}
+		w.lock.Unlock()
+		return nil
+	})
+}
+
+func newEventBuffer() []byte {
+	return make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+}
+
+func (w *credentialWatcher) run() {
+	defer close(w.done)
+	buf := newEventBuffer()
+	for {
+		n, err := w.file.Read(buf)
+		if err != nil {
+			if !errors.Is(err, os.ErrDeadlineExceeded) {
+				logrus.WithError(err).Warn("Failed to read credential access events")
+			}
+			return
+		}
+		w.handleEvents(buf[:n])
+	}
+}
+
+// drain handles the events that are still queued; the poller does not read
+// anymore once the deadline passed, so the descriptor is read directly
+func (w *credentialWatcher) drain() {
+	buf := newEventBuffer()
+	for {
+		n, err := syscall.Read(w.fd, buf)
+		if err != nil || n <= 0 {
+			return
+		}
+		w.handleEvents(buf[:n])
+	}
+}
+
+func (w *credentialWatcher) handleEvents(buf []byte) {
+	for offset := 0; offset+syscall.SizeofInotifyEvent <= len(buf); {
+		event := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+		nameStart := offset + syscall.SizeofInotifyEvent
+		name := string(bytes.TrimRight(buf[nameStart:nameStart+int(event.Len)], "\x00"))
+		offset = nameStart + int(event.Len)
+		w.handle(event.Wd, event.Mask, name)
+	}
+}
+
+func (w *credentialWatcher) handle(wd int32, mask uint32, name string) {
+	w.lock.Lock()
+	dir, ok := w.dirs[wd]
+	w.lock.Unlock()
+	if !ok || name == "" {
+		return
+	}
+	if mask&syscall.IN_ISDIR != 0 {
+		if mask&syscall.IN_CREATE != 0 {
+			if err := w.watchTree(dir.mount, filepath.Join(dir.mount, dir.relative, name)); err != nil {
+				logrus.WithError(err).Warn("Failed to watch new credential directory")
+			}
+		}
+		return
+	}
+	if mask&syscall.IN_OPEN == 0 || strings.HasPrefix(name, "..") {
+		return
+	}
+	// secret volumes keep the files in timestamped directories like ..2024_01_01_00_00_00.000000000
+	// and link to them from the mount, so these directories are the mount for all purposes
+	relative := name
+	if dir.relative != "." && !strings.HasPrefix(dir.relative, "..") {
+		relative = filepath.Join(dir.relative, name)
+	}
+	w.lock.Lock()
+	w.read[dir.mount].Insert(relative)
+	w.lock.Unlock()
+}
+
+// stop processes the pending events and returns the usage of every mount
+func (w *credentialWatcher) stop() []credentialusage.MountUsage {
+	if err := w.file.SetReadDeadline(time.Now()); err != nil {
+		logrus.WithError(err).Warn("Failed to stop watching credentials")
+	}
+	<-w.done
+	w.drain()
+	if err := w.file.Close(); err != nil {
+		logrus.WithError(err).Warn("Failed to close inotify")
+	}
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	var usage []credentialusage.MountUsage
+	for _, mount := range w.mounts {
+		mountUsage := credentialusage.MountUsage{MountPath: mount}
+		if w.read[mount].Len() > 0 {
+			mountUsage.Read = sets.List(w.read[mount])
+		}
+		usage = append(usage, mountUsage)
+	}
+	return usage
+}
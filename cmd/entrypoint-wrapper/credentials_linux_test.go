@@ -0,0 +1,59 @@
+//go:build linux
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/openshift/ci-tools/pkg/credentialusage"
+)
+
+func TestCredentialWatcher(t *testing.T) {
+	root := t.TempDir()
+	// lay out the mounts like secret volumes, with the files in a timestamped directory
+	secretMount := filepath.Join(root, "secret")
+	unusedMount := filepath.Join(root, "unused")
+	for _, mount := range []string{secretMount, unusedMount} {
+		data := filepath.Join(mount, "..2024_01_01_00_00_00.000000000")
+		if err := os.MkdirAll(data, 0755); err != nil {
+			t.Fatal(err)
+		}
+		for _, file := range []string{"token", "password"} {
+			if err := os.WriteFile(filepath.Join(data, file), []byte(file), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := os.Symlink(filepath.Base(data), filepath.Join(mount, "..data")); err != nil {
+			t.Fatal(err)
+		}
+		for _, file := range []string{"token", "password"} {
+			if err := os.Symlink(filepath.Join("..data", file), filepath.Join(mount, file)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	watcher, err := newCredentialWatcher([]string{secretMount, unusedMount})
+	if err != nil {
+		t.Fatalf("failed to start watcher: %v", err)
+	}
+	if _, err := os.ReadFile(filepath.Join(secretMount, "token")); err != nil {
+		t.Fatal(err)
+	}
+	// listing the mount is not reading a credential
+	if _, err := os.ReadDir(unusedMount); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []credentialusage.MountUsage{
+		{MountPath: secretMount, Read: []string{"token"}},
+		{MountPath: unusedMount},
+	}
+	if diff := cmp.Diff(expected, watcher.stop()); diff != "" {
+		t.Errorf("unexpected usage: %s", diff)
+	}
+}
@@ -0,0 +1,19 @@
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+
+	"github.com/openshift/ci-tools/pkg/credentialusage"
+)
+
+type credentialWatcher struct{}
+
+func newCredentialWatcher(_ []string) (*credentialWatcher, error) {
+	return nil, errors.New("watching credentials is only supported on Linux")
+}
+
+func (w *credentialWatcher) stop() []credentialusage.MountUsage {
+	return nil
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
@@ -29,8 +30,10 @@ import (
 	"k8s.io/apimachinery/pkg/util/wait"
 	coreclientset "k8s.io/client-go/kubernetes/typed/core/v1"
 	"k8s.io/client-go/rest"
+	"sigs.k8s.io/prow/pkg/flagutil"
 
 	"github.com/openshift/ci-tools/pkg/api"
+	"github.com/openshift/ci-tools/pkg/credentialusage"
 	"github.com/openshift/ci-tools/pkg/util"
 )
 
@@ -82,6 +85,8 @@ type options struct {
 	rwKubeconfig     bool
 	uploadKubeconfig bool
 	updateSharedDir  bool
+	credentialMounts flagutil.Strings
+	stepName         string
 	cmd              []string
 	client           coreclientset.SecretInterface
 }
@@ -92,6 +97,8 @@ func bindOptions(flag *flag.FlagSet) *options {
 	flag.StringVar(&opt.waitPath, "wait-for-file", "", "Wait for a file to appear at this path before starting the program")
 	flag.StringVar(&opt.waitTimeoutStr, "wait-timeout", "", "Used with --wait-for-file, maximum wait time before starting the program")
 	flag.StringVar(&opt.mode, "mode", manageKubeconfigMode, fmt.Sprintf("Set how kubeconfig should be managed. Allowed values are: %s, %s or %s", manageKubeconfigMode, skipKubeconfigMode, observerMode))
+	flag.Var(&opt.credentialMounts, "credential-mount", "Path a credential is mounted at. The files opened under it are recorded in $ARTIFACT_DIR/"+credentialusage.EvidenceFile+". Can be passed multiple times.")
+	flag.StringVar(&opt.stepName, "step-name", "", "Name of the step the credential usage is recorded for")
 	return opt
 }
 
@@ -169,9 +176,26 @@ func (o *options) run() (exitCode int, err error) {
 	if o.uploadKubeconfig {
 		go uploadKubeconfig(ctx, o.client, o.name, o.dstPath, o.dry)
 	}
+	var watcher *credentialWatcher
+	if mounts := o.credentialMounts.Strings(); len(mounts) > 0 {
+		// recording the usage is best-effort and must never fail the step
+		if watcher, err = newCredentialWatcher(mounts); err != nil {
+			logrus.WithError(err).Warn("Failed to watch credential mounts, their usage will not be recorded")
+		}
+	}
 	if exitCode, err = o.execCmd(); err != nil {
 		errs = append(errs, fmt.Errorf("failed to execute wrapped command: %w", err))
 	}
+	if watcher != nil {
+		if err := writeCredentialUsage(os.Getenv("ARTIFACT_DIR"), credentialusage.Evidence{
+			Job:     os.Getenv("JOB_NAME"),
+			BuildID: os.Getenv("BUILD_ID"),
+			Step:    o.stepName,
+			Mounts:  watcher.stop(),
+		}); err != nil {
+			logrus.WithError(err).Warn("Failed to record credential usage")
+		}
+	}
 	// we will upload the secret from the post-execution state, so we know
 	// that the best-effort upload of the kubeconfig can exit now and so as
 	// not to race with the post-execution one
@@ -185,6 +209,20 @@ func (o *options) run() (exitCode int, err error) {
 	return exitCode, utilerrors.NewAggregate(errs)
 }
 
+func writeCredentialUsage(dir string, evidence credentialusage.Evidence) error {
+	if dir == "" {
+		return errors.New("environment variable ARTIFACT_DIR is empty")
+	}
+	raw, err := json.Marshal(evidence)
+	if err != nil {
+		return fmt.Errorf("failed to serialize credential usage: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create artifact directory: %w", err)
+	}
+	return os.WriteFile(filepath.Join(dir, credentialusage.EvidenceFile), raw, 0644)
+}
+
 func loadClient(namespace string) (coreclientset.SecretInterface, error) {
 	config, err := rest.InClusterConfig()
 	if err != nil {
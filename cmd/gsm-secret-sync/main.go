@@ -5,6 +5,7 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"time"
 
 	iamadmin "cloud.google.com/go/iam/admin/apiv1"
 	resourcemanager "cloud.google.com/go/resourcemanager/apiv3"
@@ -23,6 +24,8 @@ type options struct {
 	dryRun                   bool
 	logLevel                 string
 	gcpServiceAccountKeyFile string
+	planFile                 string
+	applyPlanFile            string
 }
 
 func parseOptions() options {
@@ -32,6 +35,8 @@ func parseOptions() options {
 	fs.StringVar(&o.logLevel, "log-level", "info", "log level")
 	fs.BoolVar(&o.dryRun, "dry-run", false, "dry run mode")
 	fs.StringVar(&o.gcpServiceAccountKeyFile, "gcp-service-account-key-file", "", "path to GCP service account key file (JSON format)")
+	fs.StringVar(&o.planFile, "plan", "", "compute the changes and write them to this plan file (JSON with a .json extension, YAML otherwise) instead of applying them")
+	fs.StringVar(&o.applyPlanFile, "apply-plan", "", "apply the changes from this plan file, refusing if the live state drifted since the plan was made")
 	if err := fs.Parse(os.Args[1:]); err != nil {
 		logrus.WithError(err).Fatal("could not parse args")
 	}
@@ -39,7 +44,13 @@ func parseOptions() options {
 }
 
 func (o *options) Validate() error {
-	if o.configFile == "" {
+	if o.planFile != "" && o.applyPlanFile != "" {
+		return fmt.Errorf("--plan and --apply-plan are mutually exclusive")
+	}
+	if o.applyPlanFile != "" && o.dryRun {
+		return fmt.Errorf("--dry-run can not be used with --apply-plan")
+	}
+	if o.configFile == "" && o.applyPlanFile == "" {
 		return fmt.Errorf("--config is required")
 	}
 	if o.gcpServiceAccountKeyFile == "" {
@@ -82,11 +93,6 @@ func main() {
 
 	logrus.Info("Starting reconciliation")
 
-	desiredSAs, desiredSecrets, desiredIAMBindings, desiredCollections, err := gsm.GetDesiredState(o.configFile, config)
-	if err != nil {
-		logrus.WithError(err).Fatal("Failed to parse configuration file")
-	}
-
 	ctx := context.Background()
 
 	gcpCreds := []byte(gcpCredentials)
@@ -125,8 +131,39 @@ func main() {
 		logrus.WithError(err).Fatal("Failed to get current secrets")
 	}
 
+	if o.applyPlanFile != "" {
+		plan, err := gsm.LoadPlan(o.applyPlanFile)
+		if err != nil {
+			logrus.WithError(err).Fatal("Failed to load plan")
+		}
+		if err := plan.VerifyLiveState(config, actualSAs, actualSecrets, policy); err != nil {
+			logrus.WithError(err).Fatal("Refusing to apply the plan, create a new one")
+		}
+		actions := plan.Actions(policy)
+		logChangeSummary(actions)
+		actions.ExecuteActions(ctx, iamClient, secretsClient, projectsClient)
+		logrus.Info("Plan applied successfully")
+		return
+	}
+
+	desiredSAs, desiredSecrets, desiredIAMBindings, desiredCollections, err := gsm.GetDesiredState(o.configFile, config)
+	if err != nil {
+		logrus.WithError(err).Fatal("Failed to parse configuration file")
+	}
 	actions := gsm.ComputeDiff(config, desiredSAs, actualSAs, desiredSecrets, actualSecrets, desiredIAMBindings, policy, desiredCollections)
 
+	if o.planFile != "" {
+		plan := gsm.NewPlan(actions, actualSAs, actualSecrets, policy, time.Now())
+		if err := plan.Render(os.Stdout); err != nil {
+			logrus.WithError(err).Fatal("Failed to render plan")
+		}
+		if err := gsm.WritePlan(o.planFile, plan); err != nil {
+			logrus.WithError(err).Fatal("Failed to write plan")
+		}
+		logrus.Infof("Plan written to %s, apply it with --apply-plan", o.planFile)
+		return
+	}
+
 	logChangeSummary(actions)
 	if !o.dryRun {
 		actions.ExecuteActions(ctx, iamClient, secretsClient, projectsClient)
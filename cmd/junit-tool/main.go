@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"sigs.k8s.io/prow/pkg/logrusutil"
+
+	"github.com/openshift/ci-tools/pkg/junit"
+)
+
+const (
+	formatAuto      = "auto"
+	formatXML       = "xml"
+	formatJSON      = "json"
+	formatTest2JSON = "test2json"
+)
+
+type options struct {
+	inputFormat  string
+	outputFormat string
+	output       string
+	normalize    bool
+}
+
+func (o *options) validate() error {
+	if o.inputFormat != formatAuto && o.inputFormat != formatXML && o.inputFormat != formatJSON && o.inputFormat != formatTest2JSON {
+		return fmt.Errorf("--input-format must be one of %s, %s, %s or %s", formatAuto, formatXML, formatJSON, formatTest2JSON)
+	}
+	if o.outputFormat != formatXML && o.outputFormat != formatJSON && o.outputFormat != formatTest2JSON {
+		return fmt.Errorf("--output-format must be one of %s, %s or %s", formatXML, formatJSON, formatTest2JSON)
+	}
+	return nil
+}
+
+func main() {
+	logrusutil.ComponentInit()
+	if err := newCommand().Execute(); err != nil {
+		logrus.WithError(err).Fatal("Failed.")
+	}
+}
+
+func newCommand() *cobra.Command {
+	o := &options{}
+	root := &cobra.Command{
+		Use:           "junit-tool",
+		Short:         "Merge, diff and convert jUnit test suites",
+		Long:          "Merges the jUnit test suites of many runs, compares two sets of suites and converts suites between jUnit XML, JSON and the go test -json format.",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		PersistentPreRunE: func(*cobra.Command, []string) error {
+			return o.validate()
+		},
+	}
+	flags := root.PersistentFlags()
+	flags.StringVar(&o.inputFormat, "input-format", formatAuto, fmt.Sprintf("Format of the inputs, one of %s, %s, %s or %s. %s detects the format of every input from its content.", formatAuto, formatXML, formatJSON, formatTest2JSON, formatAuto))
+	flags.StringVar(&o.outputFormat, "output-format", formatXML, fmt.Sprintf("Format of the output, one of %s, %s or %s.", formatXML, formatJSON, formatTest2JSON))
+	flags.StringVar(&o.output, "output", "", "If set, write the output to this file instead of the standard output.")
+	flags.BoolVar(&o.normalize, "normalize", false, "Normalize test names by removing durations, namespaces and random suffixes.")
+
+	root.AddCommand(&cobra.Command{
+		Use:   "merge FILE...",
+		Short: "Merge the suites of many files into one",
+		Long:  "Merges the suites of many files, suites with the same name are merged into one holding the test cases of all of them. Use - to read the standard input.",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			suites, err := o.readAll(args)
+			if err != nil {
+				return err
+			}
+			return o.write(suites)
+		},
+	})
+	root.AddCommand(&cobra.Command{
+		Use:   "convert FILE",
+		Short: "Convert the suites of a file to another format",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			suites, err := o.readAll(args)
+			if err != nil {
+				return err
+			}
+			return o.write(suites)
+		},
+	})
+
+	var before, after []string
+	var failOnNewFailures bool
+	diff := &cobra.Command{
+		Use:   "diff --before FILE... --after FILE...",
+		Short: "Compare two sets of suites",
+		Long:  "Merges the suites of each side and writes the new failures, fixed tests, new tests and removed tests as JSON.",
+		Args:  cobra.NoArgs,
+		RunE: func(*cobra.Command, []string) error {
+			if len(before) == 0 || len(after) == 0 {
+				return errors.New("--before and --after are required")
+			}
+			beforeSuites, err := o.readAll(before)
+			if err != nil {
+				return err
+			}
+			afterSuites, err := o.readAll(after)
+			if err != nil {
+				return err
+			}
+			suitesDiff := junit.Diff(beforeSuites, afterSuites)
+			raw, err := json.MarshalIndent(suitesDiff, "", "  ")
+			if err != nil {
+				return fmt.Errorf("could not marshal the diff: %w", err)
+			}
+			if err := o.writeRaw(append(raw, '\n')); err != nil {
+				return err
+			}
+			if failOnNewFailures && len(suitesDiff.NewFailures) > 0 {
+				return fmt.Errorf("%d tests newly fail", len(suitesDiff.NewFailures))
+			}
+			return nil
+		},
+	}
+	diff.Flags().StringSliceVar(&before, "before", nil, "Files holding the suites to compare against, can be passed multiple times.")
+	diff.Flags().StringSliceVar(&after, "after", nil, "Files holding the suites to compare, can be passed multiple times.")
+	diff.Flags().BoolVar(&failOnNewFailures, "fail-on-new-failures", false, "Exit with an error when tests newly fail.")
+	root.AddCommand(diff)
+	return root
+}
+
+// readAll reads and merges the suites of all files
+func (o *options) readAll(paths []string) (*junit.TestSuites, error) {
+	var all []*junit.TestSuites
+	for _, path := range paths {
+		var raw []byte
+		var err error
+		if path == "-" {
+			raw, err = io.ReadAll(os.Stdin)
+		} else {
+			raw, err = os.ReadFile(path)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not read %s: %w", path, err)
+		}
+		suites, err := decode(raw, o.inputFormat)
+		if err != nil {
+			return nil, fmt.Errorf("could not read suites from %s: %w", path, err)
+		}
+		all = append(all, suites)
+	}
+	merged := junit.Merge(all...)
+	if o.normalize {
+		junit.Normalize(merged)
+	}
+	return merged, nil
+}
+
+// detectFormat tells XML from JSON by the first character, and the events of go test -json
+// from JSON suites by their action
+func detectFormat(raw []byte) string {
+	trimmed := bytes.TrimSpace(raw)
+	if !bytes.HasPrefix(trimmed, []byte("{")) {
+		return formatXML
+	}
+	var first map[string]json.RawMessage
+	if err := json.NewDecoder(bytes.NewReader(trimmed)).Decode(&first); err == nil {
+		if _, isEvent := first["Action"]; isEvent {
+			return formatTest2JSON
+		}
+	}
+	return formatJSON
+}
+
+func decode(raw []byte, format string) (*junit.TestSuites, error) {
+	if format == formatAuto {
+		format = detectFormat(raw)
+	}
+	switch format {
+	case formatJSON:
+		return junit.ParseJSON(raw)
+	case formatTest2JSON:
+		return junit.ParseTest2JSON(bytes.NewReader(raw))
+	default:
+		return junit.Parse(raw)
+	}
+}
+
+func encode(suites *junit.TestSuites, format string) ([]byte, error) {
+	switch format {
+	case formatJSON:
+		buf := &bytes.Buffer{}
+		err := junit.WriteJSON(buf, suites)
+		return buf.Bytes(), err
+	case formatTest2JSON:
+		buf := &bytes.Buffer{}
+		err := junit.WriteTest2JSON(buf, suites)
+		return buf.Bytes(), err
+	default:
+		raw, err := xml.MarshalIndent(suites, "", "  ")
+		return append([]byte(xml.Header), append(raw, '\n')...), err
+	}
+}
+
+func (o *options) write(suites *junit.TestSuites) error {
+	raw, err := encode(suites, o.outputFormat)
+	if err != nil {
+		return fmt.Errorf("could not write the suites as %s: %w", o.outputFormat, err)
+	}
+	return o.writeRaw(raw)
+}
+
+func (o *options) writeRaw(raw []byte) error {
+	if o.output == "" {
+		_, err := os.Stdout.Write(raw)
+		return err
+	}
+	if err := os.WriteFile(o.output, raw, 0644); err != nil {
+		return fmt.Errorf("could not write %s: %w", o.output, err)
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/openshift/ci-tools/pkg/junit"
+)
+
+func TestDetectFormat(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		raw      string
+		expected string
+	}{
+		{name: "xml", raw: `  <?xml version="1.0"?><testsuites></testsuites>`, expected: formatXML},
+		{name: "json", raw: `{"suites":[{"name":"e2e"}]}`, expected: formatJSON},
+		{name: "test2json", raw: "{\"Action\":\"start\",\"Package\":\"pkg\"}\n{\"Action\":\"pass\",\"Package\":\"pkg\"}\n", expected: formatTest2JSON},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if actual := detectFormat([]byte(tc.raw)); actual != tc.expected {
+				t.Errorf("expected %s, got %s", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestEncodeDecode(t *testing.T) {
+	suites := &junit.TestSuites{Suites: []*junit.TestSuite{{
+		Name:       "e2e",
+		NumTests:   2,
+		NumFailed:  1,
+		Properties: []*junit.Property{{Name: "version", Value: "4.16"}},
+		TestCases: []*junit.TestCase{
+			{Name: "passes", Duration: 1},
+			{Name: "fails", Duration: 2, FailureOutput: &junit.FailureOutput{Message: "failed", Output: "broken"}},
+		},
+	}}}
+	for _, format := range []string{formatXML, formatJSON, formatTest2JSON} {
+		t.Run(format, func(t *testing.T) {
+			raw, err := encode(suites, format)
+			if err != nil {
+				t.Fatalf("failed to encode: %v", err)
+			}
+			decoded, err := decode(raw, formatAuto)
+			if err != nil {
+				t.Fatalf("failed to decode: %v", err)
+			}
+			if diff := cmp.Diff(junit.Results(suites), junit.Results(decoded)); diff != "" {
+				t.Errorf("unexpected results after a round trip (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"sigs.k8s.io/prow/pkg/config/secret"
+	"sigs.k8s.io/prow/pkg/interrupts"
+	"sigs.k8s.io/prow/pkg/logrusutil"
+	"sigs.k8s.io/prow/pkg/pjutil"
+
+	"github.com/openshift/ci-tools/pkg/lease/broker"
+)
+
+type options struct {
+	address                    string
+	gracePeriod                time.Duration
+	leaseServer                string
+	leaseServerCredentialsFile string
+	agingInterval              time.Duration
+	abandonAfter               time.Duration
+	scheduleInterval           time.Duration
+}
+
+func gatherOptions() (options, error) {
+	o := options{}
+	fs := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	fs.StringVar(&o.address, "address", ":8080", "Address to run server on")
+	fs.DurationVar(&o.gracePeriod, "grace-period", 10*time.Second, "Grace period for server shutdown")
+	fs.StringVar(&o.leaseServer, "lease-server", "", "Address of the Boskos server the broker acquires leases from.")
+	fs.StringVar(&o.leaseServerCredentialsFile, "lease-server-credentials-file", "", "The path to credentials file used to access the lease server. The content is of the form <username>:<password>. Clients of the broker must authenticate with the same credentials.")
+	fs.DurationVar(&o.agingInterval, "aging-interval", 15*time.Minute, "Time after which a waiting request is served as if it had the next higher priority class.")
+	fs.DurationVar(&o.abandonAfter, "abandon-after", 2*time.Minute, "Time after which a request whose client stopped polling is dropped and its resources released.")
+	fs.DurationVar(&o.scheduleInterval, "schedule-interval", 5*time.Second, "How often the waiting requests are scheduled.")
+	if err := fs.Parse(os.Args[1:]); err != nil {
+		return o, fmt.Errorf("failed to parse flags: %w", err)
+	}
+	return o, nil
+}
+
+func (o *options) validate() error {
+	if o.leaseServer == "" {
+		return errors.New("--lease-server is required")
+	}
+	if o.leaseServerCredentialsFile == "" {
+		return errors.New("--lease-server-credentials-file is required")
+	}
+	if o.agingInterval <= 0 || o.abandonAfter <= 0 || o.scheduleInterval <= 0 {
+		return errors.New("--aging-interval, --abandon-after and --schedule-interval must be positive")
+	}
+	return nil
+}
+
+func loadCredentials(path string) (string, func() []byte, error) {
+	if err := secret.Add(path); err != nil {
+		return "", nil, fmt.Errorf("failed to start secret agent on file %s: %w", path, err)
+	}
+	splits := strings.Split(string(secret.GetSecret(path)), ":")
+	if len(splits) != 2 {
+		return "", nil, errors.New("got invalid content of lease server credentials file which must be of the form '<username>:<password>'")
+	}
+	return splits[0], func() []byte { return []byte(splits[1]) }, nil
+}
+
+func loginHandler(username string, passwordGetter func() []byte, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		if !ok || user != username || subtle.ConstantTimeCompare([]byte(pass), passwordGetter()) != 1 {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func main() {
+	logrusutil.ComponentInit()
+	o, err := gatherOptions()
+	if err != nil {
+		logrus.WithError(err).Fatal("failed to gather options")
+	}
+	if err := o.validate(); err != nil {
+		logrus.WithError(err).Fatal("invalid options")
+	}
+
+	username, passwordGetter, err := loadCredentials(o.leaseServerCredentialsFile)
+	if err != nil {
+		logrus.WithError(err).Fatal("failed to load lease server credentials")
+	}
+
+	logger := logrus.WithField("component", "lease-broker")
+	b := broker.New(logger, broker.NewBoskosAcquirer(o.leaseServer, username, passwordGetter), o.agingInterval, o.abandonAfter)
+	ctx, cancel := context.WithCancel(context.Background())
+	interrupts.OnInterrupt(cancel)
+	go b.Run(ctx, o.scheduleInterval)
+
+	mux := http.NewServeMux()
+	b.RegisterHandlers(mux)
+	health := pjutil.NewHealth()
+	interrupts.ListenAndServe(&http.Server{Addr: o.address, Handler: loginHandler(username, passwordGetter, mux)}, o.gracePeriod)
+	health.ServeReady()
+	interrupts.WaitForGracefulShutdown()
+}
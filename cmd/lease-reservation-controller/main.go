@@ -0,0 +1,120 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"sigs.k8s.io/prow/pkg/config/secret"
+	"sigs.k8s.io/prow/pkg/interrupts"
+	"sigs.k8s.io/prow/pkg/logrusutil"
+
+	"github.com/openshift/ci-tools/pkg/lease"
+	"github.com/openshift/ci-tools/pkg/lease/reservation"
+)
+
+type options struct {
+	configPath                 string
+	leaseServer                string
+	leaseServerCredentialsFile string
+	resyncInterval             time.Duration
+}
+
+func gatherOptions() (options, error) {
+	o := options{}
+	fs := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	fs.StringVar(&o.configPath, "config", "", "Path to the file declaring the reservations. The file is read again on every resync.")
+	fs.StringVar(&o.leaseServer, "lease-server", "", "Address of the Boskos server holding the reserved resources.")
+	fs.StringVar(&o.leaseServerCredentialsFile, "lease-server-credentials-file", "", "The path to credentials file used to access the lease server. The content is of the form <username>:<password>.")
+	fs.DurationVar(&o.resyncInterval, "resync-interval", 30*time.Second, "How often the reservations are reconciled. Must be shorter than the expiry of leases in the lease server.")
+	if err := fs.Parse(os.Args[1:]); err != nil {
+		return o, fmt.Errorf("failed to parse flags: %w", err)
+	}
+	return o, nil
+}
+
+func (o *options) validate() error {
+	if o.configPath == "" {
+		return errors.New("--config is required")
+	}
+	if o.leaseServer == "" {
+		return errors.New("--lease-server is required")
+	}
+	if o.leaseServerCredentialsFile == "" {
+		return errors.New("--lease-server-credentials-file is required")
+	}
+	if o.resyncInterval <= 0 {
+		return errors.New("--resync-interval must be positive")
+	}
+	return nil
+}
+
+func loadCredentials(path string) (string, func() []byte, error) {
+	if err := secret.Add(path); err != nil {
+		return "", nil, fmt.Errorf("failed to start secret agent on file %s: %w", path, err)
+	}
+	splits := strings.Split(string(secret.GetSecret(path)), ":")
+	if len(splits) != 2 {
+		return "", nil, errors.New("got invalid content of lease server credentials file which must be of the form '<username>:<password>'")
+	}
+	return splits[0], func() []byte { return []byte(splits[1]) }, nil
+}
+
+func main() {
+	logrusutil.ComponentInit()
+	o, err := gatherOptions()
+	if err != nil {
+		logrus.WithError(err).Fatal("failed to gather options")
+	}
+	if err := o.validate(); err != nil {
+		logrus.WithError(err).Fatal("invalid options")
+	}
+	if _, err := reservation.LoadConfig(o.configPath); err != nil {
+		logrus.WithError(err).Fatal("failed to load reservations")
+	}
+
+	username, passwordGetter, err := loadCredentials(o.leaseServerCredentialsFile)
+	if err != nil {
+		logrus.WithError(err).Fatal("failed to load lease server credentials")
+	}
+	// resources are only acquired when immediately available, so no acquisition timeout is needed
+	reserve, err := lease.NewClient(lease.ReservationOwner, o.leaseServer, username, passwordGetter, 3, 0)
+	if err != nil {
+		logrus.WithError(err).Fatal("failed to create the lease client")
+	}
+	drain, err := lease.NewClient(lease.DrainOwner, o.leaseServer, username, passwordGetter, 3, 0)
+	if err != nil {
+		logrus.WithError(err).Fatal("failed to create the lease client")
+	}
+
+	logger := logrus.WithField("component", "lease-reservation-controller")
+	controller := reservation.NewController(logger, reserve, drain)
+	// held leases are not released on shutdown: the reservations stay in place until the
+	// leases expire, which a restart of the controller is expected to beat
+	interrupts.TickLiteral(func() {
+		config, err := reservation.LoadConfig(o.configPath)
+		if err != nil {
+			logger.WithError(err).Error("Failed to load reservations, keeping the held leases")
+			if err := reserve.Heartbeat(); err != nil {
+				logger.WithError(err).Error("Failed to update held leases")
+			}
+			if err := drain.Heartbeat(); err != nil {
+				logger.WithError(err).Error("Failed to update held leases")
+			}
+			return
+		}
+		statuses, err := controller.Reconcile(config)
+		if err != nil {
+			logger.WithError(err).Error("Failed to reconcile reservations")
+		}
+		for _, status := range statuses {
+			logger.WithFields(logrus.Fields{"reservation": status.Name, "type": status.ResourceType, "active": status.Active, "held": len(status.Held)}).Debug("Reconciled reservation")
+		}
+	}, o.resyncInterval)
+	interrupts.WaitForGracefulShutdown()
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"sigs.k8s.io/boskos/common"
+	"sigs.k8s.io/prow/pkg/interrupts"
+	"sigs.k8s.io/prow/pkg/logrusutil"
+	"sigs.k8s.io/prow/pkg/pjutil"
+
+	"github.com/openshift/ci-tools/pkg/lease/server"
+)
+
+type options struct {
+	port           int
+	healthPort     int
+	gracePeriod    time.Duration
+	configPath     string
+	expiry         time.Duration
+	expiryInterval time.Duration
+}
+
+func gatherOptions() (options, error) {
+	o := options{}
+	fs := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	fs.IntVar(&o.port, "port", 8080, "Port to serve the Boskos protocol on")
+	fs.IntVar(&o.healthPort, "health-port", 8081, "Port to serve health checks on")
+	fs.DurationVar(&o.gracePeriod, "grace-period", 10*time.Second, "Grace period for server shutdown")
+	fs.StringVar(&o.configPath, "config", "", "Path to the Boskos configuration declaring the resources. Entries without names are dynamic resources created up to their max-count.")
+	fs.DurationVar(&o.expiry, "expiry", 5*time.Minute, "Time after which leases without a heartbeat are freed.")
+	fs.DurationVar(&o.expiryInterval, "expiry-interval", 10*time.Second, "How often leases are checked for expiry.")
+	if err := fs.Parse(os.Args[1:]); err != nil {
+		return o, fmt.Errorf("failed to parse flags: %w", err)
+	}
+	return o, nil
+}
+
+func (o *options) validate() error {
+	if o.configPath == "" {
+		return errors.New("--config is required")
+	}
+	if o.expiry <= 0 || o.expiryInterval <= 0 {
+		return errors.New("--expiry and --expiry-interval must be positive")
+	}
+	return nil
+}
+
+func main() {
+	logrusutil.ComponentInit()
+	o, err := gatherOptions()
+	if err != nil {
+		logrus.WithError(err).Fatal("failed to gather options")
+	}
+	if err := o.validate(); err != nil {
+		logrus.WithError(err).Fatal("invalid options")
+	}
+	config, err := common.ParseConfig(o.configPath)
+	if err != nil {
+		logrus.WithError(err).Fatal("failed to load the resources")
+	}
+	s, err := server.New(logrus.WithField("component", "local-lease-server"), config, o.expiry)
+	if err != nil {
+		logrus.WithError(err).Fatal("failed to create the lease server")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	interrupts.OnInterrupt(cancel)
+	go s.Run(ctx, o.expiryInterval)
+
+	mux := http.NewServeMux()
+	mux.Handle("/", s.Handler())
+	mux.Handle("/admin/", s.AdminHandler())
+	health := pjutil.NewHealthOnPort(o.healthPort)
+	logrus.Infof("Serving the Boskos protocol on port %d, failures can be injected under /admin/", o.port)
+	interrupts.ListenAndServe(&http.Server{Addr: ":" + strconv.Itoa(o.port), Handler: mux}, o.gracePeriod)
+	health.ServeReady()
+	interrupts.WaitForGracefulShutdown()
+}
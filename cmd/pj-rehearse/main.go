@@ -179,6 +179,7 @@ type options struct {
 	gcsBucket          string
 	gcsCredentialsFile string
 	gcsBrowserPrefix   string
+	productionRuns     int
 
 	dryRun        bool
 	dryRunOptions dryRunOptions
@@ -220,6 +221,7 @@ func gatherOptions() (options, error) {
 	fs.StringVar(&o.gcsBucket, "gcs-bucket", "test-platform-results", "GCS Bucket to upload affected jobs list")
 	fs.StringVar(&o.gcsCredentialsFile, "gcs-credentials-file", "/etc/gcs/service-account.json", "GCS Credentials file to upload affected jobs list")
 	fs.StringVar(&o.gcsBrowserPrefix, "gcs-browser-prefix", "https://gcsweb-ci.apps.ci.l2s4.p1.openshiftapps.com/gcs/test-platform-results/", "Prefix for the GCS Browser for viewing the affected jobs list")
+	fs.IntVar(&o.productionRuns, "production-runs", 0, "Number of the most recent production runs of the rehearsed jobs in the GCS Bucket to compare finished rehearsals with. If set, the comparison is commented on the PR once the rehearsals finish.")
 
 	o.github.AddFlags(fs)
 	o.githubEventServerOptions.Bind(fs)
@@ -250,6 +252,9 @@ func (o *options) validate() error {
 	if o.handlerTimeoutMinutes < 1 {
 		errs = append(errs, errors.New("handler-timeout-minutes must be greater than zero"))
 	}
+	if o.productionRuns < 0 {
+		errs = append(errs, errors.New("production-runs must not be negative"))
+	}
 
 	if o.dryRun {
 		errs = append(errs, o.dryRunOptions.validate())
@@ -313,6 +318,7 @@ func rehearsalConfigFromOptions(o options) rehearse.RehearsalConfig {
 		GCSBucket:          o.gcsBucket,
 		GCSCredentialsFile: o.gcsCredentialsFile,
 		GCSBrowserPrefix:   o.gcsBrowserPrefix,
+		ProductionRuns:     o.productionRuns,
 	}
 }
 
@@ -349,7 +355,7 @@ func dryRun(o options, logger *logrus.Entry) error {
 			return fmt.Errorf("%s: %w", "ERROR: pj-rehearse: failed to validate rehearsal jobs", err)
 		}
 
-		_, err := rc.RehearseJobs(candidatePath, prRefs, presubmitsToRehearse, prConfig.Prow, true, logger)
+		_, _, err := rc.RehearseJobs(candidatePath, prRefs, presubmitsToRehearse, prConfig.Prow, true, logger)
 		return err
 	}
 
@@ -385,7 +391,7 @@ func main() {
 		}
 		webhookTokenGenerator := secret.GetTokenGenerator(o.webhookSecretFile)
 
-		s, err := serverFromOptions(o)
+		s, err := serverFromOptions(interrupts.Context(), o)
 		if err != nil {
 			logger.WithError(err).Fatal("couldn't create server")
 		}
@@ -420,6 +426,8 @@ func main() {
 
 		interrupts.OnInterrupt(func() {
 			eventServer.GracefulShutdown()
+			// the comparisons with production stop once the server context is done, wait for them to return
+			s.comparisons.Wait()
 		})
 
 		health := pjutil.NewHealthOnPort(o.instrumentationOptions.HealthPort)
@@ -8,12 +8,15 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+	"sync"
 
 	"github.com/sirupsen/logrus"
 
+	"k8s.io/apimachinery/pkg/util/sets"
 	prowconfig "sigs.k8s.io/prow/pkg/config"
 	"sigs.k8s.io/prow/pkg/git/v2"
 	"sigs.k8s.io/prow/pkg/github"
+	prowio "sigs.k8s.io/prow/pkg/io"
 	"sigs.k8s.io/prow/pkg/labels"
 	"sigs.k8s.io/prow/pkg/pluginhelp"
 	"sigs.k8s.io/prow/pkg/pod-utils/gcs"
@@ -38,6 +41,14 @@ const (
 	rehearseAllowNetworkAccess = "/pj-rehearse network-access-allowed"
 )
 
+const (
+	// maxListedFailures limits the failures of a rehearsal listed in a comment, test names can be long
+	maxListedFailures = 3
+	// maxPendingComparisons limits the rehearsals waited for to compare them with production, every
+	// comparison lists the ProwJobs of its PR until its rehearsals finish
+	maxPendingComparisons = 20
+)
+
 var commentRegex = regexp.MustCompile(`(?m)^/pj-rehearse\f*.*$`)
 
 type githubClient interface {
@@ -56,6 +67,13 @@ type server struct {
 	gc  git.ClientFactory
 
 	rehearsalConfig rehearse.RehearsalConfig
+
+	// ctx is done when the server shuts down, stopping the comparisons running in the background
+	ctx context.Context
+	// comparisonSlots bounds the comparisons with production waiting in the background
+	comparisonSlots chan struct{}
+	// comparisons tracks the comparisons running in the background
+	comparisons sync.WaitGroup
 }
 
 func (s *server) helpProvider(_ []prowconfig.OrgRepo) (*pluginhelp.PluginHelp, error) {
@@ -131,7 +149,7 @@ func (s *server) helpProvider(_ []prowconfig.OrgRepo) (*pluginhelp.PluginHelp, e
 	return pluginHelp, nil
 }
 
-func serverFromOptions(o options) (*server, error) {
+func serverFromOptions(ctx context.Context, o options) (*server, error) {
 	ghc, err := o.github.GitHubClient(o.dryRun)
 	if err != nil {
 		return nil, fmt.Errorf("error creating GitHub client: %w", err)
@@ -151,11 +169,20 @@ func serverFromOptions(o options) (*server, error) {
 	rehearsalConfig := rehearsalConfigFromOptions(o)
 	rehearsalConfig.ProwjobNamespace = c.ProwJobNamespace
 	rehearsalConfig.PodNamespace = c.PodNamespace
+	if o.productionRuns > 0 {
+		opener, err := prowio.NewOpener(context.Background(), o.gcsCredentialsFile, "")
+		if err != nil {
+			return nil, fmt.Errorf("error creating opener for the job history: %w", err)
+		}
+		rehearsalConfig.JobHistory = rehearse.NewGCSJobHistory(opener, o.gcsBucket)
+	}
 
 	return &server{
 		ghc:             ghc,
 		gc:              gc,
 		rehearsalConfig: rehearsalConfig,
+		ctx:             ctx,
+		comparisonSlots: make(chan struct{}, maxPendingComparisons),
 	}, nil
 }
 
@@ -473,12 +500,15 @@ func (s *server) handlePotentialCommands(pullRequest *github.PullRequest, commen
 					}
 
 					autoAckMode := rehearseAutoAck == command
-					success, err := rc.RehearseJobs(candidatePath, prRefs, presubmitsToRehearse, prConfig.Prow, autoAckMode, logger)
+					success, compare, err := rc.RehearseJobs(candidatePath, prRefs, presubmitsToRehearse, prConfig.Prow, autoAckMode, logger)
 					if err != nil {
 						logger.WithError(err).Error("couldn't rehearse jobs")
 						s.reportFailure("failed to create rehearsal jobs", err, org, repo, user, number, true, false, logger)
 						continue
 					}
+					if compare != nil {
+						s.compareWithProduction(compare, org, repo, user, number, logger)
+					}
 					if autoAckMode && success {
 						s.acknowledgeRehearsals(org, repo, number, logger)
 					}
@@ -685,6 +715,90 @@ func getAffectedJobFormattedList(presubmits config.Presubmits, periodics config.
 	return jobs
 }
 
+// compareWithProduction comments the comparison of the rehearsals with production once they finish.
+// The rehearsals may take hours, so this happens in the background and not in the slot of the handler.
+// The comparison is skipped when too many others are waiting already, and stops when the server shuts down.
+func (s *server) compareWithProduction(compare rehearse.ProductionComparer, org, repo, user string, number int, logger *logrus.Entry) {
+	select {
+	case s.comparisonSlots <- struct{}{}:
+	default:
+		logger.Warn("Too many rehearsals are waiting to be compared with production, not comparing these.")
+		return
+	}
+	if s.ctx.Err() != nil {
+		<-s.comparisonSlots
+		logger.Info("Server is shutting down, not comparing the rehearsals with production.")
+		return
+	}
+	s.comparisons.Add(1)
+	go func() {
+		defer s.comparisons.Done()
+		defer func() { <-s.comparisonSlots }()
+		comparisons, err := compare(s.ctx)
+		if err != nil {
+			if s.ctx.Err() != nil {
+				logger.WithError(err).Info("Server is shutting down, not comparing the rehearsals with production.")
+				return
+			}
+			logger.WithError(err).Warn("Failed to compare the rehearsals with production.")
+			return
+		}
+		if len(comparisons) == 0 {
+			return
+		}
+		if err := s.ghc.CreateComment(org, repo, number, strings.Join(s.getProductionComparisonLines(comparisons, user), "\n")); err != nil {
+			logger.WithError(err).Error("failed to create comment")
+		}
+	}()
+}
+
+// getProductionComparisonLines returns a Markdown formatted table comparing the results of the rehearsals
+// with the recent production runs of the rehearsed jobs in the form of a []string
+func (s *server) getProductionComparisonLines(comparisons []rehearse.ProductionComparison, user string) []string {
+	lines := []string{
+		fmt.Sprintf("@%s: the rehearsals finished. Their results compared with the last %d production runs of the rehearsed jobs:", user, s.rehearsalConfig.ProductionRuns),
+		"",
+		"Test name | Rehearsal | Production pass rate | Failures seen in production",
+		"--- | --- | --- | ---",
+	}
+	for _, comparison := range comparisons {
+		result := "failed"
+		if comparison.Passed {
+			result = "passed"
+		}
+		if comparison.URL != "" {
+			result = fmt.Sprintf("[%s](%s)", result, comparison.URL)
+		}
+
+		passRate := "unknown"
+		if comparison.Runs > 0 {
+			passRate = fmt.Sprintf("%d%% (%d/%d)", comparison.PassedRuns*100/comparison.Runs, comparison.PassedRuns, comparison.Runs)
+		}
+
+		var seen string
+		switch {
+		case comparison.Passed:
+			seen = "N/A"
+		case len(comparison.Failures) == 0:
+			seen = "unknown"
+		case len(comparison.SeenInProduction) == 0:
+			seen = "no"
+		default:
+			var tests []string
+			for i, test := range sets.List(comparison.SeenInProduction) {
+				if i == maxListedFailures {
+					tests = append(tests, fmt.Sprintf("and %d more", len(comparison.SeenInProduction)-maxListedFailures))
+					break
+				}
+				tests = append(tests, fmt.Sprintf("`%s`", test))
+			}
+			seen = fmt.Sprintf("yes: %s", strings.Join(tests, ", "))
+		}
+		lines = append(lines, fmt.Sprintf("%s | %s | %s | %s", comparison.Job, result, passRate, seen))
+	}
+	return append(lines, "")
+}
+
 func (s *server) getUsageDetailsLines() []string {
 	rc := s.rehearsalConfig
 	return []string{
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -9,11 +10,15 @@ import (
 	"testing"
 	"time"
 
+	"github.com/google/go-cmp/cmp"
 	"github.com/sirupsen/logrus"
 
+	"k8s.io/apimachinery/pkg/util/sets"
 	"sigs.k8s.io/prow/pkg/git/localgit"
 	"sigs.k8s.io/prow/pkg/git/v2"
 	"sigs.k8s.io/prow/pkg/github"
+
+	"github.com/openshift/ci-tools/pkg/rehearse"
 )
 
 // testRepoClient wraps a real git.RepoClient and overrides FetchRef to work
@@ -444,3 +449,92 @@ func TestNotifyDroppedRequestAutomatic(t *testing.T) {
 		t.Errorf("expected timeout duration in message, got: %s", comments[0])
 	}
 }
+
+func TestGetProductionComparisonLines(t *testing.T) {
+	s := &server{rehearsalConfig: rehearse.RehearsalConfig{ProductionRuns: 10}}
+	comparisons := []rehearse.ProductionComparison{
+		{Job: "passing", URL: "https://prow/passing", Passed: true, Runs: 10, PassedRuns: 9},
+		{Job: "flaky", Runs: 4, PassedRuns: 1, Failures: sets.New[string]("a", "b", "c", "d", "e"), SeenInProduction: sets.New[string]("a", "b", "c", "d", "e")},
+		{Job: "broken", Runs: 3, PassedRuns: 3, Failures: sets.New[string]("new"), SeenInProduction: sets.New[string]()},
+		{Job: "unknown"},
+	}
+	expected := []string{
+		"@user: the rehearsals finished. Their results compared with the last 10 production runs of the rehearsed jobs:",
+		"",
+		"Test name | Rehearsal | Production pass rate | Failures seen in production",
+		"--- | --- | --- | ---",
+		"passing | [passed](https://prow/passing) | 90% (9/10) | N/A",
+		"flaky | failed | 25% (1/4) | yes: `a`, `b`, `c`, and 2 more",
+		"broken | failed | 100% (3/3) | no",
+		"unknown | failed | unknown | unknown",
+		"",
+	}
+	if diff := cmp.Diff(expected, s.getProductionComparisonLines(comparisons, "user")); diff != "" {
+		t.Errorf("unexpected comment (-want, +got): %s", diff)
+	}
+}
+
+func TestCompareWithProductionInBackground(t *testing.T) {
+	ghc := &commentRecorder{}
+	s := &server{ghc: ghc, rehearsalConfig: rehearse.RehearsalConfig{ProductionRuns: 10}, ctx: context.Background(), comparisonSlots: make(chan struct{}, 1)}
+	logger := logrus.NewEntry(logrus.StandardLogger())
+
+	release := make(chan struct{})
+	s.compareWithProduction(func(context.Context) ([]rehearse.ProductionComparison, error) {
+		<-release
+		return []rehearse.ProductionComparison{{Job: "passing", URL: "https://prow/passing", Passed: true, Runs: 1, PassedRuns: 1}}, nil
+	}, "org", "repo", "user", 1, logger)
+
+	// the comparison waits in the background, another one does not fit and is skipped
+	skipped := true
+	s.compareWithProduction(func(context.Context) ([]rehearse.ProductionComparison, error) {
+		skipped = false
+		return nil, nil
+	}, "org", "repo", "user", 2, logger)
+	if len(ghc.getComments()) != 0 {
+		t.Fatalf("expected no comment before the rehearsals finished, got %v", ghc.getComments())
+	}
+
+	close(release)
+	s.comparisons.Wait()
+	if !skipped {
+		t.Error("expected the comparison that did not fit to be skipped")
+	}
+	comments := ghc.getComments()
+	if len(comments) != 1 || !strings.Contains(comments[0], "passing | [passed](https://prow/passing)") {
+		t.Errorf("expected the comparison to be commented, got %v", comments)
+	}
+	if len(s.comparisonSlots) != 0 {
+		t.Error("expected the finished comparison to free its slot")
+	}
+}
+
+func TestCompareWithProductionStopsAtShutdown(t *testing.T) {
+	ghc := &commentRecorder{}
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &server{ghc: ghc, rehearsalConfig: rehearse.RehearsalConfig{ProductionRuns: 10}, ctx: ctx, comparisonSlots: make(chan struct{}, 1)}
+	logger := logrus.NewEntry(logrus.StandardLogger())
+
+	s.compareWithProduction(func(ctx context.Context) ([]rehearse.ProductionComparison, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}, "org", "repo", "user", 1, logger)
+	cancel()
+	s.comparisons.Wait()
+
+	started := false
+	s.compareWithProduction(func(context.Context) ([]rehearse.ProductionComparison, error) {
+		started = true
+		return nil, nil
+	}, "org", "repo", "user", 2, logger)
+	s.comparisons.Wait()
+	if started {
+		t.Error("expected no comparison to start after the shutdown")
+	}
+	if len(ghc.getComments()) != 0 {
+		t.Errorf("expected no comment after the shutdown, got %v", ghc.getComments())
+	}
+	if len(s.comparisonSlots) != 0 {
+		t.Error("expected the stopped comparisons to free their slots")
+	}
+}
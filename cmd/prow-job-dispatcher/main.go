@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io/fs"
@@ -18,6 +20,7 @@ import (
 	"syscall"
 	"time"
 
+	prometheusapi "github.com/prometheus/client_golang/api/prometheus/v1"
 	"github.com/robfig/cron/v3"
 	"github.com/sirupsen/logrus"
 	"github.com/slack-go/slack"
@@ -77,6 +80,12 @@ type options struct {
 
 	slackTokenPath string
 	opsChannelId   string
+
+	scoring            dispatcher.ScoringOptions
+	dispatchReportPath string
+	dispatchPlanPath   string
+	maxMoves           int
+	pinsPath           string
 }
 
 type slackClient interface {
@@ -109,6 +118,11 @@ func gatherOptions() options {
 	o.GitAuthorOptions.AddFlags(fs)
 	o.PrometheusOptions.AddFlags(fs)
 	o.PRCreationOptions.AddFlags(fs)
+	o.scoring.AddFlags(fs)
+	fs.StringVar(&o.dispatchReportPath, "dispatch-report-path", "", "If passed, write a JSON report of the cluster scores and the moved jobs to this path after each dispatch.")
+	fs.StringVar(&o.dispatchPlanPath, "dispatch-plan-path", "", "If passed, write the JSON dispatch plan with the moved, deferred and pinned jobs, their reasons and the predicted cluster volumes to this path after each dispatch.")
+	fs.IntVar(&o.maxMoves, "max-moves", 0, "If positive, move at most this many jobs in a single dispatch. Moves away from blocked clusters are always made.")
+	fs.StringVar(&o.pinsPath, "pins-path", "", "Path to a file listing jobs, by name or regex, which must never be moved to a different cluster.")
 
 	o.AllowAnonymous = true
 	if err := fs.Parse(os.Args[1:]); err != nil {
@@ -168,6 +182,12 @@ func (o *options) validate() error {
 			return err
 		}
 	}
+	if o.maxMoves < 0 {
+		return fmt.Errorf("--max-moves must not be negative")
+	}
+	if err := o.scoring.Validate(); err != nil {
+		return err
+	}
 	return o.PrometheusOptions.Validate()
 }
 
@@ -206,6 +226,9 @@ type clusterVolume struct {
 	blocked            sets.Set[string]
 	volumeDistribution map[string]float64
 	clusterMap         dispatcher.ClusterMap
+	// previous holds the assignments of the last dispatch, used to detect moved jobs
+	previous map[string]dispatcher.ProwJobData
+	report   *dispatcher.DispatchReport
 }
 
 // findClusterForJobConfig finds a cluster running on a preferred cloud provider for the jobs in a Prow job config.
@@ -234,6 +257,13 @@ func (cv *clusterVolume) findClusterForJobConfig(cloudProvider string, jc *prowc
 				if cv.clusterMap[c].Capacity != 100 {
 					continue
 				}
+				if cv.scoringEnabled() {
+					// compare how far each cluster is from its score-weighted share instead of the absolute volume
+					if cv.volumeDistribution[c] <= 0 {
+						continue
+					}
+					v = v / cv.volumeDistribution[c]
+				}
 				if cloudProvider == "" || cloudProvider == cp {
 					if min < 0 || min > v {
 						min = v
@@ -358,6 +388,9 @@ func (cv *clusterVolume) addToVolume(cluster string, jobBase prowconfig.JobBase,
 	blockedForJob := blockedClustersForJob(jobBase.Name, string(determinedCluster), cv.blocked)
 	c := dispatcher.DetermineTargetCluster(cluster, string(determinedCluster), string(config.Default), canBeRelocated, blockedForJob)
 	cv.pjs[jobBase.Name] = dispatcher.ProwJobData{Cluster: c, Capabilities: extractCapabilities(jobBase.Labels)}
+	if previous, ok := cv.previous[jobBase.Name]; ok && previous.Cluster != "" && previous.Cluster != c && cv.report != nil {
+		cv.report.RecordMove(jobBase.Name, previous.Cluster, c, cv.moveReason(c, cluster, string(determinedCluster), string(config.Default), canBeRelocated))
+	}
 	if determinedCloudProvider := config.IsInBuildFarm(api.Cluster(c)); determinedCloudProvider != "" {
 		cv.clusterVolumeMap[string(determinedCloudProvider)][c] = cv.clusterVolumeMap[string(determinedCloudProvider)][c] + jobVolumes[jobBase.Name]
 		return nil
@@ -366,6 +399,22 @@ func (cv *clusterVolume) addToVolume(cluster string, jobBase prowconfig.JobBase,
 	return nil
 }
 
+func (cv *clusterVolume) scoringEnabled() bool {
+	return cv.report != nil && len(cv.report.Scores) > 0
+}
+
+// moveReason explains why a job was assigned to the target cluster
+func (cv *clusterVolume) moveReason(target, chosen, determined, defaultCluster string, canBeRelocated bool) string {
+	switch {
+	case target == chosen && target != determined && canBeRelocated:
+		return cv.report.ScoreReason(target)
+	case target == defaultCluster && target != determined && target != chosen:
+		return "the determined cluster is blocked, falling back to the default cluster"
+	default:
+		return "determined by the dispatcher config"
+	}
+}
+
 // dispatchJobConfig dispatches the jobs defined in a Prow jon config
 func (cv *clusterVolume) dispatchJobConfig(jc *prowconfig.JobConfig, path string, config *dispatcher.Config, jobVolumes map[string]float64) (string, error) {
 	cloudProvidersForE2ETests := getCloudProvidersForE2ETests(jc)
@@ -398,7 +447,10 @@ type fileSizeInfo struct {
 //   - When all the e2e tests are targeting the same cloud provider, we run the test pod on the that cloud provider too.
 //   - When the e2e tests are targeting different cloud providers, or there is no e2e tests at all, we can run the tests
 //     on any cluster in the build farm. Those jobs are used to load balance the workload of clusters in the build farm.
-func dispatchJobs(prowJobConfigDir string, config *dispatcher.Config, jobVolumes map[string]float64, blocked sets.Set[string], volumeDistribution map[string]float64, cm dispatcher.ClusterMap) (map[string]dispatcher.ProwJobData, error) {
+//
+// When the report contains cluster scores, relocatable jobs are balanced against the score-weighted
+// volume distribution, and every job that changes its cluster compared to previous is recorded in the report.
+func dispatchJobs(prowJobConfigDir string, config *dispatcher.Config, jobVolumes map[string]float64, blocked sets.Set[string], volumeDistribution map[string]float64, cm dispatcher.ClusterMap, previous map[string]dispatcher.ProwJobData, report *dispatcher.DispatchReport) (map[string]dispatcher.ProwJobData, error) {
 	if config == nil {
 		return nil, fmt.Errorf("config is nil")
 	}
@@ -411,7 +463,10 @@ func dispatchJobs(prowJobConfigDir string, config *dispatcher.Config, jobVolumes
 		blocked:            blocked,
 		specialClusters:    map[string]float64{},
 		volumeDistribution: volumeDistribution,
-		clusterMap:         cm}
+		clusterMap:         cm,
+		previous:           previous,
+		report:             report,
+	}
 	for cloudProvider, v := range config.BuildFarm {
 		for cluster := range v {
 			cloudProviderString := string(cloudProvider)
@@ -469,6 +524,10 @@ func dispatchJobs(prowJobConfigDir string, config *dispatcher.Config, jobVolumes
 		}
 	}
 
+	if report != nil {
+		report.SortMoves()
+	}
+
 	return cv.pjs, utilerrors.NewAggregate(errs)
 }
 
@@ -633,7 +692,7 @@ func cleanup(directory string) {
 
 // createPR creates PR with config changes and sanitizer changes, it causes app to exit in
 // case of failure to trigger re-run of logic
-func createPR(o options, config *dispatcher.Config, pjs map[string]dispatcher.ProwJobData, cm dispatcher.ClusterMap) {
+func createPR(o options, config *dispatcher.Config, pjs map[string]dispatcher.ProwJobData, cm dispatcher.ClusterMap, plan *dispatcher.DispatchPlan) {
 	targetDirWithRelease := filepath.Join(o.targetDir, "/release")
 	cleanup(targetDirWithRelease)
 	defer cleanup(targetDirWithRelease)
@@ -656,11 +715,36 @@ func createPR(o options, config *dispatcher.Config, pjs map[string]dispatcher.Pr
 	}
 
 	title := fmt.Sprintf("%s at %s", matchTitle, time.Now().Format(time.RFC1123))
-	if err := o.PRCreationOptions.UpsertPR(targetDirWithRelease, githubOrg, githubRepo, o.upstreamBranch, title, prcreation.PrAssignee(o.assign), prcreation.MatchTitle(matchTitle), prcreation.AdditionalLabels([]string{rehearse.RehearsalsAckLabel, "priority/ci-critical"}), prcreation.PrBody(o.prBody)); err != nil {
+	body := plan.Markdown()
+	if o.prBody != "" {
+		body = o.prBody + "\n\n" + body
+	}
+	if err := o.PRCreationOptions.UpsertPR(targetDirWithRelease, githubOrg, githubRepo, o.upstreamBranch, title, prcreation.PrAssignee(o.assign), prcreation.MatchTitle(matchTitle), prcreation.AdditionalLabels([]string{rehearse.RehearsalsAckLabel, "priority/ci-critical"}), prcreation.PrBody(body), prcreation.GitCommitMessage(fmt.Sprintf("Moved %d jobs, see the PR description for the dispatch plan.", len(plan.Moves)))); err != nil {
 		logrus.WithError(err).Fatal("failed to upsert PR")
 	}
 }
 
+// writeDispatchReport writes the scores and the moves the plan kept
+func writeDispatchReport(path string, plan *dispatcher.DispatchPlan) error {
+	report := &dispatcher.DispatchReport{Scores: plan.Scores}
+	for _, move := range plan.Moves {
+		report.Moves = append(report.Moves, move.JobMove)
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal dispatch report: %w", err)
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+func writeDispatchPlan(path string, plan *dispatcher.DispatchPlan) error {
+	data, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal dispatch plan: %w", err)
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
 func sendSlackMessage(slackClient slackClient, channelId string) error {
 	blockMessage := slack.MsgOptionBlocks(
 		slack.NewSectionBlock(
@@ -711,6 +795,15 @@ func main() {
 		logrus.WithError(err).Fatal("failed to create prometheus volumes")
 	}
 
+	var loadSource dispatcher.ClusterLoadSource
+	if o.scoring.Enabled {
+		promClient, err := o.PrometheusOptions.NewPrometheusClient(secret.GetSecret)
+		if err != nil {
+			logrus.WithError(err).Fatal("failed to create prometheus client")
+		}
+		loadSource = dispatcher.NewPrometheusClusterLoadSource(prometheusapi.NewAPI(promClient))
+	}
+
 	if err := secret.Add(o.slackTokenPath); err != nil {
 		logrus.WithError(err).Fatal("failed to start secrets agent")
 	}
@@ -800,12 +893,47 @@ func main() {
 					}
 					return api.Cloud(info.Provider), nil
 				})
-			pjs, err := dispatchJobs(o.prowJobConfigDir, config, jobVolumes, blocked, promVolumes.CalculateVolumeDistribution(configClusterMap), configClusterMap)
+			report := &dispatcher.DispatchReport{}
+			volumeDistribution := promVolumes.CalculateVolumeDistribution(configClusterMap)
+			if loadSource != nil {
+				ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+				loads, err := loadSource.GetClusterLoads(ctx)
+				cancel()
+				if err != nil {
+					// the scoring is best effort, the volume-based dispatch still works without it
+					logrus.WithError(err).Warn("failed to get cluster loads, dispatching by job volume only")
+				} else {
+					report.Scores = o.scoring.ScoreClusters(configClusterMap, loads)
+					volumeDistribution = dispatcher.WeightVolumeDistribution(volumeDistribution, report.Scores)
+				}
+			}
+			var pins *dispatcher.Pins
+			if o.pinsPath != "" {
+				if pins, err = dispatcher.LoadPins(o.pinsPath); err != nil {
+					logrus.WithError(err).Error("failed to load pins")
+					return
+				}
+			}
+			previous := prowjobs.GetDataCopy()
+			pjs, err := dispatchJobs(o.prowJobConfigDir, config, jobVolumes, blocked, volumeDistribution, configClusterMap, previous, report)
 			if err != nil {
 				logrus.WithError(err).Error("failed to dispatch")
 				return
 			}
+			plan := dispatcher.PlanDispatch(report, previous, pjs, jobVolumes, sets.KeySet(configClusterMap), blocked, pins, o.maxMoves)
 			prowjobs.Regenerate(pjs)
+			logrus.WithField("moves", len(plan.Moves)).WithField("deferred", len(plan.Deferred)).WithField("pinned", len(plan.Pinned)).Info("dispatched jobs")
+
+			if o.dispatchReportPath != "" {
+				if err := writeDispatchReport(o.dispatchReportPath, plan); err != nil {
+					logrus.WithError(err).Error("failed to write dispatch report")
+				}
+			}
+			if o.dispatchPlanPath != "" {
+				if err := writeDispatchPlan(o.dispatchPlanPath, plan); err != nil {
+					logrus.WithError(err).Error("failed to write dispatch plan")
+				}
+			}
 
 			ecd.Reset(clustersFromConfig.UnsortedList())
 
@@ -814,7 +942,7 @@ func main() {
 			}
 
 			if o.createPR {
-				createPR(o, config, pjs, configClusterMap)
+				createPR(o, config, pjs, configClusterMap, plan)
 				if err := sendSlackMessage(slackClient, o.opsChannelId); err != nil {
 					logrus.WithError(err).Error("Failed to post message in ops channel")
 				}
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
+	"os"
 	"path/filepath"
 	"reflect"
 	"regexp"
@@ -116,7 +118,7 @@ func TestDispatchJobs(t *testing.T) {
 	}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			_, actual := dispatchJobs(tc.prowJobConfigDir, tc.config, tc.jobVolumes, sets.New[string](), tc.distribution, tc.clusterMap)
+			_, actual := dispatchJobs(tc.prowJobConfigDir, tc.config, tc.jobVolumes, sets.New[string](), tc.distribution, tc.clusterMap, nil, nil)
 			equalError(t, tc.expected, actual)
 			if tc.config != nil && !reflect.DeepEqual(tc.expectedBuildFarm, tc.config.BuildFarm) {
 				t.Errorf("%s: actual differs from expected:\n%s", t.Name(), cmp.Diff(tc.expectedBuildFarm, tc.config.BuildFarm))
@@ -139,6 +141,8 @@ func TestDispatchJobConfig(t *testing.T) {
 		jobVolumes  map[string]float64
 		expected    string
 		expectedErr error
+		// expectedReport is only compared if set
+		expectedReport *dispatcher.DispatchReport
 	}{
 		{
 			name: "basic case: non e2e job chooses build01",
@@ -243,6 +247,42 @@ func TestDispatchJobConfig(t *testing.T) {
 			},
 			expected: "build02",
 		},
+		{
+			name: "capacity-aware scoring: the less loaded cluster relative to its share wins and the move is recorded",
+			cv: &clusterVolume{
+				clusterVolumeMap: map[string]map[string]float64{"aws": {"build01": 10}, "gcp": {"build02": 5}},
+				cloudProviders:   sets.New[string]("aws", "gcp"),
+				pjs:              map[string]dispatcher.ProwJobData{},
+				blocked:          sets.New[string](),
+				volumeDistribution: map[string]float64{
+					"build01": 40,
+					"build02": 10,
+				},
+				clusterMap: clusterMap,
+				previous:   map[string]dispatcher.ProwJobData{"job": {Cluster: "build02"}},
+				report: &dispatcher.DispatchReport{
+					Scores: map[string]dispatcher.ClusterScore{"build01": {Score: 1}, "build02": {Score: 0.25}},
+				},
+			},
+			config: &c,
+			jc: &prowconfig.JobConfig{
+				PresubmitsStatic: map[string][]prowconfig.Presubmit{
+					"repo": {{JobBase: prowconfig.JobBase{Name: "job"}}},
+				},
+			},
+			path:       "repo-presubmits.yaml",
+			jobVolumes: map[string]float64{"job": 1},
+			expected:   "build01",
+			expectedReport: &dispatcher.DispatchReport{
+				Scores: map[string]dispatcher.ClusterScore{"build01": {Score: 1}, "build02": {Score: 0.25}},
+				Moves: []dispatcher.JobMove{{
+					Job:    "job",
+					From:   "build02",
+					To:     "build01",
+					Reason: "balanced by capacity-aware score (build01 score 1.00: 0 pending pods, 0 scheduling failures, 100% autoscaler headroom); other scores: build02 0.25",
+				}},
+			},
+		},
 	}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
@@ -253,6 +293,11 @@ func TestDispatchJobConfig(t *testing.T) {
 			if diff := cmp.Diff(tc.expectedErr, actualErr, testhelper.EquateErrorMessage); diff != "" {
 				t.Errorf("%s: actual does not match expected, diff: %s", tc.name, diff)
 			}
+			if tc.expectedReport != nil {
+				if diff := cmp.Diff(tc.expectedReport, tc.cv.report); diff != "" {
+					t.Errorf("%s: actual report does not match expected, diff: %s", tc.name, diff)
+				}
+			}
 		})
 	}
 }
@@ -617,3 +662,30 @@ func TestSendSlackMessage(t *testing.T) {
 		})
 	}
 }
+
+func TestWriteDispatchReport(t *testing.T) {
+	plan := &dispatcher.DispatchPlan{
+		Moves:    []dispatcher.PlannedMove{{JobMove: dispatcher.JobMove{Job: "moved", From: "build01", To: "build02", Reason: "overloaded"}, Volume: 10}},
+		Deferred: []dispatcher.PlannedMove{{JobMove: dispatcher.JobMove{Job: "deferred", From: "build01", To: "build02"}, Volume: 1}},
+		Scores:   map[string]dispatcher.ClusterScore{"build01": {Score: 0.5}},
+	}
+	path := filepath.Join(t.TempDir(), "report.json")
+	if err := writeDispatchReport(path, plan); err != nil {
+		t.Fatalf("failed to write the dispatch report: %v", err)
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read the dispatch report: %v", err)
+	}
+	var report dispatcher.DispatchReport
+	if err := json.Unmarshal(raw, &report); err != nil {
+		t.Fatalf("failed to unmarshal the dispatch report: %v", err)
+	}
+	expected := dispatcher.DispatchReport{
+		Scores: map[string]dispatcher.ClusterScore{"build01": {Score: 0.5}},
+		Moves:  []dispatcher.JobMove{{Job: "moved", From: "build01", To: "build02", Reason: "overloaded"}},
+	}
+	if diff := cmp.Diff(expected, report); diff != "" {
+		t.Errorf("unexpected report (-want, +got): %s", diff)
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"flag"
@@ -51,6 +52,8 @@ type options struct {
 	address     string
 	gracePeriod time.Duration
 	passwdFile  string
+	storePath   string
+	retention   time.Duration
 }
 
 func gatherOptions() (options, error) {
@@ -60,6 +63,8 @@ func gatherOptions() (options, error) {
 	fs.StringVar(&o.address, "address", ":8080", "Address to run server on")
 	fs.DurationVar(&o.gracePeriod, "gracePeriod", time.Second*10, "Grace period for server shutdown")
 	fs.StringVar(&o.passwdFile, "passwd-file", "", "Authenticate against a file. Each line of the file is with the form `<username>:<password>`.")
+	fs.StringVar(&o.storePath, "store-path", "", "Path to the database the individual results are stored in to serve the query API and UI. Results are only counted when empty.")
+	fs.DurationVar(&o.retention, "retention", 14*24*time.Hour, "How long the stored results are kept.")
 	if err := fs.Parse(os.Args[1:]); err != nil {
 		return o, fmt.Errorf("failed to parse flags: %w", err)
 	}
@@ -74,6 +79,9 @@ func validateOptions(o options) error {
 	if o.passwdFile == "" {
 		return errors.New("--passwd-file must be specified")
 	}
+	if o.storePath != "" && o.retention <= 0 {
+		return errors.New("--retention must be positive")
+	}
 	return nil
 }
 
@@ -167,7 +175,8 @@ func loginHandler(validator validator, next http.Handler) http.Handler {
 	})
 }
 
-func handleCIOperatorResult() http.HandlerFunc {
+// handleCIOperatorResult counts the result and stores it when store is set
+func handleCIOperatorResult(store *eventStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
 
@@ -189,6 +198,12 @@ func handleCIOperatorResult() http.HandlerFunc {
 		}
 
 		withErrorRate(request)
+		if store != nil {
+			// the counter is the source of truth for alerting, a failure to store the event must not fail the request
+			if err := store.recordResult(r.Context(), request); err != nil {
+				log.WithError(err).Warn("Failed to store result")
+			}
+		}
 
 		w.WriteHeader(http.StatusOK)
 
@@ -196,7 +211,8 @@ func handleCIOperatorResult() http.HandlerFunc {
 	}
 }
 
-func handlePodScalerResult() http.HandlerFunc {
+// handlePodScalerResult counts the warning and stores it when store is set
+func handlePodScalerResult(store *eventStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
 		bytes, err := io.ReadAll(r.Body)
@@ -217,6 +233,11 @@ func handlePodScalerResult() http.HandlerFunc {
 		}
 
 		recordHighResource(request)
+		if store != nil {
+			if err := store.recordPodScalerWarning(r.Context(), request); err != nil {
+				log.WithError(err).Warn("Failed to store pod-scaler warning")
+			}
+		}
 		w.WriteHeader(http.StatusOK)
 		log.WithFields(log.Fields{"request": request, "duration": time.Since(start).String()}).Info("Pod-scaler request processed")
 	}
@@ -240,8 +261,37 @@ func main() {
 
 	validator := &multi{delegates: []validator{&passwdFile{file: o.passwdFile}}}
 
-	http.Handle("/result", loginHandler(validator, handleCIOperatorResult()))
-	http.Handle("/pod-scaler", loginHandler(validator, handlePodScalerResult()))
+	var store *eventStore
+	if o.storePath != "" {
+		store, err = openEventStore(o.storePath)
+		if err != nil {
+			log.WithError(err).Fatal("failed to open the event store")
+		}
+		interrupts.OnInterrupt(func() {
+			if err := store.Close(); err != nil {
+				log.WithError(err).Error("failed to close the event store")
+			}
+		})
+		interrupts.TickLiteral(func() {
+			deleted, err := store.prune(context.Background(), o.retention)
+			if err != nil {
+				log.WithError(err).Error("failed to prune the event store")
+				return
+			}
+			log.WithField("deleted", deleted).Debug("Pruned the event store")
+		}, time.Hour)
+
+		// the error reasons hold the same labels as the exposed metrics, so they are not behind the login either
+		http.Handle("/api/reasons", handleErrorReasons(store))
+		// the step details, failure messages and pod-scaler warnings are more than the metrics expose
+		http.Handle("/api/signatures", loginHandler(validator, handleFailureSignatures(store)))
+		http.Handle("/api/top-steps", loginHandler(validator, handleTopFailingSteps(store)))
+		http.Handle("/api/pod-scaler", loginHandler(validator, handlePodScalerWarnings(store)))
+		http.Handle("/ui", loginHandler(validator, handleUI(store)))
+	}
+
+	http.Handle("/result", loginHandler(validator, handleCIOperatorResult(store)))
+	http.Handle("/pod-scaler", loginHandler(validator, handlePodScalerResult(store)))
 
 	metrics.ExposeMetrics("result-aggregator", prowConfig.PushGateway{}, flagutil.DefaultMetricsPort)
 
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultQuerySince    = 24 * time.Hour
+	defaultQueryInterval = time.Hour
+	defaultQueryLimit    = 20
+)
+
+var (
+	//go:embed ui.gohtml
+	uiTemplateString string
+
+	uiTemplate = template.Must(template.New("ui").Funcs(template.FuncMap{
+		"formatTime": func(t time.Time) string { return t.UTC().Format("2006-01-02 15:04") },
+	}).Parse(uiTemplateString))
+)
+
+// query holds the parameters shared by the query endpoints
+type query struct {
+	filter   resultFilter
+	since    time.Duration
+	interval time.Duration
+	limit    int
+	workload string
+	// onlyNew selects the signatures first seen in the queried interval
+	onlyNew bool
+}
+
+func parseQuery(values url.Values) (query, error) {
+	q := query{
+		filter: resultFilter{
+			Org:       values.Get("org"),
+			Repo:      values.Get("repo"),
+			Branch:    values.Get("branch"),
+			Step:      values.Get("step"),
+			JobName:   values.Get("job"),
+			Reason:    values.Get("reason"),
+			Signature: values.Get("signature"),
+		},
+		since:    defaultQuerySince,
+		interval: defaultQueryInterval,
+		limit:    defaultQueryLimit,
+		workload: values.Get("workload"),
+	}
+	for _, duration := range []struct {
+		name  string
+		value *time.Duration
+	}{
+		{name: "since", value: &q.since},
+		{name: "interval", value: &q.interval},
+	} {
+		raw := values.Get(duration.name)
+		if raw == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(raw)
+		if err != nil || parsed <= 0 {
+			return q, fmt.Errorf("%s must be a positive duration like 24h, got %q", duration.name, raw)
+		}
+		*duration.value = parsed
+	}
+	if raw := values.Get("new"); raw != "" {
+		onlyNew, err := strconv.ParseBool(raw)
+		if err != nil {
+			return q, fmt.Errorf("new must be true or false, got %q", raw)
+		}
+		q.onlyNew = onlyNew
+	}
+	if raw := values.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit <= 0 {
+			return q, fmt.Errorf("limit must be a positive number, got %q", raw)
+		}
+		q.limit = limit
+	}
+	return q, nil
+}
+
+func writeJSON(w http.ResponseWriter, data interface{}) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to marshal response: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write(raw); err != nil {
+		log.WithError(err).Debug("Failed to write response")
+	}
+}
+
+// handleErrorReasons serves the failures by reason, org, repo, branch and step over time
+func handleErrorReasons(store *eventStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q, err := parseQuery(r.URL.Query())
+		if err != nil {
+			handleError(w, err)
+			return
+		}
+		reasons, err := store.errorReasons(r.Context(), q.filter, store.now().Add(-q.since), q.interval)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, reasons)
+	}
+}
+
+// handleTopFailingSteps serves the steps that failed the most
+func handleTopFailingSteps(store *eventStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q, err := parseQuery(r.URL.Query())
+		if err != nil {
+			handleError(w, err)
+			return
+		}
+		steps, err := store.topFailingSteps(r.Context(), q.filter, store.now().Add(-q.since), q.limit)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, steps)
+	}
+}
+
+// handleFailureSignatures serves the failures by signature, new=true lists the new kinds of failures
+func handleFailureSignatures(store *eventStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q, err := parseQuery(r.URL.Query())
+		if err != nil {
+			handleError(w, err)
+			return
+		}
+		signatures, err := store.failureSignatures(r.Context(), q.filter, store.now().Add(-q.since), q.onlyNew, q.limit)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, signatures)
+	}
+}
+
+// handlePodScalerWarnings serves the pod-scaler warnings by workload
+func handlePodScalerWarnings(store *eventStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q, err := parseQuery(r.URL.Query())
+		if err != nil {
+			handleError(w, err)
+			return
+		}
+		warnings, err := store.podScalerWarnings(r.Context(), q.workload, store.now().Add(-q.since))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, warnings)
+	}
+}
+
+// handleUI serves a page with the same breakdowns as the query endpoints and a form for their parameters
+func handleUI(store *eventStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		values := r.URL.Query()
+		q, err := parseQuery(values)
+		if err != nil {
+			handleError(w, err)
+			return
+		}
+		since := store.now().Add(-q.since)
+		data := struct {
+			Values     url.Values
+			Reasons    []reasonCount
+			Steps      []stepFailures
+			Signatures []signatureCount
+			Warnings   []podScalerWarningCount
+		}{Values: values}
+		if data.Reasons, err = store.errorReasons(r.Context(), q.filter, since, q.interval); err == nil {
+			if data.Steps, err = store.topFailingSteps(r.Context(), q.filter, since, q.limit); err == nil {
+				if data.Signatures, err = store.failureSignatures(r.Context(), q.filter, since, q.onlyNew, q.limit); err == nil {
+					data.Warnings, err = store.podScalerWarnings(r.Context(), q.workload, since)
+				}
+			}
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		buff := bytes.Buffer{}
+		if err := uiTemplate.Execute(&buff, data); err != nil {
+			http.Error(w, fmt.Sprintf("failed to render page: %v", err), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if _, err := w.Write(buff.Bytes()); err != nil {
+			log.WithError(err).Debug("Failed to write response")
+		}
+	}
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	// registers the sqlite driver
+	_ "modernc.org/sqlite"
+
+	"github.com/openshift/ci-tools/pkg/results"
+)
+
+// storeSchema holds the individual events the counters are incremented for. Timestamps are unix seconds.
+const storeSchema = `
+CREATE TABLE IF NOT EXISTS results (
+	time INTEGER NOT NULL,
+	job_name TEXT NOT NULL,
+	type TEXT NOT NULL,
+	cluster TEXT NOT NULL,
+	state TEXT NOT NULL,
+	reason TEXT NOT NULL,
+	org TEXT NOT NULL DEFAULT '',
+	repo TEXT NOT NULL DEFAULT '',
+	branch TEXT NOT NULL DEFAULT '',
+	step TEXT NOT NULL DEFAULT '',
+	signature TEXT NOT NULL DEFAULT '',
+	signature_message TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS results_by_time ON results (time);
+CREATE INDEX IF NOT EXISTS results_by_signature ON results (signature, time);
+CREATE TABLE IF NOT EXISTS pod_scaler_warnings (
+	time INTEGER NOT NULL,
+	workload_name TEXT NOT NULL,
+	workload_type TEXT NOT NULL,
+	configured_amount TEXT NOT NULL,
+	determined_amount TEXT NOT NULL,
+	resource_type TEXT NOT NULL,
+	measured BOOLEAN NOT NULL,
+	workload_class TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS pod_scaler_warnings_by_time ON pod_scaler_warnings (time);
+`
+
+// eventStore persists the requests the server receives so the breakdown behind the counters can be queried
+type eventStore struct {
+	db  *sql.DB
+	now func() time.Time
+}
+
+func openEventStore(path string) (*eventStore, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open event store %q: %w", path, err)
+	}
+	// sqlite does not allow concurrent writers
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(storeSchema); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("failed to create the event store schema: %w", err)
+	}
+	return &eventStore{db: db, now: time.Now}, nil
+}
+
+func (s *eventStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *eventStore) recordResult(ctx context.Context, request *results.Request) error {
+	_, err := s.db.ExecContext(ctx, `INSERT INTO results (time, job_name, type, cluster, state, reason, org, repo, branch, step, signature, signature_message) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		s.now().Unix(), request.JobName, request.Type, request.Cluster, request.State, request.Reason, request.Org, request.Repo, request.Branch, request.Step, request.Signature, request.SignatureMessage)
+	return err
+}
+
+func (s *eventStore) recordPodScalerWarning(ctx context.Context, request *results.PodScalerRequest) error {
+	_, err := s.db.ExecContext(ctx, `INSERT INTO pod_scaler_warnings (time, workload_name, workload_type, configured_amount, determined_amount, resource_type, measured, workload_class) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		s.now().Unix(), request.WorkloadName, request.WorkloadType, request.ConfiguredAmount, request.DeterminedAmount, request.ResourceType, request.Measured, request.WorkloadClass)
+	return err
+}
+
+// prune deletes the events older than the retention
+func (s *eventStore) prune(ctx context.Context, retention time.Duration) (int64, error) {
+	before := s.now().Add(-retention).Unix()
+	var deleted int64
+	for _, table := range []string{"results", "pod_scaler_warnings"} {
+		result, err := s.db.ExecContext(ctx, fmt.Sprintf(`DELETE FROM %s WHERE time < ?`, table), before)
+		if err != nil {
+			return deleted, fmt.Errorf("failed to prune %s: %w", table, err)
+		}
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return deleted, err
+		}
+		deleted += rows
+	}
+	return deleted, nil
+}
+
+// resultFilter narrows the failures a query reads, empty fields match everything
+type resultFilter struct {
+	Org       string
+	Repo      string
+	Branch    string
+	Step      string
+	JobName   string
+	Signature string
+	// Reason matches the reasons that start with it, so a prefix of a chain selects all of its children
+	Reason string
+}
+
+func (f resultFilter) where(since time.Time) (string, []interface{}) {
+	clauses := []string{"state = ?", "time >= ?"}
+	args := []interface{}{results.StateFailed, since.Unix()}
+	for _, field := range []struct {
+		column, value string
+	}{
+		{column: "org", value: f.Org},
+		{column: "repo", value: f.Repo},
+		{column: "branch", value: f.Branch},
+		{column: "step", value: f.Step},
+		{column: "job_name", value: f.JobName},
+		{column: "signature", value: f.Signature},
+	} {
+		if field.value != "" {
+			clauses = append(clauses, field.column+" = ?")
+			args = append(args, field.value)
+		}
+	}
+	if f.Reason != "" {
+		clauses = append(clauses, "substr(reason, 1, length(?)) = ?")
+		args = append(args, f.Reason, f.Reason)
+	}
+	return strings.Join(clauses, " AND "), args
+}
+
+// reasonCount is the number of failures with a reason in a configuration and step during one interval
+type reasonCount struct {
+	Start  time.Time `json:"start"`
+	Org    string    `json:"org"`
+	Repo   string    `json:"repo"`
+	Branch string    `json:"branch"`
+	Step   string    `json:"step"`
+	Reason string    `json:"reason"`
+	Count  int64     `json:"count"`
+}
+
+// errorReasons counts the failures by reason, org, repo, branch and step in intervals of the given length
+func (s *eventStore) errorReasons(ctx context.Context, filter resultFilter, since time.Time, interval time.Duration) ([]reasonCount, error) {
+	seconds := int64(interval / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	where, args := filter.where(since)
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf(`
+SELECT (time / %[1]d) * %[1]d AS start, org, repo, branch, step, reason, COUNT(*)
+FROM results WHERE %[2]s
+GROUP BY start, org, repo, branch, step, reason
+ORDER BY start, COUNT(*) DESC, reason`, seconds, where), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query error reasons: %w", err)
+	}
+	defer rows.Close()
+	ret := []reasonCount{}
+	for rows.Next() {
+		var count reasonCount
+		var start int64
+		if err := rows.Scan(&start, &count.Org, &count.Repo, &count.Branch, &count.Step, &count.Reason, &count.Count); err != nil {
+			return nil, err
+		}
+		count.Start = time.Unix(start, 0).UTC()
+		ret = append(ret, count)
+	}
+	return ret, rows.Err()
+}
+
+// stepFailures is the number of failures of a step of a configuration
+type stepFailures struct {
+	Org      string `json:"org"`
+	Repo     string `json:"repo"`
+	Branch   string `json:"branch"`
+	Step     string `json:"step"`
+	Failures int64  `json:"failures"`
+	// Reason is the most common reason of the failures
+	Reason string `json:"reason"`
+}
+
+// topFailingSteps lists the steps that failed the most
+func (s *eventStore) topFailingSteps(ctx context.Context, filter resultFilter, since time.Time, limit int) ([]stepFailures, error) {
+	where, args := filter.where(since)
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf(`
+WITH failures AS (
+	SELECT org, repo, branch, step, reason, COUNT(*) AS count FROM results
+	WHERE step != '' AND %s
+	GROUP BY org, repo, branch, step, reason
+)
+SELECT org, repo, branch, step, SUM(count) AS total,
+	(SELECT f.reason FROM failures f WHERE f.org = failures.org AND f.repo = failures.repo AND f.branch = failures.branch AND f.step = failures.step ORDER BY f.count DESC, f.reason LIMIT 1)
+FROM failures
+GROUP BY org, repo, branch, step
+ORDER BY total DESC, org, repo, branch, step
+LIMIT ?`, where), append(args, limit)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query failing steps: %w", err)
+	}
+	defer rows.Close()
+	ret := []stepFailures{}
+	for rows.Next() {
+		var step stepFailures
+		if err := rows.Scan(&step.Org, &step.Repo, &step.Branch, &step.Step, &step.Failures, &step.Reason); err != nil {
+			return nil, err
+		}
+		ret = append(ret, step)
+	}
+	return ret, rows.Err()
+}
+
+// signatureCount is the number of failures with one signature
+type signatureCount struct {
+	Signature string `json:"signature"`
+	// Message is the normalized message of the latest failure with the signature
+	Message string `json:"message"`
+	// Reason is the reason of the latest failure with the signature
+	Reason string `json:"reason"`
+	Count  int64  `json:"count"`
+	Jobs   int64  `json:"jobs"`
+	// FirstSeen is the first failure with the signature in the whole store, not only in the queried interval
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// failureSignatures counts the failures by signature. When onlyNew is set, only the signatures that were first seen
+// since the time are listed, which are the new kinds of failures.
+func (s *eventStore) failureSignatures(ctx context.Context, filter resultFilter, since time.Time, onlyNew bool, limit int) ([]signatureCount, error) {
+	where, args := filter.where(since)
+	having := ""
+	if onlyNew {
+		having = "HAVING first_seen >= ?"
+		args = append(args, since.Unix())
+	}
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf(`
+SELECT signature, COUNT(*), COUNT(DISTINCT job_name), MAX(time),
+	(SELECT MIN(r.time) FROM results r WHERE r.signature = results.signature) AS first_seen,
+	(SELECT r.signature_message FROM results r WHERE r.signature = results.signature ORDER BY r.time DESC LIMIT 1),
+	(SELECT r.reason FROM results r WHERE r.signature = results.signature ORDER BY r.time DESC LIMIT 1)
+FROM results
+WHERE signature != '' AND %s
+GROUP BY signature
+%s
+ORDER BY COUNT(*) DESC, signature
+LIMIT ?`, where, having), append(args, limit)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query failure signatures: %w", err)
+	}
+	defer rows.Close()
+	ret := []signatureCount{}
+	for rows.Next() {
+		var count signatureCount
+		var firstSeen, lastSeen int64
+		if err := rows.Scan(&count.Signature, &count.Count, &count.Jobs, &lastSeen, &firstSeen, &count.Message, &count.Reason); err != nil {
+			return nil, err
+		}
+		count.FirstSeen, count.LastSeen = time.Unix(firstSeen, 0).UTC(), time.Unix(lastSeen, 0).UTC()
+		ret = append(ret, count)
+	}
+	return ret, rows.Err()
+}
+
+// podScalerWarningCount is the number of times pod-scaler raised the resources of a container of a workload
+type podScalerWarningCount struct {
+	WorkloadName string `json:"workload_name"`
+	WorkloadType string `json:"workload_type"`
+	ResourceType string `json:"resource_type"`
+	Count        int64  `json:"count"`
+	// ConfiguredAmount and DeterminedAmount are from the latest warning
+	ConfiguredAmount string    `json:"configured_amount"`
+	DeterminedAmount string    `json:"determined_amount"`
+	Last             time.Time `json:"last"`
+}
+
+// podScalerWarnings counts the pod-scaler warnings by workload, workloadName matches the names that contain it
+func (s *eventStore) podScalerWarnings(ctx context.Context, workloadName string, since time.Time) ([]podScalerWarningCount, error) {
+	rows, err := s.db.QueryContext(ctx, `
+SELECT workload_name, workload_type, resource_type, COUNT(*), MAX(time),
+	(SELECT w.configured_amount FROM pod_scaler_warnings w WHERE w.workload_name = p.workload_name AND w.workload_type = p.workload_type AND w.resource_type = p.resource_type ORDER BY w.time DESC LIMIT 1),
+	(SELECT w.determined_amount FROM pod_scaler_warnings w WHERE w.workload_name = p.workload_name AND w.workload_type = p.workload_type AND w.resource_type = p.resource_type ORDER BY w.time DESC LIMIT 1)
+FROM pod_scaler_warnings p
+WHERE time >= ? AND instr(workload_name, ?) > 0
+GROUP BY workload_name, workload_type, resource_type
+ORDER BY COUNT(*) DESC, workload_name, workload_type, resource_type`, since.Unix(), workloadName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pod-scaler warnings: %w", err)
+	}
+	defer rows.Close()
+	ret := []podScalerWarningCount{}
+	for rows.Next() {
+		var warning podScalerWarningCount
+		var last int64
+		if err := rows.Scan(&warning.WorkloadName, &warning.WorkloadType, &warning.ResourceType, &warning.Count, &last, &warning.ConfiguredAmount, &warning.DeterminedAmount); err != nil {
+			return nil, err
+		}
+		warning.Last = time.Unix(last, 0).UTC()
+		ret = append(ret, warning)
+	}
+	return ret, rows.Err()
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/openshift/ci-tools/pkg/results"
+)
+
+func newTestEventStore(t *testing.T, now *time.Time) *eventStore {
+	store, err := openEventStore(filepath.Join(t.TempDir(), "events.db"))
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+	store.now = func() time.Time { return *now }
+	return store
+}
+
+func TestEventStore(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	now := start
+	store := newTestEventStore(t, &now)
+
+	failure := func(repo, step, reason string) *results.Request {
+		return &results.Request{JobName: "pull-ci-org-" + repo + "-main-e2e", Type: "presubmit", Cluster: "build01", State: results.StateFailed, Reason: reason, Org: "org", Repo: repo, Branch: "main", Step: step}
+	}
+	for _, event := range []struct {
+		after   time.Duration
+		request *results.Request
+	}{
+		{after: 0, request: failure("a", "e2e", "executing_graph:step_failed:pod_failed")},
+		{after: 10 * time.Minute, request: failure("a", "e2e", "executing_graph:step_failed:pod_failed")},
+		{after: 20 * time.Minute, request: failure("a", "e2e", "executing_graph:step_failed:pod_pending")},
+		{after: 30 * time.Minute, request: failure("b", "images", "executing_graph:step_failed:building_image")},
+		{after: 70 * time.Minute, request: failure("a", "e2e", "executing_graph:step_failed:pod_failed")},
+		{after: 70 * time.Minute, request: failure("b", "", "loading_config")},
+		{after: 80 * time.Minute, request: &results.Request{JobName: "pull-ci-org-a-main-e2e", Type: "presubmit", Cluster: "build01", State: results.StateSucceeded, Reason: "unknown", Org: "org", Repo: "a", Branch: "main"}},
+	} {
+		now = start.Add(event.after)
+		if err := store.recordResult(ctx, event.request); err != nil {
+			t.Fatalf("failed to record result: %v", err)
+		}
+	}
+	for i, warning := range []results.PodScalerRequest{
+		{WorkloadName: "org-a-main-e2e", WorkloadType: "step", ConfiguredAmount: "1Gi", DeterminedAmount: "2Gi", ResourceType: "memory"},
+		{WorkloadName: "org-a-main-e2e", WorkloadType: "step", ConfiguredAmount: "1Gi", DeterminedAmount: "3Gi", ResourceType: "memory"},
+		{WorkloadName: "org-b-main-images", WorkloadType: "build", ConfiguredAmount: "1", DeterminedAmount: "2", ResourceType: "cpu"},
+	} {
+		now = start.Add(time.Duration(i) * time.Minute)
+		if err := store.recordPodScalerWarning(ctx, &warning); err != nil {
+			t.Fatalf("failed to record pod-scaler warning: %v", err)
+		}
+	}
+	now = start.Add(2 * time.Hour)
+
+	reasons, err := store.errorReasons(ctx, resultFilter{Repo: "a"}, start, time.Hour)
+	if err != nil {
+		t.Fatalf("failed to query reasons: %v", err)
+	}
+	expectedReasons := []reasonCount{
+		{Start: start, Org: "org", Repo: "a", Branch: "main", Step: "e2e", Reason: "executing_graph:step_failed:pod_failed", Count: 2},
+		{Start: start, Org: "org", Repo: "a", Branch: "main", Step: "e2e", Reason: "executing_graph:step_failed:pod_pending", Count: 1},
+		{Start: start.Add(time.Hour), Org: "org", Repo: "a", Branch: "main", Step: "e2e", Reason: "executing_graph:step_failed:pod_failed", Count: 1},
+	}
+	if diff := cmp.Diff(expectedReasons, reasons); diff != "" {
+		t.Errorf("unexpected reasons (-want +got):\n%s", diff)
+	}
+
+	reasons, err = store.errorReasons(ctx, resultFilter{Reason: "loading"}, start, 24*time.Hour)
+	if err != nil {
+		t.Fatalf("failed to query reasons: %v", err)
+	}
+	if len(reasons) != 1 || reasons[0].Reason != "loading_config" {
+		t.Errorf("expected the reason prefix to select loading_config, got %v", reasons)
+	}
+
+	steps, err := store.topFailingSteps(ctx, resultFilter{}, start, 10)
+	if err != nil {
+		t.Fatalf("failed to query steps: %v", err)
+	}
+	expectedSteps := []stepFailures{
+		{Org: "org", Repo: "a", Branch: "main", Step: "e2e", Failures: 4, Reason: "executing_graph:step_failed:pod_failed"},
+		{Org: "org", Repo: "b", Branch: "main", Step: "images", Failures: 1, Reason: "executing_graph:step_failed:building_image"},
+	}
+	if diff := cmp.Diff(expectedSteps, steps); diff != "" {
+		t.Errorf("unexpected steps (-want +got):\n%s", diff)
+	}
+
+	warnings, err := store.podScalerWarnings(ctx, "org-a", start)
+	if err != nil {
+		t.Fatalf("failed to query warnings: %v", err)
+	}
+	expectedWarnings := []podScalerWarningCount{
+		{WorkloadName: "org-a-main-e2e", WorkloadType: "step", ResourceType: "memory", Count: 2, ConfiguredAmount: "1Gi", DeterminedAmount: "3Gi", Last: start.Add(time.Minute)},
+	}
+	if diff := cmp.Diff(expectedWarnings, warnings); diff != "" {
+		t.Errorf("unexpected warnings (-want +got):\n%s", diff)
+	}
+
+	now = start.Add(time.Hour + 35*time.Minute)
+	deleted, err := store.prune(ctx, time.Hour)
+	if err != nil {
+		t.Fatalf("failed to prune: %v", err)
+	}
+	if deleted != 7 {
+		t.Errorf("expected the 4 results and 3 warnings older than an hour to be pruned, got %d", deleted)
+	}
+}
+
+func TestFailureSignatures(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	now := start
+	store := newTestEventStore(t, &now)
+
+	failure := func(job, message string) *results.Request {
+		return &results.Request{JobName: job, Type: "periodic", Cluster: "build01", State: results.StateFailed, Reason: "step_failed", Signature: results.Signature(message), SignatureMessage: results.NormalizeMessage(message)}
+	}
+	for _, event := range []struct {
+		after   time.Duration
+		request *results.Request
+	}{
+		{after: 0, request: failure("a", `pod ci-op-abcde/setup-12345 failed`)},
+		{after: 24 * time.Hour, request: failure("b", `pod ci-op-fghij/setup-67890 failed`)},
+		{after: 25 * time.Hour, request: failure("a", `failed to pull image sha256:`+strings.Repeat("a", 64))},
+		{after: 26 * time.Hour, request: &results.Request{JobName: "c", Type: "periodic", Cluster: "build01", State: results.StateFailed, Reason: "loading_config"}},
+	} {
+		now = start.Add(event.after)
+		if err := store.recordResult(ctx, event.request); err != nil {
+			t.Fatalf("failed to record result: %v", err)
+		}
+	}
+	since := start.Add(12 * time.Hour)
+
+	signatures, err := store.failureSignatures(ctx, resultFilter{}, since, false, 10)
+	if err != nil {
+		t.Fatalf("failed to query signatures: %v", err)
+	}
+	expected := []signatureCount{
+		{Signature: results.Signature("pod ci-op-abcde/setup-12345 failed"), Message: "pod <namespace>/setup-<n> failed", Reason: "step_failed", Count: 1, Jobs: 1, FirstSeen: start, LastSeen: start.Add(24 * time.Hour)},
+		{Signature: results.Signature("failed to pull image sha256:" + strings.Repeat("a", 64)), Message: "failed to pull image sha256:<digest>", Reason: "step_failed", Count: 1, Jobs: 1, FirstSeen: start.Add(25 * time.Hour), LastSeen: start.Add(25 * time.Hour)},
+	}
+	if expected[0].Signature > expected[1].Signature {
+		expected[0], expected[1] = expected[1], expected[0]
+	}
+	if diff := cmp.Diff(expected, signatures); diff != "" {
+		t.Errorf("unexpected signatures (-want +got):\n%s", diff)
+	}
+
+	signatures, err = store.failureSignatures(ctx, resultFilter{}, since, true, 10)
+	if err != nil {
+		t.Fatalf("failed to query new signatures: %v", err)
+	}
+	if len(signatures) != 1 || signatures[0].Message != "failed to pull image sha256:<digest>" {
+		t.Errorf("expected only the image pull failure to be new, got %v", signatures)
+	}
+}
+
+func TestQueryHandlers(t *testing.T) {
+	now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	store := newTestEventStore(t, &now)
+	if err := store.recordResult(context.Background(), &results.Request{JobName: "job", Type: "periodic", Cluster: "build01", State: results.StateFailed, Reason: "step_failed", Org: "org", Repo: "repo", Branch: "main", Step: "e2e", Signature: "0123456789abcdef", SignatureMessage: "pod failed"}); err != nil {
+		t.Fatalf("failed to record result: %v", err)
+	}
+
+	testCases := []struct {
+		name         string
+		handler      http.HandlerFunc
+		query        string
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name:         "top steps",
+			handler:      handleTopFailingSteps(store),
+			query:        "?since=1h&org=org",
+			expectedCode: http.StatusOK,
+			expectedBody: `[{"org":"org","repo":"repo","branch":"main","step":"e2e","failures":1,"reason":"step_failed"}]`,
+		},
+		{
+			name:         "reasons of another repo",
+			handler:      handleErrorReasons(store),
+			query:        "?repo=other",
+			expectedCode: http.StatusOK,
+			expectedBody: `[]`,
+		},
+		{
+			name:         "new signatures",
+			handler:      handleFailureSignatures(store),
+			query:        "?new=true",
+			expectedCode: http.StatusOK,
+			expectedBody: `[{"signature":"0123456789abcdef","message":"pod failed","reason":"step_failed","count":1,"jobs":1,"first_seen":"2024-05-01T10:00:00Z","last_seen":"2024-05-01T10:00:00Z"}]`,
+		},
+		{
+			name:         "invalid new",
+			handler:      handleFailureSignatures(store),
+			query:        "?new=maybe",
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `new must be true or false, got &#34;maybe&#34;`,
+		},
+		{
+			name:         "invalid since",
+			handler:      handleErrorReasons(store),
+			query:        "?since=yesterday",
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `since must be a positive duration like 24h, got &#34;yesterday&#34;`,
+		},
+		{
+			name:         "ui",
+			handler:      handleUI(store),
+			expectedCode: http.StatusOK,
+			expectedBody: `<td>e2e</td><td>1</td><td>step_failed</td>`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			tc.handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/"+tc.query, nil))
+			if rr.Code != tc.expectedCode {
+				t.Errorf("expected code %d, got %d", tc.expectedCode, rr.Code)
+			}
+			body := rr.Body.String()
+			if json.Valid([]byte(tc.expectedBody)) {
+				if diff := cmp.Diff(tc.expectedBody, body); diff != "" {
+					t.Errorf("unexpected body (-want +got):\n%s", diff)
+				}
+			} else if !strings.Contains(body, tc.expectedBody) {
+				t.Errorf("expected body to contain %q, got %q", tc.expectedBody, body)
+			}
+		})
+	}
+}
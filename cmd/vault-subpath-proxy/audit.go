@@ -0,0 +1,451 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/hashicorp/vault/sdk/helper/consts"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
+)
+
+var (
+	requestsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "vault_subpath_proxy_requests_total",
+			Help: "number of proxied requests, sorted by operation, status code and whether a 403 was rewritten",
+		},
+		[]string{"operation", "code", "rewritten"},
+	)
+	requestDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "vault_subpath_proxy_request_duration_seconds",
+			Help:    "duration of proxied requests, sorted by operation",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"operation"},
+	)
+	rateLimitedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "vault_subpath_proxy_rate_limited_total",
+			Help: "number of requests rejected by a rate limit, sorted by limit",
+		},
+		[]string{"limit"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(requestsTotal, requestDuration, rateLimitedTotal)
+}
+
+const (
+	anonymousIdentity = "anonymous"
+	unknownIdentity   = "unknown"
+	// unresolvedIdentity is logged for requests that were rejected before their token was looked up
+	unresolvedIdentity = "unresolved"
+
+	// identityCacheTTL is how long the identity behind a token is cached, to not look it up on every request
+	identityCacheTTL = 5 * time.Minute
+	// maxCachedIdentities bounds the identity cache, so that requests with many different tokens can not grow it without limit
+	maxCachedIdentities = 10000
+	// limiterIdleTimeout is how long the limiter of a client or an identity is kept after its last request
+	limiterIdleTimeout = 10 * time.Minute
+)
+
+// identity is the owner of a Vault token, as returned by the token lookup
+type identity struct {
+	DisplayName string
+	EntityID    string
+}
+
+// key identifies the identity for rate limiting
+func (i identity) key() string {
+	if i.EntityID != "" {
+		return i.EntityID
+	}
+	return i.DisplayName
+}
+
+type cachedIdentity struct {
+	identity identity
+	expires  time.Time
+}
+
+// identityResolver looks up the identity behind tokens and caches them by their hash
+type identityResolver struct {
+	lookup func(token string) (identity, error)
+	now    func() time.Time
+
+	lock  sync.Mutex
+	cache map[string]cachedIdentity
+}
+
+func newIdentityResolver(vaultClient *api.Client) *identityResolver {
+	return &identityResolver{
+		lookup: func(token string) (identity, error) {
+			client, err := vaultClient.Clone()
+			if err != nil {
+				return identity{}, fmt.Errorf("failed to clone vault client: %w", err)
+			}
+			client.SetToken(token)
+			secret, err := client.Auth().Token().LookupSelf()
+			if err != nil {
+				return identity{}, err
+			}
+			if secret == nil {
+				return identity{}, fmt.Errorf("token lookup returned no data")
+			}
+			displayName, _ := secret.Data["display_name"].(string)
+			entityID, _ := secret.Data["entity_id"].(string)
+			return identity{DisplayName: displayName, EntityID: entityID}, nil
+		},
+		now:   time.Now,
+		cache: map[string]cachedIdentity{},
+	}
+}
+
+func (r *identityResolver) resolve(token string) identity {
+	if token == "" {
+		return identity{DisplayName: anonymousIdentity}
+	}
+	hash := sha256.Sum256([]byte(token))
+	key := hex.EncodeToString(hash[:])
+	now := r.now()
+	r.lock.Lock()
+	cached, ok := r.cache[key]
+	r.lock.Unlock()
+	if ok && now.Before(cached.expires) {
+		return cached.identity
+	}
+	resolved, err := r.lookup(token)
+	if err != nil {
+		// invalid tokens are cached as well, so they do not cause a lookup on every request
+		logrus.WithError(err).Debug("Failed to look up token")
+		resolved = identity{DisplayName: unknownIdentity}
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for k, v := range r.cache {
+		if !now.Before(v.expires) {
+			delete(r.cache, k)
+		}
+	}
+	for k := range r.cache {
+		if len(r.cache) < maxCachedIdentities {
+			break
+		}
+		delete(r.cache, k)
+	}
+	r.cache[key] = cachedIdentity{identity: resolved, expires: now.Add(identityCacheTTL)}
+	return resolved
+}
+
+// pathRateLimit limits the requests to all paths under the prefix, which is a Vault path like secret/data/team
+type pathRateLimit struct {
+	prefix  string
+	limiter *rate.Limiter
+}
+
+type keyedLimiterEntry struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// keyedLimiter limits the requests of every key, like an identity or a client address, separately
+type keyedLimiter struct {
+	limit rate.Limit
+	burst int
+	now   func() time.Time
+
+	lock      sync.Mutex
+	limiters  map[string]*keyedLimiterEntry
+	lastPrune time.Time
+}
+
+func newKeyedLimiter(limit float64, burst int) *keyedLimiter {
+	return &keyedLimiter{
+		limit:    rate.Limit(limit),
+		burst:    burst,
+		now:      time.Now,
+		limiters: map[string]*keyedLimiterEntry{},
+	}
+}
+
+// allow determines whether the key may make another request. A zero limit allows all requests.
+func (l *keyedLimiter) allow(key string) bool {
+	if l.limit <= 0 {
+		return true
+	}
+	now := l.now()
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if now.Sub(l.lastPrune) > time.Minute {
+		for key, limiter := range l.limiters {
+			if now.Sub(limiter.lastSeen) > limiterIdleTimeout {
+				delete(l.limiters, key)
+			}
+		}
+		l.lastPrune = now
+	}
+	limiter, ok := l.limiters[key]
+	if !ok {
+		limiter = &keyedLimiterEntry{limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.limiters[key] = limiter
+	}
+	limiter.lastSeen = now
+	return limiter.limiter.AllowN(now, 1)
+}
+
+// rateLimiter limits the requests of every client address and every identity, and the requests
+// to paths across all identities
+type rateLimiter struct {
+	clients    *keyedLimiter
+	identities *keyedLimiter
+	paths      []pathRateLimit
+}
+
+// newRateLimiter creates a limiter. A zero client or identity limit disables the per-client or per-identity limit;
+// path limits are given as prefix=requests-per-second.
+func newRateLimiter(clientLimit float64, clientBurst int, identityLimit float64, identityBurst int, pathLimits []string) (*rateLimiter, error) {
+	limiter := &rateLimiter{
+		clients:    newKeyedLimiter(clientLimit, clientBurst),
+		identities: newKeyedLimiter(identityLimit, identityBurst),
+	}
+	for _, pathLimit := range pathLimits {
+		prefix, rawLimit, ok := strings.Cut(pathLimit, "=")
+		if !ok || prefix == "" {
+			return nil, fmt.Errorf("invalid path rate limit %q, must be prefix=requests-per-second", pathLimit)
+		}
+		limit, err := strconv.ParseFloat(rawLimit, 64)
+		if err != nil || limit <= 0 {
+			return nil, fmt.Errorf("invalid path rate limit %q, the limit must be a positive number", pathLimit)
+		}
+		limiter.paths = append(limiter.paths, pathRateLimit{
+			prefix:  strings.Trim(prefix, "/"),
+			limiter: rate.NewLimiter(rate.Limit(limit), int(math.Max(1, math.Ceil(limit)))),
+		})
+	}
+	// the most specific prefix applies
+	sort.SliceStable(limiter.paths, func(i, j int) bool {
+		return len(limiter.paths[i].prefix) > len(limiter.paths[j].prefix)
+	})
+	return limiter, nil
+}
+
+// allowClient determines whether the client address may make another request. It is checked before
+// the identity is resolved, so that clients can not cause a token lookup for every request by sending
+// a new token every time.
+func (l *rateLimiter) allowClient(address string) bool {
+	if l == nil {
+		return true
+	}
+	return l.clients.allow(address)
+}
+
+// allow determines whether the identity may request the path, and names the limit that was hit otherwise
+func (l *rateLimiter) allow(identityKey, path string) (bool, string) {
+	if l == nil {
+		return true, ""
+	}
+	path = strings.TrimPrefix(path, "/v1/")
+	for _, pathLimit := range l.paths {
+		if path != pathLimit.prefix && !strings.HasPrefix(path, pathLimit.prefix+"/") {
+			continue
+		}
+		if !pathLimit.limiter.Allow() {
+			return false, pathLimit.prefix
+		}
+		break
+	}
+	if !l.identities.allow(identityKey) {
+		return false, "identity"
+	}
+	return true, ""
+}
+
+// auditRecord is shared through the request context, so that the response modifiers
+// can record what they did to the response
+type auditRecord struct {
+	lock      sync.Mutex
+	rewritten bool
+}
+
+type auditRecordKey struct{}
+
+func markRewritten(ctx context.Context) {
+	if record, ok := ctx.Value(auditRecordKey{}).(*auditRecord); ok {
+		record.lock.Lock()
+		record.rewritten = true
+		record.lock.Unlock()
+	}
+}
+
+// auditHandler writes an audit log entry for every request, enforces the rate limits and records metrics
+type auditHandler struct {
+	next       http.Handler
+	identities *identityResolver
+	limiter    *rateLimiter
+	// trustedProxies are the proxies whose X-Forwarded-For headers determine the client address
+	trustedProxies []*net.IPNet
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap allows the reverse proxy to flush the underlying writer
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// parseTrustedProxies parses the addresses and CIDRs of the proxies whose X-Forwarded-For headers are trusted
+func parseTrustedProxies(values []string) ([]*net.IPNet, error) {
+	var proxies []*net.IPNet
+	for _, value := range values {
+		if !strings.Contains(value, "/") {
+			ip := net.ParseIP(value)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q: not an IP address or CIDR", value)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			proxies = append(proxies, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", value, err)
+		}
+		proxies = append(proxies, network)
+	}
+	return proxies, nil
+}
+
+func isTrustedProxy(address string, trustedProxies []*net.IPNet) bool {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return false
+	}
+	for _, network := range trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientAddress is the address the request was sent from, without its port. Requests passed on by
+// trusted proxies are attributed to the last address in their X-Forwarded-For header that is not a
+// trusted proxy, the addresses before it are set by the client and can not be relied on.
+func clientAddress(r *http.Request, trustedProxies []*net.IPNet) string {
+	client, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		client = r.RemoteAddr
+	}
+	if !isTrustedProxy(client, trustedProxies) {
+		return client
+	}
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+		client = hop
+		if !isTrustedProxy(hop, trustedProxies) {
+			break
+		}
+	}
+	return client
+}
+
+func (h *auditHandler) rejectRateLimited(w http.ResponseWriter, log *logrus.Entry, limit string) {
+	rateLimitedTotal.WithLabelValues(limit).Inc()
+	log.WithFields(logrus.Fields{"status-code": http.StatusTooManyRequests, "rate-limit": limit}).Warn("Rejected request: rate limit exceeded")
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Retry-After", "1")
+	w.WriteHeader(http.StatusTooManyRequests)
+	if err := json.NewEncoder(w).Encode(errorResponse{Errors: []string{"rate limit exceeded, please slow down"}}); err != nil {
+		log.WithError(err).Debug("Failed to write response")
+	}
+}
+
+// operation describes what the request does in Vault terms
+func operation(r *http.Request) string {
+	switch r.Method {
+	case http.MethodGet:
+		if r.URL.Query().Get("list") == "true" {
+			return "list"
+		}
+		return "read"
+	case "LIST":
+		return "list"
+	case http.MethodPut, http.MethodPost:
+		return "write"
+	default:
+		return strings.ToLower(r.Method)
+	}
+}
+
+func (h *auditHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	op := operation(r)
+	client := clientAddress(r, h.trustedProxies)
+	log := logrus.WithFields(logrus.Fields{
+		"audit":     true,
+		"client":    client,
+		"method":    r.Method,
+		"path":      r.URL.Path,
+		"operation": op,
+	})
+
+	if !h.limiter.allowClient(client) {
+		// the identity is not resolved for rejected requests, as that is what the limit protects
+		h.rejectRateLimited(w, log.WithFields(logrus.Fields{"identity": unresolvedIdentity, "entity-id": ""}), "client")
+		return
+	}
+
+	who := h.identities.resolve(r.Header.Get(consts.AuthHeaderName))
+	log = log.WithFields(logrus.Fields{"identity": who.DisplayName, "entity-id": who.EntityID})
+	if allowed, limit := h.limiter.allow(who.key(), r.URL.Path); !allowed {
+		h.rejectRateLimited(w, log, limit)
+		return
+	}
+
+	record := &auditRecord{}
+	recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	h.next.ServeHTTP(recorder, r.WithContext(context.WithValue(r.Context(), auditRecordKey{}, record)))
+
+	record.lock.Lock()
+	rewritten := record.rewritten
+	record.lock.Unlock()
+	duration := time.Since(start)
+	requestsTotal.WithLabelValues(op, strconv.Itoa(recorder.status), strconv.FormatBool(rewritten)).Inc()
+	requestDuration.WithLabelValues(op).Observe(duration.Seconds())
+	log.WithFields(logrus.Fields{
+		"status-code": recorder.status,
+		"rewritten":   rewritten,
+		"duration":    duration.String(),
+	}).Info("Proxied request")
+}
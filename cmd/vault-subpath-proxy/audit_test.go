@@ -0,0 +1,378 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/vault/sdk/helper/consts"
+	"github.com/sirupsen/logrus/hooks/test"
+
+	"github.com/openshift/ci-tools/pkg/testhelper"
+)
+
+// fakeVault answers token lookups, the resultant-acl api and denies listing the root of the kv store
+func fakeVault(t *testing.T) *httptest.Server {
+	identities := map[string]map[string]interface{}{
+		"team-1-token": {"display_name": "oidc-team-1", "entity_id": "entity-1"},
+		"team-2-token": {"display_name": "oidc-team-2", "entity_id": "entity-2"},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := r.Header.Get(consts.AuthHeaderName)
+		var response interface{}
+		switch {
+		case r.URL.Path == "/v1/auth/token/lookup-self":
+			data, ok := identities[token]
+			if !ok {
+				w.WriteHeader(http.StatusForbidden)
+				response = errorResponse{Errors: []string{"permission denied"}}
+				break
+			}
+			response = map[string]interface{}{"data": data}
+		case r.URL.Path == "/v1/sys/internal/ui/resultant-acl":
+			response = resultantACLResponse{Data: ResultantACLData{GlobPaths: map[string]PathPerms{
+				"secret/metadata/team-1/": {Capabilities: []string{"list"}},
+			}}}
+		case r.URL.Path == "/v1/secret/metadata" && r.URL.Query().Get("list") == "true":
+			w.WriteHeader(http.StatusForbidden)
+			response = errorResponse{Errors: []string{}}
+		default:
+			response = map[string]interface{}{"data": map[string]interface{}{}}
+		}
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			t.Errorf("failed to write response: %v", err)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestAuditHandler(t *testing.T) {
+	vault := fakeVault(t)
+	limiter, err := newRateLimiter(0, 0, 0, 0, []string{"secret/data/limited=1"})
+	if err != nil {
+		t.Fatalf("failed to create limiter: %v", err)
+	}
+	server, err := createProxyServer(vault.URL, "", "secret", nil, nil, false, limiter, nil)
+	if err != nil {
+		t.Fatalf("failed to create proxy server: %v", err)
+	}
+	proxy := httptest.NewServer(server.Handler)
+	defer proxy.Close()
+
+	hook := test.NewGlobal()
+	defer hook.Reset()
+
+	for _, request := range []struct {
+		method, path, token string
+		expectedStatus      int
+	}{
+		{method: http.MethodGet, path: "/v1/secret/metadata?list=true", token: "team-1-token", expectedStatus: http.StatusOK},
+		{method: http.MethodGet, path: "/v1/secret/data/team-2/secret", token: "team-2-token", expectedStatus: http.StatusOK},
+		{method: http.MethodDelete, path: "/v1/secret/data/team-2/secret", token: "invalid", expectedStatus: http.StatusOK},
+		{method: http.MethodGet, path: "/v1/secret/data/limited/a", expectedStatus: http.StatusOK},
+		{method: http.MethodGet, path: "/v1/secret/data/limited/b", token: "team-1-token", expectedStatus: http.StatusTooManyRequests},
+	} {
+		req, err := http.NewRequest(request.method, proxy.URL+request.path, nil)
+		if err != nil {
+			t.Fatalf("failed to create request: %v", err)
+		}
+		if request.token != "" {
+			req.Header.Set(consts.AuthHeaderName, request.token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != request.expectedStatus {
+			t.Errorf("%s %s: expected status %d, got %d", request.method, request.path, request.expectedStatus, resp.StatusCode)
+		}
+	}
+
+	type entry struct {
+		Identity, EntityID, Method, Path, Operation string
+		Status                                      int
+		Rewritten                                   bool
+		RateLimit                                   string
+	}
+	var entries []entry
+	for _, e := range hook.AllEntries() {
+		if e.Data["audit"] != true {
+			continue
+		}
+		audited := entry{
+			Identity:  e.Data["identity"].(string),
+			EntityID:  e.Data["entity-id"].(string),
+			Method:    e.Data["method"].(string),
+			Path:      e.Data["path"].(string),
+			Operation: e.Data["operation"].(string),
+			Status:    e.Data["status-code"].(int),
+		}
+		if rewritten, ok := e.Data["rewritten"].(bool); ok {
+			audited.Rewritten = rewritten
+		}
+		if limit, ok := e.Data["rate-limit"].(string); ok {
+			audited.RateLimit = limit
+		}
+		entries = append(entries, audited)
+	}
+	expected := []entry{
+		{Identity: "oidc-team-1", EntityID: "entity-1", Method: "GET", Path: "/v1/secret/metadata", Operation: "list", Status: 200, Rewritten: true},
+		{Identity: "oidc-team-2", EntityID: "entity-2", Method: "GET", Path: "/v1/secret/data/team-2/secret", Operation: "read", Status: 200},
+		{Identity: "unknown", Method: "DELETE", Path: "/v1/secret/data/team-2/secret", Operation: "delete", Status: 200},
+		{Identity: "anonymous", Method: "GET", Path: "/v1/secret/data/limited/a", Operation: "read", Status: 200},
+		{Identity: "oidc-team-1", EntityID: "entity-1", Method: "GET", Path: "/v1/secret/data/limited/b", Operation: "read", Status: 429, RateLimit: "secret/data/limited"},
+	}
+	if diff := cmp.Diff(expected, entries); diff != "" {
+		t.Errorf("unexpected audit entries: %s", diff)
+	}
+}
+
+func TestAuditHandlerLimitsClientsBeforeLookups(t *testing.T) {
+	lookups := 0
+	limiter, err := newRateLimiter(0.001, 2, 0, 0, nil)
+	if err != nil {
+		t.Fatalf("failed to create limiter: %v", err)
+	}
+	handler := &auditHandler{
+		next: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+		identities: &identityResolver{
+			lookup: func(token string) (identity, error) {
+				lookups++
+				return identity{}, errors.New("permission denied")
+			},
+			now:   time.Now,
+			cache: map[string]cachedIdentity{},
+		},
+		limiter: limiter,
+	}
+
+	for i, request := range []struct {
+		remoteAddr     string
+		expectedStatus int
+	}{
+		{remoteAddr: "10.0.0.1:1234", expectedStatus: http.StatusOK},
+		{remoteAddr: "10.0.0.1:1235", expectedStatus: http.StatusOK},
+		{remoteAddr: "10.0.0.1:1236", expectedStatus: http.StatusTooManyRequests},
+		{remoteAddr: "10.0.0.1:1237", expectedStatus: http.StatusTooManyRequests},
+		{remoteAddr: "10.0.0.2:1234", expectedStatus: http.StatusOK},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/v1/secret/data/team-1/secret", nil)
+		req.RemoteAddr = request.remoteAddr
+		req.Header.Set(consts.AuthHeaderName, fmt.Sprintf("bogus-token-%d", i))
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+		if recorder.Code != request.expectedStatus {
+			t.Errorf("request %d from %s: expected status %d, got %d", i, request.remoteAddr, request.expectedStatus, recorder.Code)
+		}
+	}
+	if lookups != 3 {
+		t.Errorf("expected only the tokens of allowed requests to be looked up, got %d lookups", lookups)
+	}
+}
+
+func TestClientAddress(t *testing.T) {
+	trustedProxies, err := parseTrustedProxies([]string{"10.128.0.0/14", "192.168.0.1"})
+	if err != nil {
+		t.Fatalf("failed to parse the trusted proxies: %v", err)
+	}
+	testCases := []struct {
+		name           string
+		remoteAddr     string
+		forwardedFor   []string
+		trustedProxies []*net.IPNet
+		expected       string
+	}{
+		{
+			name:       "direct request",
+			remoteAddr: "10.0.0.1:1234",
+			expected:   "10.0.0.1",
+		},
+		{
+			name:           "forwarded header of an untrusted client is ignored",
+			remoteAddr:     "10.0.0.1:1234",
+			forwardedFor:   []string{"1.2.3.4"},
+			trustedProxies: trustedProxies,
+			expected:       "10.0.0.1",
+		},
+		{
+			name:         "forwarded header is ignored without trusted proxies",
+			remoteAddr:   "10.128.0.5:1234",
+			forwardedFor: []string{"1.2.3.4"},
+			expected:     "10.128.0.5",
+		},
+		{
+			name:           "request passed on by a trusted proxy",
+			remoteAddr:     "10.128.0.5:1234",
+			forwardedFor:   []string{"1.2.3.4"},
+			trustedProxies: trustedProxies,
+			expected:       "1.2.3.4",
+		},
+		{
+			name:           "addresses set by the client are skipped",
+			remoteAddr:     "10.128.0.5:1234",
+			forwardedFor:   []string{"6.6.6.6, 1.2.3.4", "192.168.0.1"},
+			trustedProxies: trustedProxies,
+			expected:       "1.2.3.4",
+		},
+		{
+			name:           "request from a trusted proxy without forwarded header",
+			remoteAddr:     "10.128.0.5:1234",
+			trustedProxies: trustedProxies,
+			expected:       "10.128.0.5",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/v1/secret/data/team-1/secret", nil)
+			req.RemoteAddr = tc.remoteAddr
+			for _, value := range tc.forwardedFor {
+				req.Header.Add("X-Forwarded-For", value)
+			}
+			if actual := clientAddress(req, tc.trustedProxies); actual != tc.expected {
+				t.Errorf("expected client address %s, got %s", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	if _, err := parseTrustedProxies([]string{"router"}); err == nil {
+		t.Error("expected an error for a trusted proxy that is not an address")
+	}
+	if _, err := parseTrustedProxies([]string{"10.0.0.0/33"}); err == nil {
+		t.Error("expected an error for an invalid CIDR")
+	}
+	proxies, err := parseTrustedProxies([]string{"10.0.0.1", "fd00::1", "10.128.0.0/14"})
+	if err != nil {
+		t.Fatalf("failed to parse the trusted proxies: %v", err)
+	}
+	for address, expected := range map[string]bool{"10.0.0.1": true, "10.0.0.2": false, "fd00::1": true, "10.129.3.4": true} {
+		if actual := isTrustedProxy(address, proxies); actual != expected {
+			t.Errorf("%s: expected trusted %t, got %t", address, expected, actual)
+		}
+	}
+}
+
+func TestIdentityResolver(t *testing.T) {
+	lookups := 0
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	resolver := &identityResolver{
+		lookup: func(token string) (identity, error) {
+			lookups++
+			if token == "invalid" {
+				return identity{}, errors.New("permission denied")
+			}
+			return identity{DisplayName: "user-" + token}, nil
+		},
+		now:   func() time.Time { return now },
+		cache: map[string]cachedIdentity{},
+	}
+
+	for _, step := range []struct {
+		token           string
+		advance         time.Duration
+		expected        identity
+		expectedLookups int
+	}{
+		{token: "", expected: identity{DisplayName: "anonymous"}},
+		{token: "a", expected: identity{DisplayName: "user-a"}, expectedLookups: 1},
+		{token: "a", expected: identity{DisplayName: "user-a"}, expectedLookups: 1},
+		{token: "invalid", expected: identity{DisplayName: "unknown"}, expectedLookups: 2},
+		{token: "invalid", expected: identity{DisplayName: "unknown"}, expectedLookups: 2},
+		{token: "a", advance: identityCacheTTL, expected: identity{DisplayName: "user-a"}, expectedLookups: 3},
+	} {
+		now = now.Add(step.advance)
+		if diff := cmp.Diff(step.expected, resolver.resolve(step.token)); diff != "" {
+			t.Errorf("unexpected identity for token %q: %s", step.token, diff)
+		}
+		if lookups != step.expectedLookups {
+			t.Errorf("expected %d lookups after resolving token %q, got %d", step.expectedLookups, step.token, lookups)
+		}
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	testCases := []struct {
+		name          string
+		identityLimit float64
+		identityBurst int
+		pathLimits    []string
+		requests      []struct{ identity, path string }
+		expected      []string
+		expectedError error
+	}{
+		{
+			name: "no limits",
+			requests: []struct{ identity, path string }{
+				{identity: "a", path: "/v1/secret/data/x"},
+				{identity: "a", path: "/v1/secret/data/x"},
+			},
+			expected: []string{"", ""},
+		},
+		{
+			name:          "identities are limited separately",
+			identityLimit: 0.001,
+			identityBurst: 2,
+			requests: []struct{ identity, path string }{
+				{identity: "a", path: "/v1/secret/data/x"},
+				{identity: "a", path: "/v1/secret/data/y"},
+				{identity: "b", path: "/v1/secret/data/x"},
+				{identity: "a", path: "/v1/secret/data/x"},
+			},
+			expected: []string{"", "", "", "identity"},
+		},
+		{
+			name:       "the longest matching prefix applies",
+			pathLimits: []string{"secret/data=1", "/secret/data/team/=2"},
+			requests: []struct{ identity, path string }{
+				{identity: "a", path: "/v1/secret/data/team/x"},
+				{identity: "b", path: "/v1/secret/data/team/y"},
+				{identity: "c", path: "/v1/secret/data/team/z"},
+				{identity: "a", path: "/v1/secret/data/other"},
+				{identity: "a", path: "/v1/secret/data/other"},
+				{identity: "a", path: "/v1/secret/database"},
+			},
+			expected: []string{"", "", "secret/data/team", "", "secret/data", ""},
+		},
+		{
+			name:          "invalid path limit",
+			pathLimits:    []string{"secret/data"},
+			expectedError: errors.New(`invalid path rate limit "secret/data", must be prefix=requests-per-second`),
+		},
+		{
+			name:          "invalid limit",
+			pathLimits:    []string{"secret/data=0"},
+			expectedError: errors.New(`invalid path rate limit "secret/data=0", the limit must be a positive number`),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			limiter, err := newRateLimiter(0, 0, tc.identityLimit, tc.identityBurst, tc.pathLimits)
+			if diff := cmp.Diff(tc.expectedError, err, testhelper.EquateErrorMessage); diff != "" {
+				t.Fatalf("unexpected error: %s", diff)
+			}
+			if err != nil {
+				return
+			}
+			var limits []string
+			for _, request := range tc.requests {
+				allowed, limit := limiter.allow(request.identity, request.path)
+				if allowed != (limit == "") {
+					t.Errorf("request was allowed=%t, but limit %q was hit", allowed, limit)
+				}
+				limits = append(limits, limit)
+			}
+			if diff := cmp.Diff(tc.expected, limits); diff != "" {
+				t.Errorf("unexpected limits: %s", diff)
+			}
+		})
+	}
+}
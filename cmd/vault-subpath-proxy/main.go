@@ -8,6 +8,7 @@ import (
 	"flag"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
@@ -23,8 +24,10 @@ import (
 	"github.com/sirupsen/logrus"
 
 	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/prow/pkg/config"
 	"sigs.k8s.io/prow/pkg/flagutil"
 	"sigs.k8s.io/prow/pkg/logrusutil"
+	"sigs.k8s.io/prow/pkg/metrics"
 	"sigs.k8s.io/prow/pkg/version"
 
 	"github.com/openshift/ci-tools/pkg/vaultclient"
@@ -40,6 +43,13 @@ type options struct {
 	vaultToken        string
 	vaultRole         string
 	readOnly          bool
+	metricsPort       int
+	clientRateLimit   float64
+	clientRateBurst   int
+	identityRateLimit float64
+	identityRateBurst int
+	pathRateLimits    flagutil.Strings
+	trustedProxies    flagutil.Strings
 }
 
 func gatherOptions() (*options, error) {
@@ -54,6 +64,13 @@ func gatherOptions() (*options, error) {
 	fs.StringVar(&o.vaultToken, "vault-token", "", "Vault token that will be used to detect conflicting secrets. Must have read access to the whole kv store. Mutually exclusive with --vault-token.")
 	fs.StringVar(&o.vaultRole, "vault-role", "", "Vault role to use for detecting conflicting secrets. Must have access to the whole kv store. Mutually exclusive with --vault-token.")
 	fs.BoolVar(&o.readOnly, "read-only", false, "Reject all write operations to the KV store. Use during Vault-to-GSM migration to freeze secrets.")
+	fs.IntVar(&o.metricsPort, "metrics-port", flagutil.DefaultMetricsPort, "The port to serve Prometheus metrics on")
+	fs.Float64Var(&o.clientRateLimit, "client-rate-limit", 0, "The number of requests per second every client address may make. It is checked before the token of a request is looked up. Zero disables the limit.")
+	fs.IntVar(&o.clientRateBurst, "client-rate-burst", 50, "The number of requests every client address may make at once, above --client-rate-limit")
+	fs.Float64Var(&o.identityRateLimit, "identity-rate-limit", 0, "The number of requests per second every identity may make. Zero disables the limit.")
+	fs.IntVar(&o.identityRateBurst, "identity-rate-burst", 20, "The number of requests every identity may make at once, above --identity-rate-limit")
+	fs.Var(&o.pathRateLimits, "path-rate-limit", "A limit of requests per second to all paths under a Vault path prefix, across all identities, in the form prefix=limit, e.g. secret/metadata=10. Can be passed multiple times; the longest matching prefix applies.")
+	fs.Var(&o.trustedProxies, "trusted-proxy", "The address or CIDR of a proxy in front of the proxy, like the cluster router. The client address of requests it passes on is taken from their X-Forwarded-For header. Can be passed multiple times.")
 	if err := fs.Parse(os.Args[1:]); err != nil {
 		return nil, fmt.Errorf("failed to parse flags: %w", err)
 	}
@@ -63,6 +80,21 @@ func gatherOptions() (*options, error) {
 	if o.vaultToken != "" && o.vaultRole != "" {
 		return nil, errors.New("--vault-token and --vault-role are mutually exclusive")
 	}
+	if o.clientRateLimit < 0 {
+		return nil, errors.New("--client-rate-limit must not be negative")
+	}
+	if o.clientRateLimit > 0 && o.clientRateBurst < 1 {
+		return nil, errors.New("--client-rate-burst must be positive")
+	}
+	if o.identityRateLimit < 0 {
+		return nil, errors.New("--identity-rate-limit must not be negative")
+	}
+	if o.identityRateLimit > 0 && o.identityRateBurst < 1 {
+		return nil, errors.New("--identity-rate-burst must be positive")
+	}
+	if _, err := parseTrustedProxies(o.trustedProxies.Strings()); err != nil {
+		return nil, err
+	}
 	if err := o.kubernetesOptions.Validate(false); err != nil {
 		return nil, err
 	}
@@ -97,7 +129,16 @@ func main() {
 	if opts.readOnly {
 		logrus.Warn("Running in read-only mode: all write operations will be rejected")
 	}
-	server, err := createProxyServer(opts.vaultAddr, opts.listenAddr, opts.kvMountPath, clientGetter, privilegedVaultClient, opts.readOnly)
+	limiter, err := newRateLimiter(opts.clientRateLimit, opts.clientRateBurst, opts.identityRateLimit, opts.identityRateBurst, opts.pathRateLimits.Strings())
+	if err != nil {
+		logrus.WithError(err).Fatal("invalid rate limits")
+	}
+	trustedProxies, err := parseTrustedProxies(opts.trustedProxies.Strings())
+	if err != nil {
+		logrus.WithError(err).Fatal("invalid trusted proxies")
+	}
+	metrics.ExposeMetrics(version.Name, config.PushGateway{}, opts.metricsPort)
+	server, err := createProxyServer(opts.vaultAddr, opts.listenAddr, opts.kvMountPath, clientGetter, privilegedVaultClient, opts.readOnly, limiter, trustedProxies)
 	if err != nil {
 		logrus.WithError(err).Fatal("failed to create server")
 	}
@@ -116,7 +157,7 @@ func main() {
 	}
 }
 
-func createProxyServer(vaultAddr string, listenAddr string, kvMountPath string, clients func() map[string]ctrlruntimeclient.Client, privilegedVaultClient *vaultclient.VaultClient, readOnly bool) (*http.Server, error) {
+func createProxyServer(vaultAddr string, listenAddr string, kvMountPath string, clients func() map[string]ctrlruntimeclient.Client, privilegedVaultClient *vaultclient.VaultClient, readOnly bool, limiter *rateLimiter, trustedProxies []*net.IPNet) (*http.Server, error) {
 	vaultClient, err := api.NewClient(&api.Config{Address: vaultAddr})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create vault client: %w", err)
@@ -138,7 +179,7 @@ func createProxyServer(vaultAddr string, listenAddr string, kvMountPath string,
 	proxy.ModifyResponse = injector.inject
 	return &http.Server{
 		Addr:    listenAddr,
-		Handler: proxy,
+		Handler: &auditHandler{next: proxy, identities: newIdentityResolver(vaultClient), limiter: limiter, trustedProxies: trustedProxies},
 	}, nil
 }
 
@@ -238,6 +279,7 @@ func (i *kvSubPathInjector) injectSubpathInfoIfNeeded(reponseBody []byte, r *htt
 	r.Body = io.NopCloser(bytes.NewBuffer(serializedResponse))
 	r.ContentLength = int64(len(serializedResponse))
 	r.Header.Set("Content-Length", strconv.Itoa(len(serializedResponse)))
+	markRewritten(r.Request.Context())
 	return nil
 }
 
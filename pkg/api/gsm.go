@@ -7,6 +7,7 @@ import (
 
 	corev1 "k8s.io/api/core/v1"
 	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"sigs.k8s.io/yaml"
 
 	gsm "github.com/openshift/ci-tools/pkg/gsm-secrets"
@@ -33,6 +34,7 @@ type GSMBundle struct {
 	Components    []string          `json:"components,omitempty"`
 	DockerConfig  *DockerConfigSpec `json:"dockerconfig,omitempty"`
 	GSMSecrets    []GSMSecretRef    `json:"gsm_secrets,omitempty"`
+	Templates     []TemplateSpec    `json:"templates,omitempty"`
 	SyncToCluster bool              `json:"sync_to_cluster,omitempty"`
 	Targets       []TargetSpec      `json:"targets,omitempty"`
 }
@@ -81,6 +83,23 @@ type RegistryAuthData struct {
 	EmailField  string `json:"email_field,omitempty"`
 }
 
+// TemplateSpec defines how to render a composite key, like a kubeconfig, from GSM secrets
+type TemplateSpec struct {
+	// As is the key of the rendered template in the secret
+	As string `json:"as"`
+	// Template is a Go template, the fields are referenced as {{ .name }}
+	Template string `json:"template"`
+	// Fields maps the names used in the template to GSM secrets
+	Fields map[string]TemplateFieldRef `json:"fields"`
+}
+
+// TemplateFieldRef references a single GSM secret used in a template
+type TemplateFieldRef struct {
+	Collection string `json:"collection"`
+	Group      string `json:"group"`
+	Field      string `json:"field"`
+}
+
 // TargetSpec defines where a bundle should be synced
 type TargetSpec struct {
 	ClusterGroups []string          `json:"cluster_groups,omitempty"`
@@ -215,6 +234,13 @@ func (c *GSMConfig) resolve() error {
 				break
 			}
 		}
+		for _, tmpl := range bundle.Templates {
+			for _, field := range tmpl.Fields {
+				if strings.Contains(field.Field, "${CLUSTER}") {
+					hasClusterVar = true
+				}
+			}
+		}
 
 		if !hasClusterVar {
 			// No ${CLUSTER} substitution needed, keep bundle as-is
@@ -259,6 +285,14 @@ func (c *GSMConfig) resolve() error {
 				}
 				expandedBundle.GSMSecrets = append(expandedBundle.GSMSecrets, expandedSecretRef)
 			}
+			for _, tmpl := range bundle.Templates {
+				expandedTemplate := TemplateSpec{As: tmpl.As, Template: tmpl.Template, Fields: make(map[string]TemplateFieldRef, len(tmpl.Fields))}
+				for name, field := range tmpl.Fields {
+					field.Field = strings.ReplaceAll(field.Field, "${CLUSTER}", cluster)
+					expandedTemplate.Fields[name] = field
+				}
+				expandedBundle.Templates = append(expandedBundle.Templates, expandedTemplate)
+			}
 			expandedBundles = append(expandedBundles, expandedBundle)
 		}
 	}
@@ -448,8 +482,17 @@ func validateBundle(bundle *GSMBundle, idx int) error {
 		}
 	}
 
-	if len(bundle.GSMSecrets) == 0 && bundle.DockerConfig == nil && len(bundle.Components) == 0 {
-		errs = append(errs, fmt.Errorf("bundle %s has neither gsm_secrets, dockerconfig, nor components", bundle.Name))
+	if len(bundle.Templates) > 0 {
+		if !bundle.SyncToCluster {
+			errs = append(errs, fmt.Errorf("bundle %s has templates but sync_to_cluster is false - template bundles must have sync_to_cluster: true", bundle.Name))
+		}
+		if err := validateTemplates(bundle.Templates, idx, bundle.Name); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(bundle.GSMSecrets) == 0 && bundle.DockerConfig == nil && len(bundle.Templates) == 0 && len(bundle.Components) == 0 {
+		errs = append(errs, fmt.Errorf("bundle %s has neither gsm_secrets, dockerconfig, templates, nor components", bundle.Name))
 	}
 
 	return utilerrors.NewAggregate(errs)
@@ -484,3 +527,34 @@ func validateDockerConfig(dc *DockerConfigSpec, bundleIdx int, bundleName string
 
 	return utilerrors.NewAggregate(errs)
 }
+
+func validateTemplates(templates []TemplateSpec, bundleIdx int, bundleName string) error {
+	var errs []error
+	keys := sets.New[string]()
+	for i, tmpl := range templates {
+		if tmpl.As == "" {
+			errs = append(errs, fmt.Errorf("bundle[%d] %s templates[%d] has empty as", bundleIdx, bundleName, i))
+		} else if err := gsmvalidation.ValidateMountFileName(tmpl.As); err != nil {
+			errs = append(errs, fmt.Errorf("bundle[%d] %s templates[%d].as is invalid: %w", bundleIdx, bundleName, i, err))
+		} else if keys.Has(tmpl.As) {
+			errs = append(errs, fmt.Errorf("bundle[%d] %s templates[%d] renders the key %s more than once", bundleIdx, bundleName, i, tmpl.As))
+		}
+		keys.Insert(tmpl.As)
+		if err := ValidateSecretTemplate(tmpl.As, tmpl.Template, sets.KeySet(tmpl.Fields)); err != nil {
+			errs = append(errs, fmt.Errorf("bundle[%d] %s templates[%d]: %w", bundleIdx, bundleName, i, err))
+		}
+		for _, name := range sets.List(sets.KeySet(tmpl.Fields)) {
+			field := tmpl.Fields[name]
+			if !gsmvalidation.ValidateCollectionName(field.Collection) {
+				errs = append(errs, fmt.Errorf("bundle[%d] %s templates[%d].fields[%s] has invalid collection string", bundleIdx, bundleName, i, name))
+			}
+			if !gsmvalidation.ValidateGroupName(field.Group) {
+				errs = append(errs, fmt.Errorf("bundle[%d] %s templates[%d].fields[%s] has invalid group string", bundleIdx, bundleName, i, name))
+			}
+			if !gsmvalidation.ValidateSecretName(field.Field) {
+				errs = append(errs, fmt.Errorf("bundle[%d] %s templates[%d].fields[%s] has invalid field", bundleIdx, bundleName, i, name))
+			}
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
@@ -354,6 +354,49 @@ func TestGSMConfigResolve(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "${CLUSTER} substitution - template fields",
+			config: GSMConfig{
+				Bundles: []GSMBundle{
+					{
+						Name: "test-bundle",
+						Templates: []TemplateSpec{
+							{
+								As:       "kubeconfig",
+								Template: "server: {{ .server }}\ntoken: {{ .token }}\n",
+								Fields: map[string]TemplateFieldRef{
+									"server": {Collection: "clusters", Group: "api", Field: "server"},
+									"token":  {Collection: "clusters", Group: "api", Field: "token-${CLUSTER}"},
+								},
+							},
+						},
+						Targets: []TargetSpec{
+							{Cluster: "build01", Namespace: "ci"},
+						},
+					},
+				},
+			},
+			expectedConfig: GSMConfig{
+				Bundles: []GSMBundle{
+					{
+						Name: "test-bundle",
+						Templates: []TemplateSpec{
+							{
+								As:       "kubeconfig",
+								Template: "server: {{ .server }}\ntoken: {{ .token }}\n",
+								Fields: map[string]TemplateFieldRef{
+									"server": {Collection: "clusters", Group: "api", Field: "server"},
+									"token":  {Collection: "clusters", Group: "api", Field: "token-build01"},
+								},
+							},
+						},
+						Targets: []TargetSpec{
+							{Cluster: "build01", Namespace: "ci", Type: corev1.SecretTypeOpaque},
+						},
+					},
+				},
+			},
+		},
 		{
 			name: "${CLUSTER} substitution - multiple clusters creates separate bundles",
 			config: GSMConfig{
@@ -1424,7 +1467,7 @@ func TestGSMConfigValidate(t *testing.T) {
 			errorContains: "dockerconfig registry[0] has empty auth_field",
 		},
 		{
-			name: "error: bundle with neither gsm_secrets, dockerconfig, nor components",
+			name: "error: bundle with neither gsm_secrets, dockerconfig, templates, nor components",
 			config: GSMConfig{
 				Bundles: []GSMBundle{
 					{
@@ -1437,7 +1480,90 @@ func TestGSMConfigValidate(t *testing.T) {
 				},
 			},
 			expectError:   true,
-			errorContains: "bundle test-bundle has neither gsm_secrets, dockerconfig, nor components",
+			errorContains: "bundle test-bundle has neither gsm_secrets, dockerconfig, templates, nor components",
+		},
+		{
+			name: "valid: bundle with a template",
+			config: GSMConfig{
+				Bundles: []GSMBundle{
+					{
+						Name: "kubeconfigs",
+						Templates: []TemplateSpec{
+							{
+								As:       "kubeconfig",
+								Template: "server: {{ .server }}\ntoken: {{ .token }}\n",
+								Fields: map[string]TemplateFieldRef{
+									"server": {Collection: "clusters", Group: "api", Field: "server"},
+									"token":  {Collection: "clusters", Group: "api", Field: "token"},
+								},
+							},
+						},
+						SyncToCluster: true,
+						Targets: []TargetSpec{
+							{Cluster: "build01", Namespace: "ci"},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "error: template with sync_to_cluster false",
+			config: GSMConfig{
+				Bundles: []GSMBundle{
+					{
+						Name: "kubeconfigs",
+						Templates: []TemplateSpec{
+							{
+								As:       "kubeconfig",
+								Template: "server: {{ .server }}\ntoken: {{ .token }}\n",
+								Fields: map[string]TemplateFieldRef{
+									"server": {Collection: "clusters", Group: "api", Field: "server"},
+									"token":  {Collection: "clusters", Group: "api", Field: "token"},
+								},
+							},
+						},
+						SyncToCluster: false,
+						Targets: []TargetSpec{
+							{Cluster: "build01", Namespace: "ci"},
+						},
+					},
+				},
+			},
+			expectError:   true,
+			errorContains: "bundle kubeconfigs has templates but sync_to_cluster is false",
+		},
+		{
+			name: "error: invalid templates",
+			config: GSMConfig{
+				Bundles: []GSMBundle{
+					{
+						Name: "kubeconfigs",
+						Templates: []TemplateSpec{
+							{
+								As:       "kubeconfig",
+								Template: "server: {{ .server }}\n",
+								Fields: map[string]TemplateFieldRef{
+									"server": {Collection: "clusters", Group: "api", Field: "server"},
+									"token":  {Collection: "clusters", Group: "api", Field: "token"},
+								},
+							},
+							{
+								As:       "kubeconfig",
+								Template: "token: {{ .token }}\n",
+								Fields: map[string]TemplateFieldRef{
+									"token": {Collection: "Clusters", Group: "api", Field: "token"},
+								},
+							},
+						},
+						SyncToCluster: true,
+						Targets: []TargetSpec{
+							{Cluster: "build01", Namespace: "ci"},
+						},
+					},
+				},
+			},
+			expectError:   true,
+			errorContains: "[bundle[0] kubeconfigs templates[0]: template kubeconfig does not use the fields: token, bundle[0] kubeconfigs templates[1] renders the key kubeconfig more than once, bundle[0] kubeconfigs templates[1].fields[token] has invalid collection string]",
 		},
 		{
 			name: "error: sync_to_cluster true but no targets (dockerconfig bundle)",
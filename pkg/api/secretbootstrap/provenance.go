@@ -0,0 +1,72 @@
+package secretbootstrap
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// ProvenanceAnnotation is set on every secret generated by ci-secret-bootstrap and
+// holds a JSON-serialized Provenance describing where each key comes from.
+const ProvenanceAnnotation = "ci.openshift.io/secret-provenance"
+
+const (
+	ProvenanceBackendVault = "vault"
+	ProvenanceBackendGSM   = "gsm"
+)
+
+// KeyProvenance describes the source of the value of a single key in a secret
+type KeyProvenance struct {
+	// Backend is the secret store the value was read from, either "vault" or "gsm"
+	Backend string `json:"backend"`
+	// Item is the Vault item the value was read from. Values built from several items,
+	// like a .dockerconfigjson or user secrets, list all of them separated by commas.
+	Item string `json:"item,omitempty"`
+	// Collection and Group identify the GSM secret the value was read from
+	Collection string `json:"collection,omitempty"`
+	Group      string `json:"group,omitempty"`
+	Field      string `json:"field,omitempty"`
+	// Version is the version of the upstream item at the time of the sync, when
+	// the value comes from a single item and the backend exposes one
+	Version string `json:"version,omitempty"`
+	// Hash is the hash of the value of the key in the secret
+	Hash string `json:"hash"`
+}
+
+// Provenance maps keys in a secret to their sources
+type Provenance map[string]KeyProvenance
+
+// HashValue returns the hash of a secret value as recorded in the provenance
+func HashValue(value []byte) string {
+	sum := sha256.Sum256(value)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// ProvenanceFor returns the provenance recorded on a secret, or nil when none was.
+func ProvenanceFor(secret *corev1.Secret) (Provenance, error) {
+	raw, ok := secret.Annotations[ProvenanceAnnotation]
+	if !ok {
+		return nil, nil
+	}
+	var provenance Provenance
+	if err := json.Unmarshal([]byte(raw), &provenance); err != nil {
+		return nil, fmt.Errorf("failed to parse the %s annotation: %w", ProvenanceAnnotation, err)
+	}
+	return provenance, nil
+}
+
+// SetProvenance records the provenance on a secret.
+func SetProvenance(secret *corev1.Secret, provenance Provenance) error {
+	raw, err := json.Marshal(provenance)
+	if err != nil {
+		return fmt.Errorf("failed to serialize the provenance: %w", err)
+	}
+	if secret.Annotations == nil {
+		secret.Annotations = map[string]string{}
+	}
+	secret.Annotations[ProvenanceAnnotation] = string(raw)
+	return nil
+}
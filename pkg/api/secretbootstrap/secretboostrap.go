@@ -21,11 +21,26 @@ type ItemContext struct {
 	Item                 string                 `json:"item,omitempty"`
 	Field                string                 `json:"field,omitempty"`
 	DockerConfigJSONData []DockerConfigJSONData `json:"dockerconfigJSON,omitempty"`
+	// Template renders a composite value, like a kubeconfig, from fields of several items
+	Template *TemplateData `json:"template,omitempty"`
 	// If the secret should be base64 decoded before uploading to kube. Encoding
 	// it is useful to be able to store binary data.
 	Base64Decode bool `json:"base64_decode,omitempty"`
 }
 
+// TemplateData is a Go template that is rendered over named fields of items
+type TemplateData struct {
+	// Content is the Go template, the fields are referenced as {{ .name }}
+	Content string `json:"content"`
+	// Fields maps the names used in the template to fields of items
+	Fields map[string]TemplateField `json:"fields"`
+}
+
+type TemplateField struct {
+	Item  string `json:"item"`
+	Field string `json:"field"`
+}
+
 type DockerConfigJSONData struct {
 	Item        string `json:"item"`
 	RegistryURL string `json:"registry_url"`
@@ -156,6 +171,12 @@ func stripVaultPrefix(s *SecretConfig, pre string) {
 		for i, dcj := range from.DockerConfigJSONData {
 			from.DockerConfigJSONData[i].Item = strings.TrimPrefix(dcj.Item, pre)
 		}
+		if from.Template != nil {
+			for name, field := range from.Template.Fields {
+				field.Item = strings.TrimPrefix(field.Item, pre)
+				from.Template.Fields[name] = field
+			}
+		}
 		s.From[key] = from
 	}
 }
@@ -230,6 +251,14 @@ func (c *Config) resolve() error {
 						fromValue.DockerConfigJSONData[dockerCFGIdx] = dockerCFGVal
 					}
 				}
+				if fromValue.Template != nil {
+					for name, field := range fromValue.Template.Fields {
+						if field.Item != "" {
+							field.Item = c.VaultDPTPPrefix + "/" + field.Item
+							fromValue.Template.Fields[name] = field
+						}
+					}
+				}
 
 				secret.From[fromKey] = fromValue
 			}
@@ -127,6 +127,29 @@ func TestResolving(t *testing.T) {
 				}},
 			},
 		},
+		{
+			name: "DPTP prefix gets added to template items",
+			config: Config{
+				VaultDPTPPrefix: "prefix",
+				Secrets: []SecretConfig{{
+					From: map[string]ItemContext{"kubeconfig": {Template: &TemplateData{
+						Content: "{{ .ca }}",
+						Fields:  map[string]TemplateField{"ca": {Item: "foo", Field: "ca.crt"}},
+					}}},
+					To: []SecretContext{{Cluster: "foo", Namespace: "namspace", Name: "name"}},
+				}},
+			},
+			expectedConfig: Config{
+				VaultDPTPPrefix: "prefix",
+				Secrets: []SecretConfig{{
+					From: map[string]ItemContext{"kubeconfig": {Template: &TemplateData{
+						Content: "{{ .ca }}",
+						Fields:  map[string]TemplateField{"ca": {Item: "prefix/foo", Field: "ca.crt"}},
+					}}},
+					To: []SecretContext{{Cluster: "foo", Namespace: "namspace", Name: "name"}},
+				}},
+			},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -3,11 +3,14 @@ package secretgenerator
 import (
 	"encoding/json"
 	"fmt"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/getlantern/deepcopy"
 
 	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	prowv1 "sigs.k8s.io/prow/pkg/apis/prowjobs/v1"
 	"sigs.k8s.io/yaml"
 
 	"github.com/openshift/ci-tools/pkg/util/gzip"
@@ -90,6 +93,46 @@ type SecretItem struct {
 	Fields   []FieldGenerator    `json:"fields,omitempty"`
 	Notes    string              `json:"notes,omitempty"`
 	Params   map[string][]string `json:"params,omitempty"`
+	// Rotation makes the item rotated only once its values are older than the
+	// maximum age, instead of being generated on every run
+	Rotation *RotationPolicy `json:"rotation,omitempty"`
+}
+
+// RotationPolicy describes when and how the values of an item are rotated. Hooks are
+// shell snippets like the field commands; they get the item name in $ITEM_NAME and,
+// where noted, a directory holding a file per field with its value in $ROTATION_VALUES_DIR.
+type RotationPolicy struct {
+	// MaxAge is how long the values are used before they are rotated
+	MaxAge prowv1.Duration `json:"max_age"`
+	// PreRotationCmd runs before the new values are generated
+	PreRotationCmd string `json:"pre_rotation_cmd,omitempty"`
+	// VerifyCmd runs with the new values once they are stored; if it fails,
+	// the previous values are restored
+	VerifyCmd string `json:"verify_cmd,omitempty"`
+	// Overlap is how long the previous values stay valid after a rotation
+	Overlap *prowv1.Duration `json:"overlap,omitempty"`
+	// RevokeCmd runs with the previous values once the overlap is over
+	RevokeCmd string `json:"revoke_cmd,omitempty"`
+	// Fields are the names of the rotated fields, all fields of the item are rotated if it is empty.
+	// The other fields are generated on every run like the fields of items without a rotation policy.
+	Fields []string `json:"fields,omitempty"`
+}
+
+// Rotates determines whether the field is only generated when the item is rotated
+func (p *RotationPolicy) Rotates(field string) bool {
+	return p != nil && (len(p.Fields) == 0 || slices.Contains(p.Fields, field))
+}
+
+// RotationStateField is the field of a rotated item that holds its RotationState
+const RotationStateField = "ci-secret-generator-rotation-state"
+
+// RotationState records the last rotation of an item
+type RotationState struct {
+	RotatedAt time.Time `json:"rotated_at"`
+	// Previous holds the values replaced by the last rotation until they are revoked
+	Previous map[string]string `json:"previous,omitempty"`
+	// PreviousValidUntil is the end of the overlap of the previous values
+	PreviousValidUntil *time.Time `json:"previous_valid_until,omitempty"`
 }
 
 func (si SecretItem) generateItemsFromParams() ([]SecretItem, error) {
@@ -119,6 +162,14 @@ func (si SecretItem) generateItemsFromParams() ([]SecretItem, error) {
 					}
 				}
 				argItem.Notes = replaceParameter(paramName, param, argItem.Notes)
+				if argItem.Rotation != nil {
+					argItem.Rotation.PreRotationCmd = replaceParameter(paramName, param, argItem.Rotation.PreRotationCmd)
+					argItem.Rotation.VerifyCmd = replaceParameter(paramName, param, argItem.Rotation.VerifyCmd)
+					argItem.Rotation.RevokeCmd = replaceParameter(paramName, param, argItem.Rotation.RevokeCmd)
+					for i, field := range argItem.Rotation.Fields {
+						argItem.Rotation.Fields[i] = replaceParameter(paramName, param, field)
+					}
+				}
 				itemsProcessed = append(itemsProcessed, argItem)
 			}
 		}
@@ -0,0 +1,141 @@
+package api
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// secretTemplateFuncs are the functions available in secret templates. The values they derive
+// from secrets are passed to censor, as they are just as sensitive as the secrets themselves.
+func secretTemplateFuncs(censor func(...string)) template.FuncMap {
+	derived := func(value string) string {
+		if censor != nil && value != "" {
+			censor(value)
+		}
+		return value
+	}
+	return template.FuncMap{
+		"base64": func(value string) string {
+			return derived(base64.StdEncoding.EncodeToString([]byte(value)))
+		},
+		"base64decode": func(value string) (string, error) {
+			decoded, err := base64.StdEncoding.DecodeString(value)
+			if err != nil {
+				return "", fmt.Errorf("failed to base64-decode value: %w", err)
+			}
+			return derived(string(decoded)), nil
+		},
+		"trim": func(value string) string {
+			return derived(strings.TrimSpace(value))
+		},
+		"indent": func(spaces int, value string) string {
+			padding := strings.Repeat(" ", spaces)
+			return padding + strings.ReplaceAll(value, "\n", "\n"+padding)
+		},
+		"json": func(value string) (string, error) {
+			raw, err := json.Marshal(value)
+			if err != nil {
+				return "", err
+			}
+			return derived(string(raw)), nil
+		},
+	}
+}
+
+// ParseSecretTemplate parses a Go template that renders a composite secret, like a kubeconfig
+// or a configuration file. The fields of the secret are referenced as {{ .name }}. The values
+// derived from the fields by template functions are passed to censor, which may be nil.
+func ParseSecretTemplate(name, text string, censor func(...string)) (*template.Template, error) {
+	if strings.TrimSpace(text) == "" {
+		return nil, fmt.Errorf("template %s is empty", name)
+	}
+	tmpl, err := template.New(name).Option("missingkey=error").Funcs(secretTemplateFuncs(censor)).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template %s: %w", name, err)
+	}
+	return tmpl, nil
+}
+
+// SecretTemplateReferences returns the names of the fields the template references
+func SecretTemplateReferences(tmpl *template.Template) sets.Set[string] {
+	references := sets.New[string]()
+	for _, t := range tmpl.Templates() {
+		if t.Tree != nil {
+			collectSecretTemplateReferences(t.Tree.Root, references)
+		}
+	}
+	return references
+}
+
+func collectSecretTemplateReferences(node parse.Node, references sets.Set[string]) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			collectSecretTemplateReferences(child, references)
+		}
+	case *parse.ActionNode:
+		collectSecretTemplateReferences(n.Pipe, references)
+	case *parse.IfNode:
+		collectSecretTemplateReferences(&n.BranchNode, references)
+	case *parse.RangeNode:
+		collectSecretTemplateReferences(&n.BranchNode, references)
+	case *parse.WithNode:
+		collectSecretTemplateReferences(&n.BranchNode, references)
+	case *parse.BranchNode:
+		collectSecretTemplateReferences(n.Pipe, references)
+		collectSecretTemplateReferences(n.List, references)
+		collectSecretTemplateReferences(n.ElseList, references)
+	case *parse.TemplateNode:
+		collectSecretTemplateReferences(n.Pipe, references)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, cmd := range n.Cmds {
+			collectSecretTemplateReferences(cmd, references)
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			collectSecretTemplateReferences(arg, references)
+		}
+	case *parse.ChainNode:
+		collectSecretTemplateReferences(n.Node, references)
+	case *parse.FieldNode:
+		references.Insert(n.Ident[0])
+	}
+}
+
+// ValidateSecretTemplate checks that the template parses and that it references exactly the given fields
+func ValidateSecretTemplate(name, text string, fields sets.Set[string]) error {
+	tmpl, err := ParseSecretTemplate(name, text, nil)
+	if err != nil {
+		return err
+	}
+	references := SecretTemplateReferences(tmpl)
+	if undefined := references.Difference(fields); undefined.Len() > 0 {
+		return fmt.Errorf("template %s references undefined fields: %s", name, strings.Join(sets.List(undefined), ", "))
+	}
+	if unused := fields.Difference(references); unused.Len() > 0 {
+		return fmt.Errorf("template %s does not use the fields: %s", name, strings.Join(sets.List(unused), ", "))
+	}
+	return nil
+}
+
+// RenderSecretTemplate renders the template with the values of the fields
+func RenderSecretTemplate(tmpl *template.Template, values map[string]string) ([]byte, error) {
+	var rendered bytes.Buffer
+	if err := tmpl.Execute(&rendered, values); err != nil {
+		return nil, fmt.Errorf("failed to render template %s: %w", tmpl.Name(), err)
+	}
+	return rendered.Bytes(), nil
+}
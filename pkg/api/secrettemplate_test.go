@@ -0,0 +1,131 @@
+package api
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/openshift/ci-tools/pkg/testhelper"
+)
+
+func TestValidateSecretTemplate(t *testing.T) {
+	testCases := []struct {
+		name          string
+		content       string
+		fields        sets.Set[string]
+		expectedError error
+	}{
+		{
+			name:    "all fields are used",
+			content: "{{ .a }}{{ if .b }}{{ base64 .c | trim }}{{ else }}{{ with .d }}{{ . }}{{ end }}{{ end }}",
+			fields:  sets.New("a", "b", "c", "d"),
+		},
+		{
+			name:          "empty template",
+			content:       " \n",
+			expectedError: errors.New("template kubeconfig is empty"),
+		},
+		{
+			name:          "invalid template",
+			content:       "{{ .a ",
+			fields:        sets.New("a"),
+			expectedError: errors.New("failed to parse template kubeconfig: template: kubeconfig:1: unclosed action"),
+		},
+		{
+			name:          "unknown function",
+			content:       "{{ sha256 .a }}",
+			fields:        sets.New("a"),
+			expectedError: errors.New(`failed to parse template kubeconfig: template: kubeconfig:1: function "sha256" not defined`),
+		},
+		{
+			name:          "undefined field",
+			content:       "{{ .a }}{{ .b }}",
+			fields:        sets.New("a"),
+			expectedError: errors.New("template kubeconfig references undefined fields: b"),
+		},
+		{
+			name:          "unused field",
+			content:       "{{ .a }}",
+			fields:        sets.New("a", "b"),
+			expectedError: errors.New("template kubeconfig does not use the fields: b"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateSecretTemplate("kubeconfig", tc.content, tc.fields)
+			if diff := cmp.Diff(tc.expectedError, err, testhelper.EquateErrorMessage); diff != "" {
+				t.Errorf("unexpected error: %s", diff)
+			}
+		})
+	}
+}
+
+func TestRenderSecretTemplate(t *testing.T) {
+	testCases := []struct {
+		name             string
+		content          string
+		values           map[string]string
+		expected         string
+		expectedCensored []string
+		expectedError    error
+	}{
+		{
+			name:             "kubeconfig",
+			content:          "certificate-authority-data: {{ base64 .ca }}\nserver: {{ .server }}\ntoken: {{ trim .token }}\n",
+			values:           map[string]string{"ca": "ca-data", "server": "https://api.build01:6443", "token": " token\n"},
+			expected:         "certificate-authority-data: Y2EtZGF0YQ==\nserver: https://api.build01:6443\ntoken: token\n",
+			expectedCensored: []string{"Y2EtZGF0YQ==", "token"},
+		},
+		{
+			name:             "json and indentation",
+			content:          "{\"password\": {{ json .password }}}\ncert: |\n{{ indent 2 .cert }}",
+			values:           map[string]string{"password": `pa"ss`, "cert": "line1\nline2"},
+			expected:         "{\"password\": \"pa\\\"ss\"}\ncert: |\n  line1\n  line2",
+			expectedCensored: []string{`"pa\"ss"`},
+		},
+		{
+			name:             "decoding",
+			content:          "{{ base64decode .encoded }}",
+			values:           map[string]string{"encoded": "c2VjcmV0"},
+			expected:         "secret",
+			expectedCensored: []string{"secret"},
+		},
+		{
+			name:          "missing value",
+			content:       "{{ .missing }}",
+			values:        map[string]string{},
+			expectedError: errors.New(`failed to render template config: template: config:1:3: executing "config" at <.missing>: map has no entry for key "missing"`),
+		},
+		{
+			name:          "invalid base64",
+			content:       "{{ base64decode .encoded }}",
+			values:        map[string]string{"encoded": "not base64"},
+			expectedError: errors.New(`failed to render template config: template: config:1:3: executing "config" at <base64decode .encoded>: error calling base64decode: failed to base64-decode value: illegal base64 data at input byte 3`),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var censored []string
+			tmpl, err := ParseSecretTemplate("config", tc.content, func(s ...string) { censored = append(censored, s...) })
+			if err != nil {
+				t.Fatalf("failed to parse template: %v", err)
+			}
+			rendered, err := RenderSecretTemplate(tmpl, tc.values)
+			if diff := cmp.Diff(tc.expectedError, err, testhelper.EquateErrorMessage); diff != "" {
+				t.Fatalf("unexpected error: %s", diff)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(tc.expected, string(rendered)); diff != "" {
+				t.Errorf("unexpected rendered template: %s", diff)
+			}
+			if diff := cmp.Diff(tc.expectedCensored, censored); diff != "" {
+				t.Errorf("unexpected censored values: %s", diff)
+			}
+		})
+	}
+}
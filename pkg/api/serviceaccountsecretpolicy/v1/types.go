@@ -0,0 +1,64 @@
+package v1
+
+import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:resource:scope=Cluster
+
+// ServiceAccountSecretPolicy configures how the secrets of service accounts
+// are rotated by the serviceaccount_secret_refresher controller
+type ServiceAccountSecretPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata"`
+
+	Spec ServiceAccountSecretPolicySpec `json:"spec"`
+}
+
+type ServiceAccountSecretPolicySpec struct {
+	// Clusters limits the policy to service accounts on these clusters. The policy
+	// applies to all clusters when empty.
+	// +optional
+	Clusters []string `json:"clusters,omitempty"`
+	// Namespaces limits the policy to service accounts in these namespaces. The policy
+	// applies to all namespaces when empty, in which case a selector must be set.
+	// +optional
+	Namespaces []string `json:"namespaces,omitempty"`
+	// Selector limits the policy to service accounts with matching labels.
+	// +optional
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+	// IgnoreServiceAccounts lists service accounts in namespace/name format whose secrets
+	// are not rotated, even though the policy selects them.
+	// +optional
+	IgnoreServiceAccounts []string `json:"ignoreServiceAccounts,omitempty"`
+	// TTL is the age after which the secrets of a service account are rotated.
+	TTL metav1.Duration `json:"ttl"`
+	// GracePeriod is how long rotated secrets are kept before they are deleted, so that
+	// their consumers can pick up the new ones. Defaults to the TTL.
+	// +optional
+	GracePeriod *metav1.Duration `json:"gracePeriod,omitempty"`
+	// NotifyChannel is a Slack channel that is notified when secrets are rotated.
+	// +optional
+	NotifyChannel string `json:"notifyChannel,omitempty"`
+}
+
+// EffectiveGracePeriod returns the grace period, defaulting to the TTL
+func (s ServiceAccountSecretPolicySpec) EffectiveGracePeriod() time.Duration {
+	if s.GracePeriod != nil {
+		return s.GracePeriod.Duration
+	}
+	return s.TTL.Duration
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ServiceAccountSecretPolicyList is a list of ServiceAccountSecretPolicy resources
+type ServiceAccountSecretPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []ServiceAccountSecretPolicy `json:"items"`
+}
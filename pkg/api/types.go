@@ -71,6 +71,11 @@ type ProwgenOverrides struct {
 type ReleaseBuildConfiguration struct {
 	Metadata Metadata `json:"zz_generated_metadata"`
 
+	// Extends lists the named fragments this configuration is layered on, in
+	// order. Fragments are partial configurations that are merged under the
+	// configuration when it is loaded, after the defaults of its org and repo.
+	Extends []string `json:"extends,omitempty"`
+
 	Prowgen *ProwgenOverrides `json:"prowgen,omitempty"`
 
 	InputConfiguration `json:",inline"`
@@ -1790,6 +1790,11 @@ func (in *Release) DeepCopy() *Release {
 func (in *ReleaseBuildConfiguration) DeepCopyInto(out *ReleaseBuildConfiguration) {
 	*out = *in
 	out.Metadata = in.Metadata
+	if in.Extends != nil {
+		in, out := &in.Extends, &out.Extends
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	if in.Prowgen != nil {
 		in, out := &in.Prowgen, &out.Prowgen
 		*out = new(ProwgenOverrides)
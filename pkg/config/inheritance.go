@@ -0,0 +1,774 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ghodss/yaml"
+
+	cioperatorapi "github.com/openshift/ci-tools/pkg/api"
+)
+
+// CI Operator configurations are layered over shared fragments before they are validated:
+//
+//	config
+//	├── _fragments
+//	│   └── go-1.22.yaml
+//	└── org
+//	    ├── _defaults.yaml
+//	    ├── _fragments
+//	    │   └── e2e-aws.yaml
+//	    └── repo
+//	        ├── _defaults.yaml
+//	        └── org-repo-master.yaml
+//
+// The layers of a configuration are, in order, the defaults of its org, the
+// defaults of its repo and the fragments named by its `extends`, each of which
+// can extend other fragments first. A fragment is looked up in the _fragments
+// directory of the repo, then of the org and then of the whole configuration.
+//
+// Every layer is merged over the previous ones: mappings are merged key by key,
+// a null value removes the key, and any other value replaces the previous one.
+// Tests are the exception, they are merged by their name so that a layer can
+// add or replace single tests, and drop inherited ones with `exclude_tests`.
+const (
+	// DefaultsFileName is the name of the file with the defaults of every configuration of an org or repo
+	DefaultsFileName = "_defaults.yaml"
+	// FragmentsDirName is the name of the directory with the named fragments configurations can extend
+	FragmentsDirName = "_fragments"
+
+	extendsKey      = "extends"
+	excludeTestsKey = "exclude_tests"
+	testsKey        = "tests"
+	testNameKey     = "as"
+	metadataKey     = "zz_generated_metadata"
+)
+
+// layer is a partial CI Operator configuration in its JSON form
+type layer map[string]interface{}
+
+// isInheritanceFile identifies the files and directories holding the layers,
+// they are not configurations on their own
+func isInheritanceFile(info fs.DirEntry) bool {
+	if info.IsDir() {
+		return info.Name() == FragmentsDirName
+	}
+	return info.Name() == DefaultsFileName
+}
+
+func parseLayer(data []byte) (layer, error) {
+	raw, err := yaml.YAMLToJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	// numbers are kept as they were written, so they survive the round trip
+	decoder.UseNumber()
+	var parsed layer
+	if err := decoder.Decode(&parsed); err != nil {
+		return nil, err
+	}
+	if parsed == nil {
+		parsed = layer{}
+	}
+	return parsed, nil
+}
+
+// readLayer reads the layer in the file, a file that does not exist is an empty layer
+func readLayer(path string) (layer, bool, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	parsed, err := parseLayer(data)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return parsed, true, nil
+}
+
+// layerCache holds the layers read while loading many configurations, so that the
+// defaults and fragments of a directory are read once and not for every configuration
+// layered on them. A nil cache reads the layers every time.
+type layerCache struct {
+	lock   sync.Mutex
+	layers map[string]cachedLayer
+}
+
+type cachedLayer struct {
+	layer layer
+	found bool
+	err   error
+}
+
+func newLayerCache() *layerCache {
+	return &layerCache{layers: map[string]cachedLayer{}}
+}
+
+// read reads the layer in the file like readLayer, the layer must not be modified
+func (c *layerCache) read(path string) (layer, bool, error) {
+	if c == nil {
+		return readLayer(path)
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if cached, ok := c.layers[path]; ok {
+		return cached.layer, cached.found, cached.err
+	}
+	l, found, err := readLayer(path)
+	c.layers[path] = cachedLayer{layer: l, found: found, err: err}
+	return l, found, err
+}
+
+func stringList(l layer, key string) ([]string, error) {
+	value, ok := l[key]
+	if !ok || value == nil {
+		return nil, nil
+	}
+	items, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s must be a list of names", key)
+	}
+	var names []string
+	for _, item := range items {
+		name, ok := item.(string)
+		if !ok || len(name) == 0 {
+			return nil, fmt.Errorf("%s must be a list of names", key)
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// layerResolver merges the layers of the configurations of a repo
+type layerResolver struct {
+	root, org, repo string
+	cache           *layerCache
+}
+
+func (r layerResolver) defaultsPaths() []string {
+	return []string{
+		filepath.Join(r.root, r.org, DefaultsFileName),
+		filepath.Join(r.root, r.org, r.repo, DefaultsFileName),
+	}
+}
+
+func (r layerResolver) fragment(name string) (layer, error) {
+	if strings.ContainsAny(name, `/\`) || name == "." || name == ".." {
+		return nil, fmt.Errorf("invalid fragment name %q", name)
+	}
+	for _, dir := range []string{filepath.Join(r.root, r.org, r.repo), filepath.Join(r.root, r.org), r.root} {
+		fragment, found, err := r.cache.read(filepath.Join(dir, FragmentsDirName, name+".yaml"))
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			return fragment, nil
+		}
+	}
+	return nil, fmt.Errorf("fragment %q not found in %s of the repo, the org or the root of the configuration", name, FragmentsDirName)
+}
+
+// apply merges the fragments the layer extends and then the layer itself over base
+func (r layerResolver) apply(base, l layer, stack []string) (layer, error) {
+	extends, err := stringList(l, extendsKey)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range extends {
+		if base, err = r.applyFragment(base, name, stack); err != nil {
+			return nil, err
+		}
+	}
+	return mergeLayer(base, l)
+}
+
+func (r layerResolver) applyFragment(base layer, name string, stack []string) (layer, error) {
+	for _, seen := range stack {
+		if seen == name {
+			return nil, fmt.Errorf("fragments extend each other: %s -> %s", strings.Join(stack, " -> "), name)
+		}
+	}
+	fragment, err := r.fragment(name)
+	if err != nil {
+		return nil, err
+	}
+	merged, err := r.apply(base, fragment, append(append([]string{}, stack...), name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply fragment %q: %w", name, err)
+	}
+	return merged, nil
+}
+
+// base merges the layers a configuration extending the fragments is layered on,
+// it is empty when there are none
+func (r layerResolver) base(extends []string) (layer, bool, error) {
+	base, layered := layer{}, len(extends) > 0
+	for _, path := range r.defaultsPaths() {
+		defaults, found, err := r.cache.read(path)
+		if err != nil {
+			return nil, false, err
+		}
+		if !found {
+			continue
+		}
+		layered = true
+		if base, err = r.apply(base, defaults, nil); err != nil {
+			return nil, false, fmt.Errorf("failed to apply %s: %w", path, err)
+		}
+	}
+	for _, name := range extends {
+		var err error
+		if base, err = r.applyFragment(base, name, nil); err != nil {
+			return nil, false, err
+		}
+	}
+	return base, layered, nil
+}
+
+// mergeLayer merges the layer over base, leaving both untouched
+func mergeLayer(base, l layer) (layer, error) {
+	merged := layer(mergeMaps(base, nil))
+	excluded, err := stringList(l, excludeTestsKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(excluded) > 0 {
+		if merged[testsKey], err = mergeTests(merged[testsKey], nil, excluded); err != nil {
+			return nil, err
+		}
+	}
+	for key, value := range l {
+		switch key {
+		case extendsKey, excludeTestsKey:
+		case testsKey:
+			if value == nil {
+				delete(merged, key)
+				continue
+			}
+			if merged[key], err = mergeTests(merged[key], value, nil); err != nil {
+				return nil, err
+			}
+		default:
+			if value == nil {
+				delete(merged, key)
+				continue
+			}
+			merged[key] = mergeValues(merged[key], value)
+		}
+	}
+	if tests, ok := merged[testsKey].([]interface{}); ok && len(tests) == 0 {
+		delete(merged, testsKey)
+	}
+	return merged, nil
+}
+
+func mergeValues(base, override interface{}) interface{} {
+	baseMap, baseIsMap := base.(map[string]interface{})
+	overrideMap, overrideIsMap := override.(map[string]interface{})
+	if baseIsMap && overrideIsMap {
+		return mergeMaps(baseMap, overrideMap)
+	}
+	return override
+}
+
+func mergeMaps(base, override map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(base)+len(override))
+	for key, value := range base {
+		merged[key] = value
+	}
+	for key, value := range override {
+		if value == nil {
+			delete(merged, key)
+			continue
+		}
+		merged[key] = mergeValues(merged[key], value)
+		// a mapping that is left empty by removing all of its keys is removed
+		// itself, since it would not be serialized otherwise
+		if mergedMap, ok := merged[key].(map[string]interface{}); ok && len(mergedMap) == 0 {
+			if overrideMap, ok := value.(map[string]interface{}); ok && len(overrideMap) > 0 {
+				delete(merged, key)
+			}
+		}
+	}
+	return merged
+}
+
+func testName(test interface{}) (string, error) {
+	fields, ok := test.(map[string]interface{})
+	if !ok {
+		return "", errors.New("tests must be a list of tests")
+	}
+	name, ok := fields[testNameKey].(string)
+	if !ok || len(name) == 0 {
+		return "", fmt.Errorf("every test must have a name in %q to be merged", testNameKey)
+	}
+	return name, nil
+}
+
+func testList(value interface{}) ([]interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+	tests, ok := value.([]interface{})
+	if !ok {
+		return nil, errors.New("tests must be a list of tests")
+	}
+	return tests, nil
+}
+
+// mergeTests replaces the tests of base that have the name of one in override,
+// in place, and appends the others after dropping the excluded ones
+func mergeTests(base, override interface{}, excluded []string) (interface{}, error) {
+	baseTests, err := testList(base)
+	if err != nil {
+		return nil, err
+	}
+	overrideTests, err := testList(override)
+	if err != nil {
+		return nil, err
+	}
+	drop := map[string]bool{}
+	for _, name := range excluded {
+		drop[name] = true
+	}
+	var merged []interface{}
+	index := map[string]int{}
+	for _, test := range baseTests {
+		name, err := testName(test)
+		if err != nil {
+			return nil, err
+		}
+		if drop[name] {
+			continue
+		}
+		index[name] = len(merged)
+		merged = append(merged, test)
+	}
+	for _, test := range overrideTests {
+		name, err := testName(test)
+		if err != nil {
+			return nil, err
+		}
+		if i, exists := index[name]; exists {
+			merged[i] = test
+			continue
+		}
+		index[name] = len(merged)
+		merged = append(merged, test)
+	}
+	return merged, nil
+}
+
+// foldLayer determines the layer that gives full when it is merged over base,
+// leaving out what base already holds
+func foldLayer(base, full layer) (layer, error) {
+	folded := layer(diffMaps(base, full))
+	delete(folded, testsKey)
+
+	baseTests, err := testList(base[testsKey])
+	if err != nil {
+		return nil, err
+	}
+	fullTests, err := testList(full[testsKey])
+	if err != nil {
+		return nil, err
+	}
+	inherited := map[string]interface{}{}
+	for _, test := range baseTests {
+		name, err := testName(test)
+		if err != nil {
+			return nil, err
+		}
+		inherited[name] = test
+	}
+	kept := map[string]bool{}
+	var tests []interface{}
+	for _, test := range fullTests {
+		name, err := testName(test)
+		if err != nil {
+			return nil, err
+		}
+		kept[name] = true
+		if previous, ok := inherited[name]; !ok || !reflect.DeepEqual(previous, test) {
+			tests = append(tests, test)
+		}
+	}
+	var excluded []string
+	for name := range inherited {
+		if !kept[name] {
+			excluded = append(excluded, name)
+		}
+	}
+	setTests(folded, tests, excluded)
+
+	expected := layer(mergeMaps(full, map[string]interface{}{extendsKey: nil}))
+	merged, err := mergeLayer(base, folded)
+	if err != nil {
+		return nil, err
+	}
+	if !reflect.DeepEqual(merged[testsKey], expected[testsKey]) {
+		// the inherited tests come first when merged, so when the order of the
+		// tests differs all of them are replaced to keep it
+		excluded = excluded[:0]
+		for name := range inherited {
+			excluded = append(excluded, name)
+		}
+		setTests(folded, fullTests, excluded)
+		if merged, err = mergeLayer(base, folded); err != nil {
+			return nil, err
+		}
+	}
+	if !reflect.DeepEqual(merged, expected) {
+		return nil, errors.New("the configuration cannot be expressed as a layer over its base")
+	}
+	return folded, nil
+}
+
+func setTests(l layer, tests []interface{}, excluded []string) {
+	delete(l, testsKey)
+	delete(l, excludeTestsKey)
+	if len(tests) > 0 {
+		l[testsKey] = tests
+	}
+	if len(excluded) > 0 {
+		sort.Strings(excluded)
+		names := make([]interface{}, 0, len(excluded))
+		for _, name := range excluded {
+			names = append(names, name)
+		}
+		l[excludeTestsKey] = names
+	}
+}
+
+// diffMaps holds what differs in full from base, with null for what full does not hold
+func diffMaps(base, full map[string]interface{}) map[string]interface{} {
+	diff := map[string]interface{}{}
+	for key, value := range full {
+		previous, ok := base[key]
+		switch {
+		case !ok:
+			diff[key] = value
+		case reflect.DeepEqual(previous, value):
+		default:
+			previousMap, previousIsMap := previous.(map[string]interface{})
+			valueMap, valueIsMap := value.(map[string]interface{})
+			if previousIsMap && valueIsMap {
+				diff[key] = diffMaps(previousMap, valueMap)
+			} else {
+				diff[key] = value
+			}
+		}
+	}
+	for key := range base {
+		if _, ok := full[key]; !ok {
+			diff[key] = nil
+		}
+	}
+	return diff
+}
+
+func configurationLayer(configuration *cioperatorapi.ReleaseBuildConfiguration) (layer, error) {
+	raw, err := json.Marshal(configuration)
+	if err != nil {
+		return nil, err
+	}
+	return parseLayer(raw)
+}
+
+// expandConfiguration layers the configuration over its base, the result is what
+// the configuration would be without inheritance. The fragments it extends are
+// returned on their own, so that it can be folded over them again.
+func expandConfiguration(data []byte, root string, info Info, cache *layerCache) ([]byte, []string, error) {
+	raw, err := parseLayer(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	extends, err := stringList(raw, extendsKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	resolver := layerResolver{root: root, org: info.Org, repo: info.Repo, cache: cache}
+	base, layered, err := resolver.base(extends)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !layered {
+		// without a base there are no tests to exclude, so the exclusions are a mistake the
+		// decoding of the configuration would silently ignore
+		if _, ok := raw[excludeTestsKey]; ok {
+			return nil, nil, fmt.Errorf("the configuration uses %q, but it does not inherit any tests: there is no %s for it and it extends no fragments", excludeTestsKey, DefaultsFileName)
+		}
+		if _, ok := raw[extendsKey]; !ok {
+			return data, nil, nil
+		}
+		// an empty list of fragments is dropped like any other
+		extends = nil
+	}
+	expanded, err := mergeLayer(base, raw)
+	if err != nil {
+		return nil, nil, err
+	}
+	serialized, err := json.Marshal(expanded)
+	return serialized, extends, err
+}
+
+// ExpandLayers layers the configuration read from the file over the defaults and
+// fragments of the configuration directory the file is in, for the tools that load
+// a single file with strict decoding. A file that is not in such a directory cannot
+// be layered and is rejected when it uses `extends` or `exclude_tests`.
+func ExpandLayers(path string, data []byte) ([]byte, error) {
+	info, err := InfoFromPath(path)
+	if err != nil {
+		return data, RejectLayers(data)
+	}
+	expanded, _, err := expandConfiguration(data, filepath.Dir(info.OrgPath), *info, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to layer %s: %w", path, err)
+	}
+	return expanded, RejectLayers(expanded)
+}
+
+// RejectLayers fails for a configuration that uses `extends` or `exclude_tests`, they can
+// only be resolved by loading the configuration from the directory with its defaults and
+// fragments. Configurations that cannot be parsed are left for the caller to report.
+func RejectLayers(data []byte) error {
+	raw, err := parseLayer(data)
+	if err != nil {
+		return nil
+	}
+	for _, key := range []string{extendsKey, excludeTestsKey} {
+		if _, ok := raw[key]; ok {
+			return fmt.Errorf("the configuration uses %q, which is only resolved when it is loaded from the configuration directory with its %s and %s: expand it first, e.g. by loading it through the configresolver", key, DefaultsFileName, FragmentsDirName)
+		}
+	}
+	return nil
+}
+
+// foldConfiguration serializes the configuration as the layer over its base in
+// the directory and the fragments of the info, which is all of it when it has no base
+func foldConfiguration(dir string, info Info, configuration *cioperatorapi.ReleaseBuildConfiguration) ([]byte, error) {
+	resolver := layerResolver{root: dir, org: info.Org, repo: info.Repo}
+	base, layered, err := resolver.base(info.Extends)
+	if err != nil {
+		return nil, err
+	}
+	if !layered {
+		return yaml.Marshal(configuration)
+	}
+	full, err := configurationLayer(configuration)
+	if err != nil {
+		return nil, err
+	}
+	folded, err := foldLayer(base, full)
+	if err != nil {
+		return nil, err
+	}
+	if len(info.Extends) > 0 {
+		folded[extendsKey] = info.Extends
+	}
+	return yaml.Marshal(folded)
+}
+
+// commonLayer holds the top-level blocks and the tests that are the same in every configuration
+func commonLayer(configurations []layer) (layer, error) {
+	common := layer{}
+	if len(configurations) == 0 {
+		return common, nil
+	}
+	first := configurations[0]
+	for key, value := range first {
+		if key == metadataKey || key == extendsKey || key == testsKey {
+			continue
+		}
+		shared := true
+		for _, other := range configurations[1:] {
+			if !reflect.DeepEqual(other[key], value) {
+				shared = false
+				break
+			}
+		}
+		if shared {
+			common[key] = value
+		}
+	}
+
+	testsByName := make([]map[string]interface{}, len(configurations))
+	for i, configuration := range configurations {
+		tests, err := testList(configuration[testsKey])
+		if err != nil {
+			return nil, err
+		}
+		testsByName[i] = map[string]interface{}{}
+		for _, test := range tests {
+			name, err := testName(test)
+			if err != nil {
+				return nil, err
+			}
+			testsByName[i][name] = test
+		}
+	}
+	firstTests, _ := testList(first[testsKey])
+	var tests []interface{}
+	for _, test := range firstTests {
+		name, _ := testName(test)
+		shared := true
+		for _, other := range testsByName[1:] {
+			if !reflect.DeepEqual(other[name], test) {
+				shared = false
+				break
+			}
+		}
+		if shared {
+			tests = append(tests, test)
+		}
+	}
+	if len(tests) > 0 {
+		common[testsKey] = tests
+	}
+	return common, nil
+}
+
+func containsTest(tests []interface{}, test interface{}) bool {
+	for _, candidate := range tests {
+		if reflect.DeepEqual(candidate, test) {
+			return true
+		}
+	}
+	return false
+}
+
+// FoldRepoDefaults moves the blocks and tests that all configurations of a repo
+// share into the defaults of the repo in the directory. Blocks the defaults of
+// the org already hold are left there. The configurations are not written, commit
+// them afterwards to drop what their defaults now hold.
+func FoldRepoDefaults(dir string, configurations []DataWithInfo) error {
+	byRepo := map[string][]DataWithInfo{}
+	var repos []string
+	for _, configuration := range configurations {
+		key := filepath.Join(configuration.Info.Org, configuration.Info.Repo)
+		if _, seen := byRepo[key]; !seen {
+			repos = append(repos, key)
+		}
+		byRepo[key] = append(byRepo[key], configuration)
+	}
+	sort.Strings(repos)
+	for _, repo := range repos {
+		if len(byRepo[repo]) < 2 {
+			continue
+		}
+		if err := foldRepoDefaults(dir, byRepo[repo]); err != nil {
+			return fmt.Errorf("failed to fold the defaults of %s: %w", repo, err)
+		}
+	}
+	return nil
+}
+
+func foldRepoDefaults(dir string, configurations []DataWithInfo) error {
+	var full []layer
+	for i := range configurations {
+		configuration, err := configurationLayer(&configurations[i].Configuration)
+		if err != nil {
+			return err
+		}
+		full = append(full, configuration)
+	}
+	common, err := commonLayer(full)
+	if err != nil {
+		return err
+	}
+
+	info := configurations[0].Info
+	resolver := layerResolver{root: dir, org: info.Org, repo: info.Repo}
+	orgPath, repoPath := resolver.defaultsPaths()[0], resolver.defaultsPaths()[1]
+	orgDefaults, _, err := readLayer(orgPath)
+	if err != nil {
+		return err
+	}
+	orgBase, err := resolver.apply(layer{}, orgDefaults, nil)
+	if err != nil {
+		return err
+	}
+	for key, value := range common {
+		if key != testsKey && reflect.DeepEqual(orgBase[key], value) {
+			delete(common, key)
+		}
+	}
+	inherited, err := testList(orgBase[testsKey])
+	if err != nil {
+		return err
+	}
+	sharedTests, _ := testList(common[testsKey])
+	var tests []interface{}
+	for _, test := range sharedTests {
+		if !containsTest(inherited, test) {
+			tests = append(tests, test)
+		}
+	}
+	setTests(common, tests, nil)
+	if len(common) == 0 {
+		return nil
+	}
+
+	repoDefaults, _, err := readLayer(repoPath)
+	if err != nil {
+		return err
+	}
+	if repoDefaults == nil {
+		repoDefaults = layer{}
+	}
+	defaults, err := mergeLayer(repoDefaults, common)
+	if err != nil {
+		return err
+	}
+	if extends, ok := repoDefaults[extendsKey]; ok {
+		defaults[extendsKey] = extends
+	}
+	raw, err := yaml.Marshal(defaults)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(repoPath), os.ModePerm); err != nil {
+		return err
+	}
+	return os.WriteFile(repoPath, raw, 0664)
+}
+
+// RemoveLayers deletes the defaults and fragments in the directory, the
+// configurations need to be committed with nothing to extend afterwards
+// so that they hold all of their content themselves
+func RemoveLayers(dir string) error {
+	var toRemove []string
+	if err := filepath.WalkDir(dir, func(path string, info fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if isInheritanceFile(info) {
+			toRemove = append(toRemove, path)
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	for _, path := range toRemove {
+		if err := os.RemoveAll(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
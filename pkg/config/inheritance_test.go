@@ -0,0 +1,501 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/openshift/ci-tools/pkg/api"
+)
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(raw)
+}
+
+const (
+	goFragment = `build_root:
+  image_stream_tag:
+    name: release
+    namespace: openshift
+    tag: golang-1.22
+`
+	orgDefaults = `resources:
+  '*':
+    limits:
+      memory: 4Gi
+    requests:
+      cpu: 100m
+      memory: 200Mi
+`
+	repoDefaults = `tests:
+- as: unit
+  commands: make test
+  container:
+    from: src
+- as: lint
+  commands: make lint
+  container:
+    from: src
+`
+	e2eFragment = `extends:
+- go
+tests:
+- as: e2e
+  commands: make e2e
+  container:
+    from: src
+`
+	layeredConfig = `exclude_tests:
+- unit
+extends:
+- e2e
+resources:
+  '*':
+    limits: null
+tests:
+- as: lint
+  commands: make verify
+  container:
+    from: src
+zz_generated_metadata:
+  branch: master
+  org: org
+  repo: repo
+`
+)
+
+func TestLoadLayeredConfiguration(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"_fragments/go.yaml":            goFragment,
+		"org/_defaults.yaml":            orgDefaults,
+		"org/_fragments/e2e.yaml":       e2eFragment,
+		"org/repo/_defaults.yaml":       repoDefaults,
+		"org/repo/org-repo-master.yaml": layeredConfig,
+	})
+
+	loaded, err := LoadDataByFilename(root)
+	if err != nil {
+		t.Fatalf("failed to load: %v", err)
+	}
+	if len(loaded) != 1 {
+		t.Fatalf("expected only the configuration to be loaded, got %d", len(loaded))
+	}
+	expected := api.ReleaseBuildConfiguration{
+		Metadata: api.Metadata{Org: "org", Repo: "repo", Branch: "master"},
+		InputConfiguration: api.InputConfiguration{
+			BuildRootImage: &api.BuildRootImageConfiguration{
+				ImageStreamTagReference: &api.ImageStreamTagReference{Name: "release", Namespace: "openshift", Tag: "golang-1.22"},
+			},
+		},
+		Resources: api.ResourceConfiguration{"*": {Requests: api.ResourceList{"cpu": "100m", "memory": "200Mi"}}},
+		Tests: []api.TestStepConfiguration{
+			{As: "lint", Commands: "make verify", ContainerTestConfiguration: &api.ContainerTestConfiguration{From: "src"}},
+			{As: "e2e", Commands: "make e2e", ContainerTestConfiguration: &api.ContainerTestConfiguration{From: "src"}},
+		},
+	}
+	if diff := cmp.Diff(expected, loaded["org-repo-master.yaml"].Configuration); diff != "" {
+		t.Errorf("unexpected configuration (-want, +got): %s", diff)
+	}
+	if diff := cmp.Diff([]string{"e2e"}, loaded["org-repo-master.yaml"].Info.Extends); diff != "" {
+		t.Errorf("unexpected fragments (-want, +got): %s", diff)
+	}
+}
+
+func TestLoadLayeredConfigurationErrors(t *testing.T) {
+	testCases := []struct {
+		name     string
+		files    map[string]string
+		expected string
+	}{
+		{
+			name: "missing fragment",
+			files: map[string]string{
+				"org/repo/org-repo-master.yaml": "extends:\n- missing\n",
+			},
+			expected: `fragment "missing" not found`,
+		},
+		{
+			name: "fragments extending each other",
+			files: map[string]string{
+				"_fragments/a.yaml":             "extends:\n- b\n",
+				"_fragments/b.yaml":             "extends:\n- a\n",
+				"org/repo/org-repo-master.yaml": "extends:\n- a\n",
+			},
+			expected: "fragments extend each other: a -> b -> a",
+		},
+		{
+			name: "fragment outside of the fragments",
+			files: map[string]string{
+				"org/repo/org-repo-master.yaml": "extends:\n- ../secret\n",
+			},
+			expected: `invalid fragment name "../secret"`,
+		},
+		{
+			name: "test without a name",
+			files: map[string]string{
+				"org/_defaults.yaml":            "tests:\n- commands: make\n",
+				"org/repo/org-repo-master.yaml": "tests: []\n",
+			},
+			expected: `every test must have a name in "as" to be merged`,
+		},
+		{
+			name: "exclusions without defaults",
+			files: map[string]string{
+				"org/repo/org-repo-master.yaml": "exclude_tests:\n- unit\ntests:\n- as: unit\n  commands: make\n  container:\n    from: src\n",
+			},
+			expected: `the configuration uses "exclude_tests", but it does not inherit any tests`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			root := t.TempDir()
+			writeFiles(t, root, tc.files)
+			_, err := LoadDataByFilename(root)
+			if err == nil || !strings.Contains(err.Error(), tc.expected) {
+				t.Errorf("expected an error containing %q, got %v", tc.expected, err)
+			}
+		})
+	}
+}
+
+func TestCommitFoldedToFoldsConfiguration(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"_fragments/go.yaml":            goFragment,
+		"org/_defaults.yaml":            orgDefaults,
+		"org/_fragments/e2e.yaml":       e2eFragment,
+		"org/repo/_defaults.yaml":       repoDefaults,
+		"org/repo/org-repo-master.yaml": layeredConfig,
+	})
+	loaded, err := LoadDataByFilename(root)
+	if err != nil {
+		t.Fatalf("failed to load: %v", err)
+	}
+	data := loaded["org-repo-master.yaml"]
+	if err := data.CommitFoldedTo(root); err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+	if diff := cmp.Diff(layeredConfig, readFile(t, filepath.Join(root, "org/repo/org-repo-master.yaml"))); diff != "" {
+		t.Errorf("committing the loaded configuration changed it (-want, +got): %s", diff)
+	}
+
+	unit := api.TestStepConfiguration{As: "unit", Commands: "make test", ContainerTestConfiguration: &api.ContainerTestConfiguration{From: "src"}}
+	data.Configuration.Tests = append([]api.TestStepConfiguration{unit}, data.Configuration.Tests...)
+	if err := data.CommitFoldedTo(root); err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+	expected := `extends:
+- e2e
+resources:
+  '*':
+    limits: null
+tests:
+- as: lint
+  commands: make verify
+  container:
+    from: src
+zz_generated_metadata:
+  branch: master
+  org: org
+  repo: repo
+`
+	if diff := cmp.Diff(expected, readFile(t, filepath.Join(root, "org/repo/org-repo-master.yaml"))); diff != "" {
+		t.Errorf("unexpected folded configuration (-want, +got): %s", diff)
+	}
+}
+
+func TestFoldAndFlattenRepoDefaults(t *testing.T) {
+	configuration := func(branch, e2eCommands string) string {
+		return `build_root:
+  image_stream_tag:
+    name: release
+    namespace: openshift
+    tag: golang-1.22
+resources:
+  '*':
+    requests:
+      cpu: 100m
+tests:
+- as: unit
+  commands: make test
+  container:
+    from: src
+- as: e2e
+  commands: ` + e2eCommands + `
+  container:
+    from: src
+zz_generated_metadata:
+  branch: ` + branch + `
+  org: org
+  repo: repo
+`
+	}
+	flat := map[string]string{
+		"org/repo/org-repo-master.yaml":      configuration("master", "make e2e"),
+		"org/repo/org-repo-release-4.1.yaml": configuration("release-4.1", "make e2e-4.1"),
+		"org/other/org-other-master.yaml": strings.ReplaceAll(strings.ReplaceAll(configuration("master", "make e2e"),
+			"repo: repo", "repo: other"), "golang-1.22", "golang-1.21"),
+	}
+	root := t.TempDir()
+	writeFiles(t, root, flat)
+	writeFiles(t, root, map[string]string{"org/_defaults.yaml": "resources:\n  '*':\n    requests:\n      cpu: 100m\n"})
+
+	commitAll := func() {
+		loaded, err := LoadDataByFilename(root)
+		if err != nil {
+			t.Fatalf("failed to load: %v", err)
+		}
+		var all []DataWithInfo
+		for _, data := range loaded {
+			all = append(all, data)
+		}
+		if err := FoldRepoDefaults(root, all); err != nil {
+			t.Fatalf("failed to fold: %v", err)
+		}
+		for _, data := range all {
+			if err := data.CommitFoldedTo(root); err != nil {
+				t.Fatalf("failed to commit: %v", err)
+			}
+		}
+	}
+	before, err := LoadDataByFilename(root)
+	if err != nil {
+		t.Fatalf("failed to load: %v", err)
+	}
+	commitAll()
+
+	expectedDefaults := `build_root:
+  image_stream_tag:
+    name: release
+    namespace: openshift
+    tag: golang-1.22
+tests:
+- as: unit
+  commands: make test
+  container:
+    from: src
+`
+	if diff := cmp.Diff(expectedDefaults, readFile(t, filepath.Join(root, "org/repo", DefaultsFileName))); diff != "" {
+		t.Errorf("unexpected repo defaults (-want, +got): %s", diff)
+	}
+	if _, err := os.Stat(filepath.Join(root, "org/other", DefaultsFileName)); !os.IsNotExist(err) {
+		t.Errorf("expected no defaults for a repo with a single configuration, got %v", err)
+	}
+	expectedMaster := `tests:
+- as: e2e
+  commands: make e2e
+  container:
+    from: src
+zz_generated_metadata:
+  branch: master
+  org: org
+  repo: repo
+`
+	if diff := cmp.Diff(expectedMaster, readFile(t, filepath.Join(root, "org/repo/org-repo-master.yaml"))); diff != "" {
+		t.Errorf("unexpected folded configuration (-want, +got): %s", diff)
+	}
+	folded, err := LoadDataByFilename(root)
+	if err != nil {
+		t.Fatalf("failed to load: %v", err)
+	}
+	if diff := cmp.Diff(before, folded); diff != "" {
+		t.Errorf("folding changed the configurations (-want, +got): %s", diff)
+	}
+
+	if err := RemoveLayers(root); err != nil {
+		t.Fatalf("failed to remove the layers: %v", err)
+	}
+	for _, data := range folded {
+		data.Info.Extends = nil
+		if err := data.CommitFoldedTo(root); err != nil {
+			t.Fatalf("failed to commit: %v", err)
+		}
+	}
+	for name, content := range flat {
+		if diff := cmp.Diff(content, readFile(t, filepath.Join(root, name))); diff != "" {
+			t.Errorf("%s: flattening did not restore the configuration (-want, +got): %s", name, diff)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(root, "org", DefaultsFileName)); !os.IsNotExist(err) {
+		t.Errorf("expected the org defaults to be removed, got %v", err)
+	}
+}
+
+func TestCommitToWritesExpandedConfiguration(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"_fragments/go.yaml":            goFragment,
+		"org/_defaults.yaml":            orgDefaults,
+		"org/_fragments/e2e.yaml":       e2eFragment,
+		"org/repo/_defaults.yaml":       repoDefaults,
+		"org/repo/org-repo-master.yaml": layeredConfig,
+	})
+	loaded, err := LoadDataByFilename(root)
+	if err != nil {
+		t.Fatalf("failed to load: %v", err)
+	}
+	data := loaded["org-repo-master.yaml"]
+	if err := data.CommitTo(root); err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+	expected := `build_root:
+  image_stream_tag:
+    name: release
+    namespace: openshift
+    tag: golang-1.22
+resources:
+  '*':
+    requests:
+      cpu: 100m
+      memory: 200Mi
+tests:
+- as: lint
+  commands: make verify
+  container:
+    from: src
+- as: e2e
+  commands: make e2e
+  container:
+    from: src
+zz_generated_metadata:
+  branch: master
+  org: org
+  repo: repo
+`
+	if diff := cmp.Diff(expected, readFile(t, filepath.Join(root, "org/repo/org-repo-master.yaml"))); diff != "" {
+		t.Errorf("unexpected expanded configuration (-want, +got): %s", diff)
+	}
+}
+
+func TestExpandLayers(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"_fragments/go.yaml":            goFragment,
+		"org/_defaults.yaml":            orgDefaults,
+		"org/_fragments/e2e.yaml":       e2eFragment,
+		"org/repo/_defaults.yaml":       repoDefaults,
+		"org/repo/org-repo-master.yaml": layeredConfig,
+		"other/repo/other-repo-master.yaml": `exclude_tests:
+- unit
+`,
+		"other/plain/other-plain-master.yaml": "resources: {}\n",
+		"other/empty/other-empty-master.yaml": "extends: []\nresources: {}\n",
+	})
+	testCases := []struct {
+		name          string
+		path          string
+		expectedTests []string
+		expectedError string
+	}{
+		{
+			name:          "layered configuration is expanded",
+			path:          "org/repo/org-repo-master.yaml",
+			expectedTests: []string{"lint", "e2e"},
+		},
+		{
+			name:          "configuration without layers is unchanged",
+			path:          "other/plain/other-plain-master.yaml",
+			expectedTests: []string{},
+		},
+		{
+			name:          "empty list of fragments without defaults is dropped",
+			path:          "other/empty/other-empty-master.yaml",
+			expectedTests: []string{},
+		},
+		{
+			name:          "exclusions without defaults are rejected",
+			path:          "other/repo/other-repo-master.yaml",
+			expectedError: `the configuration uses "exclude_tests"`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(root, tc.path)
+			data := []byte(readFile(t, path))
+			expanded, err := ExpandLayers(path, data)
+			if len(tc.expectedError) > 0 {
+				if err == nil || !strings.Contains(err.Error(), tc.expectedError) {
+					t.Fatalf("expected an error containing %q, got %v", tc.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var configuration api.ReleaseBuildConfiguration
+			if err := yaml.UnmarshalStrict(expanded, &configuration); err != nil {
+				t.Fatalf("the expanded configuration cannot be decoded strictly: %v", err)
+			}
+			tests := []string{}
+			for _, test := range configuration.Tests {
+				tests = append(tests, test.As)
+			}
+			if diff := cmp.Diff(tc.expectedTests, tests); diff != "" {
+				t.Errorf("unexpected tests (-want, +got): %s", diff)
+			}
+			if len(configuration.Extends) > 0 {
+				t.Errorf("expected the expanded configuration to extend nothing, got %v", configuration.Extends)
+			}
+		})
+	}
+}
+
+func TestRejectLayers(t *testing.T) {
+	for raw, rejected := range map[string]bool{
+		"resources: {}\n":             false,
+		"extends:\n- e2e\n":           true,
+		"exclude_tests:\n- unit\n":    true,
+		"not: [valid yaml":            false,
+		`{"tests": [{"as": "unit"}]}`: false,
+	} {
+		if err := RejectLayers([]byte(raw)); (err != nil) != rejected {
+			t.Errorf("%q: expected rejection %v, got %v", raw, rejected, err)
+		}
+	}
+}
+
+func TestLayerCacheReadsFilesOnce(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, DefaultsFileName)
+	writeFiles(t, root, map[string]string{DefaultsFileName: orgDefaults})
+	cache := newLayerCache()
+	first, found, err := cache.read(path)
+	if err != nil || !found {
+		t.Fatalf("expected the defaults to be read, got %v", err)
+	}
+	writeFiles(t, root, map[string]string{DefaultsFileName: repoDefaults})
+	second, _, err := cache.read(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if diff := cmp.Diff(first, second); diff != "" {
+		t.Errorf("expected the cached defaults, got a difference (-want, +got): %s", diff)
+	}
+	if _, found, err := cache.read(filepath.Join(root, "missing.yaml")); err != nil || found {
+		t.Errorf("expected a missing file to be an empty layer, got found=%v, err=%v", found, err)
+	}
+}
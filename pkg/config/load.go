@@ -17,12 +17,18 @@ import (
 	"github.com/openshift/ci-tools/pkg/validation"
 )
 
-func readCiOperatorConfig(configFilePath string, info Info) (*cioperatorapi.ReleaseBuildConfiguration, error) {
+func readCiOperatorConfig(configFilePath string, info *Info, layers *layerCache) (*cioperatorapi.ReleaseBuildConfiguration, error) {
 	data, err := gzip.ReadFileMaybeGZIP(configFilePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read ci-operator config (%w)", err)
 	}
 
+	if info.OrgPath != "" {
+		if data, info.Extends, err = expandConfiguration(data, filepath.Dir(info.OrgPath), *info, layers); err != nil {
+			return nil, fmt.Errorf("failed to layer ci-operator config: %w", err)
+		}
+	}
+
 	var configSpec cioperatorapi.ReleaseBuildConfiguration
 	if err := yaml.Unmarshal(data, &configSpec); err != nil {
 		return nil, fmt.Errorf("failed to load ci-operator config (%w)", err)
@@ -45,6 +51,9 @@ type Info struct {
 	OrgPath string
 	// RepoPath is the full path to the directory containing config for the repo
 	RepoPath string
+	// Extends are the fragments the file extends, the configuration loaded from
+	// it is layered over them already
+	Extends []string
 }
 
 type InfoIterFunc func(string, *Info) error
@@ -129,7 +138,7 @@ func OperateOnCIOperatorConfig(path string, callback ConfigIterFunc) error {
 		logrus.WithField("source-file", path).WithError(err).Error("Failed to resolve info from CI Operator configuration path")
 		return err
 	}
-	jobConfig, err := readCiOperatorConfig(path, *info)
+	jobConfig, err := readCiOperatorConfig(path, info, nil)
 	if err != nil {
 		logrus.WithField("source-file", path).WithError(err).Error("Failed to load CI Operator configuration")
 		return err
@@ -153,6 +162,7 @@ func OperateOnCIOperatorConfigSubdir(configDir, subDir string, callback ConfigIt
 		info   *Info
 	}
 	inputCh := make(chan string)
+	layers := newLayerCache()
 	produce := func() error {
 		defer close(inputCh)
 		return filepath.WalkDir(filepath.Join(configDir, subDir), func(path string, info fs.DirEntry, err error) error {
@@ -160,7 +170,7 @@ func OperateOnCIOperatorConfigSubdir(configDir, subDir string, callback ConfigIt
 				logrus.WithField("source-file", path).WithError(err).Error("Failed to walk CI Operator configuration dir")
 				return err
 			}
-			if isMountSpecialFile(info.Name()) {
+			if isMountSpecialFile(info.Name()) || isInheritanceFile(info) {
 				if info.IsDir() {
 					return filepath.SkipDir
 				}
@@ -182,7 +192,7 @@ func OperateOnCIOperatorConfigSubdir(configDir, subDir string, callback ConfigIt
 				errCh <- err
 				continue
 			}
-			config, err := readCiOperatorConfig(path, *info)
+			config, err := readCiOperatorConfig(path, info, layers)
 			if err != nil {
 				logrus.WithField("source-file", path).WithError(err).Error("Failed to load CI Operator configuration")
 				errCh <- err
@@ -214,6 +224,12 @@ func OperateOnCIOperatorConfigPaths(path string, callback InfoIterFunc) error {
 			logrus.WithField("source-file", path).WithError(err).Error("Failed to walk CI Operator configuration dir")
 			return err
 		}
+		if isInheritanceFile(fsInfo) {
+			if fsInfo.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
 		if !isConfigFile(fsInfo) {
 			return nil
 		}
@@ -240,12 +256,29 @@ func (i *DataWithInfo) Logger() *logrus.Entry {
 	return LoggerForInfo(i.Info)
 }
 
+// CommitTo writes the configuration to its file in the directory with all of its
+// content, including what the defaults and fragments it is layered on hold
 func (i *DataWithInfo) CommitTo(dir string) error {
 	raw, err := yaml.Marshal(i.Configuration)
 	if err != nil {
 		i.Logger().WithError(err).Error("failed to marshal output CI Operator configuration")
 		return err
 	}
+	return i.commit(dir, raw)
+}
+
+// CommitFoldedTo writes the configuration to its file in the directory, leaving out
+// what the defaults and fragments it is layered on in the directory hold
+func (i *DataWithInfo) CommitFoldedTo(dir string) error {
+	raw, err := foldConfiguration(dir, i.Info, &i.Configuration)
+	if err != nil {
+		i.Logger().WithError(err).Error("failed to marshal output CI Operator configuration")
+		return err
+	}
+	return i.commit(dir, raw)
+}
+
+func (i *DataWithInfo) commit(dir string, raw []byte) error {
 	outputFile := path.Join(dir, i.Info.RelativePath())
 	if err := os.MkdirAll(path.Dir(outputFile), os.ModePerm); err != nil && !os.IsExist(err) {
 		i.Logger().WithError(err).Error("failed to ensure directory existed for new CI Operator configuration")
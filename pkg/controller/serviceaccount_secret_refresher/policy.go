@@ -0,0 +1,190 @@
+package serviceaccountsecretrefresher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/slack-go/slack"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	policyv1 "github.com/openshift/ci-tools/pkg/api/serviceaccountsecretpolicy/v1"
+)
+
+const (
+	// DefaultPolicyName names the policy that applies to the namespaces enabled by flags
+	DefaultPolicyName = "default"
+	// DefaultTTL is the age after which secrets are rotated by the default policy
+	DefaultTTL = 30 * 24 * time.Hour
+)
+
+// Policy determines how the secrets of a service account are rotated
+type Policy struct {
+	Name string
+	// TTL is the age after which the pull secrets are rotated
+	TTL time.Duration
+	// GracePeriod is how long rotated secrets are kept before they are deleted
+	GracePeriod time.Duration
+	// NotifyChannel is the Slack channel to notify about rotations, if any
+	NotifyChannel string
+}
+
+func defaultPolicy() Policy {
+	return Policy{Name: DefaultPolicyName, TTL: DefaultTTL, GracePeriod: DefaultTTL}
+}
+
+// validatePolicy checks that a policy can be applied
+func validatePolicy(policy *policyv1.ServiceAccountSecretPolicy) error {
+	var errs []error
+	if policy.Spec.TTL.Duration <= 0 {
+		errs = append(errs, errors.New("ttl must be positive"))
+	}
+	if policy.Spec.GracePeriod != nil && policy.Spec.GracePeriod.Duration < 0 {
+		errs = append(errs, errors.New("gracePeriod must not be negative"))
+	}
+	if len(policy.Spec.Namespaces) == 0 && policy.Spec.Selector == nil {
+		errs = append(errs, errors.New("at least one of namespaces and selector must be set"))
+	}
+	if policy.Spec.Selector != nil {
+		if _, err := metav1.LabelSelectorAsSelector(policy.Spec.Selector); err != nil {
+			errs = append(errs, fmt.Errorf("invalid selector: %w", err))
+		}
+	}
+	for _, ignored := range policy.Spec.IgnoreServiceAccounts {
+		if namespace, name, ok := strings.Cut(ignored, "/"); !ok || namespace == "" || name == "" {
+			errs = append(errs, fmt.Errorf("ignored service account %q is not in namespace/name format", ignored))
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// policyMatches determines whether a valid policy selects the service account on the cluster
+func policyMatches(policy *policyv1.ServiceAccountSecretPolicy, cluster string, sa *corev1.ServiceAccount) bool {
+	spec := policy.Spec
+	if len(spec.Clusters) > 0 && !sets.New(spec.Clusters...).Has(cluster) {
+		return false
+	}
+	if len(spec.Namespaces) > 0 && !sets.New(spec.Namespaces...).Has(sa.Namespace) {
+		return false
+	}
+	if sets.New(spec.IgnoreServiceAccounts...).Has(sa.Namespace + "/" + sa.Name) {
+		return false
+	}
+	if spec.Selector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(spec.Selector)
+		if err != nil {
+			return false
+		}
+		return selector.Matches(labels.Set(sa.Labels))
+	}
+	return true
+}
+
+// policyResolver finds the policy that applies to a service account
+type policyResolver struct {
+	cluster string
+	// policyClient reads the ServiceAccountSecretPolicies, it is nil when they are not used
+	policyClient ctrlruntimeclient.Reader
+	// ignored are service accounts in namespace/name format that are never managed, whatever policy selects them
+	ignored sets.Set[string]
+	// filter selects the service accounts the default policy applies to
+	filter        func(reconcile.Request) bool
+	defaultPolicy Policy
+}
+
+// ignores determines whether the service account is excluded from all policies
+func (r *policyResolver) ignores(req reconcile.Request) bool {
+	return r.ignored.Has(req.String())
+}
+
+// list returns the ServiceAccountSecretPolicies sorted by name, or nothing when they are not used
+func (r *policyResolver) list(ctx context.Context) ([]policyv1.ServiceAccountSecretPolicy, error) {
+	if r.policyClient == nil {
+		return nil, nil
+	}
+	policies := &policyv1.ServiceAccountSecretPolicyList{}
+	if err := r.policyClient.List(ctx, policies); err != nil {
+		return nil, fmt.Errorf("failed to list ServiceAccountSecretPolicies: %w", err)
+	}
+	sort.Slice(policies.Items, func(i, j int) bool { return policies.Items[i].Name < policies.Items[j].Name })
+	return policies.Items, nil
+}
+
+// resolve returns the policy for the service account, or nil if its secrets are not managed.
+func (r *policyResolver) resolve(ctx context.Context, log *logrus.Entry, sa *corev1.ServiceAccount) (*Policy, error) {
+	policies, err := r.list(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.choose(log, policies, sa), nil
+}
+
+// choose picks the policy for the service account. When several policies select it, the one with
+// the shortest TTL wins, as rotating too rarely is worse than rotating too often. The default
+// policy applies to the service accounts that no policy selects but the filter does. Ignored
+// service accounts are not managed at all.
+func (r *policyResolver) choose(log *logrus.Entry, policies []policyv1.ServiceAccountSecretPolicy, sa *corev1.ServiceAccount) *Policy {
+	req := reconcile.Request{NamespacedName: ctrlruntimeclient.ObjectKeyFromObject(sa)}
+	if r.ignores(req) {
+		return nil
+	}
+	var resolved *Policy
+	for i := range policies {
+		policy := &policies[i]
+		if err := validatePolicy(policy); err != nil {
+			log.WithError(err).WithField("policy", policy.Name).Warn("Ignoring invalid ServiceAccountSecretPolicy")
+			continue
+		}
+		if !policyMatches(policy, r.cluster, sa) {
+			continue
+		}
+		if resolved == nil || policy.Spec.TTL.Duration < resolved.TTL {
+			resolved = &Policy{
+				Name:          policy.Name,
+				TTL:           policy.Spec.TTL.Duration,
+				GracePeriod:   policy.Spec.EffectiveGracePeriod(),
+				NotifyChannel: policy.Spec.NotifyChannel,
+			}
+		}
+	}
+	if resolved != nil {
+		return resolved
+	}
+	if r.filter(req) {
+		policy := r.defaultPolicy
+		return &policy
+	}
+	return nil
+}
+
+// SlackClient posts the rotation notifications
+type SlackClient interface {
+	PostMessage(channelID string, options ...slack.MsgOption) (string, string, error)
+}
+
+// notifyRotation tells the channel of the policy that the pull secrets of a service account were rotated
+func notifyRotation(client SlackClient, cluster string, policy *Policy, sa *corev1.ServiceAccount, rotated []string, removeOldSecrets bool) error {
+	if client == nil || policy.NotifyChannel == "" {
+		return nil
+	}
+	text := fmt.Sprintf("Rotated the secrets `%s` of service account `%s/%s` on cluster `%s` following policy `%s`.",
+		strings.Join(rotated, "`, `"), sa.Namespace, sa.Name, cluster, policy.Name)
+	if removeOldSecrets {
+		text += fmt.Sprintf(" The rotated secrets will be deleted after a grace period of %s.", policy.GracePeriod.String())
+	}
+	if _, _, err := client.PostMessage(policy.NotifyChannel, slack.MsgOptionText(text, false)); err != nil {
+		return fmt.Errorf("failed to notify channel %s: %w", policy.NotifyChannel, err)
+	}
+	return nil
+}
@@ -0,0 +1,296 @@
+package serviceaccountsecretrefresher
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sirupsen/logrus"
+	"github.com/slack-go/slack"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	fakectrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	policyv1 "github.com/openshift/ci-tools/pkg/api/serviceaccountsecretpolicy/v1"
+	"github.com/openshift/ci-tools/pkg/testhelper"
+)
+
+func policy(name string, ttl time.Duration, mod ...func(*policyv1.ServiceAccountSecretPolicySpec)) *policyv1.ServiceAccountSecretPolicy {
+	p := &policyv1.ServiceAccountSecretPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec:       policyv1.ServiceAccountSecretPolicySpec{TTL: metav1.Duration{Duration: ttl}},
+	}
+	for _, m := range mod {
+		m(&p.Spec)
+	}
+	return p
+}
+
+func TestValidatePolicy(t *testing.T) {
+	testCases := []struct {
+		name          string
+		policy        *policyv1.ServiceAccountSecretPolicy
+		expectedError error
+	}{
+		{
+			name: "valid",
+			policy: policy("valid", time.Hour, func(s *policyv1.ServiceAccountSecretPolicySpec) {
+				s.Namespaces = []string{"ci"}
+				s.IgnoreServiceAccounts = []string{"ci/sa"}
+			}),
+		},
+		{
+			name:          "everything is wrong",
+			policy:        policy("invalid", 0, func(s *policyv1.ServiceAccountSecretPolicySpec) { s.IgnoreServiceAccounts = []string{"sa"} }),
+			expectedError: errors.New(`[ttl must be positive, at least one of namespaces and selector must be set, ignored service account "sa" is not in namespace/name format]`),
+		},
+		{
+			name: "invalid selector",
+			policy: policy("invalid", time.Hour, func(s *policyv1.ServiceAccountSecretPolicySpec) {
+				s.Selector = &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "a", Operator: "Unknown"}}}
+			}),
+			expectedError: errors.New(`invalid selector: "Unknown" is not a valid label selector operator`),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.expectedError, validatePolicy(tc.policy), testhelper.EquateErrorMessage); diff != "" {
+				t.Errorf("unexpected error: %s", diff)
+			}
+		})
+	}
+}
+
+func TestResolvePolicy(t *testing.T) {
+	sa := &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Namespace: "ci", Name: "sa", Labels: map[string]string{"rotation": "fast"}}}
+	defaultFilter := func(r reconcile.Request) bool { return r.Namespace == "ci" }
+	fastSelector := func(s *policyv1.ServiceAccountSecretPolicySpec) {
+		s.Selector = &metav1.LabelSelector{MatchLabels: map[string]string{"rotation": "fast"}}
+	}
+	grace := &metav1.Duration{Duration: time.Minute}
+
+	testCases := []struct {
+		name     string
+		policies []runtime.Object
+		filter   func(reconcile.Request) bool
+		ignored  []string
+		expected *Policy
+	}{
+		{
+			name:     "no policies, default applies",
+			filter:   defaultFilter,
+			expected: &Policy{Name: DefaultPolicyName, TTL: DefaultTTL, GracePeriod: DefaultTTL},
+		},
+		{
+			name:   "no policies and filtered out",
+			filter: func(reconcile.Request) bool { return false },
+		},
+		{
+			name: "matching policy overrides the default",
+			policies: []runtime.Object{policy("ci", time.Hour, func(s *policyv1.ServiceAccountSecretPolicySpec) {
+				s.Namespaces = []string{"ci"}
+				s.NotifyChannel = "#ci"
+			})},
+			filter:   defaultFilter,
+			expected: &Policy{Name: "ci", TTL: time.Hour, GracePeriod: time.Hour, NotifyChannel: "#ci"},
+		},
+		{
+			name: "policy manages service accounts the filter does not",
+			policies: []runtime.Object{policy("fast", time.Hour, fastSelector, func(s *policyv1.ServiceAccountSecretPolicySpec) {
+				s.GracePeriod = grace
+			})},
+			filter:   func(reconcile.Request) bool { return false },
+			expected: &Policy{Name: "fast", TTL: time.Hour, GracePeriod: time.Minute},
+		},
+		{
+			name:     "globally ignored service accounts are not managed by matching policies",
+			policies: []runtime.Object{policy("fast", time.Hour, fastSelector)},
+			filter:   defaultFilter,
+			ignored:  []string{"ci/sa"},
+		},
+		{
+			name: "shortest ttl wins",
+			policies: []runtime.Object{
+				policy("ci", 2*time.Hour, func(s *policyv1.ServiceAccountSecretPolicySpec) { s.Namespaces = []string{"ci"} }),
+				policy("fast", time.Hour, fastSelector),
+			},
+			expected: &Policy{Name: "fast", TTL: time.Hour, GracePeriod: time.Hour},
+		},
+		{
+			name: "non-matching, ignoring and invalid policies do not apply",
+			policies: []runtime.Object{
+				policy("other-namespace", time.Hour, func(s *policyv1.ServiceAccountSecretPolicySpec) { s.Namespaces = []string{"other"} }),
+				policy("other-cluster", time.Hour, fastSelector, func(s *policyv1.ServiceAccountSecretPolicySpec) { s.Clusters = []string{"build02"} }),
+				policy("other-labels", time.Hour, func(s *policyv1.ServiceAccountSecretPolicySpec) {
+					s.Selector = &metav1.LabelSelector{MatchLabels: map[string]string{"rotation": "slow"}}
+				}),
+				policy("ignoring", time.Hour, fastSelector, func(s *policyv1.ServiceAccountSecretPolicySpec) { s.IgnoreServiceAccounts = []string{"ci/sa"} }),
+				policy("invalid", time.Hour),
+			},
+			filter:   defaultFilter,
+			expected: &Policy{Name: DefaultPolicyName, TTL: DefaultTTL, GracePeriod: DefaultTTL},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.filter == nil {
+				tc.filter = func(reconcile.Request) bool { return true }
+			}
+			resolver := &policyResolver{
+				cluster:       "build01",
+				policyClient:  fakectrlruntimeclient.NewClientBuilder().WithRuntimeObjects(tc.policies...).Build(),
+				ignored:       sets.New(tc.ignored...),
+				filter:        tc.filter,
+				defaultPolicy: defaultPolicy(),
+			}
+			resolved, err := resolver.resolve(context.Background(), logrus.WithField("test", tc.name), sa)
+			if err != nil {
+				t.Fatalf("failed to resolve policy: %v", err)
+			}
+			if diff := cmp.Diff(tc.expected, resolved); diff != "" {
+				t.Errorf("unexpected policy: %s", diff)
+			}
+		})
+	}
+}
+
+func TestPolicyMapper(t *testing.T) {
+	client := fakectrlruntimeclient.NewClientBuilder().WithRuntimeObjects(
+		&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Namespace: "ci", Name: "a"}},
+		&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Namespace: "ci", Name: "b"}},
+		&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Namespace: "other", Name: "a"}},
+	).Build()
+	mapper := policyMapper(client, logrus.WithField("test", t.Name()), "build01")
+	requests := mapper(context.Background(), policy("ci", time.Hour, func(s *policyv1.ServiceAccountSecretPolicySpec) {
+		s.Namespaces = []string{"ci"}
+		s.IgnoreServiceAccounts = []string{"ci/b"}
+	}))
+	var names []string
+	for _, request := range requests {
+		names = append(names, request.String())
+	}
+	if diff := cmp.Diff([]string{"ci/a"}, names); diff != "" {
+		t.Errorf("unexpected requests: %s", diff)
+	}
+}
+
+type recordingSlackClient struct {
+	channels []string
+	err      error
+}
+
+func (c *recordingSlackClient) PostMessage(channelID string, _ ...slack.MsgOption) (string, string, error) {
+	c.channels = append(c.channels, channelID)
+	return "", "", c.err
+}
+
+func TestReconcileWithPolicy(t *testing.T) {
+	sa := &corev1.ServiceAccount{
+		ObjectMeta:       metav1.ObjectMeta{Namespace: "namespace", Name: "sa"},
+		ImagePullSecrets: []corev1.LocalObjectReference{{Name: "pull-secret"}},
+		Secrets:          []corev1.ObjectReference{{Name: "pull-secret"}, {Name: "token-secret"}},
+	}
+	// Younger than the default ttl, but older than the one of the policy
+	twoDaysOld := func(s *corev1.Secret) { s.CreationTimestamp = metav1.NewTime(time.Now().Add(-48 * time.Hour)) }
+
+	testCases := []struct {
+		name                     string
+		policy                   *policyv1.ServiceAccountSecretPolicy
+		slackErr                 error
+		expectedPullSecretName   string
+		expectedNotifiedChannels []string
+	}{
+		{
+			name:                   "default policy keeps the secret",
+			policy:                 policy("other", time.Hour, func(s *policyv1.ServiceAccountSecretPolicySpec) { s.Namespaces = []string{"other"} }),
+			expectedPullSecretName: "pull-secret",
+		},
+		{
+			name: "policy rotates the secret and notifies",
+			policy: policy("fast", 24*time.Hour, func(s *policyv1.ServiceAccountSecretPolicySpec) {
+				s.Namespaces = []string{"namespace"}
+				s.NotifyChannel = "#rotations"
+			}),
+			expectedPullSecretName:   "new-pull-secret",
+			expectedNotifiedChannels: []string{"#rotations"},
+		},
+		{
+			name: "failing notification does not fail the reconciliation",
+			policy: policy("fast", 24*time.Hour, func(s *policyv1.ServiceAccountSecretPolicySpec) {
+				s.Namespaces = []string{"namespace"}
+				s.NotifyChannel = "#rotations"
+			}),
+			slackErr:                 errors.New("channel not found"),
+			expectedPullSecretName:   "new-pull-secret",
+			expectedNotifiedChannels: []string{"#rotations"},
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			client := &serviceaccountSecretRecreatingClient{t: t, Client: fakectrlruntimeclient.NewClientBuilder().WithRuntimeObjects(
+				sa.DeepCopy(),
+				secretForSA(sa, corev1.SecretTypeDockercfg, func(s *corev1.Secret) { s.Name = "pull-secret" }, twoDaysOld),
+				secretForSA(sa, corev1.SecretTypeServiceAccountToken, func(s *corev1.Secret) { s.Name = "token-secret" }, twoDaysOld),
+				tc.policy,
+			).Build()}
+			slackClient := &recordingSlackClient{err: tc.slackErr}
+			r := &reconciler{
+				client:  client,
+				cluster: "build01",
+				policies: &policyResolver{
+					cluster:       "build01",
+					policyClient:  client,
+					filter:        func(r reconcile.Request) bool { return r.Namespace == "namespace" },
+					defaultPolicy: defaultPolicy(),
+				},
+				slackClient: slackClient,
+				log:         logrus.WithField("test", tc.name),
+				second:      10 * time.Millisecond,
+			}
+
+			ctx := context.Background()
+			if _, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "namespace", Name: "sa"}}); err != nil {
+				t.Fatalf("reconciliation failed: %v", err)
+			}
+			actual := &corev1.ServiceAccount{}
+			if err := client.Get(ctx, types.NamespacedName{Namespace: "namespace", Name: "sa"}, actual); err != nil {
+				t.Fatalf("failed to get sa: %v", err)
+			}
+			var pullSecrets []string
+			for _, ref := range actual.ImagePullSecrets {
+				pullSecrets = append(pullSecrets, ref.Name)
+			}
+			if diff := cmp.Diff([]string{tc.expectedPullSecretName}, pullSecrets); diff != "" {
+				t.Errorf("unexpected pull secrets: %s", diff)
+			}
+			if diff := cmp.Diff(tc.expectedNotifiedChannels, slackClient.channels); diff != "" {
+				t.Errorf("unexpected notifications: %s", diff)
+			}
+		})
+	}
+}
+
+func TestNotifyRotation(t *testing.T) {
+	sa := &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Namespace: "ci", Name: "sa"}}
+	client := &recordingSlackClient{}
+	for _, p := range []*Policy{{Name: "silent"}, {Name: "loud", NotifyChannel: "#loud"}} {
+		if err := notifyRotation(client, "build01", p, sa, []string{"secret"}, true); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	if err := notifyRotation(nil, "build01", &Policy{NotifyChannel: "#nil-client"}, sa, []string{"secret"}, false); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if diff := cmp.Diff([]string{"#loud"}, client.channels); diff != "" {
+		t.Errorf("unexpected notifications: %s", diff)
+	}
+}
@@ -0,0 +1,283 @@
+package serviceaccountsecretrefresher
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/duration"
+	"k8s.io/apimachinery/pkg/util/sets"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+// SecretReport describes a secret of a managed service account
+type SecretReport struct {
+	Cluster        string            `json:"cluster"`
+	Namespace      string            `json:"namespace"`
+	ServiceAccount string            `json:"serviceAccount"`
+	Secret         string            `json:"secret"`
+	Type           corev1.SecretType `json:"type"`
+	Policy         string            `json:"policy"`
+	Created        time.Time         `json:"created"`
+	Age            string            `json:"age"`
+	// Referenced is whether the service account still references the secret, rotated secrets are not referenced
+	Referenced bool `json:"referenced"`
+	// NextRotation is when the secret will be rotated, it is unset for secrets that are not rotated
+	NextRotation *time.Time `json:"nextRotation,omitempty"`
+	// Deletion is when the secret will be deleted, it is unset when old secrets are not removed
+	Deletion *time.Time `json:"deletion,omitempty"`
+	// ConsumerPods are the pods that reference the secret
+	ConsumerPods []string `json:"consumerPods,omitempty"`
+}
+
+// reportCacheTTL is how long the report of a cluster is served before it is created again, as that lists
+// the pods of every managed namespace without a cache
+const reportCacheTTL = 5 * time.Minute
+
+type cachedReport struct {
+	entries []SecretReport
+	created time.Time
+}
+
+// Reporter serves a JSON report of the secrets of all managed service accounts across the clusters
+type Reporter struct {
+	now func() time.Time
+
+	lock     sync.Mutex
+	clusters map[string]*reconciler
+
+	// cacheLock is held while a report is created, so that concurrent requests do not create it again
+	cacheLock sync.Mutex
+	cache     map[string]cachedReport
+}
+
+func NewReporter() *Reporter {
+	return &Reporter{now: time.Now, clusters: map[string]*reconciler{}, cache: map[string]cachedReport{}}
+}
+
+func (rep *Reporter) register(cluster string, r *reconciler) {
+	rep.lock.Lock()
+	defer rep.lock.Unlock()
+	rep.clusters[cluster] = r
+}
+
+// clusterReport returns the report of the cluster, which is created at most once per reportCacheTTL
+func (rep *Reporter) clusterReport(ctx context.Context, cluster string, r *reconciler) ([]SecretReport, error) {
+	rep.cacheLock.Lock()
+	defer rep.cacheLock.Unlock()
+	now := rep.now()
+	if cached, ok := rep.cache[cluster]; ok && now.Sub(cached.created) < reportCacheTTL {
+		return cached.entries, nil
+	}
+	entries, err := r.report(ctx, now)
+	if err != nil {
+		return nil, err
+	}
+	rep.cache[cluster] = cachedReport{entries: entries, created: now}
+	return entries, nil
+}
+
+// ServeHTTP writes the report, which can be limited with the cluster and namespace query parameters
+func (rep *Reporter) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	clusterFilter, namespaceFilter := req.URL.Query().Get("cluster"), req.URL.Query().Get("namespace")
+	rep.lock.Lock()
+	clusters := make(map[string]*reconciler, len(rep.clusters))
+	for cluster, r := range rep.clusters {
+		if clusterFilter == "" || cluster == clusterFilter {
+			clusters[cluster] = r
+		}
+	}
+	rep.lock.Unlock()
+
+	report := []SecretReport{}
+	for _, cluster := range sets.List(sets.KeySet(clusters)) {
+		entries, err := rep.clusterReport(req.Context(), cluster, clusters[cluster])
+		if err != nil {
+			logrus.WithError(err).WithField("cluster", cluster).Error("Failed to create the secret report")
+			http.Error(w, fmt.Sprintf("failed to create the report for cluster %s: %v", cluster, err), http.StatusInternalServerError)
+			return
+		}
+		for _, entry := range entries {
+			if namespaceFilter == "" || entry.Namespace == namespaceFilter {
+				report = append(report, entry)
+			}
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		logrus.WithError(err).Debug("Failed to write the secret report")
+	}
+}
+
+// loginHandler only passes on requests that authenticate with the username and password
+func loginHandler(username string, passwordGetter func() []byte, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		if !ok || user != username || subtle.ConstantTimeCompare([]byte(pass), passwordGetter()) != 1 {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ServeReport serves the report on the address for as long as the manager runs. Requests must
+// authenticate with the username and password.
+func ServeReport(mgr manager.Manager, address string, reporter *Reporter, username string, passwordGetter func() []byte) error {
+	mux := http.NewServeMux()
+	mux.Handle("/report", loginHandler(username, passwordGetter, reporter))
+	server := &http.Server{Addr: address, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	return mgr.Add(manager.RunnableFunc(func(ctx context.Context) error {
+		go func() {
+			<-ctx.Done()
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := server.Shutdown(shutdownCtx); err != nil {
+				logrus.WithError(err).Warn("Failed to shut down the report server")
+			}
+		}()
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return fmt.Errorf("failed to serve the secret report: %w", err)
+		}
+		return nil
+	}))
+}
+
+// report lists the secrets of the managed service accounts on the cluster
+func (r *reconciler) report(ctx context.Context, now time.Time) ([]SecretReport, error) {
+	policies, err := r.policies.list(ctx)
+	if err != nil {
+		return nil, err
+	}
+	serviceAccounts := &corev1.ServiceAccountList{}
+	if err := r.client.List(ctx, serviceAccounts); err != nil {
+		return nil, fmt.Errorf("failed to list ServiceAccounts: %w", err)
+	}
+	sort.Slice(serviceAccounts.Items, func(i, j int) bool {
+		a, b := serviceAccounts.Items[i], serviceAccounts.Items[j]
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Name < b.Name
+	})
+
+	secretsByNamespace := map[string][]corev1.Secret{}
+	consumersByNamespace := map[string]map[string][]string{}
+	var entries []SecretReport
+	for i := range serviceAccounts.Items {
+		sa := &serviceAccounts.Items[i]
+		policy := r.policies.choose(r.log, policies, sa)
+		if policy == nil {
+			continue
+		}
+		if _, listed := secretsByNamespace[sa.Namespace]; !listed {
+			secrets := &corev1.SecretList{}
+			if err := r.client.List(ctx, secrets, ctrlruntimeclient.InNamespace(sa.Namespace)); err != nil {
+				return nil, fmt.Errorf("failed to list secrets in %s namespace: %w", sa.Namespace, err)
+			}
+			sort.Slice(secrets.Items, func(i, j int) bool { return secrets.Items[i].Name < secrets.Items[j].Name })
+			secretsByNamespace[sa.Namespace] = secrets.Items
+			pods := &corev1.PodList{}
+			if err := r.podReader.List(ctx, pods, ctrlruntimeclient.InNamespace(sa.Namespace)); err != nil {
+				return nil, fmt.Errorf("failed to list pods in %s namespace: %w", sa.Namespace, err)
+			}
+			consumersByNamespace[sa.Namespace] = secretConsumers(pods.Items)
+		}
+
+		referenced := sets.New[string]()
+		for _, ref := range sa.ImagePullSecrets {
+			referenced.Insert(ref.Name)
+		}
+		for _, ref := range sa.Secrets {
+			referenced.Insert(ref.Name)
+		}
+		for j := range secretsByNamespace[sa.Namespace] {
+			secret := &secretsByNamespace[sa.Namespace][j]
+			if secret.Annotations[corev1.ServiceAccountUIDKey] != string(sa.UID) {
+				continue
+			}
+			entry := SecretReport{
+				Cluster:        r.cluster,
+				Namespace:      sa.Namespace,
+				ServiceAccount: sa.Name,
+				Secret:         secret.Name,
+				Type:           secret.Type,
+				Policy:         policy.Name,
+				Created:        secret.CreationTimestamp.Time,
+				Age:            duration.HumanDuration(now.Sub(secret.CreationTimestamp.Time)),
+				Referenced:     referenced.Has(secret.Name),
+				ConsumerPods:   consumersByNamespace[sa.Namespace][secret.Name],
+			}
+			log := r.log.WithField("secret", secret.Namespace+"/"+secret.Name)
+			// This controller does not rotate token secrets
+			if entry.Referenced && secret.Type != corev1.SecretTypeServiceAccountToken {
+				nextRotation := expiresAt(log, secret, policy.TTL)
+				entry.NextRotation = &nextRotation
+			}
+			if r.removeOldSecrets {
+				deletion := expiresAt(log, secret, policy.TTL+policy.GracePeriod)
+				entry.Deletion = &deletion
+			}
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// secretConsumers maps the names of secrets to the pods that reference them
+func secretConsumers(pods []corev1.Pod) map[string][]string {
+	consumers := map[string]sets.Set[string]{}
+	consume := func(secret, pod string) {
+		if secret == "" {
+			return
+		}
+		if _, ok := consumers[secret]; !ok {
+			consumers[secret] = sets.New[string]()
+		}
+		consumers[secret].Insert(pod)
+	}
+	for _, pod := range pods {
+		for _, ref := range pod.Spec.ImagePullSecrets {
+			consume(ref.Name, pod.Name)
+		}
+		for _, volume := range pod.Spec.Volumes {
+			if volume.Secret != nil {
+				consume(volume.Secret.SecretName, pod.Name)
+			}
+			if volume.Projected != nil {
+				for _, projection := range volume.Projected.Sources {
+					if projection.Secret != nil {
+						consume(projection.Secret.Name, pod.Name)
+					}
+				}
+			}
+		}
+		for _, container := range append(append([]corev1.Container{}, pod.Spec.InitContainers...), pod.Spec.Containers...) {
+			for _, env := range container.Env {
+				if env.ValueFrom != nil && env.ValueFrom.SecretKeyRef != nil {
+					consume(env.ValueFrom.SecretKeyRef.Name, pod.Name)
+				}
+			}
+			for _, envFrom := range container.EnvFrom {
+				if envFrom.SecretRef != nil {
+					consume(envFrom.SecretRef.Name, pod.Name)
+				}
+			}
+		}
+	}
+	result := make(map[string][]string, len(consumers))
+	for secret, pods := range consumers {
+		result[secret] = sets.List(pods)
+	}
+	return result
+}
@@ -0,0 +1,213 @@
+package serviceaccountsecretrefresher
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	fakectrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	policyv1 "github.com/openshift/ci-tools/pkg/api/serviceaccountsecretpolicy/v1"
+	"github.com/openshift/ci-tools/pkg/testhelper"
+)
+
+func TestReport(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	daysAgo := func(days int) func(*corev1.Secret) {
+		return func(s *corev1.Secret) {
+			s.CreationTimestamp = metav1.NewTime(now.Add(-time.Duration(days) * 24 * time.Hour))
+		}
+	}
+	named := func(name string) func(*corev1.Secret) {
+		return func(s *corev1.Secret) { s.Name = name }
+	}
+	managed := &corev1.ServiceAccount{
+		ObjectMeta:       metav1.ObjectMeta{Namespace: "ci", Name: "managed", UID: "managed-uid"},
+		ImagePullSecrets: []corev1.LocalObjectReference{{Name: "managed-dockercfg-new"}},
+		Secrets:          []corev1.ObjectReference{{Name: "managed-dockercfg-new"}, {Name: "managed-token"}},
+	}
+	fast := &corev1.ServiceAccount{
+		ObjectMeta:       metav1.ObjectMeta{Namespace: "team", Name: "fast", UID: "fast-uid", Labels: map[string]string{"rotation": "fast"}},
+		ImagePullSecrets: []corev1.LocalObjectReference{{Name: "fast-dockercfg"}},
+		Secrets:          []corev1.ObjectReference{{Name: "fast-dockercfg"}},
+	}
+	unmanaged := &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Namespace: "team", Name: "unmanaged", UID: "unmanaged-uid"}}
+
+	client := fakectrlruntimeclient.NewClientBuilder().WithRuntimeObjects(
+		managed, fast, unmanaged,
+		secretForSA(managed, corev1.SecretTypeDockercfg, named("managed-dockercfg-new"), daysAgo(10)),
+		secretForSA(managed, corev1.SecretTypeDockercfg, named("managed-dockercfg-old"), daysAgo(40)),
+		secretForSA(managed, corev1.SecretTypeServiceAccountToken, named("managed-token"), daysAgo(40)),
+		secretForSA(fast, corev1.SecretTypeDockercfg, named("fast-dockercfg"), daysAgo(2)),
+		secretForSA(unmanaged, corev1.SecretTypeDockercfg, named("unmanaged-dockercfg"), daysAgo(100)),
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ci", Name: "builder"},
+			Spec: corev1.PodSpec{
+				ImagePullSecrets: []corev1.LocalObjectReference{{Name: "managed-dockercfg-new"}},
+				Containers: []corev1.Container{{
+					Name: "test",
+					Env: []corev1.EnvVar{{Name: "TOKEN", ValueFrom: &corev1.EnvVarSource{
+						SecretKeyRef: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "managed-token"}, Key: "token"},
+					}}},
+				}},
+			},
+		},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ci", Name: "old-job"},
+			Spec: corev1.PodSpec{
+				Volumes: []corev1.Volume{{Name: "pull", VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: "managed-dockercfg-old"}}}},
+				InitContainers: []corev1.Container{{
+					Name:    "init",
+					EnvFrom: []corev1.EnvFromSource{{SecretRef: &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "managed-token"}}}},
+				}},
+			},
+		},
+		policy("fast", 7*24*time.Hour, func(s *policyv1.ServiceAccountSecretPolicySpec) {
+			s.Selector = &metav1.LabelSelector{MatchLabels: map[string]string{"rotation": "fast"}}
+			s.GracePeriod = &metav1.Duration{Duration: 24 * time.Hour}
+		}),
+	).Build()
+
+	reporter := NewReporter()
+	reporter.now = func() time.Time { return now }
+	reporter.register("build01", &reconciler{
+		client:    client,
+		podReader: client,
+		cluster:   "build01",
+		policies: &policyResolver{
+			cluster:       "build01",
+			policyClient:  client,
+			filter:        func(r reconcile.Request) bool { return r.Namespace == "ci" },
+			defaultPolicy: defaultPolicy(),
+		},
+		log:              logrus.WithField("test", t.Name()),
+		removeOldSecrets: true,
+	})
+	reporter.register("build02", &reconciler{
+		client:    fakectrlruntimeclient.NewClientBuilder().Build(),
+		podReader: fakectrlruntimeclient.NewClientBuilder().Build(),
+		cluster:   "build02",
+		policies:  &policyResolver{cluster: "build02", filter: func(reconcile.Request) bool { return true }, defaultPolicy: defaultPolicy()},
+		log:       logrus.WithField("test", t.Name()),
+	})
+
+	for _, query := range []struct{ suffix, query string }{
+		{suffix: "_all"},
+		{suffix: "_namespace", query: "?namespace=team"},
+		{suffix: "_cluster", query: "?cluster=build02"},
+	} {
+		recorder := httptest.NewRecorder()
+		reporter.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/report"+query.query, nil))
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("%q: expected status %d, got %d: %s", query.query, http.StatusOK, recorder.Code, recorder.Body.String())
+		}
+		var report []SecretReport
+		if err := json.Unmarshal(recorder.Body.Bytes(), &report); err != nil {
+			t.Fatalf("%q: failed to unmarshal report: %v", query.query, err)
+		}
+		testhelper.CompareWithFixture(t, report, testhelper.WithSuffix(query.suffix))
+	}
+}
+
+// countingReader counts the pod lists, which are not cached
+type countingReader struct {
+	ctrlruntimeclient.Reader
+	lists int
+}
+
+func (r *countingReader) List(ctx context.Context, list ctrlruntimeclient.ObjectList, opts ...ctrlruntimeclient.ListOption) error {
+	r.lists++
+	return r.Reader.List(ctx, list, opts...)
+}
+
+func TestReportIsCached(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	sa := &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Namespace: "ci", Name: "managed", UID: "managed-uid"}}
+	client := fakectrlruntimeclient.NewClientBuilder().WithRuntimeObjects(sa, secretForSA(sa, corev1.SecretTypeDockercfg)).Build()
+	podReader := &countingReader{Reader: client}
+
+	reporter := NewReporter()
+	reporter.now = func() time.Time { return now }
+	reporter.register("build01", &reconciler{
+		client:    client,
+		podReader: podReader,
+		cluster:   "build01",
+		policies:  &policyResolver{cluster: "build01", filter: func(reconcile.Request) bool { return true }, defaultPolicy: defaultPolicy()},
+		log:       logrus.WithField("test", t.Name()),
+	})
+
+	for _, step := range []struct {
+		query         string
+		advance       time.Duration
+		expectedLists int
+	}{
+		{expectedLists: 1},
+		{query: "?namespace=ci", expectedLists: 1},
+		{query: "?namespace=other", advance: reportCacheTTL - time.Second, expectedLists: 1},
+		{advance: time.Second, expectedLists: 2},
+	} {
+		now = now.Add(step.advance)
+		recorder := httptest.NewRecorder()
+		reporter.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/report"+step.query, nil))
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("%q: expected status %d, got %d: %s", step.query, http.StatusOK, recorder.Code, recorder.Body.String())
+		}
+		if podReader.lists != step.expectedLists {
+			t.Errorf("%q: expected %d pod lists, got %d", step.query, step.expectedLists, podReader.lists)
+		}
+	}
+}
+
+func TestLoginHandler(t *testing.T) {
+	handler := loginHandler("user", func() []byte { return []byte("secret") }, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for _, tc := range []struct {
+		name           string
+		user, password string
+		expected       int
+	}{
+		{name: "no credentials", expected: http.StatusUnauthorized},
+		{name: "wrong password", user: "user", password: "wrong", expected: http.StatusUnauthorized},
+		{name: "wrong user", user: "other", password: "secret", expected: http.StatusUnauthorized},
+		{name: "valid credentials", user: "user", password: "secret", expected: http.StatusOK},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/report", nil)
+			if tc.user != "" {
+				req.SetBasicAuth(tc.user, tc.password)
+			}
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, req)
+			if recorder.Code != tc.expected {
+				t.Errorf("expected status %d, got %d", tc.expected, recorder.Code)
+			}
+		})
+	}
+}
+
+func TestSecretConsumers(t *testing.T) {
+	pods := []corev1.Pod{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "a"},
+			Spec: corev1.PodSpec{Volumes: []corev1.Volume{{VolumeSource: corev1.VolumeSource{Projected: &corev1.ProjectedVolumeSource{
+				Sources: []corev1.VolumeProjection{{Secret: &corev1.SecretProjection{LocalObjectReference: corev1.LocalObjectReference{Name: "projected"}}}},
+			}}}}},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "b"},
+			Spec: corev1.PodSpec{
+				ImagePullSecrets: []corev1.LocalObjectReference{{Name: "projected"}, {Name: "pull"}},
+				Containers:       []corev1.Container{{Env: []corev1.EnvVar{{Name: "PLAIN", Value: "value"}}}},
+			},
+		},
+	}
+	testhelper.Diff(t, "consumers", map[string][]string{"projected": {"a", "b"}, "pull": {"b"}}, secretConsumers(pods))
+}
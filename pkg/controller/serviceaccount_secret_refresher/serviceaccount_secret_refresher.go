@@ -18,6 +18,8 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	policyv1 "github.com/openshift/ci-tools/pkg/api/serviceaccountsecretpolicy/v1"
 )
 
 const (
@@ -26,15 +28,43 @@ const (
 	TTLAnnotationKey = "serviaccount-secret-rotator.openshift.io/delete-after"
 )
 
-func AddToManager(clusterName string, mgr manager.Manager, enabledNamespaces, ignoreServiceAccounts sets.Set[string], removeOldSecrets bool) error {
+// Options configure the controller
+type Options struct {
+	// EnabledNamespaces are the namespaces the default policy applies to
+	EnabledNamespaces sets.Set[string]
+	// IgnoreServiceAccounts are service accounts in namespace/name format that are not managed by any policy
+	IgnoreServiceAccounts sets.Set[string]
+	// RemoveOldSecrets enables the deletion of secrets that outlived their grace period
+	RemoveOldSecrets bool
+	// PolicyManager is the manager of the cluster holding the ServiceAccountSecretPolicies.
+	// Only the default policy is used when it is nil.
+	PolicyManager manager.Manager
+	// SlackClient is used to notify the channels of the policies about rotations, if set
+	SlackClient SlackClient
+	// Reporter is used to serve a report of the managed secrets, if set
+	Reporter *Reporter
+}
+
+func AddToManager(clusterName string, mgr manager.Manager, opts Options) error {
 	r := &reconciler{
-		client: mgr.GetClient(),
-		filter: func(r reconcile.Request) bool {
-			return enabledNamespaces.Has(r.Namespace) && !ignoreServiceAccounts.Has(r.String())
+		client:    mgr.GetClient(),
+		podReader: mgr.GetAPIReader(),
+		cluster:   clusterName,
+		policies: &policyResolver{
+			cluster: clusterName,
+			ignored: opts.IgnoreServiceAccounts,
+			filter: func(r reconcile.Request) bool {
+				return opts.EnabledNamespaces.Has(r.Namespace)
+			},
+			defaultPolicy: defaultPolicy(),
 		},
+		slackClient:      opts.SlackClient,
 		log:              logrus.WithField("controller", ControllerName).WithField("cluster", clusterName),
 		second:           time.Second,
-		removeOldSecrets: removeOldSecrets,
+		removeOldSecrets: opts.RemoveOldSecrets,
+	}
+	if opts.PolicyManager != nil {
+		r.policies.policyClient = opts.PolicyManager.GetClient()
 	}
 	c, err := controller.New(fmt.Sprintf("%s_%s", ControllerName, clusterName), mgr, controller.Options{
 		Reconciler: r,
@@ -51,10 +81,40 @@ func AddToManager(clusterName string, mgr manager.Manager, enabledNamespaces, ig
 	if err := c.Watch(source.Kind(mgr.GetCache(), &corev1.Secret{}, handler.TypedEnqueueRequestsFromMapFunc(secretMapper))); err != nil {
 		return fmt.Errorf("failed to construct watch for Secrets: %w", err)
 	}
+	if opts.PolicyManager != nil {
+		if err := c.Watch(source.Kind(opts.PolicyManager.GetCache(), &policyv1.ServiceAccountSecretPolicy{}, handler.TypedEnqueueRequestsFromMapFunc(policyMapper(mgr.GetClient(), r.log, clusterName)))); err != nil {
+			return fmt.Errorf("failed to construct watch for ServiceAccountSecretPolicies: %w", err)
+		}
+	}
+	if opts.Reporter != nil {
+		opts.Reporter.register(clusterName, r)
+	}
 
 	return nil
 }
 
+// policyMapper enqueues the service accounts a policy selects, so that changes to it take effect immediately
+func policyMapper(client ctrlruntimeclient.Reader, log *logrus.Entry, cluster string) handler.TypedMapFunc[*policyv1.ServiceAccountSecretPolicy, reconcile.Request] {
+	return func(ctx context.Context, policy *policyv1.ServiceAccountSecretPolicy) []reconcile.Request {
+		var listOpts []ctrlruntimeclient.ListOption
+		if len(policy.Spec.Namespaces) == 1 {
+			listOpts = append(listOpts, ctrlruntimeclient.InNamespace(policy.Spec.Namespaces[0]))
+		}
+		serviceAccounts := &corev1.ServiceAccountList{}
+		if err := client.List(ctx, serviceAccounts, listOpts...); err != nil {
+			log.WithError(err).WithField("policy", policy.Name).Error("Failed to list ServiceAccounts for policy")
+			return nil
+		}
+		var requests []reconcile.Request
+		for i := range serviceAccounts.Items {
+			if policyMatches(policy, cluster, &serviceAccounts.Items[i]) {
+				requests = append(requests, reconcile.Request{NamespacedName: ctrlruntimeclient.ObjectKeyFromObject(&serviceAccounts.Items[i])})
+			}
+		}
+		return requests
+	}
+}
+
 func secretMapper(ctx context.Context, secret *corev1.Secret) []reconcile.Request {
 	sa, ok := secret.Annotations[corev1.ServiceAccountNameKey]
 	if !ok {
@@ -66,8 +126,12 @@ func secretMapper(ctx context.Context, secret *corev1.Secret) []reconcile.Reques
 
 type reconciler struct {
 	client ctrlruntimeclient.Client
-	filter func(reconcile.Request) bool
-	log    *logrus.Entry
+	// podReader lists the pods consuming the secrets, as pods are not cached
+	podReader   ctrlruntimeclient.Reader
+	cluster     string
+	policies    *policyResolver
+	slackClient SlackClient
+	log         *logrus.Entry
 	// Allow speeding up time for tests
 	second           time.Duration
 	removeOldSecrets bool
@@ -88,10 +152,8 @@ func (r *reconciler) Reconcile(ctx context.Context, req reconcile.Request) (reco
 	return *res, err
 }
 
-const thirtyDays = 30 * 24 * time.Hour
-
 func (r *reconciler) reconcile(ctx context.Context, l *logrus.Entry, req reconcile.Request) (*reconcile.Result, error) {
-	if !r.filter(req) {
+	if r.policies.ignores(req) || (r.policies.policyClient == nil && !r.policies.filter(req)) {
 		return nil, nil
 	}
 
@@ -102,8 +164,17 @@ func (r *reconciler) reconcile(ctx context.Context, l *logrus.Entry, req reconci
 		}
 		return nil, fmt.Errorf("failed to get serviaccount %s: %w", req.String(), err)
 	}
+	policy, err := r.policies.resolve(ctx, l, sa)
+	if err != nil {
+		return nil, err
+	}
+	if policy == nil {
+		return nil, nil
+	}
+	l = l.WithField("policy", policy.Name)
 
 	var imagePullSecretsToKeep []corev1.LocalObjectReference
+	var rotated []string
 	var requeueAfter time.Duration
 	for _, pullSecretRef := range sa.ImagePullSecrets {
 		l := l.WithField("imagepullsecretname", pullSecretRef.Name)
@@ -111,7 +182,7 @@ func (r *reconciler) reconcile(ctx context.Context, l *logrus.Entry, req reconci
 		if err != nil {
 			return nil, fmt.Errorf("failed to check image pull secret creationTimestamp: %w", err)
 		}
-		if deleteObjectIn := objectExpiredIn(l, secret, thirtyDays); deleteObjectIn != 0 {
+		if deleteObjectIn := objectExpiredIn(l, secret, policy.TTL); deleteObjectIn != 0 {
 			l.WithField("secret", secret.Name).Infof("DeleteObjectIn: %s", deleteObjectIn.String())
 			imagePullSecretsToKeep = append(imagePullSecretsToKeep, pullSecretRef)
 			if requeueAfter == 0 || deleteObjectIn < requeueAfter {
@@ -120,6 +191,7 @@ func (r *reconciler) reconcile(ctx context.Context, l *logrus.Entry, req reconci
 			continue
 		}
 		l.Info("Not keeping image pull secret")
+		rotated = append(rotated, pullSecretRef.Name)
 	}
 
 	var tokenSecretsToKeep []corev1.ObjectReference
@@ -134,7 +206,7 @@ func (r *reconciler) reconcile(ctx context.Context, l *logrus.Entry, req reconci
 			tokenSecretsToKeep = append(tokenSecretsToKeep, tokenSecretRef)
 			continue
 		}
-		if deleteObjectIn := objectExpiredIn(l, secret, thirtyDays); deleteObjectIn != 0 {
+		if deleteObjectIn := objectExpiredIn(l, secret, policy.TTL); deleteObjectIn != 0 {
 			tokenSecretsToKeep = append(tokenSecretsToKeep, tokenSecretRef)
 			if requeueAfter == 0 || deleteObjectIn < requeueAfter {
 				requeueAfter = deleteObjectIn
@@ -153,6 +225,10 @@ func (r *reconciler) reconcile(ctx context.Context, l *logrus.Entry, req reconci
 		if err := r.client.Update(ctx, sa); err != nil {
 			return nil, fmt.Errorf("failed to update ServiceAccount: %w", err)
 		}
+		// A failed notification must not fail the reconciliation, the rotation already happened
+		if err := notifyRotation(r.slackClient, r.cluster, policy, sa, rotated, r.removeOldSecrets); err != nil {
+			l.WithError(err).Error("Failed to notify about the rotation")
+		}
 	}
 
 	if err := wait.Poll(r.second, 30*r.second, func() (bool, error) {
@@ -178,14 +254,14 @@ func (r *reconciler) reconcile(ctx context.Context, l *logrus.Entry, req reconci
 		if secret.Annotations[corev1.ServiceAccountUIDKey] != string(sa.UID) {
 			continue
 		}
-		if deleteObjectIn := objectExpiredIn(l, &secret, 2*thirtyDays); deleteObjectIn != 0 {
+		if deleteObjectIn := objectExpiredIn(l, &secret, policy.TTL+policy.GracePeriod); deleteObjectIn != 0 {
 			if requeueAfter == 0 || deleteObjectIn < requeueAfter {
 				requeueAfter = deleteObjectIn
 			}
 			continue
 		}
 
-		l.WithField("name", secret.Name).WithField("age", time.Since(secret.CreationTimestamp.Time).String()).Info("Deleting secret that is older than 30 days")
+		l.WithField("name", secret.Name).WithField("age", time.Since(secret.CreationTimestamp.Time).String()).Info("Deleting secret that outlived its grace period")
 		// ignore ErrNotExist as there could be a race condition where something else has already deleted the secret.
 		if err := r.client.Delete(ctx, &secret); err != nil && !apierrors.IsNotFound(err) {
 			return nil, fmt.Errorf("failed to delete secret %s/%s: %w", secret.Namespace, secret.Name, err)
@@ -208,21 +284,24 @@ func (r *reconciler) creationTimestampSecret(ctx context.Context, namespace, nam
 	return secret, nil
 }
 
-func objectExpiredIn(log *logrus.Entry, o ctrlruntimeclient.Object, ttl time.Duration) (deleteAfter time.Duration) {
+// expiresAt returns when the object expires, which is the earlier of its TTL annotation and its creation plus the ttl
+func expiresAt(log *logrus.Entry, o ctrlruntimeclient.Object, ttl time.Duration) time.Time {
+	expiration := o.GetCreationTimestamp().Time.Add(ttl)
 	if val, exists := o.GetAnnotations()[TTLAnnotationKey]; exists {
 		deleteAddAnnotationTimestamp, err := time.Parse(time.RFC3339, val)
 		if err != nil {
 			// No point in returning this as retrying won't help. If someone changes the value, that
 			// will trigger us
 			log.WithError(err).Errorf("Failed to parse %s annotation value", TTLAnnotationKey)
-		} else {
-			deleteAfter = time.Until(deleteAddAnnotationTimestamp)
+		} else if deleteAddAnnotationTimestamp.Before(expiration) {
+			expiration = deleteAddAnnotationTimestamp
 		}
 	}
+	return expiration
+}
 
-	if expirationDuration := time.Until(o.GetCreationTimestamp().Time.Add(ttl)); deleteAfter == 0 || expirationDuration < deleteAfter {
-		deleteAfter = expirationDuration
-	}
+func objectExpiredIn(log *logrus.Entry, o ctrlruntimeclient.Object, ttl time.Duration) (deleteAfter time.Duration) {
+	deleteAfter = time.Until(expiresAt(log, o, ttl))
 
 	// We can't travel back in time to delete it at the right time so simplify the API by always returning 0 for "Expired"
 	if deleteAfter < 0 {
@@ -13,9 +13,12 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
 	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
 	fakectrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	policyv1 "github.com/openshift/ci-tools/pkg/api/serviceaccountsecretpolicy/v1"
 )
 
 func TestReconcile(t *testing.T) {
@@ -31,6 +34,8 @@ func TestReconcile(t *testing.T) {
 		objects                    []runtime.Object
 		removeOldSecrets           bool
 		filter                     func(reconcile.Request) bool
+		policies                   []runtime.Object
+		ignored                    []string
 		expectedRequeAfterHours    int
 		expectedNumImagePullSecret uint
 		expectedNumTokenSecret     uint
@@ -117,6 +122,23 @@ func TestReconcile(t *testing.T) {
 			expectedPullSecretName:     "pull-secret",
 			expectedTokenSecretName:    "token-secret",
 		},
+		{
+			name: "ignored service account is selected by a policy, nothing happens",
+			objects: []runtime.Object{
+				sa.DeepCopy(),
+				secretForSA(sa, corev1.SecretTypeDockercfg, func(s *corev1.Secret) { s.Name = "pull-secret" }),
+				secretForSA(sa, corev1.SecretTypeServiceAccountToken, func(s *corev1.Secret) { s.Name = "token-secret" }),
+			},
+			policies: []runtime.Object{policy("namespace", time.Hour, func(s *policyv1.ServiceAccountSecretPolicySpec) {
+				s.Namespaces = []string{"namespace"}
+			})},
+			ignored:                    []string{"namespace/sa"},
+			removeOldSecrets:           true,
+			expectedNumImagePullSecret: 1,
+			expectedNumTokenSecret:     1,
+			expectedPullSecretName:     "pull-secret",
+			expectedTokenSecretName:    "token-secret",
+		},
 		{
 			name: "young secrets are rotated and deleted because of ttl annotation",
 			objects: []runtime.Object{
@@ -149,9 +171,13 @@ func TestReconcile(t *testing.T) {
 			}
 			client := &serviceaccountSecretRecreatingClient{t: t, Client: fakectrlruntimeclient.NewClientBuilder().WithRuntimeObjects(tc.objects...).Build()}
 
+			policies := &policyResolver{ignored: sets.New(tc.ignored...), filter: tc.filter, defaultPolicy: defaultPolicy()}
+			if tc.policies != nil {
+				policies.policyClient = fakectrlruntimeclient.NewClientBuilder().WithRuntimeObjects(tc.policies...).Build()
+			}
 			r := &reconciler{
 				client:           client,
-				filter:           tc.filter,
+				policies:         policies,
 				log:              logrus.WithField("test", tc.name),
 				second:           10 * time.Millisecond,
 				removeOldSecrets: tc.removeOldSecrets,
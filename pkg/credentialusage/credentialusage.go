@@ -0,0 +1,152 @@
+// Package credentialusage holds the evidence the entrypoint wrapper records about the
+// credentials a step reads, and aggregates it into reports about unused credentials.
+package credentialusage
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/openshift/ci-tools/pkg/api"
+)
+
+// EvidenceFile is the name of the file in $ARTIFACT_DIR holding the evidence of a step
+const EvidenceFile = "credential-usage.json"
+
+// Evidence records which files under the credential mounts a step opened
+type Evidence struct {
+	// Job and BuildID identify the job run the step ran in
+	Job     string `json:"job,omitempty"`
+	BuildID string `json:"build_id,omitempty"`
+	// Step is the name of the step, which is the name of the reference for registry steps
+	Step   string       `json:"step"`
+	Mounts []MountUsage `json:"mounts"`
+}
+
+// MountUsage lists the files opened under a credential mount
+type MountUsage struct {
+	MountPath string `json:"mount_path"`
+	// Read lists the files that were opened, relative to the mount path
+	Read []string `json:"read,omitempty"`
+}
+
+// Finding is a credential mounted by a registry step that was never read
+type Finding struct {
+	Step       string `json:"step"`
+	Credential string `json:"credential"`
+	MountPath  string `json:"mount_path"`
+	// Runs is the number of runs of the step evidence was recorded for
+	Runs int `json:"runs"`
+}
+
+// Mount describes a registry step mounting a credential
+type Mount struct {
+	Step      string `json:"step"`
+	MountPath string `json:"mount_path"`
+	// Runs and Reads count the runs the step recorded evidence for, and the runs it read the credential in
+	Runs  int `json:"runs"`
+	Reads int `json:"reads"`
+	// Jobs are the jobs the step was seen running in
+	Jobs []string `json:"jobs,omitempty"`
+}
+
+// Report aggregates the evidence recorded for registry steps
+type Report struct {
+	// Findings lists the credentials that were mounted but never read
+	Findings []Finding `json:"findings"`
+	// Credentials maps every credential to the steps that can read it
+	Credentials map[string][]Mount `json:"credentials"`
+}
+
+// DescribeCredential returns a name identifying the secret behind a credential reference
+func DescribeCredential(credential api.CredentialReference) string {
+	switch {
+	case credential.IsBundleReference():
+		return "bundle " + credential.Bundle
+	case credential.Collection != "":
+		parts := []string{credential.Collection, credential.Group}
+		if credential.Field != "" {
+			parts = append(parts, credential.Field)
+		}
+		return "gsm " + strings.Join(parts, "/")
+	default:
+		return fmt.Sprintf("secret %s/%s", credential.Namespace, credential.Name)
+	}
+}
+
+// MountPaths returns the distinct mount paths of the credentials, in order
+func MountPaths(credentials []api.CredentialReference) []string {
+	var paths []string
+	seen := sets.New[string]()
+	for _, credential := range credentials {
+		mountPath := path.Clean(credential.MountPath)
+		if !seen.Has(mountPath) {
+			seen.Insert(mountPath)
+			paths = append(paths, mountPath)
+		}
+	}
+	return paths
+}
+
+// Aggregate builds a report from the evidence recorded for the steps in the registry,
+// given as the references by their name. The registry package is not imported since
+// the step generation imports this package. Evidence for steps that are not in the
+// registry is ignored, and credentials of steps without evidence are listed without
+// findings, as nothing is known about their usage.
+func Aggregate(references map[string]api.LiteralTestStep, evidence []Evidence) Report {
+	type usage struct {
+		runs, reads int
+		jobs        sets.Set[string]
+	}
+	// usages maps steps to mount paths to their usage
+	usages := map[string]map[string]*usage{}
+	for _, e := range evidence {
+		if _, ok := references[e.Step]; !ok {
+			continue
+		}
+		if usages[e.Step] == nil {
+			usages[e.Step] = map[string]*usage{}
+		}
+		for _, mount := range e.Mounts {
+			mountPath := path.Clean(mount.MountPath)
+			u, ok := usages[e.Step][mountPath]
+			if !ok {
+				u = &usage{jobs: sets.New[string]()}
+				usages[e.Step][mountPath] = u
+			}
+			u.runs++
+			if len(mount.Read) > 0 {
+				u.reads++
+			}
+			if e.Job != "" {
+				u.jobs.Insert(e.Job)
+			}
+		}
+	}
+
+	report := Report{Findings: []Finding{}, Credentials: map[string][]Mount{}}
+	for _, name := range sets.List(sets.KeySet(references)) {
+		for _, credential := range references[name].Credentials {
+			mountPath := path.Clean(credential.MountPath)
+			description := DescribeCredential(credential)
+			mount := Mount{Step: name, MountPath: mountPath}
+			if u := usages[name][mountPath]; u != nil {
+				mount.Runs, mount.Reads, mount.Jobs = u.runs, u.reads, sets.List(u.jobs)
+				if u.reads == 0 {
+					report.Findings = append(report.Findings, Finding{Step: name, Credential: description, MountPath: mountPath, Runs: u.runs})
+				}
+			}
+			report.Credentials[description] = append(report.Credentials[description], mount)
+		}
+	}
+	sort.Slice(report.Findings, func(i, j int) bool {
+		if report.Findings[i].Step != report.Findings[j].Step {
+			return report.Findings[i].Step < report.Findings[j].Step
+		}
+		return report.Findings[i].Credential < report.Findings[j].Credential
+	})
+	return report
+}
@@ -0,0 +1,117 @@
+package credentialusage
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/openshift/ci-tools/pkg/api"
+	"github.com/openshift/ci-tools/pkg/registry"
+)
+
+func TestDescribeCredential(t *testing.T) {
+	var testCases = []struct {
+		name       string
+		credential api.CredentialReference
+		expected   string
+	}{
+		{
+			name:       "secret",
+			credential: api.CredentialReference{Namespace: "test-credentials", Name: "aws", MountPath: "/var/run/aws"},
+			expected:   "secret test-credentials/aws",
+		},
+		{
+			name:       "bundle",
+			credential: api.CredentialReference{Bundle: "aws-creds", MountPath: "/var/run/aws"},
+			expected:   "bundle aws-creds",
+		},
+		{
+			name:       "gsm group",
+			credential: api.CredentialReference{Collection: "team", Group: "aws", MountPath: "/var/run/aws"},
+			expected:   "gsm team/aws",
+		},
+		{
+			name:       "gsm field",
+			credential: api.CredentialReference{Collection: "team", Group: "aws", Field: "token", MountPath: "/var/run/aws"},
+			expected:   "gsm team/aws/token",
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if diff := cmp.Diff(testCase.expected, DescribeCredential(testCase.credential)); diff != "" {
+				t.Errorf("unexpected description: %s", diff)
+			}
+		})
+	}
+}
+
+func TestMountPaths(t *testing.T) {
+	credentials := []api.CredentialReference{
+		{Namespace: "ns", Name: "a", MountPath: "/var/run/a/"},
+		{Namespace: "ns", Name: "b", MountPath: "/var/run/b"},
+		{Collection: "c", Group: "a", MountPath: "/var/run/a"},
+	}
+	if diff := cmp.Diff([]string{"/var/run/a", "/var/run/b"}, MountPaths(credentials)); diff != "" {
+		t.Errorf("unexpected mount paths: %s", diff)
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	references := registry.ReferenceByName{
+		"install": {
+			As: "install",
+			Credentials: []api.CredentialReference{
+				{Namespace: "test-credentials", Name: "aws", MountPath: "/var/run/aws"},
+				{Collection: "team", Group: "quay", MountPath: "/var/run/quay/"},
+			},
+		},
+		"e2e": {
+			As: "e2e",
+			Credentials: []api.CredentialReference{
+				{Namespace: "test-credentials", Name: "aws", MountPath: "/var/run/aws"},
+			},
+		},
+		"gather": {
+			As: "gather",
+			Credentials: []api.CredentialReference{
+				{Bundle: "gather", MountPath: "/var/run/gather"},
+			},
+		},
+	}
+	evidence := []Evidence{
+		{Job: "job-a", BuildID: "1", Step: "install", Mounts: []MountUsage{
+			{MountPath: "/var/run/aws", Read: []string{"credentials"}},
+			{MountPath: "/var/run/quay"},
+		}},
+		{Job: "job-b", BuildID: "2", Step: "install", Mounts: []MountUsage{
+			{MountPath: "/var/run/aws"},
+			{MountPath: "/var/run/quay"},
+		}},
+		{Job: "job-a", BuildID: "1", Step: "e2e", Mounts: []MountUsage{
+			{MountPath: "/var/run/aws", Read: []string{"credentials"}},
+		}},
+		{Job: "job-a", BuildID: "1", Step: "inline", Mounts: []MountUsage{
+			{MountPath: "/var/run/inline"},
+		}},
+	}
+	expected := Report{
+		Findings: []Finding{
+			{Step: "install", Credential: "gsm team/quay", MountPath: "/var/run/quay", Runs: 2},
+		},
+		Credentials: map[string][]Mount{
+			"bundle gather": {
+				{Step: "gather", MountPath: "/var/run/gather"},
+			},
+			"gsm team/quay": {
+				{Step: "install", MountPath: "/var/run/quay", Runs: 2, Jobs: []string{"job-a", "job-b"}},
+			},
+			"secret test-credentials/aws": {
+				{Step: "e2e", MountPath: "/var/run/aws", Runs: 1, Reads: 1, Jobs: []string{"job-a"}},
+				{Step: "install", MountPath: "/var/run/aws", Runs: 2, Reads: 1, Jobs: []string{"job-a", "job-b"}},
+			},
+		},
+	}
+	if diff := cmp.Diff(expected, Aggregate(references, evidence)); diff != "" {
+		t.Errorf("unexpected report: %s", diff)
+	}
+}
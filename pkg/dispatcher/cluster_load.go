@@ -0,0 +1,228 @@
+package dispatcher
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	pendingPodsQuery        = `sum(kube_pod_status_phase{phase="Pending"}) by (cluster)`
+	schedulingFailuresQuery = `sum(increase(scheduler_schedule_attempts_total{result="unschedulable"}[1h])) by (cluster)`
+	autoscalerNodesQuery    = `sum(cluster_autoscaler_nodes_count) by (cluster)`
+	autoscalerMaxNodesQuery = `sum(cluster_autoscaler_max_nodes_count) by (cluster)`
+)
+
+// ClusterLoad is the live load of a build farm cluster
+type ClusterLoad struct {
+	// PendingPods is the number of pods currently waiting to be scheduled
+	PendingPods float64 `json:"pendingPods"`
+	// SchedulingFailures is the number of recent unschedulable attempts
+	SchedulingFailures float64 `json:"schedulingFailures"`
+	// Nodes is the current number of nodes as seen by the autoscaler
+	Nodes float64 `json:"nodes"`
+	// MaxNodes is the maximal number of nodes the autoscaler may scale to; zero if unknown
+	MaxNodes float64 `json:"maxNodes"`
+}
+
+// AutoscalerHeadroom returns the ratio of nodes the autoscaler may still add, in [0,1].
+// The cluster is assumed to have full headroom if its limits are unknown.
+func (l ClusterLoad) AutoscalerHeadroom() float64 {
+	if l.MaxNodes <= 0 {
+		return 1
+	}
+	return math.Max(0, math.Min(1, (l.MaxNodes-l.Nodes)/l.MaxNodes))
+}
+
+// ClusterLoadSource provides the live load of the build farm clusters
+type ClusterLoadSource interface {
+	GetClusterLoads(ctx context.Context) (map[string]ClusterLoad, error)
+}
+
+// ScoringOptions configures the capacity-aware scoring of clusters
+type ScoringOptions struct {
+	Enabled                  bool
+	PendingPodsWeight        float64
+	SchedulingFailuresWeight float64
+	AutoscalerWeight         float64
+}
+
+// AddFlags sets up the flags for ScoringOptions
+func (o *ScoringOptions) AddFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&o.Enabled, "capacity-aware-scoring", false, "Take the live headroom of build farm clusters into account when dispatching relocatable jobs.")
+	fs.Float64Var(&o.PendingPodsWeight, "scoring-pending-pods-weight", 1, "Weight of pending pods in the capacity-aware score.")
+	fs.Float64Var(&o.SchedulingFailuresWeight, "scoring-scheduling-failures-weight", 1, "Weight of recent scheduling failures in the capacity-aware score.")
+	fs.Float64Var(&o.AutoscalerWeight, "scoring-autoscaler-weight", 1, "Weight of the exhausted autoscaler headroom in the capacity-aware score.")
+}
+
+// Validate validates the values in the options
+func (o *ScoringOptions) Validate() error {
+	if o.PendingPodsWeight < 0 || o.SchedulingFailuresWeight < 0 || o.AutoscalerWeight < 0 {
+		return fmt.Errorf("capacity-aware scoring weights must not be negative")
+	}
+	return nil
+}
+
+// ClusterScore is the capacity-aware score of a cluster
+type ClusterScore struct {
+	// Score is in (0,1], where 1 means that the cluster has full headroom
+	Score float64     `json:"score"`
+	Load  ClusterLoad `json:"load"`
+}
+
+// Reason describes the score in a human-readable way
+func (s ClusterScore) Reason() string {
+	return fmt.Sprintf("score %.2f: %.0f pending pods, %.0f scheduling failures, %.0f%% autoscaler headroom", s.Score, s.Load.PendingPods, s.Load.SchedulingFailures, 100*s.Load.AutoscalerHeadroom())
+}
+
+// ScoreClusters computes the capacity-aware score of each cluster in the cluster map.
+// Pending pods and scheduling failures are normalized against the most loaded cluster,
+// so the score reflects the relative load within the build farm.
+func (o *ScoringOptions) ScoreClusters(clusterMap ClusterMap, loads map[string]ClusterLoad) map[string]ClusterScore {
+	var maxPending, maxFailures float64
+	for cluster := range clusterMap {
+		maxPending = math.Max(maxPending, loads[cluster].PendingPods)
+		maxFailures = math.Max(maxFailures, loads[cluster].SchedulingFailures)
+	}
+	scores := make(map[string]ClusterScore, len(clusterMap))
+	for cluster := range clusterMap {
+		load := loads[cluster]
+		var penalty float64
+		if maxPending > 0 {
+			penalty += o.PendingPodsWeight * load.PendingPods / maxPending
+		}
+		if maxFailures > 0 {
+			penalty += o.SchedulingFailuresWeight * load.SchedulingFailures / maxFailures
+		}
+		penalty += o.AutoscalerWeight * (1 - load.AutoscalerHeadroom())
+		scores[cluster] = ClusterScore{Score: 1 / (1 + penalty), Load: load}
+	}
+	return scores
+}
+
+// WeightVolumeDistribution scales the volume distribution by the cluster scores while keeping
+// the total volume unchanged, so overloaded clusters receive a smaller share of the relocatable jobs.
+func WeightVolumeDistribution(volumeDistribution map[string]float64, scores map[string]ClusterScore) map[string]float64 {
+	var total, weightedTotal float64
+	weighted := make(map[string]float64, len(volumeDistribution))
+	for cluster, volume := range volumeDistribution {
+		score, ok := scores[cluster]
+		if !ok {
+			score.Score = 1
+		}
+		total += volume
+		weighted[cluster] = volume * score.Score
+		weightedTotal += weighted[cluster]
+	}
+	if weightedTotal == 0 {
+		return volumeDistribution
+	}
+	for cluster := range weighted {
+		weighted[cluster] = weighted[cluster] * total / weightedTotal
+	}
+	return weighted
+}
+
+type prometheusClusterLoadSource struct {
+	api PrometheusAPI
+}
+
+// NewPrometheusClusterLoadSource returns a ClusterLoadSource backed by Prometheus
+func NewPrometheusClusterLoadSource(api PrometheusAPI) ClusterLoadSource {
+	return &prometheusClusterLoadSource{api: api}
+}
+
+// GetClusterLoads queries Prometheus for the load of all clusters
+func (s *prometheusClusterLoadSource) GetClusterLoads(ctx context.Context) (map[string]ClusterLoad, error) {
+	loads := map[string]ClusterLoad{}
+	for _, q := range []struct {
+		query string
+		set   func(*ClusterLoad, float64)
+	}{
+		{query: pendingPodsQuery, set: func(l *ClusterLoad, v float64) { l.PendingPods = v }},
+		{query: schedulingFailuresQuery, set: func(l *ClusterLoad, v float64) { l.SchedulingFailures = v }},
+		{query: autoscalerNodesQuery, set: func(l *ClusterLoad, v float64) { l.Nodes = v }},
+		{query: autoscalerMaxNodesQuery, set: func(l *ClusterLoad, v float64) { l.MaxNodes = v }},
+	} {
+		values, err := queryByCluster(ctx, s.api, q.query)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query %q: %w", q.query, err)
+		}
+		for cluster, value := range values {
+			load := loads[cluster]
+			q.set(&load, value)
+			loads[cluster] = load
+		}
+	}
+	return loads, nil
+}
+
+func queryByCluster(ctx context.Context, api PrometheusAPI, query string) (map[string]float64, error) {
+	result, warnings, err := api.Query(ctx, query, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if len(warnings) > 0 {
+		logrus.WithField("Warnings", warnings).Warn("Got warnings from Prometheus")
+	}
+	vector, ok := result.(model.Vector)
+	if !ok {
+		return nil, fmt.Errorf("returned result of type %T from Prometheus cannot be cast to vector", result)
+	}
+	values := map[string]float64{}
+	for _, v := range vector {
+		values[string(v.Metric[model.LabelName("cluster")])] = float64(v.Value)
+	}
+	return values, nil
+}
+
+// JobMove records a job being assigned to a different cluster than before
+type JobMove struct {
+	Job    string `json:"job"`
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Reason string `json:"reason"`
+}
+
+// DispatchReport records the cluster scores used for a dispatch and why each job moved
+type DispatchReport struct {
+	Scores map[string]ClusterScore `json:"scores,omitempty"`
+	Moves  []JobMove               `json:"moves,omitempty"`
+}
+
+// RecordMove records a job move
+func (r *DispatchReport) RecordMove(job, from, to, reason string) {
+	r.Moves = append(r.Moves, JobMove{Job: job, From: from, To: to, Reason: reason})
+}
+
+// SortMoves sorts the moves by job name to keep the report stable
+func (r *DispatchReport) SortMoves() {
+	sort.Slice(r.Moves, func(i, j int) bool { return r.Moves[i].Job < r.Moves[j].Job })
+}
+
+// ScoreReason describes why a relocatable job was placed on the given cluster
+func (r *DispatchReport) ScoreReason(cluster string) string {
+	score, ok := r.Scores[cluster]
+	if !ok {
+		return "balanced by job volume"
+	}
+	var others []string
+	for c, s := range r.Scores {
+		if c != cluster {
+			others = append(others, fmt.Sprintf("%s %.2f", c, s.Score))
+		}
+	}
+	sort.Strings(others)
+	reason := fmt.Sprintf("balanced by capacity-aware score (%s %s)", cluster, score.Reason())
+	if len(others) > 0 {
+		reason = fmt.Sprintf("%s; other scores: %s", reason, strings.Join(others, ", "))
+	}
+	return reason
+}
@@ -0,0 +1,160 @@
+package dispatcher
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	prometheusapi "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+
+	"github.com/openshift/ci-tools/pkg/testhelper"
+)
+
+type clusterLoadAPIForTest struct {
+	results map[string]model.Value
+}
+
+func (api *clusterLoadAPIForTest) Query(ctx context.Context, query string, ts time.Time, opts ...prometheusapi.Option) (model.Value, prometheusapi.Warnings, error) {
+	result, ok := api.results[query]
+	if !ok {
+		return nil, nil, fmt.Errorf("not supported query: %s", query)
+	}
+	return result, nil, nil
+}
+
+func vectorByCluster(values map[string]float64) model.Vector {
+	var vector model.Vector
+	for cluster, value := range values {
+		vector = append(vector, &model.Sample{
+			Metric: model.Metric{model.LabelName("cluster"): model.LabelValue(cluster)},
+			Value:  model.SampleValue(value),
+		})
+	}
+	return vector
+}
+
+func TestGetClusterLoads(t *testing.T) {
+	testCases := []struct {
+		name          string
+		results       map[string]model.Value
+		expected      map[string]ClusterLoad
+		expectedError error
+	}{
+		{
+			name: "basic case",
+			results: map[string]model.Value{
+				pendingPodsQuery:        vectorByCluster(map[string]float64{"build01": 10, "build02": 3}),
+				schedulingFailuresQuery: vectorByCluster(map[string]float64{"build01": 4}),
+				autoscalerNodesQuery:    vectorByCluster(map[string]float64{"build01": 90, "build02": 20}),
+				autoscalerMaxNodesQuery: vectorByCluster(map[string]float64{"build01": 100, "build02": 80}),
+			},
+			expected: map[string]ClusterLoad{
+				"build01": {PendingPods: 10, SchedulingFailures: 4, Nodes: 90, MaxNodes: 100},
+				"build02": {PendingPods: 3, Nodes: 20, MaxNodes: 80},
+			},
+		},
+		{
+			name: "wrong type",
+			results: map[string]model.Value{
+				pendingPodsQuery:        &model.Scalar{Value: 1},
+				schedulingFailuresQuery: &model.Scalar{Value: 1},
+				autoscalerNodesQuery:    &model.Scalar{Value: 1},
+				autoscalerMaxNodesQuery: &model.Scalar{Value: 1},
+			},
+			expectedError: fmt.Errorf("failed to query %q: returned result of type *model.Scalar from Prometheus cannot be cast to vector", pendingPodsQuery),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			source := NewPrometheusClusterLoadSource(&clusterLoadAPIForTest{results: tc.results})
+			actual, err := source.GetClusterLoads(context.Background())
+			if diff := cmp.Diff(tc.expectedError, err, testhelper.EquateErrorMessage); diff != "" {
+				t.Errorf("error differs from expected: %s", diff)
+			}
+			if diff := cmp.Diff(tc.expected, actual); diff != "" {
+				t.Errorf("actual differs from expected: %s", diff)
+			}
+		})
+	}
+}
+
+func TestScoreClusters(t *testing.T) {
+	clusterMap := ClusterMap{
+		"build01": {Provider: "aws", Capacity: 100},
+		"build02": {Provider: "gcp", Capacity: 100},
+		"build03": {Provider: "aws", Capacity: 100},
+	}
+	testCases := []struct {
+		name     string
+		options  ScoringOptions
+		loads    map[string]ClusterLoad
+		expected map[string]float64
+	}{
+		{
+			name:     "no load means full score",
+			options:  ScoringOptions{PendingPodsWeight: 1, SchedulingFailuresWeight: 1, AutoscalerWeight: 1},
+			expected: map[string]float64{"build01": 1, "build02": 1, "build03": 1},
+		},
+		{
+			name:    "load is relative to the most loaded cluster",
+			options: ScoringOptions{PendingPodsWeight: 1, SchedulingFailuresWeight: 1, AutoscalerWeight: 1},
+			loads: map[string]ClusterLoad{
+				"build01": {PendingPods: 100, SchedulingFailures: 10, Nodes: 50, MaxNodes: 100},
+				"build02": {PendingPods: 50},
+			},
+			expected: map[string]float64{"build01": 1 / 3.5, "build02": 1 / 1.5, "build03": 1},
+		},
+		{
+			name:    "zero weights ignore the load",
+			options: ScoringOptions{},
+			loads: map[string]ClusterLoad{
+				"build01": {PendingPods: 100, SchedulingFailures: 10, Nodes: 100, MaxNodes: 100},
+			},
+			expected: map[string]float64{"build01": 1, "build02": 1, "build03": 1},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			actual := map[string]float64{}
+			for cluster, score := range tc.options.ScoreClusters(clusterMap, tc.loads) {
+				actual[cluster] = score.Score
+			}
+			if diff := cmp.Diff(tc.expected, actual, cmpopts.EquateApprox(0, 1e-9)); diff != "" {
+				t.Errorf("actual differs from expected: %s", diff)
+			}
+		})
+	}
+}
+
+func TestWeightVolumeDistribution(t *testing.T) {
+	testCases := []struct {
+		name         string
+		distribution map[string]float64
+		scores       map[string]ClusterScore
+		expected     map[string]float64
+	}{
+		{
+			name:         "no scores",
+			distribution: map[string]float64{"build01": 50, "build02": 50},
+			expected:     map[string]float64{"build01": 50, "build02": 50},
+		},
+		{
+			name:         "overloaded cluster receives less volume",
+			distribution: map[string]float64{"build01": 50, "build02": 50},
+			scores:       map[string]ClusterScore{"build01": {Score: 0.25}, "build02": {Score: 1}},
+			expected:     map[string]float64{"build01": 20, "build02": 80},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			actual := WeightVolumeDistribution(tc.distribution, tc.scores)
+			if diff := cmp.Diff(tc.expected, actual, cmpopts.EquateApprox(0, 1e-9)); diff != "" {
+				t.Errorf("actual differs from expected: %s", diff)
+			}
+		})
+	}
+}
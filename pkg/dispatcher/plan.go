@@ -0,0 +1,190 @@
+package dispatcher
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/yaml"
+)
+
+// maxMarkdownMoves limits the number of rows rendered per table, to stay within the size limit of a PR body
+const maxMarkdownMoves = 200
+
+// Pins lists jobs which the dispatcher must never move to a different cluster
+type Pins struct {
+	// Jobs are names of pinned jobs
+	Jobs []string `json:"jobs,omitempty"`
+	// Patterns are regexes matched against job names
+	Patterns []string `json:"patterns,omitempty"`
+
+	jobs       sets.Set[string]
+	patternREs []*regexp.Regexp
+}
+
+// LoadPins loads the pinning file
+func LoadPins(path string) (*Pins, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the pins file %q: %w", path, err)
+	}
+	pins := &Pins{}
+	if err := yaml.Unmarshal(data, pins); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal the pins file %q: %w", path, err)
+	}
+	var errs []error
+	pins.jobs = sets.New[string](pins.Jobs...)
+	for i, p := range pins.Patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to compile regex patterns[%d] from %q: %w", i, p, err))
+			continue
+		}
+		pins.patternREs = append(pins.patternREs, re)
+	}
+	if len(errs) > 0 {
+		return nil, utilerrors.NewAggregate(errs)
+	}
+	return pins, nil
+}
+
+// IsPinned returns true if the job must not be moved
+func (p *Pins) IsPinned(job string) bool {
+	if p == nil {
+		return false
+	}
+	if p.jobs.Has(job) {
+		return true
+	}
+	for _, re := range p.patternREs {
+		if re.MatchString(job) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClusterVolumeChange is the predicted volume of a cluster before and after a dispatch
+type ClusterVolumeChange struct {
+	Before float64 `json:"before"`
+	After  float64 `json:"after"`
+}
+
+// PlannedMove is a job move together with the volume it carries
+type PlannedMove struct {
+	JobMove
+	Volume float64 `json:"volume"`
+}
+
+// DispatchPlan describes the outcome of a dispatch in a reviewable form
+type DispatchPlan struct {
+	// Moves are the jobs moved to a different cluster
+	Moves []PlannedMove `json:"moves,omitempty"`
+	// Pinned are the moves prevented by the pinning file
+	Pinned []PlannedMove `json:"pinned,omitempty"`
+	// Deferred are the moves postponed because of the limit of moves
+	Deferred []PlannedMove `json:"deferred,omitempty"`
+	// Volumes maps a cluster to its volume before and after the dispatch
+	Volumes map[string]ClusterVolumeChange `json:"volumes,omitempty"`
+	// Scores are the capacity-aware scores of the clusters, if enabled
+	Scores map[string]ClusterScore `json:"scores,omitempty"`
+}
+
+// PlanDispatch turns the moves in the report into a plan. Moves of pinned jobs and, if maxMoves is positive,
+// moves exceeding the limit are reverted in next, so that next reflects the plan. Moves away from a cluster
+// that is blocked or no longer among the clusters are never reverted. When the limit applies, the moves
+// carrying the highest volume are kept.
+func PlanDispatch(report *DispatchReport, previous, next map[string]ProwJobData, jobVolumes map[string]float64, clusters, blocked sets.Set[string], pins *Pins, maxMoves int) *DispatchPlan {
+	plan := &DispatchPlan{Scores: report.Scores}
+	var mandatory, optional []PlannedMove
+	for _, move := range report.Moves {
+		planned := PlannedMove{JobMove: move, Volume: jobVolumes[move.Job]}
+		switch {
+		case blocked.Has(move.From) || !clusters.Has(move.From):
+			mandatory = append(mandatory, planned)
+		case pins.IsPinned(move.Job):
+			plan.Pinned = append(plan.Pinned, planned)
+		default:
+			optional = append(optional, planned)
+		}
+	}
+	if maxMoves > 0 {
+		sort.SliceStable(optional, func(i, j int) bool {
+			if optional[i].Volume != optional[j].Volume {
+				return optional[i].Volume > optional[j].Volume
+			}
+			return optional[i].Job < optional[j].Job
+		})
+		limit := maxMoves - len(mandatory)
+		if limit < 0 {
+			limit = 0
+		}
+		if len(optional) > limit {
+			plan.Deferred = optional[limit:]
+			optional = optional[:limit]
+		}
+	}
+	for _, reverted := range [][]PlannedMove{plan.Pinned, plan.Deferred} {
+		for _, move := range reverted {
+			data := next[move.Job]
+			data.Cluster = move.From
+			next[move.Job] = data
+		}
+	}
+	plan.Moves = append(mandatory, optional...)
+	for _, moves := range [][]PlannedMove{plan.Moves, plan.Pinned, plan.Deferred} {
+		sort.Slice(moves, func(i, j int) bool { return moves[i].Job < moves[j].Job })
+	}
+
+	before, after := volumesByCluster(previous, jobVolumes), volumesByCluster(next, jobVolumes)
+	plan.Volumes = map[string]ClusterVolumeChange{}
+	for _, cluster := range sets.List(sets.KeySet(before).Union(sets.KeySet(after))) {
+		plan.Volumes[cluster] = ClusterVolumeChange{Before: before[cluster], After: after[cluster]}
+	}
+	return plan
+}
+
+func volumesByCluster(pjs map[string]ProwJobData, jobVolumes map[string]float64) map[string]float64 {
+	volumes := map[string]float64{}
+	for job, data := range pjs {
+		if data.Cluster == "" {
+			continue
+		}
+		volumes[data.Cluster] += jobVolumes[job]
+	}
+	return volumes
+}
+
+// Markdown renders the plan for a PR body
+func (p *DispatchPlan) Markdown() string {
+	var b strings.Builder
+	b.WriteString("## Dispatch plan\n\n")
+	b.WriteString("| Cluster | Volume before | Volume after | Change |\n|---|---:|---:|---:|\n")
+	for _, cluster := range sets.List(sets.KeySet(p.Volumes)) {
+		v := p.Volumes[cluster]
+		b.WriteString(fmt.Sprintf("| %s | %.0f | %.0f | %+.0f |\n", cluster, v.Before, v.After, v.After-v.Before))
+	}
+	writeMoves(&b, fmt.Sprintf("Moved jobs (%d)", len(p.Moves)), p.Moves)
+	writeMoves(&b, fmt.Sprintf("Moves deferred by the limit of moves (%d)", len(p.Deferred)), p.Deferred)
+	writeMoves(&b, fmt.Sprintf("Moves prevented by pins (%d)", len(p.Pinned)), p.Pinned)
+	return b.String()
+}
+
+func writeMoves(b *strings.Builder, title string, moves []PlannedMove) {
+	if len(moves) == 0 {
+		return
+	}
+	b.WriteString(fmt.Sprintf("\n### %s\n\n", title))
+	b.WriteString("| Job | From | To | Volume | Reason |\n|---|---|---|---:|---|\n")
+	for i, move := range moves {
+		if i == maxMarkdownMoves {
+			b.WriteString(fmt.Sprintf("\n... and %d more, see the JSON plan for the full list.\n", len(moves)-maxMarkdownMoves))
+			break
+		}
+		b.WriteString(fmt.Sprintf("| `%s` | %s | %s | %.0f | %s |\n", move.Job, move.From, move.To, move.Volume, move.Reason))
+	}
+}
@@ -0,0 +1,188 @@
+package dispatcher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/openshift/ci-tools/pkg/testhelper"
+)
+
+func TestPins(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "pins.yaml")
+	if err := os.WriteFile(path, []byte("jobs:\n- job-a\npatterns:\n- ^pull-ci-openshift-installer-.*\n"), 0644); err != nil {
+		t.Fatalf("failed to write pins: %v", err)
+	}
+	pins, err := LoadPins(path)
+	if err != nil {
+		t.Fatalf("failed to load pins: %v", err)
+	}
+	for job, expected := range map[string]bool{
+		"job-a": true,
+		"job-b": false,
+		"pull-ci-openshift-installer-master-unit": true,
+		"pull-ci-openshift-origin-master-unit":    false,
+	} {
+		if actual := pins.IsPinned(job); actual != expected {
+			t.Errorf("%s: expected pinned=%t, got %t", job, expected, actual)
+		}
+	}
+
+	var nilPins *Pins
+	if nilPins.IsPinned("job-a") {
+		t.Errorf("expected no job to be pinned without a pins file")
+	}
+
+	if err := os.WriteFile(path, []byte("patterns:\n- '['\n"), 0644); err != nil {
+		t.Fatalf("failed to write pins: %v", err)
+	}
+	if _, err := LoadPins(path); err == nil {
+		t.Errorf("expected an error for an invalid regex")
+	}
+}
+
+func TestPlanDispatch(t *testing.T) {
+	previous := map[string]ProwJobData{
+		"job-a": {Cluster: "build01"},
+		"job-b": {Cluster: "build01"},
+		"job-c": {Cluster: "build01"},
+		"job-d": {Cluster: "build03"},
+		"job-e": {Cluster: "build02"},
+	}
+	jobVolumes := map[string]float64{"job-a": 10, "job-b": 20, "job-c": 30, "job-d": 5, "job-e": 1}
+	moves := []JobMove{
+		{Job: "job-a", From: "build01", To: "build02", Reason: "balanced by job volume"},
+		{Job: "job-b", From: "build01", To: "build02", Reason: "balanced by job volume"},
+		{Job: "job-c", From: "build01", To: "build02", Reason: "balanced by job volume"},
+		{Job: "job-d", From: "build03", To: "build02", Reason: "the determined cluster is blocked, falling back to the default cluster"},
+	}
+	next := func() map[string]ProwJobData {
+		return map[string]ProwJobData{
+			"job-a": {Cluster: "build02"},
+			"job-b": {Cluster: "build02"},
+			"job-c": {Cluster: "build02"},
+			"job-d": {Cluster: "build02"},
+			"job-e": {Cluster: "build02"},
+		}
+	}
+	planned := func(move JobMove) PlannedMove {
+		return PlannedMove{JobMove: move, Volume: jobVolumes[move.Job]}
+	}
+
+	testCases := []struct {
+		name         string
+		clusters     sets.Set[string]
+		pins         *Pins
+		maxMoves     int
+		expected     *DispatchPlan
+		expectedNext map[string]ProwJobData
+	}{
+		{
+			name: "no limits",
+			expected: &DispatchPlan{
+				Moves: []PlannedMove{planned(moves[0]), planned(moves[1]), planned(moves[2]), planned(moves[3])},
+				Volumes: map[string]ClusterVolumeChange{
+					"build01": {Before: 60},
+					"build02": {Before: 1, After: 66},
+					"build03": {Before: 5},
+				},
+			},
+			expectedNext: next(),
+		},
+		{
+			name:     "max moves keeps the moves with the highest volume and the moves away from blocked clusters",
+			maxMoves: 2,
+			expected: &DispatchPlan{
+				Moves:    []PlannedMove{planned(moves[2]), planned(moves[3])},
+				Deferred: []PlannedMove{planned(moves[0]), planned(moves[1])},
+				Volumes: map[string]ClusterVolumeChange{
+					"build01": {Before: 60, After: 30},
+					"build02": {Before: 1, After: 36},
+					"build03": {Before: 5},
+				},
+			},
+			expectedNext: map[string]ProwJobData{
+				"job-a": {Cluster: "build01"},
+				"job-b": {Cluster: "build01"},
+				"job-c": {Cluster: "build02"},
+				"job-d": {Cluster: "build02"},
+				"job-e": {Cluster: "build02"},
+			},
+		},
+		{
+			name: "pinned jobs stay, unless their cluster is blocked",
+			pins: &Pins{jobs: sets.New[string]("job-a", "job-d")},
+			expected: &DispatchPlan{
+				Moves:  []PlannedMove{planned(moves[1]), planned(moves[2]), planned(moves[3])},
+				Pinned: []PlannedMove{planned(moves[0])},
+				Volumes: map[string]ClusterVolumeChange{
+					"build01": {Before: 60, After: 10},
+					"build02": {Before: 1, After: 56},
+					"build03": {Before: 5},
+				},
+			},
+			expectedNext: map[string]ProwJobData{
+				"job-a": {Cluster: "build01"},
+				"job-b": {Cluster: "build02"},
+				"job-c": {Cluster: "build02"},
+				"job-d": {Cluster: "build02"},
+				"job-e": {Cluster: "build02"},
+			},
+		},
+		{
+			name:     "moves away from clusters that no longer exist are neither pinned nor deferred",
+			clusters: sets.New[string]("build02", "build03"),
+			pins:     &Pins{jobs: sets.New[string]("job-a")},
+			maxMoves: 1,
+			expected: &DispatchPlan{
+				Moves: []PlannedMove{planned(moves[0]), planned(moves[1]), planned(moves[2]), planned(moves[3])},
+				Volumes: map[string]ClusterVolumeChange{
+					"build01": {Before: 60},
+					"build02": {Before: 1, After: 66},
+					"build03": {Before: 5},
+				},
+			},
+			expectedNext: next(),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.clusters == nil {
+				tc.clusters = sets.New[string]("build01", "build02", "build03")
+			}
+			actualNext := next()
+			report := &DispatchReport{Moves: append([]JobMove{}, moves...)}
+			actual := PlanDispatch(report, previous, actualNext, jobVolumes, tc.clusters, sets.New[string]("build03"), tc.pins, tc.maxMoves)
+			if diff := cmp.Diff(tc.expected, actual); diff != "" {
+				t.Errorf("plan differs from expected: %s", diff)
+			}
+			if diff := cmp.Diff(tc.expectedNext, actualNext); diff != "" {
+				t.Errorf("assignments differ from expected: %s", diff)
+			}
+		})
+	}
+}
+
+func TestDispatchPlanMarkdown(t *testing.T) {
+	plan := &DispatchPlan{
+		Moves: []PlannedMove{
+			{JobMove: JobMove{Job: "job-c", From: "build01", To: "build02", Reason: "balanced by job volume"}, Volume: 30},
+		},
+		Deferred: []PlannedMove{
+			{JobMove: JobMove{Job: "job-a", From: "build01", To: "build02", Reason: "balanced by job volume"}, Volume: 10},
+		},
+		Pinned: []PlannedMove{
+			{JobMove: JobMove{Job: "job-b", From: "build01", To: "build02", Reason: "balanced by job volume"}, Volume: 20},
+		},
+		Volumes: map[string]ClusterVolumeChange{
+			"build01": {Before: 60, After: 30},
+			"build02": {Before: 1, After: 31},
+		},
+	}
+	testhelper.CompareWithFixture(t, plan.Markdown(), testhelper.WithExtension(".md"))
+}
@@ -10,6 +10,7 @@ import (
 	"cloud.google.com/go/iam/admin/apiv1/adminpb"
 	"cloud.google.com/go/iam/apiv1/iampb"
 	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
+	"github.com/googleapis/gax-go/v2"
 	"github.com/sirupsen/logrus"
 	"google.golang.org/api/iterator"
 )
@@ -108,12 +109,46 @@ func GetAllSecrets(ctx context.Context, client SecretManagerClient, config Confi
 // GetSecretPayload retrieves the latest version of a secret's payload data from Google Secret Manager.
 // It takes the secret resource name (e.g., "projects/my-project/secrets/my-secret") and returns the raw payload bytes.
 func GetSecretPayload(ctx context.Context, client SecretManagerClient, secretResourceName string) ([]byte, error) {
+	payload, _, err := GetSecretPayloadAndVersion(ctx, client, secretResourceName)
+	return payload, err
+}
+
+// GetSecretPayloadAndVersion retrieves the latest version of a secret's payload data like GetSecretPayload,
+// and also returns the version it was resolved to (e.g., "3"), or an empty string if the response does not name it.
+func GetSecretPayloadAndVersion(ctx context.Context, client SecretManagerClient, secretResourceName string) ([]byte, string, error) {
 	accessReq := &secretmanagerpb.AccessSecretVersionRequest{
 		Name: secretResourceName + "/versions/latest",
 	}
 	accessResp, err := client.AccessSecretVersion(ctx, accessReq)
 	if err != nil {
-		return nil, fmt.Errorf("failed to access secret version: %w", err)
+		return nil, "", fmt.Errorf("failed to access secret version: %w", err)
+	}
+	return accessResp.Payload.Data, SecretVersionFromName(accessResp.Name), nil
+}
+
+// SecretVersionGetter reads the metadata of secret versions without accessing their payloads
+type SecretVersionGetter interface {
+	GetSecretVersion(ctx context.Context, req *secretmanagerpb.GetSecretVersionRequest, opts ...gax.CallOption) (*secretmanagerpb.SecretVersion, error)
+}
+
+// GetLatestSecretVersion returns the version the latest version of a secret resolves to (e.g., "3"),
+// reading only its metadata, or an empty string if the response does not name it.
+func GetLatestSecretVersion(ctx context.Context, client SecretVersionGetter, secretResourceName string) (string, error) {
+	version, err := client.GetSecretVersion(ctx, &secretmanagerpb.GetSecretVersionRequest{
+		Name: secretResourceName + "/versions/latest",
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to get secret version: %w", err)
+	}
+	return SecretVersionFromName(version.Name), nil
+}
+
+// SecretVersionFromName extracts the version from a secret version resource name
+// (e.g., "projects/my-project/secrets/my-secret/versions/3"), returning an empty string if there is none.
+func SecretVersionFromName(versionResourceName string) string {
+	_, version, found := strings.Cut(versionResourceName, "/versions/")
+	if !found {
+		return ""
 	}
-	return accessResp.Payload.Data, nil
+	return version
 }
@@ -0,0 +1,62 @@
+package gsmsecrets
+
+import (
+	"context"
+	"testing"
+
+	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
+	"github.com/googleapis/gax-go/v2"
+)
+
+func TestSecretVersionFromName(t *testing.T) {
+	testCases := []struct {
+		name     string
+		resource string
+		expected string
+	}{
+		{
+			name:     "resolved version",
+			resource: "projects/384486694155/secrets/my-creds__default__username/versions/3",
+			expected: "3",
+		},
+		{
+			name:     "secret without a version",
+			resource: "projects/384486694155/secrets/my-creds__default__username",
+		},
+		{
+			name: "empty name",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			actual := SecretVersionFromName(tc.resource)
+			if actual != tc.expected {
+				t.Errorf("Expected %q, got %q", tc.expected, actual)
+			}
+		})
+	}
+}
+
+type fakeSecretVersionGetter struct {
+	requested []string
+}
+
+func (f *fakeSecretVersionGetter) GetSecretVersion(_ context.Context, req *secretmanagerpb.GetSecretVersionRequest, _ ...gax.CallOption) (*secretmanagerpb.SecretVersion, error) {
+	f.requested = append(f.requested, req.Name)
+	return &secretmanagerpb.SecretVersion{Name: "projects/384486694155/secrets/my-creds__default__username/versions/7"}, nil
+}
+
+func TestGetLatestSecretVersion(t *testing.T) {
+	client := &fakeSecretVersionGetter{}
+	version, err := GetLatestSecretVersion(context.Background(), client, "projects/384486694155/secrets/my-creds__default__username")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if version != "7" {
+		t.Errorf("Expected version 7, got %q", version)
+	}
+	if len(client.requested) != 1 || client.requested[0] != "projects/384486694155/secrets/my-creds__default__username/versions/latest" {
+		t.Errorf("Expected the metadata of the latest version to be requested, got %v", client.requested)
+	}
+}
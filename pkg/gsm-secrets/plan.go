@@ -0,0 +1,399 @@
+package gsmsecrets
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"cloud.google.com/go/iam/apiv1/iampb"
+	"google.golang.org/genproto/googleapis/type/expr"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Plan is a reviewable, serializable form of the Actions computed for a project. It does not
+// hold any secret payloads: service account keys are generated and index secrets are
+// constructed when the plan is applied, like when the Actions are executed directly.
+type Plan struct {
+	Config    Config         `json:"config"`
+	CreatedAt time.Time      `json:"created_at"`
+	LiveState PlanLiveState  `json:"live_state"`
+	Changes   PlannedChanges `json:"changes"`
+}
+
+// PlanLiveState fingerprints the live state a plan was computed against, so that
+// applying the plan can be refused when the state drifted since.
+type PlanLiveState struct {
+	// ServiceAccounts and Secrets are digests of the sorted names of the live resources
+	ServiceAccounts string `json:"service_accounts"`
+	Secrets         string `json:"secrets"`
+	// IAMPolicyEtag is the etag of the project IAM policy, which changes with every update
+	IAMPolicyEtag string `json:"iam_policy_etag"`
+}
+
+// PlannedChanges lists the changes of a plan, in a stable order
+type PlannedChanges struct {
+	ServiceAccountsToCreate []PlannedServiceAccount `json:"service_accounts_to_create,omitempty"`
+	ServiceAccountsToDelete []PlannedServiceAccount `json:"service_accounts_to_delete,omitempty"`
+	SecretsToCreate         []PlannedSecret         `json:"secrets_to_create,omitempty"`
+	SecretsToDelete         []PlannedSecret         `json:"secrets_to_delete,omitempty"`
+	IAMBindingsToAdd        []PlannedIAMBinding     `json:"iam_bindings_to_add,omitempty"`
+	IAMBindingsToRemove     []PlannedIAMBinding     `json:"iam_bindings_to_remove,omitempty"`
+}
+
+type PlannedServiceAccount struct {
+	Email       string `json:"email"`
+	ID          string `json:"id"`
+	DisplayName string `json:"display_name"`
+	Description string `json:"description"`
+	Collection  string `json:"collection"`
+}
+
+type PlannedSecret struct {
+	Name         string            `json:"name"`
+	ResourceName string            `json:"resource_name,omitempty"`
+	Collection   string            `json:"collection"`
+	Type         string            `json:"type"`
+	Labels       map[string]string `json:"labels,omitempty"`
+	Annotations  map[string]string `json:"annotations,omitempty"`
+}
+
+type PlannedIAMBinding struct {
+	Role      string            `json:"role"`
+	Members   []string          `json:"members"`
+	Condition *PlannedCondition `json:"condition,omitempty"`
+}
+
+type PlannedCondition struct {
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	Expression  string `json:"expression"`
+}
+
+var secretTypeNames = map[SecretType]string{
+	SecretTypeUnknown: "unknown",
+	SecretTypeSA:      "service-account",
+	SecretTypeIndex:   "index",
+	SecretTypeGeneric: "generic",
+}
+
+func secretTypeFromName(name string) SecretType {
+	for secretType, typeName := range secretTypeNames {
+		if typeName == name {
+			return secretType
+		}
+	}
+	return SecretTypeUnknown
+}
+
+// NewPlan builds a plan from the actions computed against the given live state
+func NewPlan(actions Actions, actualSAs []ServiceAccountInfo, actualSecrets map[string]GCPSecret, actualPolicy *iampb.Policy, now time.Time) Plan {
+	plan := Plan{
+		Config:    actions.Config,
+		CreatedAt: now.UTC(),
+		LiveState: liveStateOf(actualSAs, actualSecrets, actualPolicy),
+	}
+	plan.Changes.ServiceAccountsToCreate = plannedServiceAccounts(actions.SAsToCreate)
+	plan.Changes.ServiceAccountsToDelete = plannedServiceAccounts(actions.SAsToDelete)
+	for _, secret := range actions.SecretsToCreate {
+		plan.Changes.SecretsToCreate = append(plan.Changes.SecretsToCreate, plannedSecret(secret))
+	}
+	sort.Slice(plan.Changes.SecretsToCreate, func(i, j int) bool {
+		return plan.Changes.SecretsToCreate[i].Name < plan.Changes.SecretsToCreate[j].Name
+	})
+	for _, secret := range actions.SecretsToDelete {
+		plan.Changes.SecretsToDelete = append(plan.Changes.SecretsToDelete, plannedSecret(secret))
+	}
+	if actions.ConsolidatedIAMPolicy != nil {
+		plan.Changes.IAMBindingsToAdd = bindingsMissingFrom(actions.ConsolidatedIAMPolicy.Bindings, actualPolicy.GetBindings())
+		plan.Changes.IAMBindingsToRemove = bindingsMissingFrom(actualPolicy.GetBindings(), actions.ConsolidatedIAMPolicy.Bindings)
+	}
+	return plan
+}
+
+func liveStateOf(actualSAs []ServiceAccountInfo, actualSecrets map[string]GCPSecret, actualPolicy *iampb.Policy) PlanLiveState {
+	var emails []string
+	for _, sa := range actualSAs {
+		emails = append(emails, sa.Email)
+	}
+	var names []string
+	for name := range actualSecrets {
+		names = append(names, name)
+	}
+	return PlanLiveState{
+		ServiceAccounts: digest(emails),
+		Secrets:         digest(names),
+		IAMPolicyEtag:   base64.StdEncoding.EncodeToString(actualPolicy.GetEtag()),
+	}
+}
+
+func digest(values []string) string {
+	sorted := make([]string, len(values))
+	copy(sorted, values)
+	sort.Strings(sorted)
+	hash := sha256.Sum256([]byte(strings.Join(sorted, "\n")))
+	return hex.EncodeToString(hash[:])
+}
+
+func plannedServiceAccounts(sas SAMap) []PlannedServiceAccount {
+	var planned []PlannedServiceAccount
+	for _, sa := range sas {
+		planned = append(planned, PlannedServiceAccount{
+			Email:       sa.Email,
+			ID:          sa.ID,
+			DisplayName: sa.DisplayName,
+			Description: sa.Description,
+			Collection:  sa.Collection,
+		})
+	}
+	sort.Slice(planned, func(i, j int) bool {
+		return planned[i].Email < planned[j].Email
+	})
+	return planned
+}
+
+func plannedSecret(secret GCPSecret) PlannedSecret {
+	return PlannedSecret{
+		Name:         secret.Name,
+		ResourceName: secret.ResourceName,
+		Collection:   secret.Collection,
+		Type:         secretTypeNames[secret.Type],
+		Labels:       secret.Labels,
+		Annotations:  secret.Annotations,
+	}
+}
+
+// bindingsMissingFrom returns the bindings that have no equivalent in the other bindings
+func bindingsMissingFrom(bindings, other []*iampb.Binding) []PlannedIAMBinding {
+	keys := map[string]bool{}
+	for _, binding := range other {
+		keys[ToCanonicalIAMBinding(binding).makeCanonicalKey()] = true
+	}
+	var missing []PlannedIAMBinding
+	for _, binding := range bindings {
+		if !keys[ToCanonicalIAMBinding(binding).makeCanonicalKey()] {
+			missing = append(missing, plannedIAMBinding(binding))
+		}
+	}
+	sort.Slice(missing, func(i, j int) bool {
+		if missing[i].Role != missing[j].Role {
+			return missing[i].Role < missing[j].Role
+		}
+		return missing[i].Condition.GetTitle() < missing[j].Condition.GetTitle()
+	})
+	return missing
+}
+
+func plannedIAMBinding(binding *iampb.Binding) PlannedIAMBinding {
+	planned := PlannedIAMBinding{Role: binding.Role, Members: make([]string, len(binding.Members))}
+	copy(planned.Members, binding.Members)
+	sort.Strings(planned.Members)
+	if binding.Condition != nil {
+		planned.Condition = &PlannedCondition{
+			Title:       binding.Condition.GetTitle(),
+			Description: binding.Condition.GetDescription(),
+			Expression:  binding.Condition.GetExpression(),
+		}
+	}
+	return planned
+}
+
+func (c *PlannedCondition) GetTitle() string {
+	if c == nil {
+		return ""
+	}
+	return c.Title
+}
+
+func (b PlannedIAMBinding) toBinding() *iampb.Binding {
+	binding := &iampb.Binding{Role: b.Role, Members: b.Members}
+	if b.Condition != nil {
+		binding.Condition = &expr.Expr{
+			Title:       b.Condition.Title,
+			Description: b.Condition.Description,
+			Expression:  b.Condition.Expression,
+		}
+	}
+	return binding
+}
+
+// IsEmpty determines whether the plan does not change anything
+func (p Plan) IsEmpty() bool {
+	c := p.Changes
+	return len(c.ServiceAccountsToCreate)+len(c.ServiceAccountsToDelete)+len(c.SecretsToCreate)+
+		len(c.SecretsToDelete)+len(c.IAMBindingsToAdd)+len(c.IAMBindingsToRemove) == 0
+}
+
+// VerifyLiveState returns an error describing the drift when the live state is not the one the plan was computed against
+func (p Plan) VerifyLiveState(config Config, actualSAs []ServiceAccountInfo, actualSecrets map[string]GCPSecret, actualPolicy *iampb.Policy) error {
+	if p.Config != config {
+		return fmt.Errorf("the plan was made for project %s (%s), not %s (%s)", p.Config.ProjectIdString, p.Config.ProjectIdNumber, config.ProjectIdString, config.ProjectIdNumber)
+	}
+	live := liveStateOf(actualSAs, actualSecrets, actualPolicy)
+	var drifted []string
+	if live.ServiceAccounts != p.LiveState.ServiceAccounts {
+		drifted = append(drifted, "service accounts")
+	}
+	if live.Secrets != p.LiveState.Secrets {
+		drifted = append(drifted, "secrets")
+	}
+	if live.IAMPolicyEtag != p.LiveState.IAMPolicyEtag {
+		drifted = append(drifted, "IAM policy")
+	}
+	if len(drifted) > 0 {
+		return fmt.Errorf("the live state drifted since the plan was made at %s, changed: %s", p.CreatedAt.Format(time.RFC3339), strings.Join(drifted, ", "))
+	}
+	return nil
+}
+
+// Actions returns the actions that apply the plan on top of the live IAM policy, which
+// must be the one the plan was computed against.
+func (p Plan) Actions(actualPolicy *iampb.Policy) Actions {
+	actions := Actions{
+		Config:          p.Config,
+		SAsToCreate:     SAMap{},
+		SAsToDelete:     SAMap{},
+		SecretsToCreate: map[string]GCPSecret{},
+		SecretsToDelete: []GCPSecret{},
+	}
+	for _, sa := range p.Changes.ServiceAccountsToCreate {
+		actions.SAsToCreate[sa.Email] = sa.toServiceAccountInfo()
+	}
+	for _, sa := range p.Changes.ServiceAccountsToDelete {
+		actions.SAsToDelete[sa.Email] = sa.toServiceAccountInfo()
+	}
+	for _, secret := range p.Changes.SecretsToCreate {
+		actions.SecretsToCreate[secret.Name] = secret.toGCPSecret()
+	}
+	for _, secret := range p.Changes.SecretsToDelete {
+		actions.SecretsToDelete = append(actions.SecretsToDelete, secret.toGCPSecret())
+	}
+	if len(p.Changes.IAMBindingsToAdd) == 0 && len(p.Changes.IAMBindingsToRemove) == 0 {
+		return actions
+	}
+	removed := map[string]bool{}
+	for _, binding := range p.Changes.IAMBindingsToRemove {
+		removed[ToCanonicalIAMBinding(binding.toBinding()).makeCanonicalKey()] = true
+	}
+	var bindings []*iampb.Binding
+	for _, binding := range actualPolicy.GetBindings() {
+		if !removed[ToCanonicalIAMBinding(binding).makeCanonicalKey()] {
+			bindings = append(bindings, binding)
+		}
+	}
+	for _, binding := range p.Changes.IAMBindingsToAdd {
+		bindings = append(bindings, binding.toBinding())
+	}
+	actions.ConsolidatedIAMPolicy = &iampb.Policy{
+		Bindings:     bindings,
+		Etag:         actualPolicy.GetEtag(),
+		Version:      3, // required for IAM conditions support
+		AuditConfigs: actualPolicy.GetAuditConfigs(),
+	}
+	return actions
+}
+
+func (sa PlannedServiceAccount) toServiceAccountInfo() ServiceAccountInfo {
+	return ServiceAccountInfo{
+		Email:       sa.Email,
+		DisplayName: sa.DisplayName,
+		ID:          sa.ID,
+		Collection:  sa.Collection,
+		Description: sa.Description,
+	}
+}
+
+func (s PlannedSecret) toGCPSecret() GCPSecret {
+	return GCPSecret{
+		Name:         s.Name,
+		ResourceName: s.ResourceName,
+		Collection:   s.Collection,
+		Labels:       s.Labels,
+		Annotations:  s.Annotations,
+		Type:         secretTypeFromName(s.Type),
+	}
+}
+
+// Render writes the changes of the plan as a table
+func (p Plan) Render(w io.Writer) error {
+	if p.IsEmpty() {
+		_, err := fmt.Fprintln(w, "No changes required.")
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ACTION\tRESOURCE\tNAME\tDETAILS")
+	for _, sa := range p.Changes.ServiceAccountsToCreate {
+		fmt.Fprintf(tw, "+\tservice account\t%s\tcollection %s\n", sa.Email, sa.Collection)
+	}
+	for _, sa := range p.Changes.ServiceAccountsToDelete {
+		fmt.Fprintf(tw, "-\tservice account\t%s\tcollection %s\n", sa.Email, sa.Collection)
+	}
+	for _, secret := range p.Changes.SecretsToCreate {
+		fmt.Fprintf(tw, "+\tsecret\t%s\t%s secret of collection %s\n", secret.Name, secret.Type, secret.Collection)
+	}
+	for _, secret := range p.Changes.SecretsToDelete {
+		fmt.Fprintf(tw, "-\tsecret\t%s\t%s secret of collection %s\n", secret.Name, secret.Type, secret.Collection)
+	}
+	for _, binding := range p.Changes.IAMBindingsToAdd {
+		fmt.Fprintf(tw, "+\tIAM binding\t%s\t%s\n", binding.Role, binding.describe())
+	}
+	for _, binding := range p.Changes.IAMBindingsToRemove {
+		fmt.Fprintf(tw, "-\tIAM binding\t%s\t%s\n", binding.Role, binding.describe())
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	c := p.Changes
+	_, err := fmt.Fprintf(w, "\nPlan: %d to create, %d to delete, %d IAM bindings to add, %d IAM bindings to remove.\n",
+		len(c.ServiceAccountsToCreate)+len(c.SecretsToCreate), len(c.ServiceAccountsToDelete)+len(c.SecretsToDelete),
+		len(c.IAMBindingsToAdd), len(c.IAMBindingsToRemove))
+	return err
+}
+
+func (b PlannedIAMBinding) describe() string {
+	details := fmt.Sprintf("members: %s", strings.Join(b.Members, ", "))
+	if title := b.Condition.GetTitle(); title != "" {
+		details = fmt.Sprintf("condition %q, %s", title, details)
+	}
+	return details
+}
+
+// WritePlan writes the plan to the path, as JSON when the path has a .json extension and as YAML otherwise
+func WritePlan(path string, plan Plan) error {
+	var raw []byte
+	var err error
+	if filepath.Ext(path) == ".json" {
+		raw, err = json.MarshalIndent(plan, "", "  ")
+	} else {
+		raw, err = yaml.Marshal(plan)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to serialize plan: %w", err)
+	}
+	if err := os.WriteFile(path, raw, 0644); err != nil {
+		return fmt.Errorf("failed to write plan: %w", err)
+	}
+	return nil
+}
+
+// LoadPlan reads a plan written by WritePlan
+func LoadPlan(path string) (Plan, error) {
+	var plan Plan
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return plan, fmt.Errorf("failed to read plan: %w", err)
+	}
+	if err := yaml.UnmarshalStrict(raw, &plan); err != nil {
+		return plan, fmt.Errorf("failed to parse plan %s: %w", path, err)
+	}
+	return plan, nil
+}
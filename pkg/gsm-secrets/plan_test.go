@@ -0,0 +1,201 @@
+package gsmsecrets
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/iam/apiv1/iampb"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/genproto/googleapis/type/expr"
+	"google.golang.org/protobuf/testing/protocmp"
+
+	"github.com/openshift/ci-tools/pkg/testhelper"
+)
+
+func planTestState() (Config, []ServiceAccountInfo, map[string]GCPSecret, *iampb.Policy, Actions) {
+	config := Config{ProjectIdString: "test-project", ProjectIdNumber: "123456789"}
+	makeServiceAccount := func(collection string) ServiceAccountInfo {
+		return ServiceAccountInfo{
+			Email:       GetUpdaterSAEmail(collection, config),
+			DisplayName: GetUpdaterSADisplayName(collection),
+			ID:          GetUpdaterSAId(collection),
+			Collection:  collection,
+			Description: GetUpdaterSADescription(collection),
+		}
+	}
+	viewerBinding := func(collection string) *iampb.Binding {
+		return &iampb.Binding{
+			Role:    config.GetSecretAccessorRole(),
+			Members: []string{"group:" + collection + "@redhat.com", "serviceAccount:" + GetUpdaterSAEmail(collection, config)},
+			Condition: &expr.Expr{
+				Expression:  BuildSecretAccessorRoleConditionExpression(collection),
+				Title:       GetSecretsViewerConditionTitle(collection),
+				Description: GetSecretsViewerConditionDescription(collection),
+			},
+		}
+	}
+	unmanaged := &iampb.Binding{Role: "roles/owner", Members: []string{"user:admin@redhat.com"}}
+
+	actualSAs := []ServiceAccountInfo{makeServiceAccount("alpha"), makeServiceAccount("obsolete")}
+	actualSecrets := map[string]GCPSecret{
+		"alpha____index": {Name: "alpha____index", Collection: "alpha", Type: SecretTypeIndex},
+		"obsolete__token": {
+			Name:         "obsolete__token",
+			ResourceName: "projects/123456789/secrets/obsolete__token",
+			Collection:   "obsolete",
+			Type:         SecretTypeGeneric,
+		},
+	}
+	actualPolicy := &iampb.Policy{
+		Bindings: []*iampb.Binding{unmanaged, viewerBinding("alpha"), viewerBinding("obsolete")},
+		Etag:     []byte("etag-1"),
+		AuditConfigs: []*iampb.AuditConfig{
+			{Service: "allServices"},
+		},
+	}
+	actions := Actions{
+		Config: config,
+		SAsToCreate: SAMap{
+			GetUpdaterSAEmail("beta", config): makeServiceAccount("beta"),
+		},
+		SAsToDelete: SAMap{
+			GetUpdaterSAEmail("obsolete", config): makeServiceAccount("obsolete"),
+		},
+		SecretsToCreate: map[string]GCPSecret{
+			"beta__updater-service-account": {Name: "beta__updater-service-account", Collection: "beta", Type: SecretTypeSA},
+			"beta____index":                 {Name: "beta____index", Collection: "beta", Type: SecretTypeIndex},
+		},
+		SecretsToDelete: []GCPSecret{actualSecrets["obsolete__token"]},
+		ConsolidatedIAMPolicy: &iampb.Policy{
+			Bindings: []*iampb.Binding{viewerBinding("alpha"), viewerBinding("beta"), unmanaged},
+			Etag:     []byte("etag-1"),
+			Version:  3,
+		},
+	}
+	return config, actualSAs, actualSecrets, actualPolicy, actions
+}
+
+func TestPlan(t *testing.T) {
+	config, actualSAs, actualSecrets, actualPolicy, actions := planTestState()
+	plan := NewPlan(actions, actualSAs, actualSecrets, actualPolicy, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	var rendered bytes.Buffer
+	if err := plan.Render(&rendered); err != nil {
+		t.Fatalf("failed to render plan: %v", err)
+	}
+	testhelper.CompareWithFixture(t, rendered.String(), testhelper.WithPrefix("rendered-"))
+
+	for _, name := range []string{"plan.yaml", "plan.json"} {
+		path := filepath.Join(t.TempDir(), name)
+		if err := WritePlan(path, plan); err != nil {
+			t.Fatalf("failed to write plan: %v", err)
+		}
+		loaded, err := LoadPlan(path)
+		if err != nil {
+			t.Fatalf("failed to load plan: %v", err)
+		}
+		if diff := cmp.Diff(plan, loaded); diff != "" {
+			t.Errorf("%s: plan changed when loaded: %s", name, diff)
+		}
+		if name == "plan.yaml" {
+			raw, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			testhelper.CompareWithFixture(t, raw, testhelper.WithPrefix("plan-"))
+		}
+	}
+
+	if err := plan.VerifyLiveState(config, actualSAs, actualSecrets, actualPolicy); err != nil {
+		t.Errorf("expected no drift, got %v", err)
+	}
+
+	applied := plan.Actions(actualPolicy)
+	expected := actions
+	// the policy is rebuilt on top of the live one, keeping the audit configs
+	expected.ConsolidatedIAMPolicy = &iampb.Policy{
+		Bindings:     []*iampb.Binding{actualPolicy.Bindings[0], actualPolicy.Bindings[1], actions.ConsolidatedIAMPolicy.Bindings[1]},
+		Etag:         []byte("etag-1"),
+		Version:      3,
+		AuditConfigs: actualPolicy.AuditConfigs,
+	}
+	if diff := cmp.Diff(expected, applied, protocmp.Transform()); diff != "" {
+		t.Errorf("unexpected actions: %s", diff)
+	}
+}
+
+func TestPlanVerifyLiveState(t *testing.T) {
+	config, actualSAs, actualSecrets, actualPolicy, actions := planTestState()
+	plan := NewPlan(actions, actualSAs, actualSecrets, actualPolicy, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	testCases := []struct {
+		name          string
+		config        Config
+		sas           []ServiceAccountInfo
+		secrets       map[string]GCPSecret
+		policy        *iampb.Policy
+		expectedError error
+	}{
+		{
+			name:    "no drift, order of live resources does not matter",
+			config:  config,
+			sas:     []ServiceAccountInfo{actualSAs[1], actualSAs[0]},
+			secrets: actualSecrets,
+			policy:  actualPolicy,
+		},
+		{
+			name:          "other project",
+			config:        Config{ProjectIdString: "other", ProjectIdNumber: "1"},
+			sas:           actualSAs,
+			secrets:       actualSecrets,
+			policy:        actualPolicy,
+			expectedError: errors.New("the plan was made for project test-project (123456789), not other (1)"),
+		},
+		{
+			name:          "service account and secret were deleted",
+			config:        config,
+			sas:           actualSAs[:1],
+			secrets:       map[string]GCPSecret{"alpha____index": actualSecrets["alpha____index"]},
+			policy:        actualPolicy,
+			expectedError: errors.New("the live state drifted since the plan was made at 2024-01-01T00:00:00Z, changed: service accounts, secrets"),
+		},
+		{
+			name:          "IAM policy was updated",
+			config:        config,
+			sas:           actualSAs,
+			secrets:       actualSecrets,
+			policy:        &iampb.Policy{Bindings: actualPolicy.Bindings, Etag: []byte("etag-2")},
+			expectedError: errors.New("the live state drifted since the plan was made at 2024-01-01T00:00:00Z, changed: IAM policy"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := plan.VerifyLiveState(tc.config, tc.sas, tc.secrets, tc.policy)
+			if diff := cmp.Diff(tc.expectedError, err, testhelper.EquateErrorMessage); diff != "" {
+				t.Errorf("unexpected error: %s", diff)
+			}
+		})
+	}
+}
+
+func TestEmptyPlan(t *testing.T) {
+	config, actualSAs, actualSecrets, actualPolicy, _ := planTestState()
+	plan := NewPlan(Actions{Config: config}, actualSAs, actualSecrets, actualPolicy, time.Now())
+	if !plan.IsEmpty() {
+		t.Errorf("expected an empty plan, got %+v", plan.Changes)
+	}
+	var rendered bytes.Buffer
+	if err := plan.Render(&rendered); err != nil {
+		t.Fatalf("failed to render plan: %v", err)
+	}
+	if diff := cmp.Diff("No changes required.\n", rendered.String()); diff != "" {
+		t.Errorf("unexpected output: %s", diff)
+	}
+	if actions := plan.Actions(actualPolicy); actions.ConsolidatedIAMPolicy != nil {
+		t.Errorf("expected no IAM policy update, got %v", actions.ConsolidatedIAMPolicy)
+	}
+}
@@ -2,6 +2,7 @@ package jira
 
 import (
 	"errors"
+	"strings"
 	"testing"
 
 	"github.com/andygrunwald/go-jira"
@@ -40,14 +41,46 @@ type SetIssueStatusResponse struct {
 	Error error
 }
 
-// Fake is an injectable IssueFiler
+// FindOpenIssuesRequest describes a client call to find open issues, the labels are joined with commas.
+type FindOpenIssuesRequest struct {
+	Project, Labels string
+}
+
+// FindOpenIssuesResponse describes a client response for finding open issues.
+type FindOpenIssuesResponse struct {
+	Issues []jira.Issue
+	Error  error
+}
+
+// CommentRequest describes a client call to comment on an issue.
+type CommentRequest struct {
+	IssueKey, Comment string
+}
+
+// CommentResponse describes a client response for commenting on an issue.
+type CommentResponse struct {
+	Error error
+}
+
+// CreateIssueRequest describes a client call to create an issue, the labels are joined with commas.
+type CreateIssueRequest struct {
+	Project, IssueType, Title, Description, Labels string
+}
+
+// Fake is an injectable IssueFiler and IssueTracker
 type Fake struct {
-	behavior            map[IssueRequest]IssueResponse
-	closeBehavior       map[CloseIssueRequest]CloseIssueResponse
-	statusBehavior      map[SetIssueStatusRequest]SetIssueStatusResponse
-	unwanted            []IssueRequest
-	unwantedCloseCalls  []CloseIssueRequest
-	unwantedStatusCalls []SetIssueStatusRequest
+	behavior             map[IssueRequest]IssueResponse
+	closeBehavior        map[CloseIssueRequest]CloseIssueResponse
+	statusBehavior       map[SetIssueStatusRequest]SetIssueStatusResponse
+	findBehavior         map[FindOpenIssuesRequest]FindOpenIssuesResponse
+	commentBehavior      map[CommentRequest]CommentResponse
+	createBehavior       map[CreateIssueRequest]IssueResponse
+	unwanted             []IssueRequest
+	unwantedCloseCalls   []CloseIssueRequest
+	unwantedStatusCalls  []SetIssueStatusRequest
+	unwantedFindCalls    []FindOpenIssuesRequest
+	unwantedCommentCalls []CommentRequest
+	unwantedCreateCalls  []CreateIssueRequest
 }
 
 // SetCloseBehavior configures expected CloseIssue calls and responses.
@@ -66,6 +99,30 @@ func (f *Fake) SetStatusBehavior(behavior map[SetIssueStatusRequest]SetIssueStat
 	}
 }
 
+// SetFindOpenIssuesBehavior configures expected FindOpenIssues calls and responses.
+func (f *Fake) SetFindOpenIssuesBehavior(behavior map[FindOpenIssuesRequest]FindOpenIssuesResponse) {
+	f.findBehavior = make(map[FindOpenIssuesRequest]FindOpenIssuesResponse, len(behavior))
+	for request, response := range behavior {
+		f.findBehavior[request] = response
+	}
+}
+
+// SetCommentBehavior configures expected CommentOnIssue calls and responses.
+func (f *Fake) SetCommentBehavior(behavior map[CommentRequest]CommentResponse) {
+	f.commentBehavior = make(map[CommentRequest]CommentResponse, len(behavior))
+	for request, response := range behavior {
+		f.commentBehavior[request] = response
+	}
+}
+
+// SetCreateIssueBehavior configures expected CreateIssue calls and responses.
+func (f *Fake) SetCreateIssueBehavior(behavior map[CreateIssueRequest]IssueResponse) {
+	f.createBehavior = make(map[CreateIssueRequest]IssueResponse, len(behavior))
+	for request, response := range behavior {
+		f.createBehavior[request] = response
+	}
+}
+
 // FileIssue files the issue using injected behavior
 func (f *Fake) FileIssue(issueType, title, description, reporter, activityType string, logger *logrus.Entry) (*jira.Issue, error) {
 	request := IssueRequest{
@@ -114,6 +171,54 @@ func (f *Fake) SetIssueStatus(issueKey, status string, logger *logrus.Entry) err
 	return response.Error
 }
 
+// FindOpenIssues finds open issues using injected behavior.
+func (f *Fake) FindOpenIssues(project string, labels []string, logger *logrus.Entry) ([]jira.Issue, error) {
+	request := FindOpenIssuesRequest{
+		Project: project,
+		Labels:  strings.Join(labels, ","),
+	}
+	response, registered := f.findBehavior[request]
+	if !registered {
+		f.unwantedFindCalls = append(f.unwantedFindCalls, request)
+		return nil, errors.New("no such find open issues behavior in fake")
+	}
+	delete(f.findBehavior, request)
+	return response.Issues, response.Error
+}
+
+// CommentOnIssue comments on the issue using injected behavior.
+func (f *Fake) CommentOnIssue(issueKey, comment string, logger *logrus.Entry) error {
+	request := CommentRequest{
+		IssueKey: issueKey,
+		Comment:  comment,
+	}
+	response, registered := f.commentBehavior[request]
+	if !registered {
+		f.unwantedCommentCalls = append(f.unwantedCommentCalls, request)
+		return errors.New("no such comment behavior in fake")
+	}
+	delete(f.commentBehavior, request)
+	return response.Error
+}
+
+// CreateIssue creates the issue using injected behavior.
+func (f *Fake) CreateIssue(project, issueType, title, description string, labels []string, logger *logrus.Entry) (*jira.Issue, error) {
+	request := CreateIssueRequest{
+		Project:     project,
+		IssueType:   issueType,
+		Title:       title,
+		Description: description,
+		Labels:      strings.Join(labels, ","),
+	}
+	response, registered := f.createBehavior[request]
+	if !registered {
+		f.unwantedCreateCalls = append(f.unwantedCreateCalls, request)
+		return nil, errors.New("no such create issue behavior in fake")
+	}
+	delete(f.createBehavior, request)
+	return response.Issue, response.Error
+}
+
 // Validate ensures that all expected client calls happened
 func (f *Fake) Validate(t *testing.T) {
 	for request := range f.behavior {
@@ -134,15 +239,37 @@ func (f *Fake) Validate(t *testing.T) {
 	for _, request := range f.unwantedStatusCalls {
 		t.Errorf("fake issue filer got unwanted set-status request: %v", request)
 	}
+	for request := range f.findBehavior {
+		t.Errorf("fake issue tracker did not get find request: %v", request)
+	}
+	for request := range f.commentBehavior {
+		t.Errorf("fake issue tracker did not get comment request: %v", request)
+	}
+	for request := range f.createBehavior {
+		t.Errorf("fake issue tracker did not get create request: %v", request)
+	}
+	for _, request := range f.unwantedFindCalls {
+		t.Errorf("fake issue tracker got unwanted find request: %v", request)
+	}
+	for _, request := range f.unwantedCommentCalls {
+		t.Errorf("fake issue tracker got unwanted comment request: %v", request)
+	}
+	for _, request := range f.unwantedCreateCalls {
+		t.Errorf("fake issue tracker got unwanted create request: %v", request)
+	}
 }
 
 var _ IssueFiler = &Fake{}
+var _ IssueTracker = &Fake{}
 
 // NewFake creates a new fake filer with the injected behavior
 func NewFake(calls map[IssueRequest]IssueResponse) *Fake {
 	return &Fake{
-		behavior:       calls,
-		closeBehavior:  map[CloseIssueRequest]CloseIssueResponse{},
-		statusBehavior: map[SetIssueStatusRequest]SetIssueStatusResponse{},
+		behavior:        calls,
+		closeBehavior:   map[CloseIssueRequest]CloseIssueResponse{},
+		statusBehavior:  map[SetIssueStatusRequest]SetIssueStatusResponse{},
+		findBehavior:    map[FindOpenIssuesRequest]FindOpenIssuesResponse{},
+		commentBehavior: map[CommentRequest]CommentResponse{},
+		createBehavior:  map[CreateIssueRequest]IssueResponse{},
 	}
 }
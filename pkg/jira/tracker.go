@@ -0,0 +1,101 @@
+package jira
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/sirupsen/logrus"
+
+	jirautil "sigs.k8s.io/prow/pkg/jira"
+)
+
+// maxOpenIssues bounds the open issues a search returns, more than a few open issues
+// for the same labels means they need triage by a human anyway
+const maxOpenIssues = 10
+
+// IssueTracker knows how to find, comment on and file the issues that automation
+// tracks by their labels, in any project
+type IssueTracker interface {
+	FindOpenIssues(project string, labels []string, logger *logrus.Entry) ([]jira.Issue, error)
+	CommentOnIssue(issueKey, comment string, logger *logrus.Entry) error
+	CreateIssue(project, issueType, title, description string, labels []string, logger *logrus.Entry) (*jira.Issue, error)
+}
+
+func (a *jiraAdapter) Search(jql string, options *jira.SearchOptions) ([]jira.Issue, *jira.Response, error) {
+	return a.delegate.Issue.Search(jql, options)
+}
+
+func (a *jiraAdapter) AddComment(issueKey string, comment *jira.Comment) (*jira.Comment, *jira.Response, error) {
+	return a.delegate.Issue.AddComment(issueKey, comment)
+}
+
+type trackerClient interface {
+	Search(jql string, options *jira.SearchOptions) ([]jira.Issue, *jira.Response, error)
+	AddComment(issueKey string, comment *jira.Comment) (*jira.Comment, *jira.Response, error)
+	CreateIssue(issue *jira.Issue) (*jira.Issue, *jira.Response, error)
+}
+
+type tracker struct {
+	jiraClient trackerClient
+}
+
+// openIssuesQuery selects the unresolved issues of the project that have any of the labels,
+// the oldest first since that is the one the others duplicate
+func openIssuesQuery(project string, labels []string) string {
+	quoted := make([]string, 0, len(labels))
+	for _, label := range labels {
+		quoted = append(quoted, fmt.Sprintf("%q", label))
+	}
+	return fmt.Sprintf("project = %q AND resolution = Unresolved AND labels in (%s) ORDER BY created ASC", project, strings.Join(quoted, ", "))
+}
+
+// FindOpenIssues lists the unresolved issues of the project that have any of the labels, with
+// their description, creation time and comments
+func (t *tracker) FindOpenIssues(project string, labels []string, logger *logrus.Entry) ([]jira.Issue, error) {
+	if len(labels) == 0 {
+		return nil, nil
+	}
+	query := openIssuesQuery(project, labels)
+	logger.WithField("query", query).Debug("Searching for open Jira issues.")
+	issues, response, err := t.jiraClient.Search(query, &jira.SearchOptions{MaxResults: maxOpenIssues, Fields: []string{"summary", "labels", "status", "description", "created", "comment"}})
+	if err := jirautil.HandleJiraError(response, err); err != nil {
+		return nil, fmt.Errorf("could not search for open issues: %w", err)
+	}
+	return issues, nil
+}
+
+// CommentOnIssue adds a comment to the issue
+func (t *tracker) CommentOnIssue(issueKey, comment string, logger *logrus.Entry) error {
+	logger.WithField("issue", issueKey).Debug("Commenting on Jira issue.")
+	_, response, err := t.jiraClient.AddComment(issueKey, &jira.Comment{Body: comment})
+	if err := jirautil.HandleJiraError(response, err); err != nil {
+		return fmt.Errorf("could not comment on %s: %w", issueKey, err)
+	}
+	return nil
+}
+
+// CreateIssue files an issue with the labels in the project, the issue type is given by its name
+func (t *tracker) CreateIssue(project, issueType, title, description string, labels []string, logger *logrus.Entry) (*jira.Issue, error) {
+	logger.WithFields(logrus.Fields{
+		"project": project,
+		"title":   title,
+		"type":    issueType,
+	}).Debug("Filing Jira issue.")
+	issue, response, err := t.jiraClient.CreateIssue(&jira.Issue{Fields: &jira.IssueFields{
+		Project:     jira.Project{Key: project},
+		Type:        jira.IssueType{Name: issueType},
+		Summary:     title,
+		Description: description,
+		Labels:      labels,
+	}})
+	if err := jirautil.HandleJiraError(response, err); err != nil {
+		return nil, fmt.Errorf("could not file issue in %s: %w", project, err)
+	}
+	return issue, nil
+}
+
+// NewIssueTracker creates an IssueTracker that uses the client
+func NewIssueTracker(jiraClient *jira.Client) IssueTracker {
+	return &tracker{jiraClient: &jiraAdapter{delegate: jiraClient}}
+}
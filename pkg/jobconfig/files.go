@@ -182,12 +182,17 @@ func OperateOnJobConfigSubdir(configDir, subDir string, knownInfraJobFiles sets.
 }
 
 func OperateOnJobConfigSubdirPaths(configDir, subDir string, knownInfraJobFiles sets.Set[string], callback func(*Info) error) error {
-	if err := filepath.WalkDir(filepath.Join(configDir, subDir), func(path string, info fs.DirEntry, err error) error {
+	root := filepath.Join(configDir, subDir)
+	if err := filepath.WalkDir(root, func(path string, info fs.DirEntry, err error) error {
 		logger := logrus.WithField("source-file", path)
 		if err != nil {
 			logger.WithError(err).Error("Failed to walk file/directory")
 			return nil
 		}
+		// hidden directories, like the ones holding generated PipelineRuns, hold no Prow jobs
+		if info.IsDir() && path != root && strings.HasPrefix(info.Name(), ".") {
+			return filepath.SkipDir
+		}
 		if !info.IsDir() && filepath.Ext(path) == ".yaml" {
 			if knownInfraJobFiles.Has(info.Name()) {
 				logger.Debugf("Skipping known infra file: %s", info.Name())
@@ -272,11 +277,57 @@ func readFromFile(path string) (*prowconfig.JobConfig, error) {
 	return jobConfig, nil
 }
 
+// Writer writes the jobs generated for a repository into a job directory. WriteToDir
+// writes them as Prow job configuration, other writers may write them in a form
+// another CI system consumes.
+type Writer func(jobDir, org, repo string, jobConfig *prowconfig.JobConfig, generator Generator, matchLabels labels.Set) error
+
+var _ Writer = WriteToDir
+
 // WriteToDir takes a JobConfig and a target directory, and writes the Prow job configuration
 // into files in that directory. Jobs are sharded by branch and by type. If
 // target files already exist and contain Prow job configuration, the jobs will
 // be merged. Jobs will be pruned based on the provided Generator that match the matchLabels set
 func WriteToDir(jobDir, org, repo string, jobConfig *prowconfig.JobConfig, generator Generator, matchLabels labels.Set) error {
+	files, allJobs := ShardByFile(org, repo, jobConfig, generator)
+	jobDirForComponent := filepath.Join(jobDir, org, repo)
+	if err := os.MkdirAll(jobDirForComponent, os.ModePerm); err != nil {
+		return err
+	}
+	if err := OperateOnJobConfigSubdir(jobDirForComponent, "", make(sets.Set[string]), func(jobConfig *prowconfig.JobConfig, info *Info) error {
+		file := filepath.Base(info.Filename)
+		if generated, ok := files[file]; ok {
+			delete(files, file)
+			if len(generated.PresubmitsStatic) != 0 || len(generated.PostsubmitsStatic) != 0 || len(generated.Periodics) != 0 {
+				mergeJobConfig(jobConfig, generated, allJobs)
+				sortConfigFields(jobConfig)
+			}
+		}
+		jobConfig, err := Prune(jobConfig, generator, matchLabels)
+		if err != nil {
+			return err
+		}
+		return WriteToFile(info.Filename, jobConfig)
+	}); err != nil {
+		return err
+	}
+	for file, jobConfig := range files {
+		jobConfig, err := Prune(jobConfig, generator, matchLabels)
+		if err != nil {
+			return err
+		}
+		sortConfigFields(jobConfig)
+		if err := WriteToFile(filepath.Join(jobDirForComponent, file), jobConfig); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ShardByFile labels the jobs of the repository as newly generated by the generator
+// and shards them by branch and by type into the files of the job directory of the
+// repository they are written to. The names of all jobs are returned as well.
+func ShardByFile(org, repo string, jobConfig *prowconfig.JobConfig, generator Generator) (map[string]*prowconfig.JobConfig, sets.Set[string]) {
 	allJobs := sets.Set[string]{}
 	files := map[string]*prowconfig.JobConfig{}
 	key := fmt.Sprintf("%s/%s", org, repo)
@@ -337,38 +388,7 @@ func WriteToDir(jobDir, org, repo string, jobConfig *prowconfig.JobConfig, gener
 		}
 	}
 
-	jobDirForComponent := filepath.Join(jobDir, org, repo)
-	if err := os.MkdirAll(jobDirForComponent, os.ModePerm); err != nil {
-		return err
-	}
-	if err := OperateOnJobConfigSubdir(jobDirForComponent, "", make(sets.Set[string]), func(jobConfig *prowconfig.JobConfig, info *Info) error {
-		file := filepath.Base(info.Filename)
-		if generated, ok := files[file]; ok {
-			delete(files, file)
-			if len(generated.PresubmitsStatic) != 0 || len(generated.PostsubmitsStatic) != 0 || len(generated.Periodics) != 0 {
-				mergeJobConfig(jobConfig, generated, allJobs)
-				sortConfigFields(jobConfig)
-			}
-		}
-		jobConfig, err := Prune(jobConfig, generator, matchLabels)
-		if err != nil {
-			return err
-		}
-		return WriteToFile(info.Filename, jobConfig)
-	}); err != nil {
-		return err
-	}
-	for file, jobConfig := range files {
-		jobConfig, err := Prune(jobConfig, generator, matchLabels)
-		if err != nil {
-			return err
-		}
-		sortConfigFields(jobConfig)
-		if err := WriteToFile(filepath.Join(jobDirForComponent, file), jobConfig); err != nil {
-			return err
-		}
-	}
-	return nil
+	return files, allJobs
 }
 
 // Given two JobConfig, merge jobs from the `source` one to `destination`
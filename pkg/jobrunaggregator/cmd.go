@@ -6,6 +6,8 @@ import (
 
 	"github.com/openshift/ci-tools/pkg/jobrunaggregator/jobrunaggregatoranalyzer"
 	"github.com/openshift/ci-tools/pkg/jobrunaggregator/jobrunbigqueryloader"
+	"github.com/openshift/ci-tools/pkg/jobrunaggregator/jobrunchangepointanalyzer"
+	"github.com/openshift/ci-tools/pkg/jobrunaggregator/jobrunflakeanalyzer"
 	"github.com/openshift/ci-tools/pkg/jobrunaggregator/jobrunhistoricaldataanalyzer"
 	"github.com/openshift/ci-tools/pkg/jobrunaggregator/jobruntestcaseanalyzer"
 	"github.com/openshift/ci-tools/pkg/jobrunaggregator/jobtableprimer"
@@ -28,7 +30,9 @@ func NewJobAggregatorCommand() *cobra.Command {
 
 	cmd.AddCommand(jobrunbigqueryloader.NewBigQueryDisruptionUploadFlagsCommand())
 	cmd.AddCommand(jobrunbigqueryloader.NewBigQueryAlertUploadFlagsCommand())
+	cmd.AddCommand(jobrunbigqueryloader.NewOfflineDataLoadCommand())
 	cmd.AddCommand(jobrunaggregatoranalyzer.NewJobRunsAnalyzerCommand())
+	cmd.AddCommand(jobrunflakeanalyzer.NewJobRunFlakeAnalyzerCommand())
 	cmd.AddCommand(jobtableprimer.NewPrimeJobTableCommand())
 
 	cmd.AddCommand(releasebigqueryloader.NewBigQueryReleaseTableCreateFlagsCommand())
@@ -37,5 +41,6 @@ func NewJobAggregatorCommand() *cobra.Command {
 	cmd.AddCommand(jobruntestcaseanalyzer.NewJobRunsTestCaseAnalyzerCommand())
 
 	cmd.AddCommand(jobrunhistoricaldataanalyzer.NewJobRunHistoricalDataAnalyzerCommand())
+	cmd.AddCommand(jobrunchangepointanalyzer.NewJobRunChangePointAnalyzerCommand())
 	return cmd
 }
@@ -13,6 +13,7 @@ import (
 	"github.com/sirupsen/logrus"
 	"gopkg.in/yaml.v2"
 
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/utils/clock"
 	prowjobclientset "sigs.k8s.io/prow/pkg/client/clientset/versioned"
 
@@ -50,6 +51,17 @@ type JobRunAggregatorAnalyzerOptions struct {
 
 	staticJobRunIdentifiers []jobrunaggregatorlib.JobRunIdentifier
 	gcsBucket               string
+
+	// quarantinedTests are skipped instead of failing the aggregation
+	quarantinedTests sets.Set[TestKey]
+
+	// reportHistoryDays is how many days of pass rates the report shows for every test, zero leaves the history out
+	reportHistoryDays int
+	// reportURL is where the report bundle is uploaded to, the summary page links to it when set
+	reportURL string
+
+	// regressionFiler files Jira issues for the tests that regressed, nil disables it
+	regressionFiler *regressionFiler
 }
 
 func (o *JobRunAggregatorAnalyzerOptions) loadStaticJobRuns(ctx context.Context) ([]jobrunaggregatorapi.JobRunInfo, error) {
@@ -255,6 +267,7 @@ func (o *JobRunAggregatorAnalyzerOptions) Run(ctx context.Context) error {
 	if err := assignPassFail(ctx, o.jobName, currentAggregationJunitSuites, o.passFailCalculator); err != nil {
 		return err
 	}
+	skipQuarantinedTests(currentAggregationJunitSuites, o.quarantinedTests)
 
 	jobWithVariants, err := o.ciDataClient.GetJobVariants(ctx, o.jobName)
 	if err != nil {
@@ -291,13 +304,23 @@ func (o *JobRunAggregatorAnalyzerOptions) Run(ctx context.Context) error {
 	syntheticSuite := &junit.TestSuite{Children: currentAggregationJunitSuites.Suites}
 	jobrunaggregatorlib.OutputTestCaseFailures([]string{"root"}, syntheticSuite)
 
-	summaryHTML, err := htmlForTestRuns(o.jobName, syntheticSuite)
+	summaryHTML, err := htmlForTestRuns(o.jobName, syntheticSuite, o.reportURL)
 	if err != nil {
 		return err
 	}
 	if err := os.WriteFile(filepath.Join(o.workingDir, "aggregation-testrun-summary.html"), []byte(summaryHTML), 0644); err != nil {
 		return err
 	}
+	// the report and the regression issues only explain the result, they must not change it
+	report, err := o.writeReport(ctx, currentAggregationJunitSuites, currentAggregationJunit, aggregationConfiguration.FinishedJobs)
+	if err != nil {
+		alog.WithError(err).Warn("failed to write the aggregation report")
+	}
+	if o.regressionFiler != nil {
+		if err := o.regressionFiler.fileRegressions(ctx, o.jobName, o.payloadTag, o.reportURL, report, o.passFailCalculator); err != nil {
+			alog.WithError(err).Warn("failed to file regressions")
+		}
+	}
 
 	if hasBlockingFailedTestCase(syntheticSuite) {
 		// we already indicated failure messages above
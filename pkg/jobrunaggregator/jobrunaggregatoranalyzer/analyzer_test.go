@@ -513,6 +513,31 @@ func TestAggregateTestCasePropagatesProperties(t *testing.T) {
 	assert.Equal(t, "informing", combined.Properties[0].Value)
 }
 
+func TestCombineTestSuitesOfMergedJunits(t *testing.T) {
+	// the junit files of a job run that both hold an e2e suite
+	files := []*junit.TestSuites{
+		{Suites: []*junit.TestSuite{{Name: "e2e", NumTests: 2, NumFailed: 1, TestCases: []*junit.TestCase{
+			{Name: "a", FailureOutput: &junit.FailureOutput{Message: "failed"}},
+			{Name: "b"},
+		}}}},
+		{Suites: []*junit.TestSuite{
+			{Name: "e2e", NumTests: 1, TestCases: []*junit.TestCase{{Name: "a"}}},
+			{Name: "upgrade", NumTests: 1, TestCases: []*junit.TestCase{{Name: "c"}}},
+		}},
+	}
+	appended := &junit.TestSuites{}
+	for _, file := range files {
+		appended.Suites = append(appended.Suites, file.Suites...)
+	}
+	merged := junit.Merge(files...)
+	assert.Equal(t, 2, len(merged.Suites))
+
+	fromAppended, fromMerged := &junit.TestSuites{}, &junit.TestSuites{}
+	assert.NoError(t, combineTestSuites(fromAppended, "logs/job", "run1", appended))
+	assert.NoError(t, combineTestSuites(fromMerged, "logs/job", "run1", merged))
+	assert.Equal(t, fromAppended, fromMerged)
+}
+
 func TestInformingTestFailureMessage(t *testing.T) {
 	suite := &junit.TestSuites{
 		Suites: []*junit.TestSuite{
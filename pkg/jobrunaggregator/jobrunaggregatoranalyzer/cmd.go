@@ -2,6 +2,7 @@ package jobrunaggregatoranalyzer
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"time"
 
@@ -12,13 +13,16 @@ import (
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/utils/clock"
 	prowjobclientset "sigs.k8s.io/prow/pkg/client/clientset/versioned"
+	prowflagutil "sigs.k8s.io/prow/pkg/flagutil"
 
+	"github.com/openshift/ci-tools/pkg/jira"
 	"github.com/openshift/ci-tools/pkg/jobrunaggregator/jobrunaggregatorlib"
 )
 
 type JobRunsAnalyzerFlags struct {
 	DataCoordinates *jobrunaggregatorlib.BigQueryDataCoordinates
 	Authentication  *jobrunaggregatorlib.GoogleAuthenticationFlags
+	Offline         *jobrunaggregatorlib.OfflineDataFlags
 
 	JobName                     string
 	WorkingDir                  string
@@ -32,16 +36,35 @@ type JobRunsAnalyzerFlags struct {
 	StaticJobRunIdentifierPath string
 	StaticJobRunIdentifierJSON string
 	GCSBucket                  string
+	FlakeReport                string
+	ReportHistoryDays          int
+	ReportURL                  string
+
+	FileRegressions             bool
+	RegressionDryRun            bool
+	RegressionJiraProject       string
+	RegressionJiraIssueType     string
+	RegressionMinPassPercentage float64
+	RegressionMaxIssues         int
+	RegressionCommentCooldown   time.Duration
+	Jira                        prowflagutil.JiraOptions
 }
 
 func NewJobRunsAnalyzerFlags() *JobRunsAnalyzerFlags {
 	return &JobRunsAnalyzerFlags{
 		DataCoordinates: jobrunaggregatorlib.NewBigQueryDataCoordinates(),
 		Authentication:  jobrunaggregatorlib.NewGoogleAuthenticationFlags(),
+		Offline:         jobrunaggregatorlib.NewOfflineDataFlags(),
 
 		WorkingDir:                  "job-aggregator-working-dir",
 		EstimatedJobStartTimeString: time.Now().Format(kubeTimeSerializationLayout),
 		Timeout:                     5*time.Hour + 30*time.Minute,
+		ReportHistoryDays:           14,
+		RegressionJiraProject:       "OCPBUGS",
+		RegressionJiraIssueType:     jira.IssueTypeBug,
+		RegressionMinPassPercentage: 98,
+		RegressionMaxIssues:         5,
+		RegressionCommentCooldown:   24 * time.Hour,
 	}
 }
 
@@ -50,6 +73,7 @@ const kubeTimeSerializationLayout = time.RFC3339
 func (f *JobRunsAnalyzerFlags) BindFlags(fs *pflag.FlagSet) {
 	f.DataCoordinates.BindFlags(fs)
 	f.Authentication.BindFlags(fs)
+	f.Offline.BindFlags(fs)
 
 	fs.StringVar(&f.JobName, "job", f.JobName, "The name of the job to inspect, like periodic-ci-openshift-release-master-ci-4.9-e2e-gcp-upgrade")
 	fs.StringVar(&f.WorkingDir, "working-dir", f.WorkingDir, "The directory to store caches, output, and the like.")
@@ -65,6 +89,20 @@ func (f *JobRunsAnalyzerFlags) BindFlags(fs *pflag.FlagSet) {
 	fs.StringVar(&f.StaticJobRunIdentifierJSON, "static-run-info-json", f.StaticJobRunIdentifierJSON, "The optional JSON formatted string of JobRunIdentifier array used for aggregated analysis")
 
 	fs.StringVar(&f.GCSBucket, "google-storage-bucket", "test-platform-results", "The optional GCS Bucket holding test artifacts")
+	fs.StringVar(&f.FlakeReport, "flake-report", f.FlakeReport, "The optional flake report written by analyze-flakes, the failures of the tests it recommends for quarantine are reported as skips")
+	fs.IntVar(&f.ReportHistoryDays, "report-history-days", f.ReportHistoryDays, "How many days of pass rates the aggregation report shows for every failed test, 0 leaves the history out")
+	fs.StringVar(&f.ReportURL, "report-url", f.ReportURL, "The optional URL the aggregation-report directory of the working dir is uploaded to, the summary page links to it")
+
+	fs.BoolVar(&f.FileRegressions, "file-regressions", f.FileRegressions, "File a Jira issue for every test that fails aggregation although it passed nearly always before, or comment on the open issue about the test or its failure signature")
+	fs.BoolVar(&f.RegressionDryRun, "regression-dry-run", f.RegressionDryRun, "Log the regression issues and comments instead of writing them, the issues are still searched for when Jira is configured")
+	fs.StringVar(&f.RegressionJiraProject, "regression-jira-project", f.RegressionJiraProject, "The Jira project regression issues are searched for and filed in")
+	fs.StringVar(&f.RegressionJiraIssueType, "regression-jira-issue-type", f.RegressionJiraIssueType, "The type of the regression issues")
+	fs.Float64Var(&f.RegressionMinPassPercentage, "regression-min-pass-percentage", f.RegressionMinPassPercentage, "The historical pass percentage from which a test that fails aggregation is a regression")
+	fs.IntVar(&f.RegressionMaxIssues, "regression-max-issues", f.RegressionMaxIssues, "The most regression issues filed or commented on for one aggregation")
+	fs.DurationVar(&f.RegressionCommentCooldown, "regression-comment-cooldown", f.RegressionCommentCooldown, "How long after a regression issue was filed or last commented on it is not commented on again, an issue is never commented on twice for the same payload")
+	goFlags := flag.NewFlagSet("jira", flag.ContinueOnError)
+	f.Jira.AddFlags(goFlags)
+	fs.AddGoFlagSet(goFlags)
 }
 
 func NewJobRunsAnalyzerCommand() *cobra.Command {
@@ -112,11 +150,19 @@ func (f *JobRunsAnalyzerFlags) Validate() error {
 	if _, err := time.Parse(kubeTimeSerializationLayout, f.EstimatedJobStartTimeString); err != nil {
 		return err
 	}
-	if err := f.DataCoordinates.Validate(); err != nil {
+	if err := f.Offline.Validate(); err != nil {
 		return err
 	}
-	if err := f.Authentication.Validate(); err != nil {
-		return err
+	if !f.Offline.Enabled() {
+		if err := f.DataCoordinates.Validate(); err != nil {
+			return err
+		}
+	}
+	// the job runs are read from GCS unless they are mirrored
+	if len(f.Offline.MirrorDir) == 0 {
+		if err := f.Authentication.Validate(); err != nil {
+			return err
+		}
 	}
 	if len(f.PayloadTag) > 0 && len(f.AggregationID) > 0 {
 		return fmt.Errorf("cannot specify both --payload-tag and --aggregation-id")
@@ -127,6 +173,26 @@ func (f *JobRunsAnalyzerFlags) Validate() error {
 	if len(f.AggregationID) > 0 && len(f.ExplicitGCSPrefix) == 0 {
 		return fmt.Errorf("if --aggregation-id is specified, you must specify --explicit-gcs-prefix")
 	}
+	if f.ReportHistoryDays < 0 {
+		return fmt.Errorf("--report-history-days must not be negative")
+	}
+	if f.FileRegressions {
+		if len(f.RegressionJiraProject) == 0 || len(f.RegressionJiraIssueType) == 0 {
+			return fmt.Errorf("--regression-jira-project and --regression-jira-issue-type are required with --file-regressions")
+		}
+		if f.RegressionMinPassPercentage <= 0 || f.RegressionMinPassPercentage > 100 {
+			return fmt.Errorf("--regression-min-pass-percentage must be more than 0 and at most 100")
+		}
+		if f.RegressionMaxIssues < 1 {
+			return fmt.Errorf("--regression-max-issues must be at least 1")
+		}
+		if f.RegressionCommentCooldown < 0 {
+			return fmt.Errorf("--regression-comment-cooldown must not be negative")
+		}
+		if err := f.Jira.Validate(false); err != nil {
+			return err
+		}
+	}
 	if len(f.JobStateQuerySource) > 0 {
 		if _, ok := jobrunaggregatorlib.KnownQuerySources[f.JobStateQuerySource]; !ok {
 			return fmt.Errorf("unknown query-source %s, valid values are: %+q", f.JobStateQuerySource, sets.List(jobrunaggregatorlib.KnownQuerySources))
@@ -144,17 +210,30 @@ func (f *JobRunsAnalyzerFlags) ToOptions(ctx context.Context) (*JobRunAggregator
 		return nil, err
 	}
 
-	bigQueryClient, err := f.Authentication.NewBigQueryClient(ctx, f.DataCoordinates.ProjectID)
-	if err != nil {
-		return nil, err
+	var ciDataClient jobrunaggregatorlib.CIDataClient
+	if f.Offline.Enabled() {
+		ciDataClient, err = f.Offline.NewCIDataClient()
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		bigQueryClient, err := f.Authentication.NewBigQueryClient(ctx, f.DataCoordinates.ProjectID)
+		if err != nil {
+			return nil, err
+		}
+		ciDataClient = jobrunaggregatorlib.NewRetryingCIDataClient(
+			jobrunaggregatorlib.NewCIDataClient(*f.DataCoordinates, bigQueryClient),
+		)
 	}
-	ciDataClient := jobrunaggregatorlib.NewRetryingCIDataClient(
-		jobrunaggregatorlib.NewCIDataClient(*f.DataCoordinates, bigQueryClient),
-	)
 
-	ciGCSClient, err := f.Authentication.NewCIGCSClient(ctx, f.GCSBucket)
-	if err != nil {
-		return nil, err
+	var ciGCSClient jobrunaggregatorlib.CIGCSClient
+	if len(f.Offline.MirrorDir) > 0 {
+		ciGCSClient = f.Offline.NewCIGCSClient(f.GCSBucket)
+	} else {
+		ciGCSClient, err = f.Authentication.NewCIGCSClient(ctx, f.GCSBucket)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	var staticJobRunIdentifiers []jobrunaggregatorlib.JobRunIdentifier
@@ -200,6 +279,36 @@ func (f *JobRunsAnalyzerFlags) ToOptions(ctx context.Context) (*JobRunAggregator
 		}
 	}
 
+	var quarantinedTests sets.Set[TestKey]
+	if len(f.FlakeReport) > 0 {
+		quarantinedTests, err = readQuarantinedTests(f.FlakeReport, f.JobName)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var regressions *regressionFiler
+	if f.FileRegressions {
+		regressions = &regressionFiler{
+			project:               f.RegressionJiraProject,
+			issueType:             f.RegressionJiraIssueType,
+			minimumPassPercentage: f.RegressionMinPassPercentage,
+			maxIssues:             f.RegressionMaxIssues,
+			commentCooldown:       f.RegressionCommentCooldown,
+			now:                   time.Now,
+			dryRun:                f.RegressionDryRun,
+		}
+		jiraClient, err := f.Jira.Client()
+		switch {
+		case err == nil:
+			regressions.tracker = jira.NewIssueTracker(jiraClient.JiraClient())
+		case f.RegressionDryRun:
+			logrus.WithError(err).Info("Jira is not configured, the dry run will not search for open regression issues")
+		default:
+			return nil, fmt.Errorf("failed to create the Jira client: %w", err)
+		}
+	}
+
 	return &JobRunAggregatorAnalyzerOptions{
 		explicitGCSPrefix:       f.ExplicitGCSPrefix,
 		jobRunLocator:           jobRunLocator,
@@ -216,5 +325,9 @@ func (f *JobRunsAnalyzerFlags) ToOptions(ctx context.Context) (*JobRunAggregator
 		prowJobMatcherFunc:      prowJobMatcherFunc,
 		staticJobRunIdentifiers: staticJobRunIdentifiers,
 		gcsBucket:               f.GCSBucket,
+		quarantinedTests:        quarantinedTests,
+		reportHistoryDays:       f.ReportHistoryDays,
+		reportURL:               f.ReportURL,
+		regressionFiler:         regressions,
 	}, nil
 }
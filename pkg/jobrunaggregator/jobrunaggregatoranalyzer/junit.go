@@ -89,6 +89,11 @@ func (a *aggregatedJobRunJunit) aggregateAllJobRuns() (*junit.TestSuites, error)
 	return a.combinedJunit, nil
 }
 
+// combineTestSuites adds the results of a job run to the combined suites. Unlike junit.Merge, which keeps a test
+// case for every run of a test, it aggregates all runs of a test into a single test case whose system-out holds
+// the TestCaseDetails with the passes, failures and skips of every job run, which is what the pass/fail
+// calculation and the published junit of the aggregation are built on. The suites of a job run are matched by
+// name like junit.Merge does, so it does not matter whether they were merged before.
 func combineTestSuites(combined *junit.TestSuites, jobGCSBucketRoot, toAddJobRunID string, toAdd *junit.TestSuites) error {
 	for _, suiteToAdd := range toAdd.Suites {
 		combinedSuite := ensureSuiteInSuites(combined, suiteToAdd.Name)
@@ -0,0 +1,77 @@
+package jobrunaggregatoranalyzer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/openshift/ci-tools/pkg/jobrunaggregator/jobrunaggregatorapi"
+	"github.com/openshift/ci-tools/pkg/jobrunaggregator/jobrunaggregatorlib"
+	"github.com/openshift/ci-tools/pkg/junit"
+)
+
+// readQuarantinedTests reads the tests of the job recommended for quarantine from a flake report written by analyze-flakes
+func readQuarantinedTests(flakeReportPath, jobName string) (sets.Set[TestKey], error) {
+	raw, err := os.ReadFile(flakeReportPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read flake report: %w", err)
+	}
+	report := &jobrunaggregatorapi.FlakeReport{}
+	if err := json.Unmarshal(raw, report); err != nil {
+		return nil, fmt.Errorf("failed to parse flake report %q: %w", flakeReportPath, err)
+	}
+	quarantined := sets.New[TestKey]()
+	for _, test := range report.Tests {
+		if test.Quarantine && test.JobName == jobName {
+			quarantined.Insert(TestKey{TestCaseName: test.TestName, CombinedTestSuiteName: test.TestSuiteName})
+		}
+	}
+	return quarantined, nil
+}
+
+// skipQuarantinedTests turns the failures of quarantined tests into skips so they do not fail the aggregation.
+// Informing tests are left alone since they do not fail the aggregation anyway.
+func skipQuarantinedTests(combined *junit.TestSuites, quarantined sets.Set[TestKey]) {
+	if quarantined.Len() == 0 {
+		return
+	}
+	for _, currTestSuite := range combined.Suites {
+		skipQuarantinedTestsInSuite([]string{}, currTestSuite, quarantined)
+	}
+}
+
+// skipQuarantinedTestsInSuite returns the number of failures that were turned into skips
+func skipQuarantinedTestsInSuite(parentTestSuites []string, combined *junit.TestSuite, quarantined sets.Set[TestKey]) uint {
+	skipped := uint(0)
+
+	currSuiteNames := append(append([]string{}, parentTestSuites...), combined.Name)
+	for _, currTestSuite := range combined.Children {
+		skipped += skipQuarantinedTestsInSuite(currSuiteNames, currTestSuite, quarantined)
+	}
+
+	combinedSuiteName := strings.Join(currSuiteNames, jobrunaggregatorlib.TestSuitesSeparator)
+	for _, currTestCase := range combined.TestCases {
+		if currTestCase.FailureOutput == nil || isInformingTest(currTestCase) {
+			continue
+		}
+		if !quarantined.Has(TestKey{TestCaseName: currTestCase.Name, CombinedTestSuiteName: combinedSuiteName}) {
+			continue
+		}
+		currTestCase.SkipMessage = &junit.SkipMessage{
+			Message: "*** QUARANTINED: This test is flaky in this job, see analyze-flakes. The failure would have been:\n" + currTestCase.FailureOutput.Message,
+		}
+		currTestCase.FailureOutput = nil
+		skipped++
+	}
+
+	if combined.NumFailed >= skipped {
+		combined.NumFailed -= skipped
+	} else {
+		combined.NumFailed = 0
+	}
+	combined.NumSkipped += skipped
+	return skipped
+}
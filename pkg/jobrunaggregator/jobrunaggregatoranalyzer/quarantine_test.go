@@ -0,0 +1,73 @@
+package jobrunaggregatoranalyzer
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/openshift/ci-tools/pkg/junit"
+)
+
+func TestSkipQuarantinedTests(t *testing.T) {
+	combined := &junit.TestSuites{
+		Suites: []*junit.TestSuite{
+			{
+				Name:      "openshift-tests",
+				NumFailed: 3,
+				TestCases: []*junit.TestCase{
+					{Name: "flaky test", FailureOutput: &junit.FailureOutput{Message: "failed"}},
+					{Name: "broken test", FailureOutput: &junit.FailureOutput{Message: "failed"}},
+					{Name: "passing flaky test"},
+				},
+				Children: []*junit.TestSuite{
+					{
+						Name:      "nested",
+						NumFailed: 1,
+						TestCases: []*junit.TestCase{
+							{Name: "flaky test", FailureOutput: &junit.FailureOutput{Message: "nested failure"}},
+						},
+					},
+				},
+			},
+		},
+	}
+	quarantined := sets.New[TestKey](
+		TestKey{TestCaseName: "flaky test", CombinedTestSuiteName: "openshift-tests"},
+		TestKey{TestCaseName: "passing flaky test", CombinedTestSuiteName: "openshift-tests"},
+		TestKey{TestCaseName: "flaky test", CombinedTestSuiteName: "openshift-tests|||nested"},
+	)
+
+	skipQuarantinedTests(combined, quarantined)
+
+	expected := &junit.TestSuites{
+		Suites: []*junit.TestSuite{
+			{
+				Name:       "openshift-tests",
+				NumFailed:  1,
+				NumSkipped: 2,
+				TestCases: []*junit.TestCase{
+					{Name: "flaky test", SkipMessage: &junit.SkipMessage{Message: "*** QUARANTINED: This test is flaky in this job, see analyze-flakes. The failure would have been:\nfailed"}},
+					{Name: "broken test", FailureOutput: &junit.FailureOutput{Message: "failed"}},
+					{Name: "passing flaky test"},
+				},
+				Children: []*junit.TestSuite{
+					{
+						Name:       "nested",
+						NumSkipped: 1,
+						TestCases: []*junit.TestCase{
+							{Name: "flaky test", SkipMessage: &junit.SkipMessage{Message: "*** QUARANTINED: This test is flaky in this job, see analyze-flakes. The failure would have been:\nnested failure"}},
+						},
+					},
+				},
+			},
+		},
+	}
+	if diff := cmp.Diff(expected, combined); diff != "" {
+		t.Errorf("unexpected junit (-want +got):\n%s", diff)
+	}
+	if !hasBlockingFailedTestCase(&junit.TestSuite{Children: combined.Suites}) {
+		t.Errorf("expected the broken test to still fail the aggregation")
+	}
+}
@@ -0,0 +1,262 @@
+package jobrunaggregatoranalyzer
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"time"
+
+	gojira "github.com/andygrunwald/go-jira"
+	"github.com/sirupsen/logrus"
+
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+
+	"github.com/openshift/ci-tools/pkg/jira"
+	"github.com/openshift/ci-tools/pkg/jobrunaggregator/jobrunaggregatorapi"
+)
+
+const (
+	// regressionLabel is on every issue filed for a regression, so they can all be found
+	regressionLabel = "aggregation-regression"
+	// maxRegressionTitleLength keeps the title below the limit Jira has for summaries
+	maxRegressionTitleLength = 250
+	// minimumRegressionHistoricalRuns is how many runs the historical pass rate needs before a failure is trusted to
+	// be a regression, a high pass rate of a few runs says little
+	minimumRegressionHistoricalRuns = 20
+	// jiraCommentTimeLayout is how Jira formats the creation time of comments
+	jiraCommentTimeLayout = "2006-01-02T15:04:05.000-0700"
+)
+
+// historicalPassRates is implemented by the baselines that know how often the tests passed before the aggregation
+type historicalPassRates interface {
+	historicalPassPercentage(ctx context.Context, key TestKey) (percentage float64, runs int, found bool, err error)
+}
+
+func (a *weeklyAverageFromTenDays) historicalPassPercentage(ctx context.Context, key TestKey) (float64, int, bool, error) {
+	rows, err := a.getAggregatedTestRuns(ctx)
+	if err != nil {
+		return 0, 0, false, err
+	}
+	row, ok := rows[key]
+	if !ok {
+		return 0, 0, false, nil
+	}
+	return row.WorkingPercentage, row.PassCount + row.FailCount + row.FlakeCount, true, nil
+}
+
+// regressionFiler files a Jira issue for every test that fails aggregation although it passed nearly always before,
+// or comments on the open issue that already tracks the test or its failure signature
+type regressionFiler struct {
+	// tracker is only optional when dryRun is set, the issues are not searched for without it
+	tracker   jira.IssueTracker
+	project   string
+	issueType string
+	// minimumPassPercentage is the historical pass percentage from which a failing test is a regression
+	minimumPassPercentage float64
+	// maxIssues bounds how many issues are filed or commented on for one aggregation, a broken payload fails many
+	// tests at once and one issue for each helps nobody
+	maxIssues int
+	// commentCooldown is how long after an issue was filed or last commented on no other comment is added to it,
+	// every aggregation of a payload stream fails the same way until the regression is fixed
+	commentCooldown time.Duration
+	// now is replaced in tests
+	now func() time.Time
+	// dryRun logs the issues and comments instead of writing them
+	dryRun bool
+}
+
+// regression is a test that failed the aggregation although it usually passes
+type regression struct {
+	test           *reportTest
+	passPercentage float64
+	historicalRuns int
+	// signature is the failure signature of the most common failure of the test
+	signature string
+}
+
+// testLabel is a label that identifies the test in Jira, labels cannot hold spaces so the name is hashed
+func testLabel(key TestKey) string {
+	hash := fnv.New64a()
+	hash.Write([]byte(key.CombinedTestSuiteName))
+	hash.Write([]byte{0})
+	hash.Write([]byte(key.TestCaseName))
+	return fmt.Sprintf("aggregated-test-%016x", hash.Sum64())
+}
+
+func signatureLabel(signature string) string {
+	return "failure-signature-" + signature
+}
+
+func (r *regression) labels() []string {
+	labels := []string{testLabel(TestKey{TestCaseName: r.test.Name, CombinedTestSuiteName: r.test.SuiteName})}
+	if len(r.signature) > 0 {
+		labels = append(labels, signatureLabel(r.signature))
+	}
+	return labels
+}
+
+// findRegressions lists the tests that failed the aggregation and passed at least the minimum percentage of their
+// runs before, in the order of the report
+func (f *regressionFiler) findRegressions(ctx context.Context, report *aggregationReport, passRates historicalPassRates) ([]regression, error) {
+	var regressions []regression
+	for _, test := range report.Tests {
+		if test.Status != "Failed" {
+			continue
+		}
+		percentage, runs, found, err := passRates.historicalPassPercentage(ctx, TestKey{TestCaseName: test.Name, CombinedTestSuiteName: test.SuiteName})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the historical pass rate of %q: %w", test.Name, err)
+		}
+		if !found || runs < minimumRegressionHistoricalRuns || percentage < f.minimumPassPercentage {
+			continue
+		}
+		current := regression{test: test, passPercentage: percentage, historicalRuns: runs}
+		if len(test.Signatures) > 0 && len(test.Signatures[0].Runs) > 0 && len(test.Signatures[0].Runs[0].Message) > 0 {
+			current.signature = test.Signatures[0].Signature
+		}
+		regressions = append(regressions, current)
+	}
+	return regressions, nil
+}
+
+// payloadMention is how the details of a regression name the payload, an issue that holds it was already told about
+// the regression in that payload
+func payloadMention(payloadTag string) string {
+	return fmt.Sprintf("for payload %s,", payloadTag)
+}
+
+// reportedRecently determines whether the issue does not need another comment about the regression in the payload,
+// because it was already told about the payload or was filed or commented on within the cooldown
+func (f *regressionFiler) reportedRecently(issue gojira.Issue, payloadTag string) (bool, string) {
+	if issue.Fields == nil {
+		return false, ""
+	}
+	mention := payloadMention(payloadTag)
+	lastActivity := time.Time(issue.Fields.Created)
+	if strings.Contains(issue.Fields.Description, mention) {
+		return true, "the issue was filed for the payload"
+	}
+	if issue.Fields.Comments != nil {
+		for _, comment := range issue.Fields.Comments.Comments {
+			if comment == nil {
+				continue
+			}
+			if strings.Contains(comment.Body, mention) {
+				return true, "the issue was already commented on for the payload"
+			}
+			if created, err := time.Parse(jiraCommentTimeLayout, comment.Created); err == nil && created.After(lastActivity) {
+				lastActivity = created
+			}
+		}
+	}
+	if f.commentCooldown > 0 && !lastActivity.IsZero() && f.now().Sub(lastActivity) < f.commentCooldown {
+		return true, fmt.Sprintf("the issue was filed or commented on in the last %s", f.commentCooldown)
+	}
+	return false, ""
+}
+
+// regressionDetails describes the failure of the test in the aggregation in Jira markup
+func regressionDetails(jobName, payloadTag, reportURL string, r regression) string {
+	details := &strings.Builder{}
+	fmt.Fprintf(details, "{{%s}} failed the aggregation of %s %s although it passed in %.0f%% of its last %d runs.\n",
+		r.test.Name, jobName, payloadMention(payloadTag), r.passPercentage, r.historicalRuns)
+	if len(r.test.SuiteName) > 0 {
+		fmt.Fprintf(details, "\nSuite: {{%s}}\n", r.test.SuiteName)
+	}
+	if len(r.test.Summary) > 0 {
+		fmt.Fprintf(details, "\n{noformat}\n%s\n{noformat}\n", strings.TrimSpace(r.test.Summary))
+	}
+	if len(reportURL) > 0 {
+		fmt.Fprintf(details, "\nReport: %s/tests/%s\n", strings.TrimSuffix(reportURL, "/"), r.test.FileName)
+	}
+	details.WriteString("\nJob runs:\n")
+	for _, run := range r.test.Runs {
+		if run.Status != jobrunaggregatorapi.TestStatusFailed && run.Status != jobrunaggregatorapi.TestStatusFlaked {
+			continue
+		}
+		fmt.Fprintf(details, "* [%s|%s] %s", run.JobRunID, run.HumanURL, strings.ToLower(run.Status))
+		if len(run.NormalizedMessage) > 0 {
+			fmt.Fprintf(details, ": {{%s}}", run.NormalizedMessage)
+		}
+		details.WriteString("\n")
+	}
+	if len(r.signature) > 0 {
+		fmt.Fprintf(details, "\nFailure signature: %s\n", r.signature)
+	}
+	return details.String()
+}
+
+func regressionTitle(jobName string, r regression) string {
+	title := fmt.Sprintf("%s regressed in %s", r.test.Name, jobName)
+	if len(title) > maxRegressionTitleLength {
+		title = title[:maxRegressionTitleLength-3] + "..."
+	}
+	return title
+}
+
+// fileRegressions files or comments on an issue for every regression, until the limit is reached
+func (f *regressionFiler) fileRegressions(ctx context.Context, jobName, payloadTag, reportURL string, report *aggregationReport, passFailCalculator baseline) error {
+	logger := logrus.WithFields(logrus.Fields{"job": jobName, "payload": payloadTag})
+	passRates, ok := passFailCalculator.(historicalPassRates)
+	if !ok {
+		logger.Info("The pass/fail calculation has no historical pass rates, not filing regressions.")
+		return nil
+	}
+	regressions, err := f.findRegressions(ctx, report, passRates)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for i, r := range regressions {
+		if i >= f.maxIssues {
+			logger.Warnf("Reached the limit of %d regressions, not filing the %d others.", f.maxIssues, len(regressions)-i)
+			break
+		}
+		testLogger := logger.WithField("test", r.test.Name)
+		details := regressionDetails(jobName, payloadTag, reportURL, r)
+
+		var existing []gojira.Issue
+		if f.tracker != nil {
+			issues, err := f.tracker.FindOpenIssues(f.project, r.labels(), testLogger)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("failed to search for issues about %q: %w", r.test.Name, err))
+				continue
+			}
+			existing = issues
+		}
+
+		if len(existing) > 0 {
+			testLogger = testLogger.WithField("issue", existing[0].Key)
+			if skip, reason := f.reportedRecently(existing[0], payloadTag); skip {
+				testLogger.Infof("Not commenting on the open issue about the regression, %s.", reason)
+				continue
+			}
+			if f.dryRun {
+				testLogger.Infof("Dry run: would comment on the open issue:\n%s", details)
+				continue
+			}
+			if err := f.tracker.CommentOnIssue(existing[0].Key, details, testLogger); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			testLogger.Info("Commented on the open issue about the regression.")
+			continue
+		}
+
+		title := regressionTitle(jobName, r)
+		labels := append([]string{regressionLabel}, r.labels()...)
+		if f.dryRun {
+			testLogger.Infof("Dry run: would file %s %q with labels %v:\n%s", f.issueType, title, labels, details)
+			continue
+		}
+		issue, err := f.tracker.CreateIssue(f.project, f.issueType, title, details, labels, testLogger)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		testLogger.WithField("issue", issue.Key).Info("Filed an issue about the regression.")
+	}
+	return utilerrors.NewAggregate(errs)
+}
@@ -0,0 +1,248 @@
+package jobrunaggregatoranalyzer
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andygrunwald/go-jira"
+
+	jiraclient "github.com/openshift/ci-tools/pkg/jira"
+	"github.com/openshift/ci-tools/pkg/jobrunaggregator/jobrunaggregatorapi"
+	"github.com/openshift/ci-tools/pkg/results"
+)
+
+type passRate struct {
+	percentage float64
+	runs       int
+}
+
+// fakePassRates is a baseline that only knows the historical pass rates
+type fakePassRates struct {
+	baseline
+	rates map[TestKey]passRate
+}
+
+func (f *fakePassRates) historicalPassPercentage(_ context.Context, key TestKey) (float64, int, bool, error) {
+	rate, ok := f.rates[key]
+	return rate.percentage, rate.runs, ok, nil
+}
+
+func regressedTest(name string) *reportTest {
+	return &reportTest{
+		Name:      name,
+		SuiteName: "openshift-tests",
+		FileName:  name + ".html",
+		Status:    "Failed",
+		Runs: []reportRun{
+			{JobRunID: "1", HumanURL: "https://prow/1", Status: jobrunaggregatorapi.TestStatusFailed, Message: "timed out waiting for pods", NormalizedMessage: "timed out waiting for pods", Signature: results.Signature("timed out waiting for pods")},
+			{JobRunID: "2", HumanURL: "https://prow/2", Status: jobrunaggregatorapi.TestStatusPassed},
+		},
+		Signatures: []*reportSignature{{
+			Signature: results.Signature("timed out waiting for pods"),
+			Message:   "timed out waiting for pods",
+			Runs:      []reportRun{{JobRunID: "1", Message: "timed out waiting for pods"}},
+		}},
+	}
+}
+
+func TestFileRegressions(t *testing.T) {
+	signature := results.Signature("timed out waiting for pods")
+	regressed := regressedTest("regressed")
+	labelsOf := func(test *reportTest) []string {
+		return []string{testLabel(TestKey{TestCaseName: test.Name, CombinedTestSuiteName: test.SuiteName}), signatureLabel(signature)}
+	}
+	details := func(test *reportTest) string {
+		return regressionDetails("job", "4.18.0-0.nightly", "https://report", regression{test: test, passPercentage: 99, historicalRuns: 100, signature: signature})
+	}
+	rates := &fakePassRates{rates: map[TestKey]passRate{
+		{TestCaseName: "regressed", CombinedTestSuiteName: "openshift-tests"}:  {percentage: 99, runs: 100},
+		{TestCaseName: "other", CombinedTestSuiteName: "openshift-tests"}:      {percentage: 99, runs: 100},
+		{TestCaseName: "flaky", CombinedTestSuiteName: "openshift-tests"}:      {percentage: 80, runs: 100},
+		{TestCaseName: "rarely run", CombinedTestSuiteName: "openshift-tests"}: {percentage: 100, runs: 3},
+		{TestCaseName: "passing", CombinedTestSuiteName: "openshift-tests"}:    {percentage: 99, runs: 100},
+	}}
+	passing := regressedTest("passing")
+	passing.Status = "Passed"
+	now := time.Date(2024, 5, 2, 12, 0, 0, 0, time.UTC)
+	commentedAt := func(at time.Time, body string) *jira.Comments {
+		return &jira.Comments{Comments: []*jira.Comment{{Body: body, Created: at.Format(jiraCommentTimeLayout)}}}
+	}
+
+	testCases := []struct {
+		name      string
+		tests     []*reportTest
+		maxIssues int
+		dryRun    bool
+		noTracker bool
+		setup     func(fake *jiraclient.Fake)
+	}{
+		{
+			name:      "new regression is filed",
+			tests:     []*reportTest{regressed},
+			maxIssues: 5,
+			setup: func(fake *jiraclient.Fake) {
+				fake.SetFindOpenIssuesBehavior(map[jiraclient.FindOpenIssuesRequest]jiraclient.FindOpenIssuesResponse{
+					{Project: "OCPBUGS", Labels: strings.Join(labelsOf(regressed), ",")}: {},
+				})
+				fake.SetCreateIssueBehavior(map[jiraclient.CreateIssueRequest]jiraclient.IssueResponse{
+					{
+						Project:     "OCPBUGS",
+						IssueType:   "Bug",
+						Title:       "regressed regressed in job",
+						Description: details(regressed),
+						Labels:      strings.Join(append([]string{regressionLabel}, labelsOf(regressed)...), ","),
+					}: {Issue: &jira.Issue{Key: "OCPBUGS-1"}},
+				})
+			},
+		},
+		{
+			name:      "known regression is commented on",
+			tests:     []*reportTest{regressed},
+			maxIssues: 5,
+			setup: func(fake *jiraclient.Fake) {
+				fake.SetFindOpenIssuesBehavior(map[jiraclient.FindOpenIssuesRequest]jiraclient.FindOpenIssuesResponse{
+					{Project: "OCPBUGS", Labels: strings.Join(labelsOf(regressed), ",")}: {Issues: []jira.Issue{{Key: "OCPBUGS-2"}, {Key: "OCPBUGS-3"}}},
+				})
+				fake.SetCommentBehavior(map[jiraclient.CommentRequest]jiraclient.CommentResponse{
+					{IssueKey: "OCPBUGS-2", Comment: details(regressed)}: {},
+				})
+			},
+		},
+		{
+			name:      "issue that was not commented on within the cooldown is commented on",
+			tests:     []*reportTest{regressed},
+			maxIssues: 5,
+			setup: func(fake *jiraclient.Fake) {
+				fake.SetFindOpenIssuesBehavior(map[jiraclient.FindOpenIssuesRequest]jiraclient.FindOpenIssuesResponse{
+					{Project: "OCPBUGS", Labels: strings.Join(labelsOf(regressed), ",")}: {Issues: []jira.Issue{{Key: "OCPBUGS-2", Fields: &jira.IssueFields{
+						Created:     jira.Time(now.Add(-72 * time.Hour)),
+						Description: "{{regressed}} failed the aggregation of job for payload 4.18.0-0.nightly-older, although",
+						Comments:    commentedAt(now.Add(-48*time.Hour), "still failing"),
+					}}}},
+				})
+				fake.SetCommentBehavior(map[jiraclient.CommentRequest]jiraclient.CommentResponse{
+					{IssueKey: "OCPBUGS-2", Comment: details(regressed)}: {},
+				})
+			},
+		},
+		{
+			name:      "issue commented on within the cooldown is not commented on",
+			tests:     []*reportTest{regressed},
+			maxIssues: 5,
+			setup: func(fake *jiraclient.Fake) {
+				fake.SetFindOpenIssuesBehavior(map[jiraclient.FindOpenIssuesRequest]jiraclient.FindOpenIssuesResponse{
+					{Project: "OCPBUGS", Labels: strings.Join(labelsOf(regressed), ",")}: {Issues: []jira.Issue{{Key: "OCPBUGS-2", Fields: &jira.IssueFields{
+						Created:  jira.Time(now.Add(-72 * time.Hour)),
+						Comments: commentedAt(now.Add(-time.Hour), "still failing"),
+					}}}},
+				})
+			},
+		},
+		{
+			name:      "issue filed within the cooldown is not commented on",
+			tests:     []*reportTest{regressed},
+			maxIssues: 5,
+			setup: func(fake *jiraclient.Fake) {
+				fake.SetFindOpenIssuesBehavior(map[jiraclient.FindOpenIssuesRequest]jiraclient.FindOpenIssuesResponse{
+					{Project: "OCPBUGS", Labels: strings.Join(labelsOf(regressed), ",")}: {Issues: []jira.Issue{{Key: "OCPBUGS-2", Fields: &jira.IssueFields{
+						Created: jira.Time(now.Add(-time.Hour)),
+					}}}},
+				})
+			},
+		},
+		{
+			name:      "issue already told about the payload is not commented on",
+			tests:     []*reportTest{regressed},
+			maxIssues: 5,
+			setup: func(fake *jiraclient.Fake) {
+				fake.SetFindOpenIssuesBehavior(map[jiraclient.FindOpenIssuesRequest]jiraclient.FindOpenIssuesResponse{
+					{Project: "OCPBUGS", Labels: strings.Join(labelsOf(regressed), ",")}: {Issues: []jira.Issue{{Key: "OCPBUGS-2", Fields: &jira.IssueFields{
+						Created:  jira.Time(now.Add(-72 * time.Hour)),
+						Comments: commentedAt(now.Add(-48*time.Hour), details(regressed)),
+					}}}},
+				})
+			},
+		},
+		{
+			name:      "dry run searches but does not write",
+			tests:     []*reportTest{regressed},
+			maxIssues: 5,
+			dryRun:    true,
+			setup: func(fake *jiraclient.Fake) {
+				fake.SetFindOpenIssuesBehavior(map[jiraclient.FindOpenIssuesRequest]jiraclient.FindOpenIssuesResponse{
+					{Project: "OCPBUGS", Labels: strings.Join(labelsOf(regressed), ",")}: {Issues: []jira.Issue{{Key: "OCPBUGS-2"}}},
+				})
+			},
+		},
+		{
+			name:      "dry run without jira",
+			tests:     []*reportTest{regressed},
+			maxIssues: 5,
+			dryRun:    true,
+			noTracker: true,
+		},
+		{
+			name:      "tests that did not regress are skipped",
+			tests:     []*reportTest{regressedTest("flaky"), regressedTest("rarely run"), regressedTest("unknown"), passing},
+			maxIssues: 5,
+		},
+		{
+			name:      "issues are limited",
+			tests:     []*reportTest{regressed, regressedTest("other")},
+			maxIssues: 1,
+			setup: func(fake *jiraclient.Fake) {
+				fake.SetFindOpenIssuesBehavior(map[jiraclient.FindOpenIssuesRequest]jiraclient.FindOpenIssuesResponse{
+					{Project: "OCPBUGS", Labels: strings.Join(labelsOf(regressed), ",")}: {Issues: []jira.Issue{{Key: "OCPBUGS-2"}}},
+				})
+				fake.SetCommentBehavior(map[jiraclient.CommentRequest]jiraclient.CommentResponse{
+					{IssueKey: "OCPBUGS-2", Comment: details(regressed)}: {},
+				})
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fake := jiraclient.NewFake(nil)
+			if tc.setup != nil {
+				tc.setup(fake)
+			}
+			filer := &regressionFiler{
+				tracker:               fake,
+				project:               "OCPBUGS",
+				issueType:             "Bug",
+				minimumPassPercentage: 95,
+				maxIssues:             tc.maxIssues,
+				commentCooldown:       24 * time.Hour,
+				now:                   func() time.Time { return now },
+				dryRun:                tc.dryRun,
+			}
+			if tc.noTracker {
+				filer.tracker = nil
+			}
+			report := &aggregationReport{JobName: "job", PayloadTag: "4.18.0-0.nightly", Tests: tc.tests}
+			if err := filer.fileRegressions(context.Background(), "job", "4.18.0-0.nightly", "https://report", report, rates); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			fake.Validate(t)
+		})
+	}
+}
+
+func TestRegressionDetails(t *testing.T) {
+	details := regressionDetails("job", "4.18.0-0.nightly", "https://report/", regression{test: regressedTest("regressed"), passPercentage: 98.6, historicalRuns: 70, signature: "abc"})
+	for _, expected := range []string{
+		"{{regressed}} failed the aggregation of job for payload 4.18.0-0.nightly, although it passed in 99% of its last 70 runs.",
+		"Report: https://report/tests/regressed.html",
+		"* [1|https://prow/1] failed: {{timed out waiting for pods}}",
+		"Failure signature: abc",
+	} {
+		if !strings.Contains(details, expected) {
+			t.Errorf("expected the details to contain %q, got:\n%s", expected, details)
+		}
+	}
+	if strings.Contains(details, "https://prow/2") {
+		t.Errorf("expected the passing run not to be listed, got:\n%s", details)
+	}
+}
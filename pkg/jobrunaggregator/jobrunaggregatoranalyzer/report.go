@@ -0,0 +1,419 @@
+package jobrunaggregatoranalyzer
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"fmt"
+	"hash/fnv"
+	"html/template"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/openshift/ci-tools/pkg/jobrunaggregator/jobrunaggregatorapi"
+	"github.com/openshift/ci-tools/pkg/jobrunaggregator/jobrunaggregatorlib"
+	"github.com/openshift/ci-tools/pkg/junit"
+	"github.com/openshift/ci-tools/pkg/results"
+)
+
+const (
+	// reportDirName is the directory in the working dir the report bundle is written to, it is uploaded with the
+	// other artifacts of the aggregated job
+	reportDirName = "aggregation-report"
+	// noFailureMessage is shown for the failures without a message
+	noFailureMessage = "<no failure message>"
+)
+
+var (
+	//go:embed aggregation-report-index.gohtml
+	reportIndexTemplateString string
+	//go:embed aggregation-report-test.gohtml
+	reportTestTemplateString string
+
+	reportTemplateFuncs = template.FuncMap{
+		"toLower": strings.ToLower,
+		"percent": func(rate float64) string { return fmt.Sprintf("%.0f%%", rate*100) },
+	}
+	reportIndexTemplate = template.Must(template.New("aggregation_report_index").Funcs(reportTemplateFuncs).Parse(reportIndexTemplateString))
+	reportTestTemplate  = template.Must(template.New("aggregation_report_test").Funcs(reportTemplateFuncs).Parse(reportTestTemplateString))
+
+	unsafeFileNameRegex = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+)
+
+// reportRun is the result of a test in one of the aggregated job runs
+type reportRun struct {
+	JobRunID    string
+	HumanURL    string
+	ArtifactURL string
+	Status      string
+	// Message is the first failure message of the test in the job run
+	Message string
+	// NormalizedMessage is the message with the values that differ between job runs masked
+	NormalizedMessage string
+	// Signature identifies the kind of failure, it is the same signature ci-operator reports for its failures
+	Signature string
+}
+
+// reportSignature groups the failures that share a failure signature
+type reportSignature struct {
+	Signature string
+	// Message is the normalized message the signature is computed from
+	Message string
+	Runs    []reportRun
+	// Tests are the pages of the tests that failed with the signature, only set on the index
+	Tests []*reportTest
+}
+
+// reportDay is the pass rate of a test in the job runs of a day
+type reportDay struct {
+	Day      string
+	Runs     int64
+	PassRate float64
+}
+
+// reportTest is the page of a test that failed in at least one of the aggregated job runs
+type reportTest struct {
+	Name      string
+	SuiteName string
+	FileName  string
+	Status    string
+	Summary   string
+
+	Runs       []reportRun
+	Signatures []*reportSignature
+	History    []reportDay
+	Sparkline  template.HTML
+}
+
+// FailedRuns counts the job runs in which the test failed or flaked
+func (t *reportTest) FailedRuns() int {
+	failed := 0
+	for _, signature := range t.Signatures {
+		failed += len(signature.Runs)
+	}
+	return failed
+}
+
+// aggregationReport is the static bundle of pages that explains the failures of an aggregation
+type aggregationReport struct {
+	JobName     string
+	PayloadTag  string
+	GeneratedAt time.Time
+	JobRuns     []JobRunInfo
+	Tests       []*reportTest
+	Signatures  []*reportSignature
+}
+
+// reportFileName is a file name for the page of a test that is safe in GCS and unique for the test
+func reportFileName(key TestKey) string {
+	hash := fnv.New32a()
+	hash.Write([]byte(key.CombinedTestSuiteName))
+	hash.Write([]byte{0})
+	hash.Write([]byte(key.TestCaseName))
+	name := strings.Trim(unsafeFileNameRegex.ReplaceAllString(key.TestCaseName, "-"), "-")
+	if len(name) > 80 {
+		name = name[:80]
+	}
+	return fmt.Sprintf("%s-%08x.html", name, hash.Sum32())
+}
+
+func failureMessage(testCase *junit.TestCase) string {
+	if testCase.FailureOutput == nil {
+		return ""
+	}
+	if len(strings.TrimSpace(testCase.FailureOutput.Message)) > 0 {
+		return testCase.FailureOutput.Message
+	}
+	return testCase.FailureOutput.Output
+}
+
+// collectJobRunResults records the result of every test of one job run. A test that failed and passed in the same
+// job run flaked.
+func collectJobRunResults(testRuns map[TestKey][]reportRun, jobRun jobrunaggregatorapi.JobRunInfo, testSuites *junit.TestSuites) {
+	runResults := map[TestKey]*reportRun{}
+	var collect func(parentSuiteNames []string, suite *junit.TestSuite)
+	collect = func(parentSuiteNames []string, suite *junit.TestSuite) {
+		currSuiteNames := append(append([]string{}, parentSuiteNames...), suite.Name)
+		combinedSuiteName := strings.Join(currSuiteNames, jobrunaggregatorlib.TestSuitesSeparator)
+		for _, testCase := range suite.TestCases {
+			key := TestKey{TestCaseName: testCase.Name, CombinedTestSuiteName: combinedSuiteName}
+			result, ok := runResults[key]
+			if !ok {
+				result = &reportRun{
+					JobRunID:    jobRun.GetJobRunID(),
+					HumanURL:    jobRun.GetHumanURL(),
+					ArtifactURL: jobRun.GetGCSArtifactURL(),
+				}
+				runResults[key] = result
+			}
+			switch {
+			case testCase.FailureOutput != nil:
+				if result.Status == jobrunaggregatorapi.TestStatusPassed {
+					result.Status = jobrunaggregatorapi.TestStatusFlaked
+				} else if result.Status != jobrunaggregatorapi.TestStatusFlaked {
+					result.Status = jobrunaggregatorapi.TestStatusFailed
+				}
+				if len(result.Message) == 0 {
+					result.Message = failureMessage(testCase)
+					result.NormalizedMessage = results.NormalizeMessage(result.Message)
+					result.Signature = results.Signature(result.Message)
+				}
+			case testCase.SkipMessage != nil:
+				if len(result.Status) == 0 {
+					result.Status = "Skipped"
+				}
+			default:
+				if result.Status == jobrunaggregatorapi.TestStatusFailed {
+					result.Status = jobrunaggregatorapi.TestStatusFlaked
+				} else if result.Status != jobrunaggregatorapi.TestStatusFlaked {
+					result.Status = jobrunaggregatorapi.TestStatusPassed
+				}
+			}
+		}
+		for _, child := range suite.Children {
+			collect(currSuiteNames, child)
+		}
+	}
+	for _, suite := range testSuites.Suites {
+		collect([]string{}, suite)
+	}
+	for key, result := range runResults {
+		testRuns[key] = append(testRuns[key], *result)
+	}
+}
+
+// aggregatedTestStatuses reads the status and summary the pass/fail calculation assigned to every test
+func aggregatedTestStatuses(combined *junit.TestSuites) map[TestKey]*reportTest {
+	tests := map[TestKey]*reportTest{}
+	var collect func(parentSuiteNames []string, suite *junit.TestSuite)
+	collect = func(parentSuiteNames []string, suite *junit.TestSuite) {
+		currSuiteNames := append(append([]string{}, parentSuiteNames...), suite.Name)
+		combinedSuiteName := strings.Join(currSuiteNames, jobrunaggregatorlib.TestSuitesSeparator)
+		for _, testCase := range suite.TestCases {
+			test := &reportTest{Name: testCase.Name, SuiteName: combinedSuiteName, Status: "Passed"}
+			switch {
+			case testCase.SkipMessage != nil:
+				test.Status = "Skipped"
+			case isFailed(testCase):
+				test.Status = "Failed"
+			}
+			details := &jobrunaggregatorlib.TestCaseDetails{}
+			if err := yaml.Unmarshal([]byte(testCase.SystemOut), details); err == nil {
+				test.Summary = details.Summary
+			}
+			tests[TestKey{TestCaseName: testCase.Name, CombinedTestSuiteName: combinedSuiteName}] = test
+		}
+		for _, child := range suite.Children {
+			collect(currSuiteNames, child)
+		}
+	}
+	for _, suite := range combined.Suites {
+		collect([]string{}, suite)
+	}
+	return tests
+}
+
+// groupBySignature groups the failed and flaked runs by their signature, the most common signature first
+func groupBySignature(runs []reportRun) []*reportSignature {
+	bySignature := map[string]*reportSignature{}
+	for _, run := range runs {
+		if run.Status != jobrunaggregatorapi.TestStatusFailed && run.Status != jobrunaggregatorapi.TestStatusFlaked {
+			continue
+		}
+		if _, ok := bySignature[run.Signature]; !ok {
+			message := run.NormalizedMessage
+			if len(message) == 0 {
+				message = noFailureMessage
+			}
+			bySignature[run.Signature] = &reportSignature{Signature: run.Signature, Message: message}
+		}
+		bySignature[run.Signature].Runs = append(bySignature[run.Signature].Runs, run)
+	}
+	signatures := make([]*reportSignature, 0, len(bySignature))
+	for _, signature := range bySignature {
+		signatures = append(signatures, signature)
+	}
+	sortSignatures(signatures)
+	return signatures
+}
+
+func sortSignatures(signatures []*reportSignature) {
+	sort.Slice(signatures, func(i, j int) bool {
+		if len(signatures[i].Runs) != len(signatures[j].Runs) {
+			return len(signatures[i].Runs) > len(signatures[j].Runs)
+		}
+		return signatures[i].Message < signatures[j].Message
+	})
+}
+
+// sparklineSVG draws the daily pass rates as an inline SVG, days without runs are left out
+func sparklineSVG(history []reportDay) template.HTML {
+	const width, height, margin = 120.0, 24.0, 2.0
+	if len(history) == 0 {
+		return ""
+	}
+	step := 0.0
+	if len(history) > 1 {
+		step = (width - 2*margin) / float64(len(history)-1)
+	}
+	points := make([]string, 0, len(history))
+	for i, day := range history {
+		x := margin + float64(i)*step
+		y := margin + (1-day.PassRate)*(height-2*margin)
+		points = append(points, fmt.Sprintf("%.1f,%.1f", x, y))
+	}
+	last := history[len(history)-1]
+	svg := fmt.Sprintf(`<svg class="sparkline" width="%.0f" height="%.0f" viewBox="0 0 %.0f %.0f"><title>%.0f%% on %s</title>`+
+		`<polyline fill="none" stroke="#36c" stroke-width="1.5" points="%s"/></svg>`,
+		width, height, width, height, last.PassRate*100, last.Day, strings.Join(points, " "))
+	// every value in the markup is formatted above, none of it comes from the job runs
+	return template.HTML(svg)
+}
+
+// testHistory turns the daily counts of the test into pass rates, flakes count as passes like they do for the job
+func testHistory(rows []jobrunaggregatorapi.TestPassRateByDayRow) map[TestKey][]reportDay {
+	history := map[TestKey][]reportDay{}
+	for _, row := range rows {
+		runs := row.PassCount + row.FailCount + row.FlakeCount
+		if runs == 0 {
+			continue
+		}
+		key := TestKey{TestCaseName: row.TestName, CombinedTestSuiteName: row.TestSuite}
+		history[key] = append(history[key], reportDay{
+			Day:      row.Day.String(),
+			Runs:     runs,
+			PassRate: float64(row.PassCount+row.FlakeCount) / float64(runs),
+		})
+	}
+	for key := range history {
+		days := history[key]
+		sort.SliceStable(days, func(i, j int) bool { return days[i].Day < days[j].Day })
+	}
+	return history
+}
+
+// buildAggregationReport builds the pages of the tests that failed or flaked in any of the aggregated job runs
+func buildAggregationReport(jobName, payloadTag string, now time.Time, combined *junit.TestSuites, jobRunJunits []*jobRunJunit, jobRuns []JobRunInfo, historyRows []jobrunaggregatorapi.TestPassRateByDayRow) *aggregationReport {
+	runResults := map[TestKey][]reportRun{}
+	for _, currJobRunJunit := range jobRunJunits {
+		collectJobRunResults(runResults, currJobRunJunit.jobRun, currJobRunJunit.combinedJunit)
+	}
+	aggregated := aggregatedTestStatuses(combined)
+	history := testHistory(historyRows)
+
+	report := &aggregationReport{
+		JobName:     jobName,
+		PayloadTag:  payloadTag,
+		GeneratedAt: now,
+		JobRuns:     jobRuns,
+	}
+	signaturesByName := map[string]*reportSignature{}
+	for key, runs := range runResults {
+		signatures := groupBySignature(runs)
+		if len(signatures) == 0 {
+			continue
+		}
+		sort.Slice(runs, func(i, j int) bool { return runs[i].JobRunID < runs[j].JobRunID })
+		test, ok := aggregated[key]
+		if !ok {
+			test = &reportTest{Name: key.TestCaseName, SuiteName: key.CombinedTestSuiteName}
+		}
+		test.FileName = reportFileName(key)
+		test.Runs = runs
+		test.Signatures = signatures
+		test.History = history[key]
+		test.Sparkline = sparklineSVG(test.History)
+		report.Tests = append(report.Tests, test)
+
+		for _, signature := range signatures {
+			if _, ok := signaturesByName[signature.Signature]; !ok {
+				signaturesByName[signature.Signature] = &reportSignature{Signature: signature.Signature, Message: signature.Message}
+			}
+			signaturesByName[signature.Signature].Runs = append(signaturesByName[signature.Signature].Runs, signature.Runs...)
+			signaturesByName[signature.Signature].Tests = append(signaturesByName[signature.Signature].Tests, test)
+		}
+	}
+
+	// the tests that failed the aggregation come first
+	sort.Slice(report.Tests, func(i, j int) bool {
+		if (report.Tests[i].Status == "Failed") != (report.Tests[j].Status == "Failed") {
+			return report.Tests[i].Status == "Failed"
+		}
+		if report.Tests[i].SuiteName != report.Tests[j].SuiteName {
+			return report.Tests[i].SuiteName < report.Tests[j].SuiteName
+		}
+		return report.Tests[i].Name < report.Tests[j].Name
+	})
+	for _, signature := range signaturesByName {
+		sort.Slice(signature.Tests, func(i, j int) bool { return signature.Tests[i].FileName < signature.Tests[j].FileName })
+		report.Signatures = append(report.Signatures, signature)
+	}
+	sortSignatures(report.Signatures)
+	return report
+}
+
+// writeAggregationReport writes the index and a page for every test to the directory
+func writeAggregationReport(report *aggregationReport, dir string) error {
+	testDir := filepath.Join(dir, "tests")
+	if err := os.MkdirAll(testDir, 0755); err != nil {
+		return fmt.Errorf("error creating report directory %q: %w", testDir, err)
+	}
+
+	buff := bytes.Buffer{}
+	if err := reportIndexTemplate.Execute(&buff, report); err != nil {
+		return fmt.Errorf("error rendering report index: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "index.html"), buff.Bytes(), 0644); err != nil {
+		return err
+	}
+
+	for _, test := range report.Tests {
+		buff.Reset()
+		data := struct {
+			JobName    string
+			PayloadTag string
+			Test       *reportTest
+		}{
+			JobName:    report.JobName,
+			PayloadTag: report.PayloadTag,
+			Test:       test,
+		}
+		if err := reportTestTemplate.Execute(&buff, data); err != nil {
+			return fmt.Errorf("error rendering report for %q: %w", test.Name, err)
+		}
+		if err := os.WriteFile(filepath.Join(testDir, test.FileName), buff.Bytes(), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeReport builds and writes the report bundle of the aggregation. The history is optional, the pages are still
+// useful without the sparklines when it cannot be read. The report is returned even when it could not be written.
+func (o *JobRunAggregatorAnalyzerOptions) writeReport(ctx context.Context, combined *junit.TestSuites, currentAggregationJunit *aggregatedJobRunJunit, jobRuns []JobRunInfo) (*aggregationReport, error) {
+	var historyRows []jobrunaggregatorapi.TestPassRateByDayRow
+	if o.reportHistoryDays > 0 {
+		since := o.clock.Now().Add(-time.Duration(o.reportHistoryDays) * 24 * time.Hour)
+		var err error
+		historyRows, err = o.ciDataClient.ListTestPassRatesByDayForJob(ctx, o.jobName, since)
+		if err != nil {
+			logrus.WithError(err).Warn("failed to list test pass rates, the report will not have history")
+		}
+	}
+
+	var jobRunJunits []*jobRunJunit
+	for _, aggregationName := range sets.StringKeySet(currentAggregationJunit.aggregationNameToJobRuns).List() {
+		jobRunJunits = append(jobRunJunits, currentAggregationJunit.aggregationNameToJobRuns[aggregationName]...)
+	}
+	report := buildAggregationReport(o.jobName, o.payloadTag, o.clock.Now(), combined, jobRunJunits, jobRuns, historyRows)
+	return report, writeAggregationReport(report, filepath.Join(o.workingDir, reportDirName))
+}
@@ -0,0 +1,133 @@
+package jobrunaggregatoranalyzer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/civil"
+	"github.com/google/go-cmp/cmp"
+	"go.uber.org/mock/gomock"
+
+	"github.com/openshift/ci-tools/pkg/jobrunaggregator/jobrunaggregatorapi"
+	"github.com/openshift/ci-tools/pkg/junit"
+	"github.com/openshift/ci-tools/pkg/results"
+)
+
+func newReportTestJobRun(mockCtrl *gomock.Controller, id string, suites ...*junit.TestSuite) *jobRunJunit {
+	jobRun := jobrunaggregatorapi.NewMockJobRunInfo(mockCtrl)
+	jobRun.EXPECT().GetJobRunID().Return(id).AnyTimes()
+	jobRun.EXPECT().GetHumanURL().Return("https://prow/view/" + id).AnyTimes()
+	jobRun.EXPECT().GetGCSArtifactURL().Return("https://gcs/" + id).AnyTimes()
+	return &jobRunJunit{jobRun: jobRun, combinedJunit: &junit.TestSuites{Suites: suites}}
+}
+
+func TestBuildAggregationReport(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	failure := func(message string) *junit.FailureOutput { return &junit.FailureOutput{Message: message} }
+	jobRunJunits := []*jobRunJunit{
+		newReportTestJobRun(mockCtrl, "1", &junit.TestSuite{Name: "openshift-tests", TestCases: []*junit.TestCase{
+			{Name: "broken", FailureOutput: failure("timed out after 30s waiting for pod-1")},
+			{Name: "stable"},
+		}}),
+		newReportTestJobRun(mockCtrl, "2", &junit.TestSuite{Name: "openshift-tests", TestCases: []*junit.TestCase{
+			{Name: "broken", FailureOutput: failure("timed out after 45s waiting for pod-7")},
+			{Name: "stable"},
+		}}),
+		newReportTestJobRun(mockCtrl, "3", &junit.TestSuite{Name: "openshift-tests", TestCases: []*junit.TestCase{
+			{Name: "broken", FailureOutput: failure("connection refused")},
+			{Name: "broken"},
+			{Name: "stable"},
+		}}),
+	}
+	combined := &junit.TestSuites{Suites: []*junit.TestSuite{{Name: "openshift-tests", TestCases: []*junit.TestCase{
+		{Name: "broken", FailureOutput: failure("failed"), SystemOut: "summary: Passed 1 times, failed 2 times.\n"},
+		{Name: "stable"},
+	}}}}
+	history := []jobrunaggregatorapi.TestPassRateByDayRow{
+		{TestSuite: "openshift-tests", TestName: "broken", Day: civil.Date{Year: 2024, Month: 5, Day: 2}, PassCount: 1, FailCount: 1},
+		{TestSuite: "openshift-tests", TestName: "broken", Day: civil.Date{Year: 2024, Month: 5, Day: 1}, PassCount: 3, FlakeCount: 1},
+	}
+
+	report := buildAggregationReport("e2e-aws", "4.16.0-0.nightly", time.Date(2024, 5, 3, 0, 0, 0, 0, time.UTC), combined, jobRunJunits, nil, history)
+	if len(report.Tests) != 1 {
+		t.Fatalf("expected only the failed test to get a page, got %d", len(report.Tests))
+	}
+	test := report.Tests[0]
+	if test.Name != "broken" || test.Status != "Failed" || test.Summary != "Passed 1 times, failed 2 times." {
+		t.Errorf("unexpected test %q with status %q and summary %q", test.Name, test.Status, test.Summary)
+	}
+
+	type signatureRuns struct {
+		Signature string
+		Message   string
+		Runs      []string
+		Statuses  []string
+	}
+	var actual []signatureRuns
+	for _, signature := range test.Signatures {
+		curr := signatureRuns{Signature: signature.Signature, Message: signature.Message}
+		for _, run := range signature.Runs {
+			curr.Runs = append(curr.Runs, run.JobRunID)
+			curr.Statuses = append(curr.Statuses, run.Status)
+		}
+		actual = append(actual, curr)
+	}
+	expected := []signatureRuns{
+		{Signature: results.Signature("timed out after 30s waiting for pod-1"), Message: "timed out after <duration> waiting for pod-<n>", Runs: []string{"1", "2"}, Statuses: []string{"Failed", "Failed"}},
+		{Signature: results.Signature("connection refused"), Message: "connection refused", Runs: []string{"3"}, Statuses: []string{"Flaked"}},
+	}
+	if diff := cmp.Diff(expected, actual); diff != "" {
+		t.Errorf("unexpected signatures (-want +got):\n%s", diff)
+	}
+	if len(report.Signatures) != 2 || len(report.Signatures[0].Tests) != 1 {
+		t.Errorf("expected the index to group the failures by signature, got %#v", report.Signatures)
+	}
+
+	expectedHistory := []reportDay{
+		{Day: "2024-05-01", Runs: 4, PassRate: 1},
+		{Day: "2024-05-02", Runs: 2, PassRate: 0.5},
+	}
+	if diff := cmp.Diff(expectedHistory, test.History); diff != "" {
+		t.Errorf("unexpected history (-want +got):\n%s", diff)
+	}
+	if !strings.HasPrefix(string(test.Sparkline), "<svg") {
+		t.Errorf("expected a sparkline, got %q", test.Sparkline)
+	}
+
+	dir := t.TempDir()
+	if err := writeAggregationReport(report, dir); err != nil {
+		t.Fatalf("failed to write report: %v", err)
+	}
+	index, err := os.ReadFile(filepath.Join(dir, "index.html"))
+	if err != nil {
+		t.Fatalf("failed to read index: %v", err)
+	}
+	if !strings.Contains(string(index), "tests/"+test.FileName) {
+		t.Errorf("expected the index to link to the page of the test")
+	}
+	page, err := os.ReadFile(filepath.Join(dir, "tests", test.FileName))
+	if err != nil {
+		t.Fatalf("failed to read test page: %v", err)
+	}
+	for _, expected := range []string{"https://prow/view/1", "https://gcs/3", "timed out after 45s waiting for pod-7"} {
+		if !strings.Contains(string(page), expected) {
+			t.Errorf("expected the test page to contain %q", expected)
+		}
+	}
+}
+
+func TestReportFileName(t *testing.T) {
+	first := reportFileName(TestKey{TestCaseName: "[sig-network] pods should work", CombinedTestSuiteName: "openshift-tests"})
+	second := reportFileName(TestKey{TestCaseName: "[sig-network] pods should work", CombinedTestSuiteName: "openshift-tests-upgrade"})
+	if !strings.HasPrefix(first, "sig-network-pods-should-work-") || !strings.HasSuffix(first, ".html") {
+		t.Errorf("unexpected file name %q", first)
+	}
+	if first == second {
+		t.Errorf("expected the same test in different suites to get different pages, both got %q", first)
+	}
+}
@@ -144,15 +144,18 @@ var (
 )
 
 // if someone has the HTML skills, making this a mini-test grid would be awesome.
-func htmlForTestRuns(jobName string, suite *junit.TestSuite) (string, error) {
+// reportURL is the optional location of the aggregation report bundle, the page links to it when set.
+func htmlForTestRuns(jobName string, suite *junit.TestSuite, reportURL string) (string, error) {
 	data := struct {
 		JobName        string
 		Suite          *junit.TestSuite
 		InitialParents []string
+		ReportURL      string
 	}{
 		JobName:        jobName,
 		Suite:          suite,
 		InitialParents: []string{},
+		ReportURL:      reportURL,
 	}
 	buff := bytes.Buffer{}
 	err := htmlTemplate.Execute(&buff, data)
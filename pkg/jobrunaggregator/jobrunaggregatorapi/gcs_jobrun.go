@@ -112,31 +112,21 @@ func (j *gcsJobRun) writeCache(ctx context.Context, parentDir string) error {
 		}
 	}
 
-	testSuites := &junit.TestSuites{}
+	var allTestSuites []*junit.TestSuites
 	for _, junitFile := range j.GetGCSJunitPaths() {
 		junitContent, err := j.GetContent(ctx, junitFile)
 		if err != nil {
 			return fmt.Errorf("error getting content for %q %q: %w", j.GetJobRunID(), junitFile, err)
 		}
-
-		// try as testsuites first just in case we are one
-		currTestSuites := &junit.TestSuites{}
-		testSuitesErr := xml.Unmarshal(junitContent, currTestSuites)
-		if testSuitesErr == nil {
-			// if this a test suites, add them here
-			testSuites.Suites = append(testSuites.Suites, currTestSuites.Suites...)
-			continue
-		}
-
-		currTestSuite := &junit.TestSuite{}
-		if testSuiteErr := xml.Unmarshal(junitContent, currTestSuite); testSuiteErr != nil {
-			return fmt.Errorf("error parsing junit for %q %q: %w", j.GetJobRunID(), junitFile, testSuiteErr)
+		currTestSuites, err := junit.Parse(junitContent)
+		if err != nil {
+			return fmt.Errorf("error parsing junit for %q %q: %w", j.GetJobRunID(), junitFile, err)
 		}
-		testSuites.Suites = append(testSuites.Suites, currTestSuite)
+		allTestSuites = append(allTestSuites, currTestSuites)
 	}
 
 	// write aggregated junit as well.
-	combinedJunitContent, err := xml.Marshal(testSuites)
+	combinedJunitContent, err := xml.Marshal(junit.Merge(allTestSuites...))
 	if err != nil {
 		return err
 	}
@@ -214,53 +204,23 @@ func (j *gcsJobRun) GetCombinedJUnitTestSuites(ctx context.Context) (*junit.Test
 		return nil, err
 	}
 
-	testSuites := &junit.TestSuites{}
+	var allTestSuites []*junit.TestSuites
 	for _, junitFile := range j.GetGCSJunitPaths() {
 		logrus.Debug("getting junit file content content from GCS")
 		junitContent, err := j.GetContent(ctx, junitFile)
 		if err != nil {
 			return nil, fmt.Errorf("error getting content for jobrun/%v/%v %q: %w", j.GetJobName(), j.GetJobRunID(), junitFile, err)
 		}
-		// if the file was retrieve, but the content was empty, there is no work to be done.
-		if len(junitContent) == 0 {
-			continue
-		}
-
-		// try as testsuites first just in case we are one
-		currTestSuites := &junit.TestSuites{}
-		testSuitesErr := xml.Unmarshal(junitContent, currTestSuites)
-		if testSuitesErr == nil {
-			// if this a test suites, add them here
-			testSuites.Suites = append(testSuites.Suites, currTestSuites.Suites...)
-			continue
-		}
-		if isParseFloatError(testSuitesErr) {
-			// this was a testsuites, but we cannot read the file.  There is no choice to ignore errors so we suppress here
-			fmt.Fprintf(os.Stderr, "error parsing testsuites: %v", testSuitesErr)
-			continue
-		}
-
-		currTestSuite := &junit.TestSuite{}
-		if testSuiteErr := xml.Unmarshal(junitContent, currTestSuite); testSuiteErr != nil {
+		currTestSuites, err := junit.Parse(junitContent)
+		if err != nil {
 			// If we get an error reading from just one of the junits, don't end the world, just log it.
-			fmt.Printf("error parsing junit for jobrun/%v/%v %q: testsuiteError=%v  testsuitesError=%v",
-				j.GetJobName(), j.GetJobRunID(), junitFile, testSuiteErr.Error(), testSuitesErr.Error())
+			fmt.Fprintf(os.Stderr, "error parsing junit for jobrun/%v/%v %q: %v\n", j.GetJobName(), j.GetJobRunID(), junitFile, err)
 			continue
 		}
-		testSuites.Suites = append(testSuites.Suites, currTestSuite)
+		allTestSuites = append(allTestSuites, currTestSuites)
 	}
 
-	return testSuites, nil
-}
-
-func isParseFloatError(err error) bool {
-	if err == nil {
-		return false
-	}
-	if strings.HasPrefix(err.Error(), "strconv.ParseFloat:") {
-		return true
-	}
-	return false
+	return junit.Merge(allTestSuites...), nil
 }
 
 func (j *gcsJobRun) GetOpenShiftTestsFilesWithPrefix(ctx context.Context, prefix string) (map[string]string, error) {
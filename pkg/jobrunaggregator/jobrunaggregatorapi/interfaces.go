@@ -34,6 +34,10 @@ type JobRunInfo interface {
 
 	GetProwJob(ctx context.Context) (*prowjobv1.ProwJob, error)
 	GetJobRunFromGCS(ctx context.Context) error
+	// GetCombinedJUnitTestSuites reads all junit files of the job run and merges them with junit.Merge: suites
+	// with the same name in different files become one suite that holds the test cases of all of them. Every
+	// consumer identifies tests by their suite path and name, so this gives the same results as listing the
+	// suites of every file, with the counts of a suite summed instead of spread over duplicates.
 	GetCombinedJUnitTestSuites(ctx context.Context) (*junit.TestSuites, error)
 	// GetOpenShiftTestsFilesWithPrefix checks the datasource for "openshift-e2e-test/artifacts/junit/<prefix>*"
 	// and returns that content indexed by local filename.  This is useful for things like back-disruption and alerts.
@@ -0,0 +1,227 @@
+package jobrunaggregatorapi
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	prowjobv1 "sigs.k8s.io/prow/pkg/apis/prowjobs/v1"
+
+	"github.com/openshift/ci-tools/pkg/junit"
+)
+
+// CombinedJUnitFileName is the file WriteCache stores the combined junit of a job run in
+const CombinedJUnitFileName = "junit-combined-testsuites.xml"
+
+// localJobRun reads a job run from a local mirror of the GCS bucket, as written by WriteCache.
+// Paths are the same as in the bucket, relative to the mirror directory.
+type localJobRun struct {
+	mirrorDir string
+
+	jobRunGCSBucketRoot string
+	jobName             string
+	jobRunID            string
+	gcsProwJobPath      string
+	gcsJunitPaths       []string
+	gcsFileNames        []string
+
+	jobRunGCSBucket string
+}
+
+// NewLocalJobRun returns the job run that was mirrored into mirrorDir. jobGCSBucketRoot is the location
+// of the job in the bucket, like logs/<job name>.
+func NewLocalJobRun(mirrorDir, jobGCSBucketRoot, jobName, jobRunID, jobRunGCSBucket string) JobRunInfo {
+	return &localJobRun{
+		mirrorDir:           mirrorDir,
+		jobRunGCSBucketRoot: path.Join(jobGCSBucketRoot, jobRunID),
+		jobName:             jobName,
+		jobRunID:            jobRunID,
+		jobRunGCSBucket:     jobRunGCSBucket,
+	}
+}
+
+func (j *localJobRun) GetJobName() string {
+	return j.jobName
+}
+func (j *localJobRun) GetJobRunID() string {
+	return j.jobRunID
+}
+func (j *localJobRun) GetGCSProwJobPath() string {
+	return j.gcsProwJobPath
+}
+func (j *localJobRun) GetGCSJunitPaths() []string {
+	return j.gcsJunitPaths
+}
+func (j *localJobRun) SetGCSProwJobPath(gcsProwJobPath string) {
+	j.gcsProwJobPath = gcsProwJobPath
+}
+func (j *localJobRun) AddGCSJunitPaths(junitPaths ...string) {
+	j.gcsJunitPaths = append(j.gcsJunitPaths, junitPaths...)
+}
+func (j *localJobRun) AddGCSProwJobFileNames(fileNames ...string) {
+	j.gcsFileNames = append(j.gcsFileNames, fileNames...)
+}
+
+func (j *localJobRun) localPath(gcsPath string) string {
+	return filepath.Join(j.mirrorDir, filepath.FromSlash(gcsPath))
+}
+
+// GetJobRunFromGCS lists the mirrored files of the job run
+func (j *localJobRun) GetJobRunFromGCS(ctx context.Context) error {
+	root := j.localPath(j.jobRunGCSBucketRoot)
+	return filepath.WalkDir(root, func(localPath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		relative, err := filepath.Rel(j.mirrorDir, localPath)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(relative)
+		j.AddGCSProwJobFileNames(name)
+		// the combined junit is written by WriteCache from the other junit files
+		if strings.HasSuffix(name, ".xml") && strings.Contains(name, "/junit") && path.Base(name) != CombinedJUnitFileName {
+			j.AddGCSJunitPaths(name)
+		}
+		return nil
+	})
+}
+
+func (j *localJobRun) validateJobRunFromGCS(ctx context.Context) error {
+	if nil == j.gcsFileNames {
+		return j.GetJobRunFromGCS(ctx)
+	}
+	return nil
+}
+
+func (j *localJobRun) GetCombinedJUnitTestSuites(ctx context.Context) (*junit.TestSuites, error) {
+	if err := j.validateJobRunFromGCS(ctx); err != nil {
+		return nil, err
+	}
+
+	if content, err := j.GetContent(ctx, path.Join(j.jobRunGCSBucketRoot, CombinedJUnitFileName)); err == nil {
+		testSuites := &junit.TestSuites{}
+		if err := xml.Unmarshal(content, testSuites); err != nil {
+			return nil, fmt.Errorf("error parsing combined junit for jobrun/%v/%v: %w", j.GetJobName(), j.GetJobRunID(), err)
+		}
+		return testSuites, nil
+	}
+
+	var allTestSuites []*junit.TestSuites
+	for _, junitFile := range j.GetGCSJunitPaths() {
+		junitContent, err := j.GetContent(ctx, junitFile)
+		if err != nil {
+			return nil, err
+		}
+		currTestSuites, err := junit.Parse(junitContent)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing junit for jobrun/%v/%v %q: %w", j.GetJobName(), j.GetJobRunID(), junitFile, err)
+		}
+		allTestSuites = append(allTestSuites, currTestSuites)
+	}
+	return junit.Merge(allTestSuites...), nil
+}
+
+func (j *localJobRun) GetOpenShiftTestsFilesWithPrefix(ctx context.Context, prefix string) (map[string]string, error) {
+	if err := j.validateJobRunFromGCS(ctx); err != nil {
+		return nil, err
+	}
+
+	regex, err := regexp.Compile("/" + prefix + "[^/]*")
+	if err != nil {
+		return nil, err
+	}
+	ret := map[string]string{}
+	for _, name := range j.gcsFileNames {
+		if !regex.MatchString(name) {
+			continue
+		}
+		content, err := j.GetContent(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		ret[name] = string(content)
+	}
+	return ret, nil
+}
+
+func (j *localJobRun) GetProwJob(ctx context.Context) (*prowjobv1.ProwJob, error) {
+	if len(j.gcsProwJobPath) == 0 {
+		return nil, fmt.Errorf("missing prowjob path to mirrored content for jobrun/%v/%v", j.GetJobName(), j.GetJobRunID())
+	}
+	prowBytes, err := j.GetContent(ctx, j.gcsProwJobPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		// WriteCache stores the prowjob as yaml next to the original
+		prowBytes, err = j.GetContent(ctx, path.Join(path.Dir(j.gcsProwJobPath), "prowjob.yaml"))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ParseProwJob(prowBytes)
+}
+
+func (j *localJobRun) GetContent(ctx context.Context, path string) ([]byte, error) {
+	if len(path) == 0 {
+		return nil, fmt.Errorf("missing path to mirrored content for jobrun/%v/%v", j.GetJobName(), j.GetJobRunID())
+	}
+	content, err := os.ReadFile(j.localPath(path))
+	if err != nil {
+		return nil, fmt.Errorf("error reading mirrored content for jobrun/%v/%v at %q: %w", j.GetJobName(), j.GetJobRunID(), path, err)
+	}
+	return content, nil
+}
+
+// ClearAllContent is a no-op, the content is read from disk every time
+func (j *localJobRun) ClearAllContent() {}
+
+// WriteCache copies the mirrored files of the job run into parentDir
+func (j *localJobRun) WriteCache(ctx context.Context, parentDir string) error {
+	if err := j.validateJobRunFromGCS(ctx); err != nil {
+		return err
+	}
+	for _, name := range j.gcsFileNames {
+		content, err := j.GetContent(ctx, name)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(parentDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return fmt.Errorf("error making directory for %q: %w", j.GetJobRunID(), err)
+		}
+		if err := os.WriteFile(target, content, 0644); err != nil {
+			return fmt.Errorf("error writing file for %q %q: %w", j.GetJobRunID(), target, err)
+		}
+	}
+	return nil
+}
+
+func (j *localJobRun) GetHumanURL() string {
+	return GetHumanURLForLocation(j.jobRunGCSBucketRoot, j.jobRunGCSBucket)
+}
+
+func (j *localJobRun) GetGCSArtifactURL() string {
+	return GetGCSArtifactURLForLocation(j.jobRunGCSBucketRoot, j.jobRunGCSBucket)
+}
+
+// IsFinished checks for finished.json, which WriteCache does not mirror, and falls back to the completion
+// time of the prowjob
+func (j *localJobRun) IsFinished(ctx context.Context) bool {
+	if content, err := j.GetContent(ctx, path.Join(j.jobRunGCSBucketRoot, "finished.json")); err == nil && len(content) > 0 {
+		return true
+	}
+	prowJob, err := j.GetProwJob(ctx)
+	if err != nil {
+		return false
+	}
+	return prowJob.Status.CompletionTime != nil
+}
@@ -0,0 +1,59 @@
+package jobrunaggregatorapi
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/openshift/ci-tools/pkg/junit"
+)
+
+func TestLocalJobRunGetCombinedJUnitTestSuites(t *testing.T) {
+	mirrorDir := t.TempDir()
+	artifacts := filepath.Join(mirrorDir, "logs", "job", "1", "artifacts")
+	if err := os.MkdirAll(artifacts, 0755); err != nil {
+		t.Fatalf("failed to create artifacts directory: %v", err)
+	}
+	for name, content := range map[string]string{
+		"junit_e2e_1.xml": `<testsuite name="e2e" tests="2" failures="1"><testcase name="a"><failure message="failed"></failure></testcase><testcase name="b"></testcase></testsuite>`,
+		"junit_e2e_2.xml": `<testsuites><testsuite name="e2e" tests="1"><testcase name="a"></testcase></testsuite><testsuite name="upgrade" tests="1"><testcase name="c"></testcase></testsuite></testsuites>`,
+	} {
+		if err := os.WriteFile(filepath.Join(artifacts, name), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+
+	jobRun := NewLocalJobRun(mirrorDir, "logs/job", "job", "1", "bucket")
+	suites, err := jobRun.GetCombinedJUnitTestSuites(context.Background())
+	if err != nil {
+		t.Fatalf("failed to read junit: %v", err)
+	}
+	type suiteSummary struct {
+		Name      string
+		NumTests  uint
+		NumFailed uint
+		Tests     []string
+	}
+	var actual []suiteSummary
+	for _, suite := range suites.Suites {
+		summary := suiteSummary{Name: suite.Name, NumTests: suite.NumTests, NumFailed: suite.NumFailed}
+		for _, testCase := range suite.TestCases {
+			summary.Tests = append(summary.Tests, testCase.Name)
+		}
+		actual = append(actual, summary)
+	}
+	// the e2e suites of both files are merged into one
+	expected := []suiteSummary{
+		{Name: "e2e", NumTests: 3, NumFailed: 1, Tests: []string{"a", "b", "a"}},
+		{Name: "upgrade", NumTests: 1, Tests: []string{"c"}},
+	}
+	if diff := cmp.Diff(expected, actual); diff != "" {
+		t.Errorf("unexpected suites (-want +got):\n%s", diff)
+	}
+	if results := junit.Results(suites); results[junit.TestID{Suite: "e2e", Name: "a"}] != junit.TestResultFlaked {
+		t.Errorf("expected the test that failed in one file and passed in the other to flake, got %v", results)
+	}
+}
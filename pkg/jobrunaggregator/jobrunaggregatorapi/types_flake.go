@@ -0,0 +1,52 @@
+package jobrunaggregatorapi
+
+import (
+	"time"
+)
+
+const (
+	// FlakeClassificationFlaky tests fail and pass often enough to be sure they are not stable
+	FlakeClassificationFlaky = "flaky"
+	// FlakeClassificationBroken tests fail so often that it is a regression rather than a flake
+	FlakeClassificationBroken = "broken"
+	// FlakeClassificationStable tests fail rarely enough to be noise
+	FlakeClassificationStable = "stable"
+	// FlakeClassificationInsufficientData tests did not run often enough to tell
+	FlakeClassificationInsufficientData = "insufficient-data"
+
+	// FlakeSignalPassAfterRetry is set when most failures passed when the test was retried in the same job run
+	FlakeSignalPassAfterRetry = "pass-after-retry"
+	// FlakeSignalBimodalTiming is set when the durations of the test cluster around two values
+	FlakeSignalBimodalTiming = "bimodal-timing"
+)
+
+// FlakeReport is the result of the flake analysis. It is written as JSON for Sippy and read back by
+// analyze-job-runs to skip quarantined tests.
+type FlakeReport struct {
+	GeneratedAt time.Time        `json:"generatedAt"`
+	Tests       []TestFlakeScore `json:"tests"`
+}
+
+// TestFlakeScore is the flake score of a test in a job, the reports are ranked by Score
+type TestFlakeScore struct {
+	JobName       string `json:"jobName"`
+	TestName      string `json:"testName"`
+	TestSuiteName string `json:"testSuiteName,omitempty"`
+
+	Runs       int `json:"runs"`
+	PassCount  int `json:"passCount"`
+	FailCount  int `json:"failCount"`
+	FlakeCount int `json:"flakeCount"`
+
+	// InstabilityLowerBound and InstabilityUpperBound are the Wilson interval of the share of runs the test
+	// failed in, including the runs it passed on retry.
+	InstabilityLowerBound float64 `json:"instabilityLowerBound"`
+	InstabilityUpperBound float64 `json:"instabilityUpperBound"`
+	// BimodalityCoefficient is only set when the durations of the test are known
+	BimodalityCoefficient float64 `json:"bimodalityCoefficient,omitempty"`
+
+	Score          float64  `json:"score"`
+	Classification string   `json:"classification"`
+	Signals        []string `json:"signals,omitempty"`
+	Quarantine     bool     `json:"quarantine"`
+}
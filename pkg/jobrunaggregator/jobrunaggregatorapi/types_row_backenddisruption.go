@@ -1,6 +1,8 @@
 package jobrunaggregatorapi
 
 import (
+	"time"
+
 	"cloud.google.com/go/bigquery"
 )
 
@@ -18,3 +20,13 @@ type BackendDisruptionRow struct {
 	MasterNodesUpdated bigquery.NullString
 	JobRunStatus       bigquery.NullString
 }
+
+// BackendDisruptionByReleaseTagRow is the disruption of a backend in the job runs of a job that tested one payload
+type BackendDisruptionByReleaseTagRow struct {
+	BackendName string
+	ReleaseTag  string
+	// FirstJobRunStartTime orders the payloads when they are not in the ReleaseTags table
+	FirstJobRunStartTime  time.Time
+	JobRuns               int64
+	MeanDisruptionSeconds float64
+}
@@ -20,3 +20,14 @@ type TestSummaryByPeriodRow struct {
 	FirstSampleDate   civil.Date `bigquery:"first_sample_date"` // First date this test_name was seen within the sample period across all releases for this suite
 	HighCPUCount      int64      `bigquery:"high_cpu_count"`    // Number of times this test appeared in high_cpu_e2e_tests during the period
 }
+
+// TestPassRateByDayRow counts the results of a test in the job runs of a job that started on one day
+type TestPassRateByDayRow struct {
+	// TestSuite is the name of the test suite, nested suites are joined with the TestSuitesSeparator
+	TestSuite  string
+	TestName   string
+	Day        civil.Date
+	PassCount  int64
+	FailCount  int64
+	FlakeCount int64
+}
@@ -0,0 +1,28 @@
+package jobrunaggregatorapi
+
+import (
+	"time"
+)
+
+const (
+	TestRunsTableName = "TestRuns"
+
+	TestStatusPassed = "Passed"
+	TestStatusFailed = "Failed"
+	TestStatusFlaked = "Flaked"
+)
+
+// TestRunRow is the result of a single test in a job run. Tests that ran several times in the
+// same job run and both passed and failed are recorded once as flaked.
+type TestRunRow struct {
+	// TestSuite is the name of the test suite, nested suites are joined with the TestSuitesSeparator
+	TestSuite       string
+	TestName        string
+	TestStatus      string
+	DurationMs      int64
+	JobName         string
+	JobRunName      string
+	JobRunStartTime time.Time
+	Cluster         string
+	ReleaseTag      string
+}
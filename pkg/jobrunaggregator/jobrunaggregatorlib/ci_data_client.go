@@ -31,6 +31,9 @@ type AggregationJobClient interface {
 	GetBackendDisruptionStatisticsByJob(ctx context.Context, jobName, masterNodesUpdated string) ([]jobrunaggregatorapi.BackendDisruptionStatisticsRow, error)
 
 	ListAggregatedTestRunsForJob(ctx context.Context, frequency, jobName string, startDay time.Time) ([]jobrunaggregatorapi.AggregatedTestRunRow, error)
+
+	// ListTestPassRatesByDayForJob counts the results of every test per day in the job runs of the job since the time.
+	ListTestPassRatesByDayForJob(ctx context.Context, jobName string, since time.Time) ([]jobrunaggregatorapi.TestPassRateByDayRow, error)
 }
 
 type JobLister interface {
@@ -47,6 +50,10 @@ type JobLister interface {
 type HistoricalDataClient interface {
 	ListDisruptionHistoricalData(ctx context.Context) ([]jobrunaggregatorapi.HistoricalData, error)
 	ListAlertHistoricalData(ctx context.Context) ([]*jobrunaggregatorapi.AlertHistoricalDataRow, error)
+
+	// ListBackendDisruptionByReleaseTag lists the mean disruption per backend of the job runs of the job that tested each
+	// payload since the time, ordered by the start of the first job run of the payload.
+	ListBackendDisruptionByReleaseTag(ctx context.Context, jobName string, since time.Time) ([]jobrunaggregatorapi.BackendDisruptionByReleaseTagRow, error)
 }
 
 type CIDataClient interface {
@@ -56,6 +63,8 @@ type CIDataClient interface {
 
 	// these deal with release tags
 	ListReleaseTags(ctx context.Context) (map[string]bool, error)
+	// ListReleaseTagsSince lists the payloads created since the time, oldest first
+	ListReleaseTagsSince(ctx context.Context, since time.Time) ([]jobrunaggregatorapi.ReleaseTagRow, error)
 
 	// GetLastJobRunEndTimeFromTable returns the last uploaded job runs EndTime in the given table.
 	GetLastJobRunEndTimeFromTable(ctx context.Context, table string) (*time.Time, error)
@@ -221,6 +230,50 @@ func (c *ciDataClient) ListAlertHistoricalData(ctx context.Context) ([]*jobrunag
 	return alertDataSet, nil
 }
 
+func (c *ciDataClient) ListBackendDisruptionByReleaseTag(ctx context.Context, jobName string, since time.Time) ([]jobrunaggregatorapi.BackendDisruptionByReleaseTagRow, error) {
+	queryString := c.dataCoordinates.SubstituteDataSetLocation(`
+SELECT
+    BackendName,
+    ReleaseTag,
+    MIN(JobRunStartTime) AS FirstJobRunStartTime,
+    COUNT(DISTINCT JobRunName) AS JobRuns,
+    AVG(DisruptionSeconds) AS MeanDisruptionSeconds
+FROM DATA_SET_LOCATION.BackendDisruption
+WHERE
+    JobName = @JobName AND
+    JobRunStartTime >= @Since AND
+    ReleaseTag IS NOT NULL AND ReleaseTag != ""
+GROUP BY BackendName, ReleaseTag
+ORDER BY FirstJobRunStartTime, BackendName
+`)
+	query := c.client.Query(queryString)
+	query.Labels = map[string]string{
+		bigQueryLabelKeyApp:   bigQueryLabelValueApp,
+		bigQueryLabelKeyQuery: bigQueryLabelValueDisruptionByReleaseTag,
+	}
+	query.QueryConfig.Parameters = []bigquery.QueryParameter{
+		{Name: "JobName", Value: jobName},
+		{Name: "Since", Value: since},
+	}
+	it, err := query.Read(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query disruption by release tag with %q: %w", queryString, err)
+	}
+	rows := []jobrunaggregatorapi.BackendDisruptionByReleaseTagRow{}
+	for {
+		row := jobrunaggregatorapi.BackendDisruptionByReleaseTagRow{}
+		err := it.Next(&row)
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
 func (c *ciDataClient) ListAllJobsWithVariants(ctx context.Context) ([]jobrunaggregatorapi.JobRowWithVariants, error) {
 	// For Debugging, you can set "LIMIT X" where X is small
 	// so that you can process only a small subset of jobs while
@@ -914,6 +967,38 @@ func (c *ciDataClient) ListReleaseTags(ctx context.Context) (map[string]bool, er
 	return set, nil
 }
 
+func (c *ciDataClient) ListReleaseTagsSince(ctx context.Context, since time.Time) ([]jobrunaggregatorapi.ReleaseTagRow, error) {
+	queryString := c.dataCoordinates.SubstituteDataSetLocation(`
+SELECT * FROM DATA_SET_LOCATION.ReleaseTags
+WHERE releaseTime >= @Since
+ORDER BY releaseTime`)
+	query := c.client.Query(queryString)
+	query.Labels = map[string]string{
+		bigQueryLabelKeyApp:   bigQueryLabelValueApp,
+		bigQueryLabelKeyQuery: bigQueryLabelValueReleaseTags,
+	}
+	query.QueryConfig.Parameters = []bigquery.QueryParameter{
+		{Name: "Since", Value: since},
+	}
+	it, err := query.Read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	releaseTags := []jobrunaggregatorapi.ReleaseTagRow{}
+	for {
+		row := jobrunaggregatorapi.ReleaseTagRow{}
+		err := it.Next(&row)
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		releaseTags = append(releaseTags, row)
+	}
+	return releaseTags, nil
+}
+
 func (c *ciDataClient) ListReleases(ctx context.Context) ([]jobrunaggregatorapi.ReleaseRow, error) {
 	releases := []jobrunaggregatorapi.ReleaseRow{}
 	queryString := c.dataCoordinates.SubstituteDataSetLocation(`SELECT * FROM DATA_SET_LOCATION.Releases ORDER BY DevelStartDate DESC`)
@@ -1070,6 +1155,48 @@ func GetUTCDay(in time.Time) time.Time {
 	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
 }
 
+func (c *ciDataClient) ListTestPassRatesByDayForJob(ctx context.Context, jobName string, since time.Time) ([]jobrunaggregatorapi.TestPassRateByDayRow, error) {
+	queryString := c.dataCoordinates.SubstituteDataSetLocation(`
+SELECT
+    TestSuite,
+    TestName,
+    DATE(JobRunStartTime) AS Day,
+    COUNTIF(TestStatus = "Passed") AS PassCount,
+    COUNTIF(TestStatus = "Failed") AS FailCount,
+    COUNTIF(TestStatus = "Flaked") AS FlakeCount
+FROM DATA_SET_LOCATION.TestRuns
+WHERE JobName = @JobName AND JobRunStartTime >= @Since
+GROUP BY TestSuite, TestName, Day
+ORDER BY Day, TestSuite, TestName
+`)
+	query := c.client.Query(queryString)
+	query.Labels = map[string]string{
+		bigQueryLabelKeyApp:   bigQueryLabelValueApp,
+		bigQueryLabelKeyQuery: bigQueryLabelValueTestPassRatesByDay,
+	}
+	query.QueryConfig.Parameters = []bigquery.QueryParameter{
+		{Name: "JobName", Value: jobName},
+		{Name: "Since", Value: since},
+	}
+	it, err := query.Read(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query test pass rates with %q: %w", queryString, err)
+	}
+	ret := []jobrunaggregatorapi.TestPassRateByDayRow{}
+	for {
+		row := jobrunaggregatorapi.TestPassRateByDayRow{}
+		err := it.Next(&row)
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, row)
+	}
+	return ret, nil
+}
+
 func (c *ciDataClient) ListAllKnownAlerts(ctx context.Context) ([]*jobrunaggregatorapi.KnownAlertRow, error) {
 	queryString := c.dataCoordinates.SubstituteDataSetLocation(
 		`SELECT AlertName, AlertNamespace, AlertLevel, Release, FirstObserved, LastObserved, Results
@@ -208,6 +208,21 @@ func (mr *MockCIDataClientMockRecorder) ListAllKnownAlerts(ctx any) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListAllKnownAlerts", reflect.TypeOf((*MockCIDataClient)(nil).ListAllKnownAlerts), ctx)
 }
 
+// ListBackendDisruptionByReleaseTag mocks base method.
+func (m *MockCIDataClient) ListBackendDisruptionByReleaseTag(ctx context.Context, jobName string, since time.Time) ([]jobrunaggregatorapi.BackendDisruptionByReleaseTagRow, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListBackendDisruptionByReleaseTag", ctx, jobName, since)
+	ret0, _ := ret[0].([]jobrunaggregatorapi.BackendDisruptionByReleaseTagRow)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListBackendDisruptionByReleaseTag indicates an expected call of ListBackendDisruptionByReleaseTag.
+func (mr *MockCIDataClientMockRecorder) ListBackendDisruptionByReleaseTag(ctx, jobName, since any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListBackendDisruptionByReleaseTag", reflect.TypeOf((*MockCIDataClient)(nil).ListBackendDisruptionByReleaseTag), ctx, jobName, since)
+}
+
 // ListDisruptionHistoricalData mocks base method.
 func (m *MockCIDataClient) ListDisruptionHistoricalData(ctx context.Context) ([]jobrunaggregatorapi.HistoricalData, error) {
 	m.ctrl.T.Helper()
@@ -253,6 +268,21 @@ func (mr *MockCIDataClientMockRecorder) ListReleaseTags(ctx any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListReleaseTags", reflect.TypeOf((*MockCIDataClient)(nil).ListReleaseTags), ctx)
 }
 
+// ListReleaseTagsSince mocks base method.
+func (m *MockCIDataClient) ListReleaseTagsSince(ctx context.Context, since time.Time) ([]jobrunaggregatorapi.ReleaseTagRow, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListReleaseTagsSince", ctx, since)
+	ret0, _ := ret[0].([]jobrunaggregatorapi.ReleaseTagRow)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListReleaseTagsSince indicates an expected call of ListReleaseTagsSince.
+func (mr *MockCIDataClientMockRecorder) ListReleaseTagsSince(ctx, since any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListReleaseTagsSince", reflect.TypeOf((*MockCIDataClient)(nil).ListReleaseTagsSince), ctx, since)
+}
+
 // ListReleases mocks base method.
 func (m *MockCIDataClient) ListReleases(ctx context.Context) ([]jobrunaggregatorapi.ReleaseRow, error) {
 	m.ctrl.T.Helper()
@@ -268,6 +298,21 @@ func (mr *MockCIDataClientMockRecorder) ListReleases(ctx any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListReleases", reflect.TypeOf((*MockCIDataClient)(nil).ListReleases), ctx)
 }
 
+// ListTestPassRatesByDayForJob mocks base method.
+func (m *MockCIDataClient) ListTestPassRatesByDayForJob(ctx context.Context, jobName string, since time.Time) ([]jobrunaggregatorapi.TestPassRateByDayRow, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListTestPassRatesByDayForJob", ctx, jobName, since)
+	ret0, _ := ret[0].([]jobrunaggregatorapi.TestPassRateByDayRow)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListTestPassRatesByDayForJob indicates an expected call of ListTestPassRatesByDayForJob.
+func (mr *MockCIDataClientMockRecorder) ListTestPassRatesByDayForJob(ctx, jobName, since any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListTestPassRatesByDayForJob", reflect.TypeOf((*MockCIDataClient)(nil).ListTestPassRatesByDayForJob), ctx, jobName, since)
+}
+
 // ListTestSummaryByPeriod mocks base method.
 func (m *MockCIDataClient) ListTestSummaryByPeriod(ctx context.Context, suiteName, releaseName string, daysBack, minTestCount int) ([]jobrunaggregatorapi.TestSummaryByPeriodRow, error) {
 	m.ctrl.T.Helper()
@@ -1,7 +1,9 @@
 package jobrunaggregatorlib
 
+import "github.com/openshift/ci-tools/pkg/junit"
+
 // TestSuitesSeparator defines the separator to use when combine multiple level of suite names
-var TestSuitesSeparator = "|||"
+var TestSuitesSeparator = junit.SuitePathSeparator
 
 type TestCaseDetails struct {
 	Name          string
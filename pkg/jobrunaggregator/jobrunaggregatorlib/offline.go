@@ -0,0 +1,120 @@
+package jobrunaggregatorlib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
+
+	"github.com/openshift/ci-tools/pkg/jobrunaggregator/jobrunaggregatorapi"
+)
+
+// OfflineDataFlags replace BigQuery and GCS with a SQLite database and a local mirror of the GCS bucket,
+// both filled by the load-offline-data command.
+type OfflineDataFlags struct {
+	Database  string
+	MirrorDir string
+}
+
+func NewOfflineDataFlags() *OfflineDataFlags {
+	return &OfflineDataFlags{}
+}
+
+func (f *OfflineDataFlags) BindFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&f.Database, "offline-database", f.Database, "The SQLite database to read CI data from instead of BigQuery, see load-offline-data")
+	fs.StringVar(&f.MirrorDir, "offline-mirror-dir", f.MirrorDir, "The local mirror of the GCS bucket to read job runs from instead of GCS, see load-offline-data")
+}
+
+// Enabled returns true when the data is read from the database instead of BigQuery
+func (f *OfflineDataFlags) Enabled() bool {
+	return len(f.Database) > 0
+}
+
+func (f *OfflineDataFlags) Validate() error {
+	if len(f.MirrorDir) > 0 && !f.Enabled() {
+		return fmt.Errorf("--offline-mirror-dir requires --offline-database")
+	}
+	if !f.Enabled() {
+		return nil
+	}
+	if _, err := os.Stat(f.Database); err != nil {
+		return fmt.Errorf("invalid --offline-database: %w", err)
+	}
+	return nil
+}
+
+func (f *OfflineDataFlags) NewCIDataClient() (*SQLCIDataClient, error) {
+	return OpenSQLCIDataClient(f.Database)
+}
+
+func (f *OfflineDataFlags) NewCIGCSClient(gcsBucketName string) CIGCSClient {
+	return NewLocalCIGCSClient(f.MirrorDir, gcsBucketName)
+}
+
+// localCIGCSClient reads job runs from a local mirror of the GCS bucket
+type localCIGCSClient struct {
+	mirrorDir     string
+	gcsBucketName string
+}
+
+func NewLocalCIGCSClient(mirrorDir, gcsBucketName string) CIGCSClient {
+	return &localCIGCSClient{
+		mirrorDir:     mirrorDir,
+		gcsBucketName: gcsBucketName,
+	}
+}
+
+func (o *localCIGCSClient) ReadJobRunFromGCS(ctx context.Context, jobGCSRootLocation, jobName, jobRunID string, logger logrus.FieldLogger) (jobrunaggregatorapi.JobRunInfo, error) {
+	logger.Debugf("reading mirrored job run %s/%s", jobGCSRootLocation, jobRunID)
+
+	jobRun := jobrunaggregatorapi.NewLocalJobRun(o.mirrorDir, jobGCSRootLocation, jobName, jobRunID, o.gcsBucketName)
+	jobRun.SetGCSProwJobPath(path.Join(jobGCSRootLocation, jobRunID, "prowjob.json"))
+	if _, err := jobRun.GetProwJob(ctx); err != nil {
+		return nil, fmt.Errorf("failed to get prowjob for %q/%q: %w", jobName, jobRunID, err)
+	}
+	return jobRun, nil
+}
+
+func (o *localCIGCSClient) ReadRelatedJobRuns(ctx context.Context,
+	jobName, gcsPrefix, startingJobRunID, endingJobRunID string,
+	matcherFunc ProwJobMatcherFunc) ([]jobrunaggregatorapi.JobRunInfo, error) {
+
+	logrus.Debugf("searching the mirror for related job runs in %s between %s and %s", gcsPrefix, startingJobRunID, endingJobRunID)
+	entries, err := os.ReadDir(filepath.Join(o.mirrorDir, filepath.FromSlash(gcsPrefix)))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	// like the GCS listing, the job runs are sorted by name and the end is excluded
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+	relatedJobRuns := []jobrunaggregatorapi.JobRunInfo{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		jobRunID := entry.Name()
+		if jobRunID < startingJobRunID || (endingJobRunID != "" && jobRunID >= endingJobRunID) {
+			continue
+		}
+		jobRun := jobrunaggregatorapi.NewLocalJobRun(o.mirrorDir, gcsPrefix, jobName, jobRunID, o.gcsBucketName)
+		jobRun.SetGCSProwJobPath(path.Join(gcsPrefix, jobRunID, "prowjob.json"))
+		prowJob, err := jobRun.GetProwJob(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get prowjob for %q/%q: %w", jobName, jobRunID, err)
+		}
+		if matcherFunc(prowJob) {
+			relatedJobRuns = append(relatedJobRuns, jobRun)
+		}
+	}
+	return relatedJobRuns, nil
+}
@@ -115,6 +115,16 @@ func (c *retryingCIDataClient) ListReleaseTags(ctx context.Context) (map[string]
 	return ret, err
 }
 
+func (c *retryingCIDataClient) ListReleaseTagsSince(ctx context.Context, since time.Time) ([]jobrunaggregatorapi.ReleaseTagRow, error) {
+	var ret []jobrunaggregatorapi.ReleaseTagRow
+	err := retry.OnError(slowBackoff, isReadQuotaError, func() error {
+		var innerErr error
+		ret, innerErr = c.delegate.ListReleaseTagsSince(ctx, since)
+		return innerErr
+	})
+	return ret, err
+}
+
 func (c *retryingCIDataClient) ListReleases(ctx context.Context) ([]jobrunaggregatorapi.ReleaseRow, error) {
 	var ret []jobrunaggregatorapi.ReleaseRow
 	err := retry.OnError(slowBackoff, isReadQuotaError, func() error {
@@ -155,6 +165,26 @@ func (c *retryingCIDataClient) ListAggregatedTestRunsForJob(ctx context.Context,
 	return ret, err
 }
 
+func (c *retryingCIDataClient) ListBackendDisruptionByReleaseTag(ctx context.Context, jobName string, since time.Time) ([]jobrunaggregatorapi.BackendDisruptionByReleaseTagRow, error) {
+	var ret []jobrunaggregatorapi.BackendDisruptionByReleaseTagRow
+	err := retry.OnError(slowBackoff, isReadQuotaError, func() error {
+		var innerErr error
+		ret, innerErr = c.delegate.ListBackendDisruptionByReleaseTag(ctx, jobName, since)
+		return innerErr
+	})
+	return ret, err
+}
+
+func (c *retryingCIDataClient) ListTestPassRatesByDayForJob(ctx context.Context, jobName string, since time.Time) ([]jobrunaggregatorapi.TestPassRateByDayRow, error) {
+	var ret []jobrunaggregatorapi.TestPassRateByDayRow
+	err := retry.OnError(slowBackoff, isReadQuotaError, func() error {
+		var innerErr error
+		ret, innerErr = c.delegate.ListTestPassRatesByDayForJob(ctx, jobName, since)
+		return innerErr
+	})
+	return ret, err
+}
+
 func (c *retryingCIDataClient) ListDisruptionHistoricalData(ctx context.Context) ([]jobrunaggregatorapi.HistoricalData, error) {
 	var ret []jobrunaggregatorapi.HistoricalData
 	err := retry.OnError(slowBackoff, isReadQuotaError, func() error {
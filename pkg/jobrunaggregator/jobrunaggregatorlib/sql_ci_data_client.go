@@ -0,0 +1,1047 @@
+package jobrunaggregatorlib
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/civil"
+
+	// registers the sqlite driver
+	_ "modernc.org/sqlite"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/openshift/ci-tools/pkg/jobrunaggregator/jobrunaggregatorapi"
+)
+
+// sqlSchema mirrors the BigQuery tables the CIDataClient reads. Timestamps are stored as unix milliseconds
+// and dates as YYYY-MM-DD, so both sort correctly. The BigQuery views are computed by the client.
+const sqlSchema = `
+CREATE TABLE IF NOT EXISTS Jobs (
+	JobName TEXT PRIMARY KEY,
+	GCSBucketName TEXT NOT NULL DEFAULT '',
+	GCSJobHistoryLocationPrefix TEXT NOT NULL DEFAULT '',
+	CollectDisruption BOOLEAN NOT NULL DEFAULT TRUE,
+	CollectTestRuns BOOLEAN NOT NULL DEFAULT TRUE,
+	Platform TEXT NOT NULL DEFAULT '',
+	Architecture TEXT NOT NULL DEFAULT '',
+	Network TEXT NOT NULL DEFAULT '',
+	IPMode TEXT NOT NULL DEFAULT '',
+	Topology TEXT NOT NULL DEFAULT '',
+	Release TEXT NOT NULL DEFAULT '',
+	FromRelease TEXT
+);
+CREATE TABLE IF NOT EXISTS JobRuns (
+	Name TEXT PRIMARY KEY,
+	JobName TEXT NOT NULL,
+	Status TEXT NOT NULL DEFAULT '',
+	URL TEXT NOT NULL DEFAULT '',
+	StartTime INTEGER NOT NULL,
+	EndTime INTEGER NOT NULL,
+	ReleaseTag TEXT NOT NULL DEFAULT '',
+	Cluster TEXT NOT NULL DEFAULT '',
+	MasterNodesUpdated TEXT
+);
+CREATE INDEX IF NOT EXISTS JobRunsByJob ON JobRuns (JobName, StartTime);
+CREATE TABLE IF NOT EXISTS BackendDisruption (
+	BackendName TEXT NOT NULL,
+	DisruptionSeconds INTEGER NOT NULL,
+	JobName TEXT,
+	JobRunName TEXT NOT NULL,
+	JobRunStartTime INTEGER,
+	JobRunEndTime INTEGER,
+	Cluster TEXT,
+	ReleaseTag TEXT,
+	MasterNodesUpdated TEXT,
+	JobRunStatus TEXT,
+	PRIMARY KEY (JobRunName, BackendName)
+);
+CREATE INDEX IF NOT EXISTS BackendDisruptionByJob ON BackendDisruption (JobName, JobRunStartTime);
+CREATE TABLE IF NOT EXISTS Alerts (
+	Name TEXT NOT NULL,
+	Namespace TEXT NOT NULL,
+	Level TEXT NOT NULL,
+	AlertSeconds INTEGER NOT NULL,
+	JobName TEXT,
+	JobRunName TEXT NOT NULL,
+	JobRunStartTime INTEGER,
+	JobRunEndTime INTEGER,
+	Cluster TEXT,
+	ReleaseTag TEXT,
+	MasterNodesUpdated TEXT,
+	JobRunStatus TEXT,
+	PRIMARY KEY (JobRunName, Name, Namespace, Level)
+);
+CREATE TABLE IF NOT EXISTS TestRuns (
+	TestSuite TEXT NOT NULL,
+	TestName TEXT NOT NULL,
+	TestStatus TEXT NOT NULL,
+	DurationMs INTEGER NOT NULL DEFAULT 0,
+	JobName TEXT NOT NULL,
+	JobRunName TEXT NOT NULL,
+	JobRunStartTime INTEGER NOT NULL,
+	Cluster TEXT NOT NULL DEFAULT '',
+	ReleaseTag TEXT NOT NULL DEFAULT '',
+	PRIMARY KEY (JobRunName, TestSuite, TestName)
+);
+CREATE INDEX IF NOT EXISTS TestRunsByJob ON TestRuns (JobName, JobRunStartTime);
+CREATE TABLE IF NOT EXISTS Releases (
+	Release TEXT PRIMARY KEY,
+	Major INTEGER NOT NULL,
+	Minor INTEGER NOT NULL,
+	GADate TEXT,
+	DevelStartDate TEXT NOT NULL,
+	Product TEXT,
+	Patch INTEGER
+);
+`
+
+// SQLCIDataClient is a CIDataClient backed by an embedded SQLite database, which makes it possible to
+// run the aggregation offline. The database is filled with the Inserters, see the load-offline-data command.
+type SQLCIDataClient struct {
+	db  *sql.DB
+	now func() time.Time
+}
+
+var _ CIDataClient = &SQLCIDataClient{}
+
+// OpenSQLCIDataClient opens the database at path and creates the tables that do not exist yet
+func OpenSQLCIDataClient(path string) (*SQLCIDataClient, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database %s: %w", path, err)
+	}
+	// sqlite does not allow concurrent writers
+	db.SetMaxOpenConns(1)
+	client, err := NewSQLCIDataClient(db)
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return client, nil
+}
+
+func NewSQLCIDataClient(db *sql.DB) (*SQLCIDataClient, error) {
+	if _, err := db.Exec(sqlSchema); err != nil {
+		return nil, fmt.Errorf("failed to create tables: %w", err)
+	}
+	return &SQLCIDataClient{db: db, now: time.Now}, nil
+}
+
+func (c *SQLCIDataClient) Close() error {
+	return c.db.Close()
+}
+
+func toSQLTime(t time.Time) int64 {
+	return t.UnixMilli()
+}
+
+func fromSQLTime(ms int64) time.Time {
+	return time.UnixMilli(ms).UTC()
+}
+
+func toSQLNullTime(t bigquery.NullTimestamp) sql.NullInt64 {
+	return sql.NullInt64{Int64: toSQLTime(t.Timestamp), Valid: t.Valid}
+}
+
+func toSQLNullString(s bigquery.NullString) sql.NullString {
+	return sql.NullString{String: s.StringVal, Valid: s.Valid}
+}
+
+func fromSQLNullString(s sql.NullString) bigquery.NullString {
+	return bigquery.NullString{StringVal: s.String, Valid: s.Valid}
+}
+
+// percentile interpolates linearly between the closest ranks like PERCENTILE_CONT, values must be sorted
+func percentile(values []float64, p float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	rank := p * float64(len(values)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	return values[lower] + (values[upper]-values[lower])*(rank-float64(lower))
+}
+
+// meanAndStandardDeviation returns the mean and the sample standard deviation like AVG and STDDEV
+func meanAndStandardDeviation(values []float64) (float64, float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+	if len(values) < 2 {
+		return mean, 0
+	}
+	var squares float64
+	for _, v := range values {
+		squares += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(squares / float64(len(values)-1))
+}
+
+// formatPercentile formats percentiles like the BigQuery client does after casting them to strings, the
+// interpolation noise is rounded away
+func formatPercentile(value float64) string {
+	formatted := strconv.FormatFloat(math.Round(value*1e6)/1e6, 'f', -1, 64)
+	if !strings.Contains(formatted, ".") {
+		formatted += ".0"
+	}
+	return formatted
+}
+
+// historicalGroup collects the values of one row of the percentile views
+type historicalGroup struct {
+	values  []float64
+	jobRuns sets.Set[string]
+}
+
+func (g *historicalGroup) add(jobRun string, value float64) {
+	g.values = append(g.values, value)
+	g.jobRuns.Insert(jobRun)
+}
+
+func (g *historicalGroup) percentiles() (p50, p75, p95, p99 string) {
+	sort.Float64s(g.values)
+	return formatPercentile(percentile(g.values, 0.50)), formatPercentile(percentile(g.values, 0.75)),
+		formatPercentile(percentile(g.values, 0.95)), formatPercentile(percentile(g.values, 0.99))
+}
+
+func (c *SQLCIDataClient) ListDisruptionHistoricalData(ctx context.Context) ([]jobrunaggregatorapi.HistoricalData, error) {
+	// Like BackendDisruptionPercentilesByDate with a 30 day look back, only where master nodes were updated
+	// or the job is not an upgrade.
+	rows, err := c.db.QueryContext(ctx, `
+SELECT d.BackendName, d.DisruptionSeconds, d.JobRunName, d.MasterNodesUpdated,
+	j.Release, IFNULL(j.FromRelease, ''), j.Platform, j.Architecture, j.Network, j.Topology
+FROM BackendDisruption AS d JOIN Jobs AS j ON d.JobName = j.JobName
+WHERE d.JobRunStartTime >= ?`, toSQLTime(c.now().Add(-30*24*time.Hour)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query disruption: %w", err)
+	}
+	defer rows.Close()
+
+	type key struct {
+		backendName, masterNodesUpdated string
+		masterNodesUpdatedValid         bool
+		jobData                         jobrunaggregatorapi.HistoricalJobData
+	}
+	groups := map[key]*historicalGroup{}
+	for rows.Next() {
+		var k key
+		var seconds float64
+		var jobRun string
+		var masterNodesUpdated sql.NullString
+		if err := rows.Scan(&k.backendName, &seconds, &jobRun, &masterNodesUpdated,
+			&k.jobData.Release, &k.jobData.FromRelease, &k.jobData.Platform, &k.jobData.Architecture, &k.jobData.Network, &k.jobData.Topology); err != nil {
+			return nil, err
+		}
+		if !(masterNodesUpdated.Valid && masterNodesUpdated.String != "N") && k.jobData.FromRelease != "" {
+			continue
+		}
+		k.masterNodesUpdated, k.masterNodesUpdatedValid = masterNodesUpdated.String, masterNodesUpdated.Valid
+		if groups[k] == nil {
+			groups[k] = &historicalGroup{jobRuns: sets.New[string]()}
+		}
+		groups[k].add(jobRun, seconds)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	disruptionDataSet := []*jobrunaggregatorapi.DisruptionHistoricalDataRow{}
+	for k, group := range groups {
+		data := &jobrunaggregatorapi.DisruptionHistoricalDataRow{BackendName: k.backendName, HistoricalJobData: k.jobData}
+		data.MasterNodesUpdated = bigquery.NullString{StringVal: k.masterNodesUpdated, Valid: k.masterNodesUpdatedValid}
+		data.JobRuns = group.jobRuns.Len()
+		data.P50, data.P75, data.P95, data.P99 = group.percentiles()
+		disruptionDataSet = append(disruptionDataSet, data)
+	}
+	sort.Slice(disruptionDataSet, func(i, j int) bool {
+		a, b := disruptionDataSet[i], disruptionDataSet[j]
+		for _, pair := range [][2]string{
+			{a.Release, b.Release}, {a.FromRelease, b.FromRelease}, {a.MasterNodesUpdated.StringVal, b.MasterNodesUpdated.StringVal},
+			{a.Platform, b.Platform}, {a.Architecture, b.Architecture}, {a.Network, b.Network}, {a.Topology, b.Topology},
+		} {
+			if pair[0] != pair[1] {
+				return pair[0] < pair[1]
+			}
+		}
+		return a.BackendName < b.BackendName
+	})
+	return jobrunaggregatorapi.ConvertToHistoricalData(disruptionDataSet), nil
+}
+
+func (c *SQLCIDataClient) ListBackendDisruptionByReleaseTag(ctx context.Context, jobName string, since time.Time) ([]jobrunaggregatorapi.BackendDisruptionByReleaseTagRow, error) {
+	rows, err := c.db.QueryContext(ctx, `
+SELECT BackendName, ReleaseTag, MIN(JobRunStartTime), COUNT(DISTINCT JobRunName), AVG(DisruptionSeconds) FROM BackendDisruption
+WHERE JobName = ? AND JobRunStartTime >= ? AND ReleaseTag IS NOT NULL AND ReleaseTag != ''
+GROUP BY BackendName, ReleaseTag
+ORDER BY MIN(JobRunStartTime), BackendName`, jobName, toSQLTime(since))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query disruption by release tag: %w", err)
+	}
+	defer rows.Close()
+	ret := []jobrunaggregatorapi.BackendDisruptionByReleaseTagRow{}
+	for rows.Next() {
+		row := jobrunaggregatorapi.BackendDisruptionByReleaseTagRow{}
+		var firstStart int64
+		if err := rows.Scan(&row.BackendName, &row.ReleaseTag, &firstStart, &row.JobRuns, &row.MeanDisruptionSeconds); err != nil {
+			return nil, err
+		}
+		row.FirstJobRunStartTime = fromSQLTime(firstStart)
+		ret = append(ret, row)
+	}
+	return ret, rows.Err()
+}
+
+func (c *SQLCIDataClient) ListAlertHistoricalData(ctx context.Context) ([]*jobrunaggregatorapi.AlertHistoricalDataRow, error) {
+	// Like Alerts_Unified_LastWeek_P95
+	rows, err := c.db.QueryContext(ctx, `
+SELECT a.Name, a.Namespace, a.Level, a.AlertSeconds, a.JobRunName,
+	j.Release, IFNULL(j.FromRelease, ''), j.Platform, j.Architecture, j.Network, j.Topology
+FROM Alerts AS a JOIN Jobs AS j ON a.JobName = j.JobName
+WHERE a.JobRunStartTime >= ?`, toSQLTime(c.now().Add(-7*24*time.Hour)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query alerts: %w", err)
+	}
+	defer rows.Close()
+
+	type key struct {
+		name, namespace, level string
+		jobData                jobrunaggregatorapi.HistoricalJobData
+	}
+	groups := map[key]*historicalGroup{}
+	for rows.Next() {
+		var k key
+		var seconds float64
+		var jobRun string
+		if err := rows.Scan(&k.name, &k.namespace, &k.level, &seconds, &jobRun,
+			&k.jobData.Release, &k.jobData.FromRelease, &k.jobData.Platform, &k.jobData.Architecture, &k.jobData.Network, &k.jobData.Topology); err != nil {
+			return nil, err
+		}
+		if groups[k] == nil {
+			groups[k] = &historicalGroup{jobRuns: sets.New[string]()}
+		}
+		groups[k].add(jobRun, seconds)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	alertDataSet := []*jobrunaggregatorapi.AlertHistoricalDataRow{}
+	for k, group := range groups {
+		data := &jobrunaggregatorapi.AlertHistoricalDataRow{AlertName: k.name, AlertNamespace: k.namespace, AlertLevel: k.level, HistoricalJobData: k.jobData}
+		data.JobRuns = group.jobRuns.Len()
+		data.P50, data.P75, data.P95, data.P99 = group.percentiles()
+		alertDataSet = append(alertDataSet, data)
+	}
+	sort.Slice(alertDataSet, func(i, j int) bool {
+		a, b := alertDataSet[i], alertDataSet[j]
+		for _, pair := range [][2]string{
+			{a.Release, b.Release}, {a.AlertName, b.AlertName}, {a.AlertNamespace, b.AlertNamespace}, {a.AlertLevel, b.AlertLevel},
+			{a.FromRelease, b.FromRelease}, {a.Topology, b.Topology}, {a.Platform, b.Platform},
+		} {
+			if pair[0] != pair[1] {
+				return pair[0] < pair[1]
+			}
+		}
+		return a.Network < b.Network
+	})
+	return alertDataSet, nil
+}
+
+const sqlJobColumns = `JobName, GCSBucketName, GCSJobHistoryLocationPrefix, CollectDisruption, CollectTestRuns,
+	Platform, Architecture, Network, IPMode, Topology, Release, FromRelease`
+
+func scanJobWithVariants(rows *sql.Rows) (jobrunaggregatorapi.JobRowWithVariants, error) {
+	job := jobrunaggregatorapi.JobRowWithVariants{}
+	var fromRelease sql.NullString
+	err := rows.Scan(&job.JobName, &job.GCSBucketName, &job.GCSJobHistoryLocationPrefix, &job.CollectDisruption, &job.CollectTestRuns,
+		&job.Platform, &job.Architecture, &job.Network, &job.IPMode, &job.Topology, &job.Release, &fromRelease)
+	job.FromRelease = fromSQLNullString(fromRelease)
+	return job, err
+}
+
+func (c *SQLCIDataClient) listJobsWithVariants(ctx context.Context, where string, args ...any) ([]jobrunaggregatorapi.JobRowWithVariants, error) {
+	rows, err := c.db.QueryContext(ctx, `SELECT `+sqlJobColumns+` FROM Jobs `+where+` ORDER BY JobName ASC`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query job table: %w", err)
+	}
+	defer rows.Close()
+	jobs := []jobrunaggregatorapi.JobRowWithVariants{}
+	for rows.Next() {
+		job, err := scanJobWithVariants(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+func (c *SQLCIDataClient) ListAllJobsWithVariants(ctx context.Context) ([]jobrunaggregatorapi.JobRowWithVariants, error) {
+	return c.listJobsWithVariants(ctx, "")
+}
+
+func (c *SQLCIDataClient) GetJobVariants(ctx context.Context, jobName string) (*jobrunaggregatorapi.JobRowWithVariants, error) {
+	jobs, err := c.listJobsWithVariants(ctx, "WHERE JobName = ?", jobName)
+	if err != nil {
+		return nil, err
+	}
+	if len(jobs) == 0 {
+		return nil, fmt.Errorf("%s not found in variant registry", jobName)
+	}
+	return &jobs[0], nil
+}
+
+func (c *SQLCIDataClient) ListAllJobs(ctx context.Context) ([]jobrunaggregatorapi.JobRow, error) {
+	jobsWithVariants, err := c.ListAllJobsWithVariants(ctx)
+	if err != nil {
+		return nil, err
+	}
+	jobs := make([]jobrunaggregatorapi.JobRow, 0, len(jobsWithVariants))
+	for _, job := range jobsWithVariants {
+		jobs = append(jobs, jobrunaggregatorapi.JobRow{
+			JobName:                     job.JobName,
+			GCSBucketName:               job.GCSBucketName,
+			GCSJobHistoryLocationPrefix: job.GCSJobHistoryLocationPrefix,
+			CollectDisruption:           job.CollectDisruption,
+			CollectTestRuns:             job.CollectTestRuns,
+		})
+	}
+	return jobs, nil
+}
+
+// jobRunTables are the tables that record job runs, the only ones the table queries accept
+var jobRunTables = sets.New[string](jobrunaggregatorapi.BackendDisruptionTableName, jobrunaggregatorapi.AlertsTableName, jobrunaggregatorapi.TestRunsTableName)
+
+func (c *SQLCIDataClient) GetLastJobRunEndTimeFromTable(ctx context.Context, table string) (*time.Time, error) {
+	if !jobRunTables.Has(table) {
+		return nil, fmt.Errorf("unsupported table %s, must be one of %v", table, sets.List(jobRunTables))
+	}
+	var endTime sql.NullInt64
+	// TestRuns do not record the end time of the job run
+	err := c.db.QueryRowContext(ctx,
+		`SELECT MAX(r.EndTime) FROM `+table+` AS t JOIN JobRuns AS r ON t.JobRunName = r.Name WHERE t.JobRunStartTime > ?`,
+		toSQLTime(c.now().Add(-14*24*time.Hour))).Scan(&endTime)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query %s: %w", table, err)
+	}
+	ret := time.Time{}
+	if endTime.Valid {
+		ret = fromSQLTime(endTime.Int64)
+	}
+	return &ret, nil
+}
+
+func (c *SQLCIDataClient) ListUploadedJobRunIDsSinceFromTable(ctx context.Context, table string, since *time.Time) (map[string]bool, error) {
+	if !jobRunTables.Has(table) {
+		return nil, fmt.Errorf("unsupported table %s, must be one of %v", table, sets.List(jobRunTables))
+	}
+	rows, err := c.db.QueryContext(ctx,
+		`SELECT DISTINCT t.JobRunName FROM `+table+` AS t JOIN JobRuns AS r ON t.JobRunName = r.Name WHERE r.EndTime >= ?`,
+		toSQLTime(*since))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query %s: %w", table, err)
+	}
+	defer rows.Close()
+	jobRunIDs := map[string]bool{}
+	for rows.Next() {
+		var jobRunID string
+		if err := rows.Scan(&jobRunID); err != nil {
+			return nil, err
+		}
+		jobRunIDs[jobRunID] = true
+	}
+	return jobRunIDs, rows.Err()
+}
+
+func (c *SQLCIDataClient) ListProwJobRunsSince(ctx context.Context, since *time.Time) ([]*jobrunaggregatorapi.TestPlatformProwJobRow, error) {
+	rows, err := c.db.QueryContext(ctx,
+		`SELECT Name, JobName, Status, Cluster, URL, StartTime, EndTime FROM JobRuns WHERE EndTime > ? ORDER BY EndTime`,
+		toSQLTime(*since))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query job runs: %w", err)
+	}
+	defer rows.Close()
+	jobRuns := []*jobrunaggregatorapi.TestPlatformProwJobRow{}
+	for rows.Next() {
+		jobRun := &jobrunaggregatorapi.TestPlatformProwJobRow{}
+		var start, end int64
+		if err := rows.Scan(&jobRun.BuildID, &jobRun.JobName, &jobRun.State, &jobRun.Cluster, &jobRun.URL, &start, &end); err != nil {
+			return nil, err
+		}
+		jobRun.StartTime, jobRun.CompletionTime = fromSQLTime(start), fromSQLTime(end)
+		jobRuns = append(jobRuns, jobRun)
+	}
+	return jobRuns, rows.Err()
+}
+
+func masterNodesUpdatedCondition(masterNodesUpdated string) (string, []any) {
+	// empty string omits the condition for the preflag data / unable to determine
+	if len(masterNodesUpdated) == 0 {
+		return "", nil
+	}
+	return " AND MasterNodesUpdated = ?", []any{masterNodesUpdated}
+}
+
+func (c *SQLCIDataClient) GetBackendDisruptionRowCountByJob(ctx context.Context, jobName, masterNodesUpdated string) (uint64, error) {
+	condition, args := masterNodesUpdatedCondition(masterNodesUpdated)
+	var count uint64
+	err := c.db.QueryRowContext(ctx,
+		`SELECT COUNT(DISTINCT JobRunName) FROM BackendDisruption WHERE JobRunStartTime <= ? AND JobName = ?`+condition,
+		append([]any{toSQLTime(c.now().Add(-3 * 24 * time.Hour)), jobName}, args...)...).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count disruption rows: %w", err)
+	}
+	return count, nil
+}
+
+func (c *SQLCIDataClient) GetBackendDisruptionStatisticsByJob(ctx context.Context, jobName, masterNodesUpdated string) ([]jobrunaggregatorapi.BackendDisruptionStatisticsRow, error) {
+	condition, args := masterNodesUpdatedCondition(masterNodesUpdated)
+	now := c.now()
+	queryRows, err := c.db.QueryContext(ctx,
+		`SELECT BackendName, DisruptionSeconds FROM BackendDisruption WHERE JobRunStartTime BETWEEN ? AND ? AND JobName = ?`+condition,
+		append([]any{toSQLTime(now.Add(-10 * 24 * time.Hour)), toSQLTime(now.Add(-3 * 24 * time.Hour)), jobName}, args...)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query disruption: %w", err)
+	}
+	defer queryRows.Close()
+
+	valuesByBackend := map[string][]float64{}
+	for queryRows.Next() {
+		var backend string
+		var seconds float64
+		if err := queryRows.Scan(&backend, &seconds); err != nil {
+			return nil, err
+		}
+		valuesByBackend[backend] = append(valuesByBackend[backend], seconds)
+	}
+	if err := queryRows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows := make([]jobrunaggregatorapi.BackendDisruptionStatisticsRow, 0, len(valuesByBackend))
+	for _, backend := range sets.List(sets.KeySet(valuesByBackend)) {
+		values := valuesByBackend[backend]
+		sort.Float64s(values)
+		row := jobrunaggregatorapi.BackendDisruptionStatisticsRow{BackendName: backend}
+		row.Mean, row.StandardDeviation = meanAndStandardDeviation(values)
+		// the row has one field for every percentile from P1 to P99
+		fields := reflect.ValueOf(&row).Elem()
+		for p := 1; p < 100; p++ {
+			fields.FieldByName(fmt.Sprintf("P%d", p)).SetFloat(percentile(values, float64(p)/100))
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func (c *SQLCIDataClient) ListReleaseTags(ctx context.Context) (map[string]bool, error) {
+	rows, err := c.db.QueryContext(ctx, `SELECT DISTINCT ReleaseTag FROM JobRuns WHERE ReleaseTag != ''`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query release tags: %w", err)
+	}
+	defer rows.Close()
+	set := map[string]bool{}
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			return nil, err
+		}
+		set[tag] = true
+	}
+	return set, rows.Err()
+}
+
+// releaseTagTime matches the creation time at the end of payload tags, like 4.16.0-0.nightly-2024-05-20-120000
+var releaseTagTime = regexp.MustCompile(`([0-9]{4}-[0-9]{2}-[0-9]{2}-[0-9]{6})$`)
+
+// ListReleaseTagsSince lists the payloads of the job runs since there is no ReleaseTags table, the release, stream
+// and release time are parsed from the tags like the release uploader does.
+func (c *SQLCIDataClient) ListReleaseTagsSince(ctx context.Context, since time.Time) ([]jobrunaggregatorapi.ReleaseTagRow, error) {
+	rows, err := c.db.QueryContext(ctx, `SELECT ReleaseTag, MIN(StartTime) FROM JobRuns WHERE ReleaseTag != '' GROUP BY ReleaseTag`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query release tags: %w", err)
+	}
+	defer rows.Close()
+	releaseTags := []jobrunaggregatorapi.ReleaseTagRow{}
+	for rows.Next() {
+		var firstStart int64
+		row := jobrunaggregatorapi.ReleaseTagRow{}
+		if err := rows.Scan(&row.ReleaseTag, &firstStart); err != nil {
+			return nil, err
+		}
+		// 4.16.0-0.nightly-2024-05-20-120000 -> 4.16 and nightly
+		parts := strings.Split(row.ReleaseTag, ".")
+		if len(parts) >= 2 {
+			row.Release = strings.Join(parts[:2], ".")
+		}
+		if len(parts) >= 4 {
+			if stream := strings.Split(parts[3], "-"); len(stream) >= 2 {
+				row.Stream = stream[0]
+			}
+		}
+		row.ReleaseTime = fromSQLTime(firstStart)
+		if match := releaseTagTime.FindStringSubmatch(row.ReleaseTag); len(match) > 1 {
+			if t, err := time.Parse("2006-01-02-150405", match[1]); err == nil {
+				row.ReleaseTime = t
+			}
+		}
+		if row.ReleaseTime.Before(since) {
+			continue
+		}
+		releaseTags = append(releaseTags, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(releaseTags, func(i, j int) bool {
+		return releaseTags[i].ReleaseTime.Before(releaseTags[j].ReleaseTime)
+	})
+	return releaseTags, nil
+}
+
+func (c *SQLCIDataClient) ListReleases(ctx context.Context) ([]jobrunaggregatorapi.ReleaseRow, error) {
+	rows, err := c.db.QueryContext(ctx, `SELECT Release, Major, Minor, GADate, DevelStartDate, Product, Patch FROM Releases ORDER BY DevelStartDate DESC`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query releases: %w", err)
+	}
+	defer rows.Close()
+	releases := []jobrunaggregatorapi.ReleaseRow{}
+	for rows.Next() {
+		release := jobrunaggregatorapi.ReleaseRow{}
+		var gaDate, product sql.NullString
+		var develStartDate string
+		var patch sql.NullInt64
+		if err := rows.Scan(&release.Release, &release.Major, &release.Minor, &gaDate, &develStartDate, &product, &patch); err != nil {
+			return nil, err
+		}
+		if release.DevelStartDate, err = civil.ParseDate(develStartDate); err != nil {
+			return nil, fmt.Errorf("invalid DevelStartDate of release %s: %w", release.Release, err)
+		}
+		if gaDate.Valid {
+			date, err := civil.ParseDate(gaDate.String)
+			if err != nil {
+				return nil, fmt.Errorf("invalid GADate of release %s: %w", release.Release, err)
+			}
+			release.GADate = bigquery.NullDate{Date: date, Valid: true}
+		}
+		release.Product = fromSQLNullString(product)
+		release.Patch = bigquery.NullInt64{Int64: patch.Int64, Valid: patch.Valid}
+		releases = append(releases, release)
+	}
+	return releases, rows.Err()
+}
+
+// getJobRunForJobName uses the job runs as the index, BigQuery uses the disruption table instead
+func (c *SQLCIDataClient) getJobRunForJobName(ctx context.Context, query string, args ...any) (string, error) {
+	var name string
+	err := c.db.QueryRowContext(ctx, query, args...).Scan(&name)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return name, err
+}
+
+func (c *SQLCIDataClient) GetJobRunForJobNameBeforeTime(ctx context.Context, jobName string, targetTime time.Time) (string, error) {
+	return c.getJobRunForJobName(ctx,
+		`SELECT Name FROM JobRuns WHERE StartTime <= ? AND StartTime >= ? AND JobName = ? ORDER BY StartTime DESC LIMIT 1`,
+		toSQLTime(targetTime), toSQLTime(c.now().Add(-14*24*time.Hour)), jobName)
+}
+
+func (c *SQLCIDataClient) GetJobRunForJobNameAfterTime(ctx context.Context, jobName string, targetTime time.Time) (string, error) {
+	return c.getJobRunForJobName(ctx,
+		`SELECT Name FROM JobRuns WHERE StartTime >= ? AND JobName = ? ORDER BY StartTime ASC LIMIT 1`,
+		toSQLTime(targetTime), jobName)
+}
+
+// testCounts sums up the results of a test
+type testCounts struct {
+	pass, fail, flake int
+}
+
+func (t *testCounts) add(status string) {
+	switch status {
+	case jobrunaggregatorapi.TestStatusPassed:
+		t.pass++
+	case jobrunaggregatorapi.TestStatusFailed:
+		t.fail++
+	case jobrunaggregatorapi.TestStatusFlaked:
+		t.flake++
+	}
+}
+
+func (t *testCounts) total() int {
+	return t.pass + t.fail + t.flake
+}
+
+func (c *SQLCIDataClient) ListAggregatedTestRunsForJob(ctx context.Context, frequency, jobName string, startDay time.Time) ([]jobrunaggregatorapi.AggregatedTestRunRow, error) {
+	if frequency != "ByOneWeek" {
+		return nil, fmt.Errorf("unrecognized frequency: %q", frequency)
+	}
+	// Like TestRuns_Summary_Last200Runs
+	rows, err := c.db.QueryContext(ctx, `
+SELECT TestSuite, TestName, TestStatus, Cluster, JobRunStartTime FROM TestRuns
+WHERE JobName = ? AND JobRunName IN (SELECT Name FROM JobRuns WHERE JobName = ? ORDER BY StartTime DESC LIMIT 200)`, jobName, jobName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query test runs: %w", err)
+	}
+	defer rows.Close()
+
+	type key struct{ suite, name string }
+	counts := map[key]*testCounts{}
+	clusters := map[key]map[string]int{}
+	var earliest int64 = math.MaxInt64
+	for rows.Next() {
+		var k key
+		var status, cluster string
+		var start int64
+		if err := rows.Scan(&k.suite, &k.name, &status, &cluster, &start); err != nil {
+			return nil, err
+		}
+		if counts[k] == nil {
+			counts[k] = &testCounts{}
+			clusters[k] = map[string]int{}
+		}
+		counts[k].add(status)
+		clusters[k][cluster]++
+		if start < earliest {
+			earliest = start
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	ret := []jobrunaggregatorapi.AggregatedTestRunRow{}
+	for k, count := range counts {
+		total := count.total()
+		if total == 0 {
+			continue
+		}
+		dominantCluster := ""
+		for _, cluster := range sets.List(sets.KeySet(clusters[k])) {
+			if clusters[k][cluster] > clusters[k][dominantCluster] {
+				dominantCluster = cluster
+			}
+		}
+		ret = append(ret, jobrunaggregatorapi.AggregatedTestRunRow{
+			AggregationStartDate: GetUTCDay(fromSQLTime(earliest)),
+			TestName:             k.name,
+			TestSuiteName:        bigquery.NullString{StringVal: k.suite, Valid: k.suite != ""},
+			JobName:              jobName,
+			PassCount:            count.pass,
+			FailCount:            count.fail,
+			FlakeCount:           count.flake,
+			PassPercentage:       float64(count.pass) * 100 / float64(total),
+			WorkingPercentage:    float64(count.pass+count.flake) * 100 / float64(total),
+			DominantCluster:      dominantCluster,
+		})
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].TestSuiteName.StringVal != ret[j].TestSuiteName.StringVal {
+			return ret[i].TestSuiteName.StringVal < ret[j].TestSuiteName.StringVal
+		}
+		return ret[i].TestName < ret[j].TestName
+	})
+	return ret, nil
+}
+
+func (c *SQLCIDataClient) ListTestPassRatesByDayForJob(ctx context.Context, jobName string, since time.Time) ([]jobrunaggregatorapi.TestPassRateByDayRow, error) {
+	rows, err := c.db.QueryContext(ctx, `
+SELECT TestSuite, TestName, date(JobRunStartTime / 1000, 'unixepoch') AS Day,
+	SUM(TestStatus = ?), SUM(TestStatus = ?), SUM(TestStatus = ?) FROM TestRuns
+WHERE JobName = ? AND JobRunStartTime >= ?
+GROUP BY TestSuite, TestName, Day
+ORDER BY Day, TestSuite, TestName`,
+		jobrunaggregatorapi.TestStatusPassed, jobrunaggregatorapi.TestStatusFailed, jobrunaggregatorapi.TestStatusFlaked, jobName, toSQLTime(since))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query test pass rates: %w", err)
+	}
+	defer rows.Close()
+	ret := []jobrunaggregatorapi.TestPassRateByDayRow{}
+	for rows.Next() {
+		row := jobrunaggregatorapi.TestPassRateByDayRow{}
+		var day string
+		if err := rows.Scan(&row.TestSuite, &row.TestName, &day, &row.PassCount, &row.FailCount, &row.FlakeCount); err != nil {
+			return nil, err
+		}
+		if row.Day, err = civil.ParseDate(day); err != nil {
+			return nil, fmt.Errorf("invalid day %q: %w", day, err)
+		}
+		ret = append(ret, row)
+	}
+	return ret, rows.Err()
+}
+
+func (c *SQLCIDataClient) ListAllKnownAlerts(ctx context.Context) ([]*jobrunaggregatorapi.KnownAlertRow, error) {
+	// Like Alerts_AllKnown: every alert, namespace and level observed in a release
+	rows, err := c.db.QueryContext(ctx, `
+SELECT a.Name, a.Namespace, a.Level, j.Release, MIN(a.JobRunStartTime), MAX(a.JobRunStartTime), COUNT(*)
+FROM Alerts AS a JOIN Jobs AS j ON a.JobName = j.JobName
+GROUP BY a.Name, a.Namespace, a.Level, j.Release
+ORDER BY j.Release, a.Name, a.Namespace, MIN(a.JobRunStartTime) ASC`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query known alerts: %w", err)
+	}
+	defer rows.Close()
+	allKnownAlerts := []*jobrunaggregatorapi.KnownAlertRow{}
+	for rows.Next() {
+		knownAlert := &jobrunaggregatorapi.KnownAlertRow{}
+		var first, last sql.NullInt64
+		if err := rows.Scan(&knownAlert.AlertName, &knownAlert.AlertNamespace, &knownAlert.AlertLevel, &knownAlert.Release, &first, &last, &knownAlert.Results); err != nil {
+			return nil, err
+		}
+		knownAlert.FirstObserved, knownAlert.LastObserved = fromSQLTime(first.Int64), fromSQLTime(last.Int64)
+		allKnownAlerts = append(allKnownAlerts, knownAlert)
+	}
+	return allKnownAlerts, rows.Err()
+}
+
+func (c *SQLCIDataClient) ListTestSummaryByPeriod(ctx context.Context, suiteName, releaseName string, daysBack, minTestCount int) ([]jobrunaggregatorapi.TestSummaryByPeriodRow, error) {
+	// Like TestsSummaryByDate: the first sample date of a test is searched across all releases in
+	// max(100, daysBack) days. There is no data about CPU usage offline, HighCPUCount is always zero.
+	today := civil.DateOf(c.now().UTC())
+	periodStart := today.AddDays(-daysBack)
+	lookBackStart := today.AddDays(-max(100, daysBack))
+	rows, err := c.db.QueryContext(ctx, `
+SELECT t.TestName, j.Release, t.TestStatus, t.DurationMs, t.JobRunStartTime
+FROM TestRuns AS t JOIN Jobs AS j ON t.JobName = j.JobName
+WHERE t.TestSuite = ? AND t.JobRunStartTime >= ? AND t.JobRunStartTime < ?`,
+		suiteName, toSQLTime(lookBackStart.In(time.UTC)), toSQLTime(today.AddDays(1).In(time.UTC)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query test runs: %w", err)
+	}
+	defer rows.Close()
+
+	type daily struct {
+		counts     testCounts
+		durationMs int64
+	}
+	firstSampleDates := map[string]civil.Date{}
+	byTestAndDate := map[string]map[civil.Date]*daily{}
+	for rows.Next() {
+		var name, release, status string
+		var durationMs, start int64
+		if err := rows.Scan(&name, &release, &status, &durationMs, &start); err != nil {
+			return nil, err
+		}
+		date := civil.DateOf(fromSQLTime(start))
+		if first, ok := firstSampleDates[name]; !ok || date.Before(first) {
+			firstSampleDates[name] = date
+		}
+		if release != releaseName || date.Before(periodStart) {
+			continue
+		}
+		if byTestAndDate[name] == nil {
+			byTestAndDate[name] = map[civil.Date]*daily{}
+		}
+		if byTestAndDate[name][date] == nil {
+			byTestAndDate[name][date] = &daily{}
+		}
+		byTestAndDate[name][date].counts.add(status)
+		byTestAndDate[name][date].durationMs += durationMs
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	results := []jobrunaggregatorapi.TestSummaryByPeriodRow{}
+	for name, byDate := range byTestAndDate {
+		row := jobrunaggregatorapi.TestSummaryByPeriodRow{Release: releaseName, TestName: name, FirstSampleDate: firstSampleDates[name]}
+		var averageDurations float64
+		for date, day := range byDate {
+			row.TotalTestCount += int64(day.counts.total())
+			row.TotalFailureCount += int64(day.counts.fail)
+			row.TotalFlakeCount += int64(day.counts.flake)
+			if total := day.counts.total(); total > 0 {
+				averageDurations += float64(day.durationMs) / float64(total)
+			}
+			if row.PeriodStart.IsZero() || date.Before(row.PeriodStart) {
+				row.PeriodStart = date
+			}
+			if date.After(row.PeriodEnd) {
+				row.PeriodEnd = date
+			}
+		}
+		if row.TotalTestCount <= int64(minTestCount) {
+			continue
+		}
+		row.DaysWithData = int64(len(byDate))
+		row.FailureRate = float64(row.TotalFailureCount) / float64(row.TotalTestCount)
+		row.AvgDurationMs = averageDurations / float64(len(byDate))
+		results = append(results, row)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].TotalFailureCount != results[j].TotalFailureCount {
+			return results[i].TotalFailureCount > results[j].TotalFailureCount
+		}
+		return results[i].TestName < results[j].TestName
+	})
+	return results, nil
+}
+
+// InsertJobs adds the jobs or replaces the ones that already exist
+func (c *SQLCIDataClient) InsertJobs(ctx context.Context, jobs []jobrunaggregatorapi.JobRowWithVariants) error {
+	for _, job := range jobs {
+		if _, err := c.db.ExecContext(ctx, `INSERT OR REPLACE INTO Jobs (`+sqlJobColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			job.JobName, job.GCSBucketName, job.GCSJobHistoryLocationPrefix, job.CollectDisruption, job.CollectTestRuns,
+			job.Platform, job.Architecture, job.Network, job.IPMode, job.Topology, job.Release, toSQLNullString(job.FromRelease)); err != nil {
+			return fmt.Errorf("failed to insert job %s: %w", job.JobName, err)
+		}
+	}
+	return nil
+}
+
+// InsertJobRun adds the job run or replaces it if it already exists
+func (c *SQLCIDataClient) InsertJobRun(ctx context.Context, jobRun *jobrunaggregatorapi.JobRunRow, url string) error {
+	if _, err := c.db.ExecContext(ctx, `INSERT OR REPLACE INTO JobRuns (Name, JobName, Status, URL, StartTime, EndTime, ReleaseTag, Cluster, MasterNodesUpdated)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		jobRun.Name, jobRun.JobName, jobRun.Status, url, toSQLTime(jobRun.StartTime), toSQLTime(jobRun.EndTime),
+		jobRun.ReleaseTag, jobRun.Cluster, toSQLNullString(jobRun.MasterNodesUpdated)); err != nil {
+		return fmt.Errorf("failed to insert job run %s: %w", jobRun.Name, err)
+	}
+	return nil
+}
+
+// InsertReleases adds the releases or replaces the ones that already exist
+func (c *SQLCIDataClient) InsertReleases(ctx context.Context, releases []jobrunaggregatorapi.ReleaseRow) error {
+	for _, release := range releases {
+		gaDate := sql.NullString{}
+		if release.GADate.Valid {
+			gaDate = sql.NullString{String: release.GADate.Date.String(), Valid: true}
+		}
+		if _, err := c.db.ExecContext(ctx, `INSERT OR REPLACE INTO Releases (Release, Major, Minor, GADate, DevelStartDate, Product, Patch)
+VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			release.Release, release.Major, release.Minor, gaDate, release.DevelStartDate.String(),
+			toSQLNullString(release.Product), sql.NullInt64{Int64: release.Patch.Int64, Valid: release.Patch.Valid}); err != nil {
+			return fmt.Errorf("failed to insert release %s: %w", release.Release, err)
+		}
+	}
+	return nil
+}
+
+// Inserter returns a BigQueryInserter that writes the rows the uploaders produce into the table
+func (c *SQLCIDataClient) Inserter(table string) BigQueryInserter {
+	return &sqlInserter{db: c.db, table: table}
+}
+
+type sqlInserter struct {
+	db    *sql.DB
+	table string
+}
+
+func (i *sqlInserter) Put(ctx context.Context, src interface{}) error {
+	tx, err := i.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := i.put(ctx, tx, src); err != nil {
+		_ = tx.Rollback()
+		return fmt.Errorf("failed to insert into %s: %w", i.table, err)
+	}
+	return tx.Commit()
+}
+
+func (i *sqlInserter) put(ctx context.Context, tx *sql.Tx, src interface{}) error {
+	switch rows := src.(type) {
+	case []*jobrunaggregatorapi.BackendDisruptionRow:
+		if i.table != jobrunaggregatorapi.BackendDisruptionTableName {
+			break
+		}
+		for _, row := range rows {
+			if _, err := tx.ExecContext(ctx, `INSERT OR REPLACE INTO BackendDisruption (BackendName, DisruptionSeconds, JobName, JobRunName,
+	JobRunStartTime, JobRunEndTime, Cluster, ReleaseTag, MasterNodesUpdated, JobRunStatus) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+				row.BackendName, row.DisruptionSeconds, toSQLNullString(row.JobName), row.JobRunName,
+				toSQLNullTime(row.JobRunStartTime), toSQLNullTime(row.JobRunEndTime), toSQLNullString(row.Cluster),
+				toSQLNullString(row.ReleaseTag), toSQLNullString(row.MasterNodesUpdated), toSQLNullString(row.JobRunStatus)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case []jobrunaggregatorapi.AlertRow:
+		if i.table != jobrunaggregatorapi.AlertsTableName {
+			break
+		}
+		for _, row := range rows {
+			if _, err := tx.ExecContext(ctx, `INSERT OR REPLACE INTO Alerts (Name, Namespace, Level, AlertSeconds, JobName, JobRunName,
+	JobRunStartTime, JobRunEndTime, Cluster, ReleaseTag, MasterNodesUpdated, JobRunStatus) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+				row.Name, row.Namespace, row.Level, row.AlertSeconds, toSQLNullString(row.JobName), row.JobRunName,
+				toSQLNullTime(row.JobRunStartTime), toSQLNullTime(row.JobRunEndTime), toSQLNullString(row.Cluster),
+				toSQLNullString(row.ReleaseTag), toSQLNullString(row.MasterNodesUpdated), toSQLNullString(row.JobRunStatus)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case []*jobrunaggregatorapi.TestRunRow:
+		if i.table != jobrunaggregatorapi.TestRunsTableName {
+			break
+		}
+		for _, row := range rows {
+			if _, err := tx.ExecContext(ctx, `INSERT OR REPLACE INTO TestRuns (TestSuite, TestName, TestStatus, DurationMs, JobName, JobRunName,
+	JobRunStartTime, Cluster, ReleaseTag) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+				row.TestSuite, row.TestName, row.TestStatus, row.DurationMs, row.JobName, row.JobRunName,
+				toSQLTime(row.JobRunStartTime), row.Cluster, row.ReleaseTag); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported rows %T", src)
+}
+
+// ListJobRunIDs returns the names of all job runs in the database
+func (c *SQLCIDataClient) ListJobRunIDs(ctx context.Context) (map[string]bool, error) {
+	rows, err := c.db.QueryContext(ctx, `SELECT Name FROM JobRuns`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query job runs: %w", err)
+	}
+	defer rows.Close()
+	jobRunIDs := map[string]bool{}
+	for rows.Next() {
+		var jobRunID string
+		if err := rows.Scan(&jobRunID); err != nil {
+			return nil, err
+		}
+		jobRunIDs[jobRunID] = true
+	}
+	return jobRunIDs, rows.Err()
+}
+
+// ListTestRunsForJob returns the results of every test in the last 200 runs of the job, the runs
+// ListAggregatedTestRunsForJob aggregates.
+func (c *SQLCIDataClient) ListTestRunsForJob(ctx context.Context, jobName string) ([]jobrunaggregatorapi.TestRunRow, error) {
+	rows, err := c.db.QueryContext(ctx, `
+SELECT TestSuite, TestName, TestStatus, DurationMs, JobName, JobRunName, JobRunStartTime, Cluster, ReleaseTag FROM TestRuns
+WHERE JobName = ? AND JobRunName IN (SELECT Name FROM JobRuns WHERE JobName = ? ORDER BY StartTime DESC LIMIT 200)
+ORDER BY JobRunStartTime, TestSuite, TestName`, jobName, jobName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query test runs: %w", err)
+	}
+	defer rows.Close()
+	testRuns := []jobrunaggregatorapi.TestRunRow{}
+	for rows.Next() {
+		testRun := jobrunaggregatorapi.TestRunRow{}
+		var start int64
+		if err := rows.Scan(&testRun.TestSuite, &testRun.TestName, &testRun.TestStatus, &testRun.DurationMs,
+			&testRun.JobName, &testRun.JobRunName, &start, &testRun.Cluster, &testRun.ReleaseTag); err != nil {
+			return nil, err
+		}
+		testRun.JobRunStartTime = fromSQLTime(start)
+		testRuns = append(testRuns, testRun)
+	}
+	return testRuns, rows.Err()
+}
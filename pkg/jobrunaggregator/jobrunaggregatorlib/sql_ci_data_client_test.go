@@ -0,0 +1,339 @@
+package jobrunaggregatorlib
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/civil"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+
+	"github.com/openshift/ci-tools/pkg/jobrunaggregator/jobrunaggregatorapi"
+)
+
+var sqlTestNow = time.Date(2024, 5, 20, 12, 0, 0, 0, time.UTC)
+
+func newTestSQLCIDataClient(t *testing.T) *SQLCIDataClient {
+	client, err := OpenSQLCIDataClient(filepath.Join(t.TempDir(), "ci.db"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+	client.now = func() time.Time { return sqlTestNow }
+	return client
+}
+
+func insertTestJobRun(t *testing.T, client *SQLCIDataClient, jobName, name string, start time.Time, masterNodesUpdated string) *jobrunaggregatorapi.JobRunRow {
+	jobRun := &jobrunaggregatorapi.JobRunRow{
+		Name:               name,
+		JobName:            jobName,
+		Status:             "success",
+		StartTime:          start,
+		EndTime:            start.Add(2 * time.Hour),
+		Cluster:            "build01",
+		MasterNodesUpdated: bigquery.NullString{StringVal: masterNodesUpdated, Valid: masterNodesUpdated != ""},
+	}
+	if err := client.InsertJobRun(context.Background(), jobRun, "https://prow/"+name); err != nil {
+		t.Fatalf("failed to insert job run: %v", err)
+	}
+	return jobRun
+}
+
+func disruptionRows(jobRun *jobrunaggregatorapi.JobRunRow, secondsByBackend map[string]int) []*jobrunaggregatorapi.BackendDisruptionRow {
+	var rows []*jobrunaggregatorapi.BackendDisruptionRow
+	for backend, seconds := range secondsByBackend {
+		rows = append(rows, &jobrunaggregatorapi.BackendDisruptionRow{
+			BackendName:        backend,
+			DisruptionSeconds:  seconds,
+			JobName:            bigquery.NullString{StringVal: jobRun.JobName, Valid: true},
+			JobRunName:         jobRun.Name,
+			JobRunStartTime:    bigquery.NullTimestamp{Timestamp: jobRun.StartTime, Valid: true},
+			JobRunEndTime:      bigquery.NullTimestamp{Timestamp: jobRun.EndTime, Valid: true},
+			MasterNodesUpdated: jobRun.MasterNodesUpdated,
+		})
+	}
+	return rows
+}
+
+func TestSQLCIDataClientDisruption(t *testing.T) {
+	ctx := context.Background()
+	client := newTestSQLCIDataClient(t)
+	jobs := []jobrunaggregatorapi.JobRowWithVariants{
+		{JobName: "e2e-aws", Platform: "aws", Architecture: "amd64", Network: "ovn", Topology: "ha", Release: "4.16", CollectDisruption: true},
+		{JobName: "e2e-aws-upgrade", Platform: "aws", Architecture: "amd64", Network: "ovn", Topology: "ha", Release: "4.16", FromRelease: bigquery.NullString{StringVal: "4.15", Valid: true}, CollectDisruption: true},
+	}
+	if err := client.InsertJobs(ctx, jobs); err != nil {
+		t.Fatalf("failed to insert jobs: %v", err)
+	}
+
+	inserter := client.Inserter(jobrunaggregatorapi.BackendDisruptionTableName)
+	for i, seconds := range []int{1, 2, 3, 4, 10} {
+		jobRun := insertTestJobRun(t, client, "e2e-aws", string(rune('a'+i)), sqlTestNow.Add(-time.Duration(4+i)*24*time.Hour), "")
+		if err := inserter.Put(ctx, disruptionRows(jobRun, map[string]int{"kube-api": seconds})); err != nil {
+			t.Fatalf("failed to insert disruption: %v", err)
+		}
+	}
+	// upgrades only count when the master nodes were updated
+	for i, masterNodesUpdated := range []string{"Y", "N"} {
+		jobRun := insertTestJobRun(t, client, "e2e-aws-upgrade", string(rune('u'+i)), sqlTestNow.Add(-5*24*time.Hour), masterNodesUpdated)
+		if err := inserter.Put(ctx, disruptionRows(jobRun, map[string]int{"kube-api": 7})); err != nil {
+			t.Fatalf("failed to insert disruption: %v", err)
+		}
+	}
+	// too old for the historical data
+	old := insertTestJobRun(t, client, "e2e-aws", "old", sqlTestNow.Add(-40*24*time.Hour), "")
+	if err := inserter.Put(ctx, disruptionRows(old, map[string]int{"kube-api": 100})); err != nil {
+		t.Fatalf("failed to insert disruption: %v", err)
+	}
+
+	historicalData, err := client.ListDisruptionHistoricalData(ctx)
+	if err != nil {
+		t.Fatalf("failed to list historical data: %v", err)
+	}
+	var disruptionRowsByJob []jobrunaggregatorapi.DisruptionHistoricalDataRow
+	for _, data := range historicalData {
+		disruptionRowsByJob = append(disruptionRowsByJob, *data.(*jobrunaggregatorapi.DisruptionHistoricalDataRow))
+	}
+	expectedHistoricalData := []jobrunaggregatorapi.DisruptionHistoricalDataRow{
+		{
+			BackendName: "kube-api",
+			HistoricalJobData: jobrunaggregatorapi.HistoricalJobData{
+				Release: "4.16", Platform: "aws", Architecture: "amd64", Network: "ovn", Topology: "ha", JobRuns: 5,
+			},
+			P50: "3.0", P75: "4.0", P95: "8.8", P99: "9.76",
+		},
+		{
+			BackendName: "kube-api",
+			HistoricalJobData: jobrunaggregatorapi.HistoricalJobData{
+				Release: "4.16", FromRelease: "4.15", Platform: "aws", Architecture: "amd64", Network: "ovn", Topology: "ha",
+				MasterNodesUpdated: bigquery.NullString{StringVal: "Y", Valid: true}, JobRuns: 1,
+			},
+			P50: "7.0", P75: "7.0", P95: "7.0", P99: "7.0",
+		},
+	}
+	if diff := cmp.Diff(expectedHistoricalData, disruptionRowsByJob, cmpopts.EquateApprox(0, 1e-9)); diff != "" {
+		t.Errorf("unexpected historical data: %s", diff)
+	}
+
+	count, err := client.GetBackendDisruptionRowCountByJob(ctx, "e2e-aws-upgrade", "Y")
+	if err != nil {
+		t.Fatalf("failed to count rows: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("expected 1 job run where master nodes were updated, got %d", count)
+	}
+
+	statistics, err := client.GetBackendDisruptionStatisticsByJob(ctx, "e2e-aws", "")
+	if err != nil {
+		t.Fatalf("failed to get statistics: %v", err)
+	}
+	if len(statistics) != 1 {
+		t.Fatalf("expected statistics for one backend, got %d", len(statistics))
+	}
+	// all runs between 10 and 3 days ago: 1, 2, 3, 4 and 10 seconds
+	actualStatistics := map[string]float64{"P1": statistics[0].P1, "P50": statistics[0].P50, "P99": statistics[0].P99, "Mean": statistics[0].Mean}
+	expectedStatistics := map[string]float64{"P1": 1.04, "P50": 3, "P99": 9.76, "Mean": 4}
+	if diff := cmp.Diff(expectedStatistics, actualStatistics, cmpopts.EquateApprox(0, 1e-9)); diff != "" {
+		t.Errorf("unexpected statistics: %s", diff)
+	}
+
+	endTime, err := client.GetLastJobRunEndTimeFromTable(ctx, jobrunaggregatorapi.BackendDisruptionTableName)
+	if err != nil {
+		t.Fatalf("failed to get last end time: %v", err)
+	}
+	if expected := sqlTestNow.Add(-4*24*time.Hour + 2*time.Hour); !endTime.Equal(expected) {
+		t.Errorf("expected last end time %s, got %s", expected, endTime)
+	}
+	since := sqlTestNow.Add(-6 * 24 * time.Hour)
+	uploaded, err := client.ListUploadedJobRunIDsSinceFromTable(ctx, jobrunaggregatorapi.BackendDisruptionTableName, &since)
+	if err != nil {
+		t.Fatalf("failed to list uploaded job runs: %v", err)
+	}
+	if diff := cmp.Diff(map[string]bool{"a": true, "b": true, "c": true, "u": true, "v": true}, uploaded); diff != "" {
+		t.Errorf("unexpected uploaded job runs: %s", diff)
+	}
+	if _, err := client.GetLastJobRunEndTimeFromTable(ctx, "Jobs; DROP TABLE Jobs"); err == nil {
+		t.Error("expected an error for an unknown table")
+	}
+
+	before, err := client.GetJobRunForJobNameBeforeTime(ctx, "e2e-aws", sqlTestNow.Add(-5*24*time.Hour-time.Minute))
+	if err != nil {
+		t.Fatalf("failed to get job run: %v", err)
+	}
+	after, err := client.GetJobRunForJobNameAfterTime(ctx, "e2e-aws", sqlTestNow.Add(-5*24*time.Hour-time.Minute))
+	if err != nil {
+		t.Fatalf("failed to get job run: %v", err)
+	}
+	if before != "c" || after != "b" {
+		t.Errorf("expected job runs c before and b after, got %q and %q", before, after)
+	}
+}
+
+func TestSQLCIDataClientTestRuns(t *testing.T) {
+	ctx := context.Background()
+	client := newTestSQLCIDataClient(t)
+	if err := client.InsertJobs(ctx, []jobrunaggregatorapi.JobRowWithVariants{{JobName: "e2e-aws", Release: "4.16"}}); err != nil {
+		t.Fatalf("failed to insert jobs: %v", err)
+	}
+	inserter := client.Inserter(jobrunaggregatorapi.TestRunsTableName)
+	for i, statuses := range [][]string{
+		{jobrunaggregatorapi.TestStatusPassed, jobrunaggregatorapi.TestStatusPassed},
+		{jobrunaggregatorapi.TestStatusFailed, jobrunaggregatorapi.TestStatusPassed},
+		{jobrunaggregatorapi.TestStatusFlaked, jobrunaggregatorapi.TestStatusPassed},
+		{jobrunaggregatorapi.TestStatusPassed, jobrunaggregatorapi.TestStatusPassed},
+	} {
+		jobRun := insertTestJobRun(t, client, "e2e-aws", string(rune('a'+i)), sqlTestNow.Add(-time.Duration(i+1)*24*time.Hour), "")
+		var rows []*jobrunaggregatorapi.TestRunRow
+		for j, status := range statuses {
+			rows = append(rows, &jobrunaggregatorapi.TestRunRow{
+				TestSuite:       "openshift-tests",
+				TestName:        []string{"flaky", "stable"}[j],
+				TestStatus:      status,
+				DurationMs:      1000,
+				JobName:         jobRun.JobName,
+				JobRunName:      jobRun.Name,
+				JobRunStartTime: jobRun.StartTime,
+				Cluster:         jobRun.Cluster,
+			})
+		}
+		if err := inserter.Put(ctx, rows); err != nil {
+			t.Fatalf("failed to insert test runs: %v", err)
+		}
+	}
+
+	aggregated, err := client.ListAggregatedTestRunsForJob(ctx, "ByOneWeek", "e2e-aws", sqlTestNow)
+	if err != nil {
+		t.Fatalf("failed to list aggregated test runs: %v", err)
+	}
+	suite := bigquery.NullString{StringVal: "openshift-tests", Valid: true}
+	startDate := GetUTCDay(sqlTestNow.Add(-4 * 24 * time.Hour))
+	expectedAggregated := []jobrunaggregatorapi.AggregatedTestRunRow{
+		{AggregationStartDate: startDate, TestName: "flaky", TestSuiteName: suite, JobName: "e2e-aws", PassCount: 2, FailCount: 1, FlakeCount: 1, PassPercentage: 50, WorkingPercentage: 75, DominantCluster: "build01"},
+		{AggregationStartDate: startDate, TestName: "stable", TestSuiteName: suite, JobName: "e2e-aws", PassCount: 4, PassPercentage: 100, WorkingPercentage: 100, DominantCluster: "build01"},
+	}
+	if diff := cmp.Diff(expectedAggregated, aggregated); diff != "" {
+		t.Errorf("unexpected aggregated test runs: %s", diff)
+	}
+
+	summary, err := client.ListTestSummaryByPeriod(ctx, "openshift-tests", "4.16", 2, 1)
+	if err != nil {
+		t.Fatalf("failed to list test summary: %v", err)
+	}
+	today := civil.DateOf(sqlTestNow)
+	expectedSummary := []jobrunaggregatorapi.TestSummaryByPeriodRow{
+		{Release: "4.16", TestName: "flaky", TotalTestCount: 2, TotalFailureCount: 1, TotalFlakeCount: 0, FailureRate: 0.5, AvgDurationMs: 1000, PeriodStart: today.AddDays(-2), PeriodEnd: today.AddDays(-1), DaysWithData: 2, FirstSampleDate: today.AddDays(-4)},
+		{Release: "4.16", TestName: "stable", TotalTestCount: 2, AvgDurationMs: 1000, PeriodStart: today.AddDays(-2), PeriodEnd: today.AddDays(-1), DaysWithData: 2, FirstSampleDate: today.AddDays(-4)},
+	}
+	if diff := cmp.Diff(expectedSummary, summary); diff != "" {
+		t.Errorf("unexpected test summary: %s", diff)
+	}
+
+	passRates, err := client.ListTestPassRatesByDayForJob(ctx, "e2e-aws", sqlTestNow.Add(-3*24*time.Hour-time.Hour))
+	if err != nil {
+		t.Fatalf("failed to list test pass rates: %v", err)
+	}
+	expectedPassRates := []jobrunaggregatorapi.TestPassRateByDayRow{
+		{TestSuite: "openshift-tests", TestName: "flaky", Day: today.AddDays(-3), FlakeCount: 1},
+		{TestSuite: "openshift-tests", TestName: "stable", Day: today.AddDays(-3), PassCount: 1},
+		{TestSuite: "openshift-tests", TestName: "flaky", Day: today.AddDays(-2), FailCount: 1},
+		{TestSuite: "openshift-tests", TestName: "stable", Day: today.AddDays(-2), PassCount: 1},
+		{TestSuite: "openshift-tests", TestName: "flaky", Day: today.AddDays(-1), PassCount: 1},
+		{TestSuite: "openshift-tests", TestName: "stable", Day: today.AddDays(-1), PassCount: 1},
+	}
+	if diff := cmp.Diff(expectedPassRates, passRates); diff != "" {
+		t.Errorf("unexpected test pass rates: %s", diff)
+	}
+}
+
+func TestSQLCIDataClientReleases(t *testing.T) {
+	ctx := context.Background()
+	client := newTestSQLCIDataClient(t)
+	releases := []jobrunaggregatorapi.ReleaseRow{
+		{Release: "4.15", Major: 4, Minor: 15, DevelStartDate: civil.Date{Year: 2023, Month: 10, Day: 1}, GADate: bigquery.NullDate{Date: civil.Date{Year: 2024, Month: 2, Day: 27}, Valid: true}, Product: bigquery.NullString{StringVal: "OCP", Valid: true}},
+		{Release: "4.16", Major: 4, Minor: 16, DevelStartDate: civil.Date{Year: 2024, Month: 2, Day: 1}},
+	}
+	if err := client.InsertReleases(ctx, releases); err != nil {
+		t.Fatalf("failed to insert releases: %v", err)
+	}
+	actual, err := client.ListReleases(ctx)
+	if err != nil {
+		t.Fatalf("failed to list releases: %v", err)
+	}
+	if diff := cmp.Diff([]jobrunaggregatorapi.ReleaseRow{releases[1], releases[0]}, actual); diff != "" {
+		t.Errorf("unexpected releases: %s", diff)
+	}
+}
+
+func TestSQLCIDataClientDisruptionByReleaseTag(t *testing.T) {
+	ctx := context.Background()
+	client := newTestSQLCIDataClient(t)
+	inserter := client.Inserter(jobrunaggregatorapi.BackendDisruptionTableName)
+	for i, run := range []struct {
+		releaseTag string
+		seconds    int
+	}{
+		{releaseTag: "4.16.0-0.nightly-2024-05-10-010000", seconds: 2},
+		{releaseTag: "4.16.0-0.nightly-2024-05-10-010000", seconds: 4},
+		{releaseTag: "4.16.0-0.ci-2024-05-11-020000", seconds: 9},
+		{releaseTag: "", seconds: 100},
+	} {
+		jobRun := insertTestJobRun(t, client, "e2e-aws", string(rune('a'+i)), sqlTestNow.Add(-time.Duration(9-i)*24*time.Hour), "")
+		jobRun.ReleaseTag = run.releaseTag
+		if err := client.InsertJobRun(ctx, jobRun, ""); err != nil {
+			t.Fatalf("failed to insert job run: %v", err)
+		}
+		rows := disruptionRows(jobRun, map[string]int{"kube-api": run.seconds})
+		rows[0].ReleaseTag = bigquery.NullString{StringVal: run.releaseTag, Valid: true}
+		if err := inserter.Put(ctx, rows); err != nil {
+			t.Fatalf("failed to insert disruption: %v", err)
+		}
+	}
+
+	disruption, err := client.ListBackendDisruptionByReleaseTag(ctx, "e2e-aws", sqlTestNow.Add(-30*24*time.Hour))
+	if err != nil {
+		t.Fatalf("failed to list disruption: %v", err)
+	}
+	expectedDisruption := []jobrunaggregatorapi.BackendDisruptionByReleaseTagRow{
+		{BackendName: "kube-api", ReleaseTag: "4.16.0-0.nightly-2024-05-10-010000", FirstJobRunStartTime: sqlTestNow.Add(-9 * 24 * time.Hour), JobRuns: 2, MeanDisruptionSeconds: 3},
+		{BackendName: "kube-api", ReleaseTag: "4.16.0-0.ci-2024-05-11-020000", FirstJobRunStartTime: sqlTestNow.Add(-7 * 24 * time.Hour), JobRuns: 1, MeanDisruptionSeconds: 9},
+	}
+	if diff := cmp.Diff(expectedDisruption, disruption); diff != "" {
+		t.Errorf("unexpected disruption: %s", diff)
+	}
+
+	releaseTags, err := client.ListReleaseTagsSince(ctx, time.Date(2024, 5, 11, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("failed to list release tags: %v", err)
+	}
+	expectedReleaseTags := []jobrunaggregatorapi.ReleaseTagRow{
+		{Release: "4.16", Stream: "ci", ReleaseTag: "4.16.0-0.ci-2024-05-11-020000", ReleaseTime: time.Date(2024, 5, 11, 2, 0, 0, 0, time.UTC)},
+	}
+	if diff := cmp.Diff(expectedReleaseTags, releaseTags); diff != "" {
+		t.Errorf("unexpected release tags: %s", diff)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	testCases := []struct {
+		name     string
+		values   []float64
+		p        float64
+		expected float64
+	}{
+		{name: "no values", p: 0.5},
+		{name: "single value", values: []float64{3}, p: 0.99, expected: 3},
+		{name: "exact rank", values: []float64{1, 2, 3}, p: 0.5, expected: 2},
+		{name: "interpolated", values: []float64{1, 2, 3, 4}, p: 0.5, expected: 2.5},
+		{name: "maximum", values: []float64{1, 2, 3, 4}, p: 1, expected: 4},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if actual := percentile(tc.values, tc.p); actual != tc.expected {
+				t.Errorf("expected %v, got %v", tc.expected, actual)
+			}
+		})
+	}
+}
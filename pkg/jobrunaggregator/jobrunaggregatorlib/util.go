@@ -47,11 +47,13 @@ const (
 	bigQueryLabelValueAllJobsWithVariants      = "aggregator-all-jobs-with-variants"
 	bigQueryLabelValueAllKnownAlerts           = "aggregator-all-known-alerts"
 	bigQueryLabelValueDisruptionHistoricalData = "aggregator-disruption-historical"
+	bigQueryLabelValueDisruptionByReleaseTag   = "aggregator-disruption-by-release-tag"
 	bigQueryLabelValueJobRunsSinceTime         = "aggregator-job-runs-since-time"
 	bigQueryLabelValueAllReleases              = "aggregator-all-releases"
 	bigQueryLabelValueReleaseTags              = "aggregator-release-tags"
 	bigQueryLabelValueJobRunIDsSinceTime       = "aggregator-job-run-ids-since-time"
 	bigQueryLabelValueTestSummaryByPeriod      = "aggregator-test-summary-by-period"
+	bigQueryLabelValueTestPassRatesByDay       = "aggregator-test-pass-rates-by-day"
 )
 
 var (
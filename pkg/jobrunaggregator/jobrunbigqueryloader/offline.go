@@ -0,0 +1,370 @@
+package jobrunbigqueryloader
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	prowjobv1 "sigs.k8s.io/prow/pkg/apis/prowjobs/v1"
+
+	"github.com/openshift/ci-tools/pkg/jobrunaggregator/jobrunaggregatorapi"
+	"github.com/openshift/ci-tools/pkg/jobrunaggregator/jobrunaggregatorlib"
+)
+
+// offlinePrefixes are the files besides the prowjob and junit that the uploaders read. WriteCache does not
+// mirror them.
+var offlinePrefixes = []string{"backend-disruption", "alert", "cluster-data"}
+
+type OfflineDataLoadFlags struct {
+	Authentication *jobrunaggregatorlib.GoogleAuthenticationFlags
+
+	Database     string
+	MirrorDir    string
+	JobsFile     string
+	ReleasesFile string
+	DownloadJobs []string
+	Since        time.Duration
+	GCSBucket    string
+	LogLevel     string
+}
+
+func NewOfflineDataLoadFlags() *OfflineDataLoadFlags {
+	return &OfflineDataLoadFlags{
+		Authentication: jobrunaggregatorlib.NewGoogleAuthenticationFlags(),
+		Since:          14 * 24 * time.Hour,
+	}
+}
+
+func (f *OfflineDataLoadFlags) BindFlags(fs *pflag.FlagSet) {
+	f.Authentication.BindFlags(fs)
+
+	fs.StringVar(&f.Database, "database", f.Database, "The SQLite database to load the data into, created if it does not exist")
+	fs.StringVar(&f.MirrorDir, "mirror-dir", f.MirrorDir, "The local mirror of the GCS bucket to import job runs from, laid out like logs/<job>/<job run>")
+	fs.StringVar(&f.JobsFile, "jobs-file", f.JobsFile, "The optional JSON file with the jobs and their variants, jobs that are not listed are imported without variants")
+	fs.StringVar(&f.ReleasesFile, "releases-file", f.ReleasesFile, "The optional JSON file with the releases")
+	fs.StringSliceVar(&f.DownloadJobs, "download-job", f.DownloadJobs, "The jobs to download into the mirror from GCS before importing, can be repeated")
+	fs.DurationVar(&f.Since, "since", f.Since, "Only download job runs that started within this duration")
+	fs.StringVar(&f.GCSBucket, "google-storage-bucket", "test-platform-results", "The optional GCS Bucket holding test artifacts")
+	fs.StringVar(&f.LogLevel, "log-level", "info", "Log level (trace,debug,info,warn,error) (default: info)")
+}
+
+func NewOfflineDataLoadCommand() *cobra.Command {
+	f := NewOfflineDataLoadFlags()
+
+	cmd := &cobra.Command{
+		Use:          "load-offline-data",
+		Long:         `Load job runs from a local mirror of the GCS bucket into a SQLite database, for use with --offline-database`,
+		SilenceUsage: true,
+
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.Background()
+
+			if err := f.Validate(); err != nil {
+				logrus.WithError(err).Fatal("Flags are invalid")
+			}
+			o, err := f.ToOptions(ctx)
+			if err != nil {
+				logrus.WithError(err).Fatal("Failed to build runtime options")
+			}
+			defer o.ciDataClient.Close()
+
+			if err := o.Run(ctx); err != nil {
+				logrus.WithError(err).Fatal("Command failed")
+			}
+
+			return nil
+		},
+
+		Args: jobrunaggregatorlib.NoArgs,
+	}
+
+	f.BindFlags(cmd.Flags())
+
+	return cmd
+}
+
+// Validate checks to see if the user-input is likely to produce functional runtime options
+func (f *OfflineDataLoadFlags) Validate() error {
+	if len(f.Database) == 0 {
+		return fmt.Errorf("missing --database")
+	}
+	if len(f.MirrorDir) == 0 {
+		return fmt.Errorf("missing --mirror-dir")
+	}
+	if len(f.DownloadJobs) > 0 {
+		if err := f.Authentication.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ToOptions goes from the user input to the runtime values need to run the command.
+func (f *OfflineDataLoadFlags) ToOptions(ctx context.Context) (*offlineDataLoaderOptions, error) {
+	var jobs []jobrunaggregatorapi.JobRowWithVariants
+	if len(f.JobsFile) > 0 {
+		if err := readJSONFile(f.JobsFile, &jobs); err != nil {
+			return nil, err
+		}
+	}
+	var releases []jobrunaggregatorapi.ReleaseRow
+	if len(f.ReleasesFile) > 0 {
+		if err := readJSONFile(f.ReleasesFile, &releases); err != nil {
+			return nil, err
+		}
+	}
+
+	var gcsClient jobrunaggregatorlib.CIGCSClient
+	if len(f.DownloadJobs) > 0 {
+		var err error
+		gcsClient, err = f.Authentication.NewCIGCSClient(ctx, f.GCSBucket)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	ciDataClient, err := jobrunaggregatorlib.OpenSQLCIDataClient(f.Database)
+	if err != nil {
+		return nil, err
+	}
+
+	return &offlineDataLoaderOptions{
+		ciDataClient:   ciDataClient,
+		gcsClient:      gcsClient,
+		localGCSClient: jobrunaggregatorlib.NewLocalCIGCSClient(f.MirrorDir, f.GCSBucket),
+		mirrorDir:      f.MirrorDir,
+		jobs:           jobs,
+		releases:       releases,
+		downloadJobs:   f.DownloadJobs,
+		downloadSince:  time.Now().Add(-f.Since),
+		logLevel:       f.LogLevel,
+	}, nil
+}
+
+func readJSONFile(filename string, into interface{}) error {
+	raw, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(raw, into); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", filename, err)
+	}
+	return nil
+}
+
+// offlineDataLoaderOptions
+// 1. optionally downloads job runs from GCS into the mirror
+// 2. imports the mirrored job runs that are not in the database yet, with the same uploaders as BigQuery
+type offlineDataLoaderOptions struct {
+	ciDataClient *jobrunaggregatorlib.SQLCIDataClient
+	// gcsClient is only set when job runs are downloaded
+	gcsClient      jobrunaggregatorlib.CIGCSClient
+	localGCSClient jobrunaggregatorlib.CIGCSClient
+	mirrorDir      string
+
+	jobs          []jobrunaggregatorapi.JobRowWithVariants
+	releases      []jobrunaggregatorapi.ReleaseRow
+	downloadJobs  []string
+	downloadSince time.Time
+	logLevel      string
+}
+
+func (o *offlineDataLoaderOptions) Run(ctx context.Context) error {
+	level, err := logrus.ParseLevel(o.logLevel)
+	if err != nil {
+		logrus.WithError(err).Fatal("Cannot parse log-level")
+	}
+	logrus.SetLevel(level)
+
+	if err := o.ciDataClient.InsertReleases(ctx, o.releases); err != nil {
+		return err
+	}
+	if err := o.ciDataClient.InsertJobs(ctx, o.jobs); err != nil {
+		return err
+	}
+
+	for _, jobName := range o.downloadJobs {
+		if err := o.downloadJobRuns(ctx, jobName); err != nil {
+			return fmt.Errorf("failed to download job runs of %s: %w", jobName, err)
+		}
+	}
+
+	return o.importJobRuns(ctx)
+}
+
+// downloadJobRuns mirrors the finished job runs of the job that are not mirrored yet
+func (o *offlineDataLoaderOptions) downloadJobRuns(ctx context.Context, jobName string) error {
+	gcsPrefix := path.Join("logs", jobName)
+	jobRuns, err := o.gcsClient.ReadRelatedJobRuns(ctx, jobName, gcsPrefix, "", "", func(prowJob *prowjobv1.ProwJob) bool {
+		return prowJob.Status.CompletionTime != nil && prowJob.Status.StartTime.After(o.downloadSince)
+	})
+	if err != nil {
+		return err
+	}
+	logrus.WithFields(logrus.Fields{"job": jobName, "count": len(jobRuns)}).Info("found job runs to download")
+
+	for _, jobRun := range jobRuns {
+		logger := logrus.WithFields(logrus.Fields{"job": jobName, "run": jobRun.GetJobRunID()})
+		if isMirrored(filepath.Join(o.mirrorDir, filepath.FromSlash(gcsPrefix), jobRun.GetJobRunID())) {
+			logger.Debug("skipping job run that is already mirrored")
+			continue
+		}
+		logger.Info("downloading job run")
+		if err := jobRun.GetJobRunFromGCS(ctx); err != nil {
+			return err
+		}
+		if err := jobRun.WriteCache(ctx, o.mirrorDir); err != nil {
+			return err
+		}
+		for _, prefix := range offlinePrefixes {
+			files, err := jobRun.GetOpenShiftTestsFilesWithPrefix(ctx, prefix)
+			if err != nil {
+				return err
+			}
+			for name, content := range files {
+				target := filepath.Join(o.mirrorDir, filepath.FromSlash(name))
+				if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+					return err
+				}
+				if err := os.WriteFile(target, []byte(content), 0644); err != nil {
+					return err
+				}
+			}
+		}
+		jobRun.ClearAllContent()
+	}
+	return nil
+}
+
+func isMirrored(jobRunDir string) bool {
+	for _, name := range []string{"prowjob.json", "prowjob.yaml"} {
+		if _, err := os.Stat(filepath.Join(jobRunDir, name)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// mirroredJobRun identifies a job run in the mirror
+type mirroredJobRun struct {
+	jobName  string
+	jobRunID string
+}
+
+// listMirroredJobRuns finds the job runs with a prowjob in mirrorDir/logs/<job>/<job run>
+func listMirroredJobRuns(mirrorDir string) ([]mirroredJobRun, error) {
+	logsDir := filepath.Join(mirrorDir, "logs")
+	jobs, err := os.ReadDir(logsDir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var jobRuns []mirroredJobRun
+	for _, job := range jobs {
+		if !job.IsDir() {
+			continue
+		}
+		runs, err := os.ReadDir(filepath.Join(logsDir, job.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, run := range runs {
+			if run.IsDir() && isMirrored(filepath.Join(logsDir, job.Name(), run.Name())) {
+				jobRuns = append(jobRuns, mirroredJobRun{jobName: job.Name(), jobRunID: run.Name()})
+			}
+		}
+	}
+	return jobRuns, nil
+}
+
+func (o *offlineDataLoaderOptions) importJobRuns(ctx context.Context) error {
+	mirroredJobRuns, err := listMirroredJobRuns(o.mirrorDir)
+	if err != nil {
+		return fmt.Errorf("failed to list mirrored job runs: %w", err)
+	}
+	importedJobRunIDs, err := o.ciDataClient.ListJobRunIDs(ctx)
+	if err != nil {
+		return err
+	}
+	jobs, err := o.ciDataClient.ListAllJobsWithVariants(ctx)
+	if err != nil {
+		return err
+	}
+	jobRowsMap := map[string]jobrunaggregatorapi.JobRowWithVariants{}
+	for _, job := range jobs {
+		jobRowsMap[job.JobName] = job
+	}
+
+	alertUploader, err := newAlertUploader(o.ciDataClient.Inserter(jobrunaggregatorapi.AlertsTableName), o.ciDataClient)
+	if err != nil {
+		return err
+	}
+	jobRunUploaderRegistry := JobRunUploaderRegistry{}
+	jobRunUploaderRegistry.Register("jobRunUploader", &offlineJobRunUploader{ciDataClient: o.ciDataClient})
+	jobRunUploaderRegistry.Register("disruptionUploader", newDisruptionUploader(o.ciDataClient.Inserter(jobrunaggregatorapi.BackendDisruptionTableName), o.ciDataClient))
+	jobRunUploaderRegistry.Register("alertUploader", alertUploader)
+	jobRunUploaderRegistry.Register("testRunUploader", newTestRunUploader(o.ciDataClient.Inserter(jobrunaggregatorapi.TestRunsTableName)))
+
+	var errs []error
+	imported := 0
+	for _, jobRun := range mirroredJobRuns {
+		logger := logrus.WithFields(logrus.Fields{"job": jobRun.jobName, "run": jobRun.jobRunID})
+		if importedJobRunIDs[jobRun.jobRunID] {
+			logger.Debug("skipping job run we already have imported")
+			continue
+		}
+		jobRow, ok := jobRowsMap[jobRun.jobName]
+		if !ok {
+			jobRow = jobrunaggregatorapi.JobRowWithVariants{
+				JobName:                     jobRun.jobName,
+				GCSJobHistoryLocationPrefix: path.Join("logs", jobRun.jobName),
+				CollectDisruption:           true,
+				CollectTestRuns:             true,
+			}
+			if err := o.ciDataClient.InsertJobs(ctx, []jobrunaggregatorapi.JobRowWithVariants{jobRow}); err != nil {
+				return err
+			}
+			jobRowsMap[jobRun.jobName] = jobRow
+		}
+
+		jobRunLoader := &jobRunLoaderOptions{
+			jobName:                jobRun.jobName,
+			jobRunID:               jobRun.jobRunID,
+			jobRelease:             jobRow.Release,
+			gcsClient:              o.localGCSClient,
+			jobRunUploaderRegistry: jobRunUploaderRegistry,
+			logger:                 logger,
+		}
+		if err := jobRunLoader.Run(ctx); err != nil {
+			logger.WithError(err).Error("error importing job run")
+			errs = append(errs, err)
+			continue
+		}
+		imported++
+	}
+	logrus.WithFields(logrus.Fields{"imported": imported, "errors": len(errs)}).Info("completed import")
+	return utilerrors.NewAggregate(errs)
+}
+
+// offlineJobRunUploader records the job run itself, BigQuery gets the job runs from the prowjobs instead
+type offlineJobRunUploader struct {
+	ciDataClient *jobrunaggregatorlib.SQLCIDataClient
+}
+
+func (o *offlineJobRunUploader) uploadContent(ctx context.Context, jobRun jobrunaggregatorapi.JobRunInfo,
+	jobRelease string, jobRunRow *jobrunaggregatorapi.JobRunRow, logger logrus.FieldLogger) error {
+	return o.ciDataClient.InsertJobRun(ctx, jobRunRow, jobRun.GetHumanURL())
+}
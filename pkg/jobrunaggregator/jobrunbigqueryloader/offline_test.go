@@ -0,0 +1,136 @@
+package jobrunbigqueryloader
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	prowjobv1 "sigs.k8s.io/prow/pkg/apis/prowjobs/v1"
+
+	"github.com/openshift/ci-tools/pkg/jobrunaggregator/jobrunaggregatorapi"
+	"github.com/openshift/ci-tools/pkg/jobrunaggregator/jobrunaggregatorlib"
+)
+
+const offlineTestJUnit = `<testsuites>
+  <testsuite name="openshift-tests" tests="5" failures="2">
+    <testcase name="passes" time="1.5"></testcase>
+    <testcase name="fails" time="2"><failure message="boom"></failure></testcase>
+    <testcase name="flakes" time="1"><failure message="boom"></failure></testcase>
+    <testcase name="flakes" time="1"></testcase>
+    <testcase name="skipped"><skipped message="not applicable"></skipped></testcase>
+  </testsuite>
+</testsuites>`
+
+const offlineTestDisruption = `{"BackendDisruptions": {"kube-api-new-connections": {"Name": "kube-api-new-connections", "DisruptedDuration": "3s"}}}`
+
+func writeMirroredJobRun(t *testing.T, mirrorDir, jobName, jobRunID string, start time.Time) {
+	prowJob := prowjobv1.ProwJob{
+		ObjectMeta: metav1.ObjectMeta{Name: jobRunID},
+		Spec:       prowjobv1.ProwJobSpec{Job: jobName, Cluster: "build01"},
+		Status: prowjobv1.ProwJobStatus{
+			State:          prowjobv1.SuccessState,
+			StartTime:      metav1.NewTime(start),
+			CompletionTime: &metav1.Time{Time: start.Add(2 * time.Hour)},
+			BuildID:        jobRunID,
+		},
+	}
+	raw, err := json.Marshal(prowJob)
+	if err != nil {
+		t.Fatalf("failed to marshal prowjob: %v", err)
+	}
+	jobRunDir := filepath.Join(mirrorDir, "logs", jobName, jobRunID)
+	for name, content := range map[string]string{
+		"prowjob.json":                      string(raw),
+		"artifacts/e2e/junit/junit_e2e.xml": offlineTestJUnit,
+		"artifacts/e2e/openshift-e2e-test/backend-disruption_20240101.json": offlineTestDisruption,
+	} {
+		target := filepath.Join(jobRunDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			t.Fatalf("failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(target, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+}
+
+func TestOfflineDataLoader(t *testing.T) {
+	ctx := context.Background()
+	mirrorDir := t.TempDir()
+	start := time.Now().Add(-4 * 24 * time.Hour).Truncate(time.Second)
+	writeMirroredJobRun(t, mirrorDir, "e2e-aws", "1001", start)
+
+	ciDataClient, err := jobrunaggregatorlib.OpenSQLCIDataClient(filepath.Join(t.TempDir(), "ci.db"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer ciDataClient.Close()
+	o := &offlineDataLoaderOptions{
+		ciDataClient:   ciDataClient,
+		localGCSClient: jobrunaggregatorlib.NewLocalCIGCSClient(mirrorDir, "test-platform-results"),
+		mirrorDir:      mirrorDir,
+		jobs:           []jobrunaggregatorapi.JobRowWithVariants{{JobName: "e2e-aws", Release: "4.16", Platform: "aws"}},
+		logLevel:       "error",
+	}
+	// importing twice does not duplicate the job run
+	for i := 0; i < 2; i++ {
+		if err := o.Run(ctx); err != nil {
+			t.Fatalf("failed to load data: %v", err)
+		}
+	}
+
+	jobRuns, err := ciDataClient.ListProwJobRunsSince(ctx, &time.Time{})
+	if err != nil {
+		t.Fatalf("failed to list job runs: %v", err)
+	}
+	expectedJobRuns := []*jobrunaggregatorapi.TestPlatformProwJobRow{{
+		JobName:        "e2e-aws",
+		State:          "success",
+		BuildID:        "1001",
+		Cluster:        "build01",
+		URL:            "https://prow.ci.openshift.org/view/gs/test-platform-results/logs/e2e-aws/1001",
+		StartTime:      start.UTC(),
+		CompletionTime: start.Add(2 * time.Hour).UTC(),
+	}}
+	if diff := cmp.Diff(expectedJobRuns, jobRuns); diff != "" {
+		t.Errorf("unexpected job runs: %s", diff)
+	}
+
+	count, err := ciDataClient.GetBackendDisruptionRowCountByJob(ctx, "e2e-aws", "")
+	if err != nil {
+		t.Fatalf("failed to count disruption: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("expected disruption for one job run, got %d", count)
+	}
+
+	testRuns, err := ciDataClient.ListAggregatedTestRunsForJob(ctx, "ByOneWeek", "e2e-aws", time.Now())
+	if err != nil {
+		t.Fatalf("failed to list test runs: %v", err)
+	}
+	suite := bigquery.NullString{StringVal: "openshift-tests", Valid: true}
+	expectedTestRuns := []jobrunaggregatorapi.AggregatedTestRunRow{
+		{TestName: "fails", TestSuiteName: suite, JobName: "e2e-aws", FailCount: 1, DominantCluster: "build01"},
+		{TestName: "flakes", TestSuiteName: suite, JobName: "e2e-aws", FlakeCount: 1, WorkingPercentage: 100, DominantCluster: "build01"},
+		{TestName: "passes", TestSuiteName: suite, JobName: "e2e-aws", PassCount: 1, PassPercentage: 100, WorkingPercentage: 100, DominantCluster: "build01"},
+	}
+	if diff := cmp.Diff(expectedTestRuns, testRuns, cmpopts.IgnoreFields(jobrunaggregatorapi.AggregatedTestRunRow{}, "AggregationStartDate")); diff != "" {
+		t.Errorf("unexpected test runs: %s", diff)
+	}
+
+	job, err := ciDataClient.GetJobVariants(ctx, "e2e-aws")
+	if err != nil {
+		t.Fatalf("failed to get job: %v", err)
+	}
+	if job.Release != "4.16" || job.Platform != "aws" {
+		t.Errorf("expected the variants from the jobs file, got %#v", job)
+	}
+}
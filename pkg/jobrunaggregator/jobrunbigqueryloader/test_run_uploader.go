@@ -2,9 +2,14 @@ package jobrunbigqueryloader
 
 import (
 	"context"
+	"strings"
 	"time"
 
+	"github.com/sirupsen/logrus"
+
+	"github.com/openshift/ci-tools/pkg/jobrunaggregator/jobrunaggregatorapi"
 	"github.com/openshift/ci-tools/pkg/jobrunaggregator/jobrunaggregatorlib"
+	"github.com/openshift/ci-tools/pkg/junit"
 )
 
 // pendingJobRunsUploadLister is used to find out which job runs are new for an uploader.
@@ -22,3 +27,91 @@ func (o *pendingJobRunsUploadLister) getLastUploadedJobRunEndTime(ctx context.Co
 func (o *pendingJobRunsUploadLister) listUploadedJobRunIDsSince(ctx context.Context, since *time.Time) (map[string]bool, error) {
 	return o.ciDataClient.ListUploadedJobRunIDsSinceFromTable(ctx, o.tableName, since)
 }
+
+type testRunUploader struct {
+	testRunInserter jobrunaggregatorlib.BigQueryInserter
+}
+
+func newTestRunUploader(testRunInserter jobrunaggregatorlib.BigQueryInserter) uploader {
+	return &testRunUploader{
+		testRunInserter: testRunInserter,
+	}
+}
+
+func (o *testRunUploader) uploadContent(ctx context.Context, jobRun jobrunaggregatorapi.JobRunInfo,
+	jobRelease string, jobRunRow *jobrunaggregatorapi.JobRunRow, logger logrus.FieldLogger) error {
+
+	logger.Info("uploading test results")
+	testSuites, err := jobRun.GetCombinedJUnitTestSuites(ctx)
+	if err != nil {
+		return err
+	}
+	testRunRows := getTestRunsFromJUnit(testSuites, jobRunRow)
+	if len(testRunRows) == 0 {
+		logger.Info("no test results found, skipping")
+		return nil
+	}
+	if err := o.testRunInserter.Put(ctx, testRunRows); err != nil {
+		return err
+	}
+	logger.Debug("insert complete")
+	return nil
+}
+
+// getTestRunsFromJUnit records every test once per job run: tests that passed and failed flaked.
+// Skipped tests are not recorded.
+func getTestRunsFromJUnit(testSuites *junit.TestSuites, jobRunRow *jobrunaggregatorapi.JobRunRow) []*jobrunaggregatorapi.TestRunRow {
+	type testResult struct {
+		row            *jobrunaggregatorapi.TestRunRow
+		passed, failed bool
+	}
+	results := map[[2]string]*testResult{}
+	testRunRows := []*jobrunaggregatorapi.TestRunRow{}
+
+	var addSuite func(parentSuiteNames []string, suite *junit.TestSuite)
+	addSuite = func(parentSuiteNames []string, suite *junit.TestSuite) {
+		suiteNames := append(append([]string{}, parentSuiteNames...), suite.Name)
+		suiteName := strings.Join(suiteNames, jobrunaggregatorlib.TestSuitesSeparator)
+		for _, testCase := range suite.TestCases {
+			if testCase.SkipMessage != nil {
+				continue
+			}
+			key := [2]string{suiteName, testCase.Name}
+			result, ok := results[key]
+			if !ok {
+				result = &testResult{row: &jobrunaggregatorapi.TestRunRow{
+					TestSuite:       suiteName,
+					TestName:        testCase.Name,
+					JobName:         jobRunRow.JobName,
+					JobRunName:      jobRunRow.Name,
+					JobRunStartTime: jobRunRow.StartTime,
+					Cluster:         jobRunRow.Cluster,
+					ReleaseTag:      jobRunRow.ReleaseTag,
+				}}
+				results[key] = result
+				testRunRows = append(testRunRows, result.row)
+			}
+			result.row.DurationMs += int64(testCase.Duration * 1000)
+			if testCase.FailureOutput != nil {
+				result.failed = true
+			} else {
+				result.passed = true
+			}
+			switch {
+			case result.passed && result.failed:
+				result.row.TestStatus = jobrunaggregatorapi.TestStatusFlaked
+			case result.failed:
+				result.row.TestStatus = jobrunaggregatorapi.TestStatusFailed
+			default:
+				result.row.TestStatus = jobrunaggregatorapi.TestStatusPassed
+			}
+		}
+		for _, child := range suite.Children {
+			addSuite(suiteNames, child)
+		}
+	}
+	for _, suite := range testSuites.Suites {
+		addSuite(nil, suite)
+	}
+	return testRunRows
+}
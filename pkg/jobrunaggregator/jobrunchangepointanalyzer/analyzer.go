@@ -0,0 +1,177 @@
+package jobrunchangepointanalyzer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/openshift/ci-tools/pkg/jobrunaggregator/jobrunaggregatorapi"
+	"github.com/openshift/ci-tools/pkg/jobrunaggregator/jobrunaggregatorlib"
+)
+
+// releaseTagLookBack is how long before the analyzed window payloads are read, job runs test payloads that were
+// created before the window started
+const releaseTagLookBack = 7 * 24 * time.Hour
+
+type changePointOptions struct {
+	// penalty is the cost of a change point in multiples of the noise variance and of the log of the number of payloads
+	penalty float64
+	// minSegmentLength is the fewest payloads between two change points
+	minSegmentLength int
+	// minShiftSeconds is the smallest increase of the mean that is reported
+	minShiftSeconds float64
+}
+
+// JobRunChangePointAnalyzerOptions
+// 1. reads the mean disruption per backend and payload of every job
+// 2. detects the payloads at which the mean shifts up
+// 3. lists the payloads to bisect for every shift from the release tags
+type JobRunChangePointAnalyzerOptions struct {
+	ciDataClient jobrunaggregatorlib.CIDataClient
+
+	// jobNames are the jobs to analyze, every job collecting disruption is analyzed when empty
+	jobNames   []string
+	since      time.Time
+	outputFile string
+	opts       changePointOptions
+}
+
+func (o *JobRunChangePointAnalyzerOptions) Run(ctx context.Context) error {
+	jobNames := o.jobNames
+	if len(jobNames) == 0 {
+		jobs, err := o.ciDataClient.ListAllJobs(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to list jobs: %w", err)
+		}
+		for _, job := range jobs {
+			if job.CollectDisruption {
+				jobNames = append(jobNames, job.JobName)
+			}
+		}
+	}
+
+	releaseTags, err := o.ciDataClient.ListReleaseTagsSince(ctx, o.since.Add(-releaseTagLookBack))
+	if err != nil {
+		return fmt.Errorf("failed to list release tags: %w", err)
+	}
+
+	regressions := []DisruptionRegression{}
+	for _, jobName := range jobNames {
+		rows, err := o.ciDataClient.ListBackendDisruptionByReleaseTag(ctx, jobName, o.since)
+		if err != nil {
+			return fmt.Errorf("failed to list disruption of %q: %w", jobName, err)
+		}
+		jobRegressions := findDisruptionRegressions(jobName, rows, releaseTags, o.opts)
+		logrus.Infof("found %d disruption regressions in %q", len(jobRegressions), jobName)
+		regressions = append(regressions, jobRegressions...)
+	}
+
+	out, err := json.MarshalIndent(regressions, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error formatting regressions: %w", err)
+	}
+	if err := os.WriteFile(o.outputFile, out, 0644); err != nil {
+		return fmt.Errorf("failed to write output file: %w", err)
+	}
+
+	for _, regression := range regressions {
+		fmt.Printf("%s %s: %.1fs -> %.1fs, first bad payload %s, last good payload %s, bisect %d payloads: %s\n",
+			regression.JobName, regression.BackendName, regression.MeanSecondsBefore, regression.MeanSecondsAfter,
+			regression.FirstBadReleaseTag, regression.LastGoodReleaseTag, len(regression.BisectReleaseTags), strings.Join(regression.BisectReleaseTags, ", "))
+	}
+	fmt.Printf("Successfully wrote %d disruption regressions to %s\n", len(regressions), o.outputFile)
+	return nil
+}
+
+// findDisruptionRegressions detects the lasting increases of the disruption of every backend of a job over the payloads
+// it tested. Decreases are change points too, but they are not reported since there is nothing to bisect.
+func findDisruptionRegressions(jobName string, rows []jobrunaggregatorapi.BackendDisruptionByReleaseTagRow, releaseTags []jobrunaggregatorapi.ReleaseTagRow, opts changePointOptions) []DisruptionRegression {
+	releaseTagsByName := map[string]jobrunaggregatorapi.ReleaseTagRow{}
+	for _, releaseTag := range releaseTags {
+		releaseTagsByName[releaseTag.ReleaseTag] = releaseTag
+	}
+
+	pointsByBackend := map[string][]disruptionPoint{}
+	for _, row := range rows {
+		point := disruptionPoint{releaseTag: row.ReleaseTag, time: row.FirstJobRunStartTime, seconds: row.MeanDisruptionSeconds}
+		// the job runs of a payload can start in any order, the payloads were created in order
+		if releaseTag, ok := releaseTagsByName[row.ReleaseTag]; ok && !releaseTag.ReleaseTime.IsZero() {
+			point.time = releaseTag.ReleaseTime
+		}
+		pointsByBackend[row.BackendName] = append(pointsByBackend[row.BackendName], point)
+	}
+
+	var regressions []DisruptionRegression
+	for _, backendName := range sets.List(sets.KeySet(pointsByBackend)) {
+		points := pointsByBackend[backendName]
+		sort.SliceStable(points, func(i, j int) bool { return points[i].time.Before(points[j].time) })
+		values := make([]float64, 0, len(points))
+		for _, point := range points {
+			values = append(values, point.seconds)
+		}
+
+		variance := noiseVariance(values)
+		if variance == 0 {
+			continue
+		}
+		penalty := opts.penalty * variance * math.Log(float64(len(values)))
+		changePoints := detectChangePoints(values, penalty, opts.minSegmentLength)
+
+		// the segments are bounded by the change points and the ends of the series
+		bounds := append(append([]int{0}, changePoints...), len(values))
+		for i := 1; i < len(bounds)-1; i++ {
+			start, changePoint, end := bounds[i-1], bounds[i], bounds[i+1]
+			before, after := mean(values[start:changePoint]), mean(values[changePoint:end])
+			if after-before < opts.minShiftSeconds {
+				continue
+			}
+			lastGood, firstBad := points[changePoint-1], points[changePoint]
+			regressions = append(regressions, DisruptionRegression{
+				JobName:            jobName,
+				BackendName:        backendName,
+				LastGoodReleaseTag: lastGood.releaseTag,
+				LastGoodTime:       lastGood.time,
+				FirstBadReleaseTag: firstBad.releaseTag,
+				FirstBadTime:       firstBad.time,
+				BisectReleaseTags:  bisectReleaseTags(releaseTagsByName, releaseTags, lastGood, firstBad),
+				MeanSecondsBefore:  before,
+				MeanSecondsAfter:   after,
+				PayloadsBefore:     changePoint - start,
+				PayloadsAfter:      end - changePoint,
+			})
+		}
+	}
+	return regressions
+}
+
+// bisectReleaseTags lists the payloads of the stream of the first bad payload that were created after the last good
+// one, up to and including the first bad one. releaseTags must be ordered by release time.
+func bisectReleaseTags(releaseTagsByName map[string]jobrunaggregatorapi.ReleaseTagRow, releaseTags []jobrunaggregatorapi.ReleaseTagRow, lastGood, firstBad disruptionPoint) []string {
+	firstBadTag, ok := releaseTagsByName[firstBad.releaseTag]
+	if !ok {
+		return []string{firstBad.releaseTag}
+	}
+	var tags []string
+	for _, releaseTag := range releaseTags {
+		if releaseTag.Release != firstBadTag.Release || releaseTag.Stream != firstBadTag.Stream || releaseTag.Architecture != firstBadTag.Architecture {
+			continue
+		}
+		if !releaseTag.ReleaseTime.After(lastGood.time) || releaseTag.ReleaseTime.After(firstBad.time) {
+			continue
+		}
+		tags = append(tags, releaseTag.ReleaseTag)
+	}
+	if len(tags) == 0 {
+		return []string{firstBad.releaseTag}
+	}
+	return tags
+}
@@ -0,0 +1,115 @@
+package jobrunchangepointanalyzer
+
+import (
+	"math"
+	"sort"
+)
+
+// detectChangePoints finds the indexes at which the mean of the values shifts using PELT (pruned exact linear time).
+// The cost of a segment is its squared error around its mean, and every change point costs penalty. No segment is
+// shorter than minSegmentLength. The returned indexes are the first value of every segment but the first.
+func detectChangePoints(values []float64, penalty float64, minSegmentLength int) []int {
+	n := len(values)
+	if minSegmentLength < 1 {
+		minSegmentLength = 1
+	}
+	if n < 2*minSegmentLength {
+		return nil
+	}
+
+	// prefix sums make the cost of any segment O(1)
+	sums := make([]float64, n+1)
+	squares := make([]float64, n+1)
+	for i, v := range values {
+		sums[i+1] = sums[i] + v
+		squares[i+1] = squares[i] + v*v
+	}
+	cost := func(start, end int) float64 {
+		length := float64(end - start)
+		sum := sums[end] - sums[start]
+		return squares[end] - squares[start] - sum*sum/length
+	}
+
+	// best[t] is the cost of the best segmentation of values[:t], previous[t] the start of its last segment
+	best := make([]float64, n+1)
+	previous := make([]int, n+1)
+	best[0] = -penalty
+	for t := 1; t <= n; t++ {
+		best[t] = math.Inf(1)
+	}
+	candidates := []int{0}
+	for t := minSegmentLength; t <= n; t++ {
+		for _, s := range candidates {
+			if t-s < minSegmentLength {
+				continue
+			}
+			if c := best[s] + cost(s, t) + penalty; c < best[t] {
+				best[t] = c
+				previous[t] = s
+			}
+		}
+		// a candidate that is already worse than the best segmentation can never become the best
+		pruned := candidates[:0]
+		for _, s := range candidates {
+			if t-s < minSegmentLength || best[s]+cost(s, t) <= best[t] {
+				pruned = append(pruned, s)
+			}
+		}
+		candidates = pruned
+		if t+minSegmentLength <= n && !math.IsInf(best[t], 1) {
+			candidates = append(candidates, t)
+		}
+	}
+
+	var changePoints []int
+	for t := previous[n]; t > 0; t = previous[t] {
+		changePoints = append(changePoints, t)
+	}
+	sort.Ints(changePoints)
+	return changePoints
+}
+
+// noiseVariance estimates the variance of the noise around the segment means from the differences of consecutive
+// values, which a shift in the mean barely moves. It falls back to the sample variance when the median difference
+// is zero, as happens when most payloads have no disruption.
+func noiseVariance(values []float64) float64 {
+	if len(values) < 2 {
+		return 0
+	}
+	differences := make([]float64, 0, len(values)-1)
+	for i := 1; i < len(values); i++ {
+		differences = append(differences, math.Abs(values[i]-values[i-1]))
+	}
+	sort.Float64s(differences)
+	median := differences[len(differences)/2]
+	if len(differences)%2 == 0 {
+		median = (differences[len(differences)/2-1] + differences[len(differences)/2]) / 2
+	}
+	// the median absolute deviation of a normal distribution is 0.6745 standard deviations, and the difference of two
+	// values has twice the variance of one
+	if sigma := median / 0.6745 / math.Sqrt2; sigma > 0 {
+		return sigma * sigma
+	}
+
+	var mean float64
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
+	var variance float64
+	for _, v := range values {
+		variance += (v - mean) * (v - mean)
+	}
+	return variance / float64(len(values)-1)
+}
+
+func mean(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
@@ -0,0 +1,128 @@
+package jobrunchangepointanalyzer
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/openshift/ci-tools/pkg/jobrunaggregator/jobrunaggregatorapi"
+)
+
+// noisy adds a deterministic noise of up to one second to the value
+func noisy(i int, value float64) float64 {
+	return value + math.Sin(float64(i)*1.7)
+}
+
+func TestDetectChangePoints(t *testing.T) {
+	testCases := []struct {
+		name     string
+		values   func() []float64
+		expected []int
+	}{
+		{
+			name: "flat",
+			values: func() []float64 {
+				var values []float64
+				for i := 0; i < 30; i++ {
+					values = append(values, noisy(i, 5))
+				}
+				return values
+			},
+		},
+		{
+			name: "one step up",
+			values: func() []float64 {
+				var values []float64
+				for i := 0; i < 30; i++ {
+					level := 5.0
+					if i >= 18 {
+						level = 15
+					}
+					values = append(values, noisy(i, level))
+				}
+				return values
+			},
+			expected: []int{18},
+		},
+		{
+			name: "up and back down",
+			values: func() []float64 {
+				var values []float64
+				for i := 0; i < 40; i++ {
+					level := 5.0
+					if i >= 10 && i < 25 {
+						level = 20
+					}
+					values = append(values, noisy(i, level))
+				}
+				return values
+			},
+			expected: []int{10, 25},
+		},
+		{
+			name: "too short",
+			values: func() []float64 {
+				return []float64{1, 1, 1, 20, 20}
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			values := tc.values()
+			penalty := 2 * noiseVariance(values) * math.Log(float64(len(values)))
+			if diff := cmp.Diff(tc.expected, detectChangePoints(values, penalty, 3)); diff != "" {
+				t.Errorf("unexpected change points (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestFindDisruptionRegressions(t *testing.T) {
+	start := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	tag := func(i int) string {
+		return fmt.Sprintf("4.16.0-0.nightly-%s", start.Add(time.Duration(i)*time.Hour).Format("2006-01-02-150405"))
+	}
+
+	var rows []jobrunaggregatorapi.BackendDisruptionByReleaseTagRow
+	var releaseTags []jobrunaggregatorapi.ReleaseTagRow
+	for i := 0; i < 40; i++ {
+		releaseTags = append(releaseTags, jobrunaggregatorapi.ReleaseTagRow{
+			Release: "4.16", Stream: "nightly", Architecture: "amd64", ReleaseTag: tag(i), ReleaseTime: start.Add(time.Duration(i) * time.Hour),
+		})
+		// the job only tests every other payload
+		if i%2 == 1 {
+			continue
+		}
+		kubeAPI := noisy(i, 3)
+		if i >= 24 {
+			kubeAPI = noisy(i, 12)
+		}
+		// an improvement is not a regression
+		ingress := noisy(i, 10)
+		if i >= 20 {
+			ingress = noisy(i, 2)
+		}
+		rows = append(rows,
+			jobrunaggregatorapi.BackendDisruptionByReleaseTagRow{BackendName: "kube-api", ReleaseTag: tag(i), FirstJobRunStartTime: start.Add(time.Duration(i)*time.Hour + time.Minute), JobRuns: 10, MeanDisruptionSeconds: kubeAPI},
+			jobrunaggregatorapi.BackendDisruptionByReleaseTagRow{BackendName: "ingress", ReleaseTag: tag(i), FirstJobRunStartTime: start.Add(time.Duration(i)*time.Hour + time.Minute), JobRuns: 10, MeanDisruptionSeconds: ingress},
+		)
+	}
+
+	regressions := findDisruptionRegressions("e2e-aws", rows, releaseTags, changePointOptions{penalty: 2, minSegmentLength: 3, minShiftSeconds: 1})
+	if len(regressions) != 1 {
+		t.Fatalf("expected one regression, got %#v", regressions)
+	}
+	regression := regressions[0]
+	if regression.BackendName != "kube-api" || regression.LastGoodReleaseTag != tag(22) || regression.FirstBadReleaseTag != tag(24) {
+		t.Errorf("unexpected regression %s from %s to %s", regression.BackendName, regression.LastGoodReleaseTag, regression.FirstBadReleaseTag)
+	}
+	if diff := cmp.Diff([]string{tag(23), tag(24)}, regression.BisectReleaseTags); diff != "" {
+		t.Errorf("unexpected payloads to bisect (-want +got):\n%s", diff)
+	}
+	if regression.MeanSecondsAfter-regression.MeanSecondsBefore < 7 {
+		t.Errorf("expected the mean to increase by about 9s, got %.1fs -> %.1fs", regression.MeanSecondsBefore, regression.MeanSecondsAfter)
+	}
+}
@@ -0,0 +1,152 @@
+package jobrunchangepointanalyzer
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/openshift/ci-tools/pkg/jobrunaggregator/jobrunaggregatorlib"
+)
+
+type JobRunChangePointAnalyzerFlags struct {
+	DataCoordinates *jobrunaggregatorlib.BigQueryDataCoordinates
+	Authentication  *jobrunaggregatorlib.GoogleAuthenticationFlags
+	Offline         *jobrunaggregatorlib.OfflineDataFlags
+
+	JobNames         []string
+	LookBack         time.Duration
+	OutputFile       string
+	Penalty          float64
+	MinSegmentLength int
+	MinShiftSeconds  float64
+}
+
+func NewJobRunChangePointAnalyzerFlags() *JobRunChangePointAnalyzerFlags {
+	return &JobRunChangePointAnalyzerFlags{
+		DataCoordinates: jobrunaggregatorlib.NewBigQueryDataCoordinates(),
+		Authentication:  jobrunaggregatorlib.NewGoogleAuthenticationFlags(),
+		Offline:         jobrunaggregatorlib.NewOfflineDataFlags(),
+
+		LookBack:         30 * 24 * time.Hour,
+		OutputFile:       "disruption-regressions.json",
+		Penalty:          2,
+		MinSegmentLength: 5,
+		MinShiftSeconds:  1,
+	}
+}
+
+func (f *JobRunChangePointAnalyzerFlags) BindFlags(fs *pflag.FlagSet) {
+	f.DataCoordinates.BindFlags(fs)
+	f.Authentication.BindFlags(fs)
+	f.Offline.BindFlags(fs)
+
+	fs.StringArrayVar(&f.JobNames, "job", f.JobNames, "The name of a job to analyze, can be specified multiple times. Omit to analyze every job that collects disruption")
+	fs.DurationVar(&f.LookBack, "look-back", f.LookBack, "How far back to read the disruption of the job runs")
+	fs.StringVar(&f.OutputFile, "output-file", f.OutputFile, "The file to write the JSON disruption regressions to")
+	fs.Float64Var(&f.Penalty, "penalty", f.Penalty, "The cost of a change point, in multiples of the noise variance and of the log of the number of payloads. Higher values detect fewer shifts")
+	fs.IntVar(&f.MinSegmentLength, "min-segment-length", f.MinSegmentLength, "The fewest payloads between two change points")
+	fs.Float64Var(&f.MinShiftSeconds, "min-shift-seconds", f.MinShiftSeconds, "The smallest increase of the mean disruption that is reported")
+}
+
+func NewJobRunChangePointAnalyzerCommand() *cobra.Command {
+	f := NewJobRunChangePointAnalyzerFlags()
+
+	cmd := &cobra.Command{
+		Use: "analyze-disruption-change-points",
+		Long: `Detect lasting increases of the disruption of every backend over the payloads a job tested.
+The disruption checks of analyze-job-runs compare against a baseline that slowly absorbs regressions. This runs
+change-point detection (PELT) over the mean disruption per payload and, for every increase, reports the first bad
+payload and the payloads to bisect from the release tags.`,
+		SilenceUsage: true,
+
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.Background()
+
+			if err := f.Validate(); err != nil {
+				logrus.WithError(err).Fatal("Flags are invalid")
+			}
+			o, err := f.ToOptions(ctx)
+			if err != nil {
+				logrus.WithError(err).Fatal("Failed to build runtime options")
+			}
+
+			if err := o.Run(ctx); err != nil {
+				logrus.WithError(err).Fatal("Command failed")
+			}
+
+			return nil
+		},
+
+		Args: jobrunaggregatorlib.NoArgs,
+	}
+
+	f.BindFlags(cmd.Flags())
+
+	return cmd
+}
+
+// Validate checks to see if the user-input is likely to produce functional runtime options
+func (f *JobRunChangePointAnalyzerFlags) Validate() error {
+	if err := f.Offline.Validate(); err != nil {
+		return err
+	}
+	if !f.Offline.Enabled() {
+		if err := f.DataCoordinates.Validate(); err != nil {
+			return err
+		}
+		if err := f.Authentication.Validate(); err != nil {
+			return err
+		}
+	}
+	if f.LookBack <= 0 {
+		return fmt.Errorf("--look-back must be positive")
+	}
+	if len(f.OutputFile) == 0 {
+		return fmt.Errorf("missing --output-file")
+	}
+	if f.Penalty <= 0 {
+		return fmt.Errorf("--penalty must be positive")
+	}
+	if f.MinSegmentLength < 1 {
+		return fmt.Errorf("--min-segment-length must be at least 1")
+	}
+	if f.MinShiftSeconds < 0 {
+		return fmt.Errorf("--min-shift-seconds must not be negative")
+	}
+	return nil
+}
+
+func (f *JobRunChangePointAnalyzerFlags) ToOptions(ctx context.Context) (*JobRunChangePointAnalyzerOptions, error) {
+	var ciDataClient jobrunaggregatorlib.CIDataClient
+	if f.Offline.Enabled() {
+		offlineClient, err := f.Offline.NewCIDataClient()
+		if err != nil {
+			return nil, err
+		}
+		ciDataClient = offlineClient
+	} else {
+		bigQueryClient, err := f.Authentication.NewBigQueryClient(ctx, f.DataCoordinates.ProjectID)
+		if err != nil {
+			return nil, err
+		}
+		ciDataClient = jobrunaggregatorlib.NewRetryingCIDataClient(
+			jobrunaggregatorlib.NewCIDataClient(*f.DataCoordinates, bigQueryClient),
+		)
+	}
+
+	return &JobRunChangePointAnalyzerOptions{
+		ciDataClient: ciDataClient,
+		jobNames:     f.JobNames,
+		since:        time.Now().Add(-f.LookBack),
+		outputFile:   f.OutputFile,
+		opts: changePointOptions{
+			penalty:          f.Penalty,
+			minSegmentLength: f.MinSegmentLength,
+			minShiftSeconds:  f.MinShiftSeconds,
+		},
+	}, nil
+}
@@ -0,0 +1,34 @@
+package jobrunchangepointanalyzer
+
+import (
+	"time"
+)
+
+// DisruptionRegression is a lasting increase in the disruption of a backend in a job
+type DisruptionRegression struct {
+	JobName     string `json:"jobName"`
+	BackendName string `json:"backendName"`
+
+	// LastGoodReleaseTag is the last payload the job tested before the increase
+	LastGoodReleaseTag string    `json:"lastGoodReleaseTag"`
+	LastGoodTime       time.Time `json:"lastGoodTime"`
+	// FirstBadReleaseTag is the first payload the job tested after the increase
+	FirstBadReleaseTag string    `json:"firstBadReleaseTag"`
+	FirstBadTime       time.Time `json:"firstBadTime"`
+	// BisectReleaseTags are the payloads after LastGoodReleaseTag up to and including FirstBadReleaseTag, one of
+	// them introduced the increase. Only the FirstBadReleaseTag is listed when the payloads are not known.
+	BisectReleaseTags []string `json:"bisectReleaseTags"`
+
+	MeanSecondsBefore float64 `json:"meanSecondsBefore"`
+	MeanSecondsAfter  float64 `json:"meanSecondsAfter"`
+	// PayloadsBefore and PayloadsAfter are the number of payloads in the segments the means are computed from
+	PayloadsBefore int `json:"payloadsBefore"`
+	PayloadsAfter  int `json:"payloadsAfter"`
+}
+
+// disruptionPoint is the mean disruption of a backend in the job runs that tested one payload
+type disruptionPoint struct {
+	releaseTag string
+	time       time.Time
+	seconds    float64
+}
@@ -0,0 +1,126 @@
+package jobrunflakeanalyzer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/openshift/ci-tools/pkg/jobrunaggregator/jobrunaggregatorapi"
+	"github.com/openshift/ci-tools/pkg/jobrunaggregator/jobrunaggregatorlib"
+)
+
+// testRunLister is implemented by the CI data clients that keep the individual test runs, like the offline
+// SQLite client. The durations of the test runs are used to detect bimodal timing.
+type testRunLister interface {
+	ListTestRunsForJob(ctx context.Context, jobName string) ([]jobrunaggregatorapi.TestRunRow, error)
+}
+
+// JobRunFlakeAnalyzerOptions
+// 1. reads the aggregated test runs of every job
+// 2. scores how flaky every test is
+// 3. writes the scores as JSON and the tests recommended for quarantine as a ranked list
+type JobRunFlakeAnalyzerOptions struct {
+	ciDataClient jobrunaggregatorlib.CIDataClient
+
+	// jobNames are the jobs to analyze, every job is analyzed when empty
+	jobNames       []string
+	startDay       time.Time
+	outputFile     string
+	quarantineFile string
+	scoringOptions flakeScoringOptions
+}
+
+func (o *JobRunFlakeAnalyzerOptions) Run(ctx context.Context) error {
+	jobNames := o.jobNames
+	if len(jobNames) == 0 {
+		jobs, err := o.ciDataClient.ListAllJobs(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to list jobs: %w", err)
+		}
+		for _, job := range jobs {
+			jobNames = append(jobNames, job.JobName)
+		}
+	}
+
+	var rows []jobrunaggregatorapi.AggregatedTestRunRow
+	durations := map[testKey][]float64{}
+	for _, jobName := range jobNames {
+		jobRows, err := o.ciDataClient.ListAggregatedTestRunsForJob(ctx, "ByOneWeek", jobName, o.startDay)
+		if err != nil {
+			return fmt.Errorf("failed to list aggregated test runs for %q: %w", jobName, err)
+		}
+		rows = append(rows, jobRows...)
+
+		lister, ok := o.ciDataClient.(testRunLister)
+		if !ok {
+			continue
+		}
+		testRuns, err := lister.ListTestRunsForJob(ctx, jobName)
+		if err != nil {
+			return fmt.Errorf("failed to list test runs for %q: %w", jobName, err)
+		}
+		for _, testRun := range testRuns {
+			// the duration of tests that were not run or not reported carries no information
+			if testRun.DurationMs <= 0 {
+				continue
+			}
+			key := testKey{jobName: testRun.JobName, testSuiteName: testRun.TestSuite, testName: testRun.TestName}
+			durations[key] = append(durations[key], float64(testRun.DurationMs))
+		}
+	}
+	logrus.Infof("scoring %d tests of %d jobs", len(rows), len(jobNames))
+
+	report := jobrunaggregatorapi.FlakeReport{
+		GeneratedAt: time.Now().UTC(),
+		Tests:       scoreTestFlakes(rows, durations, o.scoringOptions),
+	}
+	out, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error formatting flake report: %w", err)
+	}
+	if err := os.WriteFile(o.outputFile, out, 0644); err != nil {
+		return fmt.Errorf("failed to write output file: %w", err)
+	}
+
+	quarantineList := formatQuarantineList(report.Tests)
+	if len(o.quarantineFile) > 0 {
+		if err := os.WriteFile(o.quarantineFile, []byte(quarantineList), 0644); err != nil {
+			return fmt.Errorf("failed to write quarantine file: %w", err)
+		}
+	}
+	fmt.Print(quarantineList)
+
+	return nil
+}
+
+// formatQuarantineList lists the tests recommended for quarantine in the order of the scores
+func formatQuarantineList(scores []jobrunaggregatorapi.TestFlakeScore) string {
+	buf := &bytes.Buffer{}
+	w := tabwriter.NewWriter(buf, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "RANK\tSCORE\tFAILURE RATE\tRUNS\tSIGNALS\tJOB\tTEST")
+	rank := 0
+	for _, score := range scores {
+		if !score.Quarantine {
+			continue
+		}
+		rank++
+		signals := strings.Join(score.Signals, ",")
+		if len(signals) == 0 {
+			signals = "-"
+		}
+		fmt.Fprintf(w, "%d\t%.3f\t%.1f%%-%.1f%%\t%d\t%s\t%s\t%s\n",
+			rank, score.Score, score.InstabilityLowerBound*100, score.InstabilityUpperBound*100, score.Runs, signals, score.JobName, score.TestName)
+	}
+	_ = w.Flush()
+	if rank == 0 {
+		return "No tests are recommended for quarantine\n"
+	}
+	return buf.String()
+}
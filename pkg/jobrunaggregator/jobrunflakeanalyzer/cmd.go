@@ -0,0 +1,160 @@
+package jobrunflakeanalyzer
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/openshift/ci-tools/pkg/jobrunaggregator/jobrunaggregatorlib"
+)
+
+type JobRunFlakeAnalyzerFlags struct {
+	DataCoordinates *jobrunaggregatorlib.BigQueryDataCoordinates
+	Authentication  *jobrunaggregatorlib.GoogleAuthenticationFlags
+	Offline         *jobrunaggregatorlib.OfflineDataFlags
+
+	JobNames          []string
+	StartDayString    string
+	OutputFile        string
+	QuarantineFile    string
+	MinRuns           int
+	MinInstability    float64
+	BrokenWorkingRate float64
+}
+
+func NewJobRunFlakeAnalyzerFlags() *JobRunFlakeAnalyzerFlags {
+	return &JobRunFlakeAnalyzerFlags{
+		DataCoordinates: jobrunaggregatorlib.NewBigQueryDataCoordinates(),
+		Authentication:  jobrunaggregatorlib.NewGoogleAuthenticationFlags(),
+		Offline:         jobrunaggregatorlib.NewOfflineDataFlags(),
+
+		StartDayString:    time.Now().Format(time.DateOnly),
+		OutputFile:        "flake-report.json",
+		QuarantineFile:    "quarantine.txt",
+		MinRuns:           20,
+		MinInstability:    0.05,
+		BrokenWorkingRate: 0.5,
+	}
+}
+
+func (f *JobRunFlakeAnalyzerFlags) BindFlags(fs *pflag.FlagSet) {
+	f.DataCoordinates.BindFlags(fs)
+	f.Authentication.BindFlags(fs)
+	f.Offline.BindFlags(fs)
+
+	fs.StringArrayVar(&f.JobNames, "job", f.JobNames, "The name of a job to analyze, can be specified multiple times. Omit to analyze every job")
+	fs.StringVar(&f.StartDayString, "start-day", f.StartDayString, fmt.Sprintf("The day the aggregated test runs are read for, in %s", time.DateOnly))
+	fs.StringVar(&f.OutputFile, "output-file", f.OutputFile, "The file to write the JSON flake report to, this is the format Sippy and analyze-job-runs --flake-report read")
+	fs.StringVar(&f.QuarantineFile, "quarantine-file", f.QuarantineFile, "The file to write the ranked list of tests recommended for quarantine to")
+	fs.IntVar(&f.MinRuns, "min-runs", f.MinRuns, "The minimum number of runs of a test to classify it")
+	fs.Float64Var(&f.MinInstability, "min-instability", f.MinInstability, "The lower bound of the 95% Wilson interval of the failure rate above which a test is flaky, between 0 and 1")
+	fs.Float64Var(&f.BrokenWorkingRate, "broken-working-rate", f.BrokenWorkingRate, "The upper bound of the 95% Wilson interval of the working rate below which a test is broken rather than flaky, between 0 and 1")
+}
+
+func NewJobRunFlakeAnalyzerCommand() *cobra.Command {
+	f := NewJobRunFlakeAnalyzerFlags()
+
+	cmd := &cobra.Command{
+		Use: "analyze-flakes",
+		Long: `Score how flaky every test of the aggregated test runs of jobs is and recommend the tests to quarantine.
+The score is the lower bound of the Wilson interval of the failure rate, weighted up when most failures passed on
+retry or when the durations of the test suggest two modes. Tests failing too often to be flaky are classified as
+broken and are never recommended for quarantine.`,
+		SilenceUsage: true,
+
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.Background()
+
+			if err := f.Validate(); err != nil {
+				logrus.WithError(err).Fatal("Flags are invalid")
+			}
+			o, err := f.ToOptions(ctx)
+			if err != nil {
+				logrus.WithError(err).Fatal("Failed to build runtime options")
+			}
+
+			if err := o.Run(ctx); err != nil {
+				logrus.WithError(err).Fatal("Command failed")
+			}
+
+			return nil
+		},
+
+		Args: jobrunaggregatorlib.NoArgs,
+	}
+
+	f.BindFlags(cmd.Flags())
+
+	return cmd
+}
+
+// Validate checks to see if the user-input is likely to produce functional runtime options
+func (f *JobRunFlakeAnalyzerFlags) Validate() error {
+	if err := f.Offline.Validate(); err != nil {
+		return err
+	}
+	if !f.Offline.Enabled() {
+		if err := f.DataCoordinates.Validate(); err != nil {
+			return err
+		}
+		if err := f.Authentication.Validate(); err != nil {
+			return err
+		}
+	}
+	if _, err := time.Parse(time.DateOnly, f.StartDayString); err != nil {
+		return fmt.Errorf("invalid --start-day: %w", err)
+	}
+	if len(f.OutputFile) == 0 {
+		return fmt.Errorf("missing --output-file")
+	}
+	if f.MinRuns < 1 {
+		return fmt.Errorf("--min-runs must be at least 1")
+	}
+	if f.MinInstability <= 0 || f.MinInstability >= 1 {
+		return fmt.Errorf("--min-instability must be between 0 and 1")
+	}
+	if f.BrokenWorkingRate < 0 || f.BrokenWorkingRate >= 1 {
+		return fmt.Errorf("--broken-working-rate must be between 0 and 1")
+	}
+	return nil
+}
+
+func (f *JobRunFlakeAnalyzerFlags) ToOptions(ctx context.Context) (*JobRunFlakeAnalyzerOptions, error) {
+	startDay, err := time.Parse(time.DateOnly, f.StartDayString)
+	if err != nil {
+		return nil, err
+	}
+
+	var ciDataClient jobrunaggregatorlib.CIDataClient
+	if f.Offline.Enabled() {
+		ciDataClient, err = f.Offline.NewCIDataClient()
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		bigQueryClient, err := f.Authentication.NewBigQueryClient(ctx, f.DataCoordinates.ProjectID)
+		if err != nil {
+			return nil, err
+		}
+		ciDataClient = jobrunaggregatorlib.NewRetryingCIDataClient(
+			jobrunaggregatorlib.NewCIDataClient(*f.DataCoordinates, bigQueryClient),
+		)
+	}
+
+	return &JobRunFlakeAnalyzerOptions{
+		ciDataClient:   ciDataClient,
+		jobNames:       f.JobNames,
+		startDay:       startDay,
+		outputFile:     f.OutputFile,
+		quarantineFile: f.QuarantineFile,
+		scoringOptions: flakeScoringOptions{
+			minRuns:           f.MinRuns,
+			minInstability:    f.MinInstability,
+			brokenWorkingRate: f.BrokenWorkingRate,
+		},
+	}, nil
+}
@@ -0,0 +1,138 @@
+package jobrunflakeanalyzer
+
+import (
+	"math"
+	"sort"
+
+	"github.com/openshift/ci-tools/pkg/jobrunaggregator/jobrunaggregatorapi"
+)
+
+const (
+	// wilsonZ is the z-score of the 95% confidence interval
+	wilsonZ = 1.96
+	// bimodalityThreshold is the bimodality coefficient of a uniform distribution, higher values suggest two modes
+	bimodalityThreshold = 5.0 / 9.0
+
+	// the signals make it more likely that the failures are flakes rather than a product bug, they raise the score
+	passAfterRetryWeight = 0.5
+	bimodalTimingWeight  = 0.25
+)
+
+// wilsonInterval returns the Wilson score interval of the share of successes in attempts. It behaves better than
+// the normal approximation for the small samples and extreme rates of test results.
+func wilsonInterval(successes, attempts int, z float64) (float64, float64) {
+	if attempts == 0 {
+		return 0, 1
+	}
+	n := float64(attempts)
+	p := float64(successes) / n
+	denominator := 1 + z*z/n
+	center := (p + z*z/(2*n)) / denominator
+	halfWidth := z * math.Sqrt(p*(1-p)/n+z*z/(4*n*n)) / denominator
+	return math.Max(0, center-halfWidth), math.Min(1, center+halfWidth)
+}
+
+// bimodalityCoefficient is Sarle's bimodality coefficient of the values with the sample skewness and kurtosis
+func bimodalityCoefficient(values []float64) float64 {
+	n := float64(len(values))
+	if len(values) < 4 {
+		return 0
+	}
+	var mean float64
+	for _, v := range values {
+		mean += v
+	}
+	mean /= n
+	var m2, m3, m4 float64
+	for _, v := range values {
+		d := v - mean
+		m2 += d * d
+		m3 += d * d * d
+		m4 += d * d * d * d
+	}
+	m2, m3, m4 = m2/n, m3/n, m4/n
+	if m2 == 0 {
+		return 0
+	}
+	skewness := m3 / math.Pow(m2, 1.5) * math.Sqrt(n*(n-1)) / (n - 2)
+	excessKurtosis := ((n+1)*(m4/(m2*m2)-3) + 6) * (n - 1) / ((n - 2) * (n - 3))
+	return (skewness*skewness + 1) / (excessKurtosis + 3*(n-1)*(n-1)/((n-2)*(n-3)))
+}
+
+type flakeScoringOptions struct {
+	// minRuns is the number of runs required to classify a test
+	minRuns int
+	// minInstability is the lower bound of the instability interval above which a test is flaky
+	minInstability float64
+	// brokenWorkingRate is the upper bound of the working rate interval below which a test is broken
+	brokenWorkingRate float64
+}
+
+// testKey identifies a test in a job
+type testKey struct {
+	jobName, testSuiteName, testName string
+}
+
+// scoreTestFlakes scores every test of the aggregated test runs. durations are optional, they are used to detect
+// bimodal timing. The scores are ranked from the flakiest test down.
+func scoreTestFlakes(rows []jobrunaggregatorapi.AggregatedTestRunRow, durations map[testKey][]float64, opts flakeScoringOptions) []jobrunaggregatorapi.TestFlakeScore {
+	scores := make([]jobrunaggregatorapi.TestFlakeScore, 0, len(rows))
+	for _, row := range rows {
+		score := jobrunaggregatorapi.TestFlakeScore{
+			JobName:       row.JobName,
+			TestName:      row.TestName,
+			TestSuiteName: row.TestSuiteName.StringVal,
+			Runs:          row.PassCount + row.FailCount + row.FlakeCount,
+			PassCount:     row.PassCount,
+			FailCount:     row.FailCount,
+			FlakeCount:    row.FlakeCount,
+		}
+		unstable := row.FailCount + row.FlakeCount
+		score.InstabilityLowerBound, score.InstabilityUpperBound = wilsonInterval(unstable, score.Runs, wilsonZ)
+
+		weight := 1.0
+		if unstable > 0 && row.FlakeCount*2 >= unstable {
+			score.Signals = append(score.Signals, jobrunaggregatorapi.FlakeSignalPassAfterRetry)
+			weight += passAfterRetryWeight
+		}
+		if testDurations := durations[testKey{jobName: row.JobName, testSuiteName: row.TestSuiteName.StringVal, testName: row.TestName}]; len(testDurations) >= opts.minRuns {
+			score.BimodalityCoefficient = bimodalityCoefficient(testDurations)
+			if score.BimodalityCoefficient > bimodalityThreshold {
+				score.Signals = append(score.Signals, jobrunaggregatorapi.FlakeSignalBimodalTiming)
+				weight += bimodalTimingWeight
+			}
+		}
+		score.Score = score.InstabilityLowerBound * weight
+
+		// the working rate is the complement of the instability, except that flakes count as working
+		_, workingUpperBound := wilsonInterval(row.PassCount+row.FlakeCount, score.Runs, wilsonZ)
+		switch {
+		case score.Runs < opts.minRuns:
+			score.Classification = jobrunaggregatorapi.FlakeClassificationInsufficientData
+		case unstable == 0:
+			score.Classification = jobrunaggregatorapi.FlakeClassificationStable
+		case workingUpperBound < opts.brokenWorkingRate:
+			score.Classification = jobrunaggregatorapi.FlakeClassificationBroken
+		case score.InstabilityLowerBound >= opts.minInstability:
+			score.Classification = jobrunaggregatorapi.FlakeClassificationFlaky
+			score.Quarantine = true
+		default:
+			score.Classification = jobrunaggregatorapi.FlakeClassificationStable
+		}
+		scores = append(scores, score)
+	}
+
+	sort.SliceStable(scores, func(i, j int) bool {
+		if scores[i].Score != scores[j].Score {
+			return scores[i].Score > scores[j].Score
+		}
+		if scores[i].JobName != scores[j].JobName {
+			return scores[i].JobName < scores[j].JobName
+		}
+		if scores[i].TestSuiteName != scores[j].TestSuiteName {
+			return scores[i].TestSuiteName < scores[j].TestSuiteName
+		}
+		return scores[i].TestName < scores[j].TestName
+	})
+	return scores
+}
@@ -0,0 +1,125 @@
+package jobrunflakeanalyzer
+
+import (
+	"math"
+	"testing"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+
+	"github.com/openshift/ci-tools/pkg/jobrunaggregator/jobrunaggregatorapi"
+)
+
+func TestWilsonInterval(t *testing.T) {
+	testCases := []struct {
+		name                string
+		successes, attempts int
+		expectedLowerBound  float64
+		expectedUpperBound  float64
+	}{
+		{
+			name:               "no attempts",
+			expectedLowerBound: 0,
+			expectedUpperBound: 1,
+		},
+		{
+			name:               "no successes",
+			successes:          0,
+			attempts:           10,
+			expectedLowerBound: 0,
+			expectedUpperBound: 0.2775,
+		},
+		{
+			name:               "half",
+			successes:          50,
+			attempts:           100,
+			expectedLowerBound: 0.4038,
+			expectedUpperBound: 0.5962,
+		},
+		{
+			name:               "rare failures",
+			successes:          10,
+			attempts:           200,
+			expectedLowerBound: 0.0274,
+			expectedUpperBound: 0.0896,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			lowerBound, upperBound := wilsonInterval(tc.successes, tc.attempts, wilsonZ)
+			if math.Abs(lowerBound-tc.expectedLowerBound) > 0.0001 || math.Abs(upperBound-tc.expectedUpperBound) > 0.0001 {
+				t.Errorf("expected [%.4f, %.4f], got [%.4f, %.4f]", tc.expectedLowerBound, tc.expectedUpperBound, lowerBound, upperBound)
+			}
+		})
+	}
+}
+
+func TestBimodalityCoefficient(t *testing.T) {
+	var bimodal, unimodal []float64
+	for i := 0; i < 20; i++ {
+		bimodal = append(bimodal, 1000+float64(i%3), 60000+float64(i%5))
+		unimodal = append(unimodal, 30000+float64(i*100))
+	}
+	if coefficient := bimodalityCoefficient(bimodal); coefficient <= bimodalityThreshold {
+		t.Errorf("expected two clusters of durations to be bimodal, got %f", coefficient)
+	}
+	if coefficient := bimodalityCoefficient(unimodal); coefficient > bimodalityThreshold {
+		t.Errorf("expected evenly spread durations not to be bimodal, got %f", coefficient)
+	}
+	if coefficient := bimodalityCoefficient([]float64{1, 2, 3}); coefficient != 0 {
+		t.Errorf("expected too few durations to be ignored, got %f", coefficient)
+	}
+}
+
+func TestScoreTestFlakes(t *testing.T) {
+	row := func(testName string, pass, fail, flake int) jobrunaggregatorapi.AggregatedTestRunRow {
+		return jobrunaggregatorapi.AggregatedTestRunRow{
+			TestName:      testName,
+			TestSuiteName: bigquery.NullString{StringVal: "openshift-tests", Valid: true},
+			JobName:       "e2e-aws",
+			PassCount:     pass,
+			FailCount:     fail,
+			FlakeCount:    flake,
+		}
+	}
+	rows := []jobrunaggregatorapi.AggregatedTestRunRow{
+		row("stable", 100, 0, 0),
+		row("rare failure", 99, 1, 0),
+		row("flaky", 80, 20, 0),
+		row("flaky on retry", 80, 5, 15),
+		row("broken", 10, 90, 0),
+		row("new", 2, 3, 0),
+	}
+	var durations []float64
+	for i := 0; i < 50; i++ {
+		durations = append(durations, 1000, 60000)
+	}
+	opts := flakeScoringOptions{minRuns: 20, minInstability: 0.05, brokenWorkingRate: 0.5}
+
+	scores := scoreTestFlakes(rows, map[testKey][]float64{
+		{jobName: "e2e-aws", testSuiteName: "openshift-tests", testName: "flaky"}: durations,
+	}, opts)
+
+	type result struct {
+		TestName       string
+		Classification string
+		Signals        []string
+		Quarantine     bool
+	}
+	var results []result
+	for _, score := range scores {
+		results = append(results, result{TestName: score.TestName, Classification: score.Classification, Signals: score.Signals, Quarantine: score.Quarantine})
+	}
+	expected := []result{
+		{TestName: "broken", Classification: jobrunaggregatorapi.FlakeClassificationBroken},
+		{TestName: "new", Classification: jobrunaggregatorapi.FlakeClassificationInsufficientData},
+		{TestName: "flaky on retry", Classification: jobrunaggregatorapi.FlakeClassificationFlaky, Signals: []string{jobrunaggregatorapi.FlakeSignalPassAfterRetry}, Quarantine: true},
+		{TestName: "flaky", Classification: jobrunaggregatorapi.FlakeClassificationFlaky, Signals: []string{jobrunaggregatorapi.FlakeSignalBimodalTiming}, Quarantine: true},
+		{TestName: "rare failure", Classification: jobrunaggregatorapi.FlakeClassificationStable},
+		{TestName: "stable", Classification: jobrunaggregatorapi.FlakeClassificationStable},
+	}
+	if diff := cmp.Diff(expected, results, cmpopts.EquateEmpty()); diff != "" {
+		t.Errorf("unexpected scores (-want +got):\n%s", diff)
+	}
+}
@@ -16,6 +16,7 @@ import (
 type JobRunHistoricalDataAnalyzerFlags struct {
 	DataCoordinates *jobrunaggregatorlib.BigQueryDataCoordinates
 	Authentication  *jobrunaggregatorlib.GoogleAuthenticationFlags
+	Offline         *jobrunaggregatorlib.OfflineDataFlags
 
 	NewFile         string
 	CurrentFile     string
@@ -32,12 +33,14 @@ func NewJobRunHistoricalDataAnalyzerFlags() *JobRunHistoricalDataAnalyzerFlags {
 	return &JobRunHistoricalDataAnalyzerFlags{
 		DataCoordinates: jobrunaggregatorlib.NewBigQueryDataCoordinates(),
 		Authentication:  jobrunaggregatorlib.NewGoogleAuthenticationFlags(),
+		Offline:         jobrunaggregatorlib.NewOfflineDataFlags(),
 	}
 }
 
 func (f *JobRunHistoricalDataAnalyzerFlags) BindFlags(fs *pflag.FlagSet) {
 	f.DataCoordinates.BindFlags(fs)
 	f.Authentication.BindFlags(fs)
+	f.Offline.BindFlags(fs)
 
 	fs.StringVar(&f.DataType, "data-type", f.DataType, fmt.Sprintf("data type we are fetching %s", sets.List(supportedDataTypes)))
 	fs.StringVar(&f.NewFile, "new", f.NewFile, "local file with the new query results to compare against")
@@ -49,11 +52,16 @@ func (f *JobRunHistoricalDataAnalyzerFlags) BindFlags(fs *pflag.FlagSet) {
 }
 
 func (f *JobRunHistoricalDataAnalyzerFlags) Validate() error {
-	if err := f.DataCoordinates.Validate(); err != nil && f.NewFile == "" {
+	if err := f.Offline.Validate(); err != nil {
 		return err
 	}
-	if err := f.Authentication.Validate(); err != nil && f.NewFile == "" {
-		return err
+	if !f.Offline.Enabled() {
+		if err := f.DataCoordinates.Validate(); err != nil && f.NewFile == "" {
+			return err
+		}
+		if err := f.Authentication.Validate(); err != nil && f.NewFile == "" {
+			return err
+		}
 	}
 
 	if !supportedDataTypes.Has(f.DataType) {
@@ -77,15 +85,23 @@ func (f *JobRunHistoricalDataAnalyzerFlags) Validate() error {
 }
 
 func (f *JobRunHistoricalDataAnalyzerFlags) ToOptions(ctx context.Context) (*JobRunHistoricalDataAnalyzerOptions, error) {
-	bigQueryClient, err := f.Authentication.NewBigQueryClient(ctx, f.DataCoordinates.ProjectID)
-	if err != nil && f.NewFile == "" {
-		return nil, err
+	var ciDataClient jobrunaggregatorlib.CIDataClient
+	if f.Offline.Enabled() {
+		offlineClient, err := f.Offline.NewCIDataClient()
+		if err != nil {
+			return nil, err
+		}
+		ciDataClient = offlineClient
+	} else {
+		bigQueryClient, err := f.Authentication.NewBigQueryClient(ctx, f.DataCoordinates.ProjectID)
+		if err != nil && f.NewFile == "" {
+			return nil, err
+		}
+		ciDataClient = jobrunaggregatorlib.NewRetryingCIDataClient(
+			jobrunaggregatorlib.NewCIDataClient(*f.DataCoordinates, bigQueryClient),
+		)
 	}
 
-	ciDataClient := jobrunaggregatorlib.NewRetryingCIDataClient(
-		jobrunaggregatorlib.NewCIDataClient(*f.DataCoordinates, bigQueryClient),
-	)
-
 	if f.OutputFile == "" {
 		f.OutputFile = fmt.Sprintf("results_%s.json", f.DataType)
 	}
@@ -82,6 +82,7 @@ func (s *jobGCSPrefixSlice) Type() string {
 type JobRunsTestCaseAnalyzerFlags struct {
 	DataCoordinates *jobrunaggregatorlib.BigQueryDataCoordinates
 	Authentication  *jobrunaggregatorlib.GoogleAuthenticationFlags
+	Offline         *jobrunaggregatorlib.OfflineDataFlags
 
 	TestGroup                   string
 	WorkingDir                  string
@@ -107,6 +108,7 @@ func NewJobRunsTestCaseAnalyzerFlags() *JobRunsTestCaseAnalyzerFlags {
 	return &JobRunsTestCaseAnalyzerFlags{
 		DataCoordinates: jobrunaggregatorlib.NewBigQueryDataCoordinates(),
 		Authentication:  jobrunaggregatorlib.NewGoogleAuthenticationFlags(),
+		Offline:         jobrunaggregatorlib.NewOfflineDataFlags(),
 
 		WorkingDir:                  "test-case-analyzer-working-dir",
 		EstimatedJobStartTimeString: time.Now().Format(kubeTimeSerializationLayout),
@@ -120,6 +122,7 @@ const kubeTimeSerializationLayout = time.RFC3339
 func (f *JobRunsTestCaseAnalyzerFlags) BindFlags(fs *pflag.FlagSet) {
 	f.DataCoordinates.BindFlags(fs)
 	f.Authentication.BindFlags(fs)
+	f.Offline.BindFlags(fs)
 
 	fs.StringVar(&f.TestGroup, "test-group", "install", "Test group to analyze, like install or overall")
 	fs.StringVar(&f.PayloadTag, "payload-tag", f.PayloadTag, "The release controller payload tag to analyze test case status, like 4.9.0-0.ci-2021-07-19-185802")
@@ -237,11 +240,19 @@ func (f *JobRunsTestCaseAnalyzerFlags) Validate() error {
 	if _, err := time.Parse(kubeTimeSerializationLayout, f.EstimatedJobStartTimeString); err != nil {
 		return err
 	}
-	if err := f.DataCoordinates.Validate(); err != nil {
+	if err := f.Offline.Validate(); err != nil {
 		return err
 	}
-	if err := f.Authentication.Validate(); err != nil {
-		return err
+	if !f.Offline.Enabled() {
+		if err := f.DataCoordinates.Validate(); err != nil {
+			return err
+		}
+	}
+	// the job runs are read from GCS unless they are mirrored
+	if len(f.Offline.MirrorDir) == 0 {
+		if err := f.Authentication.Validate(); err != nil {
+			return err
+		}
 	}
 	if f.TestGroup == "" {
 		return fmt.Errorf("test group has to be specified")
@@ -321,17 +332,30 @@ func (f *JobRunsTestCaseAnalyzerFlags) ToOptions(ctx context.Context) (*JobRunTe
 		return nil, err
 	}
 
-	bigQueryClient, err := f.Authentication.NewBigQueryClient(ctx, f.DataCoordinates.ProjectID)
-	if err != nil {
-		return nil, err
+	var ciDataClient jobrunaggregatorlib.CIDataClient
+	if f.Offline.Enabled() {
+		ciDataClient, err = f.Offline.NewCIDataClient()
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		bigQueryClient, err := f.Authentication.NewBigQueryClient(ctx, f.DataCoordinates.ProjectID)
+		if err != nil {
+			return nil, err
+		}
+		ciDataClient = jobrunaggregatorlib.NewRetryingCIDataClient(
+			jobrunaggregatorlib.NewCIDataClient(*f.DataCoordinates, bigQueryClient),
+		)
 	}
-	ciDataClient := jobrunaggregatorlib.NewRetryingCIDataClient(
-		jobrunaggregatorlib.NewCIDataClient(*f.DataCoordinates, bigQueryClient),
-	)
 
-	ciGCSClient, err := f.Authentication.NewCIGCSClient(ctx, f.GCSBucket)
-	if err != nil {
-		return nil, err
+	var ciGCSClient jobrunaggregatorlib.CIGCSClient
+	if len(f.Offline.MirrorDir) > 0 {
+		ciGCSClient = f.Offline.NewCIGCSClient(f.GCSBucket)
+	} else {
+		ciGCSClient, err = f.Authentication.NewCIGCSClient(ctx, f.GCSBucket)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	jobGetter := NewTestCaseAnalyzerJobGetter(f.Platform, f.Infrastructure, f.Network, f.testNameSuffix(), f.ExcludeJobNames, f.IncludeJobNames, &f.JobGCSPrefixes, ciDataClient)
@@ -0,0 +1,116 @@
+package junit
+
+import (
+	"sort"
+	"strings"
+)
+
+// SuitePathSeparator joins the names of nested suites into the suite of a TestID
+const SuitePathSeparator = "|||"
+
+// TestID identifies a test by the path of the suites that hold it and its name
+type TestID struct {
+	Suite string `json:"suite"`
+	Name  string `json:"name"`
+}
+
+// Results determines the result of every test in the suites. A test that ran more than once
+// flakes when it both passed and failed, and is only skipped when it was skipped in every run.
+func Results(suites *TestSuites) map[TestID]TestResult {
+	type outcomes struct {
+		passed, failed bool
+	}
+	seen := map[TestID]*outcomes{}
+	var addSuite func(parents []string, suite *TestSuite)
+	addSuite = func(parents []string, suite *TestSuite) {
+		if suite == nil {
+			return
+		}
+		path := append(append([]string{}, parents...), suite.Name)
+		for _, testCase := range suite.TestCases {
+			id := TestID{Suite: strings.Join(path, SuitePathSeparator), Name: testCase.Name}
+			if seen[id] == nil {
+				seen[id] = &outcomes{}
+			}
+			switch {
+			case testCase.FailureOutput != nil:
+				seen[id].failed = true
+			case testCase.SkipMessage == nil:
+				seen[id].passed = true
+			}
+		}
+		for _, child := range suite.Children {
+			addSuite(path, child)
+		}
+	}
+	if suites != nil {
+		for _, suite := range suites.Suites {
+			addSuite(nil, suite)
+		}
+	}
+
+	results := make(map[TestID]TestResult, len(seen))
+	for id, outcome := range seen {
+		switch {
+		case outcome.passed && outcome.failed:
+			results[id] = TestResultFlaked
+		case outcome.failed:
+			results[id] = TestResultFailed
+		case outcome.passed:
+			results[id] = TestResultPassed
+		default:
+			results[id] = TestResultSkipped
+		}
+	}
+	return results
+}
+
+// SuitesDiff holds the tests that changed between two sets of suites
+type SuitesDiff struct {
+	// NewFailures are the tests that fail after and did not fail before, including new tests
+	NewFailures []TestID `json:"newFailures,omitempty"`
+	// Fixed are the tests that failed before and passed or flaked after
+	Fixed []TestID `json:"fixed,omitempty"`
+	// NewTests are the tests that only ran after
+	NewTests []TestID `json:"newTests,omitempty"`
+	// RemovedTests are the tests that only ran before
+	RemovedTests []TestID `json:"removedTests,omitempty"`
+}
+
+// Diff compares the results of the tests in two sets of suites. Flakes do not count as
+// failures. Names are compared as they are, normalize both sides first to compare runs of
+// tests with generated names.
+func Diff(before, after *TestSuites) SuitesDiff {
+	beforeResults, afterResults := Results(before), Results(after)
+	var diff SuitesDiff
+	for id, result := range afterResults {
+		previous, existed := beforeResults[id]
+		if !existed {
+			diff.NewTests = append(diff.NewTests, id)
+		}
+		switch {
+		case result == TestResultFailed && previous != TestResultFailed:
+			diff.NewFailures = append(diff.NewFailures, id)
+		case previous == TestResultFailed && (result == TestResultPassed || result == TestResultFlaked):
+			diff.Fixed = append(diff.Fixed, id)
+		}
+	}
+	for id := range beforeResults {
+		if _, exists := afterResults[id]; !exists {
+			diff.RemovedTests = append(diff.RemovedTests, id)
+		}
+	}
+	for _, ids := range [][]TestID{diff.NewFailures, diff.Fixed, diff.NewTests, diff.RemovedTests} {
+		sortTestIDs(ids)
+	}
+	return diff
+}
+
+func sortTestIDs(ids []TestID) {
+	sort.Slice(ids, func(i, j int) bool {
+		if ids[i].Suite != ids[j].Suite {
+			return ids[i].Suite < ids[j].Suite
+		}
+		return ids[i].Name < ids[j].Name
+	})
+}
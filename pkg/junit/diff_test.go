@@ -0,0 +1,46 @@
+package junit
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func suitesWith(testCases ...*TestCase) *TestSuites {
+	return &TestSuites{Suites: []*TestSuite{{Name: "e2e", TestCases: testCases, Children: []*TestSuite{{Name: "child", TestCases: []*TestCase{{Name: "nested"}}}}}}}
+}
+
+func pass(name string) *TestCase { return &TestCase{Name: name} }
+func fail(name string) *TestCase {
+	return &TestCase{Name: name, FailureOutput: &FailureOutput{Message: "failed"}}
+}
+func skip(name string) *TestCase { return &TestCase{Name: name, SkipMessage: &SkipMessage{}} }
+
+func TestResults(t *testing.T) {
+	expected := map[TestID]TestResult{
+		{Suite: "e2e", Name: "passes"}:         TestResultPassed,
+		{Suite: "e2e", Name: "fails"}:          TestResultFailed,
+		{Suite: "e2e", Name: "flakes"}:         TestResultFlaked,
+		{Suite: "e2e", Name: "skipped"}:        TestResultSkipped,
+		{Suite: "e2e", Name: "skipped once"}:   TestResultPassed,
+		{Suite: "e2e|||child", Name: "nested"}: TestResultPassed,
+	}
+	actual := Results(suitesWith(pass("passes"), fail("fails"), fail("flakes"), pass("flakes"), skip("skipped"), skip("skipped once"), pass("skipped once")))
+	if diff := cmp.Diff(expected, actual); diff != "" {
+		t.Errorf("unexpected results (-want +got):\n%s", diff)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	before := suitesWith(pass("still passes"), fail("still fails"), pass("breaks"), fail("gets fixed"), fail("starts flaking"), pass("starts flaking"), pass("removed"), skip("was skipped"))
+	after := suitesWith(pass("still passes"), fail("still fails"), fail("breaks"), pass("gets fixed"), fail("starts flaking"), pass("starts flaking"), fail("new and failing"), pass("new"), fail("was skipped"))
+	expected := SuitesDiff{
+		NewFailures:  []TestID{{Suite: "e2e", Name: "breaks"}, {Suite: "e2e", Name: "new and failing"}, {Suite: "e2e", Name: "was skipped"}},
+		Fixed:        []TestID{{Suite: "e2e", Name: "gets fixed"}},
+		NewTests:     []TestID{{Suite: "e2e", Name: "new"}, {Suite: "e2e", Name: "new and failing"}},
+		RemovedTests: []TestID{{Suite: "e2e", Name: "removed"}},
+	}
+	if diff := cmp.Diff(expected, Diff(before, after)); diff != "" {
+		t.Errorf("unexpected diff (-want +got):\n%s", diff)
+	}
+}
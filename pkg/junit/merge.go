@@ -0,0 +1,60 @@
+package junit
+
+// Merge combines the suites of many runs into one collection. Suites with the same name at
+// the same level are merged into one suite holding the test cases of all of them, so a test
+// that ran in several runs is held once per run. Counts and durations are summed and the
+// properties of all suites are kept, without duplicates. The inputs are not modified, but
+// the merged suites share their test cases.
+func Merge(suites ...*TestSuites) *TestSuites {
+	merged := &TestSuites{}
+	for _, toAdd := range suites {
+		if toAdd == nil {
+			continue
+		}
+		merged.Suites = mergeSuites(merged.Suites, toAdd.Suites)
+	}
+	return merged
+}
+
+func mergeSuites(into []*TestSuite, toAdd []*TestSuite) []*TestSuite {
+	for _, suite := range toAdd {
+		if suite == nil {
+			continue
+		}
+		var existing *TestSuite
+		for _, candidate := range into {
+			if candidate.Name == suite.Name {
+				existing = candidate
+				break
+			}
+		}
+		if existing == nil {
+			existing = &TestSuite{Name: suite.Name}
+			into = append(into, existing)
+		}
+		existing.NumTests += suite.NumTests
+		existing.NumSkipped += suite.NumSkipped
+		existing.NumFailed += suite.NumFailed
+		existing.Duration += suite.Duration
+		existing.Properties = mergeProperties(existing.Properties, suite.Properties)
+		existing.TestCases = append(existing.TestCases, suite.TestCases...)
+		existing.Children = mergeSuites(existing.Children, suite.Children)
+	}
+	return into
+}
+
+func mergeProperties(into []*Property, toAdd []*Property) []*Property {
+	for _, property := range toAdd {
+		duplicate := false
+		for _, existing := range into {
+			if existing.Name == property.Name && existing.Value == property.Value {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			into = append(into, &Property{Name: property.Name, Value: property.Value})
+		}
+	}
+	return into
+}
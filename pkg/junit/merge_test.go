@@ -0,0 +1,45 @@
+package junit
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMerge(t *testing.T) {
+	passed := &TestCase{Name: "test", Duration: 1}
+	failed := &TestCase{Name: "test", Duration: 2, FailureOutput: &FailureOutput{Message: "failed"}}
+	other := &TestCase{Name: "other", Duration: 3}
+	first := &TestSuites{Suites: []*TestSuite{
+		{
+			Name: "e2e", NumTests: 1, Duration: 1,
+			Properties: []*Property{{Name: "run", Value: "1"}, {Name: "version", Value: "4.16"}},
+			TestCases:  []*TestCase{passed},
+			Children:   []*TestSuite{{Name: "child", NumTests: 1, Duration: 3, TestCases: []*TestCase{other}}},
+		},
+	}}
+	second := &TestSuites{Suites: []*TestSuite{
+		{
+			Name: "e2e", NumTests: 1, NumFailed: 1, Duration: 2,
+			Properties: []*Property{{Name: "run", Value: "2"}, {Name: "version", Value: "4.16"}},
+			TestCases:  []*TestCase{failed},
+		},
+		{Name: "unit", NumTests: 1, Duration: 3, TestCases: []*TestCase{other}},
+	}}
+
+	expected := &TestSuites{Suites: []*TestSuite{
+		{
+			Name: "e2e", NumTests: 2, NumFailed: 1, Duration: 3,
+			Properties: []*Property{{Name: "run", Value: "1"}, {Name: "version", Value: "4.16"}, {Name: "run", Value: "2"}},
+			TestCases:  []*TestCase{passed, failed},
+			Children:   []*TestSuite{{Name: "child", NumTests: 1, Duration: 3, TestCases: []*TestCase{other}}},
+		},
+		{Name: "unit", NumTests: 1, Duration: 3, TestCases: []*TestCase{other}},
+	}}
+	if diff := cmp.Diff(expected, Merge(first, nil, second)); diff != "" {
+		t.Errorf("unexpected merged suites (-want +got):\n%s", diff)
+	}
+	if len(first.Suites[0].TestCases) != 1 || len(first.Suites[0].Properties) != 2 {
+		t.Errorf("expected the inputs not to be modified, got %v", first.Suites[0])
+	}
+}
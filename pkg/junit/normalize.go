@@ -0,0 +1,58 @@
+package junit
+
+import (
+	"regexp"
+	"strings"
+)
+
+const durationPattern = `(\d+(\.\d+)?(ns|us|µs|ms|s|m|h))+`
+
+var (
+	durationInBracketsRegex = regexp.MustCompile(`\s*[\[(]` + durationPattern + `[\])]`)
+	durationAfterWordRegex  = regexp.MustCompile(`\s+(in|after|took) ` + durationPattern + `\b`)
+	namespaceRegex          = regexp.MustCompile(`\bci-(op|ln)-[a-z0-9]{5,}\b`)
+	uuidRegex               = regexp.MustCompile(`(?i)\b[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}\b`)
+	// randomSuffixRegex matches the suffixes Kubernetes generates names with, the ones without
+	// a digit are too easily confused with words
+	randomSuffixRegex = regexp.MustCompile(`-[bcdfghjklmnpqrstvwxz2456789]{5}\b`)
+	digitRegex        = regexp.MustCompile(`\d`)
+	whitespaceRegex   = regexp.MustCompile(`\s+`)
+)
+
+// NormalizeTestName removes the parts of a test name that differ between runs of the same
+// test: durations, ci-operator namespaces, UUIDs and the random suffixes of generated names.
+func NormalizeTestName(name string) string {
+	name = durationInBracketsRegex.ReplaceAllString(name, "")
+	name = durationAfterWordRegex.ReplaceAllString(name, "")
+	name = namespaceRegex.ReplaceAllString(name, "ci-$1-<random>")
+	name = uuidRegex.ReplaceAllString(name, "<uuid>")
+	name = randomSuffixRegex.ReplaceAllStringFunc(name, func(suffix string) string {
+		if !digitRegex.MatchString(suffix) {
+			return suffix
+		}
+		return "-<random>"
+	})
+	return strings.TrimSpace(whitespaceRegex.ReplaceAllString(name, " "))
+}
+
+// Normalize normalizes the names of all test cases in the suites in place.
+func Normalize(suites *TestSuites) {
+	if suites == nil {
+		return
+	}
+	for _, suite := range suites.Suites {
+		normalizeSuite(suite)
+	}
+}
+
+func normalizeSuite(suite *TestSuite) {
+	if suite == nil {
+		return
+	}
+	for _, testCase := range suite.TestCases {
+		testCase.Name = NormalizeTestName(testCase.Name)
+	}
+	for _, child := range suite.Children {
+		normalizeSuite(child)
+	}
+}
@@ -0,0 +1,37 @@
+package junit
+
+import "testing"
+
+func TestNormalizeTestName(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		expected string
+	}{
+		{name: "[sig-network] Services should serve a basic endpoint [Suite:openshift/conformance/parallel] [1m2.5s]", expected: "[sig-network] Services should serve a basic endpoint [Suite:openshift/conformance/parallel]"},
+		{name: "install should succeed: overall (35m12s)", expected: "install should succeed: overall"},
+		{name: "upgrade finished after 1h2m3s", expected: "upgrade finished"},
+		{name: "namespace ci-op-x8b2kq4t pod e2e-test-webhook-7xk2p should be ready", expected: "namespace ci-op-<random> pod e2e-test-webhook-<random> should be ready"},
+		{name: "disk 0e2a1c4b-9a4f-4c3e-8b1a-2f1e3d4c5b6a attaches", expected: "disk <uuid> attaches"},
+		{name: "operator-check should pass", expected: "operator-check should pass"},
+		{name: "TestDeployment/valid", expected: "TestDeployment/valid"},
+	} {
+		if actual := NormalizeTestName(tc.name); actual != tc.expected {
+			t.Errorf("%q: expected %q, got %q", tc.name, tc.expected, actual)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	suites := &TestSuites{Suites: []*TestSuite{{
+		Name:      "e2e",
+		TestCases: []*TestCase{{Name: "test [2s]"}},
+		Children:  []*TestSuite{{Name: "child", TestCases: []*TestCase{{Name: "in ci-op-abcdefgh"}}}},
+	}}}
+	Normalize(suites)
+	if name := suites.Suites[0].TestCases[0].Name; name != "test" {
+		t.Errorf("expected the test case to be normalized, got %q", name)
+	}
+	if name := suites.Suites[0].Children[0].TestCases[0].Name; name != "in ci-op-<random>" {
+		t.Errorf("expected the test case of the child to be normalized, got %q", name)
+	}
+}
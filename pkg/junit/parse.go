@@ -0,0 +1,212 @@
+package junit
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+// Parse reads jUnit XML that holds either a <testsuites> element or a single <testsuite>
+// element. Empty content holds no suites.
+func Parse(raw []byte) (*TestSuites, error) {
+	if len(bytes.TrimSpace(raw)) == 0 {
+		return &TestSuites{}, nil
+	}
+	suites := &TestSuites{}
+	suitesErr := xml.Unmarshal(raw, suites)
+	if suitesErr == nil {
+		return suites, nil
+	}
+	if root, err := rootElement(raw); err != nil || root != "testsuite" {
+		// a <testsuites> document that we cannot read, or not jUnit at all
+		return nil, fmt.Errorf("could not parse test suites: %w", suitesErr)
+	}
+	suite := &TestSuite{}
+	if err := xml.Unmarshal(raw, suite); err != nil {
+		return nil, fmt.Errorf("could not parse test suite: %w", err)
+	}
+	return &TestSuites{Suites: []*TestSuite{suite}}, nil
+}
+
+func rootElement(raw []byte) (string, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(raw))
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return "", err
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			return start.Name.Local, nil
+		}
+	}
+}
+
+// ParseJSON reads suites written by WriteJSON
+func ParseJSON(raw []byte) (*TestSuites, error) {
+	var parsed jsonSuites
+	if err := json.Unmarshal(raw, &parsed); err != nil {
+		return nil, fmt.Errorf("could not parse test suites: %w", err)
+	}
+	suites := &TestSuites{}
+	for _, suite := range parsed.Suites {
+		suites.Suites = append(suites.Suites, suite.toSuite())
+	}
+	return suites, nil
+}
+
+// WriteJSON writes the suites as JSON, with the names of the jUnit XML elements and attributes
+func WriteJSON(w io.Writer, suites *TestSuites) error {
+	out := jsonSuites{}
+	for _, suite := range suites.Suites {
+		out.Suites = append(out.Suites, jsonSuiteFrom(suite))
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(out)
+}
+
+// jsonSuites and the types it holds map the jUnit types to JSON, without changing how they serialize otherwise
+type jsonSuites struct {
+	Suites []*jsonSuite `json:"suites,omitempty"`
+}
+
+type jsonSuite struct {
+	Name       string          `json:"name"`
+	NumTests   uint            `json:"tests"`
+	NumSkipped uint            `json:"skipped"`
+	NumFailed  uint            `json:"failures"`
+	Duration   float64         `json:"time"`
+	Properties []*jsonProperty `json:"properties,omitempty"`
+	TestCases  []*jsonCase     `json:"testcases,omitempty"`
+	Children   []*jsonSuite    `json:"children,omitempty"`
+}
+
+type jsonProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type jsonCase struct {
+	Name          string          `json:"name"`
+	Classname     string          `json:"classname,omitempty"`
+	Duration      float64         `json:"time"`
+	Lifecycle     string          `json:"lifecycle,omitempty"`
+	Properties    []*jsonProperty `json:"properties,omitempty"`
+	SkipMessage   *jsonSkip       `json:"skipped,omitempty"`
+	FailureOutput *jsonFailure    `json:"failure,omitempty"`
+	SystemOut     string          `json:"system-out,omitempty"`
+	SystemErr     string          `json:"system-err,omitempty"`
+}
+
+type jsonSkip struct {
+	Message string `json:"message,omitempty"`
+}
+
+type jsonFailure struct {
+	Message string `json:"message"`
+	Output  string `json:"output"`
+}
+
+func jsonPropertiesFrom(properties []*Property) []*jsonProperty {
+	var out []*jsonProperty
+	for _, property := range properties {
+		if property != nil {
+			out = append(out, &jsonProperty{Name: property.Name, Value: property.Value})
+		}
+	}
+	return out
+}
+
+func toProperties(properties []*jsonProperty) []*Property {
+	var out []*Property
+	for _, property := range properties {
+		if property != nil {
+			out = append(out, &Property{Name: property.Name, Value: property.Value})
+		}
+	}
+	return out
+}
+
+func jsonSuiteFrom(suite *TestSuite) *jsonSuite {
+	if suite == nil {
+		return nil
+	}
+	out := &jsonSuite{
+		Name:       suite.Name,
+		NumTests:   suite.NumTests,
+		NumSkipped: suite.NumSkipped,
+		NumFailed:  suite.NumFailed,
+		Duration:   suite.Duration,
+		Properties: jsonPropertiesFrom(suite.Properties),
+	}
+	for _, testCase := range suite.TestCases {
+		if testCase == nil {
+			continue
+		}
+		converted := &jsonCase{
+			Name:       testCase.Name,
+			Classname:  testCase.Classname,
+			Duration:   testCase.Duration,
+			Lifecycle:  testCase.Lifecycle,
+			Properties: jsonPropertiesFrom(testCase.Properties),
+			SystemOut:  testCase.SystemOut,
+			SystemErr:  testCase.SystemErr,
+		}
+		if testCase.SkipMessage != nil {
+			converted.SkipMessage = &jsonSkip{Message: testCase.SkipMessage.Message}
+		}
+		if testCase.FailureOutput != nil {
+			converted.FailureOutput = &jsonFailure{Message: testCase.FailureOutput.Message, Output: testCase.FailureOutput.Output}
+		}
+		out.TestCases = append(out.TestCases, converted)
+	}
+	for _, child := range suite.Children {
+		if child != nil {
+			out.Children = append(out.Children, jsonSuiteFrom(child))
+		}
+	}
+	return out
+}
+
+func (s *jsonSuite) toSuite() *TestSuite {
+	if s == nil {
+		return nil
+	}
+	out := &TestSuite{
+		Name:       s.Name,
+		NumTests:   s.NumTests,
+		NumSkipped: s.NumSkipped,
+		NumFailed:  s.NumFailed,
+		Duration:   s.Duration,
+		Properties: toProperties(s.Properties),
+	}
+	for _, testCase := range s.TestCases {
+		if testCase == nil {
+			continue
+		}
+		converted := &TestCase{
+			Name:       testCase.Name,
+			Classname:  testCase.Classname,
+			Duration:   testCase.Duration,
+			Lifecycle:  testCase.Lifecycle,
+			Properties: toProperties(testCase.Properties),
+			SystemOut:  testCase.SystemOut,
+			SystemErr:  testCase.SystemErr,
+		}
+		if testCase.SkipMessage != nil {
+			converted.SkipMessage = &SkipMessage{Message: testCase.SkipMessage.Message}
+		}
+		if testCase.FailureOutput != nil {
+			converted.FailureOutput = &FailureOutput{Message: testCase.FailureOutput.Message, Output: testCase.FailureOutput.Output}
+		}
+		out.TestCases = append(out.TestCases, converted)
+	}
+	for _, child := range s.Children {
+		if child != nil {
+			out.Children = append(out.Children, child.toSuite())
+		}
+	}
+	return out
+}
@@ -0,0 +1,96 @@
+package junit
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestParse(t *testing.T) {
+	testCases := []struct {
+		name          string
+		raw           string
+		expected      *TestSuites
+		expectedError string
+	}{
+		{
+			name:     "empty",
+			raw:      "\n",
+			expected: &TestSuites{},
+		},
+		{
+			name:     "test suites",
+			raw:      junitXML,
+			expected: &TestSuites{Suites: []*TestSuite{{NumTests: 1, Duration: 1983, Properties: []*Property{{Name: "go.version", Value: "go1.17.5 linux/amd64"}}, TestCases: []*TestCase{{Name: "TestUpgradeControlPlane", Duration: 1983}}}}},
+		},
+		{
+			name:     "single test suite",
+			raw:      `<?xml version="1.0"?><testsuite name="e2e" tests="1" failures="1"><testcase name="test"><failure message="failed">output</failure></testcase></testsuite>`,
+			expected: &TestSuites{Suites: []*TestSuite{{Name: "e2e", NumTests: 1, NumFailed: 1, TestCases: []*TestCase{{Name: "test", FailureOutput: &FailureOutput{Message: "failed", Output: "output"}}}}}},
+		},
+		{
+			name:          "unreadable test suites",
+			raw:           `<testsuites><testsuite name="e2e" time="not a number"></testsuite></testsuites>`,
+			expectedError: `could not parse test suites: strconv.ParseFloat: parsing "not a number": invalid syntax`,
+		},
+		{
+			name:          "not junit",
+			raw:           `<html></html>`,
+			expectedError: "could not parse test suites: expected element type <testsuites> but have <html>",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			suites, err := Parse([]byte(tc.raw))
+			var actualError string
+			if err != nil {
+				actualError = err.Error()
+			}
+			if diff := cmp.Diff(tc.expectedError, actualError); diff != "" {
+				t.Fatalf("unexpected error (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.expected, suites, cmpopts.IgnoreTypes(xml.Name{})); diff != "" {
+				t.Errorf("unexpected suites (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	suites := &TestSuites{Suites: []*TestSuite{{
+		Name:       "e2e",
+		NumTests:   3,
+		NumSkipped: 1,
+		NumFailed:  1,
+		Duration:   6,
+		Properties: []*Property{{Name: "version", Value: "4.16"}},
+		TestCases: []*TestCase{
+			{Name: "passes", Classname: "class", Duration: 1, Lifecycle: "informing", SystemOut: "out", SystemErr: "err"},
+			{Name: "fails", Duration: 2, FailureOutput: &FailureOutput{Message: "failed", Output: "broken"}},
+			{Name: "skipped", SkipMessage: &SkipMessage{Message: "not today"}, Properties: []*Property{{Name: "p", Value: "v"}}},
+		},
+		Children: []*TestSuite{{Name: "child", TestCases: []*TestCase{{Name: "nested"}}}},
+	}}}
+	buf := &bytes.Buffer{}
+	if err := WriteJSON(buf, suites); err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+	if !strings.HasPrefix(buf.String(), "{\n  \"suites\": [\n    {\n      \"name\": \"e2e\",\n      \"tests\": 3,") {
+		t.Errorf("unexpected JSON: %s", buf.String())
+	}
+	parsed, err := ParseJSON(buf.Bytes())
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	if diff := cmp.Diff(suites, parsed); diff != "" {
+		t.Errorf("unexpected suites after a round trip (-want +got):\n%s", diff)
+	}
+
+	if _, err := ParseJSON([]byte("<testsuites/>")); err == nil {
+		t.Error("expected an error for XML")
+	}
+}
@@ -0,0 +1,168 @@
+package junit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// test2JSONEvent is one line of the output of `go test -json`, see `go doc test2json`
+type test2JSONEvent struct {
+	Action  string  `json:"Action"`
+	Package string  `json:"Package,omitempty"`
+	Test    string  `json:"Test,omitempty"`
+	Elapsed float64 `json:"Elapsed,omitempty"`
+	Output  string  `json:"Output,omitempty"`
+}
+
+// ParseTest2JSON reads the output of `go test -json` into one suite per package. Subtests
+// are test cases named like `go test` names them. A test that started but never reported a
+// result, like one that was running when the binary panicked, is a failure. Lines that are
+// not events, like the output of the build, are ignored.
+func ParseTest2JSON(r io.Reader) (*TestSuites, error) {
+	type testRun struct {
+		testCase *TestCase
+		output   strings.Builder
+		action   string
+	}
+	suites := &TestSuites{}
+	bySuite := map[string]*TestSuite{}
+	runs := map[string]map[string]*testRun{}
+	var order []*testRun
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		raw := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(raw, "{") {
+			continue
+		}
+		var event test2JSONEvent
+		if err := json.Unmarshal([]byte(raw), &event); err != nil {
+			return nil, fmt.Errorf("could not parse event on line %d: %w", line, err)
+		}
+		suite, ok := bySuite[event.Package]
+		if !ok {
+			suite = &TestSuite{Name: event.Package}
+			bySuite[event.Package] = suite
+			runs[event.Package] = map[string]*testRun{}
+			suites.Suites = append(suites.Suites, suite)
+		}
+		if event.Test == "" {
+			if event.Action == "pass" || event.Action == "fail" || event.Action == "skip" {
+				suite.Duration = event.Elapsed
+			}
+			continue
+		}
+		run, ok := runs[event.Package][event.Test]
+		if !ok {
+			run = &testRun{testCase: &TestCase{Name: event.Test}}
+			runs[event.Package][event.Test] = run
+			suite.TestCases = append(suite.TestCases, run.testCase)
+			order = append(order, run)
+		}
+		switch event.Action {
+		case "output":
+			run.output.WriteString(event.Output)
+		case "pass", "fail", "skip":
+			run.action = event.Action
+			run.testCase.Duration = event.Elapsed
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("could not read events: %w", err)
+	}
+
+	for _, run := range order {
+		output := run.output.String()
+		switch run.action {
+		case "pass":
+			run.testCase.SystemOut = output
+		case "skip":
+			run.testCase.SkipMessage = &SkipMessage{Message: strings.TrimSpace(output)}
+		case "fail":
+			run.testCase.FailureOutput = &FailureOutput{Message: "Failed", Output: output}
+		default:
+			run.testCase.FailureOutput = &FailureOutput{Message: "Test did not report a result", Output: output}
+		}
+	}
+	for _, suite := range suites.Suites {
+		for _, testCase := range suite.TestCases {
+			suite.NumTests++
+			if testCase.SkipMessage != nil {
+				suite.NumSkipped++
+			}
+			if testCase.FailureOutput != nil {
+				suite.NumFailed++
+			}
+		}
+	}
+	return suites, nil
+}
+
+// WriteTest2JSON writes the suites as the events `go test -json` would have written for them.
+// Every suite with test cases is a package, named by the path of the suite joined with `/`.
+// Properties and the output the events cannot hold are not written.
+func WriteTest2JSON(w io.Writer, suites *TestSuites) error {
+	encoder := json.NewEncoder(w)
+	var writeSuite func(parents []string, suite *TestSuite) error
+	writeSuite = func(parents []string, suite *TestSuite) error {
+		if suite == nil {
+			return nil
+		}
+		path := append(append([]string{}, parents...), suite.Name)
+		pkg := strings.Join(path, "/")
+		failed := false
+		for _, testCase := range suite.TestCases {
+			events := []test2JSONEvent{{Action: "run", Package: pkg, Test: testCase.Name}}
+			action, output := "pass", testCase.SystemOut
+			switch {
+			case testCase.FailureOutput != nil:
+				action, output, failed = "fail", testCase.FailureOutput.Output, true
+				if output == "" {
+					output = testCase.FailureOutput.Message
+				}
+			case testCase.SkipMessage != nil:
+				action, output = "skip", testCase.SkipMessage.Message
+			}
+			if output != "" {
+				if !strings.HasSuffix(output, "\n") {
+					output += "\n"
+				}
+				events = append(events, test2JSONEvent{Action: "output", Package: pkg, Test: testCase.Name, Output: output})
+			}
+			events = append(events, test2JSONEvent{Action: action, Package: pkg, Test: testCase.Name, Elapsed: testCase.Duration})
+			for _, event := range events {
+				if err := encoder.Encode(event); err != nil {
+					return err
+				}
+			}
+		}
+		if len(suite.TestCases) > 0 {
+			action := "pass"
+			if failed {
+				action = "fail"
+			}
+			if err := encoder.Encode(test2JSONEvent{Action: action, Package: pkg, Elapsed: suite.Duration}); err != nil {
+				return err
+			}
+		}
+		for _, child := range suite.Children {
+			if err := writeSuite(path, child); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if suites == nil {
+		return nil
+	}
+	for _, suite := range suites.Suites {
+		if err := writeSuite(nil, suite); err != nil {
+			return err
+		}
+	}
+	return nil
+}
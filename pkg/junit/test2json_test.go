@@ -0,0 +1,88 @@
+package junit
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const test2JSONOutput = `# github.com/openshift/ci-tools/pkg/other
+{"Action":"start","Package":"github.com/openshift/ci-tools/pkg/junit"}
+{"Action":"run","Package":"github.com/openshift/ci-tools/pkg/junit","Test":"TestPasses"}
+{"Action":"output","Package":"github.com/openshift/ci-tools/pkg/junit","Test":"TestPasses","Output":"=== RUN   TestPasses\n"}
+{"Action":"pass","Package":"github.com/openshift/ci-tools/pkg/junit","Test":"TestPasses","Elapsed":0.5}
+{"Action":"run","Package":"github.com/openshift/ci-tools/pkg/junit","Test":"TestFails"}
+{"Action":"run","Package":"github.com/openshift/ci-tools/pkg/junit","Test":"TestFails/case"}
+{"Action":"output","Package":"github.com/openshift/ci-tools/pkg/junit","Test":"TestFails/case","Output":"    junit_test.go:10: broken\n"}
+{"Action":"fail","Package":"github.com/openshift/ci-tools/pkg/junit","Test":"TestFails/case","Elapsed":0.25}
+{"Action":"fail","Package":"github.com/openshift/ci-tools/pkg/junit","Test":"TestFails","Elapsed":0.25}
+{"Action":"run","Package":"github.com/openshift/ci-tools/pkg/junit","Test":"TestSkipped"}
+{"Action":"output","Package":"github.com/openshift/ci-tools/pkg/junit","Test":"TestSkipped","Output":"    junit_test.go:20: not today\n"}
+{"Action":"skip","Package":"github.com/openshift/ci-tools/pkg/junit","Test":"TestSkipped"}
+{"Action":"run","Package":"github.com/openshift/ci-tools/pkg/junit","Test":"TestHangs"}
+{"Action":"fail","Package":"github.com/openshift/ci-tools/pkg/junit","Elapsed":1.5}
+`
+
+func TestParseTest2JSON(t *testing.T) {
+	suites, err := ParseTest2JSON(strings.NewReader(test2JSONOutput))
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	expected := &TestSuites{Suites: []*TestSuite{{
+		Name: "github.com/openshift/ci-tools/pkg/junit", NumTests: 5, NumSkipped: 1, NumFailed: 3, Duration: 1.5,
+		TestCases: []*TestCase{
+			{Name: "TestPasses", Duration: 0.5, SystemOut: "=== RUN   TestPasses\n"},
+			{Name: "TestFails", Duration: 0.25, FailureOutput: &FailureOutput{Message: "Failed"}},
+			{Name: "TestFails/case", Duration: 0.25, FailureOutput: &FailureOutput{Message: "Failed", Output: "    junit_test.go:10: broken\n"}},
+			{Name: "TestSkipped", SkipMessage: &SkipMessage{Message: "junit_test.go:20: not today"}},
+			{Name: "TestHangs", FailureOutput: &FailureOutput{Message: "Test did not report a result"}},
+		},
+	}}}
+	if diff := cmp.Diff(expected, suites); diff != "" {
+		t.Errorf("unexpected suites (-want +got):\n%s", diff)
+	}
+
+	if _, err := ParseTest2JSON(strings.NewReader(`{"Action":`)); err == nil {
+		t.Error("expected a truncated event to fail")
+	}
+}
+
+func TestWriteTest2JSON(t *testing.T) {
+	suites := &TestSuites{Suites: []*TestSuite{{
+		Name:     "e2e",
+		Duration: 3,
+		TestCases: []*TestCase{
+			{Name: "passes", Duration: 1},
+			{Name: "fails", Duration: 2, FailureOutput: &FailureOutput{Message: "failed", Output: "broken"}},
+		},
+		Children: []*TestSuite{{Name: "child", TestCases: []*TestCase{{Name: "skipped", SkipMessage: &SkipMessage{Message: "not today"}}}}},
+	}}}
+	buf := &bytes.Buffer{}
+	if err := WriteTest2JSON(buf, suites); err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+	expected := `{"Action":"run","Package":"e2e","Test":"passes"}
+{"Action":"pass","Package":"e2e","Test":"passes","Elapsed":1}
+{"Action":"run","Package":"e2e","Test":"fails"}
+{"Action":"output","Package":"e2e","Test":"fails","Output":"broken\n"}
+{"Action":"fail","Package":"e2e","Test":"fails","Elapsed":2}
+{"Action":"fail","Package":"e2e","Elapsed":3}
+{"Action":"run","Package":"e2e/child","Test":"skipped"}
+{"Action":"output","Package":"e2e/child","Test":"skipped","Output":"not today\n"}
+{"Action":"skip","Package":"e2e/child","Test":"skipped"}
+{"Action":"pass","Package":"e2e/child"}
+`
+	if diff := cmp.Diff(expected, buf.String()); diff != "" {
+		t.Errorf("unexpected events (-want +got):\n%s", diff)
+	}
+
+	roundTripped, err := ParseTest2JSON(buf)
+	if err != nil {
+		t.Fatalf("failed to parse written events: %v", err)
+	}
+	if diff := cmp.Diff(Results(&TestSuites{Suites: []*TestSuite{{Name: "e2e", TestCases: suites.Suites[0].TestCases}, {Name: "e2e/child", TestCases: suites.Suites[0].Children[0].TestCases}}}), Results(roundTripped)); diff != "" {
+		t.Errorf("unexpected results after a round trip (-want +got):\n%s", diff)
+	}
+}
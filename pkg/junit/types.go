@@ -108,3 +108,14 @@ type FailureOutput struct {
 
 // TestResult is the result of a test case
 type TestResult string
+
+const (
+	// TestResultPassed is the result of a test that passed in all of its runs
+	TestResultPassed TestResult = "passed"
+	// TestResultFailed is the result of a test that failed in all of its runs
+	TestResultFailed TestResult = "failed"
+	// TestResultFlaked is the result of a test that both passed and failed
+	TestResultFlaked TestResult = "flaked"
+	// TestResultSkipped is the result of a test that was skipped in all of its runs
+	TestResultSkipped TestResult = "skipped"
+)
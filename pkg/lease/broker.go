@@ -0,0 +1,224 @@
+package lease
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// BrokerAcquireEndpoint queues a lease request or returns the granted leases
+	BrokerAcquireEndpoint = "/broker/acquire"
+	// BrokerAcknowledgeEndpoint confirms that the client received the leases of a granted request
+	BrokerAcknowledgeEndpoint = "/broker/acknowledge"
+	// BrokerCancelEndpoint withdraws a queued lease request
+	BrokerCancelEndpoint = "/broker/cancel"
+	// BrokerQueuesEndpoint reports the state of the queues per resource type
+	BrokerQueuesEndpoint = "/broker/queues"
+
+	defaultBrokerPollInterval = 10 * time.Second
+	// maxBrokerAttempts is the number of consecutive failed calls after which the client gives up on the broker
+	maxBrokerAttempts = 5
+)
+
+// QueueStatus is the position of a lease request waiting in the broker
+type QueueStatus struct {
+	// Position is the 1-based position of the request in the queue of its resource type
+	Position int `json:"position"`
+	// Depth is the number of requests waiting for the resource type
+	Depth int `json:"depth"`
+	// EstimatedWaitSeconds is the estimated time until the request is granted, if known
+	EstimatedWaitSeconds *int64 `json:"estimatedWaitSeconds,omitempty"`
+}
+
+// EstimatedWait returns the estimated wait in a human-readable form
+func (s QueueStatus) EstimatedWait() string {
+	if s.EstimatedWaitSeconds == nil {
+		return "unknown"
+	}
+	return (time.Duration(*s.EstimatedWaitSeconds) * time.Second).String()
+}
+
+// QueueMetrics describes the queue of a resource type in the broker
+type QueueMetrics struct {
+	Depth int `json:"depth"`
+	// ByPriority is the number of waiting requests per priority class
+	ByPriority map[PriorityClass]int `json:"byPriority,omitempty"`
+	// EstimatedWaitSeconds is the estimated wait for a request joining the end of the queue, if known
+	EstimatedWaitSeconds *int64 `json:"estimatedWaitSeconds,omitempty"`
+}
+
+// BrokerGrant is the response of the broker to a granted request
+type BrokerGrant struct {
+	Names []string `json:"names"`
+}
+
+// BrokerClient talks to a lease broker which queues requests in front of Boskos.
+type BrokerClient interface {
+	// Acquire queues the request or refreshes its position. It returns the lease names once
+	// the request is granted and the queue status otherwise.
+	Acquire(ctx context.Context, rtype string, n uint, priority PriorityClass, requestID string) ([]string, *QueueStatus, error)
+	// Acknowledge confirms that the leases of the granted request were received. Until then,
+	// the broker keeps returning them and releases them if the request is abandoned.
+	Acknowledge(requestID string) error
+	// Cancel withdraws the request and releases any resources acquired for it so far.
+	Cancel(requestID string) error
+}
+
+type brokerClient struct {
+	url            string
+	owner          string
+	username       string
+	passwordGetter func() []byte
+	client         *http.Client
+}
+
+// NewBrokerClient creates a client for the lease broker. The broker acquires the resources on
+// behalf of the owner, so the leases are updated and released directly with Boskos afterwards.
+func NewBrokerClient(brokerURL, owner, username string, passwordGetter func() []byte) BrokerClient {
+	return &brokerClient{
+		url:            brokerURL,
+		owner:          owner,
+		username:       username,
+		passwordGetter: passwordGetter,
+		client:         &http.Client{Timeout: time.Minute},
+	}
+}
+
+func (c *brokerClient) do(ctx context.Context, endpoint string, params url.Values) (*http.Response, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url+endpoint+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	if c.username != "" {
+		req.SetBasicAuth(c.username, string(c.passwordGetter()))
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read the response: %w", err)
+	}
+	return resp, body, nil
+}
+
+func (c *brokerClient) Acquire(ctx context.Context, rtype string, n uint, priority PriorityClass, requestID string) ([]string, *QueueStatus, error) {
+	params := url.Values{
+		"type":     {rtype},
+		"count":    {strconv.FormatUint(uint64(n), 10)},
+		"priority": {string(priority)},
+		"owner":    {c.owner},
+		"id":       {requestID},
+	}
+	resp, body, err := c.do(ctx, BrokerAcquireEndpoint, params)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		var grant BrokerGrant
+		if err := json.Unmarshal(body, &grant); err != nil {
+			return nil, nil, fmt.Errorf("failed to unmarshal the grant: %w", err)
+		}
+		return grant.Names, nil, nil
+	case http.StatusAccepted:
+		var status QueueStatus
+		if err := json.Unmarshal(body, &status); err != nil {
+			return nil, nil, fmt.Errorf("failed to unmarshal the queue status: %w", err)
+		}
+		return nil, &status, nil
+	case http.StatusNotFound:
+		return nil, nil, ErrTypeNotFound
+	default:
+		return nil, nil, fmt.Errorf("unexpected status code %d from the lease broker: %s", resp.StatusCode, string(body))
+	}
+}
+
+func (c *brokerClient) Acknowledge(requestID string) error {
+	return c.post(BrokerAcknowledgeEndpoint, requestID)
+}
+
+func (c *brokerClient) Cancel(requestID string) error {
+	return c.post(BrokerCancelEndpoint, requestID)
+}
+
+func (c *brokerClient) post(endpoint, requestID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	resp, body, err := c.do(ctx, endpoint, url.Values{"id": {requestID}})
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("unexpected status code %d from the lease broker: %s", resp.StatusCode, string(body))
+	}
+	return nil
+}
+
+// acquireThroughBroker waits in the queue of the broker until the request is granted.
+// Failed calls are retried, as the request stays queued in the broker in the meantime.
+func (c *client) acquireThroughBroker(ctx context.Context, rtype string, n uint, cancel context.CancelFunc) ([]string, error) {
+	requestID := c.opts.randID()
+	var failures int
+	for {
+		names, status, err := c.opts.broker.Acquire(ctx, rtype, n, c.opts.priority, requestID)
+		switch {
+		case err != nil && ctx.Err() == nil:
+			failures++
+			if errors.Is(err, ErrTypeNotFound) || failures >= maxBrokerAttempts {
+				return nil, err
+			}
+			logrus.WithError(err).Warn("Failed to poll the lease broker, retrying")
+		case err == nil && status == nil:
+			if err := c.acknowledge(requestID); err != nil {
+				if cancelErr := c.opts.broker.Cancel(requestID); cancelErr != nil {
+					return nil, fmt.Errorf("%w, failed to cancel the request in the lease broker: %v", err, cancelErr)
+				}
+				return nil, err
+			}
+			c.Lock()
+			for _, name := range names {
+				c.leases[name] = &lease{cancel: cancel, brokered: true}
+			}
+			c.Unlock()
+			return names, nil
+		case status != nil:
+			failures = 0
+			if c.opts.reportQueueStatus != nil {
+				c.opts.reportQueueStatus(rtype, *status)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			if err := c.opts.broker.Cancel(requestID); err != nil {
+				return nil, fmt.Errorf("%w, failed to cancel the request in the lease broker: %v", ctx.Err(), err)
+			}
+			return nil, ctx.Err()
+		case <-time.After(c.opts.brokerPollInterval):
+		}
+	}
+}
+
+// acknowledge confirms the grant, the broker releases the leases of a grant it never sees acknowledged
+func (c *client) acknowledge(requestID string) error {
+	var err error
+	for attempt := 0; attempt < maxBrokerAttempts; attempt++ {
+		if err = c.opts.broker.Acknowledge(requestID); err == nil {
+			return nil
+		}
+		logrus.WithError(err).Warn("Failed to acknowledge the grant of the lease broker, retrying")
+		time.Sleep(c.opts.brokerPollInterval)
+	}
+	return fmt.Errorf("failed to acknowledge the grant of the lease broker: %w", err)
+}
@@ -0,0 +1,61 @@
+package broker
+
+import (
+	boskos "sigs.k8s.io/boskos/client"
+)
+
+const (
+	freeState   = "free"
+	leasedState = "leased"
+)
+
+type boskosAcquirer struct {
+	url            string
+	username       string
+	passwordGetter func() []byte
+}
+
+// NewBoskosAcquirer returns an Acquirer leasing resources from the Boskos server at the URL.
+func NewBoskosAcquirer(url, username string, passwordGetter func() []byte) Acquirer {
+	return &boskosAcquirer{url: url, username: username, passwordGetter: passwordGetter}
+}
+
+// client creates a Boskos client for the owner. Clients are not reused, as they track
+// the acquired resources for their own heartbeats, which are the owner's responsibility.
+func (a *boskosAcquirer) client(owner string) (*boskos.Client, error) {
+	c, err := boskos.NewClientWithPasswordGetter(owner, a.url, a.username, a.passwordGetter)
+	if err != nil {
+		return nil, err
+	}
+	c.DistinguishNotFoundVsTypeNotFound = true
+	return c, nil
+}
+
+func (a *boskosAcquirer) Acquire(owner, rtype string) (string, error) {
+	c, err := a.client(owner)
+	if err != nil {
+		return "", err
+	}
+	r, err := c.Acquire(rtype, freeState, leasedState)
+	if err != nil {
+		return "", err
+	}
+	return r.Name, nil
+}
+
+func (a *boskosAcquirer) Heartbeat(owner, name string) error {
+	c, err := a.client(owner)
+	if err != nil {
+		return err
+	}
+	return c.Update(name, leasedState, nil)
+}
+
+func (a *boskosAcquirer) Release(owner, name string) error {
+	c, err := a.client(owner)
+	if err != nil {
+		return err
+	}
+	// the resource was never acquired through this client, so it is not in its local storage
+	return c.Release(name, freeState)
+}
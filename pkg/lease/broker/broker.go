@@ -0,0 +1,479 @@
+// Package broker implements a lease broker which queues lease requests in front of Boskos
+// and grants them by priority class, so that bursts of low-priority jobs cannot starve
+// higher-priority jobs competing for the same resource type.
+package broker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/openshift/ci-tools/pkg/lease"
+)
+
+const (
+	// grantRateWindow is the window over which the rate of grants is measured to estimate waits
+	grantRateWindow = 30 * time.Minute
+	// heartbeatInterval is how often the broker heartbeats the resources it holds on behalf of
+	// requests that are partially granted or not acknowledged by their clients yet
+	heartbeatInterval = time.Minute
+)
+
+// Acquirer acquires and releases resources in Boskos on behalf of an owner.
+type Acquirer interface {
+	// Acquire leases one free resource without waiting, returning lease.ErrNotFound if none is free.
+	Acquire(owner, rtype string) (string, error)
+	// Heartbeat keeps a resource leased to the owner from being reclaimed by Boskos.
+	Heartbeat(owner, name string) error
+	Release(owner, name string) error
+}
+
+type request struct {
+	id       string
+	owner    string
+	rtype    string
+	count    uint
+	priority lease.PriorityClass
+	enqueued time.Time
+	lastSeen time.Time
+	names    []string
+	// err is set when the request cannot be granted anymore
+	err error
+}
+
+func (r *request) granted() bool {
+	return uint(len(r.names)) >= r.count
+}
+
+// Broker queues lease requests per resource type and grants them by priority.
+// A request waiting for one aging interval is served as if it had the next higher priority
+// class, so low-priority requests are eventually served even under constant pressure.
+// Granted requests are kept until their clients acknowledge them, so a grant whose response
+// is lost is returned again on the next poll, or released once the request is abandoned.
+type Broker struct {
+	mu sync.Mutex
+	// scheduleMu serializes the scheduling passes, which call Boskos without holding mu
+	scheduleMu sync.Mutex
+
+	logger        *logrus.Entry
+	acquirer      Acquirer
+	agingInterval time.Duration
+	// abandonAfter is the time after which requests not polled by their client are dropped
+	abandonAfter time.Duration
+	now          func() time.Time
+
+	requests map[string]*request
+	// grants holds the times of recent grants per resource type
+	grants map[string][]time.Time
+}
+
+// New creates a broker.
+func New(logger *logrus.Entry, acquirer Acquirer, agingInterval, abandonAfter time.Duration) *Broker {
+	return &Broker{
+		logger:        logger,
+		acquirer:      acquirer,
+		agingInterval: agingInterval,
+		abandonAfter:  abandonAfter,
+		now:           time.Now,
+		requests:      map[string]*request{},
+		grants:        map[string][]time.Time{},
+	}
+}
+
+// effectivePriority returns a value by which waiting requests are ordered; lower is served first.
+func (b *Broker) effectivePriority(r *request) time.Duration {
+	return time.Duration(r.priority.Rank())*b.agingInterval - b.now().Sub(r.enqueued)
+}
+
+// queue returns the waiting requests for the resource type in the order they will be served.
+// Must be called with the lock held.
+func (b *Broker) queue(rtype string) []*request {
+	var queue []*request
+	for _, r := range b.requests {
+		if r.rtype == rtype && !r.granted() && r.err == nil {
+			queue = append(queue, r)
+		}
+	}
+	sort.Slice(queue, func(i, j int) bool {
+		pi, pj := b.effectivePriority(queue[i]), b.effectivePriority(queue[j])
+		if pi != pj {
+			return pi < pj
+		}
+		if !queue[i].enqueued.Equal(queue[j].enqueued) {
+			return queue[i].enqueued.Before(queue[j].enqueued)
+		}
+		return queue[i].id < queue[j].id
+	})
+	return queue
+}
+
+// estimatedWait estimates how long it takes until n more resources are granted.
+// Must be called with the lock held.
+func (b *Broker) estimatedWait(rtype string, n int) *int64 {
+	recent := b.grants[rtype]
+	if len(recent) == 0 {
+		return nil
+	}
+	rate := float64(len(recent)) / grantRateWindow.Seconds()
+	wait := int64(float64(n) / rate)
+	return &wait
+}
+
+// Acquire registers the request or refreshes it if it is already known. It returns the
+// lease names if the request was granted, and the queue status otherwise.
+func (b *Broker) Acquire(id, owner, rtype string, count uint, priority lease.PriorityClass) ([]string, *lease.QueueStatus, error) {
+	b.mu.Lock()
+	r, known := b.requests[id]
+	if !known {
+		r = &request{id: id, owner: owner, rtype: rtype, count: count, priority: priority, enqueued: b.now()}
+		b.requests[id] = r
+		b.logger.WithFields(logrus.Fields{"id": id, "owner": owner, "type": rtype, "count": count, "priority": priority}).Info("Queued lease request")
+	}
+	r.lastSeen = b.now()
+	b.mu.Unlock()
+
+	// try to grant new requests right away instead of waiting for the next scheduling pass,
+	// unless a pass is running already and will serve the request anyway
+	if !known && b.scheduleMu.TryLock() {
+		b.schedule(rtype)
+		b.scheduleMu.Unlock()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if r.err != nil {
+		delete(b.requests, id)
+		return nil, nil, r.err
+	}
+	if r.granted() {
+		return r.names, nil, nil
+	}
+	queue := b.queue(rtype)
+	status := &lease.QueueStatus{Depth: len(queue)}
+	var needed int
+	for i, q := range queue {
+		needed += int(q.count) - len(q.names)
+		if q.id == id {
+			status.Position = i + 1
+			break
+		}
+	}
+	status.EstimatedWaitSeconds = b.estimatedWait(rtype, needed)
+	return nil, status, nil
+}
+
+// Acknowledge forgets the granted request once its client took over the resources.
+func (b *Broker) Acknowledge(id string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if r, ok := b.requests[id]; ok && r.granted() {
+		delete(b.requests, id)
+		b.logger.WithField("id", id).Debug("Lease request acknowledged")
+	}
+}
+
+// Cancel withdraws the request and releases the resources acquired for it so far.
+func (b *Broker) Cancel(id string) error {
+	b.mu.Lock()
+	r, ok := b.requests[id]
+	delete(b.requests, id)
+	b.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	b.logger.WithField("id", id).Info("Cancelled lease request")
+	return b.release(r)
+}
+
+func (b *Broker) release(r *request) error {
+	var errs []error
+	for _, name := range r.names {
+		if err := b.acquirer.Release(r.owner, name); err != nil {
+			errs = append(errs, fmt.Errorf("failed to release %s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Schedule grants free resources of the given types to the waiting requests by priority.
+// All resource types with waiting requests are scheduled if none is given.
+func (b *Broker) Schedule(rtypes ...string) {
+	b.scheduleMu.Lock()
+	defer b.scheduleMu.Unlock()
+	b.schedule(rtypes...)
+}
+
+// schedule must be called with scheduleMu held. Boskos is called without holding mu,
+// so clients polling the broker are not blocked by a slow Boskos.
+func (b *Broker) schedule(rtypes ...string) {
+	b.mu.Lock()
+	if len(rtypes) == 0 {
+		types := map[string]bool{}
+		for _, r := range b.requests {
+			types[r.rtype] = true
+		}
+		for rtype := range types {
+			rtypes = append(rtypes, rtype)
+		}
+		sort.Strings(rtypes)
+	}
+	now := b.now()
+	for _, rtype := range rtypes {
+		var recent []time.Time
+		for _, t := range b.grants[rtype] {
+			if now.Sub(t) < grantRateWindow {
+				recent = append(recent, t)
+			}
+		}
+		b.grants[rtype] = recent
+	}
+	b.mu.Unlock()
+
+	for _, rtype := range rtypes {
+		b.scheduleType(rtype)
+	}
+}
+
+// scheduleType acquires resources one at a time for the first request in the queue of the type
+// until the queue is empty or no more resources are free.
+func (b *Broker) scheduleType(rtype string) {
+	for {
+		b.mu.Lock()
+		queue := b.queue(rtype)
+		b.mu.Unlock()
+		if len(queue) == 0 {
+			return
+		}
+		r := queue[0]
+		name, err := b.acquirer.Acquire(r.owner, rtype)
+		if errors.Is(err, lease.ErrNotFound) {
+			// no free resources, nobody else in this queue can be served either
+			return
+		}
+		if err != nil {
+			b.logger.WithError(err).WithField("id", r.id).Warn("Failed to acquire a resource")
+			if !errors.Is(err, lease.ErrTypeNotFound) {
+				return
+			}
+			b.mu.Lock()
+			r.err = err
+			b.mu.Unlock()
+			continue
+		}
+
+		b.mu.Lock()
+		// the request may have been cancelled or dropped while the resource was acquired
+		current := b.requests[r.id] == r
+		var granted bool
+		if current {
+			r.names = append(r.names, name)
+			b.grants[rtype] = append(b.grants[rtype], b.now())
+			granted = r.granted()
+		}
+		b.mu.Unlock()
+		if !current {
+			if err := b.acquirer.Release(r.owner, name); err != nil {
+				b.logger.WithError(err).WithField("id", r.id).Warn("Failed to release a resource acquired for a withdrawn request")
+			}
+			continue
+		}
+		if granted {
+			b.logger.WithFields(logrus.Fields{"id": r.id, "type": rtype, "priority": r.priority, "waited": b.now().Sub(r.enqueued).String()}).Info("Granted lease request")
+		}
+	}
+}
+
+// Heartbeat heartbeats the resources held for requests that are partially granted or not
+// acknowledged yet, which nobody else heartbeats until the clients take them over.
+func (b *Broker) Heartbeat() {
+	type held struct{ id, owner, name string }
+	var resources []held
+	b.mu.Lock()
+	for _, r := range b.requests {
+		for _, name := range r.names {
+			resources = append(resources, held{id: r.id, owner: r.owner, name: name})
+		}
+	}
+	b.mu.Unlock()
+	for _, resource := range resources {
+		if err := b.acquirer.Heartbeat(resource.owner, resource.name); err != nil {
+			b.logger.WithError(err).WithFields(logrus.Fields{"id": resource.id, "name": resource.name}).Warn("Failed to heartbeat a resource")
+		}
+	}
+}
+
+// DropAbandoned drops the requests their clients stopped polling and releases their resources.
+func (b *Broker) DropAbandoned() {
+	b.mu.Lock()
+	var abandoned []*request
+	for id, r := range b.requests {
+		if b.now().Sub(r.lastSeen) > b.abandonAfter {
+			abandoned = append(abandoned, r)
+			delete(b.requests, id)
+		}
+	}
+	b.mu.Unlock()
+	for _, r := range abandoned {
+		b.logger.WithField("id", r.id).Info("Dropping abandoned lease request")
+		if err := b.release(r); err != nil {
+			b.logger.WithError(err).WithField("id", r.id).Warn("Failed to release the resources of an abandoned request")
+		}
+	}
+}
+
+// Run schedules the waiting requests periodically and heartbeats the resources held by the
+// broker until the context is cancelled.
+func (b *Broker) Run(ctx context.Context, interval time.Duration) {
+	go b.heartbeat(ctx)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			b.DropAbandoned()
+			b.Schedule()
+		}
+	}
+}
+
+func (b *Broker) heartbeat(ctx context.Context) {
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			b.Heartbeat()
+		}
+	}
+}
+
+// Queues returns the state of the queue of each resource type with waiting requests.
+func (b *Broker) Queues() map[string]lease.QueueMetrics {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	queues := map[string]lease.QueueMetrics{}
+	for _, r := range b.requests {
+		if r.granted() || r.err != nil {
+			continue
+		}
+		metrics := queues[r.rtype]
+		if metrics.ByPriority == nil {
+			metrics.ByPriority = map[lease.PriorityClass]int{}
+		}
+		metrics.Depth++
+		metrics.ByPriority[r.priority]++
+		queues[r.rtype] = metrics
+	}
+	for rtype, metrics := range queues {
+		var needed int
+		for _, r := range b.queue(rtype) {
+			needed += int(r.count) - len(r.names)
+		}
+		metrics.EstimatedWaitSeconds = b.estimatedWait(rtype, needed)
+		queues[rtype] = metrics
+	}
+	return queues
+}
+
+func (b *Broker) handleAcquire(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, fmt.Sprintf("Method %v not allowed, POST requests only.", r.Method), http.StatusMethodNotAllowed)
+		return
+	}
+	query := r.URL.Query()
+	id, owner, rtype := query.Get("id"), query.Get("owner"), query.Get("type")
+	if id == "" || owner == "" || rtype == "" {
+		http.Error(w, "id, owner and type are required", http.StatusBadRequest)
+		return
+	}
+	count, err := strconv.ParseUint(query.Get("count"), 10, strconv.IntSize)
+	if err != nil || count == 0 {
+		http.Error(w, fmt.Sprintf("parameter \"count\" is not valid: %s", query.Get("count")), http.StatusBadRequest)
+		return
+	}
+	priority, err := lease.ParsePriorityClass(query.Get("priority"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	names, status, err := b.Acquire(id, owner, rtype, uint(count), priority)
+	switch {
+	case errors.Is(err, lease.ErrTypeNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	case status != nil:
+		writeJSON(b.logger, w, http.StatusAccepted, status)
+	default:
+		writeJSON(b.logger, w, http.StatusOK, lease.BrokerGrant{Names: names})
+	}
+}
+
+func (b *Broker) handleAcknowledge(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, fmt.Sprintf("Method %v not allowed, POST requests only.", r.Method), http.StatusMethodNotAllowed)
+		return
+	}
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, "id is required", http.StatusBadRequest)
+		return
+	}
+	b.Acknowledge(id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (b *Broker) handleCancel(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, fmt.Sprintf("Method %v not allowed, POST requests only.", r.Method), http.StatusMethodNotAllowed)
+		return
+	}
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, "id is required", http.StatusBadRequest)
+		return
+	}
+	if err := b.Cancel(id); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (b *Broker) handleQueues(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(b.logger, w, http.StatusOK, b.Queues())
+}
+
+func writeJSON(logger *logrus.Entry, w http.ResponseWriter, code int, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to marshal the response: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if _, err := w.Write(data); err != nil {
+		logger.WithError(err).Warn("Failed to write response")
+	}
+}
+
+// RegisterHandlers adds to the multiplexer the HTTP endpoints served by the broker.
+func (b *Broker) RegisterHandlers(mux *http.ServeMux) {
+	mux.HandleFunc(lease.BrokerAcquireEndpoint, b.handleAcquire)
+	mux.HandleFunc(lease.BrokerAcknowledgeEndpoint, b.handleAcknowledge)
+	mux.HandleFunc(lease.BrokerCancelEndpoint, b.handleCancel)
+	mux.HandleFunc(lease.BrokerQueuesEndpoint, b.handleQueues)
+}
@@ -0,0 +1,294 @@
+package broker
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sirupsen/logrus"
+
+	"github.com/openshift/ci-tools/pkg/lease"
+)
+
+type fakeAcquirer struct {
+	free       map[string][]string
+	released   []string
+	heartbeats []string
+	// acquiring is called for every acquisition before it is made
+	acquiring func()
+}
+
+func (a *fakeAcquirer) Acquire(owner, rtype string) (string, error) {
+	if a.acquiring != nil {
+		a.acquiring()
+	}
+	free, ok := a.free[rtype]
+	if !ok {
+		return "", lease.ErrTypeNotFound
+	}
+	if len(free) == 0 {
+		return "", lease.ErrNotFound
+	}
+	a.free[rtype] = free[1:]
+	return free[0], nil
+}
+
+func (a *fakeAcquirer) Heartbeat(owner, name string) error {
+	a.heartbeats = append(a.heartbeats, fmt.Sprintf("%s:%s", owner, name))
+	return nil
+}
+
+func (a *fakeAcquirer) Release(owner, name string) error {
+	a.released = append(a.released, fmt.Sprintf("%s:%s", owner, name))
+	return nil
+}
+
+func newTestBroker(acquirer Acquirer, now *time.Time) *Broker {
+	b := New(logrus.NewEntry(logrus.New()), acquirer, 10*time.Minute, time.Minute)
+	b.now = func() time.Time { return *now }
+	return b
+}
+
+func TestBrokerPriorities(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	acquirer := &fakeAcquirer{free: map[string][]string{"aws": {}}}
+	b := newTestBroker(acquirer, &now)
+
+	// the queue is full of periodics and rehearsals when a presubmit arrives
+	for i, priority := range []lease.PriorityClass{lease.PriorityRehearsal, lease.PriorityPeriodic, lease.PriorityPeriodic} {
+		if _, _, err := b.Acquire(fmt.Sprintf("early-%d", i), fmt.Sprintf("early-owner-%d", i), "aws", 1, priority); err != nil {
+			t.Fatalf("failed to queue: %v", err)
+		}
+		now = now.Add(time.Minute)
+	}
+	_, status, err := b.Acquire("presubmit", "presubmit-owner", "aws", 1, lease.PriorityPresubmit)
+	if err != nil {
+		t.Fatalf("failed to queue: %v", err)
+	}
+	if diff := cmp.Diff(&lease.QueueStatus{Position: 1, Depth: 4}, status); diff != "" {
+		t.Errorf("presubmit should be first in the queue: %s", diff)
+	}
+
+	acquirer.free["aws"] = []string{"aws-1"}
+	b.Schedule()
+	names, status, err := b.Acquire("presubmit", "presubmit-owner", "aws", 1, lease.PriorityPresubmit)
+	if err != nil {
+		t.Fatalf("failed to poll: %v", err)
+	}
+	if status != nil || len(names) != 1 || names[0] != "aws-1" {
+		t.Fatalf("expected presubmit to be granted aws-1, got %v, status %v", names, status)
+	}
+
+	// after waiting for two aging intervals, the rehearsal is served before a new periodic
+	now = now.Add(20 * time.Minute)
+	if _, _, err := b.Acquire("late", "late-owner", "aws", 1, lease.PriorityPeriodic); err != nil {
+		t.Fatalf("failed to queue: %v", err)
+	}
+	acquirer.free["aws"] = []string{"aws-2"}
+	b.Schedule()
+	names, _, err = b.Acquire("early-1", "early-owner-1", "aws", 1, lease.PriorityPeriodic)
+	if err != nil {
+		t.Fatalf("failed to poll: %v", err)
+	}
+	if diff := cmp.Diff([]string{"aws-2"}, names); diff != "" {
+		t.Errorf("oldest periodic should have been granted: %s", diff)
+	}
+	_, status, err = b.Acquire("early-0", "early-owner-0", "aws", 1, lease.PriorityRehearsal)
+	if err != nil {
+		t.Fatalf("failed to poll: %v", err)
+	}
+	if diff := cmp.Diff(&lease.QueueStatus{Position: 2, Depth: 3, EstimatedWaitSeconds: func() *int64 { v := int64(1800); return &v }()}, status); diff != "" {
+		t.Errorf("aged rehearsal should be ahead of the new periodic: %s", diff)
+	}
+
+	expectedQueues := map[string]lease.QueueMetrics{
+		"aws": {
+			Depth:                3,
+			ByPriority:           map[lease.PriorityClass]int{lease.PriorityPeriodic: 2, lease.PriorityRehearsal: 1},
+			EstimatedWaitSeconds: func() *int64 { v := int64(1800 * 3 / 2); return &v }(),
+		},
+	}
+	if diff := cmp.Diff(expectedQueues, b.Queues()); diff != "" {
+		t.Errorf("queues differ from expected: %s", diff)
+	}
+}
+
+func TestBrokerCancelAndAbandon(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	acquirer := &fakeAcquirer{free: map[string][]string{"gcp": {"gcp-1"}}}
+	b := newTestBroker(acquirer, &now)
+
+	// partially granted request: one of two resources is available
+	if _, _, err := b.Acquire("cancelled", "owner-1", "gcp", 2, lease.PriorityPeriodic); err != nil {
+		t.Fatalf("failed to queue: %v", err)
+	}
+	if err := b.Cancel("cancelled"); err != nil {
+		t.Fatalf("failed to cancel: %v", err)
+	}
+	if diff := cmp.Diff([]string{"owner-1:gcp-1"}, acquirer.released); diff != "" {
+		t.Errorf("released resources differ from expected: %s", diff)
+	}
+
+	acquirer.free["gcp"] = []string{"gcp-2"}
+	if _, _, err := b.Acquire("abandoned", "owner-2", "gcp", 2, lease.PriorityPeriodic); err != nil {
+		t.Fatalf("failed to queue: %v", err)
+	}
+	now = now.Add(30 * time.Second)
+	b.DropAbandoned()
+	if len(b.requests) != 1 {
+		t.Fatalf("request polled recently should not be dropped")
+	}
+	now = now.Add(2 * time.Minute)
+	b.DropAbandoned()
+	if len(b.requests) != 0 {
+		t.Fatalf("abandoned request should be dropped")
+	}
+	if diff := cmp.Diff([]string{"owner-1:gcp-1", "owner-2:gcp-2"}, acquirer.released); diff != "" {
+		t.Errorf("released resources differ from expected: %s", diff)
+	}
+}
+
+func TestBrokerGrantsUntilAcknowledged(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	acquirer := &fakeAcquirer{free: map[string][]string{"aws": {"aws-1"}}}
+	b := newTestBroker(acquirer, &now)
+
+	if _, _, err := b.Acquire("partial", "owner-1", "aws", 2, lease.PriorityPresubmit); err != nil {
+		t.Fatalf("failed to queue: %v", err)
+	}
+	acquirer.free["aws"] = []string{"aws-2"}
+	if _, _, err := b.Acquire("waiting", "owner-2", "aws", 1, lease.PriorityPeriodic); err != nil {
+		t.Fatalf("failed to queue: %v", err)
+	}
+	b.Heartbeat()
+	sort.Strings(acquirer.heartbeats)
+	if diff := cmp.Diff([]string{"owner-1:aws-1", "owner-1:aws-2"}, acquirer.heartbeats); diff != "" {
+		t.Errorf("the resources of the partially granted request were not heartbeated: %s", diff)
+	}
+
+	// the response to the first poll of a granted request may be lost
+	for i := 0; i < 2; i++ {
+		names, status, err := b.Acquire("partial", "owner-1", "aws", 2, lease.PriorityPresubmit)
+		if err != nil {
+			t.Fatalf("failed to poll: %v", err)
+		}
+		if diff := cmp.Diff([]string{"aws-1", "aws-2"}, names); diff != "" || status != nil {
+			t.Fatalf("poll %d: expected the grant, got %v, status %v", i, names, status)
+		}
+	}
+	b.Acknowledge("partial")
+	if _, ok := b.requests["partial"]; ok {
+		t.Error("acknowledged request should be forgotten")
+	}
+	b.Acknowledge("waiting")
+	if _, ok := b.requests["waiting"]; !ok {
+		t.Error("request should not be forgotten before it is granted")
+	}
+}
+
+func TestBrokerAcquiresWithoutLock(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	acquirer := &fakeAcquirer{free: map[string][]string{"aws": {}}}
+	b := newTestBroker(acquirer, &now)
+	if _, _, err := b.Acquire("slow", "owner", "aws", 1, lease.PriorityPresubmit); err != nil {
+		t.Fatalf("failed to queue: %v", err)
+	}
+
+	acquirer.free["aws"] = []string{"aws-1"}
+	acquirer.acquiring = func() {
+		// a poll while Boskos is being called must not block, and may withdraw the request
+		if err := b.Cancel("slow"); err != nil {
+			t.Errorf("failed to cancel: %v", err)
+		}
+	}
+	b.Schedule()
+	if diff := cmp.Diff([]string{"owner:aws-1"}, acquirer.released); diff != "" {
+		t.Errorf("the resource acquired for the withdrawn request was not released: %s", diff)
+	}
+}
+
+func TestBrokerHandlers(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	acquirer := &fakeAcquirer{free: map[string][]string{"aws": {"aws-1"}}}
+	b := newTestBroker(acquirer, &now)
+	mux := http.NewServeMux()
+	b.RegisterHandlers(mux)
+
+	for _, tc := range []struct {
+		name     string
+		method   string
+		url      string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "wrong method",
+			method:   http.MethodGet,
+			url:      "/broker/acquire?type=aws&count=1&priority=presubmit&id=1&owner=o",
+			wantCode: http.StatusMethodNotAllowed,
+			wantBody: "Method GET not allowed, POST requests only.\n",
+		},
+		{
+			name:     "invalid priority",
+			method:   http.MethodPost,
+			url:      "/broker/acquire?type=aws&count=1&priority=urgent&id=1&owner=o",
+			wantCode: http.StatusBadRequest,
+			wantBody: "unknown priority class \"urgent\", valid values: presubmit, release-blocking, periodic, rehearsal\n",
+		},
+		{
+			name:     "granted right away",
+			method:   http.MethodPost,
+			url:      "/broker/acquire?type=aws&count=1&priority=presubmit&id=1&owner=o",
+			wantCode: http.StatusOK,
+			wantBody: `{"names":["aws-1"]}`,
+		},
+		{
+			name:     "queued",
+			method:   http.MethodPost,
+			url:      "/broker/acquire?type=aws&count=1&priority=periodic&id=2&owner=o",
+			wantCode: http.StatusAccepted,
+			wantBody: `{"position":1,"depth":1,"estimatedWaitSeconds":1800}`,
+		},
+		{
+			name:     "unknown type",
+			method:   http.MethodPost,
+			url:      "/broker/acquire?type=azure&count=1&priority=periodic&id=3&owner=o",
+			wantCode: http.StatusNotFound,
+			wantBody: "resource type not found\n",
+		},
+		{
+			name:     "queues",
+			method:   http.MethodGet,
+			url:      "/broker/queues",
+			wantCode: http.StatusOK,
+			wantBody: `{"aws":{"depth":1,"byPriority":{"periodic":1},"estimatedWaitSeconds":1800}}`,
+		},
+		{
+			name:     "acknowledge",
+			method:   http.MethodPost,
+			url:      "/broker/acknowledge?id=1",
+			wantCode: http.StatusNoContent,
+		},
+		{
+			name:     "cancel",
+			method:   http.MethodPost,
+			url:      "/broker/cancel?id=2",
+			wantCode: http.StatusNoContent,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			mux.ServeHTTP(rr, httptest.NewRequest(tc.method, tc.url, nil))
+			if rr.Code != tc.wantCode {
+				t.Errorf("want code %d, got %d", tc.wantCode, rr.Code)
+			}
+			if diff := cmp.Diff(tc.wantBody, rr.Body.String()); diff != "" {
+				t.Errorf("unexpected body: %s", diff)
+			}
+		})
+	}
+}
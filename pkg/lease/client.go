@@ -27,6 +27,9 @@ type boskosClient interface {
 	Acquire(rtype, state, dest string) (*common.Resource, error)
 	UpdateOne(name, dest string, _ *common.UserData) error
 	ReleaseOne(name, dest string) error
+	// Update and Release work on resources not acquired by this client, like those granted by a broker
+	Update(name, dest string, _ *common.UserData) error
+	Release(name, dest string) error
 	ReleaseAll(dest string) error
 	Metric(rtype string) (common.Metric, error)
 }
@@ -38,12 +41,25 @@ var (
 	ErrTypeNotFound = boskos.ErrTypeNotFound
 )
 
+const (
+	// ReservationOwner owns the leases held to honor reservations of resources
+	ReservationOwner = "ci-lease-reservations"
+	// DrainOwner owns the leases held while a resource type is drained for maintenance
+	DrainOwner = "ci-lease-reservations-drain"
+)
+
 type Metrics struct {
 	Free, Leased int
+	// Reserved and Drained are the number of leases held by ReservationOwner and DrainOwner
+	Reserved, Drained int
 }
 
 type clientOptions struct {
-	randID func() string
+	randID             func() string
+	broker             BrokerClient
+	brokerPollInterval time.Duration
+	priority           PriorityClass
+	reportQueueStatus  func(rtype string, status QueueStatus)
 }
 
 type ClientOptions func(*clientOptions)
@@ -52,6 +68,25 @@ func WithRandID(randID func() string) ClientOptions {
 	return func(o *clientOptions) { o.randID = randID }
 }
 
+// WithBroker makes the client queue its requests in a lease broker with the given
+// priority instead of polling Boskos directly.
+func WithBroker(broker BrokerClient, priority PriorityClass) ClientOptions {
+	return func(o *clientOptions) {
+		o.broker = broker
+		o.priority = priority
+	}
+}
+
+// WithBrokerPollInterval sets how often the client refreshes its position in the broker queue.
+func WithBrokerPollInterval(interval time.Duration) ClientOptions {
+	return func(o *clientOptions) { o.brokerPollInterval = interval }
+}
+
+// WithQueueStatusReporter sets a function called with the queue position while waiting in the broker.
+func WithQueueStatusReporter(report func(rtype string, status QueueStatus)) ClientOptions {
+	return func(o *clientOptions) { o.reportQueueStatus = report }
+}
+
 // Client manages resource leases, acquiring, releasing, and keeping them
 // updated.
 type Client interface {
@@ -93,6 +128,7 @@ func newClient(boskos boskosClient, retries int, acquireTimeout time.Duration, o
 		randID: func() string {
 			return strconv.Itoa(rand.Int())
 		},
+		brokerPollInterval: defaultBrokerPollInterval,
 	}
 
 	for _, f := range opts {
@@ -119,6 +155,8 @@ type client struct {
 
 type lease struct {
 	updateFailures int
+	// brokered is set for leases acquired by a broker on behalf of the client
+	brokered bool
 	// cancel holds a cancellation function for steps that depend on leases
 	// being active; we must cancel this when we encounter errors to tie the
 	// lifetime of the downstream user routines to those of the leases they
@@ -130,6 +168,9 @@ func (c *client) Acquire(rtype string, n uint, ctx context.Context, cancel conte
 	var cancelAcquire context.CancelFunc
 	ctx, cancelAcquire = context.WithTimeout(ctx, c.acquireTimeout)
 	defer cancelAcquire()
+	if c.opts.broker != nil {
+		return c.acquireThroughBroker(ctx, rtype, n, cancel)
+	}
 	var ret []string
 	// TODO `m` processes may fight for the last `m * n` remaining leases
 	for i := uint(0); i < n; i++ {
@@ -165,7 +206,11 @@ func (c *client) Heartbeat() error {
 	defer c.Unlock()
 	var errs []error
 	for name, lease := range c.leases {
-		err := c.boskos.UpdateOne(name, leasedState, nil)
+		update := c.boskos.UpdateOne
+		if lease.brokered {
+			update = c.boskos.Update
+		}
+		err := update(name, leasedState, nil)
 		if err == nil {
 			c.leases[name].updateFailures = 0
 			continue
@@ -185,13 +230,21 @@ func (c *client) Heartbeat() error {
 func (c *client) Release(name string) error {
 	c.Lock()
 	defer c.Unlock()
-	if err := c.boskos.ReleaseOne(name, freeState); err != nil {
+	if err := c.release(name); err != nil {
 		return err
 	}
 	delete(c.leases, name)
 	return nil
 }
 
+// release must be called with the lock held
+func (c *client) release(name string) error {
+	if l, ok := c.leases[name]; ok && l.brokered {
+		return c.boskos.Release(name, freeState)
+	}
+	return c.boskos.ReleaseOne(name, freeState)
+}
+
 func (c *client) ReleaseAll() ([]string, error) {
 	c.Lock()
 	defer c.Unlock()
@@ -199,7 +252,7 @@ func (c *client) ReleaseAll() ([]string, error) {
 	var errs []error
 	for l := range c.leases {
 		ret = append(ret, l)
-		if err := c.boskos.ReleaseOne(l, freeState); err != nil {
+		if err := c.release(l); err != nil {
 			errs = append(errs, err)
 			continue
 		}
@@ -214,8 +267,10 @@ func (c *client) Metrics(rtype string) (Metrics, error) {
 		return Metrics{}, err
 	}
 	return Metrics{
-		Free:   metrics.Current[freeState],
-		Leased: metrics.Current[leasedState],
+		Free:     metrics.Current[freeState],
+		Leased:   metrics.Current[leasedState],
+		Reserved: metrics.Owners[ReservationOwner],
+		Drained:  metrics.Owners[DrainOwner],
 	}, nil
 }
 
@@ -3,12 +3,16 @@ package lease
 import (
 	"context"
 	"errors"
+	"fmt"
 	"reflect"
+	"sort"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 
 	"k8s.io/apimachinery/pkg/util/diff"
+	"sigs.k8s.io/boskos/common"
 )
 
 func TestAcquire(t *testing.T) {
@@ -184,3 +188,138 @@ func TestLeases(t *testing.T) {
 	}
 
 }
+
+type fakeBroker struct {
+	// errs are returned by the first calls, statuses by the successive calls before the request is granted
+	errs         []error
+	statuses     []QueueStatus
+	names        []string
+	requests     []string
+	acknowledged []string
+	cancelled    []string
+}
+
+func (b *fakeBroker) Acquire(_ context.Context, rtype string, n uint, priority PriorityClass, requestID string) ([]string, *QueueStatus, error) {
+	b.requests = append(b.requests, fmt.Sprintf("%s %d %s %s", rtype, n, priority, requestID))
+	if len(b.errs) > 0 {
+		err := b.errs[0]
+		b.errs = b.errs[1:]
+		return nil, nil, err
+	}
+	if len(b.statuses) > 0 {
+		status := b.statuses[0]
+		b.statuses = b.statuses[1:]
+		return nil, &status, nil
+	}
+	return b.names, nil, nil
+}
+
+func (b *fakeBroker) Acknowledge(requestID string) error {
+	b.acknowledged = append(b.acknowledged, requestID)
+	return nil
+}
+
+func (b *fakeBroker) Cancel(requestID string) error {
+	b.cancelled = append(b.cancelled, requestID)
+	return nil
+}
+
+func TestAcquireThroughBroker(t *testing.T) {
+	var calls []string
+	broker := &fakeBroker{
+		errs:     []error{errors.New("connection reset by peer")},
+		statuses: []QueueStatus{{Position: 2, Depth: 3}, {Position: 1, Depth: 2}},
+		names:    []string{"aws-1", "aws-2"},
+	}
+	var reported []QueueStatus
+	client := newClient(&fakeClient{owner: "owner", calls: &calls, resources: map[string]*common.Resource{}}, 0, time.Minute,
+		WithRandID(func() string { return "random" }),
+		WithBroker(broker, PriorityPeriodic),
+		WithBrokerPollInterval(time.Millisecond),
+		WithQueueStatusReporter(func(_ string, status QueueStatus) { reported = append(reported, status) }),
+	)
+	names, err := client.Acquire("aws", 2, context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"aws-1", "aws-2"}, names); diff != "" {
+		t.Errorf("unexpected names: %s", diff)
+	}
+	if diff := cmp.Diff([]string{"aws 2 periodic random", "aws 2 periodic random", "aws 2 periodic random", "aws 2 periodic random"}, broker.requests); diff != "" {
+		t.Errorf("unexpected requests to the broker: %s", diff)
+	}
+	if diff := cmp.Diff([]string{"random"}, broker.acknowledged); diff != "" {
+		t.Errorf("the grant was not acknowledged: %s", diff)
+	}
+	if diff := cmp.Diff([]QueueStatus{{Position: 2, Depth: 3}, {Position: 1, Depth: 2}}, reported); diff != "" {
+		t.Errorf("unexpected queue status reports: %s", diff)
+	}
+	if err := client.Heartbeat(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.ReleaseAll(); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"update owner aws-1 leased",
+		"update owner aws-2 leased",
+		"release owner aws-1 free",
+		"release owner aws-2 free",
+	}
+	sort.Strings(calls[:2])
+	sort.Strings(calls[2:])
+	if diff := cmp.Diff(expected, calls); diff != "" {
+		t.Errorf("wrong calls to the boskos client: %s", diff)
+	}
+}
+
+func TestAcquireThroughBrokerErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		errs          []error
+		expectedCalls int
+	}{
+		{
+			name:          "unknown resource types are not retried",
+			errs:          []error{ErrTypeNotFound},
+			expectedCalls: 1,
+		},
+		{
+			name:          "transient errors are retried up to the limit",
+			errs:          []error{errors.New("1"), errors.New("2"), errors.New("3"), errors.New("4"), errors.New("5")},
+			expectedCalls: maxBrokerAttempts,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			broker := &fakeBroker{errs: tc.errs}
+			client := newClient(&fakeClient{owner: "owner", calls: &[]string{}, resources: map[string]*common.Resource{}}, 0, time.Minute,
+				WithRandID(func() string { return "random" }),
+				WithBroker(broker, PriorityPresubmit),
+				WithBrokerPollInterval(time.Millisecond),
+			)
+			if _, err := client.Acquire("aws", 1, context.Background(), nil); err != tc.errs[len(tc.errs)-1] {
+				t.Fatalf("expected the last error, got %v", err)
+			}
+			if len(broker.requests) != tc.expectedCalls {
+				t.Errorf("expected %d calls to the broker, got %d", tc.expectedCalls, len(broker.requests))
+			}
+		})
+	}
+}
+
+func TestAcquireThroughBrokerCancel(t *testing.T) {
+	broker := &fakeBroker{statuses: []QueueStatus{{Position: 1, Depth: 1}}}
+	client := newClient(&fakeClient{owner: "owner", calls: &[]string{}, resources: map[string]*common.Resource{}}, 0, time.Minute,
+		WithRandID(func() string { return "random" }),
+		WithBroker(broker, PriorityPresubmit),
+		WithBrokerPollInterval(time.Hour),
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := client.Acquire("aws", 1, ctx, nil); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the acquisition to be cancelled, got %v", err)
+	}
+	if diff := cmp.Diff([]string{"random"}, broker.cancelled); diff != "" {
+		t.Errorf("the request was not cancelled in the broker: %s", diff)
+	}
+}
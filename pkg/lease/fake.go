@@ -15,9 +15,15 @@ type fakeClient struct {
 	failures  map[string]error
 	calls     *[]string
 	resources map[string]*common.Resource
+	metrics   map[string]common.Metric
 }
 
 func NewFakeClient(owner, url string, retries int, failures map[string]error, calls *[]string, resources map[string]*common.Resource) Client {
+	return NewFakeClientWithMetrics(owner, url, retries, failures, calls, resources, nil)
+}
+
+// NewFakeClientWithMetrics creates a fake client which reports the given metrics per resource type.
+func NewFakeClientWithMetrics(owner, url string, retries int, failures map[string]error, calls *[]string, resources map[string]*common.Resource, metrics map[string]common.Metric) Client {
 	if calls == nil {
 		calls = &[]string{}
 	}
@@ -29,6 +35,7 @@ func NewFakeClient(owner, url string, retries int, failures map[string]error, ca
 		failures:  failures,
 		calls:     calls,
 		resources: resources,
+		metrics:   metrics,
 	}, retries, time.Duration(0), WithRandID(func() string { return "random" }))
 }
 
@@ -68,10 +75,21 @@ func (c *fakeClient) ReleaseOne(name, dest string) error {
 	return c.addCall("releaseone", name, dest)
 }
 
+func (c *fakeClient) Update(name, dest string, _ *common.UserData) error {
+	return c.addCall("update", name, dest)
+}
+
+func (c *fakeClient) Release(name, dest string) error {
+	return c.addCall("release", name, dest)
+}
+
 func (c *fakeClient) ReleaseAll(dest string) error {
 	return c.addCall("releaseall", dest)
 }
 
-func (*fakeClient) Metric(rtype string) (common.Metric, error) {
+func (c *fakeClient) Metric(rtype string) (common.Metric, error) {
+	if m, ok := c.metrics[rtype]; ok {
+		return m, nil
+	}
 	return common.NewMetric(rtype), nil
 }
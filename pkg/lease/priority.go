@@ -0,0 +1,67 @@
+package lease
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	prowapi "sigs.k8s.io/prow/pkg/apis/prowjobs/v1"
+
+	"github.com/openshift/ci-tools/pkg/api"
+)
+
+// PriorityClass orders lease requests competing for the same resource type.
+type PriorityClass string
+
+const (
+	PriorityPresubmit       PriorityClass = "presubmit"
+	PriorityReleaseBlocking PriorityClass = "release-blocking"
+	PriorityPeriodic        PriorityClass = "periodic"
+	PriorityRehearsal       PriorityClass = "rehearsal"
+)
+
+var (
+	// priorityClasses are ordered from the highest priority to the lowest
+	priorityClasses = []PriorityClass{PriorityPresubmit, PriorityReleaseBlocking, PriorityPeriodic, PriorityRehearsal}
+
+	// releaseJobRE matches the periodics run by the release controller
+	releaseJobRE = regexp.MustCompile(`^(periodic-ci-openshift-release-[^-]+-(nightly|ci)-|release-openshift-)`)
+)
+
+// Rank returns the rank of the priority class; requests with a lower rank are served first.
+// Unknown classes are ranked with the lowest priority.
+func (p PriorityClass) Rank() int {
+	if rank := slices.Index(priorityClasses, p); rank != -1 {
+		return rank
+	}
+	return len(priorityClasses)
+}
+
+// ParsePriorityClass validates a priority class
+func ParsePriorityClass(s string) (PriorityClass, error) {
+	if !slices.Contains(priorityClasses, PriorityClass(s)) {
+		var valid []string
+		for _, p := range priorityClasses {
+			valid = append(valid, string(p))
+		}
+		return "", fmt.Errorf("unknown priority class %q, valid values: %s", s, strings.Join(valid, ", "))
+	}
+	return PriorityClass(s), nil
+}
+
+// PriorityClassForJob derives the priority class of the leases requested by a job.
+// Rehearsals are recognized by their name prefix and release-blocking jobs by the
+// naming convention of the periodics run by the release controller.
+func PriorityClassForJob(spec *api.JobSpec) PriorityClass {
+	switch {
+	case strings.HasPrefix(spec.Job, "rehearse-"):
+		return PriorityRehearsal
+	case spec.Type == prowapi.PresubmitJob || spec.Type == prowapi.BatchJob:
+		return PriorityPresubmit
+	case releaseJobRE.MatchString(spec.Job):
+		return PriorityReleaseBlocking
+	default:
+		return PriorityPeriodic
+	}
+}
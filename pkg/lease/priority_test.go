@@ -0,0 +1,98 @@
+package lease
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	prowapi "sigs.k8s.io/prow/pkg/apis/prowjobs/v1"
+	"sigs.k8s.io/prow/pkg/pod-utils/downwardapi"
+
+	"github.com/openshift/ci-tools/pkg/api"
+	"github.com/openshift/ci-tools/pkg/testhelper"
+)
+
+func jobSpec(job string, jobType prowapi.ProwJobType) api.JobSpec {
+	return api.JobSpec{JobSpec: downwardapi.JobSpec{Job: job, Type: jobType}}
+}
+
+func TestPriorityClassForJob(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		spec     api.JobSpec
+		expected PriorityClass
+	}{
+		{
+			name:     "presubmit",
+			spec:     jobSpec("pull-ci-openshift-installer-master-e2e-aws", prowapi.PresubmitJob),
+			expected: PriorityPresubmit,
+		},
+		{
+			name:     "batch",
+			spec:     jobSpec("pull-ci-openshift-installer-master-e2e-aws", prowapi.BatchJob),
+			expected: PriorityPresubmit,
+		},
+		{
+			name:     "rehearsal",
+			spec:     jobSpec("rehearse-1234-pull-ci-openshift-installer-master-e2e-aws", prowapi.PresubmitJob),
+			expected: PriorityRehearsal,
+		},
+		{
+			name:     "release-blocking nightly",
+			spec:     jobSpec("periodic-ci-openshift-release-master-nightly-4.16-e2e-aws-ovn", prowapi.PeriodicJob),
+			expected: PriorityReleaseBlocking,
+		},
+		{
+			name:     "release-blocking legacy",
+			spec:     jobSpec("release-openshift-origin-installer-e2e-aws-upgrade", prowapi.PeriodicJob),
+			expected: PriorityReleaseBlocking,
+		},
+		{
+			name:     "periodic",
+			spec:     jobSpec("periodic-ci-openshift-installer-master-e2e-aws", prowapi.PeriodicJob),
+			expected: PriorityPeriodic,
+		},
+		{
+			name:     "postsubmit",
+			spec:     jobSpec("branch-ci-openshift-installer-master-images", prowapi.PostsubmitJob),
+			expected: PriorityPeriodic,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.expected, PriorityClassForJob(&tc.spec)); diff != "" {
+				t.Errorf("unexpected priority class: %s", diff)
+			}
+		})
+	}
+}
+
+func TestParsePriorityClass(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		value       string
+		expected    PriorityClass
+		expectedErr error
+	}{
+		{
+			name:     "valid",
+			value:    "release-blocking",
+			expected: PriorityReleaseBlocking,
+		},
+		{
+			name:        "invalid",
+			value:       "urgent",
+			expectedErr: errors.New(`unknown priority class "urgent", valid values: presubmit, release-blocking, periodic, rehearsal`),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			actual, err := ParsePriorityClass(tc.value)
+			if diff := cmp.Diff(tc.expectedErr, err, testhelper.EquateErrorMessage); diff != "" {
+				t.Errorf("unexpected error: %s", diff)
+			}
+			if diff := cmp.Diff(tc.expected, actual); diff != "" {
+				t.Errorf("unexpected priority class: %s", diff)
+			}
+		})
+	}
+}
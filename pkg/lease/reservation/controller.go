@@ -0,0 +1,157 @@
+package reservation
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/openshift/ci-tools/pkg/lease"
+)
+
+// Status describes how a reservation is honored
+type Status struct {
+	Name         string
+	ResourceType string
+	Active       bool
+	// Held are the names of the resources held for the reservation
+	Held []string
+}
+
+// Controller reconciles the reservations by holding leases. Resources reserved by a
+// count are held by lease.ReservationOwner, drained ones by lease.DrainOwner, so that
+// jobs can tell that a resource type is drained from the lease server metrics.
+//
+// Held leases are only known to the controller process: after a restart, the leases
+// held previously expire in the lease server and are acquired again.
+type Controller struct {
+	logger *logrus.Entry
+	// reserve and drain are clients for lease.ReservationOwner and lease.DrainOwner
+	reserve, drain lease.Client
+	now            func() time.Time
+
+	// held maps reservation names to the names of the resources held for them
+	held map[string][]string
+	// drained records which reservations are drains, so they are released with the right client
+	drained map[string]bool
+}
+
+// NewController creates a controller using clients for the reservation and drain owners.
+func NewController(logger *logrus.Entry, reserve, drain lease.Client) *Controller {
+	return &Controller{
+		logger:  logger,
+		reserve: reserve,
+		drain:   drain,
+		now:     time.Now,
+		held:    map[string][]string{},
+		drained: map[string]bool{},
+	}
+}
+
+func (c *Controller) client(drain bool) lease.Client {
+	if drain {
+		return c.drain
+	}
+	return c.reserve
+}
+
+// releaseHeld releases resources held for a reservation, keeping the first `keep`.
+func (c *Controller) releaseHeld(name string, keep int) error {
+	client := c.client(c.drained[name])
+	var errs []error
+	var remaining []string
+	for i, resource := range c.held[name] {
+		if i < keep {
+			remaining = append(remaining, resource)
+			continue
+		}
+		if err := client.Release(resource); err != nil {
+			errs = append(errs, fmt.Errorf("failed to release %s held for reservation %s: %w", resource, name, err))
+			remaining = append(remaining, resource)
+			continue
+		}
+		c.logger.WithFields(logrus.Fields{"reservation": name, "resource": resource}).Info("Released reserved resource")
+	}
+	if len(remaining) == 0 {
+		delete(c.held, name)
+		delete(c.drained, name)
+	} else {
+		c.held[name] = remaining
+	}
+	return errors.Join(errs...)
+}
+
+// Reconcile acquires and releases leases so that the resources held match the
+// reservations active at this time, and keeps the held leases alive.
+func (c *Controller) Reconcile(config *Config) ([]Status, error) {
+	now := c.now()
+	var errs []error
+
+	wanted := map[string]Reservation{}
+	for _, r := range config.Reservations {
+		if r.Active(now) {
+			wanted[r.Name] = r
+		}
+	}
+	var names []string
+	for name := range c.held {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		r, ok := wanted[name]
+		switch {
+		case !ok || r.Drain != c.drained[name]:
+			errs = append(errs, c.releaseHeld(name, 0))
+		case !r.Drain && len(c.held[name]) > int(r.Count):
+			errs = append(errs, c.releaseHeld(name, int(r.Count)))
+		}
+	}
+
+	var statuses []Status
+	for _, r := range config.Reservations {
+		status := Status{Name: r.Name, ResourceType: r.ResourceType}
+		if _, active := wanted[r.Name]; active {
+			status.Active = true
+			c.drained[r.Name] = r.Drain
+			logger := c.logger.WithFields(logrus.Fields{"reservation": r.Name, "type": r.ResourceType})
+			for r.Drain || len(c.held[r.Name]) < int(r.Count) {
+				names, err := c.client(r.Drain).AcquireIfAvailableImmediately(r.ResourceType, 1, func() {})
+				if errors.Is(err, lease.ErrNotFound) {
+					break
+				}
+				if err != nil {
+					errs = append(errs, fmt.Errorf("failed to acquire %s for reservation %s: %w", r.ResourceType, r.Name, err))
+					break
+				}
+				logger.WithField("resource", names[0]).Info("Acquired reserved resource")
+				c.held[r.Name] = append(c.held[r.Name], names...)
+			}
+		}
+		status.Held = c.held[r.Name]
+		statuses = append(statuses, status)
+	}
+
+	alive := sets.New[string]()
+	for _, client := range []lease.Client{c.reserve, c.drain} {
+		if err := client.Heartbeat(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to update held leases: %w", err))
+		}
+		alive.Insert(client.Leases()...)
+	}
+	// leases the clients gave up on after failing to update them are acquired again on the next reconciliation
+	for name, held := range c.held {
+		var remaining []string
+		for _, resource := range held {
+			if alive.Has(resource) {
+				remaining = append(remaining, resource)
+			}
+		}
+		c.held[name] = remaining
+	}
+	return statuses, errors.Join(errs...)
+}
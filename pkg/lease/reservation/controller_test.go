@@ -0,0 +1,142 @@
+package reservation
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/openshift/ci-tools/pkg/lease"
+	"github.com/openshift/ci-tools/pkg/testhelper"
+)
+
+// pool is a lease server shared by the fake clients
+type pool map[string][]string
+
+type fakeClient struct {
+	lease.Client
+	pool   pool
+	leases sets.Set[string]
+	// expire makes the next heartbeat drop the lease
+	expire string
+}
+
+func newFakeClient(p pool) *fakeClient {
+	return &fakeClient{pool: p, leases: sets.New[string]()}
+}
+
+func (c *fakeClient) AcquireIfAvailableImmediately(rtype string, n uint, _ context.CancelFunc) ([]string, error) {
+	free, ok := c.pool[rtype]
+	if !ok {
+		return nil, lease.ErrTypeNotFound
+	}
+	if uint(len(free)) < n {
+		return nil, lease.ErrNotFound
+	}
+	c.pool[rtype] = free[n:]
+	c.leases.Insert(free[:n]...)
+	return free[:n], nil
+}
+
+func (c *fakeClient) Release(name string) error {
+	if !c.leases.Has(name) {
+		return errors.New("not leased")
+	}
+	c.leases.Delete(name)
+	rtype := name[:len(name)-2]
+	c.pool[rtype] = append(c.pool[rtype], name)
+	return nil
+}
+
+func (c *fakeClient) Heartbeat() error {
+	if c.leases.Has(c.expire) {
+		// the lease server frees the resource once the lease expires
+		c.leases.Delete(c.expire)
+		rtype := c.expire[:len(c.expire)-2]
+		c.pool[rtype] = append(c.pool[rtype], c.expire)
+		return errors.New("exceeded number of retries")
+	}
+	return nil
+}
+
+func (c *fakeClient) Leases() []string {
+	return sets.List(c.leases)
+}
+
+func TestReconcile(t *testing.T) {
+	start := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+	now := start.Add(-time.Minute)
+	p := pool{"aws": {"aws-0", "aws-1", "aws-2"}, "gcp": {"gcp-0", "gcp-1"}}
+	reserve, drain := newFakeClient(p), newFakeClient(p)
+	c := NewController(logrus.NewEntry(logrus.New()), reserve, drain)
+	c.now = func() time.Time { return now }
+
+	config := &Config{Reservations: []Reservation{
+		{Name: "hold", ResourceType: "aws", Count: 2, Start: &start, End: &end},
+		{Name: "maintenance", ResourceType: "gcp", Drain: true},
+	}}
+	statuses, err := c.Reconcile(config)
+	if err != nil {
+		t.Fatalf("failed to reconcile: %v", err)
+	}
+	expected := []Status{
+		{Name: "hold", ResourceType: "aws"},
+		{Name: "maintenance", ResourceType: "gcp", Active: true, Held: []string{"gcp-0", "gcp-1"}},
+	}
+	if diff := cmp.Diff(expected, statuses); diff != "" {
+		t.Errorf("before the hold starts, statuses differ from expected: %s", diff)
+	}
+
+	now = start
+	drain.expire = "gcp-1"
+	statuses, err = c.Reconcile(config)
+	testhelper.Diff(t, "error", err, errors.New("failed to update held leases: exceeded number of retries"), testhelper.EquateErrorMessage)
+	expected = []Status{
+		{Name: "hold", ResourceType: "aws", Active: true, Held: []string{"aws-0", "aws-1"}},
+		{Name: "maintenance", ResourceType: "gcp", Active: true, Held: []string{"gcp-0", "gcp-1"}},
+	}
+	if diff := cmp.Diff(expected, statuses); diff != "" {
+		t.Errorf("when the hold starts, statuses differ from expected: %s", diff)
+	}
+	if diff := cmp.Diff([]string{"aws-0", "aws-1"}, reserve.Leases()); diff != "" {
+		t.Errorf("reserved leases differ from expected: %s", diff)
+	}
+	if diff := cmp.Diff([]string{"gcp-0"}, drain.Leases()); diff != "" {
+		t.Errorf("drained leases differ from expected: %s", diff)
+	}
+
+	// the hold is reduced and the maintenance is over
+	config = &Config{Reservations: []Reservation{
+		{Name: "hold", ResourceType: "aws", Count: 1, Start: &start, End: &end},
+	}}
+	statuses, err = c.Reconcile(config)
+	if err != nil {
+		t.Fatalf("failed to reconcile: %v", err)
+	}
+	expected = []Status{{Name: "hold", ResourceType: "aws", Active: true, Held: []string{"aws-0"}}}
+	if diff := cmp.Diff(expected, statuses); diff != "" {
+		t.Errorf("after the update, statuses differ from expected: %s", diff)
+	}
+	if len(drain.Leases()) != 0 {
+		t.Errorf("drained resources were not released: %v", drain.Leases())
+	}
+
+	now = end
+	statuses, err = c.Reconcile(config)
+	if err != nil {
+		t.Fatalf("failed to reconcile: %v", err)
+	}
+	expected = []Status{{Name: "hold", ResourceType: "aws"}}
+	if diff := cmp.Diff(expected, statuses); diff != "" {
+		t.Errorf("after the hold ends, statuses differ from expected: %s", diff)
+	}
+	if diff := cmp.Diff(pool{"aws": {"aws-2", "aws-1", "aws-0"}, "gcp": {"gcp-1", "gcp-0"}}, p); diff != "" {
+		t.Errorf("not all resources were returned to the pool: %s", diff)
+	}
+}
@@ -0,0 +1,88 @@
+// Package reservation implements declarative reservations of leased resources: holding
+// a number of resources of a type for a time window, or draining a type for maintenance.
+// Reservations are honored by acquiring and holding leases under dedicated owners.
+package reservation
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/yaml"
+)
+
+// Reservation holds resources of a type, either a fixed number or all of them
+type Reservation struct {
+	// Name identifies the reservation
+	Name string `json:"name"`
+	// ResourceType is the Boskos resource type to reserve
+	ResourceType string `json:"resource_type"`
+	// Count is the number of resources to hold
+	Count uint `json:"count,omitempty"`
+	// Drain holds all resources of the type as they are released, for maintenance
+	Drain bool `json:"drain,omitempty"`
+	// Start is the beginning of the reservation, which starts immediately if unset
+	Start *time.Time `json:"start,omitempty"`
+	// End is the end of the reservation, which lasts until it is removed if unset
+	End *time.Time `json:"end,omitempty"`
+	// Reason explains the reservation to humans
+	Reason string `json:"reason,omitempty"`
+}
+
+// Active determines whether the reservation must be honored at the time
+func (r *Reservation) Active(now time.Time) bool {
+	if r.Start != nil && now.Before(*r.Start) {
+		return false
+	}
+	if r.End != nil && !now.Before(*r.End) {
+		return false
+	}
+	return true
+}
+
+// Config is the list of reservations to honor
+type Config struct {
+	Reservations []Reservation `json:"reservations"`
+}
+
+// Validate checks the reservations for errors
+func (c *Config) Validate() error {
+	var errs []error
+	names := sets.New[string]()
+	for i, r := range c.Reservations {
+		if r.Name == "" {
+			errs = append(errs, fmt.Errorf("reservations[%d]: name must be set", i))
+		} else if names.Has(r.Name) {
+			errs = append(errs, fmt.Errorf("reservations[%d]: duplicate name %q", i, r.Name))
+		}
+		names.Insert(r.Name)
+		if r.ResourceType == "" {
+			errs = append(errs, fmt.Errorf("reservations[%d]: resource_type must be set", i))
+		}
+		if r.Drain == (r.Count != 0) {
+			errs = append(errs, fmt.Errorf("reservations[%d]: exactly one of count and drain must be set", i))
+		}
+		if r.Start != nil && r.End != nil && !r.End.After(*r.Start) {
+			errs = append(errs, fmt.Errorf("reservations[%d]: end must be after start", i))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// LoadConfig loads and validates the reservations from a file
+func LoadConfig(path string) (*Config, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read reservations from %s: %w", path, err)
+	}
+	var config Config
+	if err := yaml.UnmarshalStrict(raw, &config); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal reservations from %s: %w", path, err)
+	}
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid reservations in %s: %w", path, err)
+	}
+	return &config, nil
+}
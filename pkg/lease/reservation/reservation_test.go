@@ -0,0 +1,68 @@
+package reservation
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/openshift/ci-tools/pkg/testhelper"
+)
+
+func TestValidate(t *testing.T) {
+	start := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+	for _, tc := range []struct {
+		name     string
+		config   Config
+		expected error
+	}{
+		{
+			name: "valid",
+			config: Config{Reservations: []Reservation{
+				{Name: "hold", ResourceType: "aws", Count: 2, Start: &start, End: &end},
+				{Name: "maintenance", ResourceType: "gcp", Drain: true, Reason: "account migration"},
+			}},
+		},
+		{
+			name: "invalid",
+			config: Config{Reservations: []Reservation{
+				{Name: "hold", Count: 2, Start: &end, End: &start},
+				{Name: "hold", ResourceType: "gcp", Count: 1, Drain: true},
+				{ResourceType: "gcp"},
+			}},
+			expected: errors.New(`reservations[0]: resource_type must be set
+reservations[0]: end must be after start
+reservations[1]: duplicate name "hold"
+reservations[1]: exactly one of count and drain must be set
+reservations[2]: name must be set
+reservations[2]: exactly one of count and drain must be set`),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.expected, tc.config.Validate(), testhelper.EquateErrorMessage); diff != "" {
+				t.Errorf("unexpected error: %s", diff)
+			}
+		})
+	}
+}
+
+func TestActive(t *testing.T) {
+	start := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+	r := Reservation{Start: &start, End: &end}
+	for _, tc := range []struct {
+		now      time.Time
+		expected bool
+	}{
+		{now: start.Add(-time.Second)},
+		{now: start, expected: true},
+		{now: end.Add(-time.Second), expected: true},
+		{now: end},
+	} {
+		if actual := r.Active(tc.now); actual != tc.expected {
+			t.Errorf("at %s: expected active to be %t, got %t", tc.now, tc.expected, actual)
+		}
+	}
+}
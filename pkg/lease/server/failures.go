@@ -0,0 +1,130 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// failures are injected into the Boskos protocol endpoints
+type failures struct {
+	mu sync.Mutex
+	// errors holds the status codes returned by the next requests per endpoint
+	errors map[string][]int
+	// delays holds the time each request to an endpoint is delayed by
+	delays map[string]time.Duration
+}
+
+// InjectErrors makes the next count requests to the endpoint (e.g. "/acquire") fail with the status code.
+func (s *Server) InjectErrors(endpoint string, status, count int) {
+	s.failures.mu.Lock()
+	defer s.failures.mu.Unlock()
+	for i := 0; i < count; i++ {
+		s.failures.errors[endpoint] = append(s.failures.errors[endpoint], status)
+	}
+}
+
+// InjectDelay delays every request to the endpoint by the duration; a zero duration removes the delay.
+func (s *Server) InjectDelay(endpoint string, delay time.Duration) {
+	s.failures.mu.Lock()
+	defer s.failures.mu.Unlock()
+	if delay == 0 {
+		delete(s.failures.delays, endpoint)
+		return
+	}
+	s.failures.delays[endpoint] = delay
+}
+
+// next returns the delay and the status code to inject into the request, if any
+func (f *failures) next(endpoint string) (time.Duration, int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var status int
+	if pending := f.errors[endpoint]; len(pending) > 0 {
+		status = pending[0]
+		f.errors[endpoint] = pending[1:]
+	}
+	return f.delays[endpoint], status
+}
+
+func (s *Server) inject(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		delay, status := s.failures.next(r.URL.Path)
+		if delay > 0 {
+			select {
+			case <-time.After(delay):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		if status != 0 {
+			s.logger.WithFields(logrus.Fields{"endpoint": r.URL.Path, "status": status}).Info("Injecting failure")
+			http.Error(w, fmt.Sprintf("injected failure: %s", http.StatusText(status)), status)
+			return
+		}
+		handler(w, r)
+	}
+}
+
+func (s *Server) handleInjectErrors(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	endpoint := query.Get("endpoint")
+	status, statusErr := strconv.Atoi(query.Get("status"))
+	count, countErr := strconv.Atoi(query.Get("count"))
+	if endpoint == "" || statusErr != nil || countErr != nil || status < 400 || count < 1 {
+		http.Error(w, "endpoint, an error status and a positive count must be set in the request.", http.StatusBadRequest)
+		return
+	}
+	s.InjectErrors(endpoint, status, count)
+}
+
+func (s *Server) handleInjectDelay(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	endpoint := query.Get("endpoint")
+	delay, err := time.ParseDuration(query.Get("delay"))
+	if endpoint == "" || err != nil || delay < 0 {
+		http.Error(w, "endpoint and a non-negative delay must be set in the request.", http.StatusBadRequest)
+		return
+	}
+	s.InjectDelay(endpoint, delay)
+}
+
+func (s *Server) handleLoseLease(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		http.Error(w, "name must be set in the request.", http.StatusBadRequest)
+		return
+	}
+	if err := s.LoseLease(name); err != nil {
+		http.Error(w, err.Error(), errorToStatus(err))
+	}
+}
+
+func (s *Server) handleExpire(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(s.logger, w, s.Expire())
+}
+
+func (s *Server) handleResources(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(s.logger, w, s.Resources())
+}
+
+// AdminHandler returns the handler used by tests to inspect the server and inject failures:
+//
+//	POST /admin/errors?endpoint=/acquire&status=503&count=2: fail the next requests to an endpoint
+//	POST /admin/delay?endpoint=/acquire&delay=30s: delay the requests to an endpoint
+//	POST /admin/lose?name=aws-1: free a leased resource behind the back of its owner
+//	POST /admin/expire: expire the leases without a recent heartbeat right away
+//	GET /admin/resources: list the resources
+func (s *Server) AdminHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/admin/errors", method(http.MethodPost, s.handleInjectErrors))
+	mux.HandleFunc("/admin/delay", method(http.MethodPost, s.handleInjectDelay))
+	mux.HandleFunc("/admin/lose", method(http.MethodPost, s.handleLoseLease))
+	mux.HandleFunc("/admin/expire", method(http.MethodPost, s.handleExpire))
+	mux.HandleFunc("/admin/resources", method(http.MethodGet, s.handleResources))
+	return mux
+}
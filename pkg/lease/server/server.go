@@ -0,0 +1,461 @@
+// Package server implements an in-memory lease server speaking the Boskos HTTP protocol.
+// It is meant to run ci-operator and the lease proxy end-to-end without a real Boskos:
+// it supports static and dynamic resource types, expires leases whose holders stop
+// sending heartbeats and allows to inject failures into the protocol.
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"sigs.k8s.io/boskos/common"
+)
+
+const (
+	// requestTTL is the time after which a queued request_id that was not polled again loses its place
+	requestTTL = 30 * time.Second
+)
+
+var (
+	errNotFound      = errors.New("resource not found")
+	errOwnerMismatch = errors.New("owner mismatch")
+	errStateMismatch = errors.New("state mismatch")
+)
+
+// typeNotFoundError carries the message the Boskos client uses to tell
+// a missing resource type apart from a busy one
+type typeNotFoundError string
+
+func (e typeNotFoundError) Error() string {
+	return common.ResourceTypeNotFoundMessage(string(e))
+}
+
+func errorToStatus(err error) int {
+	var typeNotFound typeNotFoundError
+	switch {
+	case errors.Is(err, errNotFound), errors.As(err, &typeNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, errOwnerMismatch):
+		return http.StatusUnauthorized
+	case errors.Is(err, errStateMismatch):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+type resourceType struct {
+	// dynamic resources are created on demand up to maxCount and deleted when released
+	dynamic  bool
+	maxCount int
+	// created counts the dynamic resources created so far, to name new ones
+	created int
+}
+
+type queuedRequest struct {
+	id       string
+	lastSeen time.Time
+}
+
+// Server is an in-memory lease server
+type Server struct {
+	mu sync.Mutex
+
+	logger *logrus.Entry
+	now    func() time.Time
+	// expiry is the time after which leases which were not updated are freed
+	expiry time.Duration
+
+	types     map[string]*resourceType
+	resources map[string]*common.Resource
+	// queues holds the request IDs waiting for a resource type, in the order they are served
+	queues map[string][]queuedRequest
+
+	failures failures
+}
+
+// New creates a server holding the resources from the Boskos configuration.
+// Entries without names are dynamic resources: min-count of them are created
+// right away and more are created when they are acquired, up to max-count.
+func New(logger *logrus.Entry, config *common.BoskosConfig, expiry time.Duration) (*Server, error) {
+	s := &Server{
+		logger:    logger,
+		now:       time.Now,
+		expiry:    expiry,
+		types:     map[string]*resourceType{},
+		resources: map[string]*common.Resource{},
+		queues:    map[string][]queuedRequest{},
+		failures:  failures{errors: map[string][]int{}, delays: map[string]time.Duration{}},
+	}
+	for _, entry := range config.Resources {
+		if _, exists := s.types[entry.Type]; exists {
+			return nil, fmt.Errorf("resource type %s is configured more than once", entry.Type)
+		}
+		if entry.IsDRLC() {
+			t := &resourceType{dynamic: true, maxCount: entry.MaxCount}
+			s.types[entry.Type] = t
+			for ; t.created < entry.MinCount; t.created++ {
+				r := common.NewResource(fmt.Sprintf("%s-%d", entry.Type, t.created), entry.Type, entry.State, "", s.now())
+				s.resources[r.Name] = &r
+			}
+			continue
+		}
+		s.types[entry.Type] = &resourceType{}
+		for _, name := range entry.Names {
+			if _, exists := s.resources[name]; exists {
+				return nil, fmt.Errorf("resource %s is configured more than once", name)
+			}
+			r := common.NewResource(name, entry.Type, entry.State, "", s.now())
+			s.resources[name] = &r
+		}
+	}
+	return s, nil
+}
+
+// queued determines whether the request is the next one to be served for the resource type.
+// Requests without an ID are served whenever a resource is free. Must be called with the lock held.
+func (s *Server) queued(rtype, requestID string) bool {
+	if requestID == "" {
+		return true
+	}
+	now := s.now()
+	var queue []queuedRequest
+	found := false
+	for _, r := range s.queues[rtype] {
+		if r.id == requestID {
+			r.lastSeen = now
+			found = true
+		}
+		if now.Sub(r.lastSeen) < requestTTL {
+			queue = append(queue, r)
+		}
+	}
+	if !found {
+		queue = append(queue, queuedRequest{id: requestID, lastSeen: now})
+	}
+	s.queues[rtype] = queue
+	return queue[0].id == requestID
+}
+
+func (s *Server) dequeue(rtype, requestID string) {
+	var queue []queuedRequest
+	for _, r := range s.queues[rtype] {
+		if r.id != requestID {
+			queue = append(queue, r)
+		}
+	}
+	s.queues[rtype] = queue
+}
+
+// Acquire leases a resource of the type in the state, moving it to the destination state.
+func (s *Server) Acquire(rtype, state, dest, owner, requestID string) (*common.Resource, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.types[rtype]
+	if !ok {
+		return nil, typeNotFoundError(rtype)
+	}
+	if !s.queued(rtype, requestID) {
+		return nil, fmt.Errorf("no available resource %s, request %s is queued: %w", rtype, requestID, errNotFound)
+	}
+	var candidates []*common.Resource
+	for _, r := range s.resources {
+		if r.Type == rtype && r.State == state && r.Owner == "" {
+			candidates = append(candidates, r)
+		}
+	}
+	var resource *common.Resource
+	if len(candidates) > 0 {
+		// serve the resource idle for the longest time, like Boskos
+		sort.Slice(candidates, func(i, j int) bool {
+			if !candidates[i].LastUpdate.Equal(candidates[j].LastUpdate) {
+				return candidates[i].LastUpdate.Before(candidates[j].LastUpdate)
+			}
+			return candidates[i].Name < candidates[j].Name
+		})
+		resource = candidates[0]
+	} else if t.dynamic && state == common.Free && s.count(rtype) < t.maxCount {
+		name := fmt.Sprintf("%s-%d", rtype, t.created)
+		t.created++
+		r := common.NewResource(name, rtype, state, "", s.now())
+		resource = &r
+		s.resources[name] = resource
+		s.logger.WithField("resource", name).Info("Created dynamic resource")
+	}
+	if resource == nil {
+		return nil, fmt.Errorf("no available resource %s: %w", rtype, errNotFound)
+	}
+	s.dequeue(rtype, requestID)
+	resource.State = dest
+	resource.Owner = owner
+	resource.LastUpdate = s.now()
+	s.logger.WithFields(logrus.Fields{"resource": resource.Name, "owner": owner}).Info("Leased resource")
+	ret := *resource
+	return &ret, nil
+}
+
+// count returns the number of resources of the type. Must be called with the lock held.
+func (s *Server) count(rtype string) int {
+	var n int
+	for _, r := range s.resources {
+		if r.Type == rtype {
+			n++
+		}
+	}
+	return n
+}
+
+// lookup returns the resource if it is owned by the owner. Must be called with the lock held.
+func (s *Server) lookup(name, owner string) (*common.Resource, error) {
+	r, ok := s.resources[name]
+	if !ok {
+		return nil, fmt.Errorf("resource %s: %w", name, errNotFound)
+	}
+	if r.Owner != owner {
+		return nil, fmt.Errorf("resource %s is owned by %q, not %q: %w", name, r.Owner, owner, errOwnerMismatch)
+	}
+	return r, nil
+}
+
+// Release ends the lease of the owner, moving the resource to the destination state.
+func (s *Server) Release(name, dest, owner string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, err := s.lookup(name, owner)
+	if err != nil {
+		return err
+	}
+	s.free(r, dest)
+	s.logger.WithFields(logrus.Fields{"resource": name, "owner": owner}).Info("Released resource")
+	return nil
+}
+
+// free ends the lease of the resource. Must be called with the lock held.
+func (s *Server) free(r *common.Resource, dest string) {
+	if s.types[r.Type].dynamic {
+		delete(s.resources, r.Name)
+		return
+	}
+	r.State = dest
+	r.Owner = ""
+	r.LastUpdate = s.now()
+}
+
+// Update refreshes the lease of the owner, which acts as its heartbeat.
+func (s *Server) Update(name, owner, state string, data *common.UserData) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, err := s.lookup(name, owner)
+	if err != nil {
+		return err
+	}
+	if r.State != state {
+		return fmt.Errorf("resource %s is in state %s, not %s: %w", name, r.State, state, errStateMismatch)
+	}
+	if r.UserData == nil {
+		r.UserData = &common.UserData{}
+	}
+	r.UserData.Update(data)
+	r.LastUpdate = s.now()
+	return nil
+}
+
+// Reset moves the resources of the type in the state not updated for longer than expire
+// to the destination state, returning their names and previous owners.
+func (s *Server) Reset(rtype, state string, expire time.Duration, dest string) map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ret := map[string]string{}
+	for _, r := range s.resources {
+		if r.Type == rtype && r.State == state && s.now().Sub(r.LastUpdate) > expire {
+			ret[r.Name] = r.Owner
+			r.State = dest
+			r.LastUpdate = s.now()
+		}
+	}
+	return ret
+}
+
+// Metric counts the resources of the type per state and owner.
+func (s *Server) Metric(rtype string) (common.Metric, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.types[rtype]; !ok {
+		return common.Metric{}, typeNotFoundError(rtype)
+	}
+	metric := common.NewMetric(rtype)
+	for _, r := range s.resources {
+		if r.Type == rtype {
+			metric.Current[r.State]++
+			metric.Owners[r.Owner]++
+		}
+	}
+	return metric, nil
+}
+
+// Resources returns a copy of all resources, sorted by name.
+func (s *Server) Resources() []common.Resource {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var ret []common.Resource
+	for _, r := range s.resources {
+		ret = append(ret, *r)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Name < ret[j].Name })
+	return ret
+}
+
+// Expire frees the leases which were not updated within the expiry, as if their holders died.
+func (s *Server) Expire() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var expired []string
+	for _, r := range s.resources {
+		if r.Owner != "" && s.now().Sub(r.LastUpdate) > s.expiry {
+			expired = append(expired, r.Name)
+			s.logger.WithFields(logrus.Fields{"resource": r.Name, "owner": r.Owner}).Info("Lease expired")
+			s.free(r, common.Free)
+		}
+	}
+	sort.Strings(expired)
+	return expired
+}
+
+// Run expires leases periodically until the context is cancelled.
+func (s *Server) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.Expire()
+		}
+	}
+}
+
+// LoseLease frees a leased resource, so its owner finds out the next time it sends a heartbeat.
+func (s *Server) LoseLease(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.resources[name]
+	if !ok {
+		return fmt.Errorf("resource %s: %w", name, errNotFound)
+	}
+	s.logger.WithFields(logrus.Fields{"resource": name, "owner": r.Owner}).Info("Losing lease")
+	s.free(r, common.Free)
+	return nil
+}
+
+func (s *Server) handleAcquire(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	rtype, state, dest, owner := query.Get("type"), query.Get("state"), query.Get("dest"), query.Get("owner")
+	if rtype == "" || state == "" || dest == "" || owner == "" {
+		http.Error(w, fmt.Sprintf("Type: %v, state: %v, dest: %v, owner: %v, all of them must be set in the request.", rtype, state, dest, owner), http.StatusBadRequest)
+		return
+	}
+	resource, err := s.Acquire(rtype, state, dest, owner, query.Get("request_id"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Acquire failed: %v", err), errorToStatus(err))
+		return
+	}
+	writeJSON(s.logger, w, resource)
+}
+
+func (s *Server) handleRelease(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	name, dest, owner := query.Get("name"), query.Get("dest"), query.Get("owner")
+	if name == "" || dest == "" || owner == "" {
+		http.Error(w, fmt.Sprintf("Name: %v, dest: %v, owner: %v, all of them must be set in the request.", name, dest, owner), http.StatusBadRequest)
+		return
+	}
+	if err := s.Release(name, dest, owner); err != nil {
+		http.Error(w, fmt.Sprintf("Done failed: %v", err), errorToStatus(err))
+	}
+}
+
+func (s *Server) handleUpdate(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	name, owner, state := query.Get("name"), query.Get("owner"), query.Get("state")
+	if name == "" || owner == "" || state == "" {
+		http.Error(w, fmt.Sprintf("Name: %v, owner: %v, state : %v, all of them must be set in the request.", name, owner, state), http.StatusBadRequest)
+		return
+	}
+	var data common.UserData
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil && err != io.EOF {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := s.Update(name, owner, state, &data); err != nil {
+		http.Error(w, fmt.Sprintf("Update failed: %v", err), errorToStatus(err))
+	}
+}
+
+func (s *Server) handleReset(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	rtype, state, dest := query.Get("type"), query.Get("state"), query.Get("dest")
+	expire, err := time.ParseDuration(query.Get("expire"))
+	if rtype == "" || state == "" || dest == "" || err != nil {
+		http.Error(w, fmt.Sprintf("Type: %v, state: %v, expire: %v, dest: %v, all of them must be valid in the request.", rtype, state, query.Get("expire"), dest), http.StatusBadRequest)
+		return
+	}
+	writeJSON(s.logger, w, s.Reset(rtype, state, expire, dest))
+}
+
+func (s *Server) handleMetric(w http.ResponseWriter, r *http.Request) {
+	rtype := r.URL.Query().Get("type")
+	if rtype == "" {
+		http.Error(w, "Type must be set in the request.", http.StatusBadRequest)
+		return
+	}
+	metric, err := s.Metric(rtype)
+	if err != nil {
+		http.Error(w, err.Error(), errorToStatus(err))
+		return
+	}
+	writeJSON(s.logger, w, metric)
+}
+
+func writeJSON(logger *logrus.Entry, w http.ResponseWriter, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write(data); err != nil {
+		logger.WithError(err).Warn("Failed to write response")
+	}
+}
+
+func method(method string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			http.Error(w, fmt.Sprintf("Method %v, %s only accepts %s.", r.Method, r.URL.Path, method), http.StatusMethodNotAllowed)
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// Handler returns the handler serving the Boskos protocol, with the injected failures.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(http.ResponseWriter, *http.Request) {})
+	mux.HandleFunc("/acquire", s.inject(method(http.MethodPost, s.handleAcquire)))
+	mux.HandleFunc("/release", s.inject(method(http.MethodPost, s.handleRelease)))
+	mux.HandleFunc("/update", s.inject(method(http.MethodPost, s.handleUpdate)))
+	mux.HandleFunc("/reset", s.inject(method(http.MethodPost, s.handleReset)))
+	mux.HandleFunc("/metric", s.inject(method(http.MethodGet, s.handleMetric)))
+	return mux
+}
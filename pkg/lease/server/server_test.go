@@ -0,0 +1,233 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sirupsen/logrus"
+
+	boskos "sigs.k8s.io/boskos/client"
+	"sigs.k8s.io/boskos/common"
+)
+
+func newTestServer(t *testing.T, now *time.Time) (*Server, *boskos.Client) {
+	t.Helper()
+	config := &common.BoskosConfig{Resources: []common.ResourceEntry{
+		{Type: "aws-quota-slice", State: common.Free, Names: []string{"us-east-1--aws-quota-slice-0", "us-east-1--aws-quota-slice-1"}},
+		{Type: "gcp-quota-slice", State: common.Free, MaxCount: 2},
+	}}
+	s, err := New(logrus.NewEntry(logrus.New()), config, 5*time.Minute)
+	if err != nil {
+		t.Fatalf("failed to create the server: %v", err)
+	}
+	s.now = func() time.Time { return *now }
+	server := httptest.NewServer(s.Handler())
+	t.Cleanup(server.Close)
+	client, err := boskos.NewClient("owner", server.URL, "", "")
+	if err != nil {
+		t.Fatalf("failed to create the client: %v", err)
+	}
+	client.DistinguishNotFoundVsTypeNotFound = true
+	return s, client
+}
+
+func metric(t *testing.T, client *boskos.Client, rtype string) common.Metric {
+	t.Helper()
+	m, err := client.Metric(rtype)
+	if err != nil {
+		t.Fatalf("failed to get metrics: %v", err)
+	}
+	return m
+}
+
+func TestProtocol(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	_, client := newTestServer(t, &now)
+
+	if _, err := client.Acquire("azure-quota-slice", common.Free, common.Leased); !errors.Is(err, boskos.ErrTypeNotFound) {
+		t.Errorf("expected an unknown type to be reported, got %v", err)
+	}
+	var names []string
+	for i := 0; i < 2; i++ {
+		now = now.Add(time.Second)
+		r, err := client.Acquire("aws-quota-slice", common.Free, common.Leased)
+		if err != nil {
+			t.Fatalf("failed to acquire: %v", err)
+		}
+		names = append(names, r.Name)
+	}
+	if diff := cmp.Diff([]string{"us-east-1--aws-quota-slice-0", "us-east-1--aws-quota-slice-1"}, names); diff != "" {
+		t.Errorf("unexpected resources: %s", diff)
+	}
+	if _, err := client.Acquire("aws-quota-slice", common.Free, common.Leased); !errors.Is(err, boskos.ErrNotFound) {
+		t.Errorf("expected no resource to be available, got %v", err)
+	}
+	expected := common.Metric{Type: "aws-quota-slice", Current: map[string]int{common.Leased: 2}, Owners: map[string]int{"owner": 2}}
+	if diff := cmp.Diff(expected, metric(t, client, "aws-quota-slice")); diff != "" {
+		t.Errorf("unexpected metrics: %s", diff)
+	}
+
+	if err := client.UpdateOne(names[0], common.Leased, nil); err != nil {
+		t.Errorf("failed to update: %v", err)
+	}
+	if err := client.ReleaseOne(names[0], common.Free); err != nil {
+		t.Errorf("failed to release: %v", err)
+	}
+	expected = common.Metric{Type: "aws-quota-slice", Current: map[string]int{common.Free: 1, common.Leased: 1}, Owners: map[string]int{"": 1, "owner": 1}}
+	if diff := cmp.Diff(expected, metric(t, client, "aws-quota-slice")); diff != "" {
+		t.Errorf("unexpected metrics after release: %s", diff)
+	}
+
+	now = now.Add(time.Hour)
+	reset, err := client.Reset("aws-quota-slice", common.Leased, time.Minute, common.Dirty)
+	if err != nil {
+		t.Fatalf("failed to reset: %v", err)
+	}
+	if diff := cmp.Diff(map[string]string{names[1]: "owner"}, reset); diff != "" {
+		t.Errorf("unexpected reset resources: %s", diff)
+	}
+}
+
+func TestDynamicResources(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	_, client := newTestServer(t, &now)
+
+	var names []string
+	for i := 0; i < 2; i++ {
+		r, err := client.Acquire("gcp-quota-slice", common.Free, common.Leased)
+		if err != nil {
+			t.Fatalf("failed to acquire: %v", err)
+		}
+		names = append(names, r.Name)
+	}
+	if diff := cmp.Diff([]string{"gcp-quota-slice-0", "gcp-quota-slice-1"}, names); diff != "" {
+		t.Errorf("unexpected resources: %s", diff)
+	}
+	if _, err := client.Acquire("gcp-quota-slice", common.Free, common.Leased); !errors.Is(err, boskos.ErrNotFound) {
+		t.Errorf("expected the maximum count to be reached, got %v", err)
+	}
+	if err := client.ReleaseOne(names[0], common.Free); err != nil {
+		t.Errorf("failed to release: %v", err)
+	}
+	expected := common.Metric{Type: "gcp-quota-slice", Current: map[string]int{common.Leased: 1}, Owners: map[string]int{"owner": 1}}
+	if diff := cmp.Diff(expected, metric(t, client, "gcp-quota-slice")); diff != "" {
+		t.Errorf("released dynamic resource was not deleted: %s", diff)
+	}
+	r, err := client.Acquire("gcp-quota-slice", common.Free, common.Leased)
+	if err != nil {
+		t.Fatalf("failed to acquire: %v", err)
+	}
+	if r.Name != "gcp-quota-slice-2" {
+		t.Errorf("expected a new dynamic resource, got %s", r.Name)
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	s, client := newTestServer(t, &now)
+	original := boskos.SleepFunc
+	boskos.SleepFunc = func(time.Duration) {}
+	t.Cleanup(func() { boskos.SleepFunc = original })
+
+	alive, err := client.Acquire("aws-quota-slice", common.Free, common.Leased)
+	if err != nil {
+		t.Fatalf("failed to acquire: %v", err)
+	}
+	dead, err := client.Acquire("aws-quota-slice", common.Free, common.Leased)
+	if err != nil {
+		t.Fatalf("failed to acquire: %v", err)
+	}
+	now = now.Add(4 * time.Minute)
+	if err := client.UpdateOne(alive.Name, common.Leased, nil); err != nil {
+		t.Fatalf("failed to update: %v", err)
+	}
+	now = now.Add(2 * time.Minute)
+	if diff := cmp.Diff([]string{dead.Name}, s.Expire()); diff != "" {
+		t.Errorf("unexpected expired leases: %s", diff)
+	}
+	if err := client.UpdateOne(dead.Name, common.Leased, nil); err == nil {
+		t.Error("expected the heartbeat of an expired lease to fail")
+	}
+	if err := client.UpdateOne(alive.Name, common.Leased, nil); err != nil {
+		t.Errorf("failed to update: %v", err)
+	}
+}
+
+func TestFailureInjection(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	s, client := newTestServer(t, &now)
+	original := boskos.SleepFunc
+	boskos.SleepFunc = func(time.Duration) {}
+	t.Cleanup(func() { boskos.SleepFunc = original })
+	admin := httptest.NewServer(s.AdminHandler())
+	t.Cleanup(admin.Close)
+
+	// the client retries up to four times
+	s.InjectErrors("/acquire", http.StatusServiceUnavailable, 3)
+	r, err := client.Acquire("aws-quota-slice", common.Free, common.Leased)
+	if err != nil {
+		t.Fatalf("expected the acquisition to succeed after retries, got %v", err)
+	}
+	resp, err := http.Post(admin.URL+"/admin/errors?endpoint=/acquire&status=500&count=4", "", nil)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("failed to inject errors: %v, %v", err, resp)
+	}
+	if _, err := client.Acquire("aws-quota-slice", common.Free, common.Leased); err == nil {
+		t.Error("expected the acquisition to fail")
+	}
+
+	resp, err = http.Post(admin.URL+"/admin/lose?name="+r.Name, "", nil)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("failed to lose the lease: %v, %v", err, resp)
+	}
+	if err := client.UpdateOne(r.Name, common.Leased, nil); err == nil {
+		t.Error("expected the heartbeat of a lost lease to fail")
+	}
+
+	s.InjectDelay("/metric", 100*time.Millisecond)
+	start := time.Now()
+	metric(t, client, "aws-quota-slice")
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("expected the request to be delayed, took %s", elapsed)
+	}
+}
+
+func TestRequestQueue(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	s, _ := newTestServer(t, &now)
+	for _, name := range []string{"us-east-1--aws-quota-slice-0", "us-east-1--aws-quota-slice-1"} {
+		if _, err := s.Acquire("aws-quota-slice", common.Free, common.Leased, "other", ""); err != nil {
+			t.Fatalf("failed to acquire %s: %v", name, err)
+		}
+	}
+	for _, id := range []string{"first", "second"} {
+		if _, err := s.Acquire("aws-quota-slice", common.Free, common.Leased, id, id); !errors.Is(err, errNotFound) {
+			t.Fatalf("expected %s to be queued, got %v", id, err)
+		}
+	}
+	if err := s.Release("us-east-1--aws-quota-slice-0", common.Free, "other"); err != nil {
+		t.Fatalf("failed to release: %v", err)
+	}
+	if _, err := s.Acquire("aws-quota-slice", common.Free, common.Leased, "second", "second"); !errors.Is(err, errNotFound) {
+		t.Errorf("expected the second request to wait for the first one, got %v", err)
+	}
+	if _, err := s.Acquire("aws-quota-slice", common.Free, common.Leased, "first", "first"); err != nil {
+		t.Errorf("expected the first request to be served, got %v", err)
+	}
+
+	// requests that are not polled anymore lose their place
+	if _, err := s.Acquire("aws-quota-slice", common.Free, common.Leased, "abandoned", "abandoned"); !errors.Is(err, errNotFound) {
+		t.Fatalf("expected the request to be queued, got %v", err)
+	}
+	now = now.Add(time.Minute)
+	if err := s.Release("us-east-1--aws-quota-slice-1", common.Free, "other"); err != nil {
+		t.Fatalf("failed to release: %v", err)
+	}
+	if _, err := s.Acquire("aws-quota-slice", common.Free, common.Leased, "second", "second"); err != nil {
+		t.Errorf("expected the second request to be served, got %v", err)
+	}
+}
@@ -24,6 +24,12 @@ type prowJobBaseBuilder struct {
 
 	info     *cioperatorapi.Metadata
 	testName string
+
+	// sources explains the fields of all jobs built, jobSources those of the job
+	// being generated, provenance collects the explanations when it is set
+	sources    fieldSources
+	jobSources fieldSources
+	provenance Provenance
 }
 
 func fromRepositorySet(configSpec *cioperatorapi.ReleaseBuildConfiguration) bool {
@@ -100,28 +106,38 @@ func NewProwJobBaseBuilder(configSpec *cioperatorapi.ReleaseBuildConfiguration,
 	shouldSkipCloning := len(sparseFiles) == 0
 	if shouldSkipCloning {
 		b.base.UtilityConfig.DecorationConfig.SkipCloning = ptr.To(true)
+		b.explain("decoration_config.skip_cloning", "images.items and build_root.from_repository: no file of the repository is needed")
 	} else {
-		disableSparseCheckout := (configSpec.Prowgen != nil && configSpec.Prowgen.DisableSparseCheckout) || configSpec.Images.BuildIfAffected
-		if !disableSparseCheckout {
+		switch {
+		case configSpec.Prowgen != nil && configSpec.Prowgen.DisableSparseCheckout:
+			b.explain("decoration_config.sparse_checkout_files", "prowgen.disable_sparse_checkout")
+		case configSpec.Images.BuildIfAffected:
+			b.explain("decoration_config.sparse_checkout_files", "images.build_if_affected")
+		default:
 			b.base.UtilityConfig.DecorationConfig.SparseCheckoutFiles = sparseFiles
+			b.explain("decoration_config.sparse_checkout_files", "images.items and build_root.from_repository")
 		}
 		if private {
 			b.base.UtilityConfig.DecorationConfig.OauthTokenSecret = &prowv1.OauthTokenSecret{Key: cioperatorapi.OauthTokenSecretKey, Name: cioperatorapi.OauthTokenSecretName}
+			b.explain("decoration_config.oauth_token_secret", "prowgen.private")
 		}
 	}
 
 	if len(info.Variant) > 0 {
 		b.base.Labels[jc.ProwJobLabelVariant] = info.Variant
+		b.explain("labels."+jc.ProwJobLabelVariant, "zz_generated_metadata.variant")
 	}
 
 	// jobs generated from some configSpec shapes provide relevant CI signal about OCP version stream
 	// quality, so we label them as such for downstream tooling like Sippy to recognize them
 	if versionStream := ocplifecycle.ProvidesSignalForVersion(configSpec); versionStream != "" {
 		b.base.Labels[jc.JobReleaseKey] = versionStream
+		b.explain("labels."+jc.JobReleaseKey, fmt.Sprintf("releases.%s.candidate.version", cioperatorapi.LatestReleaseName))
 	}
 
 	if hasNoBuilds(configSpec, info) {
 		b.base.Labels[cioperatorapi.NoBuildsLabel] = cioperatorapi.NoBuildsValue
+		b.explain("labels."+cioperatorapi.NoBuildsLabel, "images.items, build_root and the build commands: nothing is built")
 	}
 
 	b.PodSpec.Add(Variant(info.Variant))
@@ -131,10 +147,14 @@ func NewProwJobBaseBuilder(configSpec *cioperatorapi.ReleaseBuildConfiguration,
 
 	if configSpec.CanonicalGoRepository != nil {
 		b.base.UtilityConfig.PathAlias = *configSpec.CanonicalGoRepository
+		b.explain("path_alias", "canonical_go_repository")
 	}
 
 	if private && !expose {
 		b.base.Hidden = true
+		b.explain("hidden", "prowgen.private")
+	} else if private {
+		b.explain("hidden", "prowgen.expose")
 	}
 
 	b.info = info
@@ -147,9 +167,11 @@ func NewProwJobBaseBuilder(configSpec *cioperatorapi.ReleaseBuildConfiguration,
 func NewProwJobBaseBuilderForTest(configSpec *cioperatorapi.ReleaseBuildConfiguration, info *cioperatorapi.Metadata,
 	podSpecGenerator CiOperatorPodSpecGenerator, test cioperatorapi.TestStepConfiguration, clusterProfileResolver ClusterProfileResolver) (*prowJobBaseBuilder, error) {
 	p := NewProwJobBaseBuilder(configSpec, info, podSpecGenerator)
+	path := testPath(test.As)
 	if test.Cluster != "" {
 		p.Cluster(test.Cluster)
 		p.WithLabel(cioperatorapi.ClusterLabel, string(test.Cluster))
+		p.explain("cluster", path+".cluster").explain("labels."+cioperatorapi.ClusterLabel, path+".cluster")
 	}
 	p.testName = test.As
 
@@ -160,6 +182,7 @@ func NewProwJobBaseBuilderForTest(configSpec *cioperatorapi.ReleaseBuildConfigur
 			u.DecorationConfig = &prowv1.DecorationConfig{}
 		}
 		u.DecorationConfig.Timeout = test.Timeout
+		p.explain("decoration_config.timeout", path+".timeout")
 	}
 
 	p.PodSpec.Add(Secrets(test.Secret), Secrets(test.Secrets...))
@@ -180,6 +203,8 @@ func NewProwJobBaseBuilderForTest(configSpec *cioperatorapi.ReleaseBuildConfigur
 		if clusterProfile := test.MultiStageTestConfigurationLiteral.ClusterProfileLiteral; clusterProfile != nil {
 			p.WithLabel(cioperatorapi.CloudClusterProfileLabel, clusterProfile.Name)
 			p.WithLabel(cioperatorapi.CloudLabel, clusterProfile.ClusterType)
+			p.explain("labels."+cioperatorapi.CloudClusterProfileLabel, path+".steps.cluster_profile")
+			p.explain("labels."+cioperatorapi.CloudLabel, path+".steps.cluster_profile")
 		}
 		if configSpec.Releases != nil {
 			p.PodSpec.Add(CIPullSecret())
@@ -199,6 +224,8 @@ func NewProwJobBaseBuilderForTest(configSpec *cioperatorapi.ReleaseBuildConfigur
 
 			p.WithLabel(cioperatorapi.CloudClusterProfileLabel, clusterProfile)
 			p.WithLabel(cioperatorapi.CloudLabel, profile.ClusterType)
+			p.explain("labels."+cioperatorapi.CloudClusterProfileLabel, path+".steps.cluster_profile")
+			p.explain("labels."+cioperatorapi.CloudLabel, path+".steps.cluster_profile")
 		}
 		if configSpec.Releases != nil {
 			p.PodSpec.Add(CIPullSecret())
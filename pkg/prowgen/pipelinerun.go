@@ -0,0 +1,410 @@
+package prowgen
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+	prowv1 "sigs.k8s.io/prow/pkg/apis/prowjobs/v1"
+	prowconfig "sigs.k8s.io/prow/pkg/config"
+	"sigs.k8s.io/prow/pkg/pod-utils/downwardapi"
+	"sigs.k8s.io/yaml"
+
+	cioperatorapi "github.com/openshift/ci-tools/pkg/api"
+	jc "github.com/openshift/ci-tools/pkg/jobconfig"
+)
+
+const (
+	// The annotations Pipelines as Code matches the events that trigger a PipelineRun with
+	onCELExpressionAnnotation = "pipelinesascode.tekton.dev/on-cel-expression"
+	onCommentAnnotation       = "pipelinesascode.tekton.dev/on-comment"
+
+	// PipelineRunsDir is the directory at the root of a repository Pipelines as Code
+	// reads the PipelineRuns from
+	PipelineRunsDir = ".tekton"
+
+	ciOperatorTaskName   = "ci-operator"
+	jobSpecEnv           = "JOB_SPEC"
+	gcsCredentialsVolume = "gcs-credentials"
+)
+
+// CiOperatorPipelineGenerator builds Tekton PipelineRuns that run ci-operator the
+// same way the PodSpecs of the Prow jobs do: the PodSpec is built from the same
+// mutators and the PipelineRun runs its container as the only step of the only task.
+type CiOperatorPipelineGenerator interface {
+	CiOperatorPodSpecGenerator
+	// BuildPipelineRun returns a PipelineRun running the PodSpec returned by Build.
+	// The caller names it and decides which events trigger it.
+	BuildPipelineRun() (*tektonv1.PipelineRun, error)
+}
+
+type ciOperatorPipelineGenerator struct {
+	podSpec CiOperatorPodSpecGenerator
+}
+
+// NewCiOperatorPipelineGenerator returns a new CiOperatorPipelineGenerator instance
+func NewCiOperatorPipelineGenerator() CiOperatorPipelineGenerator {
+	return &ciOperatorPipelineGenerator{podSpec: NewCiOperatorPodSpecGenerator()}
+}
+
+func (c *ciOperatorPipelineGenerator) Add(mutators ...PodSpecMutator) CiOperatorPodSpecGenerator {
+	c.podSpec.Add(mutators...)
+	return c
+}
+
+func (c *ciOperatorPipelineGenerator) Build() (*corev1.PodSpec, error) {
+	return c.podSpec.Build()
+}
+
+func (c *ciOperatorPipelineGenerator) MustBuild() *corev1.PodSpec {
+	return c.podSpec.MustBuild()
+}
+
+func (c *ciOperatorPipelineGenerator) BuildPipelineRun() (*tektonv1.PipelineRun, error) {
+	spec, err := c.podSpec.Build()
+	if err != nil {
+		return nil, err
+	}
+	return pipelineRunFor(spec)
+}
+
+// jobPodSpec starts from the PodSpec of a generated Prow job, which was built by
+// the same mutators, instead of the default PodSpec
+func jobPodSpec(base prowconfig.JobBase) PodSpecMutator {
+	return func(spec *corev1.PodSpec) error {
+		if base.Spec == nil {
+			return fmt.Errorf("job %s has no PodSpec", base.Name)
+		}
+		*spec = *base.Spec.DeepCopy()
+		return nil
+	}
+}
+
+// decorationVolumes adds the volumes of the credentials Prow decorates the pod of the
+// job with, for uploading the artifacts and for cloning private repositories: nothing
+// decorates the task, so it must mount them itself
+func decorationVolumes(base prowconfig.JobBase) PodSpecMutator {
+	return func(spec *corev1.PodSpec) error {
+		if len(spec.Containers) != 1 {
+			return nil
+		}
+		decorationSecrets := map[string]string{gcsCredentialsVolume: cioperatorapi.GCSUploadCredentialsSecret}
+		if config := base.DecorationConfig; config != nil && config.OauthTokenSecret != nil {
+			decorationSecrets[config.OauthTokenSecret.Name] = config.OauthTokenSecret.Name
+		}
+		for _, mount := range spec.Containers[0].VolumeMounts {
+			if secret, decorated := decorationSecrets[mount.Name]; decorated && !hasVolume(spec, mount.Name) {
+				spec.Volumes = append(spec.Volumes, corev1.Volume{
+					Name:         mount.Name,
+					VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: secret}},
+				})
+			}
+		}
+		return nil
+	}
+}
+
+// pipelineRunFor returns a PipelineRun running the container of the PodSpec
+func pipelineRunFor(spec *corev1.PodSpec) (*tektonv1.PipelineRun, error) {
+	if len(spec.Containers) != 1 {
+		return nil, fmt.Errorf("expected the pod to run a single container, got %d", len(spec.Containers))
+	}
+	container := spec.Containers[0]
+	return &tektonv1.PipelineRun{
+		TypeMeta: metav1.TypeMeta{APIVersion: tektonv1.SchemeGroupVersion.String(), Kind: "PipelineRun"},
+		Spec: tektonv1.PipelineRunSpec{
+			PipelineSpec: &tektonv1.PipelineSpec{
+				Tasks: []tektonv1.PipelineTask{{
+					Name: ciOperatorTaskName,
+					TaskSpec: &tektonv1.EmbeddedTask{TaskSpec: tektonv1.TaskSpec{
+						Steps: []tektonv1.Step{{
+							Name:             ciOperatorTaskName,
+							Image:            container.Image,
+							ImagePullPolicy:  container.ImagePullPolicy,
+							Command:          container.Command,
+							Args:             container.Args,
+							Env:              container.Env,
+							VolumeMounts:     container.VolumeMounts,
+							ComputeResources: container.Resources,
+							SecurityContext:  container.SecurityContext,
+						}},
+						Volumes: spec.Volumes,
+					}},
+				}},
+			},
+			TaskRunTemplate: tektonv1.PipelineTaskRunTemplate{ServiceAccountName: spec.ServiceAccountName},
+		},
+	}, nil
+}
+
+// pipelineRunForJob returns the PipelineRun that runs the job, the job spec that Prow
+// would give to ci-operator is given in the JOB_SPEC of the step
+func pipelineRunForJob(base prowconfig.JobBase, jobSpec string, triggers map[string]string) (*tektonv1.PipelineRun, error) {
+	if base.Spec == nil || len(base.Spec.Containers) != 1 {
+		return nil, fmt.Errorf("job %s does not run a single container", base.Name)
+	}
+	generator := NewCiOperatorPipelineGenerator()
+	generator.Add(jobPodSpec(base), decorationVolumes(base))
+	run, err := generator.BuildPipelineRun()
+	if err != nil {
+		return nil, fmt.Errorf("job %s: %w", base.Name, err)
+	}
+	run.Name = base.Name
+	run.Labels = base.Labels
+	run.Annotations = map[string]string{}
+	for key, value := range base.Annotations {
+		run.Annotations[key] = value
+	}
+	for key, value := range triggers {
+		run.Annotations[key] = value
+	}
+	step := &run.Spec.PipelineSpec.Tasks[0].TaskSpec.Steps[0]
+	step.Env = append(step.Env, corev1.EnvVar{Name: jobSpecEnv, Value: jobSpec})
+	if config := base.DecorationConfig; config != nil && config.Timeout != nil {
+		run.Spec.Timeouts = &tektonv1.TimeoutFields{Pipeline: &metav1.Duration{Duration: config.Timeout.Duration}}
+	}
+	return run, nil
+}
+
+func hasVolume(spec *corev1.PodSpec, name string) bool {
+	for _, volume := range spec.Volumes {
+		if volume.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// The number of the pull request is only known once Pipelines as Code resolves its
+// variables, the job spec is marshalled with this number in its place
+const pullNumberPlaceholder = -1
+
+// jobSpecFor returns the job spec Prow would give to ci-operator for the job, with
+// the Pipelines as Code variables where the values depend on the event
+func jobSpecFor(jobType prowv1.ProwJobType, name string, refs *prowv1.Refs, extraRefs []prowv1.Refs) (string, error) {
+	spec := downwardapi.JobSpec{
+		Type:      jobType,
+		Job:       name,
+		BuildID:   "$(context.taskRun.name)",
+		ProwJobID: "$(context.taskRun.uid)",
+		Refs:      refs,
+		ExtraRefs: extraRefs,
+	}
+	raw, err := json.Marshal(spec)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal the job spec: %w", err)
+	}
+	return strings.Replace(string(raw), fmt.Sprintf(`"number":%d`, pullNumberPlaceholder), `"number":{{ pull_request_number }}`, 1), nil
+}
+
+// eventRefs are the refs of the pull request or the push that triggered the PipelineRun
+func eventRefs(jobType prowv1.ProwJobType, org, repo string) *prowv1.Refs {
+	refs := &prowv1.Refs{Org: org, Repo: repo, BaseRef: "{{ target_branch }}"}
+	if jobType == prowv1.PresubmitJob {
+		refs.Pulls = []prowv1.Pull{{Number: pullNumberPlaceholder, Author: "{{ sender }}", SHA: "{{ revision }}"}}
+	} else {
+		refs.BaseSHA = "{{ revision }}"
+	}
+	return refs
+}
+
+// matches returns a CEL expression matching the value against the Prow regexes
+func matches(value string, regexes []string) string {
+	return fmt.Sprintf("%s.matches(%s)", value, strconv.Quote(strings.Join(regexes, "|")))
+}
+
+// changeConditions returns the CEL expressions matching the changed files like Prow
+// matches run_if_changed and skip_if_only_changed
+func changeConditions(changes prowconfig.RegexpChangeMatcher) []string {
+	var conditions []string
+	if changes.RunIfChanged != "" {
+		conditions = append(conditions, fmt.Sprintf("files.all.exists(f, f.matches(%s))", strconv.Quote(changes.RunIfChanged)))
+	}
+	if changes.SkipIfOnlyChanged != "" {
+		conditions = append(conditions, fmt.Sprintf("!files.all.all(f, f.matches(%s))", strconv.Quote(changes.SkipIfOnlyChanged)))
+	}
+	return conditions
+}
+
+func presubmitPipelineRun(org, repo string, job prowconfig.Presubmit) (*tektonv1.PipelineRun, error) {
+	triggers := map[string]string{onCommentAnnotation: job.Trigger}
+	changes := changeConditions(job.RegexpChangeMatcher)
+	// jobs that neither always run nor run on some changes only run when asked for
+	if job.AlwaysRun || len(changes) > 0 {
+		conditions := []string{`event == "pull_request"`}
+		if len(job.Branches) > 0 {
+			conditions = append(conditions, matches("target_branch", job.Branches))
+		}
+		if len(job.SkipBranches) > 0 {
+			conditions = append(conditions, "!"+matches("target_branch", job.SkipBranches))
+		}
+		triggers[onCELExpressionAnnotation] = strings.Join(append(conditions, changes...), " && ")
+	}
+	jobSpec, err := jobSpecFor(prowv1.PresubmitJob, job.Name, eventRefs(prowv1.PresubmitJob, org, repo), nil)
+	if err != nil {
+		return nil, err
+	}
+	return pipelineRunForJob(job.JobBase, jobSpec, triggers)
+}
+
+func postsubmitPipelineRun(org, repo string, job prowconfig.Postsubmit) (*tektonv1.PipelineRun, error) {
+	conditions := []string{`event == "push"`}
+	if len(job.Branches) > 0 {
+		conditions = append(conditions, matches("target_branch", job.Branches))
+	}
+	if len(job.SkipBranches) > 0 {
+		conditions = append(conditions, "!"+matches("target_branch", job.SkipBranches))
+	}
+	conditions = append(conditions, changeConditions(job.RegexpChangeMatcher)...)
+	jobSpec, err := jobSpecFor(prowv1.PostsubmitJob, job.Name, eventRefs(prowv1.PostsubmitJob, org, repo), nil)
+	if err != nil {
+		return nil, err
+	}
+	return pipelineRunForJob(job.JobBase, jobSpec, map[string]string{onCELExpressionAnnotation: strings.Join(conditions, " && ")})
+}
+
+// pipelineRunsFor returns the PipelineRuns running the presubmits and postsubmits of
+// the repository. Pipelines as Code cannot run PipelineRuns on a schedule, so there
+// are none for the periodics.
+func pipelineRunsFor(org, repo string, jobConfig *prowconfig.JobConfig) ([]*tektonv1.PipelineRun, error) {
+	orgRepo := fmt.Sprintf("%s/%s", org, repo)
+	var runs []*tektonv1.PipelineRun
+	var errs []error
+	add := func(run *tektonv1.PipelineRun, err error) {
+		if err != nil {
+			errs = append(errs, err)
+			return
+		}
+		runs = append(runs, run)
+	}
+	for _, job := range jobConfig.PresubmitsStatic[orgRepo] {
+		add(presubmitPipelineRun(org, repo, job))
+	}
+	for _, job := range jobConfig.PostsubmitsStatic[orgRepo] {
+		add(postsubmitPipelineRun(org, repo, job))
+	}
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].Name < runs[j].Name
+	})
+	return runs, errors.Join(errs...)
+}
+
+// WritePipelineRunsToDir is a jobconfig.Writer that writes the presubmits and
+// postsubmits as Tekton PipelineRuns triggered by Pipelines as Code. Every PipelineRun
+// is written to a file named after it in the PipelineRunsDir of <jobDir>/<org>/<repo>,
+// which is the root of the repository when jobDir holds the checkouts of the
+// repositories. The files are owned by the generator: they are overwritten, and
+// removed when their PipelineRun is not generated anymore.
+func WritePipelineRunsToDir(jobDir, org, repo string, jobConfig *prowconfig.JobConfig, generator jc.Generator, matchLabels labels.Set) error {
+	dir := filepath.Join(jobDir, org, repo, PipelineRunsDir)
+	files, _ := jc.ShardByFile(org, repo, jobConfig, generator)
+	var runs []*tektonv1.PipelineRun
+	for file, jobs := range files {
+		pruned, err := jc.Prune(jobs, generator, matchLabels)
+		if err != nil {
+			return err
+		}
+		generated, err := pipelineRunsFor(org, repo, pruned)
+		if err != nil {
+			return fmt.Errorf("failed to generate the PipelineRuns for %s: %w", file, err)
+		}
+		runs = append(runs, generated...)
+	}
+	if len(runs) > 0 {
+		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+			return err
+		}
+	}
+	written := sets.New[string]()
+	for _, run := range runs {
+		file := run.Name + ".yaml"
+		raw, err := marshalPipelineRun(run)
+		if err != nil {
+			return fmt.Errorf("failed to marshal PipelineRun %s: %w", run.Name, err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, file), raw, 0664); err != nil {
+			return fmt.Errorf("failed to write %s: %w", file, err)
+		}
+		written.Insert(file)
+	}
+	return removeStalePipelineRuns(dir, written, generator, matchLabels)
+}
+
+// marshalPipelineRun marshals the PipelineRun without the fields that are only
+// set on the objects of the cluster
+func marshalPipelineRun(run *tektonv1.PipelineRun) ([]byte, error) {
+	raw, err := json.Marshal(run)
+	if err != nil {
+		return nil, err
+	}
+	var object map[string]interface{}
+	if err := json.Unmarshal(raw, &object); err != nil {
+		return nil, err
+	}
+	delete(object, "status")
+	if metadata, ok := object["metadata"].(map[string]interface{}); ok {
+		delete(metadata, "creationTimestamp")
+	}
+	spec, _ := object["spec"].(map[string]interface{})
+	pipelineSpec, _ := spec["pipelineSpec"].(map[string]interface{})
+	tasks, _ := pipelineSpec["tasks"].([]interface{})
+	for _, task := range tasks {
+		if task, ok := task.(map[string]interface{}); ok {
+			if taskSpec, ok := task["taskSpec"].(map[string]interface{}); ok {
+				delete(taskSpec, "metadata")
+				delete(taskSpec, "spec")
+			}
+		}
+	}
+	return yaml.Marshal(object)
+}
+
+// removeStalePipelineRuns removes the files of the directory that are not written
+// anymore, if all their PipelineRuns were generated by the generator for jobs that
+// match the labels
+func removeStalePipelineRuns(dir string, written sets.Set[string], generator jc.Generator, matchLabels labels.Set) error {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	owned := labels.SelectorFromSet(labels.Merge(matchLabels, labels.Set{jc.LabelGenerator: string(generator)}))
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".yaml" || written.Has(entry.Name()) {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		stale := true
+		for _, document := range bytes.Split(raw, []byte("\n---\n")) {
+			var run metav1.PartialObjectMetadata
+			if err := yaml.Unmarshal(document, &run); err != nil || !owned.Matches(labels.Set(run.Labels)) {
+				stale = false
+				break
+			}
+		}
+		if stale {
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package prowgen
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	utilpointer "k8s.io/utils/pointer"
+	prowconfig "sigs.k8s.io/prow/pkg/config"
+
+	ciop "github.com/openshift/ci-tools/pkg/api"
+	"github.com/openshift/ci-tools/pkg/testhelper"
+)
+
+func TestPresubmitPipelineRunTriggers(t *testing.T) {
+	testCases := []struct {
+		name     string
+		job      prowconfig.Presubmit
+		expected map[string]string
+	}{
+		{
+			name: "always run",
+			job:  prowconfig.Presubmit{AlwaysRun: true, Brancher: prowconfig.Brancher{Branches: []string{"^main$", "^main-"}}},
+			expected: map[string]string{
+				onCELExpressionAnnotation: `event == "pull_request" && target_branch.matches("^main$|^main-")`,
+				onCommentAnnotation:       "/test unit",
+			},
+		},
+		{
+			name: "run if changed",
+			job: prowconfig.Presubmit{
+				Brancher:            prowconfig.Brancher{Branches: []string{"^main$"}, SkipBranches: []string{"^main-old$"}},
+				RegexpChangeMatcher: prowconfig.RegexpChangeMatcher{RunIfChanged: `^pkg/.*\.go$`},
+			},
+			expected: map[string]string{
+				onCELExpressionAnnotation: `event == "pull_request" && target_branch.matches("^main$") && !target_branch.matches("^main-old$") && files.all.exists(f, f.matches("^pkg/.*\\.go$"))`,
+				onCommentAnnotation:       "/test unit",
+			},
+		},
+		{
+			name: "skip if only changed",
+			job:  prowconfig.Presubmit{RegexpChangeMatcher: prowconfig.RegexpChangeMatcher{SkipIfOnlyChanged: `^docs/`}},
+			expected: map[string]string{
+				onCELExpressionAnnotation: `event == "pull_request" && !files.all.all(f, f.matches("^docs/"))`,
+				onCommentAnnotation:       "/test unit",
+			},
+		},
+		{
+			name:     "run when asked for only",
+			job:      prowconfig.Presubmit{},
+			expected: map[string]string{onCommentAnnotation: "/test unit"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.job.Name = "pull-ci-org-repo-main-unit"
+			tc.job.Trigger = "/test unit"
+			tc.job.Spec = NewCiOperatorPodSpecGenerator().MustBuild()
+			run, err := presubmitPipelineRun("org", "repo", tc.job)
+			if err != nil {
+				t.Fatalf("failed to generate the PipelineRun: %v", err)
+			}
+			if diff := cmp.Diff(tc.expected, run.Annotations); diff != "" {
+				t.Errorf("unexpected triggers (-want, +got): %s", diff)
+			}
+		})
+	}
+}
+
+func TestWritePipelineRunsToDir(t *testing.T) {
+	info := &ciop.Metadata{Org: "org", Repo: "repo", Branch: "main"}
+	config := &ciop.ReleaseBuildConfiguration{
+		Images:                 ciop.ImageConfiguration{Items: []ciop.ProjectDirectoryImageBuildStepConfiguration{{From: "base", To: "image"}}},
+		PromotionConfiguration: &ciop.PromotionConfiguration{Targets: []ciop.PromotionTarget{{Namespace: "ci"}}},
+		Prowgen:                &ciop.ProwgenOverrides{Private: true},
+		Tests: []ciop.TestStepConfiguration{
+			{As: "unit", ContainerTestConfiguration: &ciop.ContainerTestConfiguration{From: "src"}, RunIfChanged: "^pkg/"},
+			{As: "e2e", ContainerTestConfiguration: &ciop.ContainerTestConfiguration{From: "src"}, Interval: utilpointer.String("24h")},
+		},
+	}
+	jobs, err := GenerateJobs(config, info, nil)
+	if err != nil {
+		t.Fatalf("failed to generate the jobs: %v", err)
+	}
+	dir := t.TempDir()
+	repoDir := filepath.Join(dir, "org", "repo")
+	runsDir := filepath.Join(repoDir, PipelineRunsDir)
+	prowJobs := filepath.Join(repoDir, "org-repo-main-presubmits.yaml")
+	if err := os.MkdirAll(repoDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(prowJobs, []byte("presubmits: {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := WritePipelineRunsToDir(dir, "org", "repo", jobs, Generator, nil); err != nil {
+		t.Fatalf("failed to write the PipelineRuns: %v", err)
+	}
+	for _, name := range []string{"pull-ci-org-repo-main-images", "pull-ci-org-repo-main-unit", "branch-ci-org-repo-main-images"} {
+		raw, err := os.ReadFile(filepath.Join(runsDir, name+".yaml"))
+		if err != nil {
+			t.Fatalf("failed to read the PipelineRun %s: %v", name, err)
+		}
+		testhelper.CompareWithFixture(t, raw, testhelper.WithSuffix("_"+name))
+	}
+	if _, err := os.Stat(filepath.Join(runsDir, "periodic-ci-org-repo-main-e2e.yaml")); !os.IsNotExist(err) {
+		t.Errorf("expected no PipelineRun for the periodic, got %v", err)
+	}
+	if raw, err := os.ReadFile(prowJobs); err != nil || string(raw) != "presubmits: {}\n" {
+		t.Errorf("expected the Prow jobs to be left alone, got %q, %v", string(raw), err)
+	}
+
+	handWritten := filepath.Join(runsDir, "custom.yaml")
+	if err := os.WriteFile(handWritten, []byte("apiVersion: tekton.dev/v1\nkind: PipelineRun\nmetadata:\n  name: custom\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config.Tests = config.Tests[1:]
+	if jobs, err = GenerateJobs(config, info, nil); err != nil {
+		t.Fatalf("failed to generate the jobs: %v", err)
+	}
+	if err := WritePipelineRunsToDir(dir, "org", "repo", jobs, Generator, nil); err != nil {
+		t.Fatalf("failed to write the PipelineRuns: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(runsDir, "pull-ci-org-repo-main-unit.yaml")); !os.IsNotExist(err) {
+		t.Errorf("expected the PipelineRun of the removed presubmit to be removed, got %v", err)
+	}
+	if _, err := os.Stat(handWritten); err != nil {
+		t.Errorf("expected the PipelineRun that was not generated to be kept, got %v", err)
+	}
+}
+
+func TestCiOperatorPipelineGenerator(t *testing.T) {
+	generator := NewCiOperatorPipelineGenerator()
+	generator.Add(Secrets(&ciop.Secret{Name: "secret", MountPath: "/secret"}), Targets("unit"))
+	spec, err := generator.Build()
+	if err != nil {
+		t.Fatalf("failed to build the PodSpec: %v", err)
+	}
+	run, err := generator.BuildPipelineRun()
+	if err != nil {
+		t.Fatalf("failed to build the PipelineRun: %v", err)
+	}
+	expected, err := pipelineRunFor(spec)
+	if err != nil {
+		t.Fatalf("failed to build the PipelineRun of the PodSpec: %v", err)
+	}
+	if diff := cmp.Diff(expected, run); diff != "" {
+		t.Errorf("the PipelineRun does not run the PodSpec of the generator (-want, +got): %s", diff)
+	}
+}
@@ -0,0 +1,204 @@
+package prowgen
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	prowconfig "sigs.k8s.io/prow/pkg/config"
+)
+
+// DefaultSource is the source of the fields prowgen sets without being configured to
+const DefaultSource = "default"
+
+// Provenance explains the generated jobs by their name
+type Provenance map[string]JobProvenance
+
+// JobProvenance maps the significant fields of a generated job to the part of the
+// ci-operator configuration that set them
+type JobProvenance struct {
+	// Kind is presubmit, postsubmit or periodic
+	Kind   string            `json:"kind"`
+	Fields []FieldProvenance `json:"fields"`
+}
+
+// FieldProvenance explains the value of a field of a generated job. Fields the
+// configuration left unset on purpose, like a label that is omitted, are explained
+// with an empty value.
+type FieldProvenance struct {
+	Field string `json:"field"`
+	Value string `json:"value,omitempty"`
+	// Source is the path of the configuration field that set the value, like
+	// `tests[e2e].run_if_changed`, or DefaultSource when nothing did
+	Source string `json:"source"`
+}
+
+// String renders the explanation of the job, one field per line
+func (p JobProvenance) String() string {
+	var lines []string
+	for _, field := range p.Fields {
+		value := field.Value
+		if value == "" {
+			value = "<unset>"
+		}
+		lines = append(lines, fmt.Sprintf("%s: %s <- %s", field.Field, value, field.Source))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func testPath(name string) string {
+	return fmt.Sprintf("tests[%s]", name)
+}
+
+// fieldSources maps the fields of a job to the configuration field that set them
+type fieldSources map[string]string
+
+// explain records what set the field, the first source recorded for a field wins
+// so that the generic defaults do not override what was recorded for a specific
+// kind of job
+func (s fieldSources) explain(field, source string) {
+	if _, recorded := s[field]; !recorded {
+		s[field] = source
+	}
+}
+
+// explain records what set a field of all jobs built by the builder, it takes
+// precedence over what the generation of the job records for the same field
+func (p *prowJobBaseBuilder) explain(field, source string) *prowJobBaseBuilder {
+	if p.sources == nil {
+		p.sources = fieldSources{}
+	}
+	p.sources.explain(field, source)
+	return p
+}
+
+// explainJob records what set the fields of the job that is being generated,
+// fields without a source are left out
+func (p *prowJobBaseBuilder) explainJob(sources map[string]string) {
+	p.jobSources = fieldSources{}
+	for field, source := range sources {
+		if source != "" {
+			p.jobSources.explain(field, source)
+		}
+	}
+}
+
+// setFields joins the paths of the fields of the configuration at path that are set
+func setFields(path string, fields map[string]bool) string {
+	if path == "" {
+		return ""
+	}
+	var paths []string
+	for field, set := range fields {
+		if set {
+			paths = append(paths, path+"."+field)
+		}
+	}
+	sort.Strings(paths)
+	return strings.Join(paths, ", ")
+}
+
+func (p *prowJobBaseBuilder) recordPresubmit(presubmit prowconfig.Presubmit) {
+	p.record("presubmit", presubmit.JobBase, map[string]string{
+		"always_run":           fmt.Sprintf("%t", presubmit.AlwaysRun),
+		"run_if_changed":       presubmit.RunIfChanged,
+		"skip_if_only_changed": presubmit.SkipIfOnlyChanged,
+		"optional":             boolValue(presubmit.Optional),
+		"trigger":              presubmit.Trigger,
+		"branches":             strings.Join(presubmit.Branches, ","),
+		"skip_branches":        strings.Join(presubmit.SkipBranches, ","),
+	})
+}
+
+func (p *prowJobBaseBuilder) recordPostsubmit(postsubmit prowconfig.Postsubmit) {
+	alwaysRun := postsubmit.AlwaysRun != nil && *postsubmit.AlwaysRun
+	p.record("postsubmit", postsubmit.JobBase, map[string]string{
+		"always_run":           fmt.Sprintf("%t", alwaysRun),
+		"run_if_changed":       postsubmit.RunIfChanged,
+		"skip_if_only_changed": postsubmit.SkipIfOnlyChanged,
+		"branches":             strings.Join(postsubmit.Branches, ","),
+	})
+}
+
+func (p *prowJobBaseBuilder) recordPeriodic(periodic prowconfig.Periodic) {
+	p.record("periodic", periodic.JobBase, map[string]string{
+		"cron":             periodic.Cron,
+		"interval":         periodic.Interval,
+		"minimum_interval": periodic.MinimumInterval,
+	})
+}
+
+// record explains the job that was built, if the provenance is collected. The
+// fields specific to the kind of the job are given with their values.
+func (p *prowJobBaseBuilder) record(kind string, base prowconfig.JobBase, fields map[string]string) {
+	if p.provenance == nil {
+		return
+	}
+	sources := fieldSources{}
+	for field, source := range p.sources {
+		sources[field] = source
+	}
+	for field, source := range p.jobSources {
+		sources.explain(field, source)
+	}
+	values := map[string]string{
+		"cluster":    base.Cluster,
+		"hidden":     boolValue(base.Hidden),
+		"path_alias": base.UtilityConfig.PathAlias,
+	}
+	if base.ReporterConfig != nil && base.ReporterConfig.Slack != nil {
+		values["slack_reporter_config"] = base.ReporterConfig.Slack.Channel
+	}
+	if base.MaxConcurrency != 0 {
+		values["max_concurrency"] = fmt.Sprintf("%d", base.MaxConcurrency)
+	}
+	if config := base.UtilityConfig.DecorationConfig; config != nil {
+		if config.Timeout != nil {
+			values["decoration_config.timeout"] = config.Timeout.Duration.String()
+		}
+		if config.SkipCloning != nil {
+			values["decoration_config.skip_cloning"] = boolValue(*config.SkipCloning)
+		}
+		values["decoration_config.sparse_checkout_files"] = strings.Join(config.SparseCheckoutFiles, ",")
+		if config.OauthTokenSecret != nil {
+			values["decoration_config.oauth_token_secret"] = config.OauthTokenSecret.Name
+		}
+	}
+	for key, value := range base.Labels {
+		values["labels."+key] = value
+	}
+	for key, value := range base.Annotations {
+		values["annotations."+key] = value
+	}
+	for field, value := range fields {
+		values[field] = value
+	}
+
+	provenance := JobProvenance{Kind: kind}
+	for field, value := range values {
+		source, explained := sources[field]
+		if value == "" && !explained {
+			continue
+		}
+		if !explained {
+			source = DefaultSource
+		}
+		provenance.Fields = append(provenance.Fields, FieldProvenance{Field: field, Value: value, Source: source})
+	}
+	for field, source := range sources {
+		if _, known := values[field]; !known {
+			provenance.Fields = append(provenance.Fields, FieldProvenance{Field: field, Source: source})
+		}
+	}
+	sort.Slice(provenance.Fields, func(i, j int) bool {
+		return provenance.Fields[i].Field < provenance.Fields[j].Field
+	})
+	p.provenance[base.Name] = provenance
+}
+
+func boolValue(value bool) string {
+	if value {
+		return "true"
+	}
+	return ""
+}
@@ -0,0 +1,124 @@
+package prowgen
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	utilpointer "k8s.io/utils/pointer"
+
+	ciop "github.com/openshift/ci-tools/pkg/api"
+)
+
+func TestGenerateJobsWithProvenance(t *testing.T) {
+	info := &ciop.Metadata{Org: "org", Repo: "repo", Branch: "branch"}
+	container := &ciop.ContainerTestConfiguration{From: "src"}
+	testCases := []struct {
+		name     string
+		config   *ciop.ReleaseBuildConfiguration
+		job      string
+		kind     string
+		expected []FieldProvenance
+	}{
+		{
+			name: "presubmit that runs if changed and cannot be rehearsed",
+			config: &ciop.ReleaseBuildConfiguration{
+				Tests: []ciop.TestStepConfiguration{{
+					As: "unit", ContainerTestConfiguration: container, RunIfChanged: "^pkg/", DisableRehearsal: true,
+				}},
+			},
+			job:  "pull-ci-org-repo-branch-unit",
+			kind: "presubmit",
+			expected: []FieldProvenance{
+				{Field: "always_run", Value: "false", Source: "tests[unit].run_if_changed"},
+				{Field: "branches", Value: "^branch$,^branch-", Source: "zz_generated_metadata.branch"},
+				{Field: "decoration_config.skip_cloning", Value: "true", Source: "images.items and build_root.from_repository: no file of the repository is needed"},
+				{Field: "labels.pj-rehearse.openshift.io/can-be-rehearsed", Source: "tests[unit].disable_rehearsal"},
+				{Field: "run_if_changed", Value: "^pkg/", Source: "tests[unit].run_if_changed"},
+				{Field: "trigger", Value: `(?m)^/test( | .* )unit,?($|\s.*)`, Source: DefaultSource},
+			},
+		},
+		{
+			name: "private presubmit on a cluster",
+			config: &ciop.ReleaseBuildConfiguration{
+				Prowgen: &ciop.ProwgenOverrides{Private: true},
+				Tests: []ciop.TestStepConfiguration{{
+					As: "unit", ContainerTestConfiguration: container, Cluster: "build01", Optional: true,
+				}},
+			},
+			job:  "pull-ci-org-repo-branch-unit",
+			kind: "presubmit",
+			expected: []FieldProvenance{
+				{Field: "always_run", Value: "true", Source: DefaultSource},
+				{Field: "branches", Value: "^branch$,^branch-", Source: "zz_generated_metadata.branch"},
+				{Field: "cluster", Value: "build01", Source: "tests[unit].cluster"},
+				{Field: "decoration_config.skip_cloning", Value: "true", Source: "images.items and build_root.from_repository: no file of the repository is needed"},
+				{Field: "hidden", Value: "true", Source: "prowgen.private"},
+				{Field: "labels.ci-operator.openshift.io/cluster", Value: "build01", Source: "tests[unit].cluster"},
+				{Field: "labels.pj-rehearse.openshift.io/can-be-rehearsed", Value: "true", Source: DefaultSource},
+				{Field: "optional", Value: "true", Source: "tests[unit].optional"},
+				{Field: "trigger", Value: `(?m)^/test( | .* )unit,?($|\s.*)`, Source: DefaultSource},
+			},
+		},
+		{
+			name: "daily periodic",
+			config: &ciop.ReleaseBuildConfiguration{
+				Tests: []ciop.TestStepConfiguration{{
+					As: "e2e", ContainerTestConfiguration: container, Cron: utilpointer.String("@daily"),
+				}},
+			},
+			job:  "periodic-ci-org-repo-branch-e2e",
+			kind: "periodic",
+			expected: []FieldProvenance{
+				{Field: "cron", Value: "1 23 * * *", Source: "tests[e2e].cron: @daily, at a time derived from the job name"},
+				{Field: "decoration_config.skip_cloning", Value: "true", Source: "images.items and build_root.from_repository: no file of the repository is needed"},
+				{Field: "labels.pj-rehearse.openshift.io/can-be-rehearsed", Value: "true", Source: DefaultSource},
+			},
+		},
+		{
+			name: "postsubmit with a concurrency limit",
+			config: &ciop.ReleaseBuildConfiguration{
+				Tests: []ciop.TestStepConfiguration{{
+					As: "deploy", ContainerTestConfiguration: container, Postsubmit: true, MaxConcurrency: 3,
+				}},
+			},
+			job:  "branch-ci-org-repo-branch-deploy",
+			kind: "postsubmit",
+			expected: []FieldProvenance{
+				{Field: "always_run", Value: "true", Source: DefaultSource},
+				{Field: "branches", Value: "^branch$", Source: "zz_generated_metadata.branch"},
+				{Field: "decoration_config.skip_cloning", Value: "true", Source: "images.items and build_root.from_repository: no file of the repository is needed"},
+				{Field: "max_concurrency", Value: "3", Source: "tests[deploy].max_concurrency"},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			jobs, provenance, err := GenerateJobsWithProvenance(tc.config, info, nil)
+			if err != nil {
+				t.Fatalf("failed to generate jobs: %v", err)
+			}
+			if count := len(jobs.AllStaticPresubmits(nil)) + len(jobs.AllStaticPostsubmits(nil)) + len(jobs.AllPeriodics()); count != len(provenance) {
+				t.Errorf("expected every one of the %d generated jobs to be explained, got %d", count, len(provenance))
+			}
+			explained, ok := provenance[tc.job]
+			if !ok {
+				t.Fatalf("job %s was not explained", tc.job)
+			}
+			if diff := cmp.Diff(JobProvenance{Kind: tc.kind, Fields: tc.expected}, explained); diff != "" {
+				t.Errorf("unexpected provenance (-want, +got): %s", diff)
+			}
+		})
+	}
+}
+
+func TestJobProvenanceString(t *testing.T) {
+	provenance := JobProvenance{Kind: "presubmit", Fields: []FieldProvenance{
+		{Field: "always_run", Value: "false", Source: "tests[unit].run_if_changed"},
+		{Field: "labels.pj-rehearse.openshift.io/can-be-rehearsed", Source: "tests[unit].disable_rehearsal"},
+	}}
+	expected := `always_run: false <- tests[unit].run_if_changed
+labels.pj-rehearse.openshift.io/can-be-rehearsed: <unset> <- tests[unit].disable_rehearsal`
+	if diff := cmp.Diff(expected, provenance.String()); diff != "" {
+		t.Errorf("unexpected explanation (-want, +got): %s", diff)
+	}
+}
@@ -32,6 +32,15 @@ const (
 // Prune() function to remove all stale jobs and label the jobs as simply
 // "generated".
 func GenerateJobs(configSpec *cioperatorapi.ReleaseBuildConfiguration, info *cioperatorapi.Metadata, clusterProfileResolver ClusterProfileResolver) (*prowconfig.JobConfig, error) {
+	jobConfig, _, err := GenerateJobsWithProvenance(configSpec, info, clusterProfileResolver)
+	return jobConfig, err
+}
+
+// GenerateJobsWithProvenance generates the jobs like GenerateJobs does, and
+// explains which fields of the configuration set the significant fields of
+// every generated job
+func GenerateJobsWithProvenance(configSpec *cioperatorapi.ReleaseBuildConfiguration, info *cioperatorapi.Metadata, clusterProfileResolver ClusterProfileResolver) (*prowconfig.JobConfig, Provenance, error) {
+	provenance := Provenance{}
 	orgrepo := fmt.Sprintf("%s/%s", info.Org, info.Repo)
 	presubmits := map[string][]prowconfig.Presubmit{}
 	postsubmits := map[string][]prowconfig.Postsubmit{}
@@ -39,6 +48,7 @@ func GenerateJobs(configSpec *cioperatorapi.ReleaseBuildConfiguration, info *cio
 	disableAllRehearsals := configSpec.Prowgen != nil && configSpec.Prowgen.DisableRehearsals
 
 	for _, element := range configSpec.Tests {
+		path := testPath(element.As)
 		shardCount := 1
 		if element.ShardCount != nil {
 			shardCount = *element.ShardCount
@@ -48,8 +58,9 @@ func GenerateJobs(configSpec *cioperatorapi.ReleaseBuildConfiguration, info *cio
 		for i := 1; i <= shardCount; i++ {
 			g, err := NewProwJobBaseBuilderForTest(configSpec, info, NewCiOperatorPodSpecGenerator(), element, clusterProfileResolver)
 			if err != nil {
-				return nil, fmt.Errorf("new prowjob builder: %w", err)
+				return nil, nil, fmt.Errorf("new prowjob builder: %w", err)
 			}
+			g.provenance = provenance
 
 			name := element.As
 			if shardCount > 1 {
@@ -60,9 +71,16 @@ func GenerateJobs(configSpec *cioperatorapi.ReleaseBuildConfiguration, info *cio
 
 			if element.NodeArchitecture != "" {
 				g.WithLabel(fmt.Sprintf("capability/%s", element.NodeArchitecture), string(element.NodeArchitecture))
+				g.explain(fmt.Sprintf("labels.capability/%s", element.NodeArchitecture), path+".node_architecture")
 			}
 
 			disableRehearsal := disableAllRehearsals || element.DisableRehearsal
+			switch {
+			case disableAllRehearsals:
+				g.explain("labels."+jc.CanBeRehearsedLabel, "prowgen.disable_rehearsals")
+			case element.DisableRehearsal:
+				g.explain("labels."+jc.CanBeRehearsedLabel, path+".disable_rehearsal")
+			}
 
 			if element.IsPeriodic() {
 				cron := ""
@@ -83,6 +101,7 @@ func GenerateJobs(configSpec *cioperatorapi.ReleaseBuildConfiguration, info *cio
 				}
 
 				periodic := GeneratePeriodicForTest(g, info, FromConfigSpec(configSpec), func(options *GeneratePeriodicOptions) {
+					options.source = path
 					options.Cron = cron
 					options.Capabilities = element.Capabilities
 					options.Interval = interval
@@ -93,12 +112,14 @@ func GenerateJobs(configSpec *cioperatorapi.ReleaseBuildConfiguration, info *cio
 					options.MaxConcurrency = element.MaxConcurrency
 					options.SlackReporterConfig = element.SlackReporterConfig
 				})
+				g.recordPeriodic(*periodic)
 				periodics = append(periodics, *periodic)
 				if element.Presubmit {
 					handlePresubmit(g, element, info, name, disableRehearsal, configSpec.Resources.RequirementsForStep(element.As).Requests, presubmits, orgrepo, true)
 				}
 			} else if element.Postsubmit {
 				postsubmit := generatePostsubmitForTest(g, info, func(options *generatePostsubmitOptions) {
+					options.source = path
 					options.runIfChanged = element.RunIfChanged
 					options.Capabilities = element.Capabilities
 					options.skipIfOnlyChanged = element.SkipIfOnlyChanged
@@ -107,7 +128,9 @@ func GenerateJobs(configSpec *cioperatorapi.ReleaseBuildConfiguration, info *cio
 				postsubmit.MaxConcurrency = 1
 				if element.MaxConcurrency != 0 {
 					postsubmit.MaxConcurrency = element.MaxConcurrency
+					g.explain("max_concurrency", path+".max_concurrency")
 				}
+				g.recordPostsubmit(*postsubmit)
 				postsubmits[orgrepo] = append(postsubmits[orgrepo], *postsubmit)
 			} else {
 				handlePresubmit(g, element, info, name, disableRehearsal, configSpec.Resources.RequirementsForStep(element.As).Requests, presubmits, orgrepo, false)
@@ -116,7 +139,9 @@ func GenerateJobs(configSpec *cioperatorapi.ReleaseBuildConfiguration, info *cio
 	}
 
 	newJobBaseBuilder := func() *prowJobBaseBuilder {
-		return NewProwJobBaseBuilder(configSpec, info, NewCiOperatorPodSpecGenerator())
+		b := NewProwJobBaseBuilder(configSpec, info, NewCiOperatorPodSpecGenerator())
+		b.provenance = provenance
+		return b
 	}
 
 	imageTargets := cioperatorapi.ImageTargets(configSpec)
@@ -132,29 +157,35 @@ func GenerateJobs(configSpec *cioperatorapi.ReleaseBuildConfiguration, info *cio
 		injectCapabilitiesForImgJobs(jobBaseGen, configSpec.Images.Items)
 
 		optional := false
-		for _, image := range configSpec.Images.Items {
+		for i, image := range configSpec.Images.Items {
 			if image.Optional {
 				optional = true
+				jobBaseGen.explain("optional", fmt.Sprintf("images.items[%d].optional", i))
 				break
 			}
 		}
 
 		jobBaseGen.PodSpec.Add(Targets(presubmitTargets...))
-		presubmits[orgrepo] = append(presubmits[orgrepo], *generatePresubmitForTest(jobBaseGen, imagesTestName, info, func(options *generatePresubmitOptions) {
+		imagesPresubmit := generatePresubmitForTest(jobBaseGen, imagesTestName, info, func(options *generatePresubmitOptions) {
+			options.source = "images"
 			options.optional = optional
 			options.runIfChanged = configSpec.Images.RunIfChanged
 			options.skipIfOnlyChanged = configSpec.Images.SkipIfOnlyChanged
 			options.pipelineRunIfChanged = configSpec.Images.PipelineRunIfChanged
 			options.pipelineSkipIfOnlyChanged = configSpec.Images.PipelineSkipIfOnlyChanged
 			options.slackReporterConfig = configSpec.Images.SlackReporterConfig
-		}))
+		})
+		jobBaseGen.recordPresubmit(*imagesPresubmit)
+		presubmits[orgrepo] = append(presubmits[orgrepo], *imagesPresubmit)
 
 		if configSpec.PromotionConfiguration != nil {
 			jobBaseGen = newJobBaseBuilder().TestName(imagesTestName)
 			injectCapabilitiesForImgJobs(jobBaseGen, configSpec.Images.Items)
 
 			jobBaseGen.PodSpec.Add(Promotion(), Targets(imageTargets.UnsortedList()...))
+			jobBaseGen.explain("labels."+cioperatorapi.PromotionJobLabelKey, "promotion")
 			postsubmit := generatePostsubmitForTest(jobBaseGen, info, func(options *generatePostsubmitOptions) {
+				options.source = "images"
 				options.slackReporterConfig = configSpec.Images.SlackReporterConfig
 			})
 			postsubmit.MaxConcurrency = 1
@@ -162,18 +193,21 @@ func GenerateJobs(configSpec *cioperatorapi.ReleaseBuildConfiguration, info *cio
 				postsubmit.Labels = map[string]string{}
 			}
 			postsubmit.Labels[cioperatorapi.PromotionJobLabelKey] = "true"
+			jobBaseGen.recordPostsubmit(*postsubmit)
 			postsubmits[orgrepo] = append(postsubmits[orgrepo], *postsubmit)
 			if configSpec.PromotionConfiguration.Cron != "" {
 				periodic := GeneratePeriodicForTest(jobBaseGen, info, func(options *GeneratePeriodicOptions) {
 					//not needed in promotion job, the same way postsubmit does not need it
 					options.DisableRehearsal = true
 					options.Cron = configSpec.PromotionConfiguration.Cron
+					options.source = "promotion"
 				})
 				periodic.MaxConcurrency = 1
 				if periodic.Labels == nil {
 					periodic.Labels = map[string]string{}
 				}
 				periodic.Labels[cioperatorapi.PromotionJobLabelKey] = "true"
+				jobBaseGen.recordPeriodic(*periodic)
 				periodics = append(periodics, *periodic)
 
 			}
@@ -183,7 +217,7 @@ func GenerateJobs(configSpec *cioperatorapi.ReleaseBuildConfiguration, info *cio
 	skipOperatorPresubmits := configSpec.Prowgen != nil && configSpec.Prowgen.SkipOperatorPresubmits
 	if configSpec.Operator != nil && !skipOperatorPresubmits {
 		containsUnnamedBundle := false
-		for _, bundle := range configSpec.Operator.Bundles {
+		for i, bundle := range configSpec.Operator.Bundles {
 			if bundle.As == "" {
 				containsUnnamedBundle = true
 				continue
@@ -198,16 +232,23 @@ func GenerateJobs(configSpec *cioperatorapi.ReleaseBuildConfiguration, info *cio
 			} else {
 				jobBaseGen.PodSpec.Add(Targets(testName))
 			}
-			presubmits[orgrepo] = append(presubmits[orgrepo], *generatePresubmitForTest(jobBaseGen, testName, info, func(options *generatePresubmitOptions) {
+			presubmit := generatePresubmitForTest(jobBaseGen, testName, info, func(options *generatePresubmitOptions) {
+				options.source = fmt.Sprintf("operator.bundles[%d]", i)
 				options.optional = bundle.Optional
 				options.Capabilities = bundle.Capabilities
-			}))
+			})
+			jobBaseGen.recordPresubmit(*presubmit)
+			presubmits[orgrepo] = append(presubmits[orgrepo], *presubmit)
 		}
 		if containsUnnamedBundle {
 			name := string(cioperatorapi.PipelineImageStreamTagReferenceIndexImage)
 			jobBaseGen := newJobBaseBuilder().TestName(name)
 			jobBaseGen.PodSpec.Add(Targets(name))
-			presubmits[orgrepo] = append(presubmits[orgrepo], *generatePresubmitForTest(jobBaseGen, name, info))
+			presubmit := generatePresubmitForTest(jobBaseGen, name, info, func(options *generatePresubmitOptions) {
+				options.source = "operator.bundles"
+			})
+			jobBaseGen.recordPresubmit(*presubmit)
+			presubmits[orgrepo] = append(presubmits[orgrepo], *presubmit)
 		}
 	}
 
@@ -215,7 +256,7 @@ func GenerateJobs(configSpec *cioperatorapi.ReleaseBuildConfiguration, info *cio
 		PresubmitsStatic:  presubmits,
 		PostsubmitsStatic: postsubmits,
 		Periodics:         periodics,
-	}, nil
+	}, provenance, nil
 }
 
 func handlePresubmit(g *prowJobBaseBuilder, element cioperatorapi.TestStepConfiguration, info *cioperatorapi.Metadata, name string, disableRehearsal bool, requests cioperatorapi.ResourceList, presubmits map[string][]prowconfig.Presubmit, orgrepo string, fromPeriodic bool) {
@@ -224,6 +265,7 @@ func handlePresubmit(g *prowJobBaseBuilder, element cioperatorapi.TestStepConfig
 		slackConfig = nil
 	}
 	presubmit := generatePresubmitForTest(g, name, info, func(options *generatePresubmitOptions) {
+		options.source = testPath(element.As)
 		options.pipelineRunIfChanged = element.PipelineRunIfChanged
 		options.pipelineSkipIfOnlyChanged = element.PipelineSkipIfOnlyChanged
 		options.Capabilities = element.Capabilities
@@ -239,11 +281,15 @@ func handlePresubmit(g *prowJobBaseBuilder, element cioperatorapi.TestStepConfig
 	v, requestingKVM := requests[cioperatorapi.KVMDeviceLabel]
 	if requestingKVM {
 		presubmit.Labels[cioperatorapi.KVMDeviceLabel] = v
+		g.jobSources.explain("labels."+cioperatorapi.KVMDeviceLabel, fmt.Sprintf("resources.%s.requests", element.As))
 	}
+	g.recordPresubmit(*presubmit)
 	presubmits[orgrepo] = append(presubmits[orgrepo], *presubmit)
 }
 
 type generatePresubmitOptions struct {
+	// source is the path of the configuration the job is generated for
+	source                    string
 	pipelineRunIfChanged      string
 	pipelineSkipIfOnlyChanged string
 	Capabilities              []string
@@ -330,10 +376,36 @@ func generatePresubmitForTest(jobBaseBuilder *prowJobBaseBuilder, name string, i
 	}
 	pj.MaxConcurrency = opts.maxConcurrency
 	injectCapabilities(pj.Labels, opts.Capabilities)
+
+	sources := map[string]string{
+		"always_run": setFields(opts.source, map[string]bool{
+			"run_if_changed":                opts.runIfChanged != "",
+			"skip_if_only_changed":          opts.skipIfOnlyChanged != "",
+			"pipeline_run_if_changed":       opts.pipelineRunIfChanged != "",
+			"pipeline_skip_if_only_changed": opts.pipelineSkipIfOnlyChanged != "",
+			"always_run":                    opts.defaultDisable,
+		}),
+		"run_if_changed":                            setFields(opts.source, map[string]bool{"run_if_changed": opts.runIfChanged != ""}),
+		"skip_if_only_changed":                      setFields(opts.source, map[string]bool{"skip_if_only_changed": opts.skipIfOnlyChanged != ""}),
+		"annotations.pipeline_run_if_changed":       setFields(opts.source, map[string]bool{"pipeline_run_if_changed": opts.pipelineRunIfChanged != ""}),
+		"annotations.pipeline_skip_if_only_changed": setFields(opts.source, map[string]bool{"pipeline_skip_if_only_changed": opts.pipelineSkipIfOnlyChanged != ""}),
+		"skip_branches":                             setFields(opts.source, map[string]bool{"skip_branches": len(opts.skipBranches) > 0}),
+		"optional":                                  setFields(opts.source, map[string]bool{"optional": opts.optional}),
+		"max_concurrency":                           setFields(opts.source, map[string]bool{"max_concurrency": opts.maxConcurrency != 0}),
+		"slack_reporter_config":                     setFields(opts.source, map[string]bool{"slack_reporter_config": opts.slackReporterConfig != nil}),
+		"trigger":                                   setFields(opts.source, map[string]bool{"always_run": triggerCommand != prowconfig.DefaultTriggerFor(shortName)}),
+		"branches":                                  "zz_generated_metadata.branch",
+	}
+	for _, capability := range opts.Capabilities {
+		sources["labels.capability/"+capability] = setFields(opts.source, map[string]bool{"capabilities": true})
+	}
+	jobBaseBuilder.explainJob(sources)
 	return pj
 }
 
 type generatePostsubmitOptions struct {
+	// source is the path of the configuration the job is generated for
+	source              string
 	runIfChanged        string
 	Capabilities        []string
 	skipIfOnlyChanged   string
@@ -363,6 +435,21 @@ func generatePostsubmitForTest(jobBaseBuilder *prowJobBaseBuilder, info *ciopera
 		Brancher: prowconfig.Brancher{Branches: []string{jc.ExactlyBranch(info.Branch)}},
 	}
 	injectCapabilities(pj.Labels, opts.Capabilities)
+
+	sources := map[string]string{
+		"always_run": setFields(opts.source, map[string]bool{
+			"run_if_changed":       opts.runIfChanged != "",
+			"skip_if_only_changed": opts.skipIfOnlyChanged != "",
+		}),
+		"run_if_changed":        setFields(opts.source, map[string]bool{"run_if_changed": opts.runIfChanged != ""}),
+		"skip_if_only_changed":  setFields(opts.source, map[string]bool{"skip_if_only_changed": opts.skipIfOnlyChanged != ""}),
+		"slack_reporter_config": setFields(opts.source, map[string]bool{"slack_reporter_config": opts.slackReporterConfig != nil}),
+		"branches":              "zz_generated_metadata.branch",
+	}
+	for _, capability := range opts.Capabilities {
+		sources["labels.capability/"+capability] = setFields(opts.source, map[string]bool{"capabilities": true})
+	}
+	jobBaseBuilder.explainJob(sources)
 	return pj
 }
 
@@ -379,6 +466,8 @@ func hashDailyCron(job string) string {
 }
 
 type GeneratePeriodicOptions struct {
+	// source is the path of the configuration the job is generated for
+	source              string
 	Interval            string
 	MinimumInterval     string
 	Capabilities        []string
@@ -444,6 +533,26 @@ func GeneratePeriodicForTest(jobBaseBuilder *prowJobBaseBuilder, info *cioperato
 	}
 	pj.MaxConcurrency = opts.MaxConcurrency
 	injectCapabilities(pj.Labels, opts.Capabilities)
+
+	cronSource := setFields(opts.source, map[string]bool{"cron": opts.Cron != ""})
+	if opts.Cron == "@daily" {
+		cronSource += ": @daily, at a time derived from the job name"
+	}
+	sources := map[string]string{
+		"cron":                  cronSource,
+		"interval":              setFields(opts.source, map[string]bool{"interval": opts.Interval != ""}),
+		"minimum_interval":      setFields(opts.source, map[string]bool{"minimum_interval": opts.MinimumInterval != ""}),
+		"max_concurrency":       setFields(opts.source, map[string]bool{"max_concurrency": opts.MaxConcurrency != 0}),
+		"slack_reporter_config": setFields(opts.source, map[string]bool{"slack_reporter_config": opts.SlackReporterConfig != nil}),
+	}
+	if opts.ReleaseController {
+		release := setFields(opts.source, map[string]bool{"release_controller": true})
+		sources["cron"], sources["interval"], sources["labels."+jc.ReleaseControllerLabel] = release, release, release
+	}
+	for _, capability := range opts.Capabilities {
+		sources["labels.capability/"+capability] = setFields(opts.source, map[string]bool{"capabilities": true})
+	}
+	jobBaseBuilder.explainJob(sources)
 	return pj
 }
 
@@ -454,9 +563,10 @@ func injectCapabilities(labels map[string]string, capabilities []string) {
 }
 
 func injectCapabilitiesForImgJobs(g *prowJobBaseBuilder, imagesConfig []cioperatorapi.ProjectDirectoryImageBuildStepConfiguration) {
-	for _, img := range imagesConfig {
+	for i, img := range imagesConfig {
 		for _, c := range img.AllCapabilities() {
 			g.WithLabel(fmt.Sprintf("capability/%s", c), c)
+			g.explain(fmt.Sprintf("labels.capability/%s", c), fmt.Sprintf("images.items[%d]", i))
 		}
 	}
 }
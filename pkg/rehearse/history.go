@@ -0,0 +1,310 @@
+package rehearse
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	pjapi "sigs.k8s.io/prow/pkg/apis/prowjobs/v1"
+	"sigs.k8s.io/prow/pkg/gcsupload"
+	prowio "sigs.k8s.io/prow/pkg/io"
+	"sigs.k8s.io/prow/pkg/io/providers"
+	"sigs.k8s.io/prow/pkg/pod-utils/downwardapi"
+
+	"github.com/openshift/ci-tools/pkg/junit"
+)
+
+const (
+	// periodics and postsubmits upload their runs to logs/<job>/<build>
+	logsDirectory = "logs"
+	// presubmits upload their runs under the pull request they tested, pr-logs/directory/<job>/<build>.txt
+	// holds the location of every run
+	presubmitsDirectory = "pr-logs/directory"
+	// latestBuildFile holds the ID of the latest run in both directories
+	latestBuildFile    = "latest-build.txt"
+	finishedFile       = "finished.json"
+	artifactsDirectory = "artifacts"
+	// maxListedObjects bounds a listing, jobs that run often have many thousands of runs
+	maxListedObjects = 1000
+	// productionComparisonTimeout bounds reading the results of the rehearsals and the production runs
+	productionComparisonTimeout = 10 * time.Minute
+)
+
+// JobRun is the result of a finished run of a job
+type JobRun struct {
+	// ID is the build ID of the run
+	ID string
+	// Passed is true when the run succeeded
+	Passed bool
+	// Failures are the normalized names of the tests that failed in the run
+	Failures sets.Set[string]
+}
+
+// JobHistory is a source of the results of the runs of jobs
+type JobHistory interface {
+	// RecentRuns returns up to limit of the most recent finished runs of the job, newest first
+	RecentRuns(ctx context.Context, job string, limit int) ([]JobRun, error)
+	// Failures returns the normalized names of the tests that failed in the finished run of the ProwJob
+	Failures(ctx context.Context, pj *pjapi.ProwJob) (sets.Set[string], error)
+}
+
+// storage is the part of an io.Opener the GCS job history reads with
+type storage interface {
+	Iterator(ctx context.Context, prefix, delimiter string) (prowio.ObjectIterator, error)
+	Reader(ctx context.Context, path string) (io.ReadCloser, error)
+}
+
+type gcsJobHistory struct {
+	storage storage
+	bucket  string
+}
+
+// NewGCSJobHistory returns the job history Prow uploaded to the bucket
+func NewGCSJobHistory(opener prowio.Opener, bucket string) JobHistory {
+	return &gcsJobHistory{storage: opener, bucket: bucketPath(bucket)}
+}
+
+func bucketPath(bucket string) string {
+	if strings.Contains(bucket, "://") {
+		return strings.TrimSuffix(bucket, "/")
+	}
+	return "gs://" + strings.TrimSuffix(bucket, "/")
+}
+
+func (h *gcsJobHistory) RecentRuns(ctx context.Context, job string, limit int) ([]JobRun, error) {
+	// the same job may have uploaded runs to both locations when its type changed,
+	// a location is either the directory of the run or a file that holds it
+	locations := map[string]string{}
+	// runs that have not finished yet are skipped, a few more than the limit are listed for them
+	wanted := 2 * limit
+	if err := h.listRecent(ctx, path.Join(logsDirectory, job), wanted, func(attrs prowio.ObjectAttributes) bool {
+		if !attrs.IsDir {
+			return false
+		}
+		locations[path.Base(attrs.Name)] = h.bucket + "/" + strings.TrimSuffix(attrs.Name, "/")
+		return true
+	}); err != nil {
+		return nil, err
+	}
+	if err := h.listRecent(ctx, path.Join(presubmitsDirectory, job), wanted, func(attrs prowio.ObjectAttributes) bool {
+		if attrs.IsDir || !strings.HasSuffix(attrs.Name, ".txt") || path.Base(attrs.Name) == latestBuildFile {
+			return false
+		}
+		locations[strings.TrimSuffix(path.Base(attrs.Name), ".txt")] = h.bucket + "/" + attrs.Name
+		return true
+	}); err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(locations))
+	for id := range locations {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return newerID(ids[i], ids[j])
+	})
+
+	var runs []JobRun
+	for _, id := range ids {
+		if len(runs) >= limit {
+			break
+		}
+		dir := locations[id]
+		if strings.HasSuffix(dir, ".txt") {
+			raw, err := h.read(ctx, dir)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read the location of run %s of %s: %w", id, job, err)
+			}
+			dir = strings.TrimSuffix(strings.TrimSpace(string(raw)), "/")
+		}
+		run, finished, err := h.run(ctx, dir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read run %s of %s: %w", id, job, err)
+		}
+		if !finished {
+			continue
+		}
+		run.ID = id
+		runs = append(runs, run)
+	}
+	return runs, nil
+}
+
+// newerID determines whether the first build ID is newer, build IDs grow over time
+// so the longer of two IDs is the newer one
+func newerID(first, second string) bool {
+	if len(first) != len(second) {
+		return len(first) > len(second)
+	}
+	return first > second
+}
+
+// listRecent visits the runs in the directory of a job that are closest to its latest run, until
+// the visitor accepted the wanted number of them, without listing all the runs of the job. Build
+// IDs grow over time, so runs close in time share the leading digits of their IDs: the runs that
+// share ever fewer digits with the latest one are listed until there are enough of them or a
+// listing would be too long, in which case the runs of the previous listing are all there is.
+func (h *gcsJobHistory) listRecent(ctx context.Context, dir string, wanted int, visit func(prowio.ObjectAttributes) bool) error {
+	raw, err := h.read(ctx, h.bucket+"/"+dir+"/"+latestBuildFile)
+	if prowio.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read the latest run in %s: %w", dir, err)
+	}
+	latest := strings.TrimSpace(string(raw))
+	for digits := len(latest) - 1; digits >= 0; digits-- {
+		var listed []prowio.ObjectAttributes
+		truncated, err := h.iterate(ctx, h.bucket+"/"+dir+"/"+latest[:digits], "/", maxListedObjects, func(attrs prowio.ObjectAttributes) {
+			listed = append(listed, attrs)
+		})
+		if err != nil {
+			return err
+		}
+		if truncated {
+			return nil
+		}
+		// every listing holds the runs of the previous one, visiting them again is harmless
+		visited := 0
+		for _, attrs := range listed {
+			if visit(attrs) {
+				visited++
+			}
+		}
+		if visited >= wanted {
+			return nil
+		}
+	}
+	return nil
+}
+
+func (h *gcsJobHistory) Failures(ctx context.Context, pj *pjapi.ProwJob) (sets.Set[string], error) {
+	if pj.Spec.DecorationConfig == nil || pj.Spec.DecorationConfig.GCSConfiguration == nil || pj.Spec.DecorationConfig.GCSConfiguration.PathStrategy == "" {
+		return nil, fmt.Errorf("ProwJob %s does not upload its results", pj.Name)
+	}
+	gcsConfig := pj.Spec.DecorationConfig.GCSConfiguration
+	spec := downwardapi.NewJobSpec(pj.Spec, pj.Status.BuildID, pj.Name)
+	dir, _, _ := gcsupload.PathsForJob(gcsConfig, &spec, "")
+	return h.failures(ctx, bucketPath(gcsConfig.Bucket)+"/"+dir)
+}
+
+// run reads the result of the run in the directory, it is not finished before it uploads finished.json
+func (h *gcsJobHistory) run(ctx context.Context, dir string) (JobRun, bool, error) {
+	raw, err := h.read(ctx, dir+"/"+finishedFile)
+	if err != nil {
+		if prowio.IsNotExist(err) {
+			return JobRun{}, false, nil
+		}
+		return JobRun{}, false, err
+	}
+	var finished struct {
+		Passed *bool  `json:"passed,omitempty"`
+		Result string `json:"result,omitempty"`
+	}
+	if err := json.Unmarshal(raw, &finished); err != nil {
+		return JobRun{}, false, fmt.Errorf("failed to unmarshal %s: %w", finishedFile, err)
+	}
+	run := JobRun{Passed: finished.Result == "SUCCESS" || finished.Passed != nil && *finished.Passed}
+	if !run.Passed {
+		if run.Failures, err = h.failures(ctx, dir); err != nil {
+			return JobRun{}, false, err
+		}
+	}
+	return run, true, nil
+}
+
+// failures collects the tests that failed in the jUnit files at the top of the artifacts of the run,
+// where ci-operator records the steps that failed. The artifacts of the steps are not searched, a run
+// can upload many thousands of them. Files that cannot be parsed are not the reason a run failed and
+// are ignored.
+func (h *gcsJobHistory) failures(ctx context.Context, dir string) (sets.Set[string], error) {
+	_, bucket, _, err := providers.ParseStoragePath(dir)
+	if err != nil {
+		return nil, err
+	}
+	// listed names are relative to the bucket the run uploaded to
+	root := dir[:strings.Index(dir, "://")+len("://")] + bucket
+	files := sets.New[string]()
+	if _, err := h.iterate(ctx, dir+"/"+artifactsDirectory+"/", "/", maxListedObjects, func(attrs prowio.ObjectAttributes) {
+		if name := path.Base(attrs.Name); !attrs.IsDir && strings.HasPrefix(name, "junit") && strings.HasSuffix(name, ".xml") {
+			files.Insert(attrs.Name)
+		}
+	}); err != nil {
+		return nil, err
+	}
+	failures := sets.New[string]()
+	for _, file := range sets.List(files) {
+		raw, err := h.read(ctx, root+"/"+file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		suites, err := junit.Parse(raw)
+		if err != nil {
+			continue
+		}
+		junit.Normalize(suites)
+		for id, result := range junit.Results(suites) {
+			if result == junit.TestResultFailed {
+				failures.Insert(id.Name)
+			}
+		}
+	}
+	return failures, nil
+}
+
+// iterate visits up to limit objects with the prefix, it returns whether there were more
+func (h *gcsJobHistory) iterate(ctx context.Context, prefix, delimiter string, limit int, visit func(prowio.ObjectAttributes)) (bool, error) {
+	iterator, err := h.storage.Iterator(ctx, prefix, delimiter)
+	if err != nil {
+		return false, fmt.Errorf("failed to list %s: %w", prefix, err)
+	}
+	for listed := 0; ; listed++ {
+		attrs, err := iterator.Next(ctx)
+		if errors.Is(err, io.EOF) {
+			return false, nil
+		}
+		if err != nil {
+			return false, fmt.Errorf("failed to list %s: %w", prefix, err)
+		}
+		if listed == limit {
+			return true, nil
+		}
+		visit(attrs)
+	}
+}
+
+func (h *gcsJobHistory) read(ctx context.Context, path string) ([]byte, error) {
+	reader, err := h.storage.Reader(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	defer prowio.LogClose(reader)
+	return io.ReadAll(reader)
+}
+
+// ProductionComparison compares the result of a rehearsal with the recent production runs of the rehearsed job
+type ProductionComparison struct {
+	// Job is the name of the rehearsed production job
+	Job string
+	// Rehearsal is the name of the rehearsal job
+	Rehearsal string
+	// URL links to the rehearsal run
+	URL string
+	// Passed is true when the rehearsal succeeded
+	Passed bool
+	// Runs counts the recent production runs of the job, and PassedRuns the ones among them that passed.
+	// Runs is zero when the history of the job is unknown.
+	Runs       int
+	PassedRuns int
+	// Failures are the tests that failed in the rehearsal, they are nil when unknown
+	Failures sets.Set[string]
+	// SeenInProduction are the failures of the rehearsal that also failed in a recent production run
+	SeenInProduction sets.Set[string]
+}
@@ -0,0 +1,250 @@
+package rehearse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sirupsen/logrus"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	pjapi "sigs.k8s.io/prow/pkg/apis/prowjobs/v1"
+	prowconfig "sigs.k8s.io/prow/pkg/config"
+	prowio "sigs.k8s.io/prow/pkg/io"
+)
+
+// fakeStorage holds the content of objects by their gs:// paths
+type fakeStorage map[string]string
+
+func (s fakeStorage) Reader(_ context.Context, path string) (io.ReadCloser, error) {
+	content, ok := s[path]
+	if !ok {
+		return nil, prowio.ErrNotFoundTest
+	}
+	return io.NopCloser(strings.NewReader(content)), nil
+}
+
+func (s fakeStorage) Iterator(_ context.Context, prefix, delimiter string) (prowio.ObjectIterator, error) {
+	bucket := prefix[:strings.Index(strings.TrimPrefix(prefix, "gs://"), "/")+len("gs://")+1]
+	seen := sets.New[string]()
+	var attrs []prowio.ObjectAttributes
+	for path := range s {
+		if !strings.HasPrefix(path, prefix) {
+			continue
+		}
+		name := strings.TrimPrefix(path, bucket)
+		if delimiter != "" {
+			if i := strings.Index(strings.TrimPrefix(path, prefix), delimiter); i != -1 {
+				dir := strings.TrimPrefix(prefix, bucket) + strings.TrimPrefix(path, prefix)[:i+1]
+				if !seen.Has(dir) {
+					seen.Insert(dir)
+					attrs = append(attrs, prowio.ObjectAttributes{Name: dir, IsDir: true})
+				}
+				continue
+			}
+		}
+		attrs = append(attrs, prowio.ObjectAttributes{Name: name})
+	}
+	sort.Slice(attrs, func(i, j int) bool { return attrs[i].Name < attrs[j].Name })
+	return &fakeIterator{attrs: attrs}, nil
+}
+
+type fakeIterator struct {
+	attrs []prowio.ObjectAttributes
+}
+
+func (i *fakeIterator) Next(context.Context) (prowio.ObjectAttributes, error) {
+	if len(i.attrs) == 0 {
+		return prowio.ObjectAttributes{}, io.EOF
+	}
+	next := i.attrs[0]
+	i.attrs = i.attrs[1:]
+	return next, nil
+}
+
+const failingJUnit = `<testsuite name="operator" tests="2" failures="1">
+  <testcase name="Run multi-stage test e2e - e2e-test container test"><failure message="">failed after 10m0s</failure></testcase>
+  <testcase name="Run multi-stage test e2e - e2e-ipi-install container test"></testcase>
+</testsuite>`
+
+func TestGCSJobHistoryRecentRuns(t *testing.T) {
+	storage := fakeStorage{
+		"gs://bucket/logs/periodic-job/latest-build.txt":                              "101",
+		"gs://bucket/logs/periodic-job/100/finished.json":                             `{"passed":true,"result":"SUCCESS"}`,
+		"gs://bucket/logs/periodic-job/98/finished.json":                              `{"passed":false,"result":"FAILURE"}`,
+		"gs://bucket/logs/periodic-job/98/artifacts/junit_operator.xml":               failingJUnit,
+		"gs://bucket/logs/periodic-job/98/artifacts/e2e/build-log.txt":                "log",
+		"gs://bucket/logs/periodic-job/98/artifacts/e2e/test/artifacts/junit_e2e.xml": "not xml",
+		"gs://bucket/logs/periodic-job/101/started.json":                              `{}`,
+		"gs://bucket/logs/periodic-job/99/finished.json":                              `{"result":"ABORTED"}`,
+		"gs://bucket/pr-logs/directory/periodic-job/latest-build.txt":                 "1000",
+		"gs://bucket/pr-logs/directory/periodic-job/1000.txt":                         "gs://other/pr-logs/pull/org_repo/1/periodic-job/1000",
+		"gs://other/pr-logs/pull/org_repo/1/periodic-job/1000/finished.json":          `{"passed":false}`,
+		"gs://other/pr-logs/pull/org_repo/1/periodic-job/1000/artifacts/junit.xml":    failingJUnit,
+		"gs://bucket/logs/other-job/102/finished.json":                                `{"passed":false}`,
+	}
+	history := &gcsJobHistory{storage: storage, bucket: "gs://bucket"}
+
+	runs, err := history.RecentRuns(context.Background(), "periodic-job", 4)
+	if err != nil {
+		t.Fatalf("failed to fetch the recent runs: %v", err)
+	}
+	expected := []JobRun{
+		{ID: "1000", Failures: sets.New[string]("Run multi-stage test e2e - e2e-test container test")},
+		{ID: "100", Passed: true},
+		{ID: "99", Failures: sets.New[string]()},
+		{ID: "98", Failures: sets.New[string]("Run multi-stage test e2e - e2e-test container test")},
+	}
+	if diff := cmp.Diff(expected, runs); diff != "" {
+		t.Errorf("unexpected runs (-want, +got): %s", diff)
+	}
+}
+
+func TestGCSJobHistoryRecentRunsBoundsListing(t *testing.T) {
+	storage := fakeStorage{"gs://bucket/logs/periodic-job/latest-build.txt": "2100"}
+	for id := 1000; id <= 2100; id++ {
+		storage[fmt.Sprintf("gs://bucket/logs/periodic-job/%d/finished.json", id)] = `{"passed":true}`
+	}
+	history := &gcsJobHistory{storage: storage, bucket: "gs://bucket"}
+
+	runs, err := history.RecentRuns(context.Background(), "periodic-job", 2)
+	if err != nil {
+		t.Fatalf("failed to fetch the recent runs: %v", err)
+	}
+	if diff := cmp.Diff([]JobRun{{ID: "2100", Passed: true}, {ID: "2099", Passed: true}}, runs); diff != "" {
+		t.Errorf("unexpected runs (-want, +got): %s", diff)
+	}
+
+	// listing all the runs of the job is longer than allowed, only the runs sharing a digit with the latest one are read
+	runs, err = history.RecentRuns(context.Background(), "periodic-job", 600)
+	if err != nil {
+		t.Fatalf("failed to fetch the recent runs: %v", err)
+	}
+	if len(runs) != 101 || runs[0].ID != "2100" || runs[100].ID != "2000" {
+		t.Errorf("expected the 101 runs starting with 2, got %d runs", len(runs))
+	}
+}
+
+func TestGCSJobHistoryRecentRunsWithoutLatestBuild(t *testing.T) {
+	storage := fakeStorage{"gs://bucket/logs/periodic-job/100/finished.json": `{"passed":true}`}
+	history := &gcsJobHistory{storage: storage, bucket: "gs://bucket"}
+
+	runs, err := history.RecentRuns(context.Background(), "periodic-job", 2)
+	if err != nil {
+		t.Fatalf("failed to fetch the recent runs: %v", err)
+	}
+	if len(runs) != 0 {
+		t.Errorf("expected no runs for a job without a latest build, got %v", runs)
+	}
+}
+
+func TestGCSJobHistoryFailures(t *testing.T) {
+	storage := fakeStorage{
+		"gs://results/pr-logs/pull/123/rehearse-123-job/7/artifacts/junit_operator.xml": failingJUnit,
+	}
+	history := &gcsJobHistory{storage: storage, bucket: "gs://bucket"}
+	pj := &pjapi.ProwJob{
+		ObjectMeta: metav1.ObjectMeta{Name: "uid"},
+		Spec: pjapi.ProwJobSpec{
+			Type: pjapi.PresubmitJob,
+			Job:  "rehearse-123-job",
+			Refs: &pjapi.Refs{Org: "org", Repo: "release", Pulls: []pjapi.Pull{{Number: 123}}},
+			DecorationConfig: &pjapi.DecorationConfig{GCSConfiguration: &pjapi.GCSConfiguration{
+				Bucket: "results", PathStrategy: pjapi.PathStrategySingle, DefaultOrg: "org", DefaultRepo: "release",
+			}},
+		},
+		Status: pjapi.ProwJobStatus{BuildID: "7"},
+	}
+
+	failures, err := history.Failures(context.Background(), pj)
+	if err != nil {
+		t.Fatalf("failed to fetch the failures: %v", err)
+	}
+	if diff := cmp.Diff(sets.New[string]("Run multi-stage test e2e - e2e-test container test"), failures); diff != "" {
+		t.Errorf("unexpected failures (-want, +got): %s", diff)
+	}
+
+	pj.Spec.DecorationConfig = nil
+	if _, err := history.Failures(context.Background(), pj); err == nil {
+		t.Error("expected an error for a ProwJob that does not upload its results")
+	}
+}
+
+type fakeJobHistory struct {
+	runs     map[string][]JobRun
+	failures map[string]sets.Set[string]
+}
+
+func (h *fakeJobHistory) RecentRuns(_ context.Context, job string, limit int) ([]JobRun, error) {
+	runs, ok := h.runs[job]
+	if !ok {
+		return nil, errors.New("no history")
+	}
+	if len(runs) > limit {
+		runs = runs[:limit]
+	}
+	return runs, nil
+}
+
+func (h *fakeJobHistory) Failures(_ context.Context, pj *pjapi.ProwJob) (sets.Set[string], error) {
+	failures, ok := h.failures[pj.Spec.Job]
+	if !ok {
+		return nil, errors.New("no artifacts")
+	}
+	return failures, nil
+}
+
+func TestCompareWithProduction(t *testing.T) {
+	rehearsal := func(job string, state pjapi.ProwJobState) *pjapi.ProwJob {
+		return &pjapi.ProwJob{
+			ObjectMeta: metav1.ObjectMeta{Name: job},
+			Spec:       pjapi.ProwJobSpec{Job: "rehearse-123-" + job},
+			Status:     pjapi.ProwJobStatus{State: state, URL: "https://prow/" + job},
+		}
+	}
+	history := &fakeJobHistory{
+		runs: map[string][]JobRun{
+			"passing": {{Passed: true}, {Passed: false, Failures: sets.New[string]("flaky")}},
+			"failing": {
+				{Passed: false, Failures: sets.New[string]("install")},
+				{Passed: false, Failures: sets.New[string]("upgrade")},
+				{Passed: true},
+				{Passed: false, Failures: sets.New[string]("conformance")},
+			},
+		},
+		failures: map[string]sets.Set[string]{
+			"rehearse-123-failing": sets.New[string]("install", "new"),
+		},
+	}
+	client := newTC(
+		rehearsal("passing", pjapi.SuccessState),
+		rehearsal("failing", pjapi.FailureState),
+		rehearsal("unknown", pjapi.ErrorState),
+		rehearsal("pending", pjapi.PendingState),
+	)
+	executor := NewExecutor(nil, "", &pjapi.Refs{Pulls: []pjapi.Pull{{Number: 123}}}, false, logrus.NewEntry(logrus.New()), client, "", &prowconfig.Config{}, true)
+	executor.pollFunc = threetimesTryingPoller
+	if _, err := executor.waitForJobs(context.Background(), sets.New[string]("passing", "failing", "unknown"), &ctrlruntimeclient.ListOptions{}); err != nil {
+		t.Fatalf("failed to wait for the jobs: %v", err)
+	}
+
+	comparisons := executor.CompareWithProduction(context.Background(), history, 3)
+	expected := []ProductionComparison{
+		{
+			Job: "failing", Rehearsal: "rehearse-123-failing", URL: "https://prow/failing", Runs: 3, PassedRuns: 1,
+			Failures: sets.New[string]("install", "new"), SeenInProduction: sets.New[string]("install"),
+		},
+		{Job: "passing", Rehearsal: "rehearse-123-passing", URL: "https://prow/passing", Passed: true, Runs: 2, PassedRuns: 1},
+		{Job: "unknown", Rehearsal: "rehearse-123-unknown", URL: "https://prow/unknown"},
+	}
+	if diff := cmp.Diff(expected, comparisons); diff != "" {
+		t.Errorf("unexpected comparisons (-want, +got): %s", diff)
+	}
+}
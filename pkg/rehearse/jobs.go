@@ -884,6 +884,10 @@ type Executor struct {
 	pollFunc          func(ctx context.Context, interval, timeout time.Duration, immediate bool, condition wait.ConditionWithContextFunc) error
 	prowCfg           *prowconfig.Config
 	waitForCompletion bool
+	// submitted holds the names of the rehearsals ExecuteJobs created
+	submitted sets.Set[string]
+	// finished holds the rehearsals waitForJobs saw finishing
+	finished []pjapi.ProwJob
 }
 
 // NewExecutor creates an executor. It also configures the rehearsal jobs as a list of presubmits.
@@ -937,16 +941,14 @@ func (e *Executor) ExecuteJobs() (bool, error) {
 		return true, fmt.Errorf("failed to submit all rehearsal jobs")
 	}
 
-	prNumber := e.refs.Pulls[0].Number
-	selector := ctrlruntimeclient.MatchingLabels{Label: strconv.Itoa(prNumber)}
-
 	names := sets.New[string]()
 	for _, job := range pjs {
 		names.Insert(job.Name)
 	}
+	e.submitted = names.Clone()
 	waitSuccess := true //default to true as we don't assume failure if not waiting for completion
 	if e.waitForCompletion {
-		waitSuccess, err = e.waitForJobs(names, selector)
+		waitSuccess, err = e.waitForJobs(context.Background(), names, e.rehearsalSelector())
 	}
 	if !submitSuccess {
 		return waitSuccess, fmt.Errorf("failed to submit all rehearsal jobs")
@@ -954,13 +956,27 @@ func (e *Executor) ExecuteJobs() (bool, error) {
 	return waitSuccess, err
 }
 
-func (e *Executor) waitForJobs(jobs sets.Set[string], selector ctrlruntimeclient.ListOption) (bool, error) {
+func (e *Executor) rehearsalSelector() ctrlruntimeclient.ListOption {
+	return ctrlruntimeclient.MatchingLabels{Label: strconv.Itoa(e.refs.Pulls[0].Number)}
+}
+
+// WaitForRehearsals waits for the rehearsals ExecuteJobs created that were not seen finishing yet
+func (e *Executor) WaitForRehearsals(ctx context.Context) error {
+	pending := e.submitted.Clone()
+	for _, pj := range e.finished {
+		pending.Delete(pj.Name)
+	}
+	_, err := e.waitForJobs(ctx, pending, e.rehearsalSelector())
+	return err
+}
+
+func (e *Executor) waitForJobs(ctx context.Context, jobs sets.Set[string], selector ctrlruntimeclient.ListOption) (bool, error) {
 	if len(jobs) == 0 {
 		return true, nil
 	}
 	success := true
 	var listErrors []error
-	if err := e.pollFunc(context.Background(), 10*time.Second, 4*time.Hour, false, func(ctx context.Context) (bool, error) {
+	if err := e.pollFunc(ctx, 10*time.Second, 4*time.Hour, false, func(ctx context.Context) (bool, error) {
 		result := &pjapi.ProwJobList{}
 		// Don't bail out just because one LIST failed
 		if err := e.pjclient.List(ctx, result, selector, ctrlruntimeclient.InNamespace(e.namespace)); err != nil {
@@ -990,6 +1006,7 @@ func (e *Executor) waitForJobs(jobs sets.Set[string], selector ctrlruntimeclient
 			default:
 				continue
 			}
+			e.finished = append(e.finished, pj)
 			jobs.Delete(pj.Name)
 			if jobs.Len() == 0 {
 				return true, nil
@@ -1004,6 +1021,51 @@ func (e *Executor) waitForJobs(jobs sets.Set[string], selector ctrlruntimeclient
 	return success, nil
 }
 
+// CompareWithProduction compares the rehearsals that finished while waiting for them with up to
+// runs of the most recent production runs of the rehearsed jobs
+func (e *Executor) CompareWithProduction(ctx context.Context, history JobHistory, runs int) []ProductionComparison {
+	ctx, cancel := context.WithTimeout(ctx, productionComparisonTimeout)
+	defer cancel()
+	prefix := fmt.Sprintf("rehearse-%d-", e.refs.Pulls[0].Number)
+	var comparisons []ProductionComparison
+	for i := range e.finished {
+		pj := &e.finished[i]
+		comparison := ProductionComparison{
+			Job:       strings.TrimPrefix(pj.Spec.Job, prefix),
+			Rehearsal: pj.Spec.Job,
+			URL:       pj.Status.URL,
+			Passed:    pj.Status.State == pjapi.SuccessState,
+		}
+		logger := e.logger.WithField(logRehearsalJob, pj.Spec.Job)
+
+		productionFailures := sets.New[string]()
+		recent, err := history.RecentRuns(ctx, comparison.Job, runs)
+		if err != nil {
+			logger.WithError(err).Warn("Failed to fetch the recent production runs of the rehearsed job")
+		}
+		for _, run := range recent {
+			comparison.Runs++
+			if run.Passed {
+				comparison.PassedRuns++
+			}
+			productionFailures = productionFailures.Union(run.Failures)
+		}
+
+		if !comparison.Passed {
+			failures, err := history.Failures(ctx, pj)
+			if err != nil {
+				logger.WithError(err).Warn("Failed to fetch the failures of the rehearsal")
+			} else {
+				comparison.Failures = failures
+				comparison.SeenInProduction = failures.Intersection(productionFailures)
+			}
+		}
+		comparisons = append(comparisons, comparison)
+	}
+	sort.Slice(comparisons, func(i, j int) bool { return comparisons[i].Job < comparisons[j].Job })
+	return comparisons
+}
+
 func removeConfigResolverFlags(args []string) ([]string, api.Metadata) {
 	var newArgs []string
 	var usedConfig api.Metadata
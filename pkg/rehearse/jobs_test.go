@@ -897,7 +897,7 @@ func TestWaitForJobs(t *testing.T) {
 
 			executor := NewExecutor(nil, "", &pjapi.Refs{}, true, logger, client, "", &prowconfig.Config{}, true)
 			executor.pollFunc = threetimesTryingPoller
-			success, err := executor.waitForJobs(tc.pjs, &ctrlruntimeclient.ListOptions{})
+			success, err := executor.waitForJobs(context.Background(), tc.pjs, &ctrlruntimeclient.ListOptions{})
 			if err != tc.err {
 				t.Fatalf("want `err` == %v, got %v", tc.err, err)
 			}
@@ -926,7 +926,7 @@ func TestWaitForJobsRetries(t *testing.T) {
 
 	executor := NewExecutor(nil, "", &pjapi.Refs{}, true, logrus.NewEntry(logrus.New()), client, "", &prowconfig.Config{}, true)
 	executor.pollFunc = threetimesTryingPoller
-	success, err := executor.waitForJobs(sets.Set[string]{"j": {}}, &ctrlruntimeclient.ListOptions{})
+	success, err := executor.waitForJobs(context.Background(), sets.Set[string]{"j": {}}, &ctrlruntimeclient.ListOptions{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -948,7 +948,7 @@ func TestWaitForJobsLog(t *testing.T) {
 
 	executor := NewExecutor(nil, "", &pjapi.Refs{}, true, logger.WithFields(nil), client, "", &prowconfig.Config{}, true)
 	executor.pollFunc = threetimesTryingPoller
-	_, err := executor.waitForJobs(sets.New[string]("success", "failure"), &ctrlruntimeclient.ListOptions{})
+	_, err := executor.waitForJobs(context.Background(), sets.New[string]("success", "failure"), &ctrlruntimeclient.ListOptions{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -55,6 +55,11 @@ type RehearsalConfig struct {
 	GCSCredentialsFile string
 	GCSBrowserPrefix   string
 
+	// JobHistory, when set, is where the rehearsals are compared with up to
+	// ProductionRuns of the most recent production runs of their jobs
+	JobHistory     JobHistory
+	ProductionRuns int
+
 	DryRun bool
 }
 
@@ -277,7 +282,13 @@ func labelSelectorForRehearsalJobs(org, repo string, prNumber int) ctrlruntimecl
 	}
 }
 
-// RehearseJobs returns true if the jobs were triggered and succeed
+// ProductionComparer waits for the rehearsals to finish and compares them with the recent
+// production runs of the rehearsed jobs
+type ProductionComparer func(ctx context.Context) ([]ProductionComparison, error)
+
+// RehearseJobs returns true if the jobs were triggered and succeed. When the config has a job history,
+// it also returns the comparer of the rehearsals with the production runs, it is nil otherwise.
+// The comparer waits for the rehearsals, which may take hours, so it should run in the background.
 func (r RehearsalConfig) RehearseJobs(
 	candidatePath string,
 	prRefs *prowapi.Refs,
@@ -285,7 +296,7 @@ func (r RehearsalConfig) RehearseJobs(
 	prowCfg *prowconfig.Config,
 	waitForSuccess bool,
 	logger *logrus.Entry,
-) (bool, error) {
+) (bool, ProductionComparer, error) {
 	prowJobConfig := r.getProwJobKubeConfig(logger)
 	pjclient, err := NewProwJobClient(prowJobConfig, r.DryRun)
 	if err != nil {
@@ -295,7 +306,7 @@ func (r RehearsalConfig) RehearseJobs(
 	success, err := executor.ExecuteJobs()
 	if err != nil {
 		logger.WithError(err).Error("Failed to rehearse jobs")
-		return false, err
+		return false, nil, err
 	} else if !success {
 		logger.Info("Some jobs failed their rehearsal runs")
 	} else if waitForSuccess {
@@ -304,7 +315,16 @@ func (r RehearsalConfig) RehearseJobs(
 		logger.Info("All jobs were triggered successfully")
 	}
 
-	return success, nil
+	var compare ProductionComparer
+	if r.JobHistory != nil && !r.DryRun {
+		compare = func(ctx context.Context) ([]ProductionComparison, error) {
+			if err := executor.WaitForRehearsals(ctx); err != nil {
+				return nil, err
+			}
+			return executor.CompareWithProduction(ctx, r.JobHistory, r.ProductionRuns), nil
+		}
+	}
+	return success, compare, nil
 }
 
 func (r RehearsalConfig) getProwJobKubeConfig(logger *logrus.Entry) *rest.Config {
@@ -15,6 +15,8 @@ type Error struct {
 	reason  Reason
 	message string
 	wrapped error
+	// step is the name of the step that failed, if the error is specific to one
+	step string
 }
 
 // Error makes an Error an error
@@ -39,21 +41,44 @@ func (e *Error) Is(target error) bool {
 // errors — are recursively expanded, generating a separate chain for each
 // child.
 func Reasons(errs ...error) (ret []string) {
+	for _, chain := range ReasonChains(errs...) {
+		ret = append(ret, chain.Reason)
+	}
+	return
+}
+
+// ReasonChain is a single chain of error reasons and the step it
+// failed in, if any Error in the chain names one
+type ReasonChain struct {
+	// Reason is the chain divided by colons
+	Reason string
+	// Step is the innermost step named in the chain
+	Step string
+	// Message is the message of the innermost Error in the chain
+	Message string
+}
+
+// ReasonChains provides the chains of error reasons like Reasons does,
+// together with the step each chain failed in.
+func ReasonChains(errs ...error) (ret []ReasonChain) {
 	for _, err := range errs {
 		switch err := err.(type) {
 		case *Error:
-			children := Reasons(err.Unwrap())
+			children := ReasonChains(err.Unwrap())
 			if len(children) == 0 {
-				ret = append(ret, string(err.reason))
+				ret = append(ret, ReasonChain{Reason: string(err.reason), Step: err.step, Message: err.message})
 				break
 			}
-			for _, r := range children {
-				ret = append(ret, fmt.Sprintf("%s:%s", err.reason, r))
+			for _, child := range children {
+				if child.Step == "" {
+					child.Step = err.step
+				}
+				ret = append(ret, ReasonChain{Reason: fmt.Sprintf("%s:%s", err.reason, child.Reason), Step: child.Step, Message: child.Message})
 			}
 		case interface{ Errors() []error }:
-			ret = append(ret, Reasons(err.Errors()...)...)
+			ret = append(ret, ReasonChains(err.Errors()...)...)
 		case interface{ Unwrap() error }:
-			ret = append(ret, Reasons(err.Unwrap())...)
+			ret = append(ret, ReasonChains(err.Unwrap())...)
 		}
 	}
 	return
@@ -79,6 +104,12 @@ func ForReason(reason Reason) *BuilderWithReason {
 	}
 }
 
+// ForStep is a builder that records the step the Error happened in.
+func (e *BuilderWithReason) ForStep(step string) *BuilderWithReason {
+	e.step = step
+	return e
+}
+
 // BuilderWithReasonAndError adds a child error to the builder
 type BuilderWithReasonAndError struct {
 	Error
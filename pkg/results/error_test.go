@@ -170,3 +170,20 @@ func TestReasons(t *testing.T) {
 		})
 	}
 }
+
+func TestReasonChains(t *testing.T) {
+	stepFailure := func(step string, err error) error {
+		return ForReason("step_failed").ForStep(step).WithError(err).Errorf("step %s failed: %v", step, err)
+	}
+	err := ForReason("executing_graph").WithError(utilerrors.NewAggregate([]error{
+		stepFailure("e2e-aws", ForReason("pod_failed").ForError(errors.New("oops"))),
+		stepFailure("images", errors.New("build failed")),
+		ForReason("interrupted").ForError(errors.New("execution cancelled")),
+	})).Errorf("could not run steps")
+	expected := []ReasonChain{
+		{Reason: "executing_graph:step_failed:pod_failed", Step: "e2e-aws", Message: "oops"},
+		{Reason: "executing_graph:step_failed", Step: "images", Message: "step images failed: build failed"},
+		{Reason: "executing_graph:interrupted", Message: "execution cancelled"},
+	}
+	testhelper.Diff(t, "reason chains", ReasonChains(err), expected)
+}
@@ -94,6 +94,16 @@ type Request struct {
 	State string `json:"state"`
 	// Reason is a colon-delimited list of reasons for failure
 	Reason string `json:"reason"`
+	// Org, Repo and Branch identify the ci-operator configuration of the job
+	Org    string `json:"org,omitempty"`
+	Repo   string `json:"repo,omitempty"`
+	Branch string `json:"branch,omitempty"`
+	// Step is the name of the step that failed, if the failure is specific to one
+	Step string `json:"step,omitempty"`
+	// Signature identifies the kind of failure, see Signature
+	Signature string `json:"signature,omitempty"`
+	// SignatureMessage is the normalized message the signature is computed from
+	SignatureMessage string `json:"signature_message,omitempty"`
 }
 
 // PodScalerRequest holds the data from pod-scaler used to report a result to an aggregation server
@@ -137,17 +147,28 @@ func (r *reporter) Report(err error) {
 	if err != nil {
 		state = StateFailed
 	}
-	reasons := Reasons(err)
-	if len(reasons) == 0 {
-		reasons = []string{string(ReasonUnknown)}
+	chains := ReasonChains(err)
+	if len(chains) == 0 {
+		chains = []ReasonChain{{Reason: string(ReasonUnknown)}}
 	}
-	for _, reason := range reasons {
+	for _, chain := range chains {
+		var signature, signatureMessage string
+		if state == StateFailed && chain.Message != "" {
+			signature, signatureMessage = Signature(chain.Message), NormalizeMessage(chain.Message)
+		}
 		r.report(Request{
 			JobName: r.spec.Job,
 			Type:    string(r.spec.Type),
 			Cluster: r.consoleHost,
 			State:   state,
-			Reason:  reason,
+			Reason:  chain.Reason,
+			Org:     r.spec.Metadata.Org,
+			Repo:    r.spec.Metadata.Repo,
+			Branch:  r.spec.Metadata.Branch,
+			Step:    chain.Step,
+
+			Signature:        signature,
+			SignatureMessage: signatureMessage,
 		})
 	}
 }
@@ -49,14 +49,21 @@ func TestReporter_Report(t *testing.T) {
 			spec:        &api.JobSpec{JobSpec: downwardapi.JobSpec{Job: "runme", Type: v1.PresubmitJob}},
 			consoleHost: "foo.com",
 			err:         ForReason("because").ForError(errors.New("oops")),
-			expected:    `{"job_name":"runme","type":"presubmit","cluster":"foo.com","state":"failed","reason":"because"}`,
+			expected:    `{"job_name":"runme","type":"presubmit","cluster":"foo.com","state":"failed","reason":"because","signature":"` + Signature("oops") + `","signature_message":"oops"}`,
 		},
 		{
 			name:        "nested reasoned err reports failure with specific reason",
 			spec:        &api.JobSpec{JobSpec: downwardapi.JobSpec{Job: "runme", Type: v1.PresubmitJob}},
 			consoleHost: "foo.com",
 			err:         ForReason("because").WithError(ForReason("something").ForError(errors.New("oops"))).Errorf("argh"),
-			expected:    `{"job_name":"runme","type":"presubmit","cluster":"foo.com","state":"failed","reason":"because:something"}`,
+			expected:    `{"job_name":"runme","type":"presubmit","cluster":"foo.com","state":"failed","reason":"because:something","signature":"` + Signature("oops") + `","signature_message":"oops"}`,
+		},
+		{
+			name:        "step failure reports the configuration and the step",
+			spec:        &api.JobSpec{JobSpec: downwardapi.JobSpec{Job: "runme", Type: v1.PresubmitJob}, Metadata: api.Metadata{Org: "org", Repo: "repo", Branch: "main"}},
+			consoleHost: "foo.com",
+			err:         ForReason("step_failed").ForStep("e2e").WithError(errors.New("oops")).Errorf("step e2e failed: oops"),
+			expected:    `{"job_name":"runme","type":"presubmit","cluster":"foo.com","state":"failed","reason":"step_failed","org":"org","repo":"repo","branch":"main","step":"e2e","signature":"` + Signature("step e2e failed: oops") + `","signature_message":"step e2e failed: oops"}`,
 		},
 	}
 
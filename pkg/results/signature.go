@@ -0,0 +1,116 @@
+package results
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"strings"
+)
+
+const (
+	// SignatureProperty is the name of the JUnit test case property that holds the signature of the failure
+	SignatureProperty = "failure-signature"
+
+	// maxSignatureTokens bounds the part of a message that is normalized, the start of a message is what
+	// tells the kinds of failures apart and the rest is often a log
+	maxSignatureTokens = 64
+)
+
+var (
+	// messageMasks are applied to the whole message since the values they mask can contain spaces
+	messageMasks = []mask{
+		{regex: regexp.MustCompile(`\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:?\d{2})?`), replacement: "<time>"},
+		{regex: regexp.MustCompile(`(?i)\b(mon|tue|wed|thu|fri|sat|sun), \d{2} [a-z]{3} \d{4} \d{2}:\d{2}:\d{2} [a-z]+`), replacement: "<time>"},
+	}
+	// tokenMasks are applied in order to every token with the surrounding punctuation removed, the first one
+	// that matches wins
+	tokenMasks = []mask{
+		{regex: regexp.MustCompile(`^ci-(op|ln)-[a-z0-9]{5,}$`), replacement: "<namespace>"},
+		{regex: regexp.MustCompile(`^(?i)[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`), replacement: "<uuid>"},
+		{regex: regexp.MustCompile(`^sha256:[0-9a-f]{64}$`), replacement: "sha256:<digest>"},
+		{regex: regexp.MustCompile(`^\d{1,3}(\.\d{1,3}){3}(:\d+)?$`), replacement: "<ip>"},
+		{regex: regexp.MustCompile(`^((\d+(\.\d+)?)(ns|us|µs|ms|s|m|h))+$`), replacement: "<duration>"},
+		{regex: regexp.MustCompile(`^(0x)?[0-9a-f]*[0-9][0-9a-f]*[a-f][0-9a-f]*$|^(0x)?[0-9a-f]*[a-f][0-9a-f]*[0-9][0-9a-f]*$`), replacement: "<hex>", minLength: 8},
+		// pods of deployments and replica sets, jobs and daemon sets have a random suffix from the alphabet
+		// Kubernetes generates names with
+		{regex: regexp.MustCompile(`^([a-z0-9-]+)-[bcdfghjklmnpqrstvwxz2456789]{8,10}-[bcdfghjklmnpqrstvwxz2456789]{5}$`), replacement: "$1-<pod>"},
+		{regex: regexp.MustCompile(`^([a-z0-9-]+)-[bcdfghjklmnpqrstvwxz]*[2456789][bcdfghjklmnpqrstvwxz2456789]*$`), replacement: "$1-<pod>", suffixLength: 5},
+	}
+	// numberRegex only matches standalone numbers, so that names like e2e, ipv6 or gp3 are kept
+	numberRegex     = regexp.MustCompile(`\b\d+\b`)
+	punctuationTrim = "\"'`()[]{}<>,;:."
+)
+
+// mask replaces the variable part of a message
+type mask struct {
+	regex       *regexp.Regexp
+	replacement string
+	// minLength is the length a token needs for the mask to apply
+	minLength int
+	// suffixLength is the exact length of the part after the last dash for the mask to apply
+	suffixLength int
+}
+
+func (m mask) apply(token string) (string, bool) {
+	if len(token) < m.minLength {
+		return token, false
+	}
+	if m.suffixLength > 0 {
+		if i := strings.LastIndex(token, "-"); i < 0 || len(token)-i-1 != m.suffixLength {
+			return token, false
+		}
+	}
+	if !m.regex.MatchString(token) {
+		return token, false
+	}
+	return m.regex.ReplaceAllString(token, m.replacement), true
+}
+
+// NormalizeMessage masks the parts of the first line of an error message that differ between occurrences of the
+// same failure, like namespaces, pod names, addresses, times and counts, so that the same failure in different
+// jobs has the same normalized message.
+func NormalizeMessage(message string) string {
+	line := ""
+	for _, candidate := range strings.Split(message, "\n") {
+		if candidate = strings.TrimSpace(candidate); candidate != "" {
+			line = candidate
+			break
+		}
+	}
+	for _, m := range messageMasks {
+		line = m.regex.ReplaceAllString(line, m.replacement)
+	}
+
+	tokens := strings.Fields(line)
+	if len(tokens) > maxSignatureTokens {
+		tokens = tokens[:maxSignatureTokens]
+	}
+	for i, token := range tokens {
+		core := strings.TrimRight(strings.TrimLeft(token, punctuationTrim), punctuationTrim)
+		if core == "" || strings.HasPrefix(core, "<") && strings.HasSuffix(core, ">") {
+			continue
+		}
+		// a namespaced name is masked part by part
+		parts := strings.Split(core, "/")
+		for j, part := range parts {
+			masked := false
+			for _, m := range tokenMasks {
+				if parts[j], masked = m.apply(part); masked {
+					break
+				}
+			}
+			if !masked {
+				parts[j] = numberRegex.ReplaceAllString(part, "<n>")
+			}
+		}
+		tokens[i] = strings.Replace(token, core, strings.Join(parts, "/"), 1)
+	}
+	return strings.Join(tokens, " ")
+}
+
+// Signature is a stable hash of the normalized error message, failures with the same signature are the same kind
+// of failure.
+func Signature(message string) string {
+	sum := sha256.Sum256([]byte(NormalizeMessage(message)))
+	return hex.EncodeToString(sum[:])[:16]
+}
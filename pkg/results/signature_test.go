@@ -0,0 +1,58 @@
+package results
+
+import (
+	"testing"
+)
+
+func TestNormalizeMessage(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		message  string
+		expected string
+	}{{
+		name:     "pod failure",
+		message:  `"e2e" pod "e2e-test" failed: the pod ci-op-x2v9kq7h/e2e-test failed after 31m20s (failed containers: test): ContainerFailed one or more containers exited` + "\n\nContainer test exited with code 1",
+		expected: `"e2e" pod "e2e-test" failed: the pod <namespace>/e2e-test failed after <duration> (failed containers: test): ContainerFailed one or more containers exited`,
+	}, {
+		name:     "generated pod names",
+		message:  "pod openshift-apiserver/apiserver-7d9f6b8c5d-x2k4p is not ready, node-exporter-8m2zq restarted",
+		expected: "pod openshift-apiserver/apiserver-<pod> is not ready, node-exporter-<pod> restarted",
+	}, {
+		name:     "names that look random are kept",
+		message:  "could not build image release-images",
+		expected: "could not build image release-images",
+	}, {
+		name:     "addresses, ids and times",
+		message:  "dial tcp 10.0.12.4:6443: i/o timeout at 2024-05-01T10:00:01Z for lease 0c0b7d4e-8f1a-4b57-9a55-2b1c3a0e7f10 image sha256:" + "4f53cda18c2baa0c0354bb5f9a3ecbe5ed12ab4d8e11ba873c2f11161202b945",
+		expected: "dial tcp <ip>: i/o timeout at <time> for lease <uuid> image sha256:<digest>",
+	}, {
+		name:     "numbers inside names are kept",
+		message:  "ipv6 cluster on gp3 volumes failed to reach s3 after retry-3 of 5",
+		expected: "ipv6 cluster on gp3 volumes failed to reach s3 after retry-<n> of <n>",
+	}, {
+		name:     "hex and counts",
+		message:  "commit 3f2a9c1be7 has 12 failures",
+		expected: "commit <hex> has <n> failures",
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			if actual := NormalizeMessage(tc.message); actual != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestSignature(t *testing.T) {
+	first := Signature(`the pod ci-op-x2v9kq7h/e2e-test failed after 31m20s`)
+	second := Signature(`the pod ci-op-4bq8zt2m/e2e-test failed after 1h2m3s`)
+	other := Signature(`the pod ci-op-x2v9kq7h/e2e-test was deleted`)
+	if first != second {
+		t.Errorf("expected the same failure in different namespaces to have the same signature, got %s and %s", first, second)
+	}
+	if first == other {
+		t.Errorf("expected different failures to have different signatures, both got %s", first)
+	}
+	if len(first) != 16 {
+		t.Errorf("expected a 16 character signature, got %q", first)
+	}
+}
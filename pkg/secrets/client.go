@@ -12,11 +12,15 @@ type ReadOnlyClient interface {
 	GetInUseInformationForAllItems(optionalPrefix string) (map[string]SecretUsageComparer, error)
 	GetUserSecrets() (map[types.NamespacedName]map[string]string, error)
 	HasItem(itemname string) (bool, error)
+	// GetItemVersion returns the current version of the item, or zero if the backend does not track it
+	GetItemVersion(itemName string) (int, error)
 }
 
 type Client interface {
 	ReadOnlyClient
 	SetFieldOnItem(itemName, fieldName string, fieldValue []byte) error
+	// DeleteFieldOnItem removes the field from the item, it is not an error if it does not exist
+	DeleteFieldOnItem(itemName, fieldName string) error
 	UpdateNotesOnItem(itemName string, notes string) error
 	UpdateIndexSecret(itemName string, payload []byte) error
 }
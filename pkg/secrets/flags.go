@@ -8,13 +8,34 @@ import (
 	"github.com/openshift/ci-tools/pkg/vaultclient"
 )
 
+// Backend is a store secrets are read from and written to
+type Backend string
+
+const (
+	// BackendVault stores secrets in Vault
+	BackendVault Backend = "vault"
+	// BackendSealedFile stores secrets in a local file with every value encrypted,
+	// which allows to run the tools without Vault
+	BackendSealedFile Backend = "sealed-file"
+)
+
+// defaultSealedFilePrefix is the prefix items are stored under in sealed files when --vault-prefix is unset
+const defaultSealedFilePrefix = "secret"
+
 type CLIOptions struct {
+	Backend Backend
+
 	VaultTokenFile string
 	VaultAddr      string
 	VaultPrefix    string
 	VaultRole      string
 
 	VaultToken string
+
+	SealedFilePath    string
+	SealedFileKeyFile string
+
+	SealedFileKey []byte
 }
 
 func (o *CLIOptions) Bind(fs *flag.FlagSet, getenv func(string) string, censor *DynamicCensor) {
@@ -22,6 +43,9 @@ func (o *CLIOptions) Bind(fs *flag.FlagSet, getenv func(string) string, censor *
 	fs.StringVar(&o.VaultTokenFile, "vault-token-file", "", "Token file to use when interacting with Vault, defaults to the VAULT_TOKEN env var if unset. Mutually exclusive with --bw-user and --bw-password-path.")
 	fs.StringVar(&o.VaultPrefix, "vault-prefix", "", "Prefix under which to operate in Vault. Mandatory when using vault.")
 	fs.StringVar(&o.VaultRole, "vault-role", "", "The vault role to use for Kubernetes auth. When passed and no token is passed, login via Kubernetes auth will be attempted.")
+	fs.Var(&backendFlag{backend: &o.Backend}, "secrets-backend", fmt.Sprintf("Backend to store secrets in, one of %q (default) or %q.", BackendVault, BackendSealedFile))
+	fs.StringVar(&o.SealedFilePath, "sealed-file", "", "Path to the sealed file used with --secrets-backend=sealed-file. It is created when it does not exist.")
+	fs.StringVar(&o.SealedFileKeyFile, "sealed-file-key-file", "", "Path to the file holding the base64-encoded 32 byte key the sealed file is encrypted with.")
 	o.VaultAddr = getenv("VAULT_ADDR")
 	if v := getenv("VAULT_TOKEN"); v != "" {
 		censor.AddSecrets(v)
@@ -29,7 +53,35 @@ func (o *CLIOptions) Bind(fs *flag.FlagSet, getenv func(string) string, censor *
 	}
 }
 
+// backendFlag only accepts known backends
+type backendFlag struct {
+	backend *Backend
+}
+
+func (f *backendFlag) String() string {
+	if f.backend == nil {
+		return ""
+	}
+	return string(*f.backend)
+}
+
+func (f *backendFlag) Set(value string) error {
+	switch backend := Backend(value); backend {
+	case BackendVault, BackendSealedFile:
+		*f.backend = backend
+		return nil
+	default:
+		return fmt.Errorf("unknown backend %q", value)
+	}
+}
+
 func (o *CLIOptions) Validate() error {
+	if o.Backend == BackendSealedFile {
+		if o.SealedFilePath == "" || o.SealedFileKeyFile == "" {
+			return errors.New("--sealed-file and --sealed-file-key-file are required with --secrets-backend=sealed-file")
+		}
+		return nil
+	}
 	if o.VaultAddr == "" || (o.VaultToken == "" && o.VaultTokenFile == "" && o.VaultRole == "") || o.VaultPrefix == "" {
 		return errors.New("--vault-addr, one of --vault-token, the VAULT_TOKEN env var or --vault-role and --vault-prefix must be specified together")
 	}
@@ -37,6 +89,15 @@ func (o *CLIOptions) Validate() error {
 }
 
 func (o *CLIOptions) Complete(censor *DynamicCensor) error {
+	if o.Backend == BackendSealedFile {
+		if o.SealedFileKeyFile != "" {
+			var err error
+			if o.SealedFileKey, err = ReadSealedFileKey(o.SealedFileKeyFile, censor); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
 	if o.VaultTokenFile != "" {
 		var err error
 		if o.VaultToken, err = ReadFromFile(o.VaultTokenFile, censor); err != nil {
@@ -51,6 +112,17 @@ func (o *CLIOptions) NewReadOnlyClient(censor *DynamicCensor) (ReadOnlyClient, e
 }
 
 func (o *CLIOptions) NewClient(censor *DynamicCensor) (Client, error) {
+	if o.Backend == BackendSealedFile {
+		prefix := o.VaultPrefix
+		if prefix == "" {
+			prefix = defaultSealedFilePrefix
+		}
+		client, err := NewSealedFileClient(o.SealedFilePath, o.SealedFileKey, prefix, censor)
+		if err != nil {
+			return nil, fmt.Errorf("failed to construct sealed file client: %w", err)
+		}
+		return client, nil
+	}
 	var c *vaultclient.VaultClient
 	var err error
 	if o.VaultRole != "" {
@@ -1,6 +1,8 @@
 package secrets
 
 import (
+	"bytes"
+	"encoding/base64"
 	"flag"
 	"fmt"
 	"os"
@@ -29,6 +31,11 @@ func TestParseOptions(t *testing.T) {
 			env:      map[string]string{"VAULT_TOKEN": "vault token"},
 			expected: CLIOptions{VaultToken: "vault token"},
 		},
+		{
+			name:     "sealed file backend",
+			given:    []string{"--secrets-backend=sealed-file", "--sealed-file=secrets.yaml", "--sealed-file-key-file=key"},
+			expected: CLIOptions{Backend: BackendSealedFile, SealedFilePath: "secrets.yaml", SealedFileKeyFile: "key"},
+		},
 	}
 	censor := NewDynamicCensor()
 	for _, tc := range testCases {
@@ -100,6 +107,22 @@ func TestValidateOptions(t *testing.T) {
 			},
 			expected: fmt.Errorf("--vault-addr, one of --vault-token, the VAULT_TOKEN env var or --vault-role and --vault-prefix must be specified together"),
 		},
+		{
+			name: "sealed file does not need vault",
+			given: CLIOptions{
+				Backend:           BackendSealedFile,
+				SealedFilePath:    "secrets.yaml",
+				SealedFileKeyFile: "key",
+			},
+		},
+		{
+			name: "sealed file without key",
+			given: CLIOptions{
+				Backend:        BackendSealedFile,
+				SealedFilePath: "secrets.yaml",
+			},
+			expected: fmt.Errorf("--sealed-file and --sealed-file-key-file are required with --secrets-backend=sealed-file"),
+		},
 	}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
@@ -126,11 +149,16 @@ func TestCompleteOptions(t *testing.T) {
 	if err := os.WriteFile(vaultPasswordPath, []byte("topSecret"), 0755); err != nil {
 		t.Errorf("Failed to remove temp dir")
 	}
+	sealedFileKeyPath := filepath.Join(dir, "sealedFileKey")
+	if err := os.WriteFile(sealedFileKeyPath, []byte(base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, SealedFileKeySize))+"\n"), 0600); err != nil {
+		t.Errorf("Failed to write key")
+	}
 	testCases := []struct {
 		name          string
 		given         CLIOptions
 		expectedError error
 		expectedToken string
+		expectedKey   []byte
 	}{
 		{
 			name: "basic case",
@@ -139,6 +167,14 @@ func TestCompleteOptions(t *testing.T) {
 			},
 			expectedToken: "topSecret",
 		},
+		{
+			name: "sealed file key",
+			given: CLIOptions{
+				Backend:           BackendSealedFile,
+				SealedFileKeyFile: sealedFileKeyPath,
+			},
+			expectedKey: bytes.Repeat([]byte{1}, SealedFileKeySize),
+		},
 	}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
@@ -150,6 +186,9 @@ func TestCompleteOptions(t *testing.T) {
 			if diff := cmp.Diff(tc.given.VaultToken, tc.expectedToken, testhelper.EquateErrorMessage); diff != "" {
 				t.Errorf("unexpected password: %s", diff)
 			}
+			if diff := cmp.Diff(tc.given.SealedFileKey, tc.expectedKey); diff != "" {
+				t.Errorf("unexpected key: %s", diff)
+			}
 		})
 	}
 }
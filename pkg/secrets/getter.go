@@ -0,0 +1,23 @@
+package secrets
+
+import (
+	"fmt"
+
+	coreclientset "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/rest"
+)
+
+// Getter gives access to the secrets and namespaces on a cluster
+type Getter interface {
+	coreclientset.SecretsGetter
+	coreclientset.NamespacesGetter
+}
+
+// NewGetter creates a Getter for the cluster from its context in the kubeconfigs.
+func NewGetter(kubeConfigs map[string]rest.Config, cluster string) (Getter, error) {
+	kc, ok := kubeConfigs[cluster]
+	if !ok {
+		return nil, fmt.Errorf("failed to find cluster context %q in the kubeconfig", cluster)
+	}
+	return coreclientset.NewForConfig(&kc)
+}
@@ -5,8 +5,11 @@ import (
 	"fmt"
 
 	secretmanager "cloud.google.com/go/secretmanager/apiv1"
+	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
 	"github.com/sirupsen/logrus"
 	"google.golang.org/api/option"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	gsm "github.com/openshift/ci-tools/pkg/gsm-secrets"
 	gsmvalidation "github.com/openshift/ci-tools/pkg/gsm-validation"
@@ -75,6 +78,28 @@ func (g *gsmSyncDecorator) SetFieldOnItem(itemName, fieldName string, fieldValue
 	return nil
 }
 
+// DeleteFieldOnItem removes a secret field from both Vault and GSM.
+func (g *gsmSyncDecorator) DeleteFieldOnItem(itemName, fieldName string) error {
+	if err := g.Client.DeleteFieldOnItem(itemName, fieldName); err != nil {
+		return err
+	}
+
+	group := gsmvalidation.NormalizeName(itemName)
+	field := gsmvalidation.NormalizeName(fieldName)
+	secretName := gsm.GetGSMSecretName(TestPlatformCollection, group, field)
+	err := g.gsmClient.DeleteSecret(g.ctx, &secretmanagerpb.DeleteSecretRequest{
+		Name: gsm.GetGSMSecretResourceName(g.config.ProjectIdNumber, TestPlatformCollection, group, field),
+	})
+	if err != nil && status.Code(err) != codes.NotFound {
+		logrus.WithError(err).Errorf("Failed to delete from GSM: %s", secretName)
+		// Don't fail the Vault deletion
+	} else {
+		logrus.Debugf("Successfully deleted secret '%s' from GSM", secretName)
+	}
+
+	return nil
+}
+
 func (g *gsmSyncDecorator) UpdateIndexSecret(itemName string, payload []byte) error {
 	annotations := make(map[string]string)
 	annotations["request-information"] = "Created by periodic-ci-secret-generator."
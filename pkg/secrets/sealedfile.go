@@ -0,0 +1,217 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	vaultapi "github.com/hashicorp/vault/api"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/openshift/ci-tools/pkg/vaultclient"
+)
+
+// SealedFileKeySize is the size of the keys sealed files are encrypted with
+const SealedFileKeySize = 32
+
+// sealedValuePattern matches values sealed with AES-256-GCM, in the same notation SOPS uses
+var sealedValuePattern = regexp.MustCompile(`^ENC\[AES256_GCM,data:([A-Za-z0-9+/=]*),iv:([A-Za-z0-9+/=]+)\]$`)
+
+// sealedFile is the content of a sealed file. Item paths and field names are kept in
+// clear text so that changes to the file can be reviewed, values are sealed.
+type sealedFile struct {
+	Items map[string]sealedItem `json:"items,omitempty"`
+}
+
+type sealedItem struct {
+	Version     int               `json:"version"`
+	CreatedTime time.Time         `json:"created_time"`
+	Data        map[string]string `json:"data,omitempty"`
+}
+
+// sealedFileStore is a VaultClient storing the items in a sealed file on disk
+type sealedFileStore struct {
+	path string
+	aead cipher.AEAD
+	now  func() time.Time
+	lock sync.Mutex
+}
+
+// NewSealedFileClient returns a client for the items in the sealed file at the path. The file
+// is created on the first write if it does not exist. Items are addressed like in Vault, under
+// the prefix.
+func NewSealedFileClient(path string, key []byte, prefix string, censor *DynamicCensor) (Client, error) {
+	store, err := newSealedFileStore(path, key)
+	if err != nil {
+		return nil, err
+	}
+	return NewVaultClient(store, prefix, censor), nil
+}
+
+func newSealedFileStore(path string, key []byte) (*sealedFileStore, error) {
+	if len(key) != SealedFileKeySize {
+		return nil, fmt.Errorf("the key must be %d bytes long, got %d", SealedFileKeySize, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to construct cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to construct cipher: %w", err)
+	}
+	return &sealedFileStore{path: path, aead: aead, now: time.Now}, nil
+}
+
+// ReadSealedFileKey reads a base64-encoded key from the file
+func ReadSealedFileKey(path string, censor *DynamicCensor) ([]byte, error) {
+	raw, err := ReadFromFile(path, censor)
+	if err != nil {
+		return nil, err
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(raw))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode the key in %s: %w", path, err)
+	}
+	return key, nil
+}
+
+// seal encrypts the value; the path and field are authenticated so that sealed values can not
+// be moved to other fields
+func (s *sealedFileStore) seal(path, field, value string) (string, error) {
+	iv := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(iv); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	data := s.aead.Seal(nil, iv, []byte(value), []byte(path+":"+field))
+	return fmt.Sprintf("ENC[AES256_GCM,data:%s,iv:%s]", base64.StdEncoding.EncodeToString(data), base64.StdEncoding.EncodeToString(iv)), nil
+}
+
+func (s *sealedFileStore) unseal(path, field, sealed string) (string, error) {
+	match := sealedValuePattern.FindStringSubmatch(sealed)
+	if match == nil {
+		return "", fmt.Errorf("field %s of item %s is not sealed", field, path)
+	}
+	data, err := base64.StdEncoding.DecodeString(match[1])
+	if err != nil {
+		return "", fmt.Errorf("failed to decode field %s of item %s: %w", field, path, err)
+	}
+	iv, err := base64.StdEncoding.DecodeString(match[2])
+	if err != nil || len(iv) != s.aead.NonceSize() {
+		return "", fmt.Errorf("field %s of item %s has an invalid nonce", field, path)
+	}
+	value, err := s.aead.Open(nil, iv, data, []byte(path+":"+field))
+	if err != nil {
+		return "", fmt.Errorf("failed to unseal field %s of item %s: %w", field, path, err)
+	}
+	return string(value), nil
+}
+
+func (s *sealedFileStore) load() (sealedFile, error) {
+	var file sealedFile
+	raw, err := os.ReadFile(s.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return file, nil
+		}
+		return file, fmt.Errorf("failed to read sealed file: %w", err)
+	}
+	if err := yaml.Unmarshal(raw, &file); err != nil {
+		return file, fmt.Errorf("failed to parse sealed file %s: %w", s.path, err)
+	}
+	return file, nil
+}
+
+func (s *sealedFileStore) save(file sealedFile) error {
+	raw, err := yaml.Marshal(file)
+	if err != nil {
+		return fmt.Errorf("failed to serialize sealed file: %w", err)
+	}
+	// write to a temporary file first so that a failed write does not lose all items
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(raw); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write sealed file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write sealed file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to write sealed file: %w", err)
+	}
+	return nil
+}
+
+func (s *sealedFileStore) GetKV(path string) (*vaultclient.KVData, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	file, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	item, ok := file.Items[path]
+	if !ok {
+		return nil, &vaultapi.ResponseError{HTTPMethod: http.MethodGet, URL: path, StatusCode: http.StatusNotFound}
+	}
+	data := make(map[string]string, len(item.Data))
+	for field, sealed := range item.Data {
+		if data[field], err = s.unseal(path, field, sealed); err != nil {
+			return nil, err
+		}
+	}
+	return &vaultclient.KVData{Data: data, Metadata: vaultclient.KVMetadata{CreatedTime: item.CreatedTime, Version: item.Version}}, nil
+}
+
+func (s *sealedFileStore) ListKVRecursively(path string) ([]string, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	file, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	prefix := strings.TrimSuffix(path, "/") + "/"
+	var result []string
+	for itemPath := range file.Items {
+		if path == "" || strings.HasPrefix(itemPath, prefix) {
+			result = append(result, itemPath)
+		}
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+func (s *sealedFileStore) UpsertKV(path string, data map[string]string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	file, err := s.load()
+	if err != nil {
+		return err
+	}
+	if file.Items == nil {
+		file.Items = map[string]sealedItem{}
+	}
+	item := sealedItem{Version: file.Items[path].Version + 1, CreatedTime: s.now().UTC(), Data: make(map[string]string, len(data))}
+	for field, value := range data {
+		if item.Data[field], err = s.seal(path, field, value); err != nil {
+			return err
+		}
+	}
+	file.Items[path] = item
+	return s.save(file)
+}
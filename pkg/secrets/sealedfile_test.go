@@ -0,0 +1,140 @@
+package secrets
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/openshift/ci-tools/pkg/api/vault"
+	"github.com/openshift/ci-tools/pkg/testhelper"
+)
+
+func TestSealedFileClient(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.yaml")
+	key := bytes.Repeat([]byte{1}, SealedFileKeySize)
+	censor := NewDynamicCensor()
+	client, err := NewSealedFileClient(path, key, "secret", &censor)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	if _, err := client.GetFieldOnItem("item", "field"); !IsNotFound(err) {
+		t.Errorf("expected a not found error for a missing item, got %v", err)
+	}
+	for _, field := range []struct{ item, name, value string }{
+		{item: "dptp/item", name: "token", value: "s3cr3t"},
+		{item: "dptp/item", name: "other", value: "0th3r"},
+		{item: "user/secret", name: "password", value: "hunter2"},
+		{item: "user/secret", name: vault.SecretSyncTargetNamepaceKey, value: "ns1,ns2"},
+		{item: "user/secret", name: vault.SecretSyncTargetNameKey, value: "synced"},
+	} {
+		if err := client.SetFieldOnItem(field.item, field.name, []byte(field.value)); err != nil {
+			t.Fatalf("failed to set field %s on item %s: %v", field.name, field.item, err)
+		}
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read sealed file: %v", err)
+	}
+	for _, value := range []string{"s3cr3t", "0th3r", "hunter2"} {
+		if strings.Contains(string(raw), value) {
+			t.Errorf("sealed file contains value %q in clear text", value)
+		}
+	}
+	if !strings.Contains(string(raw), "secret/dptp/item:") {
+		t.Errorf("sealed file does not contain the item path in clear text:\n%s", raw)
+	}
+
+	value, err := client.GetFieldOnItem("dptp/item", "token")
+	if err != nil {
+		t.Fatalf("failed to get field: %v", err)
+	}
+	if diff := cmp.Diff("s3cr3t", string(value)); diff != "" {
+		t.Errorf("unexpected value: %s", diff)
+	}
+	if _, err := client.GetFieldOnItem("dptp/item", "missing"); !IsNotFound(err) {
+		t.Errorf("expected a not found error for a missing field, got %v", err)
+	}
+	if has, err := client.HasItem("dptp/item"); err != nil || !has {
+		t.Errorf("expected item to exist, got %t, %v", has, err)
+	}
+	if version, err := client.GetItemVersion("dptp/item"); err != nil || version != 2 {
+		t.Errorf("expected version 2 after two writes, got %d, %v", version, err)
+	}
+
+	inUse, err := client.GetInUseInformationForAllItems("dptp")
+	if err != nil {
+		t.Fatalf("failed to get in-use information: %v", err)
+	}
+	if diff := cmp.Diff([]string{"dptp/item"}, sets.List(sets.KeySet(inUse))); diff != "" {
+		t.Errorf("unexpected items: %s", diff)
+	}
+	if diff := cmp.Diff(sets.New[string]("missing"), inUse["dptp/item"].UnusedFields(sets.New[string]("token", "missing"))); diff != "" {
+		t.Errorf("unexpected unused fields: %s", diff)
+	}
+	if diff := cmp.Diff(sets.New[string]("other"), inUse["dptp/item"].SuperfluousFields()); diff != "" {
+		t.Errorf("unexpected superfluous fields: %s", diff)
+	}
+
+	userSecrets, err := client.GetUserSecrets()
+	if err != nil {
+		t.Fatalf("failed to get user secrets: %v", err)
+	}
+	expectedUserSecret := map[string]string{"password": "hunter2", vault.VaultSourceKey: "secret/user/secret"}
+	expected := map[types.NamespacedName]map[string]string{
+		{Namespace: "ns1", Name: "synced"}: expectedUserSecret,
+		{Namespace: "ns2", Name: "synced"}: expectedUserSecret,
+	}
+	if diff := cmp.Diff(expected, userSecrets); diff != "" {
+		t.Errorf("unexpected user secrets: %s", diff)
+	}
+
+	otherKey, err := NewSealedFileClient(path, bytes.Repeat([]byte{2}, SealedFileKeySize), "secret", &censor)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	if _, err := otherKey.GetFieldOnItem("dptp/item", "token"); err == nil {
+		t.Error("expected an error when unsealing with another key")
+	}
+}
+
+func TestSealedFileStoreRejectsMovedValues(t *testing.T) {
+	store, err := newSealedFileStore(filepath.Join(t.TempDir(), "secrets.yaml"), bytes.Repeat([]byte{1}, SealedFileKeySize))
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	store.now = func() time.Time { return time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC) }
+	if err := store.UpsertKV("secret/a", map[string]string{"token": "a"}); err != nil {
+		t.Fatalf("failed to write item: %v", err)
+	}
+	file, err := store.load()
+	if err != nil {
+		t.Fatalf("failed to load file: %v", err)
+	}
+	file.Items["secret/b"] = file.Items["secret/a"]
+	if err := store.save(file); err != nil {
+		t.Fatalf("failed to save file: %v", err)
+	}
+	_, err = store.GetKV("secret/b")
+	if diff := cmp.Diff(errors.New("failed to unseal field token of item secret/b: cipher: message authentication failed"), err, testhelper.EquateErrorMessage); diff != "" {
+		t.Errorf("unexpected error: %s", diff)
+	}
+}
+
+func TestNewSealedFileClientInvalidKey(t *testing.T) {
+	censor := NewDynamicCensor()
+	_, err := NewSealedFileClient("secrets.yaml", []byte("short"), "secret", &censor)
+	if diff := cmp.Diff(errors.New("the key must be 32 bytes long, got 5"), err, testhelper.EquateErrorMessage); diff != "" {
+		t.Errorf("unexpected error: %s", diff)
+	}
+}
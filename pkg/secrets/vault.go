@@ -1,6 +1,7 @@
 package secrets
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"sort"
@@ -31,6 +32,11 @@ func (d dryRunClient) SetFieldOnItem(itemName, fieldName string, fieldValue []by
 	return err
 }
 
+func (d dryRunClient) DeleteFieldOnItem(itemName, fieldName string) error {
+	_, err := fmt.Fprintf(d.file, "ItemName: %s\n\tDeleted field: %s\n", itemName, fieldName)
+	return err
+}
+
 func (d dryRunClient) UpdateNotesOnItem(itemName, notes string) error {
 	_, err := fmt.Fprintf(d.file, "ItemName: %s\n\tNotes: %s\n", itemName, notes)
 	return err
@@ -40,6 +46,10 @@ func (d dryRunClient) GetFieldOnItem(_, _ string) ([]byte, error) {
 	return nil, nil
 }
 
+func (d dryRunClient) GetItemVersion(_ string) (int, error) {
+	return 0, nil
+}
+
 func (d dryRunClient) GetInUseInformationForAllItems(_ string) (map[string]SecretUsageComparer, error) {
 	return nil, nil
 }
@@ -62,10 +72,30 @@ func NewDryRunClient(outputFile *os.File) Client {
 	}
 }
 
+// fieldNotFoundError is returned when an existing item does not have the requested field
+type fieldNotFoundError struct {
+	path, key string
+}
+
+func (e *fieldNotFoundError) Error() string {
+	return fmt.Sprintf("item at path %q has no key %q", e.path, e.key)
+}
+
+// IsNotFound determines if the error is returned because the item or its field do not exist.
+func IsNotFound(err error) bool {
+	var fieldNotFound *fieldNotFoundError
+	return errors.As(err, &fieldNotFound) || vaultclient.IsNotFound(err)
+}
+
 type vaultClient struct {
 	upstream VaultClient
 	prefix   string
 	censor   *DynamicCensor
+
+	// versions holds the version of the items whose fields were read, so that
+	// GetItemVersion does not have to read them again
+	versionsLock sync.Mutex
+	versions     map[string]int
 }
 
 func NewVaultClient(upstream VaultClient, prefix string, censor *DynamicCensor) Client {
@@ -73,22 +103,36 @@ func NewVaultClient(upstream VaultClient, prefix string, censor *DynamicCensor)
 		upstream: upstream,
 		prefix:   prefix,
 		censor:   censor,
+		versions: map[string]int{},
 	}
 }
 
+func (c *vaultClient) recordVersion(item string, version int) {
+	c.versionsLock.Lock()
+	defer c.versionsLock.Unlock()
+	c.versions[item] = version
+}
+
+func (c *vaultClient) forgetVersion(item string) {
+	c.versionsLock.Lock()
+	defer c.versionsLock.Unlock()
+	delete(c.versions, item)
+}
+
 func (c *vaultClient) pathFor(item string) string {
 	return c.prefix + "/" + item
 }
 
-func (c *vaultClient) getKeyAtPath(path, key string) ([]byte, error) {
-	path = c.pathFor(path)
+func (c *vaultClient) getKeyAtPath(item, key string) ([]byte, error) {
+	path := c.pathFor(item)
 	response, err := c.upstream.GetKV(path)
 	if err != nil {
 		return nil, err
 	}
+	c.recordVersion(item, response.Metadata.Version)
 	val, ok := response.Data[key]
 	if !ok {
-		return nil, fmt.Errorf("item at path %q has no key %q", path, key)
+		return nil, &fieldNotFoundError{path: path, key: key}
 	}
 
 	return []byte(val), nil
@@ -102,8 +146,9 @@ func (c *vaultClient) getSecretAtPath(path, key string) ([]byte, error) {
 	return ret, err
 }
 
-func (c *vaultClient) setItemAtPath(path, field string, content string) error {
-	path = c.pathFor(path)
+func (c *vaultClient) setItemAtPath(item, field string, content string) error {
+	c.forgetVersion(item)
+	path := c.pathFor(item)
 	var data map[string]string
 	if current, err := c.upstream.GetKV(path); err != nil {
 		if !vaultclient.IsNotFound(err) {
@@ -134,6 +179,39 @@ func (c *vaultClient) GetFieldOnItem(itemName, fieldName string) ([]byte, error)
 	return c.getSecretAtPath(itemName, fieldName)
 }
 
+func (c *vaultClient) DeleteFieldOnItem(itemName, fieldName string) error {
+	c.forgetVersion(itemName)
+	path := c.pathFor(itemName)
+	current, err := c.upstream.GetKV(path)
+	if err != nil {
+		if vaultclient.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	if _, ok := current.Data[fieldName]; !ok {
+		return nil
+	}
+	delete(current.Data, fieldName)
+	return c.upstream.UpsertKV(path, current.Data)
+}
+
+// GetItemVersion returns the version of the item when its fields were read, it only reads the item if they were not
+func (c *vaultClient) GetItemVersion(itemName string) (int, error) {
+	c.versionsLock.Lock()
+	version, ok := c.versions[itemName]
+	c.versionsLock.Unlock()
+	if ok {
+		return version, nil
+	}
+	response, err := c.upstream.GetKV(c.pathFor(itemName))
+	if err != nil {
+		return 0, err
+	}
+	c.recordVersion(itemName, response.Metadata.Version)
+	return response.Metadata.Version, nil
+}
+
 func (c *vaultClient) UpdateIndexSecret(itemName string, payload []byte) error {
 	return nil
 }
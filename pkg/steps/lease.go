@@ -188,6 +188,10 @@ func (s *leaseStep) acquireLeases(ctx context.Context, cancel context.CancelFunc
 	})
 	var errs []error
 	for _, l := range s.leases {
+		if err := checkDrained(client, l.ResourceType); err != nil {
+			errs = append(errs, err)
+			break
+		}
 		start := time.Now()
 		logrus.Debugf("Acquiring %d lease(s) for %s", l.Count, l.ResourceType)
 		names, err := client.Acquire(l.ResourceType, l.Count, ctx, cancel)
@@ -246,6 +250,20 @@ func releaseLeases(client lease.Client, metricsAgent *metrics.MetricsAgent, leas
 	return utilerrors.NewAggregate(errs)
 }
 
+// checkDrained fails early when the resource type is drained for maintenance, as
+// waiting for a lease would only end when the acquisition times out.
+func checkDrained(client lease.Client, rtype string) error {
+	m, err := client.Metrics(rtype)
+	if err != nil {
+		logrus.WithError(err).Debugf("Could not get resource metrics for %s, cannot check whether it is drained.", rtype)
+		return nil
+	}
+	if m.Drained == 0 {
+		return nil
+	}
+	return results.ForReason("lease_type_drained").ForError(fmt.Errorf("failed to acquire lease for %q: the resource type is drained for maintenance", rtype))
+}
+
 func printResourceMetrics(client lease.Client, rtype string) {
 	m, err := client.Metrics(rtype)
 	if err != nil {
@@ -248,6 +248,34 @@ func TestError(t *testing.T) {
 	}
 }
 
+func TestDrainedLeaseType(t *testing.T) {
+	leases := []api.StepLease{
+		{ResourceType: "rtype0", Count: 1},
+		{ResourceType: "rtype1", Count: 1},
+	}
+	drained := common.NewMetric("rtype1")
+	drained.Owners[lease.DrainOwner] = 2
+	var calls []string
+	client := lease.NewFakeClientWithMetrics("owner", "url", 0, nil, &calls, nil, map[string]common.Metric{"rtype1": drained})
+	s := stepNeedsLease{}
+	err := LeaseStep(&client, leases, &s, func() string { return "" }, nil, nil, nil).Run(context.Background())
+	if err == nil {
+		t.Fatalf("unexpected success, calls: %#v", calls)
+	}
+	if s.ran {
+		t.Error("the wrapped step ran although a lease type is drained")
+	}
+	testhelper.Diff(t, "reasons", results.Reasons(err), []string{"utilizing_lease:lease_type_drained"})
+	testhelper.Diff(t, "error", err.Error(), `failed to acquire lease for "rtype1": the resource type is drained for maintenance`)
+	expected := []string{
+		"acquireWaitWithPriority owner rtype0 free leased random",
+		"releaseone owner rtype0_0 free",
+	}
+	if diff := cmp.Diff(expected, calls); diff != "" {
+		t.Errorf("wrong calls to the lease client: %s", diff)
+	}
+}
+
 func TestAcquireLeases(t *testing.T) {
 	ns := "ci-op-xxx"
 	nsFunc := func() string { return ns }
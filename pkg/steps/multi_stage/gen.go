@@ -15,6 +15,7 @@ import (
 	"sigs.k8s.io/prow/pkg/entrypoint"
 
 	"github.com/openshift/ci-tools/pkg/api"
+	"github.com/openshift/ci-tools/pkg/credentialusage"
 	base_steps "github.com/openshift/ci-tools/pkg/steps"
 	"github.com/openshift/ci-tools/pkg/steps/csi_secrets"
 	"github.com/openshift/ci-tools/pkg/steps/utils"
@@ -190,7 +191,7 @@ func (s *multiStageTestStep) generatePods(
 			}
 		}
 
-		addSecretWrapper(pod, s.vpnConf, !needsKubeConfig, genPodOpts)
+		addSecretWrapper(pod, s.vpnConf, !needsKubeConfig, genPodOpts, step)
 		if s.vpnConf != nil {
 			s.addVPNClient(pod)
 		}
@@ -289,7 +290,7 @@ func isKubeconfigNeeded(step *api.LiteralTestStep, opts *generatePodOptions) boo
 	return needsKubeconfig || opts.IsObserver
 }
 
-func addSecretWrapper(pod *coreapi.Pod, vpnConf *vpnConf, skipKubeconfig bool, genPodOpts *generatePodOptions) {
+func addSecretWrapper(pod *coreapi.Pod, vpnConf *vpnConf, skipKubeconfig bool, genPodOpts *generatePodOptions, step api.LiteralTestStep) {
 	volume := "entrypoint-wrapper"
 	dir := "/tmp/entrypoint-wrapper"
 	bin := path.Join(dir, "entrypoint-wrapper")
@@ -328,6 +329,13 @@ func addSecretWrapper(pod *coreapi.Pod, vpnConf *vpnConf, skipKubeconfig bool, g
 	if genPodOpts.IsObserver {
 		container.Args = append(container.Args, "--mode=observer")
 	}
+	if mounts := credentialusage.MountPaths(step.Credentials); len(mounts) > 0 {
+		// the wrapper records which credentials the step reads
+		container.Args = append(container.Args, "--step-name="+step.As)
+		for _, mount := range mounts {
+			container.Args = append(container.Args, "--credential-mount="+mount)
+		}
+	}
 	container.Args = append(container.Args, container.Command...)
 	container.Args = append(container.Args, args...)
 	container.Command = []string{bin}
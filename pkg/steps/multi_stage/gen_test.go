@@ -449,6 +449,38 @@ func TestGeneratePodBestEffort(t *testing.T) {
 	}
 }
 
+func TestAddSecretWrapperCredentialMounts(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		step     api.LiteralTestStep
+		expected []string
+	}{
+		{
+			name:     "no credentials",
+			step:     api.LiteralTestStep{As: "step"},
+			expected: []string{"/bin/sh", "-c", "true"},
+		},
+		{
+			name: "credentials are watched once per mount path",
+			step: api.LiteralTestStep{As: "step", Credentials: []api.CredentialReference{
+				{Namespace: "ns", Name: "a", MountPath: "/var/run/a/"},
+				{Collection: "c", Group: "g", MountPath: "/var/run/a"},
+				{Bundle: "b", MountPath: "/var/run/b"},
+			}},
+			expected: []string{"--step-name=step", "--credential-mount=/var/run/a", "--credential-mount=/var/run/b", "/bin/sh", "-c", "true"},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			pod := coreapi.Pod{Spec: coreapi.PodSpec{Containers: []coreapi.Container{{Command: []string{"/bin/sh", "-c"}, Args: []string{"true"}}}}}
+			addSecretWrapper(&pod, nil, false, defaultGeneratePodOptions(), testCase.step)
+			if diff := cmp.Diff(testCase.expected, pod.Spec.Containers[0].Args); diff != "" {
+				t.Errorf("unexpected args: %s", diff)
+			}
+		})
+	}
+}
+
 func TestAddCredentials(t *testing.T) {
 	var testCases = []struct {
 		name        string
@@ -19,6 +19,7 @@ import (
 
 	"github.com/openshift/ci-tools/pkg/api"
 	"github.com/openshift/ci-tools/pkg/junit"
+	"github.com/openshift/ci-tools/pkg/results"
 	base_steps "github.com/openshift/ci-tools/pkg/steps"
 	"github.com/openshift/ci-tools/pkg/util"
 )
@@ -74,6 +75,7 @@ func (s *multiStageTestStep) runSteps(
 		testCase.FailureOutput = &junit.FailureOutput{
 			Output: err.Error(),
 		}
+		testCase.Properties = append(testCase.Properties, &junit.Property{Name: results.SignatureProperty, Value: results.Signature(err.Error())})
 	}
 	s.subTests = append(s.subTests, testCase)
 	logrus.Infof("Step phase %s %s after %s.", phase, verb, duration.Truncate(time.Second))
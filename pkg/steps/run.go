@@ -59,7 +59,8 @@ func Run(ctx context.Context, graph api.StepGraph, agent *metrics.MetricsAgent)
 			stepDetails = append(stepDetails, out.stepDetails)
 			if out.err != nil {
 				testCase.FailureOutput = &junit.FailureOutput{Output: out.err.Error()}
-				executionErrors = append(executionErrors, results.ForReason("step_failed").WithError(out.err).Errorf("step %s failed: %v", out.node.Step.Name(), out.err))
+				testCase.Properties = append(testCase.Properties, &junit.Property{Name: results.SignatureProperty, Value: results.Signature(out.err.Error())})
+				executionErrors = append(executionErrors, results.ForReason("step_failed").ForStep(out.node.Step.Name()).WithError(out.err).Errorf("step %s failed: %v", out.node.Step.Name(), out.err))
 			} else {
 				seen = append(seen, out.node.Step.Creates()...)
 				if !interrupted {
@@ -160,6 +160,11 @@ const ciOperatorReferenceYaml = "# The list of base images describe\n" +
 	"canonical_go_repository_list:\n" +
 	"    - ref: ' '\n" +
 	"      repository: ' '\n" +
+	"# Extends lists the named fragments this configuration is layered on, in\n" +
+	"# order. Fragments are partial configurations that are merged under the\n" +
+	"# configuration when it is loaded, after the defaults of its org and repo.\n" +
+	"extends:\n" +
+	"    - \"\"\n" +
 	"# ExternalImages are images that are imported into the pipeline from an external source.\n" +
 	"external_images:\n" +
 	"    \"\":\n" +
@@ -9,6 +9,7 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"time"
 
 	"github.com/pmezard/go-difflib/difflib"
 
@@ -189,6 +190,44 @@ func Boskos(opt BoskosOptions) *Accessory {
 	)
 }
 
+// LocalLeaseServerOptions are options for running the local lease server
+type LocalLeaseServerOptions struct {
+	ConfigPath string
+	// Expiry and ExpiryInterval override the defaults of the server when set
+	Expiry         time.Duration
+	ExpiryInterval time.Duration
+}
+
+// LocalLeaseServer begins the in-memory lease server, which speaks the Boskos
+// protocol and allows to inject failures, and makes sure it is ready to serve.
+func LocalLeaseServer(opt LocalLeaseServerOptions) *Accessory {
+	args := map[string]string{
+		"config": opt.ConfigPath,
+	}
+	if opt.Expiry != 0 {
+		args["expiry"] = opt.Expiry.String()
+	}
+	if opt.ExpiryInterval != 0 {
+		args["expiry-interval"] = opt.ExpiryInterval.String()
+	}
+	return testhelper.NewAccessory("local-lease-server",
+		flags(args),
+		func(port, healthPort string) []string {
+			return flags(map[string]string{
+				"port":        port,
+				"health-port": healthPort,
+			})
+		},
+		func(port, _ string) []string {
+			return flags(map[string]string{
+				"lease-server":                  "http://127.0.0.1:" + port,
+				"lease-server-credentials-file": "/dev/null",
+				"lease-acquire-timeout":         "2s",
+			})
+		},
+	)
+}
+
 // ConfigResolverOptions are options for running the config server
 type ConfigResolverOptions struct {
 	ConfigPath              string
@@ -4,10 +4,22 @@
 package lease
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/boskos/common"
+	"sigs.k8s.io/prow/pkg/interrupts"
+
+	"github.com/openshift/ci-tools/pkg/lease"
+	"github.com/openshift/ci-tools/pkg/testhelper"
 	"github.com/openshift/ci-tools/test/e2e/framework"
 )
 
@@ -113,20 +125,128 @@ func TestLeases(t *testing.T) {
 		},
 	}
 
-	for _, testCase := range testCases {
-		framework.Run(t, testCase.name, func(t *framework.T, cmd *framework.CiOperatorCommand) {
-			cmd.AddArgs(
-				framework.RemotePullSecretFlag(t),
-				"--registry=step-registry",
-				"--config=config.yaml",
-			)
-			cmd.AddArgs(testCase.args...)
-			cmd.AddEnv(testCase.env...)
-			output, err := cmd.Run()
-			if testCase.success != (err == nil) {
-				t.Fatalf("%s: didn't expect an error from ci-operator: %v; output:\n%v", testCase.name, err, string(output))
-			}
-			cmd.VerboseOutputContains(t, testCase.name, testCase.output...)
-		}, framework.Boskos(framework.BoskosOptions{ConfigPath: "boskos.yaml"}))
+	for _, server := range []struct {
+		name      string
+		accessory func() *framework.Accessory
+	}{
+		{name: "boskos", accessory: func() *framework.Accessory {
+			return framework.Boskos(framework.BoskosOptions{ConfigPath: "boskos.yaml"})
+		}},
+		{name: "local lease server", accessory: func() *framework.Accessory {
+			return framework.LocalLeaseServer(framework.LocalLeaseServerOptions{ConfigPath: "boskos.yaml"})
+		}},
+	} {
+		for _, testCase := range testCases {
+			framework.Run(t, fmt.Sprintf("%s with %s", testCase.name, server.name), func(t *framework.T, cmd *framework.CiOperatorCommand) {
+				cmd.AddArgs(
+					framework.RemotePullSecretFlag(t),
+					"--registry=step-registry",
+					"--config=config.yaml",
+				)
+				cmd.AddArgs(testCase.args...)
+				cmd.AddEnv(testCase.env...)
+				output, err := cmd.Run()
+				if testCase.success != (err == nil) {
+					t.Fatalf("%s: didn't expect an error from ci-operator: %v; output:\n%v", testCase.name, err, string(output))
+				}
+				cmd.VerboseOutputContains(t, testCase.name, testCase.output...)
+			}, server.accessory())
+		}
+	}
+}
+
+// TestLeaseHeartbeats runs the lease client against the local lease server, which
+// allows to expire leases and lose them behind the back of their owners.
+func TestLeaseHeartbeats(t *testing.T) {
+	t.Parallel()
+	T := testhelper.NewT(interrupts.Context(), t)
+	server := framework.LocalLeaseServer(framework.LocalLeaseServerOptions{
+		ConfigPath:     "boskos.yaml",
+		Expiry:         2 * time.Second,
+		ExpiryInterval: 100 * time.Millisecond,
+	})
+	server.Run(T)
+	server.Ready(T)
+	var serverURL string
+	for _, flag := range server.ClientFlags() {
+		if value, ok := strings.CutPrefix(flag, "--lease-server="); ok {
+			serverURL = value
+		}
+	}
+
+	acquire := func(t *testing.T, owner string, retries int) (lease.Client, string, context.Context) {
+		client, err := lease.NewClient(owner, serverURL, "", func() []byte { return nil }, retries, time.Minute)
+		if err != nil {
+			t.Fatalf("failed to create the lease client: %v", err)
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		t.Cleanup(cancel)
+		names, err := client.Acquire("aws-quota-slice", 1, ctx, cancel)
+		if err != nil {
+			t.Fatalf("failed to acquire a lease: %v", err)
+		}
+		return client, names[0], ctx
+	}
+
+	t.Run("leases without heartbeats expire", func(t *testing.T) {
+		client, name, ctx := acquire(t, "expiring", 0)
+		if err := wait.PollUntilContextTimeout(context.Background(), 100*time.Millisecond, 30*time.Second, true, func(context.Context) (bool, error) {
+			owner, err := leaseOwner(serverURL, name)
+			return owner == "", err
+		}); err != nil {
+			t.Fatalf("the lease of %s did not expire: %v", name, err)
+		}
+		if err := client.Heartbeat(); err == nil {
+			t.Error("expected the heartbeat of an expired lease to fail")
+		}
+		if ctx.Err() == nil {
+			t.Error("expected the users of the expired lease to be cancelled")
+		}
+		if leases := client.Leases(); len(leases) != 0 {
+			t.Errorf("expected the expired lease to be forgotten, got %v", leases)
+		}
+	})
+
+	t.Run("lost heartbeats are retried before the lease is given up", func(t *testing.T) {
+		client, name, ctx := acquire(t, "losing", 1)
+		if err := client.Heartbeat(); err != nil {
+			t.Fatalf("failed to heartbeat: %v", err)
+		}
+		resp, err := http.Post(serverURL+"/admin/lose?name="+name, "", nil)
+		if err != nil || resp.StatusCode != http.StatusOK {
+			t.Fatalf("failed to lose the lease of %s: %v", name, err)
+		}
+		resp.Body.Close()
+		if err := client.Heartbeat(); err != nil {
+			t.Errorf("expected the first lost heartbeat to be retried, got %v", err)
+		}
+		if ctx.Err() != nil {
+			t.Error("the users of the lease were cancelled before the heartbeat was retried")
+		}
+		if err := client.Heartbeat(); err == nil {
+			t.Error("expected the lease to be given up after the retries")
+		}
+		if ctx.Err() == nil {
+			t.Error("expected the users of the lost lease to be cancelled")
+		}
+	})
+}
+
+// leaseOwner returns the current owner of the resource, or an empty string if it is free
+func leaseOwner(serverURL, name string) (string, error) {
+	resp, err := http.Get(serverURL + "/admin/resources")
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	var resources []common.Resource
+	if err := json.NewDecoder(resp.Body).Decode(&resources); err != nil {
+		return "", fmt.Errorf("failed to decode the resources: %w", err)
+	}
+	for _, resource := range resources {
+		if resource.Name == name {
+			return resource.Owner, nil
+		}
 	}
+	return "", fmt.Errorf("resource %s not found", name)
 }